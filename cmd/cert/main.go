@@ -16,6 +16,7 @@ func main() {
 			cert.Check,
 			cert.Rotate,
 			cert.RotateCA,
+			cert.UndoRotate,
 		),
 	}
 
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/cli/check"
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := cmds.NewApp()
+	app.Commands = []*cli.Command{
+		cmds.NewCheckCommands(
+			check.CIS,
+			check.AuditLog,
+		),
+	}
+
+	cmds.MustRun(app, configfilearg.MustParse(os.Args))
+}
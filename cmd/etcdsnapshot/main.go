@@ -17,6 +17,9 @@ func main() {
 			etcdsnapshot.List,
 			etcdsnapshot.Prune,
 			etcdsnapshot.Save,
+			etcdsnapshot.Restore,
+			etcdsnapshot.Verify,
+			etcdsnapshot.Download,
 		),
 	}
 
@@ -46,18 +46,33 @@ func main() {
 	etcdsnapshotCommand := internalCLIAction(version.Program+"-"+cmds.EtcdSnapshotCommand, dataDir, os.Args)
 	secretsencryptCommand := internalCLIAction(version.Program+"-"+cmds.SecretsEncryptCommand, dataDir, os.Args)
 	certCommand := internalCLIAction(version.Program+"-"+cmds.CertCommand, dataDir, os.Args)
+	migrateCommand := internalCLIAction(version.Program+"-"+cmds.MigrateCommand, dataDir, os.Args)
+	checkCommand := internalCLIAction(version.Program+"-"+cmds.CheckCommand, dataDir, os.Args)
+	checkConfigCommand := internalCLIAction(version.Program+"-"+cmds.CheckConfigCommand, dataDir, os.Args)
+	nodeCommand := internalCLIAction(version.Program+"-"+cmds.NodeCommand, dataDir, os.Args)
+	etcdCommand := internalCLIAction(version.Program+"-"+cmds.EtcdCommand, dataDir, os.Args)
+	debugCommand := internalCLIAction(version.Program+"-"+cmds.DebugCommand, dataDir, os.Args)
+	checkpointCommand := internalCLIAction(version.Program+"-"+cmds.CheckpointCommand, dataDir, os.Args)
+	statusCommand := internalCLIAction(version.Program+"-"+cmds.StatusCommand, dataDir, os.Args)
+	kubeconfigCommand := internalCLIAction(version.Program+"-"+cmds.KubeconfigCommand, dataDir, os.Args)
+	configCommand := internalCLIAction(version.Program+"-"+cmds.ConfigCommand, dataDir, os.Args)
 
 	// Handle subcommand invocation (k3s server, k3s crictl, etc)
 	app := cmds.NewApp()
 	app.EnableBashCompletion = true
 	app.DisableSliceFlagSeparator = true
 	app.Commands = []*cli.Command{
-		cmds.NewServerCommand(internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args)),
+		cmds.NewServerCommand(
+			internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args),
+			internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args),
+			internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args),
+			internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args),
+		),
 		cmds.NewAgentCommand(internalCLIAction(version.Program+"-agent"+programPostfix, dataDir, os.Args)),
 		cmds.NewKubectlCommand(externalCLIAction("kubectl", dataDir)),
 		cmds.NewCRICTL(externalCLIAction("crictl", dataDir)),
 		cmds.NewCtrCommand(externalCLIAction("ctr", dataDir)),
-		cmds.NewCheckConfigCommand(externalCLIAction("check-config", dataDir)),
+		cmds.NewCheckConfigCommand(checkConfigCommand),
 		cmds.NewTokenCommands(
 			tokenCommand,
 			tokenCommand,
@@ -70,6 +85,9 @@ func main() {
 			etcdsnapshotCommand,
 			etcdsnapshotCommand,
 			etcdsnapshotCommand,
+			etcdsnapshotCommand,
+			etcdsnapshotCommand,
+			etcdsnapshotCommand,
 		),
 		cmds.NewSecretsEncryptCommands(
 			secretsencryptCommand,
@@ -84,7 +102,33 @@ func main() {
 			certCommand,
 			certCommand,
 			certCommand,
+			certCommand,
+		),
+		cmds.NewMigrateCommands(
+			migrateCommand,
+			migrateCommand,
+		),
+		cmds.NewCheckCommands(
+			checkCommand,
+			checkCommand,
+		),
+		cmds.NewNodeCommands(
+			nodeCommand,
+			nodeCommand,
+			nodeCommand,
+			nodeCommand,
+			nodeCommand,
+		),
+		cmds.NewEtcdCommands(
+			etcdCommand,
+		),
+		cmds.NewDebugCommands(
+			debugCommand,
 		),
+		cmds.NewCheckpointCommand(checkpointCommand),
+		cmds.NewStatusCommand(statusCommand),
+		cmds.NewKubeconfigCommands(kubeconfigCommand),
+		cmds.NewConfigCommands(configCommand, configCommand),
 		cmds.NewCompletionCommand(
 			internalCLIAction(version.Program+"-completion", dataDir, os.Args),
 			internalCLIAction(version.Program+"-completion", dataDir, os.Args),
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/cli/kubeconfig"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := cmds.NewApp()
+	app.Commands = []*cli.Command{
+		cmds.NewKubeconfigCommands(
+			kubeconfig.Generate,
+		),
+	}
+
+	cmds.MustRun(app, configfilearg.MustParse(os.Args))
+}
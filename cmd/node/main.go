@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/cli/node"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := cmds.NewApp()
+	app.Commands = []*cli.Command{
+		cmds.NewNodeCommands(
+			node.Cordon,
+			node.Uncordon,
+			node.Drain,
+			node.Promote,
+			node.Approve,
+		),
+	}
+
+	cmds.MustRun(app, configfilearg.MustParse(os.Args))
+}
@@ -1,5 +1,5 @@
 //go:generate go run pkg/codegen/cleanup/main.go
-//go:generate rm -rf pkg/generated
+//go:generate rm -rf pkg/generated pkg/apis/generated
 //go:generate go run pkg/codegen/main.go
 
 package main
@@ -10,13 +10,23 @@ import (
 
 	"github.com/k3s-io/k3s/pkg/cli/agent"
 	"github.com/k3s-io/k3s/pkg/cli/cert"
+	"github.com/k3s-io/k3s/pkg/cli/check"
+	"github.com/k3s-io/k3s/pkg/cli/checkconfig"
+	"github.com/k3s-io/k3s/pkg/cli/checkpoint"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/cli/completion"
+	"github.com/k3s-io/k3s/pkg/cli/config"
 	"github.com/k3s-io/k3s/pkg/cli/crictl"
+	"github.com/k3s-io/k3s/pkg/cli/debug"
+	"github.com/k3s-io/k3s/pkg/cli/etcd"
 	"github.com/k3s-io/k3s/pkg/cli/etcdsnapshot"
+	"github.com/k3s-io/k3s/pkg/cli/kubeconfig"
 	"github.com/k3s-io/k3s/pkg/cli/kubectl"
+	"github.com/k3s-io/k3s/pkg/cli/migrate"
+	"github.com/k3s-io/k3s/pkg/cli/node"
 	"github.com/k3s-io/k3s/pkg/cli/secretsencrypt"
 	"github.com/k3s-io/k3s/pkg/cli/server"
+	"github.com/k3s-io/k3s/pkg/cli/status"
 	"github.com/k3s-io/k3s/pkg/configfilearg"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/executor/embed"
@@ -40,7 +50,7 @@ func main() {
 	app := cmds.NewApp()
 	app.DisableSliceFlagSeparator = true
 	app.Commands = []*cli.Command{
-		cmds.NewServerCommand(initExecutor(server.Run)),
+		cmds.NewServerCommand(initExecutor(server.Run), server.Decommission, server.BootstrapExport, server.BootstrapImport),
 		cmds.NewAgentCommand(initExecutor(agent.Run)),
 		cmds.NewKubectlCommand(kubectl.Run),
 		cmds.NewCRICTL(crictl.Run),
@@ -49,6 +59,9 @@ func main() {
 			etcdsnapshot.List,
 			etcdsnapshot.Prune,
 			etcdsnapshot.Save,
+			initExecutor(etcdsnapshot.Restore),
+			etcdsnapshot.Verify,
+			etcdsnapshot.Download,
 		),
 		cmds.NewSecretsEncryptCommands(
 			secretsencrypt.Status,
@@ -63,6 +76,38 @@ func main() {
 			cert.Check,
 			cert.Rotate,
 			cert.RotateCA,
+			cert.UndoRotate,
+		),
+		cmds.NewMigrateCommands(
+			migrate.ClusterDomain,
+			migrate.ServiceCIDR,
+		),
+		cmds.NewCheckCommands(
+			check.CIS,
+			check.AuditLog,
+		),
+		cmds.NewCheckConfigCommand(checkconfig.Run),
+		cmds.NewNodeCommands(
+			node.Cordon,
+			node.Uncordon,
+			node.Drain,
+			node.Promote,
+			node.Approve,
+		),
+		cmds.NewEtcdCommands(
+			etcd.Defrag,
+		),
+		cmds.NewDebugCommands(
+			debug.SetLogLevel,
+		),
+		cmds.NewCheckpointCommand(checkpoint.Run),
+		cmds.NewStatusCommand(status.Run),
+		cmds.NewKubeconfigCommands(
+			kubeconfig.Generate,
+		),
+		cmds.NewConfigCommands(
+			config.Migrate,
+			config.Export,
 		),
 		cmds.NewCompletionCommand(
 			completion.Bash,
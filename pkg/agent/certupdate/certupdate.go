@@ -0,0 +1,156 @@
+package certupdate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	agentutil "github.com/k3s-io/k3s/pkg/agent/util"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Address is the address dialed by servers through the agent tunnel to push re-issued kubelet certificates.
+// It is not a real address; connections to it are served in memory by the agent.
+// As the tunnel server only dials agents by IP address or on behalf of a node name,
+// the address cannot be reached through the apiserver's egress proxy.
+const Address = "cert-update.invalid:80"
+
+// Update is sent by servers to push re-issued kubelet client and serving certificates, along with their
+// new private keys, to an agent. All fields are PEM encoded.
+type Update struct {
+	ClientCert  string `json:"clientCert"`
+	ClientKey   string `json:"clientKey"`
+	ServingCert string `json:"servingCert"`
+	ServingKey  string `json:"servingKey"`
+}
+
+// Handler handles certificate updates pushed by servers. The certificates are validated against the node name
+// and the cluster CAs, and written over the current kubelet certificates and keys. The kubelet kubeconfig is
+// regenerated to reference them. The kubelet watches its certificate files, and reloads them when they change.
+type Handler struct {
+	node *config.Node
+}
+
+// NewHandler returns a handler for certificate updates pushed to the agent.
+func NewHandler(node *config.Node) *Handler {
+	return &Handler{node: node}
+}
+
+// Dial returns a connection that is served in memory by the handler.
+func (h *Handler) Dial() net.Conn {
+	return agentutil.ServeConn(h)
+}
+
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+		return
+	}
+	update := Update{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 1024*1024)).Decode(&update); err != nil {
+		util.SendError(err, resp, req, http.StatusBadRequest)
+		return
+	}
+
+	restConfig, err := util.GetRESTConfig(h.node.AgentConfig.KubeConfigKubelet)
+	if err != nil {
+		util.SendError(err, resp, req, http.StatusInternalServerError)
+		return
+	}
+	if err := Validate(&update, h.node.AgentConfig.NodeName, h.node.AgentConfig.ClientCA, restConfig.TLSClientConfig.CAFile); err != nil {
+		util.SendError(err, resp, req, http.StatusBadRequest)
+		return
+	}
+
+	agent := &h.node.AgentConfig
+	for _, file := range []struct {
+		path string
+		data string
+	}{
+		{path: agent.ClientKubeletKey, data: update.ClientKey},
+		{path: agent.ClientKubeletCert, data: update.ClientCert},
+		{path: agent.ServingKubeletKey, data: update.ServingKey},
+		{path: agent.ServingKubeletCert, data: update.ServingCert},
+	} {
+		if err := util.AtomicWrite(file.path, []byte(file.data), 0600); err != nil {
+			util.SendError(errors.WithMessagef(err, "failed to write %s", file.path), resp, req, http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := deps.KubeConfig(agent.KubeConfigKubelet, restConfig.Host, restConfig.TLSClientConfig.CAFile, agent.ClientKubeletCert, agent.ClientKubeletKey); err != nil {
+		util.SendError(errors.WithMessagef(err, "failed to write %s", agent.KubeConfigKubelet), resp, req, http.StatusInternalServerError)
+		return
+	}
+
+	logrus.Infof("Stored re-issued kubelet certificates pushed by server in %s and %s", agent.ClientKubeletCert, agent.ServingKubeletCert)
+	resp.WriteHeader(http.StatusOK)
+}
+
+// Validate checks that each certificate matches its key, that the client certificate identifies the node and is
+// signed by the client CA, and that the serving certificate is valid for the node name and is signed by the server CA.
+func Validate(update *Update, nodeName, clientCAFile, serverCAFile string) error {
+	clientCert, clientIntermediates, err := parseKeyPair(update.ClientCert, update.ClientKey)
+	if err != nil {
+		return errors.WithMessage(err, "invalid client certificate")
+	}
+	if cn := clientCert.Subject.CommonName; cn != "system:node:"+nodeName {
+		return errors.New("client certificate is for " + cn + ", not node " + nodeName)
+	}
+	if err := verify(clientCert, clientIntermediates, clientCAFile, x509.ExtKeyUsageClientAuth); err != nil {
+		return errors.WithMessage(err, "invalid client certificate")
+	}
+
+	servingCert, servingIntermediates, err := parseKeyPair(update.ServingCert, update.ServingKey)
+	if err != nil {
+		return errors.WithMessage(err, "invalid serving certificate")
+	}
+	if err := servingCert.VerifyHostname(nodeName); err != nil {
+		return errors.WithMessage(err, "invalid serving certificate")
+	}
+	if err := verify(servingCert, servingIntermediates, serverCAFile, x509.ExtKeyUsageServerAuth); err != nil {
+		return errors.WithMessage(err, "invalid serving certificate")
+	}
+	return nil
+}
+
+// parseKeyPair checks that the certificate matches the key, and returns the leaf certificate
+// along with any intermediate certificates included in the bundle.
+func parseKeyPair(certPEM, keyPEM string) (*x509.Certificate, *x509.CertPool, error) {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, nil, err
+	}
+	certs := make([]*x509.Certificate, len(pair.Certificate))
+	for i, der := range pair.Certificate {
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, nil, err
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	return certs[0], intermediates, nil
+}
+
+// verify checks that the certificate is signed by a CA in the file, and is valid for the usage.
+func verify(cert *x509.Certificate, intermediates *x509.CertPool, caFile string, usage x509.ExtKeyUsage) error {
+	caBytes, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBytes) {
+		return errors.New("no CA certificates found in " + caFile)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{usage}})
+	return err
+}
@@ -22,12 +22,17 @@ import (
 	"time"
 
 	"github.com/k3s-io/k3s/pkg/agent/proxy"
+	"github.com/k3s-io/k3s/pkg/agent/tokenupdate"
 	agentutil "github.com/k3s-io/k3s/pkg/agent/util"
+	"github.com/k3s-io/k3s/pkg/authenticator/cloudidentity"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/ipam"
+	"github.com/k3s-io/k3s/pkg/secretstore"
 	"github.com/k3s-io/k3s/pkg/spegel"
+	"github.com/k3s-io/k3s/pkg/tpm"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
@@ -35,6 +40,8 @@ import (
 	"github.com/rancher/wharfie/pkg/registries"
 	"github.com/rancher/wrangler/pkg/slice"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/wait"
 	utilsnet "k8s.io/utils/net"
@@ -49,28 +56,23 @@ var InstanceMetadataServiceIP = net.ParseIP("169.254.169.254")
 // Get returns a pointer to a completed Node configuration struct,
 // containing a merging of the local CLI configuration with settings from the server.
 // Node configuration includes client certificates, which requires node password verification,
-// so this is somewhat computationally expensive on the server side, and is retried with jitter
-// to avoid having clients hammer on the server at fixed periods.
-// A call to this will bock until agent configuration is successfully returned by the
-// server, or the context is cancelled.
+// so this is somewhat computationally expensive on the server side, and is retried with
+// exponential backoff and jitter to avoid having clients hammer on the server at fixed periods.
+// A call to this will block until agent configuration is successfully returned by the
+// server, the context is cancelled, or the configured server retry limit is reached.
 func Get(ctx context.Context, agent cmds.Agent, proxy proxy.Proxy) (*config.Node, error) {
 	var agentConfig *config.Node
-	var err error
-
-	// This would be more clear as wait.PollImmediateUntilWithContext, but that function
-	// does not support jittering, so we instead use wait.JitterUntilWithContext, and cancel
-	// the context on success.
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+	retry := config.ServerRetry{
+		Limit:       agent.ServerRetryLimit,
+		Interval:    metav1.Duration{Duration: agent.ServerRetryInterval},
+		MaxInterval: metav1.Duration{Duration: agent.ServerRetryMaxInterval},
+		Jitter:      agent.ServerRetryJitter,
+	}
+	err := clientaccess.RetryWithBackoff(ctx, retry, "Waiting to retrieve agent configuration; server is not ready", func(ctx context.Context) error {
+		var err error
 		agentConfig, err = get(ctx, &agent, proxy)
-		if err != nil {
-			logrus.Infof("Waiting to retrieve agent configuration; server is not ready: %v", err)
-		} else {
-			cancel()
-		}
-	}, 5*time.Second, 1.0, true)
+		return err
+	})
 	return agentConfig, err
 }
 
@@ -135,7 +137,15 @@ func Request(path string, info *clientaccess.Info, requester HTTPRequester) ([]b
 	return requester(u.String(), clientaccess.GetHTTPClient(info.CACerts, info.CertFile, info.KeyFile), info.Username, info.Password, info.Token())
 }
 
-func getNodeNamedCrt(nodeName string, nodeIPs []net.IP, nodePasswordFile string, csr []byte) HTTPRequester {
+// nodeCredentials are used to authenticate requests for node-named resources from the supervisor.
+type nodeCredentials struct {
+	// passwordFile is the path to the node password file, which is created if it does not exist
+	passwordFile string
+	// tpmDevice is the path to the TPM used to sign requests, if TPM key pinning is enabled
+	tpmDevice string
+}
+
+func getNodeNamedCrt(nodeName string, nodeIPs []net.IP, creds nodeCredentials, csr []byte) HTTPRequester {
 	return func(u string, client *http.Client, username, password, token string) ([]byte, error) {
 		req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(csr))
 		if err != nil {
@@ -149,13 +159,25 @@ func getNodeNamedCrt(nodeName string, nodeIPs []net.IP, nodePasswordFile string,
 		}
 
 		req.Header.Set(version.Program+"-Node-Name", nodeName)
-		nodePassword, err := ensureNodePassword(nodePasswordFile)
+		nodePassword, err := ensureNodePassword(creds.passwordFile)
 		if err != nil {
 			return nil, err
 		}
 		req.Header.Set(version.Program+"-Node-Password", nodePassword)
 		req.Header.Set(version.Program+"-Node-IP", util.JoinIPs(nodeIPs))
 
+		if creds.tpmDevice != "" {
+			proof, err := tpm.Prove(creds.tpmDevice, nodeName, csr)
+			if err != nil {
+				return nil, err
+			}
+			value, err := proof.Encode()
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(tpm.HeaderName, value)
+		}
+
 		resp, err := client.Do(req)
 		if err != nil {
 			return nil, err
@@ -176,8 +198,11 @@ func getNodeNamedCrt(nodeName string, nodeIPs []net.IP, nodePasswordFile string,
 			}
 		}
 
+		if resp.StatusCode == http.StatusForbidden && creds.tpmDevice != "" {
+			return nil, fmt.Errorf("Node password or TPM key rejected, duplicate hostname, contents of '%s', or TPM key may not match those recorded by the server", creds.passwordFile)
+		}
 		if resp.StatusCode == http.StatusForbidden {
-			return nil, fmt.Errorf("Node password rejected, duplicate hostname or contents of '%s' may not match server node-passwd entry, try enabling a unique node name with the --with-node-id flag", nodePasswordFile)
+			return nil, fmt.Errorf("Node password rejected, duplicate hostname or contents of '%s' may not match server node-passwd entry, try enabling a unique node name with the --with-node-id flag", creds.passwordFile)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -244,14 +269,14 @@ func upgradeOldNodePasswordPath(oldNodePasswordFile, newNodePasswordFile string)
 // from the server.  We attempt to POST a CSR to the server, in hopes that it will
 // sign the cert using our locally generated key. If the server does not support CSR
 // signing, the key generated by the server is used instead.
-func getKubeletServingCert(nodeName string, nodeIPs []net.IP, certFile, keyFile, nodePasswordFile string, info *clientaccess.Info) error {
+func getKubeletServingCert(nodeName string, nodeIPs []net.IP, certFile, keyFile string, creds nodeCredentials, info *clientaccess.Info) error {
 	csr, err := getCSRBytes(keyFile)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to create certificate request %s", certFile)
 	}
 
 	basename := filepath.Base(certFile)
-	body, err := Request("/v1-"+version.Program+"/"+basename, info, getNodeNamedCrt(nodeName, nodeIPs, nodePasswordFile, csr))
+	body, err := Request("/v1-"+version.Program+"/"+basename, info, getNodeNamedCrt(nodeName, nodeIPs, creds, csr))
 	if err != nil {
 		return err
 	}
@@ -272,6 +297,16 @@ func getKubeletServingCert(nodeName string, nodeIPs []net.IP, certFile, keyFile,
 }
 
 // getHostFile fills a file with content returned from the server.
+// getNodeIPAM requests addresses for the node from the external IPAM webhook, via the server.
+func getNodeIPAM(nodeName string, nodeIPs []net.IP, creds nodeCredentials, info *clientaccess.Info) (*ipam.Response, error) {
+	body, err := Request("/v1-"+version.Program+"/ipam", info, getNodeNamedCrt(nodeName, nodeIPs, creds, nil))
+	if err != nil {
+		return nil, err
+	}
+	ipamResp := &ipam.Response{}
+	return ipamResp, json.Unmarshal(body, ipamResp)
+}
+
 func getHostFile(filename string, info *clientaccess.Info) error {
 	basename := filepath.Base(filename)
 	fileBytes, err := info.Get("/v1-" + version.Program + "/" + basename)
@@ -288,14 +323,15 @@ func getHostFile(filename string, info *clientaccess.Info) error {
 // We attempt to POST a CSR to the server, in hopes that it will sign the cert using
 // our locally generated key. If the server does not support CSR signing, the key
 // generated by the server is used instead.
-func getClientCert(certFile, keyFile string, info *clientaccess.Info) error {
+// The node name and password are sent so that the request can be authorized when using a limited-use token.
+func getClientCert(certFile, keyFile, nodeName string, nodeIPs []net.IP, creds nodeCredentials, info *clientaccess.Info) error {
 	csr, err := getCSRBytes(keyFile)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to create certificate request %s", certFile)
 	}
 
 	basename := filepath.Base(certFile)
-	fileBytes, err := info.Post("/v1-"+version.Program+"/"+basename, csr)
+	fileBytes, err := Request("/v1-"+version.Program+"/"+basename, info, getNodeNamedCrt(nodeName, nodeIPs, creds, csr))
 	if err != nil {
 		return err
 	}
@@ -349,14 +385,14 @@ func splitCertKeyPEM(bytes []byte) (certPem []byte, keyPem []byte) {
 // from the server.  We attempt to POST a CSR to the server, in hopes that it will
 // sign the cert using our locally generated key. If the server does not support CSR
 // signing, the key generated by the server is used instead.
-func getKubeletClientCert(certFile, keyFile, nodeName string, nodeIPs []net.IP, nodePasswordFile string, info *clientaccess.Info) error {
+func getKubeletClientCert(certFile, keyFile, nodeName string, nodeIPs []net.IP, creds nodeCredentials, info *clientaccess.Info) error {
 	csr, err := getCSRBytes(keyFile)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to create certificate request %s", certFile)
 	}
 
 	basename := filepath.Base(certFile)
-	body, err := Request("/v1-"+version.Program+"/"+basename, info, getNodeNamedCrt(nodeName, nodeIPs, nodePasswordFile, csr))
+	body, err := Request("/v1-"+version.Program+"/"+basename, info, getNodeNamedCrt(nodeName, nodeIPs, creds, csr))
 	if err != nil {
 		return err
 	}
@@ -436,6 +472,60 @@ func locateOrGenerateResolvConf(envInfo *cmds.Agent) string {
 	return resolvConf
 }
 
+// applyDNSSearchPolicy renders a resolv.conf for the kubelet with the search domains from the server configuration,
+// and returns its path. If there are no search domains to add or remove, or the resolv.conf has been set by the user,
+// the provided resolv.conf is returned unchanged.
+func applyDNSSearchPolicy(envInfo *cmds.Agent, resolvConf string, search []string, policy string) string {
+	if resolvConf == "" || (len(search) == 0 && policy != config.DNSSearchPolicyReplace) {
+		return resolvConf
+	}
+	if envInfo.ResolvConf != "" {
+		logrus.Warnf("Not applying DNS search domains from server configuration, as resolv-conf is set")
+		return resolvConf
+	}
+	content, err := renderResolvConf(resolvConf, search, policy)
+	if err != nil {
+		logrus.Errorf("Failed to read %s: %v", resolvConf, err)
+		return resolvConf
+	}
+	searchResolvConf := filepath.Join(envInfo.DataDir, "agent", "etc", "resolv-search.conf")
+	if err := agentutil.WriteFile(searchResolvConf, content); err != nil {
+		logrus.Errorf("Failed to write %s: %v", searchResolvConf, err)
+		return resolvConf
+	}
+	logrus.Infof("Kubelet will use resolv.conf with search domains from server configuration at %s", searchResolvConf)
+	return searchResolvConf
+}
+
+// renderResolvConf returns the content of a resolv.conf file, with nameservers and options copied from the source file.
+// Search domains from the source file are retained if the policy is to append, followed by the provided search domains.
+func renderResolvConf(resolvConf string, search []string, policy string) (string, error) {
+	b, err := os.ReadFile(resolvConf)
+	if err != nil {
+		return "", err
+	}
+	var domains, lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") {
+			// Only the last search or domain line is used by the resolver
+			if policy != config.DNSSearchPolicyReplace {
+				domains = fields[1:]
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for _, domain := range search {
+		if !slice.ContainsString(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) > 0 {
+		lines = append(lines, "search "+strings.Join(domains, " "))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
 func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.Node, error) {
 	if envInfo.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -443,16 +533,55 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	clientKubeletCert := filepath.Join(envInfo.DataDir, "agent", "client-kubelet.crt")
 	clientKubeletKey := filepath.Join(envInfo.DataDir, "agent", "client-kubelet.key")
 	withCert := clientaccess.WithClientCertificate(clientKubeletCert, clientKubeletKey)
+
+	// Tokens read from a secret store are read again once the refresh interval has passed,
+	// so that a token rotated in the store is used on the next attempt to retrieve the config.
+	if envInfo.TokenSource != "" {
+		token, err := secretstore.Read(ctx, envInfo.TokenSource, envInfo.TokenRefresh)
+		if err != nil {
+			return nil, err
+		}
+		envInfo.Token = token
+	}
+
+	// Cloud identity credentials are short-lived, so a new one is retrieved each time the config is requested.
+	if envInfo.CloudIdentity != "" {
+		password, err := cloudidentity.Password(ctx, envInfo.CloudIdentity, envInfo.CloudAudience, clientaccess.CAHash(envInfo.Token))
+		if err != nil {
+			return nil, err
+		}
+		envInfo.Token = clientaccess.ReplacePassword(envInfo.Token, password)
+	}
+
 	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), envInfo.Token, withCert)
 	if err != nil {
 		return nil, err
 	}
 
+	// Tokens pushed by a server after the cluster token was rotated are stored, and used in place of the configured
+	// token. Tokens read from a secret store, cloud identity credentials, and bootstrap tokens are not replaced.
+	var tokenFile string
+	if envInfo.TokenSource == "" && envInfo.CloudIdentity == "" && info.BootstrapTokenString == nil {
+		tokenFile = filepath.Join(envInfo.DataDir, "agent", "rotated-token")
+		if token := tokenupdate.Load(tokenFile, info.String()); token != "" {
+			logrus.Infof("Using rotated token from %s", tokenFile)
+			info, err = clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), token, withCert)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	controlConfig, err := getConfig(info)
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed to retrieve configuration from server")
 	}
 
+	componentEnv, err := cmds.ParseComponentEnv(envInfo.ComponentEnv.Value())
+	if err != nil {
+		return nil, err
+	}
+
 	nodeName, nodeIPs, err := util.GetHostnameAndIPs(envInfo.NodeName, envInfo.NodeIP.Value())
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed to get node name and addresses")
@@ -491,13 +620,9 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	oldNodePasswordFile := filepath.Join(envInfo.DataDir, "agent", "node-password.txt")
 	newNodePasswordFile := filepath.Join(nodeConfigPath, "password")
 	upgradeOldNodePasswordPath(oldNodePasswordFile, newNodePasswordFile)
-
-	if controlConfig.ClusterIPRange != nil {
-		if utilsnet.IPFamilyOfCIDR(controlConfig.ClusterIPRange) != utilsnet.IPFamilyOf(nodeIPs[0]) && len(nodeIPs) > 1 {
-			firstNodeIP := nodeIPs[0]
-			nodeIPs[0] = nodeIPs[1]
-			nodeIPs[1] = firstNodeIP
-		}
+	creds := nodeCredentials{passwordFile: newNodePasswordFile}
+	if envInfo.TPMKeyPinning {
+		creds.tpmDevice = envInfo.TPMDevice
 	}
 
 	nodeExternalIPs, err := util.ParseStringSliceToIPs(envInfo.NodeExternalIP.Value())
@@ -513,6 +638,31 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 		nodeName += "-" + nodeID
 	}
 
+	if controlConfig.ExternalIPAM {
+		ipamResp, err := getNodeIPAM(nodeName, nodeIPs, creds, info)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to retrieve node addresses from external IPAM")
+		}
+		if len(ipamResp.NodeIPs) > 0 {
+			if len(envInfo.NodeIP.Value()) > 0 {
+				logrus.Warnf("Ignoring node IPs %v allocated by external IPAM, as node-ip is set", ipamResp.NodeIPs)
+			} else {
+				if nodeIPs, err = util.ParseStringSliceToIPs(ipamResp.NodeIPs); err != nil {
+					return nil, errors.WithMessage(err, "invalid node IPs allocated by external IPAM")
+				}
+				logrus.Infof("Using node IPs %v allocated by external IPAM", ipamResp.NodeIPs)
+			}
+		}
+	}
+
+	if controlConfig.ClusterIPRange != nil {
+		if utilsnet.IPFamilyOfCIDR(controlConfig.ClusterIPRange) != utilsnet.IPFamilyOf(nodeIPs[0]) && len(nodeIPs) > 1 {
+			firstNodeIP := nodeIPs[0]
+			nodeIPs[0] = nodeIPs[1]
+			nodeIPs[1] = firstNodeIP
+		}
+	}
+
 	os.Setenv("NODE_NAME", nodeName)
 
 	kubeconfigKubelet := filepath.Join(envInfo.DataDir, "agent", "kubelet.kubeconfig")
@@ -537,6 +687,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 		SupervisorPort:           controlConfig.SupervisorPort,
 		SupervisorMetrics:        controlConfig.SupervisorMetrics,
 		Token:                    info.String(),
+		TokenFile:                tokenFile,
 		Flannel: config.Flannel{
 			Backend:    controlConfig.FlannelBackend,
 			IPv6Masq:   controlConfig.FlannelIPv6Masq,
@@ -552,7 +703,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeConfig.AgentConfig.ServingKubeletKey = servingKubeletKey
 	nodeConfig.AgentConfig.ClusterDNS = controlConfig.ClusterDNS
 	nodeConfig.AgentConfig.ClusterDomain = controlConfig.ClusterDomain
-	nodeConfig.AgentConfig.ResolvConf = locateOrGenerateResolvConf(envInfo)
+	nodeConfig.AgentConfig.ResolvConf = applyDNSSearchPolicy(envInfo, locateOrGenerateResolvConf(envInfo), controlConfig.AgentDNSSearch, controlConfig.AgentDNSSearchPolicy)
 	nodeConfig.AgentConfig.ClientCA = clientCAFile
 	nodeConfig.AgentConfig.KubeletConfig = kubeletConfig
 	nodeConfig.AgentConfig.KubeConfigKubelet = kubeconfigKubelet
@@ -560,6 +711,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeConfig.AgentConfig.KubeConfigK3sController = kubeconfigK3sController
 	nodeConfig.AgentConfig.Snapshotter = envInfo.Snapshotter
 	nodeConfig.AgentConfig.IPSECPSK = controlConfig.IPSECPSK
+	nodeConfig.AgentConfig.MixedOS = controlConfig.MixedOS
 	nodeConfig.Containerd.Config = filepath.Join(envInfo.DataDir, "agent", "etc", "containerd", "config.toml")
 	nodeConfig.Containerd.Root = filepath.Join(envInfo.DataDir, "agent", "containerd")
 	nodeConfig.CRIDockerd.Root = filepath.Join(envInfo.DataDir, "agent", "cri-dockerd")
@@ -570,6 +722,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeConfig.Containerd.NoDefault = envInfo.ContainerdNoDefault
 	nodeConfig.Containerd.NonrootDevices = envInfo.ContainerdNonrootDevices
 	nodeConfig.Containerd.Debug = envInfo.Debug
+	nodeConfig.Containerd.Env = componentEnv[cmds.EnvComponentContainerd]
 	nodeConfig.Containerd.Template = filepath.Join(envInfo.DataDir, "agent", "etc", "containerd", "config.toml.tmpl")
 
 	if envInfo.Rootless {
@@ -673,6 +826,9 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	}
 
 	nodeConfig.AgentConfig.ExtraKubeletArgs = envInfo.ExtraKubeletArgs.Value()
+	if err := setKubeletAddresses(nodeConfig, envInfo); err != nil {
+		return nil, err
+	}
 	nodeConfig.AgentConfig.ExtraKubeProxyArgs = envInfo.ExtraKubeProxyArgs.Value()
 	nodeConfig.AgentConfig.NodeTaints = envInfo.Taints.Value()
 	nodeConfig.AgentConfig.NodeLabels = envInfo.Labels.Value()
@@ -685,6 +841,23 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeConfig.AgentConfig.Rootless = envInfo.Rootless
 	nodeConfig.AgentConfig.PodManifests = filepath.Join(envInfo.DataDir, "agent", DefaultPodManifestPath)
 	nodeConfig.AgentConfig.ProtectKernelDefaults = envInfo.ProtectKernelDefaults
+	nodeConfig.AgentConfig.Containerized = envInfo.Containerized
+	nodeConfig.AgentConfig.ContainerCheckpoint = envInfo.ContainerCheckpoint
+	nodeConfig.AgentConfig.WarmStandby = envInfo.WarmStandby
+	nodeConfig.AgentConfig.WarmStandbyInterval = metav1.Duration{Duration: envInfo.WarmStandbyInterval}
+	nodeConfig.AgentConfig.ImageMirrorList = envInfo.ImageMirrorList
+	nodeConfig.AgentConfig.ImageMirrorInterval = metav1.Duration{Duration: envInfo.ImageMirrorInterval}
+	nodeConfig.AgentConfig.DiskPressureActions = util.SplitStringSlice(envInfo.DiskPressureActions.Value())
+	nodeConfig.AgentConfig.DiskPressureThreshold = envInfo.DiskPressureThreshold
+	nodeConfig.AgentConfig.DiskPressureInterval = metav1.Duration{Duration: envInfo.DiskPressureInterval}
+	nodeConfig.AgentConfig.DiskPressureDryRun = envInfo.DiskPressureDryRun
+	if envInfo.DiskPressureLogMaxSize != "" {
+		logMaxSize, err := resource.ParseQuantity(envInfo.DiskPressureLogMaxSize)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid disk-pressure-log-max-size")
+		}
+		nodeConfig.AgentConfig.DiskPressureLogMaxSize = logMaxSize.Value()
+	}
 	nodeConfig.AgentConfig.DisableServiceLB = envInfo.DisableServiceLB
 	nodeConfig.AgentConfig.VLevel = cmds.LogConfig.VLevel
 	nodeConfig.AgentConfig.VModule = cmds.LogConfig.VModule
@@ -730,12 +903,12 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeExternalAndInternalIPs := append(nodeConfig.AgentConfig.NodeIPs, nodeConfig.AgentConfig.NodeExternalIPs...)
 
 	// Ask the server to sign our kubelet server cert.
-	if err := getKubeletServingCert(nodeConfig.AgentConfig.NodeName, nodeExternalAndInternalIPs, servingKubeletCert, servingKubeletKey, newNodePasswordFile, info); err != nil {
+	if err := getKubeletServingCert(nodeConfig.AgentConfig.NodeName, nodeExternalAndInternalIPs, servingKubeletCert, servingKubeletKey, creds, info); err != nil {
 		return nil, errors.WithMessage(err, servingKubeletCert)
 	}
 
 	// Ask the server to sign our kubelet client cert.
-	if err := getKubeletClientCert(clientKubeletCert, clientKubeletKey, nodeConfig.AgentConfig.NodeName, nodeConfig.AgentConfig.NodeIPs, newNodePasswordFile, info); err != nil {
+	if err := getKubeletClientCert(clientKubeletCert, clientKubeletKey, nodeConfig.AgentConfig.NodeName, nodeConfig.AgentConfig.NodeIPs, creds, info); err != nil {
 		return nil, errors.WithMessage(err, clientKubeletCert)
 	}
 
@@ -745,7 +918,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	}
 
 	// Ask the server to sign our kube-proxy client cert.
-	if err := getClientCert(clientKubeProxyCert, clientKubeProxyKey, info); err != nil {
+	if err := getClientCert(clientKubeProxyCert, clientKubeProxyKey, nodeConfig.AgentConfig.NodeName, nodeConfig.AgentConfig.NodeIPs, creds, info); err != nil {
 		return nil, errors.WithMessage(err, clientKubeProxyCert)
 	}
 
@@ -755,7 +928,7 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	}
 
 	// Ask the server to sign our agent controller client cert.
-	if err := getClientCert(clientK3sControllerCert, clientK3sControllerKey, info); err != nil {
+	if err := getClientCert(clientK3sControllerCert, clientK3sControllerKey, nodeConfig.AgentConfig.NodeName, nodeConfig.AgentConfig.NodeIPs, creds, info); err != nil {
 		return nil, errors.WithMessage(err, clientK3sControllerCert)
 	}
 
@@ -832,3 +1005,43 @@ func validateNetworkConfig(nodeConfig *config.Node) error {
 
 	return nil
 }
+
+// setKubeletAddresses sets the addresses and ports that the kubelet serves on. The kubelet API and
+// metrics are served on the agent listen address unless otherwise configured. If the kubelet is bound
+// to a loopback address, it can only be reached by the apiserver through the supervisor tunnel.
+func setKubeletAddresses(nodeConfig *config.Node, envInfo *cmds.Agent) error {
+	nodeConfig.AgentConfig.KubeletBindAddress = nodeConfig.AgentConfig.ListenAddress
+	if envInfo.KubeletBindAddress != "" {
+		ip := net.ParseIP(envInfo.KubeletBindAddress)
+		if ip == nil {
+			return fmt.Errorf("invalid kubelet-bind-address %s", envInfo.KubeletBindAddress)
+		}
+		if ip.IsLoopback() {
+			switch nodeConfig.EgressSelectorMode {
+			case config.EgressSelectorModeDisabled, config.EgressSelectorModeKonnectivity:
+				return fmt.Errorf("kubelet-bind-address %s requires the server egress-selector-mode to not be %s, as the apiserver must reach the kubelet through the supervisor tunnel", ip, nodeConfig.EgressSelectorMode)
+			}
+			logrus.Warnf("Kubelet is only listening on %s; in-cluster clients such as metrics-server will not be able to reach the kubelet", ip)
+		}
+		nodeConfig.AgentConfig.KubeletBindAddress = ip.String()
+	}
+
+	if envInfo.KubeletHealthzAddress != "" {
+		ip := net.ParseIP(envInfo.KubeletHealthzAddress)
+		if ip == nil {
+			return fmt.Errorf("invalid kubelet-healthz-bind-address %s", envInfo.KubeletHealthzAddress)
+		}
+		nodeConfig.AgentConfig.KubeletHealthzAddress = ip.String()
+	}
+
+	if envInfo.KubeletReadOnlyPort < 0 || envInfo.KubeletReadOnlyPort > 65535 {
+		return fmt.Errorf("invalid kubelet-read-only-port %d", envInfo.KubeletReadOnlyPort)
+	}
+	if envInfo.KubeletReadOnlyPort != 0 {
+		if ip := net.ParseIP(nodeConfig.AgentConfig.KubeletBindAddress); ip == nil || !ip.IsLoopback() {
+			logrus.Warnf("Kubelet unauthenticated read-only API is enabled on port %d; this port should be protected by a firewall", envInfo.KubeletReadOnlyPort)
+		}
+	}
+	nodeConfig.AgentConfig.KubeletReadOnlyPort = envInfo.KubeletReadOnlyPort
+	return nil
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
 )
 
 func Test_isValidResolvConf(t *testing.T) {
@@ -39,3 +43,116 @@ func Test_isValidResolvConf(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitSetKubeletAddresses(t *testing.T) {
+	tests := []struct {
+		name         string
+		envInfo      cmds.Agent
+		egressMode   string
+		wantBind     string
+		wantHealthz  string
+		wantReadOnly int
+		wantErr      bool
+	}{
+		{
+			name:       "defaults",
+			egressMode: config.EgressSelectorModeAgent,
+			wantBind:   "0.0.0.0",
+		},
+		{
+			name:        "loopback only",
+			envInfo:     cmds.Agent{KubeletBindAddress: "127.0.0.1", KubeletHealthzAddress: "::1"},
+			egressMode:  config.EgressSelectorModeAgent,
+			wantBind:    "127.0.0.1",
+			wantHealthz: "::1",
+		},
+		{
+			name:       "loopback with egress selector disabled",
+			envInfo:    cmds.Agent{KubeletBindAddress: "127.0.0.1"},
+			egressMode: config.EgressSelectorModeDisabled,
+			wantErr:    true,
+		},
+		{
+			name:         "read-only port",
+			envInfo:      cmds.Agent{KubeletReadOnlyPort: 10255},
+			egressMode:   config.EgressSelectorModeDisabled,
+			wantBind:     "0.0.0.0",
+			wantReadOnly: 10255,
+		},
+		{
+			name:    "invalid bind address",
+			envInfo: cmds.Agent{KubeletBindAddress: "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid read-only port",
+			envInfo: cmds.Agent{KubeletReadOnlyPort: 70000},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeConfig := &config.Node{EgressSelectorMode: tt.egressMode}
+			nodeConfig.AgentConfig.ListenAddress = "0.0.0.0"
+			err := setKubeletAddresses(nodeConfig, &tt.envInfo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setKubeletAddresses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := nodeConfig.AgentConfig.KubeletBindAddress; got != tt.wantBind {
+				t.Errorf("setKubeletAddresses() KubeletBindAddress = %q, want %q", got, tt.wantBind)
+			}
+			if got := nodeConfig.AgentConfig.KubeletHealthzAddress; got != tt.wantHealthz {
+				t.Errorf("setKubeletAddresses() KubeletHealthzAddress = %q, want %q", got, tt.wantHealthz)
+			}
+			if got := nodeConfig.AgentConfig.KubeletReadOnlyPort; got != tt.wantReadOnly {
+				t.Errorf("setKubeletAddresses() KubeletReadOnlyPort = %d, want %d", got, tt.wantReadOnly)
+			}
+		})
+	}
+}
+
+func Test_UnitRenderResolvConf(t *testing.T) {
+	hostResolvConf := "nameserver 10.0.0.1\nsearch broken.dhcp example.com\noptions ndots:2\n"
+	tests := []struct {
+		name   string
+		search []string
+		policy string
+		want   string
+	}{
+		{
+			name:   "append",
+			search: []string{"corp.example.com", "example.com"},
+			policy: config.DNSSearchPolicyAppend,
+			want:   "nameserver 10.0.0.1\noptions ndots:2\nsearch broken.dhcp example.com corp.example.com\n",
+		},
+		{
+			name:   "replace",
+			search: []string{"corp.example.com"},
+			policy: config.DNSSearchPolicyReplace,
+			want:   "nameserver 10.0.0.1\noptions ndots:2\nsearch corp.example.com\n",
+		},
+		{
+			name:   "replace with no search domains",
+			policy: config.DNSSearchPolicyReplace,
+			want:   "nameserver 10.0.0.1\noptions ndots:2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvConf := filepath.Join(t.TempDir(), "resolv.conf")
+			if err := os.WriteFile(resolvConf, []byte(hostResolvConf), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := renderResolvConf(resolvConf, tt.search, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("renderResolvConf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/containerd/containerd"
@@ -105,7 +106,8 @@ func Run(ctx context.Context, cfg *config.Node) error {
 		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 		cmd.Stdout = stdOut
 		cmd.Stderr = stdErr
-		cmd.Env = append(env, cenv...)
+		// Variables set for containerd with --component-env take precedence over the process environment
+		cmd.Env = slices.Concat(env, cenv, cfg.Containerd.Env)
 
 		addDeathSig(cmd)
 		err := cmd.Run()
@@ -0,0 +1,213 @@
+package containerd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/containerd/remotes"
+	remotedocker "github.com/containerd/containerd/remotes/docker"
+	dockerconfig "github.com/containerd/containerd/remotes/docker/config"
+	docker "github.com/distribution/reference"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/rancher/wharfie/pkg/registries"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// Images fetched by the mirror controller are pinned with their own label, so that the pin
+	// can be removed when the image is dropped from the mirror list.
+	k3sMirrorImageLabelKey   = "io.cattle." + version.Program + ".mirrored"
+	k3sMirrorImageLabelValue = "pinned"
+)
+
+// MirrorImages periodically fetches all images listed in the image mirror list file into the
+// containerd content store, for all platforms. Fetches are resolved using the same registry
+// hosts configuration as the CRI, so that content is pulled through any configured mirrors.
+// Mirrored content is available to other nodes via the embedded registry, if enabled, and
+// keeps airgap caches warm ahead of upgrades. The list is re-read on every sync, so changes
+// to the file take effect at the next interval.
+func MirrorImages(ctx context.Context, cfg *config.Node) {
+	logrus.Infof("Image mirroring enabled for images listed in %s", cfg.AgentConfig.ImageMirrorList)
+	ticker := time.NewTicker(cfg.AgentConfig.ImageMirrorInterval.Duration)
+	defer ticker.Stop()
+	for {
+		if err := syncMirrorImages(ctx, cfg); err != nil {
+			logrus.Errorf("Failed to sync mirrored images: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncMirrorImages fetches and pins the images in the mirror list, and removes the
+// pin from any images previously mirrored that are no longer listed.
+func syncMirrorImages(ctx context.Context, cfg *config.Node) error {
+	f, err := os.Open(cfg.AgentConfig.ImageMirrorList)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names, err := readMirrorList(f)
+	if err != nil {
+		return err
+	}
+
+	client, err := Client(cfg.Containerd.Address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx = namespaces.WithNamespace(ctx, criK8sContainerdNamespace)
+	resolver := mirrorResolver(ctx, cfg)
+
+	// Pin any images that were successfully fetched, even if others failed
+	var errs []error
+	fetched := []images.Image{}
+	for _, name := range names {
+		logrus.Infof("Fetching image %s for all platforms", name)
+		image, err := client.Fetch(ctx, name, containerd.WithResolver(resolver), containerd.WithPlatformMatcher(platforms.All))
+		if err != nil {
+			errs = append(errs, errors.WithMessage(err, "failed to fetch image "+name))
+			continue
+		}
+		fetched = append(fetched, image)
+	}
+	if err := labelMirrorImages(ctx, client, fetched); err != nil {
+		errs = append(errs, err)
+	}
+	if err := clearMirrorLabels(ctx, client, fetched); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// mirrorResolver returns a resolver that uses the registry hosts config directory written for
+// the CRI, along with credentials from the private registry configuration.
+func mirrorResolver(ctx context.Context, cfg *config.Node) remotes.Resolver {
+	return remotedocker.NewResolver(remotedocker.ResolverOptions{
+		Hosts: dockerconfig.ConfigureHosts(ctx, dockerconfig.HostOptions{
+			HostDir: dockerconfig.HostDirFromRoot(cfg.Containerd.Registry),
+			Credentials: func(host string) (string, string, error) {
+				if cfg.AgentConfig.Registry == nil {
+					return "", "", nil
+				}
+				return registryCredentials(configForHost(cfg.AgentConfig.Registry.Configs, host).Auth)
+			},
+		}),
+	})
+}
+
+// registryCredentials returns the username and secret from a registry auth config.
+// An identity token is returned as the secret with an empty username.
+func registryCredentials(auth *registries.AuthConfig) (string, string, error) {
+	switch {
+	case auth == nil:
+		return "", "", nil
+	case auth.IdentityToken != "":
+		return "", auth.IdentityToken, nil
+	case auth.Username != "" || auth.Password != "":
+		return auth.Username, auth.Password, nil
+	case auth.Auth != "":
+		b, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", err
+		}
+		user, pass, ok := strings.Cut(string(b), ":")
+		if !ok {
+			return "", "", errors.New("invalid registry auth: expected base64 encoded username:password")
+		}
+		return user, pass, nil
+	}
+	return "", "", nil
+}
+
+// readMirrorList returns a sorted list of unique normalized image references from an image list.
+// Empty lines and comments are ignored; lines that cannot be parsed as an image reference are logged and skipped.
+func readMirrorList(r io.Reader) ([]string, error) {
+	names := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		ref, err := docker.ParseDockerRef(name)
+		if err != nil {
+			logrus.Errorf("Failed to parse mirrored image reference %q: %v", name, err)
+			continue
+		}
+		names = append(names, ref.String())
+	}
+	slices.Sort(names)
+	return slices.Compact(names), scanner.Err()
+}
+
+// labelMirrorImages adds labels to the listed images, indicating that they
+// are pinned by the mirror controller and should not be pruned.
+func labelMirrorImages(ctx context.Context, client *containerd.Client, images []images.Image) error {
+	var errs []error
+	imageService := client.ImageService()
+	for _, image := range images {
+		if image.Labels[k3sMirrorImageLabelKey] == k3sMirrorImageLabelValue &&
+			image.Labels[criPinnedImageLabelKey] == criPinnedImageLabelValue {
+			continue
+		}
+
+		if image.Labels == nil {
+			image.Labels = map[string]string{}
+		}
+
+		image.Labels[k3sMirrorImageLabelKey] = k3sMirrorImageLabelValue
+		image.Labels[criPinnedImageLabelKey] = criPinnedImageLabelValue
+		if _, err := imageService.Update(ctx, image, "labels"); err != nil {
+			errs = append(errs, errors.WithMessage(err, "failed to add labels to image "+image.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clearMirrorLabels removes the mirror label from all images other than the listed images.
+// The CRI pinned label is also removed, unless the image is still pinned by the import process
+// or for warm standby.
+func clearMirrorLabels(ctx context.Context, client *containerd.Client, keep []images.Image) error {
+	var errs []error
+	imageService := client.ImageService()
+	mirrored, err := imageService.List(ctx, fmt.Sprintf("labels.%q==%s", k3sMirrorImageLabelKey, k3sMirrorImageLabelValue))
+	if err != nil {
+		return err
+	}
+	for _, image := range mirrored {
+		if slices.ContainsFunc(keep, func(i images.Image) bool { return i.Name == image.Name }) {
+			continue
+		}
+		delete(image.Labels, k3sMirrorImageLabelKey)
+		if image.Labels[k3sPinnedImageLabelKey] != k3sPinnedImageLabelValue &&
+			image.Labels[k3sStandbyImageLabelKey] != k3sStandbyImageLabelValue {
+			delete(image.Labels, criPinnedImageLabelKey)
+		}
+		if _, err := imageService.Update(ctx, image, "labels"); err != nil {
+			errs = append(errs, errors.WithMessage(err, "failed to delete labels from image "+image.Name))
+		} else {
+			logrus.Infof("Removed mirror pin from image %s", image.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,46 @@
+package containerd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_UnitReadMirrorList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "Empty list",
+			input: "\n# comment\n",
+			want:  []string{},
+		},
+		{
+			name:  "Normalized and deduplicated",
+			input: "nginx:1.27\ndocker.io/library/nginx:1.27\n  busybox  \nregistry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001\n",
+			want: []string{
+				"docker.io/library/busybox:latest",
+				"docker.io/library/nginx:1.27",
+				"registry.example.com/app@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+			},
+		},
+		{
+			name:  "Invalid entries skipped",
+			input: "not a valid reference\nnginx:1.27\n",
+			want:  []string{"docker.io/library/nginx:1.27"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readMirrorList(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("readMirrorList() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readMirrorList() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/namespaces"
+	docker "github.com/distribution/reference"
+	"github.com/k3s-io/k3s/pkg/agent/cri"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+var (
+	// WarmStandbyPodLabel selects pods whose images are kept ready on warm standby nodes.
+	WarmStandbyPodLabel = version.Program + ".io/warm-standby"
+
+	// Images pulled for warm standby are pinned with their own label, so that the pin can be
+	// removed when the images are no longer used by any selected pod, without affecting
+	// images that were also pinned by the agent images directory import process.
+	k3sStandbyImageLabelKey   = "io.cattle." + version.Program + ".warm-standby"
+	k3sStandbyImageLabelValue = "pinned"
+)
+
+// WarmStandby starts a controller that keeps the images for all pods labeled for warm standby
+// pulled and pinned on this node, along with the pause image used for pod sandboxes. If a
+// selected pod fails over to this node, the kubelet can create and start its containers
+// immediately, without waiting for image pulls. Containers themselves are not pre-created,
+// as the kubelet garbage collects any containers or sandboxes that do not belong to a pod
+// bound to the node; the image pull and unpack are the bulk of the start latency.
+// Images are synced whenever the set of selected pods changes, and on the configured interval.
+func WarmStandby(ctx context.Context, cfg *config.Node) error {
+	restConfig, err := util.GetRESTConfig(cfg.AgentConfig.KubeConfigK3sController)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	lw := toolscache.NewFilteredListWatchFromClient(client.CoreV1().RESTClient(), "pods", metav1.NamespaceAll, func(opts *metav1.ListOptions) {
+		opts.LabelSelector = WarmStandbyPodLabel + "=true"
+	})
+	informer := toolscache.NewSharedIndexInformer(lw, &v1.Pod{}, 0, toolscache.Indexers{})
+
+	// Only trigger a sync when pods are added or removed, or their images change;
+	// pod status updates do not affect the set of images to be pulled.
+	trigger := make(chan struct{}, 1)
+	enqueue := func(any) {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj any) {
+			oldPod, oldOK := oldObj.(*v1.Pod)
+			newPod, newOK := newObj.(*v1.Pod)
+			if !oldOK || !newOK || !slices.Equal(podImages(oldPod), podImages(newPod)) {
+				enqueue(newObj)
+			}
+		},
+		DeleteFunc: enqueue,
+	})
+
+	go informer.Run(ctx.Done())
+	if !toolscache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to wait for warm standby pod cache to sync")
+	}
+
+	logrus.Infof("Warm standby enabled for pods labeled %s=true", WarmStandbyPodLabel)
+	go func() {
+		ticker := time.NewTicker(cfg.AgentConfig.WarmStandbyInterval.Duration)
+		defer ticker.Stop()
+		for {
+			pods := []*v1.Pod{}
+			for _, obj := range informer.GetStore().List() {
+				if pod, ok := obj.(*v1.Pod); ok {
+					pods = append(pods, pod)
+				}
+			}
+			if err := syncWarmStandby(ctx, cfg, warmStandbyImages(cfg.AgentConfig.PauseImage, pods)); err != nil {
+				logrus.Errorf("Failed to sync warm standby images: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// syncWarmStandby pulls and pins the listed images, and removes the pin from any images
+// previously pinned for warm standby that are no longer listed.
+// NOTE: Pulls MUST be done via CRI API, not containerd API, in order to use mirrors and rewrites.
+// Credentials from pod image pull secrets are not available to the agent, so images from
+// private registries must have credentials configured in registries.yaml.
+func syncWarmStandby(ctx context.Context, cfg *config.Node, names []string) error {
+	client, err := Client(cfg.Containerd.Address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	criConn, err := cri.Connection(ctx, cfg.Containerd.Address)
+	if err != nil {
+		return err
+	}
+	defer criConn.Close()
+
+	ctx = namespaces.WithNamespace(ctx, criK8sContainerdNamespace)
+	imageClient := runtimeapi.NewImageServiceClient(criConn)
+
+	// Pin any images that were successfully pulled, even if others failed
+	var errs []error
+	pulled, err := prePullImages(ctx, client, imageClient, strings.NewReader(strings.Join(names, "\n")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := labelStandbyImages(ctx, client, pulled); err != nil {
+		errs = append(errs, err)
+	}
+	if err := clearStandbyLabels(ctx, client, pulled); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// labelStandbyImages adds labels to the listed images, indicating that they
+// are pinned for warm standby and should not be pruned.
+func labelStandbyImages(ctx context.Context, client *containerd.Client, images []images.Image) error {
+	var errs []error
+	imageService := client.ImageService()
+	for _, image := range images {
+		if image.Labels[k3sStandbyImageLabelKey] == k3sStandbyImageLabelValue &&
+			image.Labels[criPinnedImageLabelKey] == criPinnedImageLabelValue {
+			continue
+		}
+
+		if image.Labels == nil {
+			image.Labels = map[string]string{}
+		}
+
+		image.Labels[k3sStandbyImageLabelKey] = k3sStandbyImageLabelValue
+		image.Labels[criPinnedImageLabelKey] = criPinnedImageLabelValue
+		if _, err := imageService.Update(ctx, image, "labels"); err != nil {
+			errs = append(errs, errors.WithMessage(err, "failed to add labels to image "+image.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clearStandbyLabels removes the warm standby label from all images other than the listed images.
+// The CRI pinned label is also removed, unless the image is still pinned by the import process
+// or the mirror controller.
+func clearStandbyLabels(ctx context.Context, client *containerd.Client, keep []images.Image) error {
+	var errs []error
+	imageService := client.ImageService()
+	pinned, err := imageService.List(ctx, fmt.Sprintf("labels.%q==%s", k3sStandbyImageLabelKey, k3sStandbyImageLabelValue))
+	if err != nil {
+		return err
+	}
+	for _, image := range pinned {
+		if slices.ContainsFunc(keep, func(i images.Image) bool { return i.Name == image.Name }) {
+			continue
+		}
+		delete(image.Labels, k3sStandbyImageLabelKey)
+		if image.Labels[k3sPinnedImageLabelKey] != k3sPinnedImageLabelValue &&
+			image.Labels[k3sMirrorImageLabelKey] != k3sMirrorImageLabelValue {
+			delete(image.Labels, criPinnedImageLabelKey)
+		}
+		if _, err := imageService.Update(ctx, image, "labels"); err != nil {
+			errs = append(errs, errors.WithMessage(err, "failed to delete labels from image "+image.Name))
+		} else {
+			logrus.Infof("Removed warm standby pin from image %s", image.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// warmStandbyImages returns a sorted list of unique normalized image references
+// used by the given pods, along with the pause image.
+func warmStandbyImages(pauseImage string, pods []*v1.Pod) []string {
+	names := []string{}
+	if pauseImage != "" {
+		names = append(names, normalizeImageName(pauseImage))
+	}
+	for _, pod := range pods {
+		names = append(names, podImages(pod)...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// podImages returns the normalized image references for all init and app containers in a pod.
+func podImages(pod *v1.Pod) []string {
+	names := []string{}
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Image != "" {
+				names = append(names, normalizeImageName(container.Image))
+			}
+		}
+	}
+	return names
+}
+
+// normalizeImageName converts an image reference to the fully qualified form used as the image name
+// in the containerd image store, so that the image can be found after it has been pulled.
+// References that cannot be parsed are returned as-is.
+func normalizeImageName(name string) string {
+	if ref, err := docker.ParseDockerRef(name); err == nil {
+		return ref.String()
+	}
+	return name
+}
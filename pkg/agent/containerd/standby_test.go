@@ -0,0 +1,76 @@
+package containerd
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func Test_UnitWarmStandbyImages(t *testing.T) {
+	type args struct {
+		pauseImage string
+		pods       []*v1.Pod
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "No pods",
+			args: args{
+				pauseImage: "rancher/mirrored-pause:3.6",
+			},
+			want: []string{
+				"docker.io/rancher/mirrored-pause:3.6",
+			},
+		},
+		{
+			name: "Init and app containers",
+			args: args{
+				pauseImage: "rancher/mirrored-pause:3.6",
+				pods: []*v1.Pod{
+					{
+						Spec: v1.PodSpec{
+							InitContainers: []v1.Container{{Image: "busybox"}},
+							Containers:     []v1.Container{{Image: "registry.example.com/app:v1"}},
+						},
+					},
+				},
+			},
+			want: []string{
+				"docker.io/library/busybox:latest",
+				"docker.io/rancher/mirrored-pause:3.6",
+				"registry.example.com/app:v1",
+			},
+		},
+		{
+			name: "Duplicate images across pods",
+			args: args{
+				pods: []*v1.Pod{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{{Image: "nginx:1.27"}, {Image: "docker.io/library/nginx:1.27"}},
+						},
+					},
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{{Image: "nginx:1.27"}},
+						},
+					},
+				},
+			},
+			want: []string{
+				"docker.io/library/nginx:1.27",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := warmStandbyImages(tt.args.pauseImage, tt.args.pods); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("warmStandbyImages() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
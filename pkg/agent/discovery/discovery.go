@@ -0,0 +1,251 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// schemePrefix is prepended to the https scheme of server URLs that should be discovered via DNS.
+	schemePrefix = "dns+"
+	// defaultPort is the port used for A and AAAA records, if the URL does not set one.
+	defaultPort = "6443"
+	// RefreshInterval is the interval at which DNS records are resolved, and candidate servers are health-checked.
+	RefreshInterval = 30 * time.Second
+	// healthCheckTimeout is the timeout for each candidate server health check.
+	healthCheckTimeout = 5 * time.Second
+)
+
+// insecureHealthClient is used to check the readiness of candidate servers when the token does not include a
+// CA hash. The server CA cannot be verified in this case; servers are only trusted once the token is validated.
+var insecureHealthClient = &http.Client{
+	Timeout: healthCheckTimeout,
+	Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	},
+}
+
+// resolver is the subset of net.Resolver used to look up candidate servers.
+type resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Discoverer finds servers using DNS records, for server URLs of the form dns+https://name[:port].
+// SRV records at _<program>._tcp.<name> are preferred, in priority and weight order; if there
+// are none, the A and AAAA records for the name are used with the port from the URL.
+// Only candidates that pass the supervisor readiness check are used.
+type Discoverer struct {
+	name        string
+	port        string
+	resolver    resolver
+	healthCheck func(ctx context.Context, address string) error
+}
+
+// IsDiscoveryURL returns true if the server URL should be discovered via DNS.
+func IsDiscoveryURL(serverURL string) bool {
+	return strings.HasPrefix(serverURL, schemePrefix)
+}
+
+// New returns a Discoverer for the given dns+https server URL. If the token includes a CA hash, candidate servers
+// are only considered healthy if they present a certificate signed by the cluster CA that matches the hash.
+func New(serverURL, token string) (*Discoverer, error) {
+	if !IsDiscoveryURL(serverURL) {
+		return nil, fmt.Errorf("server URL %s does not use the %shttps scheme", serverURL, schemePrefix)
+	}
+	u, err := url.Parse(strings.TrimPrefix(serverURL, schemePrefix))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to parse server URL %s", serverURL)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported server URL scheme %s%s; only %shttps is supported", schemePrefix, u.Scheme, schemePrefix)
+	}
+	if u.Hostname() == "" || net.ParseIP(u.Hostname()) != nil {
+		return nil, fmt.Errorf("server URL %s must contain a DNS name", serverURL)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("server URL %s must not contain a path", serverURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	healthCheck := checkPinnedReadyz(token)
+	if !clientaccess.HasCAHash(token) {
+		logrus.Warnf("Token does not include a CA hash; servers discovered from %s cannot be verified until the token is validated. "+
+			"Use the full token from the server's node-token file to enable Cluster CA validation.", u.Hostname())
+		healthCheck = checkReadyz
+	}
+	return &Discoverer{
+		name:        u.Hostname(),
+		port:        port,
+		resolver:    net.DefaultResolver,
+		healthCheck: healthCheck,
+	}, nil
+}
+
+// URL returns the https URL of the discovery name, without discovering any servers.
+func (d *Discoverer) URL() string {
+	return "https://" + net.JoinHostPort(d.name, d.port)
+}
+
+// Resolve discovers the available servers, retrying until at least one candidate server is healthy, and
+// returns the URL of the first healthy server.
+func (d *Discoverer) Resolve(ctx context.Context, retry config.ServerRetry) (string, error) {
+	var serverURL string
+	err := clientaccess.RetryWithBackoff(ctx, retry, "Waiting for a healthy server at "+d.name, func(ctx context.Context) error {
+		addresses, err := d.Discover(ctx)
+		if err != nil {
+			return err
+		}
+		serverURL = "https://" + addresses[0]
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("Discovered server %s from %s", serverURL, d.name)
+	return serverURL, nil
+}
+
+// Discover returns the addresses of the candidate servers that pass the health check, in DNS order.
+// An error is returned if no candidates are found, or none are healthy.
+func (d *Discoverer) Discover(ctx context.Context) ([]string, error) {
+	candidates, err := d.lookup(ctx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to resolve %s", d.name)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no servers found for %s", d.name)
+	}
+
+	healthy := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, address := range candidates {
+		wg.Go(func() {
+			if err := d.healthCheck(ctx, address); err != nil {
+				logrus.Debugf("Server %s discovered from %s failed health check: %v", address, d.name, err)
+				return
+			}
+			healthy[i] = true
+		})
+	}
+	wg.Wait()
+
+	var addresses []string
+	for i, address := range candidates {
+		if healthy[i] {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("none of the %d servers found for %s are healthy", len(candidates), d.name)
+	}
+	return addresses, nil
+}
+
+// Watch periodically discovers the available servers until the context is cancelled, and calls the update function
+// with the healthy addresses whenever they change. Failures are logged, and the last healthy addresses are retained.
+func (d *Discoverer) Watch(ctx context.Context, update func(addresses []string)) {
+	var current []string
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		addresses, err := d.Discover(ctx)
+		if err != nil {
+			logrus.Warnf("Failed to discover servers: %v", err)
+			return
+		}
+		if slices.Equal(addresses, current) {
+			return
+		}
+		logrus.Infof("Discovered servers from %s: %v", d.name, addresses)
+		current = addresses
+		update(addresses)
+	}, RefreshInterval)
+}
+
+// lookup returns the addresses of candidate servers from DNS, with duplicates removed.
+func (d *Discoverer) lookup(ctx context.Context) ([]string, error) {
+	var addresses []string
+	if _, srvs, err := d.resolver.LookupSRV(ctx, version.Program, "tcp", d.name); err == nil && len(srvs) > 0 {
+		for _, srv := range srvs {
+			target := strings.TrimSuffix(srv.Target, ".")
+			hosts, err := d.resolver.LookupHost(ctx, target)
+			if err != nil {
+				logrus.Debugf("Failed to resolve SRV target %s for %s: %v", target, d.name, err)
+				continue
+			}
+			for _, host := range hosts {
+				addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+			}
+		}
+	} else {
+		hosts, err := d.resolver.LookupHost(ctx, d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			addresses = append(addresses, net.JoinHostPort(host, d.port))
+		}
+	}
+
+	var unique []string
+	for _, address := range addresses {
+		if !slices.Contains(unique, address) {
+			unique = append(unique, address)
+		}
+	}
+	return unique, nil
+}
+
+// checkReadyz checks the supervisor's load-balancer readiness endpoint, which is not authenticated,
+// without verifying the server certificate.
+func checkReadyz(ctx context.Context, address string) error {
+	return getReadyz(ctx, insecureHealthClient, address)
+}
+
+// checkPinnedReadyz returns a health check that validates the server's CA bundle against the CA hash in the
+// token, and then checks the supervisor's load-balancer readiness endpoint using the validated CA bundle.
+func checkPinnedReadyz(token string) func(ctx context.Context, address string) error {
+	return func(ctx context.Context, address string) error {
+		info, err := clientaccess.ParseAndValidateToken("https://"+address, token)
+		if err != nil {
+			return err
+		}
+		return getReadyz(ctx, clientaccess.GetHTTPClient(info.CACerts, "", "", clientaccess.WithTimeout(healthCheckTimeout)), address)
+	}
+}
+
+func getReadyz(ctx context.Context, client *http.Client, address string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+address+"/v1-"+version.Program+"/lb/readyz", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("readiness check returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+type fakeResolver struct {
+	srvs  []*net.SRV
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if len(r.srvs) == 0 {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return "", r.srvs, nil
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func Test_UnitNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		wantName string
+		wantPort string
+		wantErr  bool
+	}{
+		{
+			name:     "default port",
+			url:      "dns+https://cluster.example.com",
+			wantName: "cluster.example.com",
+			wantPort: "6443",
+		},
+		{
+			name:     "explicit port",
+			url:      "dns+https://cluster.example.com:9345",
+			wantName: "cluster.example.com",
+			wantPort: "9345",
+		},
+		{
+			name:    "not a discovery url",
+			url:     "https://cluster.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "http scheme",
+			url:     "dns+http://cluster.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "ip address",
+			url:     "dns+https://10.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "path",
+			url:     "dns+https://cluster.example.com/servers",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(tt.url, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if d.name != tt.wantName || d.port != tt.wantPort {
+				t.Errorf("New() name = %s port = %s, want name = %s port = %s", d.name, d.port, tt.wantName, tt.wantPort)
+			}
+		})
+	}
+}
+
+func Test_UnitDiscover(t *testing.T) {
+	unhealthy := "10.0.0.2:6443"
+	healthCheck := func(ctx context.Context, address string) error {
+		if address == unhealthy {
+			return errors.New("readiness check returned 503 Service Unavailable")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "address records",
+			resolver: &fakeResolver{hosts: map[string][]string{
+				"cluster.example.com": {"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.1"},
+			}},
+			want: []string{"10.0.0.1:6443", "10.0.0.3:6443"},
+		},
+		{
+			name: "srv records",
+			resolver: &fakeResolver{
+				srvs: []*net.SRV{
+					{Target: "server-2.example.com.", Port: 6443, Priority: 10},
+					{Target: "server-1.example.com.", Port: 9345, Priority: 20},
+					{Target: "missing.example.com.", Port: 6443, Priority: 30},
+				},
+				hosts: map[string][]string{
+					"cluster.example.com":  {"10.0.0.9"},
+					"server-1.example.com": {"10.0.0.1", "fd00::1"},
+					"server-2.example.com": {"10.0.0.2"},
+				},
+			},
+			want: []string{"10.0.0.1:9345", "[fd00::1]:9345"},
+		},
+		{
+			name:     "no records",
+			resolver: &fakeResolver{},
+			wantErr:  true,
+		},
+		{
+			name: "no healthy servers",
+			resolver: &fakeResolver{hosts: map[string][]string{
+				"cluster.example.com": {"10.0.0.2"},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Discoverer{name: "cluster.example.com", port: "6443", resolver: tt.resolver, healthCheck: healthCheck}
+			got, err := d.Discover(t.Context())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+// Package diskpressure remediates high disk usage on the filesystems used by the kubelet and container runtime,
+// for unattended nodes where kubelet eviction and garbage collection alone are not sufficient to keep the node
+// running. When usage crosses the configured threshold, the configured actions are taken in order of least
+// disruption, until usage drops back below the threshold. Actions taken are reported as events on the node.
+package diskpressure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	docker "github.com/distribution/reference"
+	"github.com/k3s-io/k3s/pkg/agent/cri"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	// ActionSandboxes removes pod sandboxes that are no longer ready, along with their exited
+	// containers and logs. Logs of completed pods will no longer be available via the API.
+	ActionSandboxes = "sandboxes"
+	// ActionLogs rotates the logs of running containers whose logs exceed the maximum size, and
+	// removes the rotated files.
+	ActionLogs = "logs"
+	// ActionImages removes images that are not pinned, and are not used by any container.
+	ActionImages = "images"
+
+	// defaultKubeletRootDir is used when the kubelet root dir is not set by the agent.
+	defaultKubeletRootDir = "/var/lib/kubelet"
+	// podLogsDir is the directory that the kubelet creates container log files in.
+	podLogsDir = "/var/log/pods"
+
+	// minSandboxAge is the minimum age of a sandbox before it is considered for removal, to
+	// avoid racing the kubelet when it is in the process of starting or stopping a pod.
+	minSandboxAge = time.Minute
+	// minImageUnusedAge is how long an image must have been unused before it is removed, to
+	// avoid removing images that have been pulled for containers that are being created.
+	minImageUnusedAge = 2 * time.Minute
+	// rotatedLogTimestamp is the suffix format used by the kubelet when rotating container logs.
+	rotatedLogTimestamp = "20060102-150405"
+)
+
+var (
+	controllerName = version.Program + "-disk-pressure"
+
+	// Actions is the list of supported actions, in the order that they are taken.
+	Actions = []string{ActionSandboxes, ActionLogs, ActionImages}
+)
+
+// Validate checks that the disk pressure remediation configuration is valid.
+func Validate(cfg *config.Agent) error {
+	for _, action := range cfg.DiskPressureActions {
+		if !slices.Contains(Actions, action) {
+			return fmt.Errorf("invalid disk pressure remediation action %q: must be one of %s", action, strings.Join(Actions, ", "))
+		}
+	}
+	if cfg.DiskPressureThreshold <= 0 || cfg.DiskPressureThreshold >= 100 {
+		return errors.New("disk-pressure-threshold must be between 1 and 99")
+	}
+	if cfg.DiskPressureInterval.Duration <= 0 {
+		return errors.New("disk-pressure-interval must be greater than zero")
+	}
+	if slices.Contains(cfg.DiskPressureActions, ActionLogs) && cfg.DiskPressureLogMaxSize <= 0 {
+		return errors.New("disk-pressure-log-max-size must be greater than zero")
+	}
+	return nil
+}
+
+type controller struct {
+	cfg         *config.Agent
+	paths       []string
+	runtime     runtimeapi.RuntimeServiceClient
+	images      runtimeapi.ImageServiceClient
+	recorder    record.EventRecorder
+	nodeRef     *corev1.ObjectReference
+	unusedSince map[string]time.Time
+}
+
+// Run starts the disk pressure remediation controller. Usage of the kubelet root dir, pod log dir, and
+// container runtime root is checked at the configured interval.
+func Run(ctx context.Context, nodeConfig *config.Node) error {
+	cfg := &nodeConfig.AgentConfig
+	paths := []string{cfg.RootDir, podLogsDir}
+	if cfg.RootDir == "" {
+		paths[0] = defaultKubeletRootDir
+	}
+	if !nodeConfig.Docker && nodeConfig.ContainerRuntimeEndpoint == "" {
+		paths = append(paths, nodeConfig.Containerd.Root)
+	}
+	for _, path := range paths {
+		if _, _, err := filesystemUsage(path); errors.Is(err, errors.ErrUnsupportedPlatform) {
+			return err
+		}
+	}
+
+	client, err := util.GetClientSet(cfg.KubeConfigKubelet)
+	if err != nil {
+		return err
+	}
+
+	runtimeConn, err := cri.Connection(ctx, cfg.RuntimeSocket)
+	if err != nil {
+		return err
+	}
+	imageConn := runtimeConn
+	if cfg.ImageServiceSocket != "" && cfg.ImageServiceSocket != cfg.RuntimeSocket {
+		if imageConn, err = cri.Connection(ctx, cfg.ImageServiceSocket); err != nil {
+			runtimeConn.Close()
+			return err
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		runtimeConn.Close()
+		imageConn.Close()
+	}()
+
+	c := &controller{
+		cfg:      cfg,
+		paths:    paths,
+		runtime:  runtimeapi.NewRuntimeServiceClient(runtimeConn),
+		images:   runtimeapi.NewImageServiceClient(imageConn),
+		recorder: util.BuildControllerEventRecorder(client, controllerName, metav1.NamespaceDefault),
+		// This is consistent with events attached to the node generated by the kubelet
+		nodeRef: &corev1.ObjectReference{
+			Kind: "Node",
+			Name: cfg.NodeName,
+			UID:  types.UID(cfg.NodeName),
+		},
+		unusedSince: map[string]time.Time{},
+	}
+
+	mode := ""
+	if cfg.DiskPressureDryRun {
+		mode = " (dry run)"
+	}
+	logrus.Infof("Disk pressure remediation%s enabled at %d%% usage with actions %s", mode, cfg.DiskPressureThreshold, strings.Join(cfg.DiskPressureActions, ", "))
+	go func() {
+		ticker := time.NewTicker(cfg.DiskPressureInterval.Duration)
+		defer ticker.Stop()
+		for {
+			if err := c.sync(ctx); err != nil && ctx.Err() == nil {
+				logrus.Errorf("Failed to check disk pressure: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// sync checks filesystem usage, and takes the configured actions in order until usage is below the threshold.
+func (c *controller) sync(ctx context.Context) error {
+	// Unused images are tracked on every sync, so that images that have been unused for
+	// long enough can be removed as soon as the threshold is crossed.
+	if slices.Contains(c.cfg.DiskPressureActions, ActionImages) {
+		if _, err := c.trackUnusedImages(ctx, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	path, usage, err := c.maxUsage()
+	if err != nil || usage < float64(c.cfg.DiskPressureThreshold) {
+		return err
+	}
+	message := fmt.Sprintf("Usage of the filesystem containing %s is %.1f%%, above the disk pressure remediation threshold of %d%%", path, usage, c.cfg.DiskPressureThreshold)
+	logrus.Warn(message)
+	c.recorder.Event(c.nodeRef, corev1.EventTypeWarning, "DiskPressureThresholdExceeded", message)
+
+	for _, action := range Actions {
+		if !slices.Contains(c.cfg.DiskPressureActions, action) {
+			continue
+		}
+		var result string
+		switch action {
+		case ActionSandboxes:
+			result, err = c.removeSandboxes(ctx)
+		case ActionLogs:
+			result, err = c.rotateLogs(ctx)
+		case ActionImages:
+			result, err = c.removeImages(ctx)
+		}
+		if err != nil {
+			message := fmt.Sprintf("Failed to remediate disk pressure with %s action: %v", action, err)
+			logrus.Error(message)
+			c.recorder.Event(c.nodeRef, corev1.EventTypeWarning, "DiskPressureRemediationFailed", message)
+		}
+		if result != "" {
+			reason := "DiskPressureRemediation"
+			if c.cfg.DiskPressureDryRun {
+				reason = "DiskPressureRemediationDryRun"
+				result = "Dry run: would have " + result
+			} else {
+				result = strings.ToUpper(result[:1]) + result[1:]
+			}
+			logrus.Info(result)
+			c.recorder.Event(c.nodeRef, corev1.EventTypeNormal, reason, result)
+		}
+		if c.cfg.DiskPressureDryRun {
+			continue
+		}
+		if path, usage, err = c.maxUsage(); err != nil || usage < float64(c.cfg.DiskPressureThreshold) {
+			return err
+		}
+	}
+
+	if !c.cfg.DiskPressureDryRun {
+		message := fmt.Sprintf("Usage of the filesystem containing %s is still %.1f%% after all disk pressure remediation actions were taken", path, usage)
+		logrus.Warn(message)
+		c.recorder.Event(c.nodeRef, corev1.EventTypeWarning, "DiskPressureUnresolved", message)
+	}
+	return nil
+}
+
+// maxUsage returns the path with the highest space or inode usage, and its usage percentage.
+// Paths that do not exist are skipped.
+func (c *controller) maxUsage() (string, float64, error) {
+	var maxPath string
+	var maxUsage float64
+	for _, path := range c.paths {
+		bytesUsed, inodesUsed, err := filesystemUsage(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", 0, errors.WithMessagef(err, "failed to get filesystem usage for %s", path)
+		}
+		if usage := max(bytesUsed, inodesUsed); usage >= maxUsage {
+			maxPath, maxUsage = path, usage
+		}
+	}
+	return maxPath, maxUsage, nil
+}
+
+// removeSandboxes removes pod sandboxes that are not ready and have no running containers.
+func (c *controller) removeSandboxes(ctx context.Context) (string, error) {
+	sandboxes, err := c.runtime.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{})
+	if err != nil {
+		return "", err
+	}
+	containers, err := c.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	var removed []string
+	var errs []error
+	for _, sandbox := range removableSandboxes(sandboxes.Items, containers.Containers, time.Now()) {
+		name := sandboxName(sandbox)
+		if !c.cfg.DiskPressureDryRun {
+			if _, err := c.runtime.RemovePodSandbox(ctx, &runtimeapi.RemovePodSandboxRequest{PodSandboxId: sandbox.Id}); err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to remove sandbox for pod %s", name))
+				continue
+			}
+		}
+		removed = append(removed, name)
+	}
+	if len(removed) == 0 {
+		return "", errors.Join(errs...)
+	}
+	return fmt.Sprintf("removed %d completed pod sandboxes: %s", len(removed), strings.Join(removed, ", ")), errors.Join(errs...)
+}
+
+// rotateLogs rotates the logs of running containers whose current and previously rotated logs exceed
+// the maximum size, and removes the rotated files. Logs are rotated the same way as the kubelet, by renaming
+// the current log file and asking the runtime to reopen it.
+func (c *controller) rotateLogs(ctx context.Context) (string, error) {
+	containers, err := c.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{
+		Filter: &runtimeapi.ContainerFilter{
+			State: &runtimeapi.ContainerStateValue{State: runtimeapi.ContainerState_CONTAINER_RUNNING},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var rotated []string
+	var freed int64
+	var errs []error
+	for _, container := range containers.Containers {
+		status, err := c.runtime.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: container.Id})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logPath := status.GetStatus().GetLogPath()
+		if logPath == "" {
+			continue
+		}
+		files, size := logFiles(logPath)
+		if size <= c.cfg.DiskPressureLogMaxSize {
+			continue
+		}
+		if !c.cfg.DiskPressureDryRun {
+			if err := c.rotateLog(ctx, container.Id, logPath); err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to rotate log %s", logPath))
+				continue
+			}
+			files, _ = logFiles(logPath)
+			for _, file := range files {
+				if file != logPath {
+					os.Remove(file)
+				}
+			}
+		}
+		rotated = append(rotated, container.GetMetadata().GetName())
+		freed += size
+	}
+	if len(rotated) == 0 {
+		return "", errors.Join(errs...)
+	}
+	return fmt.Sprintf("rotated %d container logs totalling %s: %s", len(rotated), formatBytes(freed), strings.Join(rotated, ", ")), errors.Join(errs...)
+}
+
+// rotateLog renames the current log file, and asks the runtime to reopen it. If the runtime
+// fails to reopen the log, the file is renamed back so that the container can continue logging.
+func (c *controller) rotateLog(ctx context.Context, containerID, logPath string) error {
+	rotatedPath := logPath + "." + time.Now().Format(rotatedLogTimestamp)
+	if err := os.Rename(logPath, rotatedPath); err != nil {
+		return err
+	}
+	if _, err := c.runtime.ReopenContainerLog(ctx, &runtimeapi.ReopenContainerLogRequest{ContainerId: containerID}); err != nil {
+		if renameErr := os.Rename(rotatedPath, logPath); renameErr != nil {
+			return errors.Join(err, renameErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// trackUnusedImages records when each image was first seen to be unused, and forgets images that are in use
+// or no longer exist. The currently unused images are returned.
+func (c *controller) trackUnusedImages(ctx context.Context, now time.Time) ([]*runtimeapi.Image, error) {
+	images, err := c.images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := c.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	unused := unusedImages(images.Images, containers.Containers, c.cfg.PauseImage)
+	unusedSince := map[string]time.Time{}
+	for _, image := range unused {
+		if since, ok := c.unusedSince[image.Id]; ok {
+			unusedSince[image.Id] = since
+		} else {
+			unusedSince[image.Id] = now
+		}
+	}
+	c.unusedSince = unusedSince
+	return unused, nil
+}
+
+// removeImages removes images that have been unused for at least the minimum unused age.
+func (c *controller) removeImages(ctx context.Context) (string, error) {
+	now := time.Now()
+	unused, err := c.trackUnusedImages(ctx, now)
+	if err != nil {
+		return "", err
+	}
+
+	var removed []string
+	var freed uint64
+	var errs []error
+	for _, image := range unused {
+		if now.Sub(c.unusedSince[image.Id]) < minImageUnusedAge {
+			continue
+		}
+		if !c.cfg.DiskPressureDryRun {
+			if _, err := c.images.RemoveImage(ctx, &runtimeapi.RemoveImageRequest{Image: &runtimeapi.ImageSpec{Image: image.Id}}); err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to remove image %s", imageName(image)))
+				continue
+			}
+			delete(c.unusedSince, image.Id)
+		}
+		removed = append(removed, imageName(image))
+		freed += image.Size_
+	}
+	if len(removed) == 0 {
+		return "", errors.Join(errs...)
+	}
+	return fmt.Sprintf("removed %d unused images totalling %s: %s", len(removed), formatBytes(int64(freed)), strings.Join(removed, ", ")), errors.Join(errs...)
+}
+
+// removableSandboxes returns sandboxes that are not ready, are older than the minimum age, and do not have
+// any containers that are running or being created.
+func removableSandboxes(sandboxes []*runtimeapi.PodSandbox, containers []*runtimeapi.Container, now time.Time) []*runtimeapi.PodSandbox {
+	active := map[string]bool{}
+	for _, container := range containers {
+		if container.State != runtimeapi.ContainerState_CONTAINER_EXITED {
+			active[container.PodSandboxId] = true
+		}
+	}
+	var removable []*runtimeapi.PodSandbox
+	for _, sandbox := range sandboxes {
+		if sandbox.State != runtimeapi.PodSandboxState_SANDBOX_NOTREADY || active[sandbox.Id] {
+			continue
+		}
+		if now.Sub(time.Unix(0, sandbox.CreatedAt)) < minSandboxAge {
+			continue
+		}
+		removable = append(removable, sandbox)
+	}
+	return removable
+}
+
+// unusedImages returns images that are not pinned, are not the pause image, and are not referenced by any container.
+func unusedImages(images []*runtimeapi.Image, containers []*runtimeapi.Container, pauseImage string) []*runtimeapi.Image {
+	used := map[string]bool{}
+	if ref, err := docker.ParseDockerRef(pauseImage); err == nil {
+		used[ref.String()] = true
+	}
+	for _, container := range containers {
+		used[container.ImageRef] = true
+		if container.Image != nil {
+			used[container.Image.Image] = true
+		}
+	}
+	var unused []*runtimeapi.Image
+	for _, image := range images {
+		if image.Pinned || used[image.Id] || slices.ContainsFunc(image.RepoTags, func(s string) bool { return used[s] }) || slices.ContainsFunc(image.RepoDigests, func(s string) bool { return used[s] }) {
+			continue
+		}
+		unused = append(unused, image)
+	}
+	return unused
+}
+
+// logFiles returns the current log file and any rotated log files for a container, along with their total size.
+func logFiles(logPath string) ([]string, int64) {
+	matches, _ := filepath.Glob(logPath + ".*")
+	var files []string
+	var size int64
+	for _, file := range append([]string{logPath}, matches...) {
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			files = append(files, file)
+			size += info.Size()
+		}
+	}
+	return files, size
+}
+
+func sandboxName(sandbox *runtimeapi.PodSandbox) string {
+	if metadata := sandbox.GetMetadata(); metadata != nil {
+		return metadata.Namespace + "/" + metadata.Name
+	}
+	return sandbox.Id
+}
+
+func imageName(image *runtimeapi.Image) string {
+	if len(image.RepoTags) > 0 {
+		return image.RepoTags[0]
+	}
+	if len(image.RepoDigests) > 0 {
+		return image.RepoDigests[0]
+	}
+	return image.Id
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
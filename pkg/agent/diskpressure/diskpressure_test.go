@@ -0,0 +1,185 @@
+package diskpressure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func Test_UnitValidate(t *testing.T) {
+	valid := config.Agent{
+		DiskPressureActions:    []string{ActionSandboxes, ActionLogs, ActionImages},
+		DiskPressureThreshold:  85,
+		DiskPressureInterval:   metav1.Duration{Duration: time.Minute},
+		DiskPressureLogMaxSize: 50 * 1024 * 1024,
+	}
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Agent)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(cfg *config.Agent) {}},
+		{name: "invalid action", modify: func(cfg *config.Agent) { cfg.DiskPressureActions = []string{"volumes"} }, wantErr: true},
+		{name: "zero threshold", modify: func(cfg *config.Agent) { cfg.DiskPressureThreshold = 0 }, wantErr: true},
+		{name: "full threshold", modify: func(cfg *config.Agent) { cfg.DiskPressureThreshold = 100 }, wantErr: true},
+		{name: "no interval", modify: func(cfg *config.Agent) { cfg.DiskPressureInterval.Duration = 0 }, wantErr: true},
+		{name: "no log size", modify: func(cfg *config.Agent) { cfg.DiskPressureLogMaxSize = 0 }, wantErr: true},
+		{name: "no log size without logs action", modify: func(cfg *config.Agent) {
+			cfg.DiskPressureActions = []string{ActionImages}
+			cfg.DiskPressureLogMaxSize = 0
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if err := Validate(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitRemovableSandboxes(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour).UnixNano()
+	sandboxes := []*runtimeapi.PodSandbox{
+		{Id: "ready", State: runtimeapi.PodSandboxState_SANDBOX_READY, CreatedAt: old},
+		{Id: "completed", State: runtimeapi.PodSandboxState_SANDBOX_NOTREADY, CreatedAt: old},
+		{Id: "recent", State: runtimeapi.PodSandboxState_SANDBOX_NOTREADY, CreatedAt: now.UnixNano()},
+		{Id: "restarting", State: runtimeapi.PodSandboxState_SANDBOX_NOTREADY, CreatedAt: old},
+	}
+	containers := []*runtimeapi.Container{
+		{Id: "a", PodSandboxId: "completed", State: runtimeapi.ContainerState_CONTAINER_EXITED},
+		{Id: "b", PodSandboxId: "restarting", State: runtimeapi.ContainerState_CONTAINER_EXITED},
+		{Id: "c", PodSandboxId: "restarting", State: runtimeapi.ContainerState_CONTAINER_CREATED},
+	}
+
+	var got []string
+	for _, sandbox := range removableSandboxes(sandboxes, containers, now) {
+		got = append(got, sandbox.Id)
+	}
+	if want := []string{"completed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removableSandboxes() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitUnusedImages(t *testing.T) {
+	images := []*runtimeapi.Image{
+		{Id: "sha256:pause", RepoTags: []string{"docker.io/rancher/mirrored-pause:3.6"}},
+		{Id: "sha256:pinned", RepoTags: []string{"docker.io/rancher/klipper-helm:v0.9.0"}, Pinned: true},
+		{Id: "sha256:by-ref", RepoTags: []string{"docker.io/library/nginx:latest"}},
+		{Id: "sha256:by-tag", RepoTags: []string{"docker.io/library/busybox:latest"}},
+		{Id: "sha256:by-digest", RepoDigests: []string{"registry.example.com/app@sha256:1234"}},
+		{Id: "sha256:unused", RepoTags: []string{"docker.io/library/alpine:3.20"}},
+	}
+	containers := []*runtimeapi.Container{
+		{Id: "a", ImageRef: "sha256:by-ref"},
+		{Id: "b", Image: &runtimeapi.ImageSpec{Image: "docker.io/library/busybox:latest"}},
+		{Id: "c", Image: &runtimeapi.ImageSpec{Image: "registry.example.com/app@sha256:1234"}},
+	}
+
+	var got []string
+	for _, image := range unusedImages(images, containers, "rancher/mirrored-pause:3.6") {
+		got = append(got, image.Id)
+	}
+	if want := []string{"sha256:unused"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unusedImages() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                          "0B",
+		1023:                       "1023B",
+		1024:                       "1.0KiB",
+		50 * 1024 * 1024:           "50.0MiB",
+		3 * 1024 * 1024 * 1024 / 2: "1.5GiB",
+	}
+	for size, want := range tests {
+		if got := formatBytes(size); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
+
+// fakeRuntime implements the runtime service methods used to rotate logs; other methods are not implemented.
+type fakeRuntime struct {
+	runtimeapi.RuntimeServiceClient
+	containers []*runtimeapi.Container
+	logPaths   map[string]string
+	reopened   []string
+}
+
+func (f *fakeRuntime) ListContainers(ctx context.Context, in *runtimeapi.ListContainersRequest, opts ...grpc.CallOption) (*runtimeapi.ListContainersResponse, error) {
+	return &runtimeapi.ListContainersResponse{Containers: f.containers}, nil
+}
+
+func (f *fakeRuntime) ContainerStatus(ctx context.Context, in *runtimeapi.ContainerStatusRequest, opts ...grpc.CallOption) (*runtimeapi.ContainerStatusResponse, error) {
+	return &runtimeapi.ContainerStatusResponse{Status: &runtimeapi.ContainerStatus{Id: in.ContainerId, LogPath: f.logPaths[in.ContainerId]}}, nil
+}
+
+func (f *fakeRuntime) ReopenContainerLog(ctx context.Context, in *runtimeapi.ReopenContainerLogRequest, opts ...grpc.CallOption) (*runtimeapi.ReopenContainerLogResponse, error) {
+	f.reopened = append(f.reopened, in.ContainerId)
+	return &runtimeapi.ReopenContainerLogResponse{}, os.WriteFile(f.logPaths[in.ContainerId], nil, 0600)
+}
+
+func Test_UnitRotateLogs(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dryRun=%t", dryRun), func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile := func(name string, size int) string {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0600); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			}
+			bigLog := writeFile("big.log", 600)
+			bigRotated := writeFile("big.log.20260101-000000.gz", 600)
+			smallLog := writeFile("small.log", 100)
+
+			runtime := &fakeRuntime{
+				containers: []*runtimeapi.Container{
+					{Id: "big", Metadata: &runtimeapi.ContainerMetadata{Name: "big"}},
+					{Id: "small", Metadata: &runtimeapi.ContainerMetadata{Name: "small"}},
+				},
+				logPaths: map[string]string{"big": bigLog, "small": smallLog},
+			}
+			c := &controller{
+				cfg:     &config.Agent{DiskPressureLogMaxSize: 1000, DiskPressureDryRun: dryRun},
+				runtime: runtime,
+			}
+
+			result, err := c.rotateLogs(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(result, "rotated 1 container logs totalling 1.2KiB: big") {
+				t.Errorf("unexpected result %q", result)
+			}
+
+			files, size := logFiles(bigLog)
+			if dryRun {
+				if len(runtime.reopened) != 0 || !reflect.DeepEqual(files, []string{bigLog, bigRotated}) {
+					t.Errorf("dry run modified logs: reopened %v, files %v", runtime.reopened, files)
+				}
+			} else if !reflect.DeepEqual(runtime.reopened, []string{"big"}) || !reflect.DeepEqual(files, []string{bigLog}) || size != 0 {
+				t.Errorf("logs not rotated: reopened %v, files %v, size %d", runtime.reopened, files, size)
+			}
+			if files, size := logFiles(smallLog); len(files) != 1 || size != 100 {
+				t.Errorf("small log modified: files %v, size %d", files, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package diskpressure
+
+import "golang.org/x/sys/unix"
+
+// filesystemUsage returns the percentage of space and inodes in use on the filesystem containing
+// the given path. Space reserved for privileged users is not counted as available, consistent with df.
+func filesystemUsage(path string) (float64, float64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	var bytesUsed, inodesUsed float64
+	if used := st.Blocks - st.Bfree; used+st.Bavail > 0 {
+		bytesUsed = float64(used) * 100 / float64(used+st.Bavail)
+	}
+	if st.Files > 0 {
+		inodesUsed = float64(st.Files-st.Ffree) * 100 / float64(st.Files)
+	}
+	return bytesUsed, inodesUsed, nil
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package diskpressure
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+func filesystemUsage(path string) (float64, float64, error) {
+	return 0, 0, errors.ErrUnsupportedPlatform
+}
@@ -37,6 +37,12 @@ const (
 }
 `
 
+	mixedOSVXLANBackend = `{
+	"Type": "vxlan",
+	"VNI": 4096,
+	"Port": 4789
+}`
+
 	hostGWBackend = `{
 	"Type": "host-gw"
 }`
@@ -230,6 +236,10 @@ func createFlannelConf(nodeConfig *config.Node) error {
 	switch nodeConfig.Flannel.Backend {
 	case BackendVXLAN:
 		backendConf = vxlanBackend
+		if nodeConfig.AgentConfig.MixedOS {
+			// Windows nodes require a VNI of 4096 or greater, and the VNI and port must match on all nodes.
+			backendConf = mixedOSVXLANBackend
+		}
 	case BackendHostGW:
 		backendConf = hostGWBackend
 	case BackendTailscale:
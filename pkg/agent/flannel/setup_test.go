@@ -265,6 +265,17 @@ func Test_UnitcreateFlannelConf(t *testing.T) {
 				`"EnableIPv4": false`,
 			},
 		},
+		{
+			name: "vxlan backend mixed-os sets windows-compatible VNI and port",
+			nodeConfig: func(confFile string) *config.Node {
+				cidrs := stringToCIDR("10.42.0.0/16")
+				return &config.Node{
+					Flannel:     config.Flannel{Backend: BackendVXLAN, ConfFile: confFile},
+					AgentConfig: config.Agent{ClusterCIDR: cidrs[0], ClusterCIDRs: cidrs, MixedOS: true},
+				}
+			},
+			wantContain: []string{`"Type": "vxlan"`, `"VNI": 4096`, `"Port": 4789`},
+		},
 		{
 			name: "host-gw backend",
 			nodeConfig: func(confFile string) *config.Node {
@@ -5,8 +5,10 @@ import (
 	"net"
 	"net/url"
 	"strconv"
+	"sync"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
 	"github.com/k3s-io/k3s/pkg/util/errors"
@@ -14,6 +16,7 @@ import (
 
 type Proxy interface {
 	Update(addresses []string)
+	SetDiscoveredAddresses(addresses []string)
 	SetAPIServerPort(port int, isIPv6 bool) error
 	SetSupervisorDefault(address string)
 	IsSupervisorLBEnabled() bool
@@ -79,26 +82,58 @@ type proxy struct {
 	initialSupervisorURL      string
 	fallbackSupervisorAddress string
 	supervisorAddresses       []string
+	clusterAddresses          []string
+	discoveredAddresses       []string
 
+	// mu serializes updates to the load-balancer addresses, which are made by both the
+	// tunnel and the DNS server discovery.
+	mu           sync.Mutex
 	apiServerLB  *loadbalancer.LoadBalancer
 	supervisorLB *loadbalancer.LoadBalancer
 	context      context.Context
 }
 
+// Update sets the apiserver addresses retrieved from the cluster.
 func (p *proxy) Update(addresses []string) {
-	apiServerAddresses := addresses
-	supervisorAddresses := addresses
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clusterAddresses = addresses
+	p.update()
+}
+
+// SetDiscoveredAddresses sets the supervisor addresses discovered via DNS. These are load-balanced
+// to alongside the addresses retrieved from the cluster, but are not returned by SupervisorAddresses,
+// as tunnels are only opened to servers that are cluster members.
+func (p *proxy) SetDiscoveredAddresses(addresses []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.discoveredAddresses = addresses
+	p.update()
+}
+
+func (p *proxy) update() {
+	apiServerAddresses := p.clusterAddresses
+	supervisorAddresses := p.clusterAddresses
 
 	if p.apiServerEnabled {
 		supervisorAddresses = p.setSupervisorPort(supervisorAddresses)
 	}
+	p.supervisorAddresses = supervisorAddresses
+
+	if len(p.discoveredAddresses) > 0 {
+		discoveredAPIServerAddresses := p.discoveredAddresses
+		if p.apiServerEnabled {
+			discoveredAPIServerAddresses = setPort(discoveredAPIServerAddresses, p.apiServerPort)
+		}
+		apiServerAddresses = sets.List(sets.New(apiServerAddresses...).Insert(discoveredAPIServerAddresses...))
+		supervisorAddresses = sets.List(sets.New(supervisorAddresses...).Insert(p.discoveredAddresses...))
+	}
 	if p.apiServerLB != nil {
 		p.apiServerLB.Update(apiServerAddresses)
 	}
 	if p.supervisorLB != nil {
 		p.supervisorLB.Update(supervisorAddresses)
 	}
-	p.supervisorAddresses = supervisorAddresses
 }
 
 func (p *proxy) SetHealthCheck(address string, healthCheck loadbalancer.HealthCheckFunc) {
@@ -114,6 +149,10 @@ func (p *proxy) SetHealthCheck(address string, healthCheck loadbalancer.HealthCh
 }
 
 func (p *proxy) setSupervisorPort(addresses []string) []string {
+	return setPort(addresses, p.supervisorPort)
+}
+
+func setPort(addresses []string, port string) []string {
 	var newAddresses []string
 	for _, address := range addresses {
 		h, _, err := net.SplitHostPort(address)
@@ -121,7 +160,7 @@ func (p *proxy) setSupervisorPort(addresses []string) []string {
 			logrus.Errorf("Failed to parse address %s, dropping: %v", address, err)
 			continue
 		}
-		newAddresses = append(newAddresses, net.JoinHostPort(h, p.supervisorPort))
+		newAddresses = append(newAddresses, net.JoinHostPort(h, port))
 	}
 	return newAddresses
 }
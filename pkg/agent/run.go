@@ -15,6 +15,8 @@ import (
 	systemd "github.com/coreos/go-systemd/v22/daemon"
 	"github.com/k3s-io/k3s/pkg/agent/config"
 	"github.com/k3s-io/k3s/pkg/agent/containerd"
+	"github.com/k3s-io/k3s/pkg/agent/discovery"
+	"github.com/k3s-io/k3s/pkg/agent/diskpressure"
 	"github.com/k3s-io/k3s/pkg/agent/proxy"
 	"github.com/k3s-io/k3s/pkg/agent/syssetup"
 	"github.com/k3s-io/k3s/pkg/agent/tunnel"
@@ -26,6 +28,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/daemons/agent"
 	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/hooks"
 	"github.com/k3s-io/k3s/pkg/metrics"
 	"github.com/k3s-io/k3s/pkg/nodeconfig"
 	"github.com/k3s-io/k3s/pkg/profile"
@@ -95,6 +98,15 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		return errors.New("dual-stack or IPv6 are not supported on Windows node")
 	}
 
+	startupHooks, err := cmds.ParseHooks(&cfg)
+	if err != nil {
+		return err
+	}
+	nodeName := nodeConfig.AgentConfig.NodeName
+	if err := startupHooks.Run(ctx, hooks.PreNetwork, nodeName); err != nil {
+		return err
+	}
+
 	conntrackConfig, err := getConntrackConfig(nodeConfig)
 	if err != nil {
 		return errors.WithMessage(err, "failed to validate kube-proxy conntrack configuration")
@@ -106,7 +118,7 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 	// "none" literal rather than flannel.BackendNone to avoid importing the flannel package,
 	// which registers all flannel backends via init().
 	setBridgeFilter := !config.KubeProxyDisabled(ctx, nodeConfig, proxy) || nodeConfig.Flannel.Backend != "none"
-	syssetup.Configure(enableIPv6, setBridgeFilter, conntrackConfig)
+	syssetup.Configure(enableIPv6, setBridgeFilter, cfg.Containerized, conntrackConfig)
 	nodeConfig.AgentConfig.EnableIPv4 = enableIPv4
 	nodeConfig.AgentConfig.EnableIPv6 = enableIPv6
 
@@ -124,7 +136,31 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		}
 	}
 
-	if nodeConfig.SupervisorMetrics {
+	if nodeConfig.AgentConfig.WarmStandby {
+		if nodeConfig.Docker || nodeConfig.ContainerRuntimeEndpoint != "" {
+			return errors.New("warm standby requires embedded containerd")
+		}
+		if nodeConfig.AgentConfig.WarmStandbyInterval.Duration <= 0 {
+			return errors.New("warm-standby-interval must be greater than zero")
+		}
+	}
+
+	if nodeConfig.AgentConfig.ImageMirrorList != "" {
+		if nodeConfig.Docker || nodeConfig.ContainerRuntimeEndpoint != "" {
+			return errors.New("image mirroring requires embedded containerd")
+		}
+		if nodeConfig.AgentConfig.ImageMirrorInterval.Duration <= 0 {
+			return errors.New("image-mirror-interval must be greater than zero")
+		}
+	}
+
+	if len(nodeConfig.AgentConfig.DiskPressureActions) > 0 {
+		if err := diskpressure.Validate(&nodeConfig.AgentConfig); err != nil {
+			return err
+		}
+	}
+
+	if metrics.DefaultMetrics.Enabled(nodeConfig) {
 		if err := metrics.DefaultMetrics.Start(ctx, nodeConfig); err != nil {
 			return errors.WithMessage(err, "failed to serve metrics")
 		}
@@ -158,6 +194,20 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		return err
 	}
 
+	go func() {
+		<-executor.CRIReadyChan()
+		if err := startupHooks.Run(ctx, hooks.PostContainerd, nodeName); err != nil {
+			signals.RequestShutdown(err)
+		}
+	}()
+
+	go func() {
+		<-executor.APIServerReadyChan()
+		if err := startupHooks.Run(ctx, hooks.PostAPIServerReady, nodeName); err != nil {
+			signals.RequestShutdown(err)
+		}
+	}()
+
 	go func() {
 		<-executor.APIServerReadyChan()
 		if err := startNetwork(ctx, &sync.WaitGroup{}, nodeConfig); err != nil {
@@ -174,6 +224,33 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		}
 	}()
 
+	if nodeConfig.AgentConfig.WarmStandby {
+		go func() {
+			<-executor.APIServerReadyChan()
+			<-executor.CRIReadyChan()
+			if err := containerd.WarmStandby(ctx, nodeConfig); err != nil {
+				logrus.Errorf("Failed to start warm standby controller: %v", err)
+			}
+		}()
+	}
+
+	if len(nodeConfig.AgentConfig.DiskPressureActions) > 0 {
+		go func() {
+			<-executor.APIServerReadyChan()
+			<-executor.CRIReadyChan()
+			if err := diskpressure.Run(ctx, nodeConfig); err != nil {
+				logrus.Errorf("Failed to start disk pressure remediation controller: %v", err)
+			}
+		}()
+	}
+
+	if nodeConfig.AgentConfig.ImageMirrorList != "" {
+		go func() {
+			<-executor.CRIReadyChan()
+			containerd.MirrorImages(ctx, nodeConfig)
+		}()
+	}
+
 	return nil
 }
 
@@ -279,7 +356,7 @@ func RunStandalone(ctx context.Context, wg *sync.WaitGroup, cfg cmds.Agent) erro
 		return err
 	}
 
-	if nodeConfig.SupervisorMetrics {
+	if metrics.DefaultMetrics.Enabled(nodeConfig) {
 		if err := metrics.DefaultMetrics.Start(ctx, nodeConfig); err != nil {
 			return errors.WithMessage(err, "failed to serve metrics")
 		}
@@ -338,6 +415,14 @@ func createProxyAndValidateToken(ctx context.Context, cfg *cmds.Agent) (proxy.Pr
 		return nil, err
 	}
 
+	if cfg.ServerDiscoveryURL != "" && proxy.IsSupervisorLBEnabled() {
+		d, err := discovery.New(cfg.ServerDiscoveryURL, cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		go d.Watch(ctx, proxy.SetDiscoveredAddresses)
+	}
+
 	options := []clientaccess.ValidationOption{
 		clientaccess.WithUser("node"),
 		clientaccess.WithClientCertificate(clientKubeletCert, clientKubeletKey),
@@ -15,12 +15,18 @@ import (
 	kubeproxyconfig "k8s.io/kubernetes/pkg/proxy/apis/config"
 )
 
-func loadKernelModule(moduleName string) {
+func loadKernelModule(moduleName string, containerized bool) {
 	if _, err := os.Stat("/sys/module/" + moduleName); err == nil {
 		logrus.Info("Module " + moduleName + " was already loaded")
 		return
 	}
 
+	// Modules cannot be loaded from within a container, so they must be loaded on the host.
+	if containerized {
+		logrus.Warnf("Kernel module %v is not loaded; it must be loaded on the host when running in a container", moduleName)
+		return
+	}
+
 	if err := exec.Command("modprobe", "--", moduleName).Run(); err != nil {
 		logrus.Warnf("Failed to load kernel module %v with modprobe: %v", moduleName, err)
 	}
@@ -28,16 +34,17 @@ func loadKernelModule(moduleName string) {
 
 // Configure loads required kernel modules and sets sysctls required for other components to
 // function properly. The bridge netfilter sysctls are only managed when setBridgeFilter is
-// true; see kernelSysctls for details.
-func Configure(enableIPv6, setBridgeFilter bool, config *kubeproxyconfig.KubeProxyConntrackConfiguration) {
-	loadKernelModule("overlay")
-	loadKernelModule("nf_conntrack")
-	loadKernelModule("br_netfilter")
-	loadKernelModule("iptable_nat")
-	loadKernelModule("iptable_filter")
+// true; see kernelSysctls for details. When containerized, modules and sysctls that cannot be
+// managed from within the container are left for the host to configure.
+func Configure(enableIPv6, setBridgeFilter, containerized bool, config *kubeproxyconfig.KubeProxyConntrackConfiguration) {
+	loadKernelModule("overlay", containerized)
+	loadKernelModule("nf_conntrack", containerized)
+	loadKernelModule("br_netfilter", containerized)
+	loadKernelModule("iptable_nat", containerized)
+	loadKernelModule("iptable_filter", containerized)
 	if enableIPv6 {
-		loadKernelModule("ip6table_nat")
-		loadKernelModule("ip6table_filter")
+		loadKernelModule("ip6table_nat", containerized)
+		loadKernelModule("ip6table_filter", containerized)
 	}
 
 	sys := sysctl.New()
@@ -45,7 +52,11 @@ func Configure(enableIPv6, setBridgeFilter bool, config *kubeproxyconfig.KubePro
 		if val, _ := sys.GetSysctl(entry); val != value {
 			logrus.Infof("Set sysctl '%v' to %v", entry, value)
 			if err := sys.SetSysctl(entry, value); err != nil {
-				logrus.Errorf("Failed to set sysctl: %v", err)
+				if containerized {
+					logrus.Warnf("Failed to set sysctl: %v; it must be set on the host when running in a container", err)
+				} else {
+					logrus.Errorf("Failed to set sysctl: %v", err)
+				}
 			}
 		}
 	}
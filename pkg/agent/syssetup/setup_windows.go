@@ -2,6 +2,6 @@ package syssetup
 
 import kubeproxyconfig "k8s.io/kubernetes/pkg/proxy/apis/config"
 
-func Configure(enableIPv6, setBridgeFilter bool, config *kubeproxyconfig.KubeProxyConntrackConfiguration) {
+func Configure(enableIPv6, setBridgeFilter, containerized bool, config *kubeproxyconfig.KubeProxyConntrackConfiguration) {
 
 }
@@ -0,0 +1,161 @@
+package tokenupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/agent/proxy"
+	agentutil "github.com/k3s-io/k3s/pkg/agent/util"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// Address is the address dialed by servers through the agent tunnel to push token updates.
+	// It is not a real address; connections to it are served in memory by the agent.
+	// As the tunnel server only dials agents by IP address or on behalf of a node name,
+	// the address cannot be reached through the apiserver's egress proxy.
+	Address = "token-update.invalid:80"
+
+	verifyInterval = 10 * time.Second
+	verifyTimeout  = 15 * time.Minute
+)
+
+// Update is sent by servers to push the new agent token password to agents, after the cluster token has been rotated.
+type Update struct {
+	Password string `json:"password"`
+}
+
+// stored is the content of the agent's rotated token file.
+type stored struct {
+	// Replaces is the SHA256 hash of the token the agent was started with, before the token was first rotated.
+	Replaces string `json:"replaces"`
+	// Token is the token pushed by the server
+	Token string `json:"token"`
+}
+
+// Load returns the token stored in the token file, if it replaces the given token.
+// If the agent has since been started with a different token, the stored token is ignored.
+func Load(file, token string) string {
+	s, err := read(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("Failed to read rotated token from %s: %v", file, err)
+		}
+		return ""
+	}
+	if s.Replaces != hash(token) {
+		return ""
+	}
+	return s.Token
+}
+
+// Save atomically stores a token that replaces the current token. If the current token was itself loaded
+// from the token file, the token that it replaced is retained, so that the new token continues to be used
+// in place of the token that the agent is configured with.
+func Save(file, current, token string) error {
+	replaces := hash(current)
+	if s, err := read(file); err == nil && s.Token == current {
+		replaces = s.Replaces
+	}
+	b, err := json.Marshal(stored{Replaces: replaces, Token: token})
+	if err != nil {
+		return err
+	}
+	return util.AtomicWrite(file, b, 0600)
+}
+
+func read(file string) (*stored, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &stored{}
+	return s, json.Unmarshal(b, s)
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Handler handles token updates pushed by servers. The new token is created from the CA hash of the
+// agent's current token and the new password, and stored in the node's token file for use the next
+// time the agent is started. The new token is then verified by using it to authenticate to the supervisor.
+type Handler struct {
+	ctx   context.Context
+	node  *config.Node
+	proxy proxy.Proxy
+}
+
+// NewHandler returns a handler for token updates pushed to the agent.
+func NewHandler(ctx context.Context, node *config.Node, proxy proxy.Proxy) *Handler {
+	return &Handler{ctx: ctx, node: node, proxy: proxy}
+}
+
+// Dial returns a connection that is served in memory by the handler.
+func (h *Handler) Dial() net.Conn {
+	return agentutil.ServeConn(h)
+}
+
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+		return
+	}
+	update := Update{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 64*1024)).Decode(&update); err != nil {
+		util.SendError(err, resp, req, http.StatusBadRequest)
+		return
+	}
+	if update.Password == "" {
+		util.SendError(errors.New("password is required"), resp, req, http.StatusBadRequest)
+		return
+	}
+
+	token := clientaccess.ReplacePassword(h.node.Token, update.Password)
+	if err := Save(h.node.TokenFile, h.node.Token, token); err != nil {
+		util.SendError(err, resp, req, http.StatusInternalServerError)
+		return
+	}
+	logrus.Infof("Stored rotated token pushed by server in %s", h.node.TokenFile)
+	resp.WriteHeader(http.StatusOK)
+
+	go h.verify(token)
+}
+
+// verify retries authenticating to the supervisor with the new token until it succeeds, as servers
+// other than the one that rotated the token may not accept it until they have been restarted.
+func (h *Handler) verify(token string) {
+	ctx, cancel := context.WithTimeout(h.ctx, verifyTimeout)
+	defer cancel()
+	err := wait.PollUntilContextCancel(ctx, verifyInterval, true, func(ctx context.Context) (bool, error) {
+		info, err := clientaccess.ParseAndValidateToken(h.proxy.SupervisorURL(), token)
+		if err != nil {
+			logrus.Debugf("Failed to validate rotated token: %v", err)
+			return false, nil
+		}
+		if _, err := info.Get("/v1-" + version.Program + "/apiservers"); err != nil {
+			logrus.Debugf("Failed to authenticate with rotated token: %v", err)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		logrus.Warn("Unable to authenticate to the server with the rotated token; it will be used the next time the agent is started")
+		return
+	}
+	logrus.Info("Authenticated to the server with the rotated token; it will be used the next time the agent is started")
+}
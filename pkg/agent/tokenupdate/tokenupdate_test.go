@@ -0,0 +1,35 @@
+package tokenupdate
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_UnitLoadSave(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rotated-token")
+
+	if got := Load(file, "original"); got != "" {
+		t.Fatalf("Load() before Save = %q, want empty", got)
+	}
+
+	// first rotation replaces the configured token
+	if err := Save(file, "original", "rotated1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Load(file, "original"); got != "rotated1" {
+		t.Errorf("Load() = %q, want %q", got, "rotated1")
+	}
+
+	// second rotation, while running with the stored token, still replaces the configured token
+	if err := Save(file, "rotated1", "rotated2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Load(file, "original"); got != "rotated2" {
+		t.Errorf("Load() = %q, want %q", got, "rotated2")
+	}
+
+	// a different configured token takes precedence over the stored token
+	if got := Load(file, "other"); got != "" {
+		t.Errorf("Load() with different token = %q, want empty", got)
+	}
+}
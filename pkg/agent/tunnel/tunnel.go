@@ -10,9 +10,11 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/k3s-io/k3s/pkg/agent/certupdate"
 	agentconfig "github.com/k3s-io/k3s/pkg/agent/config"
 	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
 	"github.com/k3s-io/k3s/pkg/agent/proxy"
+	"github.com/k3s-io/k3s/pkg/agent/tokenupdate"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
@@ -57,6 +59,8 @@ type agentTunnel struct {
 	kubeletAddr string
 	kubeletPort string
 	startTime   time.Time
+	tokens      *tokenupdate.Handler
+	certs       *certupdate.Handler
 }
 
 // explicit interface check
@@ -95,9 +99,14 @@ func Setup(ctx context.Context, config *daemonconfig.Node, proxy proxy.Proxy) er
 		cidrs:       cidranger.NewPCTrieRanger(),
 		ports:       map[string]bool{},
 		mode:        config.EgressSelectorMode,
-		kubeletAddr: config.AgentConfig.ListenAddress,
+		kubeletAddr: config.AgentConfig.KubeletBindAddress,
 		kubeletPort: fmt.Sprint(ports.KubeletPort),
 		startTime:   time.Now().Truncate(time.Second),
+		certs:       certupdate.NewHandler(config),
+	}
+
+	if config.TokenFile != "" {
+		tunnel.tokens = tokenupdate.NewHandler(ctx, config, proxy)
 	}
 
 	go tunnel.startWatches(ctx, config, proxy)
@@ -334,11 +343,19 @@ func (a *agentTunnel) watchEndpointSlices(ctx context.Context, rbacReady <-chan
 }
 
 // authorized determines whether or not a dial request is authorized.
+// Connections to the token update address are allowed, if the agent accepts token updates.
+// Connections to the certificate update address are allowed.
 // Connections to the local kubelet ports are allowed.
 // Connections to other IPs are allowed if they are contained in a CIDR managed by this node.
 // All other requests are rejected.
 func (a *agentTunnel) authorized(ctx context.Context, proto, address string) bool {
 	logrus.Debugf("Tunnel authorizer checking dial request for %s", address)
+	if address == tokenupdate.Address {
+		return proto == "tcp" && a.tokens != nil
+	}
+	if address == certupdate.Address {
+		return proto == "tcp"
+	}
 	host, port, err := net.SplitHostPort(address)
 	if err == nil {
 		if a.isKubeletOrStreamPort(proto, host, port) {
@@ -418,8 +435,15 @@ func (a *agentTunnel) isKubeletOrStreamPort(proto, host, port string) bool {
 
 // dialContext dials a local connection on behalf of the remote server.  If the
 // connection is to the kubelet port on the loopback address, the kubelet is dialed
-// at its configured bind address.  Otherwise, the connection is dialed normally.
+// at its configured bind address.  Connections to the token and certificate update
+// addresses are served in memory.  Otherwise, the connection is dialed normally.
 func (a *agentTunnel) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if address == tokenupdate.Address && a.tokens != nil {
+		return a.tokens.Dial(), nil
+	}
+	if address == certupdate.Address {
+		return a.certs.Dial(), nil
+	}
 	host, port, err := net.SplitHostPort(address)
 	if err != nil {
 		return nil, err
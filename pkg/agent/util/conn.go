@@ -0,0 +1,39 @@
+package util
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ServeConn returns a connection that is served in memory by the handler. This is used to serve
+// requests made by servers through the agent tunnel, without listening on a real address.
+func ServeConn(handler http.Handler) net.Conn {
+	client, server := net.Pipe()
+	go (&http.Server{Handler: handler}).Serve(&connListener{conn: server})
+	return client
+}
+
+// connListener is a listener that returns a single connection.
+type connListener struct {
+	once sync.Once
+	conn net.Conn
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() { conn = l.conn })
+	if conn == nil {
+		return nil, io.EOF
+	}
+	return conn, nil
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
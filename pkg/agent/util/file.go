@@ -4,12 +4,14 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 )
 
+// WriteFile atomically writes content to the named file, creating the parent directory if necessary.
 func WriteFile(name string, content string) error {
 	os.MkdirAll(filepath.Dir(name), 0755)
-	err := os.WriteFile(name, []byte(content), 0644)
+	err := util.AtomicWrite(name, []byte(content), 0644)
 	if err != nil {
 		return errors.WithMessagef(err, "writing %s", name)
 	}
@@ -10,7 +10,7 @@ import (
 	fmt "fmt"
 	http "net/http"
 
-	k3sv1 "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/typed/k3s.cattle.io/v1"
+	k3sv1 "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/typed/k3s.cattle.io/v1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
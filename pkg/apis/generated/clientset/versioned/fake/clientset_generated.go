@@ -7,9 +7,9 @@ Copyright The Kubernetes Authors.
 package fake
 
 import (
-	clientset "github.com/k3s-io/k3s/pkg/generated/clientset/versioned"
-	k3sv1 "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/typed/k3s.cattle.io/v1"
-	fakek3sv1 "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/typed/k3s.cattle.io/v1/fake"
+	clientset "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned"
+	k3sv1 "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/typed/k3s.cattle.io/v1"
+	fakek3sv1 "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/typed/k3s.cattle.io/v1/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
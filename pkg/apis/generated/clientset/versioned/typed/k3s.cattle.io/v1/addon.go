@@ -9,8 +9,8 @@ package v1
 import (
 	context "context"
 
+	scheme "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/scheme"
 	k3scattleiov1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
-	scheme "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/scheme"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	watch "k8s.io/apimachinery/pkg/watch"
@@ -7,8 +7,8 @@ Copyright The Kubernetes Authors.
 package fake
 
 import (
+	k3scattleiov1 "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/typed/k3s.cattle.io/v1"
 	v1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
-	k3scattleiov1 "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/typed/k3s.cattle.io/v1"
 	gentype "k8s.io/client-go/gentype"
 )
 
@@ -7,7 +7,7 @@ Copyright The Kubernetes Authors.
 package fake
 
 import (
-	v1 "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/typed/k3s.cattle.io/v1"
+	v1 "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/typed/k3s.cattle.io/v1"
 	rest "k8s.io/client-go/rest"
 	testing "k8s.io/client-go/testing"
 )
@@ -24,6 +24,10 @@ func (c *FakeK3sV1) ETCDSnapshotFiles() v1.ETCDSnapshotFileInterface {
 	return newFakeETCDSnapshotFiles(c)
 }
 
+func (c *FakeK3sV1) ETCDSnapshotRestores() v1.ETCDSnapshotRestoreInterface {
+	return newFakeETCDSnapshotRestores(c)
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeK3sV1) RESTClient() rest.Interface {
@@ -9,3 +9,5 @@ package v1
 type AddonExpansion any
 
 type ETCDSnapshotFileExpansion any
+
+type ETCDSnapshotRestoreExpansion any
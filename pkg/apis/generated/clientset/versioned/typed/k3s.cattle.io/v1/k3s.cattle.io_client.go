@@ -9,8 +9,8 @@ package v1
 import (
 	http "net/http"
 
+	scheme "github.com/k3s-io/k3s/pkg/apis/generated/clientset/versioned/scheme"
 	k3scattleiov1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
-	scheme "github.com/k3s-io/k3s/pkg/generated/clientset/versioned/scheme"
 	rest "k8s.io/client-go/rest"
 )
 
@@ -18,6 +18,7 @@ type K3sV1Interface interface {
 	RESTClient() rest.Interface
 	AddonsGetter
 	ETCDSnapshotFilesGetter
+	ETCDSnapshotRestoresGetter
 }
 
 // K3sV1Client is used to interact with features provided by the k3s.cattle.io group.
@@ -33,6 +34,10 @@ func (c *K3sV1Client) ETCDSnapshotFiles() ETCDSnapshotFileInterface {
 	return newETCDSnapshotFiles(c)
 }
 
+func (c *K3sV1Client) ETCDSnapshotRestores() ETCDSnapshotRestoreInterface {
+	return newETCDSnapshotRestores(c)
+}
+
 // NewForConfig creates a new K3sV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
@@ -0,0 +1,8 @@
+module github.com/k3s-io/k3s/pkg/apis
+
+go 1.26.5
+
+require (
+	k8s.io/apimachinery v0.36.3
+	k8s.io/client-go v0.36.3
+)
@@ -37,6 +37,7 @@ type AddonSpec struct {
 // +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.spec.location`
 // +kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.size`
 // +kubebuilder:printcolumn:name="CreationTime",type=date,JSONPath=`.status.creationTime`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,priority=1
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ETCDSnapshot tracks a point-in-time snapshot of the etcd datastore.
@@ -100,6 +101,16 @@ type ETCDSnapshotStatus struct {
 	CreationTime *metav1.Time `json:"creationTime,omitempty" column:""`
 	// ReadyToUse indicates that the snapshot is available to be restored.
 	ReadyToUse *bool `json:"readyToUse,omitempty"`
+	// Phase is the current state of the snapshot. Snapshots that are still being saved
+	// or uploaded are InProgress; snapshots from older releases may not set a phase.
+	// +kubebuilder:validation:Enum=InProgress;Succeeded;Failed
+	Phase ETCDSnapshotPhase `json:"phase,omitempty"`
+	// BytesUploaded is the number of bytes of the snapshot file that have been uploaded
+	// to S3. Only set for snapshots stored on S3.
+	BytesUploaded *resource.Quantity `json:"bytesUploaded,omitempty"`
+	// Checksum is the checksum of the snapshot file, in the form "<algorithm>:<hex digest>".
+	// If not specified, the checksum is not available.
+	Checksum string `json:"checksum,omitempty"`
 	// Error is the last observed error during snapshot creation, if any.
 	// If the snapshot is retried, this field will be cleared on success.
 	Error *ETCDSnapshotError `json:"error,omitempty"`
@@ -112,4 +123,106 @@ type ETCDSnapshotError struct {
 	// Message is a string detailing the encountered error during snapshot creation if specified.
 	// NOTE: message may be logged, and it should not contain sensitive information.
 	Message *string `json:"message,omitempty"`
+	// Reason is a brief CamelCase string describing the step that failed, such as SaveFailed or UploadFailed.
+	Reason *string `json:"reason,omitempty"`
 }
+
+// ETCDSnapshotPhase describes the state of an etcd snapshot.
+type ETCDSnapshotPhase string
+
+const (
+	// ETCDSnapshotPhaseInProgress indicates that the snapshot is being saved or uploaded.
+	ETCDSnapshotPhaseInProgress ETCDSnapshotPhase = "InProgress"
+	// ETCDSnapshotPhaseSucceeded indicates that the snapshot was saved and is available to be restored.
+	ETCDSnapshotPhaseSucceeded ETCDSnapshotPhase = "Succeeded"
+	// ETCDSnapshotPhaseFailed indicates that the snapshot could not be saved or uploaded.
+	ETCDSnapshotPhaseFailed ETCDSnapshotPhase = "Failed"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="ETCDSnapshotFile",type=string,JSONPath=`.spec.etcdSnapshotFile`
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ETCDSnapshotRestore requests that a server node perform a cluster-reset restore from an etcd snapshot.
+// The server restarts to perform the restore, and records the result in the status once it has restarted.
+type ETCDSnapshotRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the snapshot to restore, and where and when to restore it.
+	Spec ETCDSnapshotRestoreSpec `json:"spec"`
+	// Status represents the progress of the restore.
+	Status ETCDSnapshotRestoreStatus `json:"status,omitempty"`
+}
+
+// ETCDSnapshotRestoreSpec describes an etcd snapshot restore.
+type ETCDSnapshotRestoreSpec struct {
+	// ETCDSnapshotFile is the name of the ETCDSnapshotFile resource for the snapshot to restore.
+	ETCDSnapshotFile string `json:"etcdSnapshotFile"`
+	// NodeName is the name of the server node that performs the restore. If not specified, the
+	// restore is performed by the node that took the snapshot.
+	NodeName string `json:"nodeName,omitempty"`
+	// MaintenanceWindow is the time window within which the restore may be started.
+	// If not specified, the restore is started immediately.
+	MaintenanceWindow *ETCDSnapshotRestoreWindow `json:"maintenanceWindow,omitempty"`
+	// Force restores the snapshot even if it was taken on an incompatible version, or from a cluster
+	// with a different token.
+	Force bool `json:"force,omitempty"`
+}
+
+// ETCDSnapshotRestoreWindow describes the time window within which a restore may be started.
+type ETCDSnapshotRestoreWindow struct {
+	// Start is the time at which the window opens. If not specified, the window is already open.
+	Start *metav1.Time `json:"start,omitempty"`
+	// End is the time at which the window closes. A restore that has not been started by this time
+	// fails instead of starting. If not specified, the window does not close.
+	End *metav1.Time `json:"end,omitempty"`
+}
+
+// ETCDSnapshotRestoreStatus is the status of the ETCDSnapshotRestore object.
+type ETCDSnapshotRestoreStatus struct {
+	// Phase is the current phase of the restore.
+	// +kubebuilder:validation:Enum=Pending;Preparing;Restoring;Succeeded;Failed
+	Phase ETCDSnapshotRestorePhase `json:"phase,omitempty"`
+	// SnapshotName is the name of the snapshot file being restored.
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// StartTime is the timestamp when the restore was started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the timestamp when the restore succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Conditions are the Accepted, Prepared, and Restored conditions of the restore.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ETCDSnapshotRestorePhase describes the phase of an etcd snapshot restore.
+type ETCDSnapshotRestorePhase string
+
+const (
+	// ETCDSnapshotRestorePhasePending indicates that the restore has been accepted, and is waiting for the maintenance window.
+	ETCDSnapshotRestorePhasePending ETCDSnapshotRestorePhase = "Pending"
+	// ETCDSnapshotRestorePhasePreparing indicates that the snapshot is being retrieved and checked for compatibility.
+	ETCDSnapshotRestorePhasePreparing ETCDSnapshotRestorePhase = "Preparing"
+	// ETCDSnapshotRestorePhaseRestoring indicates that the server is restarting to restore the snapshot.
+	ETCDSnapshotRestorePhaseRestoring ETCDSnapshotRestorePhase = "Restoring"
+	// ETCDSnapshotRestorePhaseSucceeded indicates that the snapshot was restored.
+	ETCDSnapshotRestorePhaseSucceeded ETCDSnapshotRestorePhase = "Succeeded"
+	// ETCDSnapshotRestorePhaseFailed indicates that the snapshot could not be restored.
+	ETCDSnapshotRestorePhaseFailed ETCDSnapshotRestorePhase = "Failed"
+)
+
+const (
+	// ETCDSnapshotRestoreConditionAccepted indicates that the restore request is valid, and has been accepted by the target node.
+	ETCDSnapshotRestoreConditionAccepted = "Accepted"
+	// ETCDSnapshotRestoreConditionPrepared indicates that the snapshot is available on the target node, and is compatible with the cluster.
+	ETCDSnapshotRestoreConditionPrepared = "Prepared"
+	// ETCDSnapshotRestoreConditionRestored indicates that the snapshot has been restored.
+	ETCDSnapshotRestoreConditionRestored = "Restored"
+)
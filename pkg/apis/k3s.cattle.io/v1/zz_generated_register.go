@@ -16,8 +16,9 @@ import (
 )
 
 var (
-	AddonResourceName            = "addons"
-	ETCDSnapshotFileResourceName = "etcdsnapshotfiles"
+	AddonResourceName               = "addons"
+	ETCDSnapshotFileResourceName    = "etcdsnapshotfiles"
+	ETCDSnapshotRestoreResourceName = "etcdsnapshotrestores"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -45,6 +46,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&AddonList{},
 		&ETCDSnapshotFile{},
 		&ETCDSnapshotFileList{},
+		&ETCDSnapshotRestore{},
+		&ETCDSnapshotRestoreList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
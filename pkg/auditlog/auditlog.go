@@ -0,0 +1,306 @@
+// Package auditlog records state-changing management actions, such as token, certificate, secrets
+// encryption, and etcd snapshot operations, to an append-only log under the server data-dir.
+// Each entry is signed with a key that is stored alongside the log, and the signature of each entry
+// covers the signature of the previous entry, so that entries cannot be modified, removed, or
+// reordered without invalidating the entries that follow them.
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	authuser "k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	// SourceLocal is recorded as the source of actions taken by commands run on the server itself.
+	SourceLocal = "local"
+
+	logName     = "management.log"
+	keyName     = "management.key"
+	enabledName = "enabled"
+
+	// maxReasonLength limits the length of failure reasons, so that entries remain a reasonable size.
+	maxReasonLength = 1024
+	// maxEntrySize bounds the size of the tail of the log that is read to find the previous entry.
+	maxEntrySize = 64 * 1024
+)
+
+// Entry is a single management action recorded in the audit log.
+type Entry struct {
+	// Sequence is incremented for each entry, starting at 1
+	Sequence int64     `json:"sequence"`
+	Time     time.Time `json:"time"`
+	// Action is the command that was run, such as "token rotate" or "etcd-snapshot save"
+	Action string `json:"action"`
+	// Target identifies what the action was taken on, such as a node or snapshot name
+	Target string `json:"target,omitempty"`
+	// Result is either success or failure
+	Result string `json:"result"`
+	// Reason describes why the action failed
+	Reason string `json:"reason,omitempty"`
+	// User is the authenticated identity of the caller
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	// LocalUser is the operating system user that ran the command, for actions taken on the server itself
+	LocalUser string `json:"localUser,omitempty"`
+	// Source is the address that the request came from, or local for actions taken on the server itself
+	Source   string `json:"source"`
+	Hostname string `json:"hostname"`
+	// Signature is the hex-encoded HMAC-SHA256 of the previous entry's signature, and this entry
+	Signature string `json:"signature"`
+}
+
+// Dir returns the audit log directory within the server data-dir.
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "audit")
+}
+
+// LogFile returns the path to the audit log within the server data-dir.
+func LogFile(dataDir string) string {
+	return filepath.Join(Dir(dataDir), logName)
+}
+
+// Setup enables or disables recording to the audit log within the server data-dir. When enabled, the
+// signing key is generated if it does not already exist. The enabled state is persisted, so that
+// commands that take actions without going through the server record them only if the server does.
+// Existing log entries and the signing key are retained when disabled, so that they can still be verified.
+func Setup(dataDir string, enabled bool) error {
+	dir := Dir(dataDir)
+	if !enabled {
+		if err := os.Remove(filepath.Join(dir, enabledName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	keyFile := filepath.Join(dir, keyName)
+	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, enabledName), nil, 0600)
+}
+
+// Enabled returns true if recording to the audit log within the server data-dir is enabled.
+func Enabled(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(Dir(dataDir), enabledName))
+	return err == nil
+}
+
+// Record signs the entry and appends it to the audit log, if enabled. The sequence number and
+// signature are set from the previous entry; the time and hostname are set if empty.
+func Record(dataDir string, entry Entry) error {
+	if !Enabled(dataDir) {
+		return nil
+	}
+	key, err := readKey(dataDir)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(LogFile(dataDir), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// the log may be written to by both the server and the CLI, so the file must be locked
+	// while the previous entry is read and the new entry is appended.
+	if err := lockFile(f); err != nil {
+		return errors.WithMessage(err, "failed to lock audit log")
+	}
+	defer unlockFile(f)
+
+	prev, err := lastEntry(f)
+	if err != nil {
+		return errors.WithMessage(err, "failed to read previous audit log entry")
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.Hostname == "" {
+		entry.Hostname, _ = os.Hostname()
+	}
+	if len(entry.Reason) > maxReasonLength {
+		entry.Reason = entry.Reason[:maxReasonLength]
+	}
+	entry.Sequence = 1
+	prevSignature := ""
+	if prev != nil {
+		entry.Sequence = prev.Sequence + 1
+		prevSignature = prev.Signature
+	}
+	if entry.Signature, err = sign(key, prevSignature, entry); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// RecordLocal records an action taken by a command run on the server itself. If info is nil, the
+// operating system user that ran the command is recorded as the user. Failures to record the
+// action are logged, but do not cause the command to fail.
+func RecordLocal(dataDir string, info authuser.Info, action, target string, actionErr error) {
+	entry := NewEntry(action, target, actionErr)
+	entry.Source = SourceLocal
+	entry.LocalUser = localUser()
+	if info != nil {
+		entry.User = info.GetName()
+		entry.Groups = info.GetGroups()
+	} else {
+		entry.User = entry.LocalUser
+	}
+	if err := Record(dataDir, entry); err != nil {
+		logrus.Warnf("Failed to record %s to management audit log: %v", action, err)
+	}
+}
+
+// NewEntry returns an entry for an action, with the result set from the error returned by the action.
+func NewEntry(action, target string, actionErr error) Entry {
+	entry := Entry{
+		Action: action,
+		Target: target,
+		Result: ResultSuccess,
+	}
+	if actionErr != nil {
+		entry.Result = ResultFailure
+		entry.Reason = actionErr.Error()
+	}
+	return entry
+}
+
+// Verify checks the sequence and signature of each entry in the audit log within the server data-dir,
+// returning the number of entries that were verified. An error is returned for the first entry that
+// is out of sequence or has an invalid signature, as that entry or a previous entry has been modified.
+func Verify(dataDir string) (int64, error) {
+	key, err := readKey(dataDir)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(LogFile(dataDir))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return verify(f, key)
+}
+
+func verify(r io.Reader, key []byte) (int64, error) {
+	var count int64
+	prevSignature := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxEntrySize)
+	for scanner.Scan() {
+		line := count + 1
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Sequence != line {
+			return count, fmt.Errorf("line %d: expected sequence %d, found %d", line, line, entry.Sequence)
+		}
+		want, err := sign(key, prevSignature, entry)
+		if err != nil {
+			return count, err
+		}
+		if !hmac.Equal([]byte(want), []byte(entry.Signature)) {
+			return count, fmt.Errorf("line %d: invalid signature for entry %d", line, entry.Sequence)
+		}
+		prevSignature = entry.Signature
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// sign returns the signature for the entry, which covers the previous signature and all fields
+// of the entry other than its own signature.
+func sign(key []byte, prevSignature string, entry Entry) (string, error) {
+	entry.Signature = ""
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(prevSignature))
+	mac.Write([]byte{'\n'})
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// lastEntry returns the last entry in the log, or nil if the log is empty.
+func lastEntry(f *os.File) (*Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+	offset := max(size-maxEntrySize, 0)
+	buf := make([]byte, size-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(buf, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func readKey(dataDir string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(Dir(dataDir), keyName))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read audit log signing key")
+	}
+	return hex.DecodeString(string(bytes.TrimSpace(b)))
+}
+
+// localUser returns the name and uid of the operating system user running the current process.
+func localUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return "uid=" + strconv.Itoa(os.Getuid())
+	}
+	return u.Username + " (uid=" + u.Uid + ")"
+}
@@ -0,0 +1,188 @@
+package auditlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	authuser "k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func Test_UnitRecordAndVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		tamper  func(lines [][]byte) [][]byte
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:    "Disabled",
+			enabled: false,
+		},
+		{
+			name:    "Unmodified",
+			enabled: true,
+			want:    3,
+		},
+		{
+			name:    "Modified entry",
+			enabled: true,
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"token rotate"`), []byte(`"token create"`), 1)
+				return lines
+			},
+			want:    1,
+			wantErr: true,
+		},
+		{
+			name:    "Removed entry",
+			enabled: true,
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			want:    1,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated log",
+			enabled: true,
+			tamper: func(lines [][]byte) [][]byte {
+				return lines[:2]
+			},
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if err := Setup(dataDir, true); err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+			if err := Setup(dataDir, tt.enabled); err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+
+			RecordLocal(dataDir, nil, "etcd-snapshot save", "on-demand", nil)
+			RecordLocal(dataDir, &authuser.DefaultInfo{Name: "admin", Groups: []string{"system:masters"}}, "token rotate", "", nil)
+			RecordLocal(dataDir, nil, "certificate rotate", "k3s-controller-manager", errors.New("permission denied"))
+
+			b, err := os.ReadFile(LogFile(dataDir))
+			if !tt.enabled {
+				if !os.IsNotExist(err) {
+					t.Fatalf("expected no audit log when disabled, got error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read audit log: %v", err)
+			}
+
+			lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+			if tt.tamper != nil {
+				lines = tt.tamper(lines)
+				if err := os.WriteFile(LogFile(dataDir), append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+					t.Fatalf("failed to write audit log: %v", err)
+				}
+			}
+
+			got, err := Verify(dataDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		status     int
+		err        error
+		wantResult string
+		wantReason string
+	}{
+		{
+			name:   "Not annotated",
+			status: http.StatusOK,
+		},
+		{
+			name:       "Success",
+			action:     "secrets-encrypt rotate-keys",
+			status:     http.StatusOK,
+			wantResult: ResultSuccess,
+		},
+		{
+			name:       "Failure",
+			action:     "secrets-encrypt rotate-keys",
+			status:     http.StatusBadRequest,
+			err:        errors.New("secrets encryption is not enabled"),
+			wantResult: ResultFailure,
+			wantReason: "secrets encryption is not enabled",
+		},
+		{
+			name:       "Failure without message",
+			action:     "etcd defrag",
+			status:     http.StatusInternalServerError,
+			wantResult: ResultFailure,
+			wantReason: "500 Internal Server Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if err := Setup(dataDir, true); err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+
+			handler := Middleware(dataDir)(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				if tt.action != "" {
+					Annotate(req, tt.action, "")
+				}
+				if tt.err != nil {
+					util.SendError(tt.err, resp, req, tt.status)
+					return
+				}
+				resp.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodPut, "/v1-k3s/encrypt/config", nil)
+			req.RemoteAddr = "10.0.0.1:34567"
+			req = req.WithContext(apirequest.WithUser(req.Context(), &authuser.DefaultInfo{Name: "server", Groups: []string{"k3s:server"}}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			b, err := os.ReadFile(LogFile(dataDir))
+			if tt.action == "" {
+				if !os.IsNotExist(err) {
+					t.Fatalf("expected no audit log for request that was not annotated, got error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read audit log: %v", err)
+			}
+
+			entry := Entry{}
+			if err := json.Unmarshal(bytes.TrimSpace(b), &entry); err != nil {
+				t.Fatalf("failed to decode audit log entry: %v", err)
+			}
+			if entry.Action != tt.action || entry.Result != tt.wantResult || !strings.HasPrefix(entry.Reason, tt.wantReason) {
+				t.Errorf("entry = %+v, want action %q result %q reason %q", entry, tt.action, tt.wantResult, tt.wantReason)
+			}
+			if entry.User != "server" || entry.Source != "10.0.0.1" {
+				t.Errorf("entry = %+v, want user server from 10.0.0.1", entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package auditlog
+
+import (
+	"context"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authuser "k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientUser returns the identity that the client is authenticated to the apiserver as, for recording
+// actions that commands take through the Kubernetes API. Nil is returned if the identity cannot be
+// determined, so that the operating system user is recorded instead.
+func ClientUser(ctx context.Context, client kubernetes.Interface) authuser.Info {
+	review, err := client.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil || review.Status.UserInfo.Username == "" {
+		return nil
+	}
+	return &authuser.DefaultInfo{
+		Name:   review.Status.UserInfo.Username,
+		UID:    review.Status.UserInfo.UID,
+		Groups: review.Status.UserInfo.Groups,
+	}
+}
@@ -0,0 +1,130 @@
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventsInterval     = 10 * time.Second
+	eventsSequenceName = "events.sequence"
+)
+
+// eventsController records entries appended to the audit log as Kubernetes Events. Entries are read from
+// the log rather than recorded as they are appended, so that actions taken by commands run on the server
+// while it is stopped, such as certificate rotation and snapshot restores, are recorded once it starts.
+type eventsController struct {
+	dataDir  string
+	recorder record.EventRecorder
+	nodeRef  *corev1.ObjectReference
+	// sequence is the sequence number of the last entry recorded as an event
+	sequence int64
+}
+
+// StartEvents starts recording audit log entries as Kubernetes Events attached to the server's Node resource,
+// if enabled. Entries appended while the server was stopped are recorded when it starts; the first time that
+// events are enabled, only entries appended after the server starts are recorded.
+func StartEvents(ctx context.Context, control *config.Control) {
+	nodeName := os.Getenv("NODE_NAME")
+	if !control.ManagementAuditEvents || control.Runtime.Event == nil || nodeName == "" {
+		return
+	}
+	c := &eventsController{
+		dataDir:  control.DataDir,
+		recorder: control.Runtime.Event,
+		nodeRef: &corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			UID:  types.UID(nodeName),
+		},
+		sequence: -1,
+	}
+	if b, err := os.ReadFile(c.sequenceFile()); err == nil {
+		c.sequence, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+	go wait.UntilWithContext(ctx, c.sync, eventsInterval)
+}
+
+func (c *eventsController) sequenceFile() string {
+	return filepath.Join(Dir(c.dataDir), eventsSequenceName)
+}
+
+// sync records events for entries with a sequence number greater than that of the last recorded entry.
+func (c *eventsController) sync(ctx context.Context) {
+	f, err := os.Open(LogFile(c.dataDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Errorf("Failed to read management audit log: %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	entries, err := readEntriesAfter(f, c.sequence)
+	if err != nil {
+		logrus.Errorf("Failed to read management audit log: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		if c.sequence < 0 {
+			c.sequence = 0
+		}
+		return
+	}
+	// if no entries have been recorded before, start with the last entry in the log
+	if c.sequence < 0 {
+		c.sequence = entries[len(entries)-1].Sequence
+	} else {
+		for _, entry := range entries {
+			c.record(entry)
+			c.sequence = entry.Sequence
+		}
+	}
+	if err := os.WriteFile(c.sequenceFile(), []byte(strconv.FormatInt(c.sequence, 10)+"\n"), 0600); err != nil {
+		logrus.Errorf("Failed to save management audit log event sequence: %v", err)
+	}
+}
+
+func (c *eventsController) record(entry Entry) {
+	message := entry.Action
+	if entry.Target != "" {
+		message += " " + entry.Target
+	}
+	message += " by " + entry.User + " from " + entry.Source + " on " + entry.Hostname
+	if entry.Result == ResultSuccess {
+		c.recorder.Event(c.nodeRef, corev1.EventTypeNormal, "ManagementActionSucceeded", message)
+	} else {
+		c.recorder.Event(c.nodeRef, corev1.EventTypeWarning, "ManagementActionFailed", message+" failed: "+entry.Reason)
+	}
+}
+
+// readEntriesAfter returns all entries in the log with a sequence number greater than the provided sequence.
+func readEntriesAfter(r io.Reader, sequence int64) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxEntrySize)
+	for scanner.Scan() {
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if entry.Sequence > sequence {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,116 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/k3s-io/k3s/pkg/util/mux"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type contextKey struct{}
+
+// annotation holds the action and target for a request, as set by the handler.
+type annotation struct {
+	action string
+	target string
+}
+
+// Annotate sets the action and target that a request is recorded as. Only requests that are
+// annotated by their handler are recorded, so that read-only requests are not recorded.
+// Annotate has no effect if the request was not routed through the audit middleware.
+func Annotate(req *http.Request, action, target string) {
+	if a, ok := req.Context().Value(contextKey{}).(*annotation); ok {
+		a.action = action
+		a.target = target
+	}
+}
+
+// Middleware returns a middleware function that records requests to the audit log within the
+// server data-dir, if enabled. It must run after the request is authenticated, so that the
+// authenticated user is available. The result is determined by the response status code.
+func Middleware(dataDir string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !Enabled(dataDir) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			a := &annotation{}
+			sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(sw, req.WithContext(context.WithValue(req.Context(), contextKey{}, a)))
+			if a.action == "" {
+				return
+			}
+
+			entry := Entry{
+				Action: a.action,
+				Target: a.target,
+				Result: ResultSuccess,
+			}
+			if sw.status >= http.StatusBadRequest {
+				entry.Result = ResultFailure
+				entry.Reason = sw.reason()
+			}
+			if info, ok := apirequest.UserFrom(req.Context()); ok {
+				entry.User = info.GetName()
+				entry.Groups = info.GetGroups()
+			}
+			entry.Source, _, _ = net.SplitHostPort(req.RemoteAddr)
+			if err := Record(dataDir, entry); err != nil {
+				logrus.Warnf("Failed to record %s to management audit log: %v", a.action, err)
+			}
+		})
+	}
+}
+
+// statusWriter records the status code of the response, and the body of error responses.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if w.status >= http.StatusBadRequest && w.body.Len() < maxReasonLength {
+		w.body.Write(b[:min(len(b), maxReasonLength-w.body.Len())])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// reason returns the message from an error response, or the status code if the response
+// does not contain a message.
+func (w *statusWriter) reason() string {
+	status := metav1.Status{}
+	if err := json.Unmarshal(w.body.Bytes(), &status); err == nil && status.Message != "" {
+		return status.Message
+	}
+	return strconv.Itoa(w.status) + " " + http.StatusText(w.status)
+}
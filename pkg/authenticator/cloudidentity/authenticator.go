@@ -0,0 +1,277 @@
+package cloudidentity
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	gcpIssuer         = "https://accounts.google.com"
+	azureIssuerFormat = "https://sts.windows.net/%s/"
+	verifyTimeout     = 10 * time.Second
+)
+
+var (
+	ErrInvalidCredential = errors.New("invalid cloud identity credential")
+	ErrNotAllowed        = errors.New("cloud identity is not allowed to join the cluster")
+
+	stsHostRegexp = regexp.MustCompile(`^sts(\.[a-z0-9-]+)?\.amazonaws\.com$`)
+
+	// stsHeaders are the headers forwarded from the signed request to STS
+	stsHeaders = []string{"Authorization", "Content-Type", "X-Amz-Content-Sha256", "X-Amz-Date", "X-Amz-Security-Token", clusterIDHeader}
+)
+
+// Authenticator authenticates agents with a cloud identity credential in the join password,
+// in place of a shared secret. Agents with an identity that matches one of the rules are
+// authenticated as a member of the agent group.
+type Authenticator struct {
+	rules     []Rule
+	audience  string
+	clusterID string
+	client    *http.Client
+
+	mu        sync.Mutex
+	verifiers map[string]*oidc.IDTokenVerifier
+}
+
+// New returns a password authenticator for the given rules. The cluster ID is the hash of the cluster's server CA,
+// which must be included in the signed headers of AWS credentials. The audience is the required audience of GCP
+// and Azure identity tokens; if not set, it is derived from the cluster ID. Azure tokens can only be issued for the
+// application ID URI of an app registration, so the audience must be set if there are any Azure rules.
+func New(values []string, audience, clusterID string) (*Authenticator, error) {
+	rules, err := ParseRules(values)
+	if err != nil {
+		return nil, err
+	}
+	if clusterID == "" {
+		return nil, errors.New("cluster ID must not be empty")
+	}
+	if audience == "" {
+		for _, rule := range rules {
+			if rule.Provider == Azure {
+				return nil, errors.New("--cloud-identity-audience must be set to the application ID URI of an app registration when using azure cloud identity rules")
+			}
+		}
+		audience = DefaultAudience(clusterID)
+	}
+	return &Authenticator{
+		rules:     rules,
+		audience:  audience,
+		clusterID: clusterID,
+		client:    &http.Client{Timeout: verifyTimeout},
+		verifiers: map[string]*oidc.IDTokenVerifier{},
+	}, nil
+}
+
+// AuthenticatePassword returns user info if the password contains a valid cloud identity credential
+// that is allowed by the rules. Passwords that do not contain a cloud identity credential are ignored.
+func (a *Authenticator) AuthenticatePassword(ctx context.Context, username, password string) (*authenticator.Response, bool, error) {
+	provider, credential, ok := ParsePassword(password)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var identity string
+	var err error
+	switch provider {
+	case AWS:
+		identity, err = a.verifyAWS(ctx, credential)
+	case GCP:
+		identity, err = a.verifyGCP(ctx, credential)
+	case Azure:
+		identity, err = a.verifyAzure(ctx, credential)
+	}
+	if err != nil {
+		return nil, false, errors.WithMessagef(err, "failed to verify %s cloud identity", provider)
+	}
+
+	for _, rule := range a.rules {
+		if rule.Matches(provider, identity) {
+			return &authenticator.Response{
+				User: &user.DefaultInfo{
+					Name:   "system:cloud-identity:" + provider + ":" + identity,
+					Groups: []string{version.Program + ":agent"},
+				},
+			}, true, nil
+		}
+	}
+	return nil, false, errors.WithMessagef(ErrNotAllowed, "%s identity %s", provider, identity)
+}
+
+// verifyAWS sends the signed GetCallerIdentity request to STS, and returns the ARN of the caller.
+// The request is checked to ensure that it can only be sent to STS, and only returns the caller identity.
+func (a *Authenticator) verifyAWS(ctx context.Context, credential string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(credential)
+	if err != nil {
+		return "", ErrInvalidCredential
+	}
+	sr := stsRequest{}
+	if err := json.Unmarshal(b, &sr); err != nil {
+		return "", ErrInvalidCredential
+	}
+	u, err := url.Parse(sr.URL)
+	if err != nil || u.Scheme != "https" || !stsHostRegexp.MatchString(u.Host) || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", errors.WithMessagef(ErrInvalidCredential, "unexpected STS URL %q", sr.URL)
+	}
+	if sr.Body != getCallerIdentity {
+		return "", errors.WithMessage(ErrInvalidCredential, "unexpected STS request body")
+	}
+	if err := a.verifyClusterID(sr.Headers); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(sr.Body))
+	if err != nil {
+		return "", err
+	}
+	for _, header := range stsHeaders {
+		if value := sr.Headers.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err = io.ReadAll(io.LimitReader(resp.Body, maxMetadataBodySize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", u.Host, resp.Status)
+	}
+	result := struct {
+		Arn string `xml:"GetCallerIdentityResult>Arn"`
+	}{}
+	if err := xml.NewDecoder(bytes.NewReader(b)).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Arn == "" {
+		return "", fmt.Errorf("%s: response did not contain caller ARN", u.Host)
+	}
+	return result.Arn, nil
+}
+
+// verifyClusterID checks that the signed request is bound to this cluster, by ensuring that the cluster ID header
+// matches, and is one of the signed headers. STS rejects the request if the header value does not match the signature.
+func (a *Authenticator) verifyClusterID(headers http.Header) error {
+	if headers.Get(clusterIDHeader) != a.clusterID {
+		return errors.WithMessage(ErrInvalidCredential, "credential is not bound to this cluster")
+	}
+	_, signedHeaders, ok := strings.Cut(headers.Get("Authorization"), "SignedHeaders=")
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+	if !ok || !slices.Contains(strings.Split(signedHeaders, ";"), strings.ToLower(clusterIDHeader)) {
+		return errors.WithMessage(ErrInvalidCredential, "cluster ID header is not signed")
+	}
+	return nil
+}
+
+// verifyGCP verifies a GCP instance identity token, and returns the service account email.
+func (a *Authenticator) verifyGCP(ctx context.Context, credential string) (string, error) {
+	token, err := a.verify(ctx, gcpIssuer, credential)
+	if err != nil {
+		return "", err
+	}
+	claims := struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}{}
+	if err := token.Claims(&claims); err != nil {
+		return "", err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return "", errors.WithMessage(ErrInvalidCredential, "token does not contain a verified service account email")
+	}
+	return claims.Email, nil
+}
+
+// verifyAzure verifies an Azure managed identity token, and returns the tenant and object IDs
+// in TENANT/OBJECT format. Only tokens from tenants that appear in the rules are verified.
+func (a *Authenticator) verifyAzure(ctx context.Context, credential string) (string, error) {
+	unverified := struct {
+		TenantID string `json:"tid"`
+	}{}
+	if err := unverifiedClaims(credential, &unverified); err != nil || unverified.TenantID == "" {
+		return "", ErrInvalidCredential
+	}
+	if !a.allowsTenant(unverified.TenantID) {
+		return "", errors.WithMessagef(ErrNotAllowed, "azure tenant %s", unverified.TenantID)
+	}
+
+	token, err := a.verify(ctx, fmt.Sprintf(azureIssuerFormat, unverified.TenantID), credential)
+	if err != nil {
+		return "", err
+	}
+	claims := struct {
+		TenantID string `json:"tid"`
+		ObjectID string `json:"oid"`
+	}{}
+	if err := token.Claims(&claims); err != nil {
+		return "", err
+	}
+	if claims.TenantID == "" || claims.ObjectID == "" {
+		return "", errors.WithMessage(ErrInvalidCredential, "token does not contain tenant and object IDs")
+	}
+	return claims.TenantID + "/" + claims.ObjectID, nil
+}
+
+func (a *Authenticator) allowsTenant(tenantID string) bool {
+	for _, rule := range a.rules {
+		if tenant, _, _ := strings.Cut(rule.Pattern, "/"); rule.Provider == Azure && tenant == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// verify verifies the signature, issuer, audience, and expiry of an OIDC token.
+// Verifiers are created on first use, as discovery requires a request to the issuer.
+func (a *Authenticator) verify(ctx context.Context, issuer, rawToken string) (*oidc.IDToken, error) {
+	a.mu.Lock()
+	verifier, ok := a.verifiers[issuer]
+	if !ok {
+		// The provider caches keys for use by later requests, so it must not use the request context
+		provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), a.client), issuer)
+		if err != nil {
+			a.mu.Unlock()
+			return nil, errors.WithMessagef(err, "failed to discover OIDC issuer %s", issuer)
+		}
+		verifier = provider.Verifier(&oidc.Config{ClientID: a.audience})
+		a.verifiers[issuer] = verifier
+	}
+	a.mu.Unlock()
+	return verifier.Verify(ctx, rawToken)
+}
+
+// unverifiedClaims decodes the claims from a JWT without verifying it, so that the issuer can be determined.
+func unverifiedClaims(rawToken string, claims any) error {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return ErrInvalidCredential
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, claims)
+}
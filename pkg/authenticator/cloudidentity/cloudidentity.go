@@ -0,0 +1,92 @@
+package cloudidentity
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+const (
+	AWS   = "aws"
+	GCP   = "gcp"
+	Azure = "azure"
+
+	// passwordPrefix identifies join passwords that contain a cloud identity credential,
+	// instead of a shared secret.
+	passwordPrefix = "cloud-identity:"
+)
+
+var ErrUnsupportedProvider = errors.New("unsupported cloud identity provider")
+
+// Rule allows agents with a matching cloud identity to join the cluster. The pattern is matched against
+// the caller ARN for AWS, the service account email for GCP, or the tenant and object IDs for Azure,
+// formatted as TENANT/OBJECT. Azure patterns must specify a tenant ID, as tokens are only accepted from
+// the tenants listed in the rules.
+type Rule struct {
+	Provider string
+	Pattern  string
+}
+
+// ParseRules parses rules in PROVIDER:PATTERN format, for example
+// aws:arn:aws:sts::123456789012:assumed-role/agent-role/*
+func ParseRules(values []string) ([]Rule, error) {
+	var rules []Rule
+	for _, value := range values {
+		provider, pattern, _ := strings.Cut(value, ":")
+		if err := validProvider(provider); err != nil {
+			return nil, errors.WithMessagef(err, "invalid cloud identity rule %q", value)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("invalid cloud identity rule %q: pattern must not be empty", value)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.WithMessagef(err, "invalid cloud identity rule %q", value)
+		}
+		if provider == Azure {
+			tenant, _, ok := strings.Cut(pattern, "/")
+			if !ok || tenant == "" || strings.ContainsAny(tenant, `*?[\`) {
+				return nil, fmt.Errorf("invalid cloud identity rule %q: pattern must be in TENANT/OBJECT format, with a literal tenant ID", value)
+			}
+		}
+		rules = append(rules, Rule{Provider: provider, Pattern: pattern})
+	}
+	return rules, nil
+}
+
+// Matches returns true if the rule allows the identity from the given provider.
+func (r Rule) Matches(provider, identity string) bool {
+	if r.Provider != provider {
+		return false
+	}
+	ok, _ := path.Match(r.Pattern, identity)
+	return ok
+}
+
+// FormatPassword returns a join password containing a cloud identity credential.
+func FormatPassword(provider, credential string) string {
+	return passwordPrefix + provider + ":" + credential
+}
+
+// ParsePassword returns the provider and credential from a join password,
+// along with a bool indicating if the password contains a cloud identity credential.
+func ParsePassword(password string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(password, passwordPrefix)
+	if !ok {
+		return "", "", false
+	}
+	provider, credential, ok := strings.Cut(rest, ":")
+	if !ok || validProvider(provider) != nil || credential == "" {
+		return "", "", false
+	}
+	return provider, credential, true
+}
+
+func validProvider(provider string) error {
+	switch provider {
+	case AWS, GCP, Azure:
+		return nil
+	}
+	return fmt.Errorf("%w %q", ErrUnsupportedProvider, provider)
+}
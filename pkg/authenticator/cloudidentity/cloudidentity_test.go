@@ -0,0 +1,167 @@
+package cloudidentity
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_UnitParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			values: []string{"aws:arn:aws:sts::123456789012:assumed-role/agent/*", "gcp:*@project.iam.gserviceaccount.com", "azure:tenant/*"},
+		},
+		{
+			name:    "unknown provider",
+			values:  []string{"ibm:*"},
+			wantErr: true,
+		},
+		{
+			name:    "empty pattern",
+			values:  []string{"gcp:"},
+			wantErr: true,
+		},
+		{
+			name:    "bad pattern",
+			values:  []string{"gcp:["},
+			wantErr: true,
+		},
+		{
+			name:    "azure without tenant",
+			values:  []string{"azure:object"},
+			wantErr: true,
+		},
+		{
+			name:    "azure wildcard tenant",
+			values:  []string{"azure:*/object"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(rules) != len(tt.values) {
+				t.Errorf("ParseRules() returned %d rules, want %d", len(rules), len(tt.values))
+			}
+		})
+	}
+}
+
+func Test_UnitRuleMatches(t *testing.T) {
+	rules, err := ParseRules([]string{"aws:arn:aws:sts::123456789012:assumed-role/agent/*", "azure:tenant/object"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		provider string
+		identity string
+		want     bool
+	}{
+		{provider: AWS, identity: "arn:aws:sts::123456789012:assumed-role/agent/i-0123456789abcdef0", want: true},
+		{provider: AWS, identity: "arn:aws:sts::123456789012:assumed-role/admin/i-0123456789abcdef0"},
+		{provider: AWS, identity: "arn:aws:sts::210987654321:assumed-role/agent/i-0123456789abcdef0"},
+		{provider: GCP, identity: "arn:aws:sts::123456789012:assumed-role/agent/i-0123456789abcdef0"},
+		{provider: Azure, identity: "tenant/object", want: true},
+		{provider: Azure, identity: "other/object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+":"+tt.identity, func(t *testing.T) {
+			var got bool
+			for _, rule := range rules {
+				got = got || rule.Matches(tt.provider, tt.identity)
+			}
+			if got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitParsePassword(t *testing.T) {
+	tests := []struct {
+		password       string
+		wantProvider   string
+		wantCredential string
+		wantOK         bool
+	}{
+		{password: FormatPassword(GCP, "a.b.c"), wantProvider: GCP, wantCredential: "a.b.c", wantOK: true},
+		{password: FormatPassword(AWS, "e30"), wantProvider: AWS, wantCredential: "e30", wantOK: true},
+		{password: "cloud-identity:ibm:a.b.c"},
+		{password: "cloud-identity:gcp:"},
+		{password: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			provider, credential, ok := ParsePassword(tt.password)
+			if provider != tt.wantProvider || credential != tt.wantCredential || ok != tt.wantOK {
+				t.Errorf("ParsePassword() = %q, %q, %v, want %q, %q, %v", provider, credential, ok, tt.wantProvider, tt.wantCredential, tt.wantOK)
+			}
+		})
+	}
+}
+
+func Test_UnitNewAudience(t *testing.T) {
+	a, err := New([]string{"gcp:*@project.iam.gserviceaccount.com"}, "", "cluster-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.audience != DefaultAudience("cluster-hash") {
+		t.Errorf("New() audience = %q, want %q", a.audience, DefaultAudience("cluster-hash"))
+	}
+	if _, err := New([]string{"azure:tenant/*"}, "", "cluster-hash"); err == nil {
+		t.Errorf("New() with azure rule and no audience did not return an error")
+	}
+	if _, err := New([]string{"gcp:*@project.iam.gserviceaccount.com"}, "", ""); err == nil {
+		t.Errorf("New() with no cluster ID did not return an error")
+	}
+}
+
+func Test_UnitVerifyClusterID(t *testing.T) {
+	signed := "AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/sts/aws4_request, SignedHeaders=content-type;host;x-amz-date;" +
+		strings.ToLower(clusterIDHeader) + ", Signature=abcdef"
+	unsigned := "AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/sts/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=abcdef"
+	tests := []struct {
+		name    string
+		headers http.Header
+		wantErr bool
+	}{
+		{
+			name:    "bound to this cluster",
+			headers: http.Header{"Authorization": {signed}, clusterIDHeader: {"cluster-hash"}},
+		},
+		{
+			name:    "bound to another cluster",
+			headers: http.Header{"Authorization": {signed}, clusterIDHeader: {"other-hash"}},
+			wantErr: true,
+		},
+		{
+			name:    "not bound",
+			headers: http.Header{"Authorization": {unsigned}},
+			wantErr: true,
+		},
+		{
+			name:    "header not signed",
+			headers: http.Header{"Authorization": {unsigned}, clusterIDHeader: {"cluster-hash"}},
+			wantErr: true,
+		},
+	}
+	a, err := New([]string{"aws:*"}, "", "cluster-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.verifyClusterID(tt.headers); (err != nil) != tt.wantErr {
+				t.Errorf("verifyClusterID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package cloudidentity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+const (
+	stsURL              = "https://sts.amazonaws.com/"
+	stsRegion           = "us-east-1"
+	getCallerIdentity   = "Action=GetCallerIdentity&Version=2011-06-15"
+	gcpIdentityURL      = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+	azureTokenURL       = "http://169.254.169.254/metadata/identity/oauth2/token"
+	metadataTimeout     = 10 * time.Second
+	maxMetadataBodySize = 64 * 1024
+)
+
+// clusterIDHeader is the signed header that binds an AWS credential to a cluster. STS ignores
+// the header, but rejects the request if the header does not match the signature.
+var clusterIDHeader = http.CanonicalHeaderKey("X-" + version.Program + "-Cluster-Id")
+
+// stsRequest is a signed AWS STS GetCallerIdentity request. The request is sent by the server
+// to STS, which returns the identity of the instance that signed it.
+type stsRequest struct {
+	URL     string      `json:"url"`
+	Body    string      `json:"body"`
+	Headers http.Header `json:"headers"`
+}
+
+// Password returns a join password containing a short-lived credential for the cloud identity of this instance,
+// retrieved from the instance metadata service. The credential is bound to the cluster identified by the cluster
+// ID, which is the CA hash from the token, so that it cannot be used to join any other cluster. For AWS, the
+// cluster ID is included in a signed header. For GCP and Azure, the audience is the audience of the identity
+// token, and must match the audience configured on the server; if not set, the GCP audience is derived from the
+// cluster ID. Azure tokens can only be requested for the application ID URI of an app registration, so the
+// audience must be set, and should be unique to the cluster.
+func Password(ctx context.Context, provider, audience, clusterID string) (string, error) {
+	if clusterID == "" {
+		return "", errors.New("a token containing the cluster CA hash is required to join with a cloud identity")
+	}
+	if audience == "" {
+		if provider == Azure {
+			return "", errors.New("azure cloud identity requires --cloud-identity-audience to be set to the application ID URI of an app registration")
+		}
+		audience = DefaultAudience(clusterID)
+	}
+	var credential string
+	var err error
+	switch provider {
+	case AWS:
+		credential, err = awsCredential(clusterID)
+	case GCP:
+		credential, err = gcpCredential(ctx, audience)
+	case Azure:
+		credential, err = azureCredential(ctx, audience)
+	default:
+		err = validProvider(provider)
+	}
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed to get %s cloud identity credential", provider)
+	}
+	return FormatPassword(provider, credential), nil
+}
+
+// DefaultAudience returns the audience used for identity tokens when none is configured, derived from the cluster ID.
+func DefaultAudience(clusterID string) string {
+	return version.Program + ":" + clusterID
+}
+
+// awsCredential signs a GetCallerIdentity request with the instance profile credentials. The cluster ID
+// header is included in the signature, so that the request cannot be replayed to a different cluster.
+// The signature is valid for 15 minutes.
+func awsCredential(clusterID string) (string, error) {
+	creds, err := credentials.NewIAM("").Get()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, stsURL, strings.NewReader(getCallerIdentity))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(getCallerIdentity))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(hash[:]))
+	req.Header.Set(clusterIDHeader, clusterID)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	req = signer.SignV4STS(*req, creds.AccessKeyID, creds.SecretAccessKey, stsRegion)
+
+	b, err := json.Marshal(stsRequest{URL: stsURL, Body: getCallerIdentity, Headers: req.Header})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// gcpCredential retrieves an identity token for the instance's default service account.
+func gcpCredential(ctx context.Context, audience string) (string, error) {
+	u := gcpIdentityURL + "?format=full&audience=" + url.QueryEscape(audience)
+	b, err := getMetadata(ctx, u, "Metadata-Flavor", "Google")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// azureCredential retrieves an access token for the instance's managed identity.
+func azureCredential(ctx context.Context, audience string) (string, error) {
+	u := azureTokenURL + "?api-version=2018-02-01&resource=" + url.QueryEscape(audience)
+	b, err := getMetadata(ctx, u, "Metadata", "true")
+	if err != nil {
+		return "", err
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(b, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("metadata service did not return an access token")
+	}
+	return token.AccessToken, nil
+}
+
+func getMetadata(ctx context.Context, u, header, value string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(header, value)
+
+	// The metadata service is link-local, and must not be reached through a proxy
+	client := &http.Client{Timeout: metadataTimeout, Transport: &http.Transport{}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return b, nil
+}
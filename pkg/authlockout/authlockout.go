@@ -0,0 +1,206 @@
+// Package authlockout rate limits failed authentication attempts against the supervisor. Clients that fail to
+// authenticate too many times within a window are temporarily locked out, so that the join token and node
+// passwords cannot be brute-forced by repeatedly hammering the supervisor. Clients are tracked by address,
+// so many clients behind a single NAT address share a failure count; requests that authenticate successfully
+// are never rejected, so that a locked out address does not prevent legitimate clients from joining.
+package authlockout
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	ReasonToken        = "token"
+	ReasonNodePassword = "node-password"
+
+	// cleanupInterval is the interval at which expired client records are removed.
+	cleanupInterval = 30 * time.Second
+)
+
+var (
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: version.Program + "_supervisor_auth_failures_total",
+		Help: "Total number of failed authentication attempts against the supervisor.",
+	}, []string{"reason"})
+	authLockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: version.Program + "_supervisor_auth_lockouts_total",
+		Help: "Total number of times a client has been locked out of the supervisor due to repeated authentication failures.",
+	})
+	authRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: version.Program + "_supervisor_auth_rejected_total",
+		Help: "Total number of requests rejected because the client is locked out of the supervisor.",
+	})
+	lockedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: version.Program + "_supervisor_auth_locked_clients",
+		Help: "Number of clients currently locked out of the supervisor.",
+	})
+)
+
+// client tracks authentication failures from a single client address. Failures are counted
+// within a fixed window that starts at the first failure.
+type client struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// Tracker tracks authentication failures by client address. A nil Tracker does not track failures,
+// and never locks out clients.
+type Tracker struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	duration time.Duration
+	clients  map[string]*client
+	now      func() time.Time
+}
+
+// New returns a Tracker that locks out clients that fail to authenticate limit times within the window,
+// for the given duration. Requests from loopback addresses are never locked out.
+func New(limit int, window, duration time.Duration) *Tracker {
+	return &Tracker{
+		limit:    limit,
+		window:   window,
+		duration: duration,
+		clients:  map[string]*client{},
+		now:      time.Now,
+	}
+}
+
+// MustRegister registers supervisor authentication failure metrics
+func MustRegister(registerer prometheus.Registerer) {
+	registerer.MustRegister(authFailures, authLockouts, authRejected, lockedClients)
+}
+
+// Start removes expired client records at regular intervals, until the context is cancelled.
+func (t *Tracker) Start(ctx context.Context) {
+	logrus.Infof("Locking out supervisor clients for %s after %d authentication failures within %s", t.duration, t.limit, t.window)
+	go wait.Until(t.cleanup, cleanupInterval, ctx.Done())
+}
+
+// Check returns the time remaining until the request's client is allowed to authenticate again,
+// along with a bool indicating if the client is currently locked out. Check should only be called
+// for requests that have failed to authenticate.
+func (t *Tracker) Check(req *http.Request) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	ip, ok := clientIP(req)
+	if !ok {
+		return 0, false
+	}
+	remaining, locked := t.check(ip)
+	if locked {
+		authRejected.Inc()
+	}
+	return remaining, locked
+}
+
+// Failure records a failed authentication attempt by the request's client. The client
+// is locked out if it has reached the failure limit within the window.
+func (t *Tracker) Failure(req *http.Request, reason string) {
+	if t == nil {
+		return
+	}
+	ip, ok := clientIP(req)
+	if !ok {
+		return
+	}
+	t.failure(ip, reason)
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// clientIP returns the address of the request's client, along with a bool indicating if failures from the client
+// should be tracked. Loopback clients are not tracked, so that local components are never locked out.
+func clientIP(req *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+func (t *Tracker) check(ip string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.limit <= 0 {
+		return 0, false
+	}
+	c, ok := t.clients[ip]
+	if !ok {
+		return 0, false
+	}
+	if remaining := c.lockedUntil.Sub(t.now()); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// failure records a failed authentication attempt, and returns true if the client has just been locked out.
+func (t *Tracker) failure(ip, reason string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.limit <= 0 {
+		return false
+	}
+	now := t.now()
+	c, ok := t.clients[ip]
+	if !ok {
+		c = &client{}
+		t.clients[ip] = c
+	}
+	if now.Before(c.lockedUntil) {
+		return false
+	}
+	if now.Sub(c.windowStart) >= t.window {
+		c.failures = 0
+		c.windowStart = now
+	}
+	c.failures++
+	if c.failures < t.limit {
+		return false
+	}
+	c.failures = 0
+	c.lockedUntil = now.Add(t.duration)
+	authLockouts.Inc()
+	lockedClients.Set(float64(t.locked(now)))
+	logrus.Warnf("Locking out %s from the supervisor for %s after %d %s authentication failures within %s", ip, t.duration, t.limit, reason, t.window)
+	return true
+}
+
+// cleanup removes clients that are not locked out, and whose failure window has expired.
+func (t *Tracker) cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for ip, c := range t.clients {
+		if now.After(c.lockedUntil) && now.Sub(c.windowStart) >= t.window {
+			delete(t.clients, ip)
+		}
+	}
+	lockedClients.Set(float64(t.locked(now)))
+}
+
+// locked returns the number of clients that are currently locked out. The caller must hold the lock.
+func (t *Tracker) locked(now time.Time) int {
+	count := 0
+	for _, c := range t.clients {
+		if now.Before(c.lockedUntil) {
+			count++
+		}
+	}
+	return count
+}
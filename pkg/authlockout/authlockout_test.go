@@ -0,0 +1,81 @@
+package authlockout
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_UnitTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := New(3, time.Minute, 5*time.Minute)
+	tr.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if tr.failure("10.0.0.1", ReasonToken) {
+			t.Fatalf("failure() locked out client after %d failures", i+1)
+		}
+	}
+	if _, locked := tr.check("10.0.0.1"); locked {
+		t.Fatal("check() = locked before reaching limit")
+	}
+
+	// failures outside the window are not counted
+	now = now.Add(time.Minute)
+	if tr.failure("10.0.0.1", ReasonToken) {
+		t.Fatal("failure() locked out client with failures outside window")
+	}
+	if tr.failure("10.0.0.1", ReasonNodePassword) {
+		t.Fatal("failure() locked out client before reaching limit")
+	}
+	if !tr.failure("10.0.0.1", ReasonToken) {
+		t.Fatal("failure() did not lock out client on reaching limit")
+	}
+	if remaining, locked := tr.check("10.0.0.1"); !locked || remaining != 5*time.Minute {
+		t.Fatalf("check() = %s, %v; want %s, true", remaining, locked, 5*time.Minute)
+	}
+	if _, locked := tr.check("10.0.0.2"); locked {
+		t.Fatal("check() = locked for other client")
+	}
+
+	// client is allowed again once the lockout expires, and is removed by cleanup
+	now = now.Add(5 * time.Minute)
+	if _, locked := tr.check("10.0.0.1"); locked {
+		t.Fatal("check() = locked after lockout expired")
+	}
+	tr.cleanup()
+	if len(tr.clients) != 0 {
+		t.Fatalf("cleanup() left %d clients, want 0", len(tr.clients))
+	}
+
+	// tracking is disabled when the limit is 0
+	tr.limit = 0
+	for i := 0; i < 5; i++ {
+		tr.failure("10.0.0.1", ReasonToken)
+	}
+	if _, locked := tr.check("10.0.0.1"); locked {
+		t.Fatal("check() = locked with tracking disabled")
+	}
+}
+
+func Test_UnitClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+		wantOK     bool
+	}{
+		{remoteAddr: "10.0.0.1:45678", want: "10.0.0.1", wantOK: true},
+		{remoteAddr: "[fd00::1]:45678", want: "fd00::1", wantOK: true},
+		{remoteAddr: "127.0.0.1:45678"},
+		{remoteAddr: "[::1]:45678"},
+		{remoteAddr: "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			got, ok := clientIP(&http.Request{RemoteAddr: tt.remoteAddr})
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("clientIP() = %s, %v; want %s, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
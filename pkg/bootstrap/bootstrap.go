@@ -20,7 +20,7 @@ func ReadFromDisk(w io.Writer, bootstrap *config.ControlRuntimeBootstrap) error
 		return nil
 	}
 
-	dataMap := make(map[string]File)
+	dataMap := make(PathsDataformat)
 	for pathKey, path := range paths {
 		if path == "" {
 			continue
@@ -42,6 +42,9 @@ func ReadFromDisk(w io.Writer, bootstrap *config.ControlRuntimeBootstrap) error
 		}
 	}
 
+	// files on disk are always in the current schema, as they are migrated when reconciled.
+	dataMap.SetVersion(CurrentVersion())
+
 	return json.NewEncoder(w).Encode(dataMap)
 }
 
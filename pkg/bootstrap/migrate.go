@@ -0,0 +1,91 @@
+package bootstrap
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// VersionKey is the key under which the schema version of the bootstrap data is stored. The version is
+// stored as a File so that servers that predate schema versioning can still decode the data; they ignore
+// the key, as they do any other key that they do not have a path for.
+const VersionKey = "SchemaVersion"
+
+// Migration converts bootstrap data written with the previous schema version to Version.
+// Migrations that change the content of a file must also update its Timestamp, so that
+// the change is written to disk on all servers when the data is reconciled.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(files PathsDataformat) error
+}
+
+// Migrations lists all bootstrap data schema migrations, in order. Any change to the set of
+// files in the bootstrap data, or to their format, must be made by adding a migration here,
+// instead of handling data written by older versions when it is reconciled.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "record schema version",
+		Migrate:     func(PathsDataformat) error { return nil },
+	},
+}
+
+// CurrentVersion returns the schema version of bootstrap data written by this version.
+func CurrentVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// Version returns the schema version of the bootstrap data. Data without a version was
+// written before schema versioning was introduced, and is version 0.
+func (p PathsDataformat) Version() (int, error) {
+	file, ok := p[VersionKey]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(file.Content))
+	if err != nil {
+		return 0, errors.WithMessage(err, "invalid bootstrap data schema version")
+	}
+	return version, nil
+}
+
+// SetVersion sets the schema version of the bootstrap data.
+func (p PathsDataformat) SetVersion(version int) {
+	p[VersionKey] = File{
+		Timestamp: time.Now(),
+		Content:   []byte(strconv.Itoa(version)),
+	}
+}
+
+// Migrate applies any migrations needed to bring the bootstrap data up to the current schema
+// version, returning true if the data was migrated and should be saved back to the datastore.
+// Data written by a newer version is left as-is.
+func Migrate(files PathsDataformat) (bool, error) {
+	version, err := files.Version()
+	if err != nil {
+		return false, err
+	}
+	if version > CurrentVersion() {
+		logrus.Warnf("Bootstrap data schema version %d is newer than the latest supported version %d; unrecognized files will be ignored", version, CurrentVersion())
+		return false, nil
+	}
+	if version == CurrentVersion() {
+		return false, nil
+	}
+
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+		logrus.Infof("Migrating bootstrap data to schema version %d: %s", m.Version, m.Description)
+		if err := m.Migrate(files); err != nil {
+			return false, errors.WithMessagef(err, "failed to migrate bootstrap data to schema version %d", m.Version)
+		}
+		version = m.Version
+	}
+	files.SetVersion(version)
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package bootstrap
+
+import (
+	"testing"
+)
+
+func Test_UnitMigrate(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        PathsDataformat
+		wantMigrated bool
+		wantVersion  int
+		wantErr      bool
+	}{
+		{
+			name:         "unversioned data",
+			files:        PathsDataformat{"ServerCA": File{Content: []byte("ca")}},
+			wantMigrated: true,
+			wantVersion:  CurrentVersion(),
+		},
+		{
+			name:         "current version",
+			files:        PathsDataformat{VersionKey: File{Content: []byte("1")}},
+			wantMigrated: false,
+			wantVersion:  CurrentVersion(),
+		},
+		{
+			name:         "newer version",
+			files:        PathsDataformat{VersionKey: File{Content: []byte("1000")}},
+			wantMigrated: false,
+			wantVersion:  1000,
+		},
+		{
+			name:    "invalid version",
+			files:   PathsDataformat{VersionKey: File{Content: []byte("one")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, err := Migrate(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if migrated != tt.wantMigrated {
+				t.Errorf("Migrate() = %v, want %v", migrated, tt.wantMigrated)
+			}
+			if version, _ := tt.files.Version(); version != tt.wantVersion {
+				t.Errorf("Migrate() version = %d, want %d", version, tt.wantVersion)
+			}
+		})
+	}
+}
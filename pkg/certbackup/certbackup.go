@@ -0,0 +1,191 @@
+package certbackup
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Dir is the directory within the data-dir that backups are stored in. Each backup is stored in a
+	// subdirectory named for the unix timestamp at which it was taken, and contains the backed up files
+	// at their path relative to the data-dir.
+	Dir = "tls-backups"
+	// Retention is the number of backups to retain; older backups are removed when a new backup is saved.
+	Retention = 5
+)
+
+var ErrNoBackup = errors.New("no certificate backups found")
+
+type file struct {
+	data []byte
+	mode fs.FileMode
+}
+
+// Snapshot holds the contents of credential files, as they were when the snapshot was taken.
+type Snapshot struct {
+	dataDir string
+	files   map[string]file
+}
+
+// Take reads the current contents of the given files, and all files within the given directories.
+// Paths must be within the data-dir. Paths that do not exist are ignored.
+func Take(dataDir string, paths ...string) (*Snapshot, error) {
+	s := &Snapshot{dataDir: dataDir, files: map[string]file{}}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			name, err := filepath.Rel(dataDir, path)
+			if err != nil || !filepath.IsLocal(name) {
+				return errors.New(path + " is not within " + dataDir)
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			s.files[name] = file{data: data, mode: info.Mode().Perm()}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.WithMessagef(err, "failed to read %s", path)
+		}
+	}
+	return s, nil
+}
+
+// Save backs up the snapshotted contents of any files that have since been modified or removed. If any files
+// were backed up, the path to the backup is returned, and old backups beyond the retention count are removed.
+func (s *Snapshot) Save() (string, error) {
+	changed := map[string]file{}
+	for name, f := range s.files {
+		data, err := os.ReadFile(filepath.Join(s.dataDir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if err != nil || !bytes.Equal(data, f.data) {
+			changed[name] = f
+		}
+	}
+	if len(changed) == 0 {
+		return "", nil
+	}
+
+	backupDir := filepath.Join(s.dataDir, Dir, strconv.FormatInt(time.Now().Unix(), 10))
+	for name, f := range changed {
+		path := filepath.Join(backupDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		if err := util.AtomicWrite(path, f.data, f.mode); err != nil {
+			return "", err
+		}
+	}
+	logrus.Infof("Backed up %d modified certificate and kubeconfig files to %s", len(changed), backupDir)
+
+	if err := prune(s.dataDir); err != nil {
+		logrus.Warnf("Failed to remove old certificate backups: %v", err)
+	}
+	return backupDir, nil
+}
+
+// Restore restores all files from the most recent backup to their original location within the data-dir,
+// returning the path to the backup that was restored.
+func Restore(dataDir string) (string, error) {
+	backups, err := list(dataDir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", ErrNoBackup
+	}
+	backupDir := filepath.Join(dataDir, Dir, backups[len(backups)-1])
+
+	err = filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(backupDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		logrus.Debugf("Restoring %s from %s", dest, backupDir)
+		return util.AtomicWrite(dest, data, info.Mode().Perm())
+	})
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed to restore certificate backup %s", backupDir)
+	}
+	return backupDir, nil
+}
+
+// list returns the names of all backups within the data-dir, sorted from oldest to newest.
+func list(dataDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, Dir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	timestamps := []int64{}
+	for _, entry := range entries {
+		if ts, err := strconv.ParseInt(entry.Name(), 10, 64); err == nil && entry.IsDir() {
+			timestamps = append(timestamps, ts)
+		}
+	}
+	slices.Sort(timestamps)
+	backups := make([]string, len(timestamps))
+	for i, ts := range timestamps {
+		backups[i] = strconv.FormatInt(ts, 10)
+	}
+	return backups, nil
+}
+
+// prune removes the oldest backups beyond the retention count.
+func prune(dataDir string) error {
+	backups, err := list(dataDir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(backups) > Retention {
+		backupDir := filepath.Join(dataDir, Dir, backups[0])
+		logrus.Infof("Removing old certificate backup %s", backupDir)
+		if err := os.RemoveAll(backupDir); err != nil {
+			errs = append(errs, err)
+		}
+		backups = backups[1:]
+	}
+	return errors.Join(errs...)
+}
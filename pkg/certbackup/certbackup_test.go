@@ -0,0 +1,101 @@
+package certbackup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_UnitSnapshotSaveRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	files := map[string]string{
+		"server/tls/client-ca.crt":     "ca",
+		"server/tls/client-admin.crt":  "admin",
+		"server/cred/admin.kubeconfig": "kubeconfig",
+	}
+	for name, data := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot, err := Take(dataDir, filepath.Join(dataDir, "server", "tls"), filepath.Join(dataDir, "server", "cred"), filepath.Join(dataDir, "agent"))
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	// no changes should not create a backup
+	if backupDir, err := snapshot.Save(); err != nil || backupDir != "" {
+		t.Fatalf("Save() = %q, %v, want no backup", backupDir, err)
+	}
+
+	// modify one file and remove another
+	if err := os.WriteFile(filepath.Join(dataDir, "server/tls/client-admin.crt"), []byte("new-admin"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dataDir, "server/cred/admin.kubeconfig")); err != nil {
+		t.Fatal(err)
+	}
+	backupDir, err := snapshot.Save()
+	if err != nil || backupDir == "" {
+		t.Fatalf("Save() = %q, %v, want backup", backupDir, err)
+	}
+	got := map[string]string{}
+	filepath.WalkDir(backupDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			name, _ := filepath.Rel(backupDir, path)
+			data, _ := os.ReadFile(path)
+			got[filepath.ToSlash(name)] = string(data)
+		}
+		return err
+	})
+	want := map[string]string{
+		"server/tls/client-admin.crt":  "admin",
+		"server/cred/admin.kubeconfig": "kubeconfig",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Save() backed up %v, want %v", got, want)
+	}
+
+	restored, err := Restore(dataDir)
+	if err != nil || restored != backupDir {
+		t.Fatalf("Restore() = %q, %v, want %q", restored, err, backupDir)
+	}
+	for name, data := range files {
+		if b, err := os.ReadFile(filepath.Join(dataDir, name)); err != nil || string(b) != data {
+			t.Errorf("Restore() %s = %q, %v, want %q", name, b, err, data)
+		}
+	}
+}
+
+func Test_UnitPrune(t *testing.T) {
+	dataDir := t.TempDir()
+	for i := 1; i <= Retention+2; i++ {
+		if err := os.MkdirAll(filepath.Join(dataDir, Dir, strconv.Itoa(i*100)), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := prune(dataDir); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	got, err := list(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"300", "400", "500", "600", "700"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("list() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitRestoreNoBackup(t *testing.T) {
+	if _, err := Restore(t.TempDir()); err != ErrNoBackup {
+		t.Errorf("Restore() error = %v, want %v", err, ErrNoBackup)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/loadshed"
 	"github.com/k3s-io/k3s/pkg/metrics"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/services"
@@ -87,6 +88,7 @@ func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string) e
 	go wait.Until(func() {
 		// don't check and create events until after the apiserver is up, otherwise the events may be lost.
 		<-executor.APIServerReadyChan()
+		loadshed.Wait(ctx, controllerName+" certificate expiration check")
 
 		logrus.Debugf("Running %s certificate expiration check", controllerName)
 		var hasErr bool
@@ -0,0 +1,274 @@
+// Package checkconfig verifies that the host meets the requirements for running k3s: kernel configuration and
+// modules, cgroup controllers, iptables mode, overlayfs support, swap, firewall, and conflicting container runtimes.
+// It replaces the check-config shell script, and can produce JSON output for use in automation.
+package checkconfig
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+const (
+	SectionBinaries = "Verifying binaries"
+	SectionSystem   = "System"
+	SectionFirewall = "Firewall"
+	SectionLimits   = "Limits"
+	SectionRuntimes = "Container runtimes"
+	SectionModules  = "Kernel modules"
+	SectionRequired = "Generally Necessary"
+	SectionOptional = "Optional Features"
+)
+
+// Result is the result of a single check.
+type Result struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report is the result of all checks. The host meets the requirements if there are no failures;
+// warnings indicate optional features that are unavailable, or configuration that may cause problems.
+type Report struct {
+	KernelConfig string   `json:"kernelConfig,omitempty"`
+	Results      []Result `json:"results"`
+	Failures     int      `json:"failures"`
+	Warnings     int      `json:"warnings"`
+}
+
+// Options control where checks look for k3s binaries and the kernel config.
+type Options struct {
+	// BinDir is the directory containing the k3s binaries, which are verified against their checksums and links.
+	BinDir string
+	// KernelConfig is the path to the kernel config. If empty, common locations are searched.
+	KernelConfig string
+}
+
+func (r *Report) add(section, name string, status Status, format string, args ...any) {
+	r.Results = append(r.Results, Result{Section: section, Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	switch status {
+	case StatusFail:
+		r.Failures++
+	case StatusWarn:
+		r.Warnings++
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report grouped by section, in the format used by the check-config script.
+// Colors are used unless color is false.
+func (r *Report) WriteText(w io.Writer, color bool) {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return "\033[" + code + "m" + text + "\033[0m"
+	}
+
+	section := ""
+	for _, res := range r.Results {
+		if res.Section != section {
+			section = res.Section
+			fmt.Fprintf(w, "\n%s:\n", section)
+		}
+		switch res.Status {
+		case StatusPass:
+			fmt.Fprintf(w, "- %s: %s\n", paint("37", res.Name), paint("32", res.Message))
+		case StatusWarn:
+			fmt.Fprintf(w, "- %s: %s\n", paint("1", res.Name), paint("1;33", res.Message))
+		case StatusFail:
+			fmt.Fprintf(w, "- %s: %s\n", paint("1", res.Name), paint("1;31", res.Message+" (fail)"))
+		}
+	}
+
+	fmt.Fprintln(w)
+	if r.Failures == 0 {
+		fmt.Fprintf(w, "%s: %s\n", paint("37", "STATUS"), paint("32", "pass"))
+	} else {
+		fmt.Fprintf(w, "%s: %s\n", paint("1", "STATUS"), paint("1;31", strconv.Itoa(r.Failures)+" (fail)"))
+	}
+}
+
+// kernelConfig holds the values of kernel config options, without the CONFIG_ prefix.
+type kernelConfig map[string]string
+
+// readKernelConfig reads a kernel config file, which may be gzip compressed.
+func readKernelConfig(path string) (kernelConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKernelConfig(b)
+}
+
+func parseKernelConfig(b []byte) (kernelConfig, error) {
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		if b, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	config := kernelConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || !strings.HasPrefix(key, "CONFIG_") {
+			continue
+		}
+		config[strings.TrimPrefix(key, "CONFIG_")] = value
+	}
+	return config, scanner.Err()
+}
+
+// check returns the status and message for a kernel config option. Options that are not set
+// result in the given status for missing options.
+func (c kernelConfig) check(option string, missing Status) (Status, string) {
+	switch c[option] {
+	case "y":
+		return StatusPass, "enabled"
+	case "m":
+		return StatusPass, "enabled (as module)"
+	default:
+		return missing, "missing"
+	}
+}
+
+// isSet returns true if the option is built in, or available as a module.
+func (c kernelConfig) isSet(option string) bool {
+	return c[option] == "y" || c[option] == "m"
+}
+
+// parseVersion parses a version such as v1.8.7 or 5.15.0-91-generic into its numeric components.
+// Parsing stops at the first component that is not a number.
+func parseVersion(version string) []int {
+	var parts []int
+	for _, s := range strings.Split(strings.TrimPrefix(version, "v"), ".") {
+		end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if end == 0 {
+			break
+		}
+		if end > 0 {
+			s = s[:end]
+		}
+		n, _ := strconv.Atoi(s)
+		parts = append(parts, n)
+		if end > 0 {
+			break
+		}
+	}
+	return parts
+}
+
+// versionLess returns true if version a is less than version b.
+func versionLess(a, b []int) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// parseIPTablesVersion parses the output of iptables --version, such as "iptables v1.8.7 (nf_tables)",
+// and returns the version and mode. The mode is empty for versions older than 1.8, which do not report it.
+func parseIPTablesVersion(output string) (string, string, bool) {
+	fields := strings.Fields(output)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "v") || len(parseVersion(fields[1])) == 0 {
+		return "", "", false
+	}
+	mode := ""
+	if len(fields) > 2 {
+		mode = strings.Trim(fields[2], "()")
+	}
+	return fields[1], mode, true
+}
+
+// parseSwaps returns the swap devices listed in /proc/swaps.
+func parseSwaps(swaps string) []string {
+	var devices []string
+	for i, line := range strings.Split(swaps, "\n") {
+		if fields := strings.Fields(line); i > 0 && len(fields) > 0 {
+			devices = append(devices, fields[0])
+		}
+	}
+	return devices
+}
+
+// parseRouteConflicts returns the interfaces listed in /proc/net/route that have routes to the default cluster
+// and service CIDRs, 10.42.0.0/16 and 10.43.0.0/16. Routes via cni0 are ignored, as they are created by k3s.
+func parseRouteConflicts(routes string) []string {
+	var ifaces []string
+	for i, line := range strings.Split(routes, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 || fields[0] == "cni0" {
+			continue
+		}
+		// the destination is a little-endian hex encoded IPv4 address
+		dest, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		if byte(dest) == 10 && (byte(dest>>8) == 42 || byte(dest>>8) == 43) && !slices.Contains(ifaces, fields[0]) {
+			ifaces = append(ifaces, fields[0])
+		}
+	}
+	return ifaces
+}
+
+// parseCgroupV1Controllers returns the controllers listed in /proc/self/cgroup.
+func parseCgroupV1Controllers(cgroups string) []string {
+	var controllers []string
+	for _, line := range strings.Split(cgroups, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || fields[1] == "" {
+			continue
+		}
+		controllers = append(controllers, strings.Split(fields[1], ",")...)
+	}
+	return controllers
+}
+
+// missingControllers returns the required controllers that are not present.
+func missingControllers(required, controllers []string) []string {
+	var missing []string
+	for _, controller := range required {
+		if !slices.Contains(controllers, controller) {
+			missing = append(missing, controller)
+		}
+	}
+	return missing
+}
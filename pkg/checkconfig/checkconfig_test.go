@@ -0,0 +1,108 @@
+package checkconfig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+func Test_UnitParseKernelConfig(t *testing.T) {
+	raw := []byte("# comment\nCONFIG_NAMESPACES=y\nCONFIG_VXLAN=m\n# CONFIG_USER_NS is not set\nCONFIG_LOCALVERSION=\"\"\n")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(raw)
+	zw.Close()
+
+	for name, b := range map[string][]byte{"plain": raw, "gzip": gz.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			config, err := parseKernelConfig(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status, message := config.check("NAMESPACES", StatusFail); status != StatusPass || message != "enabled" {
+				t.Errorf("check(NAMESPACES) = %s %s, want pass enabled", status, message)
+			}
+			if status, message := config.check("VXLAN", StatusFail); status != StatusPass || message != "enabled (as module)" {
+				t.Errorf("check(VXLAN) = %s %s, want pass enabled (as module)", status, message)
+			}
+			if status, _ := config.check("USER_NS", StatusWarn); status != StatusWarn {
+				t.Errorf("check(USER_NS) = %s, want warn", status)
+			}
+			if config.isSet("LOCALVERSION") {
+				t.Errorf("isSet(LOCALVERSION) = true, want false")
+			}
+		})
+	}
+}
+
+func Test_UnitVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.6.2", "v1.8.0", true},
+		{"v1.8.0", "v1.8.0", false},
+		{"v1.8.3", "v1.8.4", true},
+		{"v1.8.10", "v1.8.4", false},
+		{"5.15.0-91-generic", "4.8", false},
+		{"4.4.302+", "4.8", true},
+		{"3.10.0-1160.el7.x86_64", "3.13", true},
+	}
+	for _, tt := range tests {
+		if got := versionLess(parseVersion(tt.a), parseVersion(tt.b)); got != tt.want {
+			t.Errorf("versionLess(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_UnitParseIPTablesVersion(t *testing.T) {
+	tests := []struct {
+		output, version, mode string
+		ok                    bool
+	}{
+		{"iptables v1.8.7 (nf_tables)", "v1.8.7", "nf_tables", true},
+		{"iptables v1.8.2 (legacy)", "v1.8.2", "legacy", true},
+		{"iptables v1.6.1", "v1.6.1", "", true},
+		{"iptables: command not found", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		version, mode, ok := parseIPTablesVersion(tt.output)
+		if version != tt.version || mode != tt.mode || ok != tt.ok {
+			t.Errorf("parseIPTablesVersion(%q) = %q, %q, %v, want %q, %q, %v", tt.output, version, mode, ok, tt.version, tt.mode, tt.ok)
+		}
+	}
+}
+
+func Test_UnitParseSwaps(t *testing.T) {
+	swaps := "Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n/dev/sda2                               partition\t2097148\t\t0\t\t-2\n"
+	if got, want := parseSwaps(swaps), []string{"/dev/sda2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSwaps() = %v, want %v", got, want)
+	}
+	if got := parseSwaps("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"); len(got) != 0 {
+		t.Errorf("parseSwaps() = %v, want none", got)
+	}
+}
+
+func Test_UnitParseRouteConflicts(t *testing.T) {
+	routes := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"cni0\t00002A0A\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+		"tun0\t00002B0A\t00000000\t0001\t0\t0\t0\t0000FFFF\t0\t0\t0\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+	if got, want := parseRouteConflicts(routes), []string{"tun0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRouteConflicts() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitCgroupControllers(t *testing.T) {
+	cgroups := "12:cpuset:/\n11:memory:/user.slice\n4:cpu,cpuacct:/\n1:name=systemd:/init.scope\n0::/init.scope\n"
+	controllers := parseCgroupV1Controllers(cgroups)
+	if missing := missingControllers([]string{"cpuset", "memory"}, controllers); len(missing) != 0 {
+		t.Errorf("missingControllers() = %v, want none", missing)
+	}
+	if got, want := missingControllers([]string{"cpu", "cpuset", "memory"}, []string{"cpu", "io", "pids"}), []string{"cpuset", "memory"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingControllers() = %v, want %v", got, want)
+	}
+}
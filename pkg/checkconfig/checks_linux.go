@@ -0,0 +1,552 @@
+//go:build linux
+
+package checkconfig
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// requiredFlags are kernel config options that must be enabled.
+	requiredFlags = []string{
+		"NAMESPACES", "NET_NS", "PID_NS", "IPC_NS", "UTS_NS",
+		"CGROUPS", "CGROUP_PIDS", "CGROUP_CPUACCT", "CGROUP_DEVICE", "CGROUP_FREEZER", "CGROUP_SCHED", "CPUSETS", "MEMCG",
+		"SECCOMP", "KEYS",
+		"VETH", "BRIDGE", "BRIDGE_NETFILTER",
+		"IP_NF_FILTER", "IP_NF_TARGET_MASQUERADE", "IP_NF_TARGET_REJECT",
+		"NETFILTER_XT_MATCH_ADDRTYPE", "NETFILTER_XT_MATCH_CONNTRACK", "NETFILTER_XT_MATCH_IPVS", "NETFILTER_XT_MATCH_COMMENT", "NETFILTER_XT_MATCH_MULTIPORT", "NETFILTER_XT_MATCH_STATISTIC",
+		"IP_NF_NAT", "NF_NAT",
+		"POSIX_MQUEUE",
+	}
+
+	// optionalFlags are kernel config options used by optional features.
+	optionalFlags = []string{
+		"BLK_CGROUP", "BLK_DEV_THROTTLING",
+		"CGROUP_PERF",
+		"CGROUP_HUGETLB",
+		"NET_CLS_CGROUP",
+		"CFS_BANDWIDTH", "FAIR_GROUP_SCHED", "RT_GROUP_SCHED",
+		"IP_NF_TARGET_REDIRECT",
+		"IP_SET",
+		"IP_VS", "IP_VS_NFCT", "IP_VS_PROTO_TCP", "IP_VS_PROTO_UDP", "IP_VS_RR",
+		"EXT4_FS", "EXT4_FS_POSIX_ACL", "EXT4_FS_SECURITY",
+		"VXLAN",
+		"CRYPTO", "CRYPTO_AEAD", "CRYPTO_GCM", "CRYPTO_SEQIV", "CRYPTO_GHASH",
+		"XFRM", "XFRM_USER", "XFRM_ALGO", "INET_ESP", "INET_XFRM_MODE_TRANSPORT",
+		"OVERLAY_FS",
+	}
+
+	// requiredModules are the kernel modules loaded by the agent at startup, and the config options that provide them.
+	requiredModules = []struct{ name, option string }{
+		{"overlay", "OVERLAY_FS"},
+		{"nf_conntrack", "NF_CONNTRACK"},
+		{"br_netfilter", "BRIDGE_NETFILTER"},
+		{"iptable_nat", "IP_NF_NAT"},
+		{"iptable_filter", "IP_NF_FILTER"},
+	}
+
+	// conflictingRuntimes are processes that conflict with the embedded container runtime or kubelet, and why.
+	conflictingRuntimes = []struct {
+		comm   string
+		status Status
+		reason string
+	}{
+		{"dockerd", StatusWarn, "running; containers managed by docker are not visible to k3s unless --docker is used"},
+		{"crio", StatusWarn, "running; CRI-O may conflict with the embedded containerd"},
+		{"containerd", StatusWarn, "running outside of k3s; use --container-runtime-endpoint to use it, or stop it to avoid conflicts"},
+		{"kubelet", StatusFail, "running outside of k3s; it will conflict with the embedded kubelet"},
+	}
+
+	firewallTCPPorts = []int{6443, 10250, 5001, 2379, 2380}
+	firewallUDPPorts = []int{8472, 51820, 51821}
+)
+
+// Run runs all checks, and returns a report of the results.
+func Run(opts Options) (*Report, error) {
+	r := &Report{}
+
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return nil, err
+	}
+	release := unix.ByteSliceToString(uts.Release[:])
+
+	if opts.BinDir != "" {
+		checkBinaries(r, opts.BinDir)
+	}
+
+	config, path := loadKernelConfig(opts.KernelConfig, release)
+	r.KernelConfig = path
+
+	checkIPTables(r, opts.BinDir)
+	checkSwap(r)
+	checkRoutes(r)
+	checkOverlayFS(r, config)
+	checkFirewall(r)
+	checkLimits(r)
+	checkRuntimes(r, opts.BinDir)
+	checkModules(r, config, release)
+
+	if config == nil {
+		if opts.KernelConfig != "" {
+			r.add(SectionRequired, "kernel config", StatusFail, "cannot read kernel config %s", opts.KernelConfig)
+		} else {
+			r.add(SectionRequired, "kernel config", StatusFail, "cannot find kernel config; specify it with --kernel-config")
+		}
+		return r, nil
+	}
+	checkKernelConfig(r, config, release)
+	return r, nil
+}
+
+// checkBinaries verifies the checksums and symlinks of the binaries in binDir.
+func checkBinaries(r *Report, binDir string) {
+	section := SectionBinaries + " in " + binDir
+
+	if sums, err := os.ReadFile(filepath.Join(binDir, ".sha256sums")); err == nil && len(sums) > 0 {
+		var mismatched []string
+		for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+			want, file, ok := strings.Cut(strings.TrimSpace(line), " ")
+			if !ok {
+				continue
+			}
+			file = strings.TrimLeft(file, " *")
+			if got, err := sha256File(filepath.Join(binDir, file)); err != nil || got != want {
+				mismatched = append(mismatched, file)
+			}
+		}
+		if len(mismatched) == 0 {
+			r.add(section, "sha256sum", StatusPass, "good")
+		} else {
+			r.add(section, "sha256sum", StatusFail, "does not match: %s", strings.Join(mismatched, ", "))
+		}
+	} else {
+		r.add(section, "sha256sum", StatusWarn, "sha256sums unavailable")
+	}
+
+	links, err := os.ReadFile(filepath.Join(binDir, ".links"))
+	if err != nil || len(links) == 0 {
+		r.add(section, "links", StatusWarn, "link list unavailable")
+		return
+	}
+	linkFail := false
+	for _, line := range strings.Split(strings.TrimSpace(string(links)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		file, link := fields[0], fields[1]
+		target, _ := os.Readlink(filepath.Join(binDir, file))
+		switch {
+		case target == link:
+		// If no iptables is installed on the host system, the symlink will be different
+		case target == "xtables-legacy-multi" || target == "xtables-nft-multi":
+			r.add(section, file, StatusWarn, "symlink to %s", target)
+		default:
+			r.add(section, file, StatusFail, "symlink to %s", link)
+			linkFail = true
+		}
+	}
+	if !linkFail {
+		r.add(section, "links", StatusPass, "good")
+	}
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadKernelConfig reads the kernel config from the given path, or from common locations if the path is empty.
+// The configs module is loaded if necessary to provide /proc/config.gz.
+func loadKernelConfig(path, release string) (kernelConfig, string) {
+	if path == "" {
+		path = os.Getenv("CONFIG")
+	}
+	if path != "" {
+		config, err := readKernelConfig(path)
+		if err != nil {
+			return nil, path
+		}
+		return config, path
+	}
+
+	if _, err := os.Stat("/proc/config.gz"); err != nil {
+		exec.Command("modprobe", "configs").Run()
+	}
+	possibleConfigs := []string{
+		"/proc/config.gz",
+		"/boot/config-" + release,
+		"/boot/config-" + release[strings.LastIndex(release, "-")+1:],
+		"/usr/src/linux-" + release + "/.config",
+		"/usr/src/linux/.config",
+	}
+	for _, path := range possibleConfigs {
+		if config, err := readKernelConfig(path); err == nil {
+			return config, path
+		}
+	}
+	return nil, ""
+}
+
+// systemIPTables returns the path to iptables, preferring the host's iptables over the one bundled in binDir.
+func systemIPTables(binDir string) string {
+	var bundled string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		path := filepath.Join(dir, "iptables")
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() || fi.Mode()&0111 == 0 {
+			continue
+		}
+		if binDir != "" && filepath.Clean(dir) == filepath.Clean(binDir) {
+			bundled = path
+			continue
+		}
+		return path
+	}
+	return bundled
+}
+
+// checkIPTables checks the iptables version and mode. Versions 1.8.0 through 1.8.3 have bugs in nf_tables mode.
+// Rules that are present in the legacy backend while iptables is in nf_tables mode are also reported,
+// as rules split across both backends will not be evaluated as expected.
+func checkIPTables(r *Report, binDir string) {
+	cmd := systemIPTables(binDir)
+	if cmd == "" {
+		r.add(SectionSystem, "iptables", StatusWarn, "not found")
+		return
+	}
+	out, _ := exec.Command(cmd, "--version").Output()
+	info := strings.TrimSpace(string(out))
+	version, mode, ok := parseIPTablesVersion(info)
+	if !ok {
+		r.add(SectionSystem, cmd, StatusWarn, "unknown version: %s", info)
+		return
+	}
+
+	label := filepath.Dir(cmd) + " " + info
+	v := parseVersion(version)
+	switch {
+	case versionLess(v, []int{1, 8, 0}):
+		r.add(SectionSystem, label, StatusPass, "older than v1.8")
+	case mode != "legacy" && versionLess(v, []int{1, 8, 4}):
+		r.add(SectionSystem, label, StatusFail, "should be older than v1.8.0, newer than v1.8.3, or in legacy mode")
+	default:
+		r.add(SectionSystem, label, StatusPass, "ok")
+	}
+
+	if mode == "nf_tables" {
+		if tables, err := os.ReadFile("/proc/net/ip_tables_names"); err == nil && len(strings.TrimSpace(string(tables))) > 0 {
+			r.add(SectionSystem, "iptables mode", StatusWarn, "nf_tables, but legacy iptables tables are also in use: %s", strings.Join(strings.Fields(string(tables)), ", "))
+			return
+		}
+	}
+	if mode != "" {
+		r.add(SectionSystem, "iptables mode", StatusPass, mode)
+	}
+}
+
+func checkSwap(r *Report) {
+	b, err := os.ReadFile("/proc/swaps")
+	if err != nil {
+		r.add(SectionSystem, "swap", StatusWarn, "unknown: %v", err)
+		return
+	}
+	if devices := parseSwaps(string(b)); len(devices) > 0 {
+		r.add(SectionSystem, "swap", StatusWarn, "should be disabled: %s", strings.Join(devices, ", "))
+	} else {
+		r.add(SectionSystem, "swap", StatusPass, "disabled")
+	}
+}
+
+func checkRoutes(r *Report) {
+	b, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		r.add(SectionSystem, "routes", StatusWarn, "unknown: %v", err)
+		return
+	}
+	if conflicts := parseRouteConflicts(string(b)); len(conflicts) > 0 {
+		r.add(SectionSystem, "routes", StatusWarn, "default CIDRs 10.42.0.0/16 or 10.43.0.0/16 already routed via %s", strings.Join(conflicts, ", "))
+	} else {
+		r.add(SectionSystem, "routes", StatusPass, "ok")
+	}
+}
+
+// checkOverlayFS checks that the overlay filesystem is available, as it is used by the default snapshotter.
+func checkOverlayFS(r *Report, config kernelConfig) {
+	if b, err := os.ReadFile("/proc/filesystems"); err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 && fields[len(fields)-1] == "overlay" {
+				r.add(SectionSystem, "overlayfs", StatusPass, "supported")
+				return
+			}
+		}
+	}
+	if config.isSet("OVERLAY_FS") {
+		r.add(SectionSystem, "overlayfs", StatusPass, "available as module")
+		return
+	}
+	r.add(SectionSystem, "overlayfs", StatusFail, "not supported; use --snapshotter=native or fuse-overlayfs")
+}
+
+// checkFirewall checks whether firewalld or ufw is active, and if so, whether the ports used by k3s are open.
+// Blocked TCP ports are failures; blocked UDP ports are only needed for flannel vxlan and wireguard.
+func checkFirewall(r *Report) {
+	var isBlocked func(port int, proto string) bool
+	if err := exec.Command("firewall-cmd", "--state").Run(); err == nil {
+		r.add(SectionFirewall, "firewalld", StatusWarn, "is enabled")
+		out, _ := exec.Command("firewall-cmd", "--list-ports").Output()
+		ports := strings.Fields(string(out))
+		isBlocked = func(port int, proto string) bool {
+			for _, p := range ports {
+				if p == fmt.Sprintf("%d/%s", port, proto) {
+					return false
+				}
+			}
+			return true
+		}
+	} else if out, err := exec.Command("ufw", "status").Output(); err == nil && strings.Contains(string(out), "Status: active") {
+		r.add(SectionFirewall, "ufw", StatusWarn, "is enabled")
+		status := string(out)
+		isBlocked = func(port int, proto string) bool {
+			return regexp.MustCompile(fmt.Sprintf(`(?m)^%d/%s\s+DENY`, port, proto)).MatchString(status)
+		}
+	} else {
+		return
+	}
+
+	for _, port := range firewallTCPPorts {
+		if isBlocked(port, "tcp") {
+			r.add(SectionFirewall, fmt.Sprintf("TCP Port %d", port), StatusFail, "blocked")
+		} else {
+			r.add(SectionFirewall, fmt.Sprintf("TCP Port %d", port), StatusPass, "open")
+		}
+	}
+	for _, port := range firewallUDPPorts {
+		if isBlocked(port, "udp") {
+			r.add(SectionFirewall, fmt.Sprintf("UDP Port %d", port), StatusWarn, "blocked; required for Flannel VXLAN/WireGuard")
+		} else {
+			r.add(SectionFirewall, fmt.Sprintf("UDP Port %d", port), StatusPass, "open")
+		}
+	}
+}
+
+func checkLimits(r *Report) {
+	const path, min = "/proc/sys/kernel/keys/root_maxkeys", 10000
+	b, err := os.ReadFile(path)
+	if err != nil {
+		r.add(SectionLimits, path, StatusWarn, "unknown: %v", err)
+		return
+	}
+	value := strings.TrimSpace(string(b))
+	if n, err := strconv.Atoi(value); err != nil || n <= min {
+		r.add(SectionLimits, path, StatusFail, "%s; this should be set to at least %d, for example set: sysctl -w kernel/keys/root_maxkeys=1000000", value, min)
+	} else {
+		r.add(SectionLimits, path, StatusPass, value)
+	}
+}
+
+// checkRuntimes checks for container runtimes and kubelets that are running outside of k3s.
+// Processes started from binDir, or that reference k3s on their command line, are ignored.
+func checkRuntimes(r *Report, binDir string) {
+	found := map[string]bool{}
+	pids, _ := filepath.Glob("/proc/[0-9]*")
+	for _, pid := range pids {
+		comm, err := os.ReadFile(filepath.Join(pid, "comm"))
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(string(comm))
+		if exe, err := os.Readlink(filepath.Join(pid, "exe")); err == nil && binDir != "" && filepath.Dir(exe) == filepath.Clean(binDir) {
+			continue
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(pid, "cmdline")); err == nil && strings.Contains(string(cmdline), "k3s") {
+			continue
+		}
+		found[name] = true
+	}
+
+	conflicts := false
+	for _, runtime := range conflictingRuntimes {
+		if found[runtime.comm] {
+			r.add(SectionRuntimes, runtime.comm, runtime.status, runtime.reason)
+			conflicts = true
+		}
+	}
+	if !conflicts {
+		r.add(SectionRuntimes, "conflicting runtimes", StatusPass, "none")
+	}
+}
+
+// checkModules checks that the kernel modules required by the agent are loaded, built in, or can be loaded.
+func checkModules(r *Report, config kernelConfig, release string) {
+	modulesDep, _ := os.ReadFile(filepath.Join("/lib/modules", release, "modules.dep"))
+	for _, module := range requiredModules {
+		switch {
+		case isDir(filepath.Join("/sys/module", module.name)):
+			r.add(SectionModules, module.name, StatusPass, "loaded")
+		case config[module.option] == "y":
+			r.add(SectionModules, module.name, StatusPass, "built in")
+		case config.isSet(module.option) || moduleAvailable(string(modulesDep), module.name):
+			r.add(SectionModules, module.name, StatusPass, "available as module")
+		default:
+			r.add(SectionModules, module.name, StatusFail, "missing")
+		}
+	}
+}
+
+// moduleAvailable returns true if modules.dep lists the module.
+func moduleAvailable(modulesDep, name string) bool {
+	for _, line := range strings.Split(modulesDep, "\n") {
+		file, _, _ := strings.Cut(line, ":")
+		base := filepath.Base(file)
+		if base, _, _ = strings.Cut(base, ".ko"); strings.ReplaceAll(base, "-", "_") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCgroups checks that the cgroup hierarchy is mounted and has the required controllers enabled.
+func checkCgroups(r *Report) {
+	var fs, hybrid unix.Statfs_t
+	variant := "V1"
+	if err := unix.Statfs("/sys/fs/cgroup", &fs); err != nil {
+		r.add(SectionRequired, "cgroup hierarchy", StatusFail, "cgroups Nonexistent")
+		return
+	}
+	if fs.Type == unix.CGROUP2_SUPER_MAGIC {
+		variant = "V2"
+	} else if err := unix.Statfs("/sys/fs/cgroup/unified", &hybrid); err == nil && hybrid.Type == unix.CGROUP2_SUPER_MAGIC {
+		variant = "Hybrid"
+	}
+
+	var required, controllers []string
+	if variant == "V2" {
+		required = []string{"cpu", "cpuset", "memory"}
+		b, _ := os.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+		controllers = strings.Fields(string(b))
+	} else {
+		required = []string{"cpuset", "memory"}
+		b, _ := os.ReadFile("/proc/self/cgroup")
+		controllers = parseCgroupV1Controllers(string(b))
+	}
+
+	if missing := missingControllers(required, controllers); len(missing) > 0 {
+		r.add(SectionRequired, "cgroup hierarchy", StatusFail, "cgroups %s mounted, missing controllers: %s", variant, strings.Join(missing, ", "))
+	} else {
+		r.add(SectionRequired, "cgroup hierarchy", StatusPass, "cgroups %s mounted, %s controllers status: good", variant, strings.Join(required, "|"))
+	}
+}
+
+func checkAppArmor(r *Report) {
+	if b, _ := os.ReadFile("/sys/module/apparmor/parameters/enabled"); strings.TrimSpace(string(b)) != "Y" {
+		return
+	}
+	if _, err := exec.LookPath("apparmor_parser"); err == nil {
+		r.add(SectionRequired, "apparmor", StatusPass, "enabled and tools installed")
+		return
+	}
+	hint := `look for an "apparmor" package for your distribution`
+	switch {
+	case commandExists("apt-get"):
+		hint = `use "apt-get install apparmor" to fix this`
+	case commandExists("yum"):
+		hint = `your best bet is "yum install apparmor-parser"`
+	case commandExists("zypper"):
+		hint = `your best bet is "zypper install apparmor-parser"`
+	}
+	r.add(SectionRequired, "apparmor", StatusFail, "enabled, but apparmor_parser missing (%s)", hint)
+}
+
+// checkDistroUserNS checks that user namespaces are enabled on RHEL7 and CentOS7, which disable them by default.
+func checkDistroUserNS(r *Report) {
+	b, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return
+	}
+	osRelease := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			osRelease[key] = strings.Trim(value, `"'`)
+		}
+	}
+	if (osRelease["ID"] != "centos" && osRelease["ID"] != "rhel") || !strings.HasPrefix(osRelease["VERSION_ID"], "7") {
+		return
+	}
+	if cmdline, _ := os.ReadFile("/proc/cmdline"); !strings.Contains(string(cmdline), "user_namespace.enable=1") {
+		r.add(SectionOptional, "RHEL7/CentOS7", StatusFail, "User namespaces disabled; add 'user_namespace.enable=1' to boot command line")
+	}
+}
+
+// checkKernelConfig checks the cgroup hierarchy, apparmor, and the required and optional kernel config options.
+// Some options are only checked on kernel versions where they exist.
+func checkKernelConfig(r *Report, config kernelConfig, release string) {
+	// only the major and minor version are compared, as in the check-config script
+	kernel := parseVersion(release)
+	if len(kernel) > 2 {
+		kernel = kernel[:2]
+	}
+
+	checkCgroups(r)
+	checkAppArmor(r)
+	flags := requiredFlags
+	if versionLess(kernel, []int{4, 8}) {
+		flags = append(flags[:len(flags):len(flags)], "DEVPTS_MULTIPLE_INSTANCES")
+	}
+	for _, flag := range flags {
+		status, message := config.check(flag, StatusFail)
+		r.add(SectionRequired, "CONFIG_"+flag, status, message)
+	}
+
+	status, message := config.check("USER_NS", StatusWarn)
+	r.add(SectionOptional, "CONFIG_USER_NS", status, message)
+	checkDistroUserNS(r)
+
+	flags = []string{}
+	if !versionLess([]int{4, 5}, kernel) {
+		flags = append(flags, "MEMCG_KMEM")
+	}
+	if !versionLess([]int{3, 18}, kernel) {
+		flags = append(flags, "RESOURCE_COUNTERS")
+	}
+	if !versionLess([]int{3, 13}, kernel) {
+		flags = append(flags, "NETPRIO_CGROUP")
+	} else {
+		flags = append(flags, "CGROUP_NET_PRIO")
+	}
+	for _, flag := range append(flags, optionalFlags...) {
+		status, message := config.check(flag, StatusWarn)
+		r.add(SectionOptional, "CONFIG_"+flag, status, message)
+	}
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package checkconfig
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+// Run is not supported on Windows.
+func Run(opts Options) (*Report, error) {
+	return nil, errors.ErrUnsupportedPlatform
+}
@@ -8,13 +8,19 @@ import (
 	"sync"
 
 	"github.com/k3s-io/k3s/pkg/agent"
+	"github.com/k3s-io/k3s/pkg/agent/discovery"
 	"github.com/k3s-io/k3s/pkg/agent/https"
+	"github.com/k3s-io/k3s/pkg/authenticator/cloudidentity"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/containerized"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/datadir"
 	k3smetrics "github.com/k3s-io/k3s/pkg/metrics"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/profile"
+	"github.com/k3s-io/k3s/pkg/secretstore"
 	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/spegel"
 	"github.com/k3s-io/k3s/pkg/util"
@@ -22,7 +28,9 @@ import (
 	"github.com/k3s-io/k3s/pkg/util/logger"
 	"github.com/k3s-io/k3s/pkg/util/mux"
 	"github.com/k3s-io/k3s/pkg/util/permissions"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 )
 
@@ -31,6 +39,29 @@ func Run(clx *cli.Context) (rerr error) {
 	// database credentials or other secrets.
 	proctitle.SetProcTitle(os.Args[0] + " agent")
 
+	// Validate extra args against the flags supported by the embedded components as soon as the command
+	// line and config file have been parsed, so that unsupported flags are rejected before anything is
+	// started, instead of causing the component to exit after startup.
+	if err := executor.ValidateArgs(executor.ComponentKubelet, cmds.AgentConfig.ExtraKubeletArgs.Value()); err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported kubelet args")
+	}
+	if err := executor.ValidateArgs(executor.ComponentKubeProxy, cmds.AgentConfig.ExtraKubeProxyArgs.Value()); err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported kube-proxy args")
+	}
+
+	containerizedMode, err := containerized.Resolve(cmds.AgentConfig.Containerized, false)
+	if err != nil {
+		return err
+	}
+	cmds.AgentConfig.Containerized = containerizedMode
+
+	if _, err := cmds.ParseComponentEnv(cmds.AgentConfig.ComponentEnv.Value()); err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported component env")
+	}
+	if _, err := cmds.ParseHooks(&cmds.AgentConfig); err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported hook")
+	}
+
 	// Evacuate cgroup v2 before doing anything else that may fork.
 	if err := cmds.EvacuateCgroup2(); err != nil {
 		return err
@@ -42,6 +73,12 @@ func Run(clx *cli.Context) (rerr error) {
 		return err
 	}
 
+	timeouts, err := signals.ParsePhaseTimeouts(cmds.AgentConfig.ShutdownPhaseTimeouts)
+	if err != nil {
+		return errors.WithMessage(err, "invalid shutdown-phase-timeouts")
+	}
+	signals.SetPhaseTimeouts(timeouts)
+
 	klog.EnableContextualLogging(true)
 	ctx := logger.NewContext(signals.SetupSignalContext(), "agent")
 	wg := &sync.WaitGroup{}
@@ -56,7 +93,9 @@ func Run(clx *cli.Context) (rerr error) {
 			<-ctx.Done()
 			rerr = ctx.Err()
 		}
-		wg.Wait()
+		if timeout := signals.PhaseTimeout(signals.PhaseETCD); !signals.Wait(wg, timeout) {
+			logrus.Warnf("Shutdown phase %s did not complete within %s", signals.PhaseETCD, timeout)
+		}
 	}()
 
 	if !cmds.AgentConfig.Rootless {
@@ -73,18 +112,59 @@ func Run(clx *cli.Context) (rerr error) {
 		cmds.AgentConfig.Token = token
 	}
 
+	if cmds.AgentConfig.TokenSource != "" {
+		if cmds.AgentConfig.TokenFile != "" {
+			return errors.New("invalid flag use; --token-file and --token-source cannot be used together")
+		}
+		token, err := secretstore.Read(ctx, cmds.AgentConfig.TokenSource, cmds.AgentConfig.TokenRefresh)
+		if err != nil {
+			return err
+		}
+		cmds.AgentConfig.Token = token
+	}
+
 	clientKubeletCert := filepath.Join(cmds.AgentConfig.DataDir, "agent", "client-kubelet.crt")
 	clientKubeletKey := filepath.Join(cmds.AgentConfig.DataDir, "agent", "client-kubelet.key")
-	_, err := tls.LoadX509KeyPair(clientKubeletCert, clientKubeletKey)
+	_, err = tls.LoadX509KeyPair(clientKubeletCert, clientKubeletKey)
 
-	if err != nil && cmds.AgentConfig.Token == "" {
-		return errors.New("--token is required")
+	if err != nil && cmds.AgentConfig.Token == "" && cmds.AgentConfig.CloudIdentity == "" {
+		return errors.New("--token or --cloud-identity is required")
+	}
+
+	// The cloud identity credential is used in place of the token password. If the token contains a CA hash,
+	// it is still used to validate the server's CA bundle.
+	if cmds.AgentConfig.CloudIdentity != "" {
+		password, err := cloudidentity.Password(ctx, cmds.AgentConfig.CloudIdentity, cmds.AgentConfig.CloudAudience, clientaccess.CAHash(cmds.AgentConfig.Token))
+		if err != nil {
+			return err
+		}
+		cmds.AgentConfig.Token = clientaccess.ReplacePassword(cmds.AgentConfig.Token, password)
 	}
 
 	if cmds.AgentConfig.ServerURL == "" {
 		return errors.New("--server is required")
 	}
 
+	// Servers discovered via DNS are health-checked, and the first healthy server is used to join the cluster.
+	// The agent continues to discover servers, and load-balances to them along with the servers in the cluster.
+	if discovery.IsDiscoveryURL(cmds.AgentConfig.ServerURL) {
+		d, err := discovery.New(cmds.AgentConfig.ServerURL, cmds.AgentConfig.Token)
+		if err != nil {
+			return err
+		}
+		serverURL, err := d.Resolve(ctx, config.ServerRetry{
+			Limit:       cmds.AgentConfig.ServerRetryLimit,
+			Interval:    metav1.Duration{Duration: cmds.AgentConfig.ServerRetryInterval},
+			MaxInterval: metav1.Duration{Duration: cmds.AgentConfig.ServerRetryMaxInterval},
+			Jitter:      cmds.AgentConfig.ServerRetryJitter,
+		})
+		if err != nil {
+			return err
+		}
+		cmds.AgentConfig.ServerDiscoveryURL = cmds.AgentConfig.ServerURL
+		cmds.AgentConfig.ServerURL = serverURL
+	}
+
 	if cmds.AgentConfig.FlannelIface != "" && len(cmds.AgentConfig.NodeIP.Value()) == 0 {
 		ip, err := util.GetIPFromInterface(cmds.AgentConfig.FlannelIface)
 		if err != nil {
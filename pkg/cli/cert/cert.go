@@ -12,8 +12,9 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
-	"github.com/k3s-io/k3s/pkg/agent/util"
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/bootstrap"
+	"github.com/k3s-io/k3s/pkg/certbackup"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -21,17 +22,20 @@ import (
 	"github.com/k3s-io/k3s/pkg/datadir"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/server/handlers"
 	k3sutil "github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/util/services"
 	"github.com/k3s-io/k3s/pkg/version"
-	"github.com/otiai10/copy"
 	certutil "github.com/rancher/dynamiclistener/cert"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 )
 
+// rotateTargetTimeout allows time for the server to sign the certificates and push them to the agent.
+const rotateTargetTimeout = 45 * time.Second
+
 // Certificate defines a single certificate data structure
 type Certificate struct {
 	Filename     string
@@ -252,7 +256,47 @@ func Rotate(app *cli.Context) error {
 	if err := cmds.InitLogging(); err != nil {
 		return err
 	}
-	return rotate(app, &cmds.ServerConfig)
+	if cmds.CertRotateConfig.Target != "" {
+		return rotateTarget(app, &cmds.ServerConfig, &cmds.CertRotateConfig)
+	}
+	err := rotate(app, &cmds.ServerConfig)
+	recordAction(&cmds.ServerConfig, "certificate rotate", strings.Join(cmds.ServicesList.Value(), ","), err)
+	return err
+}
+
+// rotateTarget requests that the server re-issue kubelet certificates for the target node,
+// and push them to the agent. The certificates are not rotated on the local node.
+func rotateTarget(app *cli.Context, cfg *cmds.Server, rotate *cmds.CertRotate) error {
+	nodeName, ok := strings.CutPrefix(rotate.Target, "node:")
+	if !ok || nodeName == "" {
+		return fmt.Errorf("invalid target %q: must be in the format node:<name>", rotate.Target)
+	}
+	if len(cmds.ServicesList.Value()) > 0 {
+		return errors.New("--service cannot be used with --target")
+	}
+
+	var serverConfig server.Config
+
+	_, err := commandSetup(app, cfg, &serverConfig)
+	if err != nil {
+		return err
+	}
+
+	info, err := clientaccess.ParseAndValidateToken(cfg.ServerURL, serverConfig.ControlConfig.Token, clientaccess.WithUser("server"))
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(handlers.NodeCertRequest{Name: nodeName})
+	if err != nil {
+		return err
+	}
+	if _, err := info.Post("/v1-"+version.Program+"/cert/node", b, clientaccess.WithTimeout(rotateTargetTimeout)); err != nil {
+		return errors.WithMessage(err, "see server log for details")
+	}
+
+	fmt.Printf("kubelet certificates re-issued and pushed to node %s; previously issued certificates remain valid until they expire\n", nodeName)
+	return nil
 }
 
 func rotate(app *cli.Context, cfg *cmds.Server) error {
@@ -289,9 +333,12 @@ func rotate(app *cli.Context, cfg *cmds.Server) error {
 		return err
 	}
 
-	// back up all the files
-	agentDataDir := filepath.Join(dataDir, "agent")
-	tlsBackupDir, err := backupCertificates(serverConfig.ControlConfig.DataDir, agentDataDir, fileMap)
+	// snapshot all the files, so that they can be backed up once removed
+	paths := []string{filepath.Join(serverConfig.ControlConfig.DataDir, "tls")}
+	for _, files := range fileMap {
+		paths = append(paths, files...)
+	}
+	snapshot, err := certbackup.Take(dataDir, paths...)
 	if err != nil {
 		return err
 	}
@@ -317,40 +364,62 @@ func rotate(app *cli.Context, cfg *cmds.Server) error {
 			}
 		}
 	}
+
+	// back up all the removed files
+	tlsBackupDir, err := snapshot.Save()
+	if err != nil {
+		return err
+	}
+	if tlsBackupDir == "" {
+		logrus.Infof("No certificates found to back up, please restart %s server or agent to rotate certificates", version.Program)
+		return nil
+	}
 	logrus.Infof("Successfully backed up certificates to %s, please restart %s server or agent to rotate certificates", tlsBackupDir, version.Program)
 	return nil
 }
 
-func backupCertificates(serverDataDir, agentDataDir string, fileMap map[string][]string) (string, error) {
-	backupDirName := fmt.Sprintf("tls-%d", time.Now().Unix())
-	serverTLSDir := filepath.Join(serverDataDir, "tls")
-	tlsBackupDir := filepath.Join(agentDataDir, backupDirName)
+func UndoRotate(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	err := undoRotate(app, &cmds.ServerConfig)
+	recordAction(&cmds.ServerConfig, "certificate undo-rotate", "", err)
+	return err
+}
 
-	// backup the server TLS dir if it exists
-	if _, err := os.Stat(serverTLSDir); err != nil {
-		if !os.IsNotExist(err) {
-			return "", err
-		}
-	} else {
-		tlsBackupDir = filepath.Join(serverDataDir, backupDirName)
-		if err := copy.Copy(serverTLSDir, tlsBackupDir); err != nil {
-			return "", err
-		}
+// undoRotate restores the most recent backup of certificates and kubeconfigs, taken either by the
+// rotate command, or when certificates were regenerated on startup.
+func undoRotate(app *cli.Context, cfg *cmds.Server) error {
+	var serverConfig server.Config
+
+	dataDir, err := commandSetup(app, cfg, &serverConfig)
+	if err != nil {
+		return err
 	}
 
-	for _, files := range fileMap {
-		for _, file := range files {
-			if strings.HasPrefix(file, agentDataDir) {
-				cert := filepath.Base(file)
-				tlsBackupCert := filepath.Join(tlsBackupDir, cert)
-				if err := util.CopyFile(file, tlsBackupCert, true); err != nil {
-					return "", err
-				}
-			}
-		}
+	tlsBackupDir, err := certbackup.Restore(dataDir)
+	if err != nil {
+		return err
 	}
 
-	return tlsBackupDir, nil
+	// Remove the dynamiclistener regeneration trigger file, if the rotate command created one.
+	dynamicListenerRegenFilePath := filepath.Join(serverConfig.ControlConfig.DataDir, "tls", "dynamic-cert-regenerate")
+	if err := os.Remove(dynamicListenerRegenFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	logrus.Infof("Successfully restored certificates from %s, please restart %s server or agent to use the restored certificates", tlsBackupDir, version.Program)
+	return nil
+}
+
+// recordAction records a certificate management action taken on this node to the audit log,
+// if enabled on this server.
+func recordAction(cfg *cmds.Server, action, target string, err error) {
+	dataDir, derr := datadir.Resolve(cfg.DataDir)
+	if derr != nil {
+		return
+	}
+	auditlog.RecordLocal(filepath.Join(dataDir, "server"), nil, action, target, err)
 }
 
 func validateCertConfig() error {
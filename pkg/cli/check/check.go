@@ -0,0 +1,148 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/datadir"
+	"github.com/k3s-io/k3s/pkg/permmonitor"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// cisResult is the output of the cis check, when json output is requested.
+type cisResult struct {
+	DataDir  string                `json:"dataDir"`
+	Findings []permmonitor.Finding `json:"findings"`
+	Fixed    bool                  `json:"fixed"`
+}
+
+func CIS(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return cis(app, &cmds.ServerConfig, &cmds.CheckConfig)
+}
+
+// cis audits the permissions and ownership of files under the server data-dir, using the same
+// checks as the periodic permissions audit run by the server. Returns an error if any drift
+// is found and not fixed, so that the command can be used in scripted compliance checks.
+func cis(app *cli.Context, cfg *cmds.Server, ccfg *cmds.Check) error {
+	proctitle.SetProcTitle(os.Args[0])
+
+	if ccfg.Output != "text" && ccfg.Output != "json" {
+		return fmt.Errorf("invalid output format %s", ccfg.Output)
+	}
+
+	dataDir, err := datadir.Resolve(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	controlConfig := &config.Control{
+		DataDir:              filepath.Join(dataDir, "server"),
+		KubeConfigMode:       cfg.KubeConfigMode,
+		KubeConfigGroup:      cfg.KubeConfigGroup,
+		KubeConfigComponents: cfg.KubeConfigComponents,
+		Rootless:             cfg.Rootless,
+		Runtime:              config.NewRuntime(),
+	}
+	if _, err := os.Stat(controlConfig.DataDir); err != nil {
+		return errors.WithMessage(err, "server data directory not found; the cis check must be run on a server node")
+	}
+	deps.CreateRuntimeCertFiles(controlConfig)
+
+	findings, err := permmonitor.Audit(controlConfig)
+	if err != nil {
+		return errors.WithMessage(err, "failed to audit permissions")
+	}
+
+	if ccfg.Fix && len(findings) > 0 {
+		if err := permmonitor.Fix(findings); err != nil {
+			return errors.WithMessage(err, "failed to fix permissions")
+		}
+	}
+
+	if ccfg.Output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cisResult{DataDir: controlConfig.DataDir, Findings: findings, Fixed: ccfg.Fix && len(findings) > 0}); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+	}
+
+	switch {
+	case len(findings) == 0:
+		logrus.Infof("Permissions and ownership under %s match CIS expectations", controlConfig.DataDir)
+	case ccfg.Fix:
+		logrus.Infof("Restored permissions and ownership of %d files under %s", len(findings), controlConfig.DataDir)
+	default:
+		return fmt.Errorf("permissions or ownership of %d files under %s do not match CIS expectations; re-run with --fix to restore them", len(findings), controlConfig.DataDir)
+	}
+	return nil
+}
+
+// auditLogResult is the output of the audit-log check, when json output is requested.
+type auditLogResult struct {
+	LogFile  string `json:"logFile"`
+	Verified int64  `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+func AuditLog(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return auditLog(app, &cmds.ServerConfig, &cmds.CheckConfig)
+}
+
+// auditLog verifies the sequence and signature of each entry in the management audit log under the
+// server data-dir. Returns an error if any entry fails verification, as the log has been modified.
+func auditLog(app *cli.Context, cfg *cmds.Server, ccfg *cmds.Check) error {
+	proctitle.SetProcTitle(os.Args[0])
+
+	if ccfg.Output != "text" && ccfg.Output != "json" {
+		return fmt.Errorf("invalid output format %s", ccfg.Output)
+	}
+
+	dataDir, err := datadir.Resolve(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	serverDataDir := filepath.Join(dataDir, "server")
+	logFile := auditlog.LogFile(serverDataDir)
+	if _, err := os.Stat(logFile); err != nil {
+		return errors.WithMessage(err, "management audit log not found; the audit-log check must be run on a server node with --management-audit enabled")
+	}
+
+	verified, verifyErr := auditlog.Verify(serverDataDir)
+	if ccfg.Output == "json" {
+		result := auditLogResult{LogFile: logFile, Verified: verified}
+		if verifyErr != nil {
+			result.Error = verifyErr.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	}
+
+	if verifyErr != nil {
+		return errors.WithMessagef(verifyErr, "management audit log %s failed verification after %d entries", logFile, verified)
+	}
+	logrus.Infof("Verified %d entries in management audit log %s", verified, logFile)
+	return nil
+}
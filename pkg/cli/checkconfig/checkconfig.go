@@ -0,0 +1,64 @@
+package checkconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/checkconfig"
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/urfave/cli/v2"
+)
+
+func Run(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return run(app, &cmds.ConfigCheckConfig)
+}
+
+// run checks that the host meets the requirements for running k3s, and prints the results.
+// The exit code is the number of failed checks, so that the command can be used in automation.
+func run(app *cli.Context, cfg *cmds.ConfigCheck) error {
+	proctitle.SetProcTitle(os.Args[0] + " check-config")
+
+	if cfg.Output != "text" && cfg.Output != "json" {
+		return fmt.Errorf("invalid output format %s", cfg.Output)
+	}
+	if app.Args().Len() > 1 {
+		return errors.New("at most one kernel config path may be given")
+	}
+
+	// the kernel config may also be passed as an argument, for compatibility with the check-config script
+	opts := checkconfig.Options{KernelConfig: cfg.KernelConfig}
+	if app.Args().Len() == 1 {
+		opts.KernelConfig = app.Args().First()
+	}
+	// the bundled binaries are verified if the command is run from the data dir
+	if exe, err := os.Executable(); err == nil {
+		opts.BinDir = filepath.Dir(exe)
+	}
+
+	report, err := checkconfig.Run(opts)
+	if err != nil {
+		return errors.WithMessage(err, "failed to check config")
+	}
+
+	if cfg.Output == "json" {
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			return err
+		}
+	} else {
+		if report.KernelConfig != "" {
+			fmt.Printf("info: reading kernel config from %s ...\n", report.KernelConfig)
+		}
+		report.WriteText(os.Stdout, !cfg.NoColor)
+	}
+
+	if report.Failures > 0 {
+		return cli.Exit("", min(report.Failures, 255))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkpointResponse is the response from the kubelet checkpoint API, listing
+// the paths of the checkpoint archives written on the node.
+type checkpointResponse struct {
+	Items []string `json:"items"`
+}
+
+func Run(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return run(app, &cmds.CheckpointConfig)
+}
+
+// run checkpoints a container via the apiserver's node proxy to the kubelet checkpoint API.
+// The kubelet writes the archive to its checkpoint directory on the node hosting the pod;
+// if that is this node, the archive is copied to the output directory.
+func run(app *cli.Context, cfg *cmds.Checkpoint) error {
+	if app.Args().Len() != 1 {
+		return errors.New("exactly one pod name must be given")
+	}
+
+	client, err := util.GetClientSet(util.GetKubeConfigPath(cfg.Kubeconfig))
+	if err != nil {
+		return err
+	}
+
+	pod, err := client.CoreV1().Pods(cfg.Namespace).Get(app.Context, app.Args().First(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.Spec.NodeName == "" {
+		return fmt.Errorf("pod %s/%s is not scheduled to a node", pod.Namespace, pod.Name)
+	}
+
+	container := cfg.Container
+	if container == "" {
+		if len(pod.Spec.Containers) != 1 {
+			return fmt.Errorf("pod %s/%s has %d containers; the container to checkpoint must be specified", pod.Namespace, pod.Name, len(pod.Spec.Containers))
+		}
+		container = pod.Spec.Containers[0].Name
+	}
+
+	req := client.CoreV1().RESTClient().Post().AbsPath("/api/v1/nodes", pod.Spec.NodeName, "proxy", "checkpoint", pod.Namespace, pod.Name, container)
+	if cfg.Timeout > 0 {
+		req = req.Param("timeout", strconv.Itoa(int(cfg.Timeout.Seconds())))
+	}
+	b, err := req.DoRaw(app.Context)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to checkpoint container %s in pod %s/%s; ensure that node %s is started with --container-checkpoint", container, pod.Namespace, pod.Name, pod.Spec.NodeName)
+	}
+	resp := checkpointResponse{}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+
+	for _, archive := range resp.Items {
+		if _, err := os.Stat(archive); err != nil {
+			logrus.Infof("Checkpoint archive for container %s in pod %s/%s written to %s on node %s", container, pod.Namespace, pod.Name, archive, pod.Spec.NodeName)
+			continue
+		}
+		dest := filepath.Join(cfg.Output, filepath.Base(archive))
+		if err := copyArchive(archive, dest); err != nil {
+			return errors.WithMessagef(err, "failed to copy checkpoint archive %s", archive)
+		}
+		logrus.Infof("Checkpoint archive for container %s in pod %s/%s saved to %s", container, pod.Namespace, pod.Name, dest)
+	}
+	return nil
+}
+
+// copyArchive copies the checkpoint archive to the destination path. The archive contains
+// the memory of the container, so it is only readable by the owner.
+func copyArchive(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
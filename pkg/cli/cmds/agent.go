@@ -3,7 +3,11 @@ package cmds
 import (
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/k3s-io/k3s/pkg/hooks"
+	"github.com/k3s-io/k3s/pkg/secretstore"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/urfave/cli/v2"
 )
@@ -11,8 +15,17 @@ import (
 type Agent struct {
 	Token                    string
 	TokenFile                string
+	TokenSource              string
+	TokenRefresh             time.Duration
+	CloudIdentity            string
+	CloudAudience            string
 	ClusterSecret            string
 	ServerURL                string
+	ServerDiscoveryURL       string
+	ServerRetryLimit         int
+	ServerRetryInterval      time.Duration
+	ServerRetryMaxInterval   time.Duration
+	ServerRetryJitter        float64
 	APIAddressCh             chan []string
 	DisableLoadBalancer      bool
 	DisableServiceLB         bool
@@ -44,13 +57,34 @@ type Agent struct {
 	Rootless                 bool
 	RootlessAlreadyUnshared  bool
 	WithNodeID               bool
+	TPMKeyPinning            bool
+	TPMDevice                string
 	EnableSELinux            bool
 	ProtectKernelDefaults    bool
+	Containerized            bool
+	ComponentEnv             cli.StringSlice
+	Hooks                    cli.StringSlice
+	HookTimeout              time.Duration
+	HookPolicy               string
+	ShutdownPhaseTimeouts    string
+	ContainerCheckpoint      bool
+	WarmStandby              bool
+	WarmStandbyInterval      time.Duration
+	ImageMirrorList          string
+	ImageMirrorInterval      time.Duration
+	DiskPressureActions      cli.StringSlice
+	DiskPressureThreshold    int
+	DiskPressureInterval     time.Duration
+	DiskPressureLogMaxSize   string
+	DiskPressureDryRun       bool
 	ClusterReset             bool
 	PrivateRegistry          string
 	SystemDefaultRegistry    string
 	AirgapExtraRegistry      cli.StringSlice
 	ExtraKubeletArgs         cli.StringSlice
+	KubeletBindAddress       string
+	KubeletHealthzAddress    string
+	KubeletReadOnlyPort      int
 	ExtraKubeProxyArgs       cli.StringSlice
 	Labels                   cli.StringSlice
 	Taints                   cli.StringSlice
@@ -105,17 +139,133 @@ var (
 		Usage:       "(agent/node) Append id to node name",
 		Destination: &AgentConfig.WithNodeID,
 	}
+	TPMKeyPinningFlag = &cli.BoolFlag{
+		Name:        "tpm-key-pinning",
+		Usage:       "(agent/node) Sign certificate requests with a TPM-resident key. The server pins the key the first time it is used for this node name, and rejects later requests for the node name that are not signed with it",
+		Destination: &AgentConfig.TPMKeyPinning,
+	}
+	TPMDeviceFlag = &cli.StringFlag{
+		Name:        "tpm-device",
+		Usage:       "(agent/node) TPM device used for key pinning",
+		Value:       "/dev/tpmrm0",
+		Destination: &AgentConfig.TPMDevice,
+	}
 	ProtectKernelDefaultsFlag = &cli.BoolFlag{
 		Name:        "protect-kernel-defaults",
 		Usage:       "(agent/node) Kernel tuning behavior. If set, error if kernel tunables are different than kubelet defaults.",
 		Destination: &AgentConfig.ProtectKernelDefaults,
 	}
+	ContainerizedFlag = &cli.BoolFlag{
+		Name:        "containerized",
+		Usage:       "(agent/node) Run in a container. Kernel modules and sysctls that cannot be managed from the container are left to the host. Enabled automatically in unprivileged containers, where it requires --disable-agent",
+		Destination: &AgentConfig.Containerized,
+	}
+	HookFlag = &cli.StringSliceFlag{
+		Name:        "hook",
+		Usage:       "(agent/node) Command to run at a point during startup, in POINT:COMMAND format. Commands are run with sh. Supported points: " + strings.Join(hooks.Points, ", "),
+		Destination: &AgentConfig.Hooks,
+	}
+	HookTimeoutFlag = &cli.DurationFlag{
+		Name:        "hook-timeout",
+		Usage:       "(agent/node) Maximum time to wait for each startup hook to complete",
+		Value:       time.Minute,
+		Destination: &AgentConfig.HookTimeout,
+	}
+	HookFailurePolicyFlag = &cli.StringFlag{
+		Name:        "hook-failure-policy",
+		Usage:       "(agent/node) Action taken when a startup hook fails, one of '" + hooks.FailurePolicyFail + "' to stop startup, or '" + hooks.FailurePolicyIgnore + "' to continue",
+		Value:       hooks.FailurePolicyFail,
+		Destination: &AgentConfig.HookPolicy,
+	}
+	ShutdownPhaseTimeoutsFlag = &cli.StringFlag{
+		Name:        "shutdown-phase-timeouts",
+		Usage:       "(agent/node) Maximum time allowed for each shutdown phase, as comma-separated phase=duration pairs. Phases are run in order: joins=5s, snapshots=5m, controllers=30s, kubelet=1m, leadership=10s, etcd=0s. A duration of 0s waits indefinitely",
+		Destination: &AgentConfig.ShutdownPhaseTimeouts,
+	}
+	ContainerCheckpointFlag = &cli.BoolFlag{
+		Name:        "container-checkpoint",
+		Usage:       "(agent/node) Enable the kubelet container checkpoint API, for checkpointing running containers with CRIU. Requires criu to be installed",
+		Destination: &AgentConfig.ContainerCheckpoint,
+	}
+	WarmStandbyFlag = &cli.BoolFlag{
+		Name:        "warm-standby",
+		Usage:       "(agent/node) Pre-pull and pin images for pods labeled " + version.Program + ".io/warm-standby=true, so that they start quickly if rescheduled to this node. Requires embedded containerd",
+		Destination: &AgentConfig.WarmStandby,
+	}
+	WarmStandbyIntervalFlag = &cli.DurationFlag{
+		Name:        "warm-standby-interval",
+		Usage:       "(agent/node) Interval at which warm standby images are resynced",
+		Value:       5 * time.Minute,
+		Destination: &AgentConfig.WarmStandbyInterval,
+	}
+	ImageMirrorListFlag = &cli.StringFlag{
+		Name:        "image-mirror-list",
+		Usage:       "(agent/runtime) Path to a file listing image references to periodically fetch for all platforms through the configured registry mirrors, keeping them cached in the local content store. Requires embedded containerd",
+		Destination: &AgentConfig.ImageMirrorList,
+	}
+	ImageMirrorIntervalFlag = &cli.DurationFlag{
+		Name:        "image-mirror-interval",
+		Usage:       "(agent/runtime) Interval at which images in the image mirror list are refreshed",
+		Value:       6 * time.Hour,
+		Destination: &AgentConfig.ImageMirrorInterval,
+	}
+	DiskPressureActionsFlag = &cli.StringSliceFlag{
+		Name:        "disk-pressure-remediation",
+		Usage:       "(agent/runtime) Remediation actions to take when usage of the kubelet or container runtime filesystem exceeds the disk pressure threshold, in addition to kubelet eviction: sandboxes (remove completed pod sandboxes), logs (rotate oversized container logs), images (remove unused images). Disabled if not set",
+		Destination: &AgentConfig.DiskPressureActions,
+	}
+	DiskPressureThresholdFlag = &cli.IntFlag{
+		Name:        "disk-pressure-threshold",
+		Usage:       "(agent/runtime) Filesystem space or inode usage percentage at which disk pressure remediation actions are taken",
+		Value:       85,
+		Destination: &AgentConfig.DiskPressureThreshold,
+	}
+	DiskPressureIntervalFlag = &cli.DurationFlag{
+		Name:        "disk-pressure-interval",
+		Usage:       "(agent/runtime) Interval at which filesystem usage is checked for disk pressure remediation",
+		Value:       time.Minute,
+		Destination: &AgentConfig.DiskPressureInterval,
+	}
+	DiskPressureLogMaxSizeFlag = &cli.StringFlag{
+		Name:        "disk-pressure-log-max-size",
+		Usage:       "(agent/runtime) Total size of current and rotated logs for a container above which its logs are rotated and discarded by disk pressure remediation",
+		Value:       "50Mi",
+		Destination: &AgentConfig.DiskPressureLogMaxSize,
+	}
+	DiskPressureDryRunFlag = &cli.BoolFlag{
+		Name:        "disk-pressure-dry-run",
+		Usage:       "(agent/runtime) Report the disk pressure remediation actions that would be taken as events, without taking them",
+		Destination: &AgentConfig.DiskPressureDryRun,
+	}
 	SELinuxFlag = &cli.BoolFlag{
 		Name:        "selinux",
 		Usage:       "(agent/node) Enable SELinux in containerd",
 		Destination: &AgentConfig.EnableSELinux,
 		EnvVars:     []string{version.ProgramUpper + "_SELINUX"},
 	}
+	ServerRetryLimitFlag = &cli.IntFlag{
+		Name:        "server-retry-limit",
+		Usage:       "(cluster) Maximum number of attempts to contact the server URL when joining the cluster. If value <= 0, attempts are retried indefinitely",
+		Destination: &AgentConfig.ServerRetryLimit,
+	}
+	ServerRetryIntervalFlag = &cli.DurationFlag{
+		Name:        "server-retry-interval",
+		Usage:       "(cluster) Initial interval between attempts to contact the server URL when joining the cluster. The interval is doubled after each failed attempt",
+		Destination: &AgentConfig.ServerRetryInterval,
+		Value:       5 * time.Second,
+	}
+	ServerRetryMaxIntervalFlag = &cli.DurationFlag{
+		Name:        "server-retry-max-interval",
+		Usage:       "(cluster) Maximum interval between attempts to contact the server URL when joining the cluster",
+		Destination: &AgentConfig.ServerRetryMaxInterval,
+		Value:       time.Minute,
+	}
+	ServerRetryJitterFlag = &cli.Float64Flag{
+		Name:        "server-retry-jitter",
+		Usage:       "(cluster) Maximum random jitter added to the interval between attempts to contact the server URL, as a fraction of the interval",
+		Destination: &AgentConfig.ServerRetryJitter,
+		Value:       1.0,
+	}
 	LBServerPortFlag = &cli.IntFlag{
 		Name:        "lb-server-port",
 		Usage:       "(agent/node) Local port for supervisor client load-balancer. If the supervisor and apiserver are not colocated an additional port 1 less than this port will also be used for the apiserver client load-balancer.",
@@ -205,6 +355,21 @@ var (
 		Usage:       "(agent/flags) Customized flag for kubelet process",
 		Destination: &AgentConfig.ExtraKubeletArgs,
 	}
+	KubeletBindAddressFlag = &cli.StringFlag{
+		Name:        "kubelet-bind-address",
+		Usage:       "(agent/networking) IPv4/IPv6 address the kubelet API and metrics are served on; set to a loopback address to only allow access to the kubelet through the supervisor tunnel (default: bind-address, or all interfaces)",
+		Destination: &AgentConfig.KubeletBindAddress,
+	}
+	KubeletHealthzAddressFlag = &cli.StringFlag{
+		Name:        "kubelet-healthz-bind-address",
+		Usage:       "(agent/networking) IPv4/IPv6 address the kubelet healthz endpoint is served on (default: loopback address)",
+		Destination: &AgentConfig.KubeletHealthzAddress,
+	}
+	KubeletReadOnlyPortFlag = &cli.IntFlag{
+		Name:        "kubelet-read-only-port",
+		Usage:       "(agent/networking) Port the unauthenticated kubelet read-only API is served on; 0 to disable (default: 0)",
+		Destination: &AgentConfig.KubeletReadOnlyPort,
+	}
 	ExtraKubeProxyArgs = &cli.StringSliceFlag{
 		Name:        "kube-proxy-arg",
 		Usage:       "(agent/flags) Customized flag for kube-proxy process",
@@ -272,6 +437,7 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			VModule,
 			LogFile,
 			AlsoLogToStderr,
+			LogMetrics,
 			AgentTokenFlag,
 			&cli.StringFlag{
 				Name:        "token-file",
@@ -279,13 +445,40 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 				EnvVars:     []string{version.ProgramUpper + "_TOKEN_FILE"},
 				Destination: &AgentConfig.TokenFile,
 			},
+			&cli.StringFlag{
+				Name:        "token-source",
+				Usage:       "(cluster) Secret store reference to read the token from: vault://PATH[#KEY], aws-sm://SECRET[#KEY], gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION], or azure-kv://VAULT/SECRET[/VERSION]",
+				EnvVars:     []string{version.ProgramUpper + "_TOKEN_SOURCE"},
+				Destination: &AgentConfig.TokenSource,
+			},
+			&cli.DurationFlag{
+				Name:        "token-source-refresh",
+				Usage:       "(cluster) Interval at which the token is read again from the secret store",
+				Value:       secretstore.DefaultRefreshInterval,
+				Destination: &AgentConfig.TokenRefresh,
+			},
+			&cli.StringFlag{
+				Name:        "cloud-identity",
+				Usage:       "(cluster) Authenticate to the server with the cloud identity of this instance, instead of a token password. --token must still be set to the K10-prefixed CA hash of the cluster, which the credential is bound to. Supported providers: aws, gcp, azure",
+				EnvVars:     []string{version.ProgramUpper + "_CLOUD_IDENTITY"},
+				Destination: &AgentConfig.CloudIdentity,
+			},
+			&cli.StringFlag{
+				Name:        "cloud-identity-audience",
+				Usage:       "(cluster) Audience of the gcp or azure identity token. Must match the server's --cloud-identity-audience. Defaults to an audience derived from the cluster CA hash; required for azure",
+				Destination: &AgentConfig.CloudAudience,
+			},
 			&cli.StringFlag{
 				Name:        "server",
 				Aliases:     []string{"s"},
-				Usage:       "(cluster) Server to connect to",
+				Usage:       "(cluster) Server to connect to. Use dns+https://NAME[:PORT] to discover servers from _" + version.Program + "._tcp.NAME SRV records, or from NAME A/AAAA records",
 				EnvVars:     []string{version.ProgramUpper + "_URL"},
 				Destination: &AgentConfig.ServerURL,
 			},
+			ServerRetryLimitFlag,
+			ServerRetryIntervalFlag,
+			ServerRetryMaxIntervalFlag,
+			ServerRetryJitterFlag,
 			// Note that this is different from DataDirFlag used elswhere in the CLI,
 			// as this is bound to AgentConfig instead of ServerConfig.
 			&cli.StringFlag{
@@ -298,6 +491,8 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			},
 			NodeNameFlag,
 			WithNodeIDFlag,
+			TPMKeyPinningFlag,
+			TPMDeviceFlag,
 			NodeLabels,
 			NodeTaints,
 			ImageCredProvBinDirFlag,
@@ -305,6 +500,15 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			SELinuxFlag,
 			LBServerPortFlag,
 			ProtectKernelDefaultsFlag,
+			ContainerizedFlag,
+			ComponentEnvFlag,
+			HookFlag,
+			HookTimeoutFlag,
+			HookFailurePolicyFlag,
+			ShutdownPhaseTimeoutsFlag,
+			ContainerCheckpointFlag,
+			WarmStandbyFlag,
+			WarmStandbyIntervalFlag,
 			CRIEndpointFlag,
 			DefaultRuntimeFlag,
 			ImageServiceEndpointFlag,
@@ -314,6 +518,13 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			DisableDefaultRegistryEndpointFlag,
 			NonrootDevicesFlag,
 			AirgapExtraRegistryFlag,
+			ImageMirrorListFlag,
+			ImageMirrorIntervalFlag,
+			DiskPressureActionsFlag,
+			DiskPressureThresholdFlag,
+			DiskPressureIntervalFlag,
+			DiskPressureLogMaxSizeFlag,
+			DiskPressureDryRunFlag,
 			NodeIPFlag,
 			BindAddressFlag,
 			NodeExternalIPFlag,
@@ -325,6 +536,9 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			FlannelCniConfFileFlag,
 			ExtraKubeletArgs,
 			ExtraKubeProxyArgs,
+			KubeletBindAddressFlag,
+			KubeletHealthzAddressFlag,
+			KubeletReadOnlyPortFlag,
 			// Experimental flags
 			EnablePProfFlag,
 			&cli.BoolFlag{
@@ -341,3 +555,13 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 		},
 	}
 }
+
+// ParseHooks parses the startup hooks configured for the agent, along with any environment
+// variables set for hooks with --component-env.
+func ParseHooks(agent *Agent) (*hooks.Hooks, error) {
+	env, err := ParseComponentEnv(agent.ComponentEnv.Value())
+	if err != nil {
+		return nil, err
+	}
+	return hooks.Parse(agent.Hooks.Value(), agent.HookTimeout, agent.HookPolicy, env[EnvComponentHook])
+}
@@ -7,6 +7,10 @@ import (
 
 const CertCommand = "certificate"
 
+type CertRotate struct {
+	Target string
+}
+
 type CertRotateCA struct {
 	CACertPath string
 	Force      bool
@@ -14,6 +18,7 @@ type CertRotateCA struct {
 
 var (
 	ServicesList           cli.StringSlice
+	CertRotateConfig       CertRotate
 	CertRotateCAConfig     CertRotateCA
 	CertRotateCommandFlags = []cli.Flag{
 		DebugFlag,
@@ -28,6 +33,13 @@ var (
 			Destination: &ServicesList,
 		},
 	}
+	CertUndoRotateCommandFlags = []cli.Flag{
+		DebugFlag,
+		ConfigFlag,
+		LogFile,
+		AlsoLogToStderr,
+		DataDirFlag,
+	}
 	CertRotateCACommandFlags = []cli.Flag{
 		DataDirFlag,
 		&cli.StringFlag{
@@ -52,13 +64,13 @@ var (
 	}
 )
 
-func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.Command {
+func NewCertCommands(check, rotate, rotateCA, undoRotate func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:            CertCommand,
 		Usage:           "Manage K3s certificates",
 		SkipFlagParsing: false,
 		Subcommands: []*cli.Command{
-			{
+			withCompletion(&cli.Command{
 				Name:            "check",
 				Usage:           "Check " + version.Program + " component certificates on disk",
 				SkipFlagParsing: false,
@@ -69,14 +81,27 @@ func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.
 					Usage:   "Format output. Options: text, table, json, yaml",
 					Value:   "text",
 				}),
-			},
-			{
+			}, nil, map[string]CompleteFunc{"service": completeCertServices}),
+			withCompletion(&cli.Command{
 				Name:            "rotate",
 				Usage:           "Rotate " + version.Program + " component certificates on disk",
 				SkipFlagParsing: false,
 				Action:          rotate,
-				Flags:           CertRotateCommandFlags,
-			},
+				Flags: append(CertRotateCommandFlags,
+					&cli.StringFlag{
+						Name:        "target",
+						Usage:       "Re-issue kubelet certificates for a single node, and push them to the agent through a connected server. Format: node:<name>",
+						Destination: &CertRotateConfig.Target,
+					},
+					&cli.StringFlag{
+						Name:        "server",
+						Usage:       "(cluster) Server to connect to when rotating certificates for a target node",
+						EnvVars:     []string{version.ProgramUpper + "_URL"},
+						Value:       "https://127.0.0.1:6443",
+						Destination: &ServerConfig.ServerURL,
+					},
+				),
+			}, nil, map[string]CompleteFunc{"service": completeCertServices}),
 			{
 				Name:            "rotate-ca",
 				Usage:           "Write updated " + version.Program + " CA certificates to the datastore",
@@ -84,6 +109,13 @@ func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.
 				Action:          rotateCA,
 				Flags:           CertRotateCACommandFlags,
 			},
+			{
+				Name:            "undo-rotate",
+				Usage:           "Restore the most recent backup of " + version.Program + " component certificates on disk",
+				SkipFlagParsing: false,
+				Action:          undoRotate,
+				Flags:           CertUndoRotateCommandFlags,
+			},
 		},
 	}
 }
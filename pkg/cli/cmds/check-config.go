@@ -4,11 +4,44 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+const CheckConfigCommand = "check-config"
+
+// ConfigCheck holds CLI values for the check-config command
+type ConfigCheck struct {
+	Output       string
+	KernelConfig string
+	NoColor      bool
+}
+
+var ConfigCheckConfig = ConfigCheck{}
+
 func NewCheckConfigCommand(action func(*cli.Context) error) *cli.Command {
 	return &cli.Command{
-		Name:            "check-config",
-		Usage:           "Run config check",
-		SkipFlagParsing: true,
+		Name:            CheckConfigCommand,
+		Usage:           "Check that the host meets the requirements for running " + appName + ". Exits nonzero if any required check fails",
+		UsageText:       appName + " check-config [OPTIONS] [KERNEL_CONFIG]",
+		SkipFlagParsing: false,
 		Action:          action,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "Format output. Options: text, json",
+				Value:       "text",
+				Destination: &ConfigCheckConfig.Output,
+			},
+			&cli.StringFlag{
+				Name:        "kernel-config",
+				Usage:       "Path to the kernel config. If not set, common locations such as /proc/config.gz and /boot/config-$(uname -r) are searched",
+				EnvVars:     []string{"CONFIG"},
+				Destination: &ConfigCheckConfig.KernelConfig,
+			},
+			&cli.BoolFlag{
+				Name:        "no-color",
+				Usage:       "Disable colors in text output",
+				EnvVars:     []string{"NO_COLOR"},
+				Destination: &ConfigCheckConfig.NoColor,
+			},
+		},
 	}
 }
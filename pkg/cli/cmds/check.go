@@ -0,0 +1,60 @@
+package cmds
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+const CheckCommand = "check"
+
+// Check holds CLI values for the check subcommands
+type Check struct {
+	Fix    bool
+	Output string
+}
+
+var CheckConfig = Check{}
+
+func NewCheckCommands(cis, auditLog func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:            CheckCommand,
+		Usage:           "Check the local node for compliance with security benchmarks",
+		SkipFlagParsing: false,
+		Subcommands: []*cli.Command{
+			{
+				Name:            "cis",
+				Usage:           "Check that the permissions and ownership of certificates, credentials, and generated configs under the data directory match CIS expectations",
+				UsageText:       appName + " check cis [OPTIONS]",
+				SkipFlagParsing: false,
+				Action:          cis,
+				Flags: append(ServerFlags,
+					&cli.BoolFlag{
+						Name:        "fix",
+						Usage:       "Restore the expected permissions and ownership of any files that do not match",
+						Destination: &CheckConfig.Fix,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Usage:       "Format output. Options: text, json",
+						Destination: &CheckConfig.Output,
+						Value:       "text",
+					},
+				),
+			},
+			{
+				Name:            "audit-log",
+				Usage:           "Verify the sequence and signatures of entries in the management audit log under the data directory",
+				UsageText:       appName + " check audit-log [OPTIONS]",
+				SkipFlagParsing: false,
+				Action:          auditLog,
+				Flags: append(ServerFlags,
+					&cli.StringFlag{
+						Name:        "output",
+						Usage:       "Format output. Options: text, json",
+						Destination: &CheckConfig.Output,
+						Value:       "text",
+					},
+				),
+			},
+		},
+	}
+}
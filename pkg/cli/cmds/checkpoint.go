@@ -0,0 +1,63 @@
+package cmds
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const CheckpointCommand = "checkpoint"
+
+// Checkpoint holds CLI values for the checkpoint command
+type Checkpoint struct {
+	Kubeconfig string
+	Namespace  string
+	Container  string
+	Output     string
+	Timeout    time.Duration
+}
+
+var CheckpointConfig = Checkpoint{}
+
+func NewCheckpointCommand(action func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:            CheckpointCommand,
+		Usage:           "Checkpoint a running container with CRIU, using the kubelet container checkpoint API. The node must be started with --container-checkpoint",
+		UsageText:       appName + " checkpoint [OPTIONS] POD",
+		SkipFlagParsing: false,
+		Action:          action,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "kubeconfig",
+				Usage:       "(cluster) Server to connect to",
+				EnvVars:     []string{"KUBECONFIG"},
+				Destination: &CheckpointConfig.Kubeconfig,
+			},
+			&cli.StringFlag{
+				Name:        "namespace",
+				Aliases:     []string{"n"},
+				Usage:       "Namespace of the pod",
+				Value:       "default",
+				Destination: &CheckpointConfig.Namespace,
+			},
+			&cli.StringFlag{
+				Name:        "container",
+				Aliases:     []string{"c"},
+				Usage:       "Container to checkpoint. May be omitted if the pod has only one container",
+				Destination: &CheckpointConfig.Container,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "Directory to copy the checkpoint archive to, when the pod is running on this node. The archive contains the memory of the container and should be handled as sensitive data",
+				Value:       ".",
+				Destination: &CheckpointConfig.Output,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Usage:       "Time to wait for the checkpoint to complete. If zero, the kubelet default is used",
+				Destination: &CheckpointConfig.Timeout,
+			},
+		},
+	}
+}
@@ -1,6 +1,14 @@
 package cmds
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/datadir"
+	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/urfave/cli/v2"
 )
 
@@ -36,3 +44,117 @@ func NewCompletionCommand(bash, zsh func(*cli.Context) error) *cli.Command {
 		},
 	}
 }
+
+// CompleteFunc returns the values that a positional argument or flag value may be completed with.
+type CompleteFunc func(ctx *cli.Context) []string
+
+// certServices are the services that certificates can be checked or rotated for.
+var certServices = []string{
+	"admin",
+	"api-server",
+	"auth-proxy",
+	"cloud-controller",
+	"controller-manager",
+	"etcd",
+	"scheduler",
+	"supervisor",
+	version.Program + "-server",
+	"kube-proxy",
+	"kubelet",
+	version.Program + "-controller",
+}
+
+// withCompletion sets the shell completion function for a command. Positional arguments are completed with
+// the values returned by args, and flag values with the values returned by the function for that flag.
+// The values of flags without a completion function are not completed, so that the shell falls back to
+// completing paths. Subcommand and flag names are completed as usual.
+func withCompletion(cmd *cli.Command, args CompleteFunc, flags map[string]CompleteFunc) *cli.Command {
+	defaultComplete := cli.DefaultCompleteWithFlags(cmd)
+	cmd.BashComplete = func(ctx *cli.Context) {
+		// the shell passes the word being completed only if it is a flag, so the last
+		// argument before the completion flag is either a partial flag name, or the
+		// last complete word before the word being completed.
+		var lastArg string
+		if len(os.Args) > 2 {
+			lastArg = os.Args[len(os.Args)-2]
+		}
+		f := findFlag(cmd.Flags, lastArg)
+		if v, ok := f.(cli.DocGenerationFlag); ok && v.TakesValue() {
+			for _, name := range f.Names() {
+				if complete := flags[name]; complete != nil {
+					printCompletions(ctx, complete(ctx))
+					return
+				}
+			}
+			return
+		}
+		if args != nil && (f != nil || !strings.HasPrefix(lastArg, "-")) {
+			printCompletions(ctx, args(ctx))
+			return
+		}
+		defaultComplete(ctx)
+	}
+	return cmd
+}
+
+// setDefaultCompletion sets the shell completion function for commands and their subcommands that do not
+// already have one, so that flag values are not completed with subcommand or flag names.
+func setDefaultCompletion(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if cmd.BashComplete == nil {
+			withCompletion(cmd, nil, nil)
+		}
+		setDefaultCompletion(cmd.Subcommands)
+	}
+}
+
+// findFlag returns the flag with the given name, including leading dashes.
+func findFlag(flags []cli.Flag, arg string) cli.Flag {
+	name, ok := strings.CutPrefix(arg, "-")
+	if !ok {
+		return nil
+	}
+	name = strings.TrimPrefix(name, "-")
+	for _, f := range flags {
+		if slices.Contains(f.Names(), name) {
+			return f
+		}
+	}
+	return nil
+}
+
+func printCompletions(ctx *cli.Context, values []string) {
+	for _, value := range values {
+		fmt.Fprintln(ctx.App.Writer, value)
+	}
+}
+
+// completeCertServices returns the services that certificates can be checked or rotated for.
+func completeCertServices(ctx *cli.Context) []string {
+	return certServices
+}
+
+// completeSnapshotNames returns the names of local etcd snapshots that are not already present in the
+// command's arguments. Snapshots are listed from the snapshot dir if set, or the default snapshot dir
+// within the data-dir.
+func completeSnapshotNames(ctx *cli.Context) []string {
+	dir := ServerConfig.EtcdSnapshotDir
+	if dir == "" {
+		dataDir, err := datadir.Resolve(ServerConfig.DataDir)
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Join(dataDir, "server", "db", "snapshots")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !slices.Contains(ctx.Args().Slice(), entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,54 @@
+package cmds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	EnvComponentContainerd       = "containerd"
+	EnvComponentEtcdSnapshotHook = "etcd-snapshot-hook"
+	EnvComponentHook             = "hook"
+)
+
+var (
+	// envComponents are the components that run as child processes, and can have their own environment.
+	envComponents = []string{EnvComponentContainerd, EnvComponentEtcdSnapshotHook, EnvComponentHook}
+
+	// embeddedComponents run within the main process, and share its environment.
+	embeddedComponents = []string{"cloud-controller-manager", "etcd", "kube-apiserver", "kube-controller-manager", "kube-proxy", "kube-scheduler", "kubelet"}
+
+	ComponentEnvFlag = &cli.StringSliceFlag{
+		Name:        "component-env",
+		Usage:       "(flags) Environment variable to set for a component that runs as a child process, in COMPONENT:NAME=VALUE format. Supported components: " + strings.Join(envComponents, ", "),
+		Destination: &AgentConfig.ComponentEnv,
+	}
+)
+
+// ParseComponentEnv parses environment variables in COMPONENT:NAME=VALUE format, and returns the
+// variables for each component, in NAME=VALUE format. Components that run within the main process
+// are rejected, as they cannot have an environment that differs from the rest of the process.
+func ParseComponentEnv(values []string) (map[string][]string, error) {
+	env := map[string][]string{}
+	for _, value := range values {
+		component, variable, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid component-env %q: must be in COMPONENT:NAME=VALUE format", value)
+		}
+		if slices.Contains(embeddedComponents, component) {
+			return nil, fmt.Errorf("invalid component-env %q: %s runs within the %s process, and uses the environment of the process", value, component, version.Program)
+		}
+		if !slices.Contains(envComponents, component) {
+			return nil, fmt.Errorf("invalid component-env %q: unsupported component %q; must be one of %s", value, component, strings.Join(envComponents, ", "))
+		}
+		if name, _, ok := strings.Cut(variable, "="); !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid component-env %q: must be in COMPONENT:NAME=VALUE format", value)
+		}
+		env[component] = append(env[component], variable)
+	}
+	return env, nil
+}
@@ -11,8 +11,70 @@ var (
 	ConfigFlag = &cli.StringFlag{
 		Name:    "config",
 		Aliases: []string{"c"},
-		Usage:   "(config) Load configuration from `FILE`",
+		Usage:   "(config) Load configuration from `FILE` in YAML, JSON, or TOML format as indicated by its extension, or from an https URL that may be pinned to a checksum with #sha256=<hex>. Remote files are cached locally for use when the URL is unavailable. References to environment variables in values, such as ${VAR} or ${VAR:-default}, are expanded unless " + version.ProgramUpper + "_CONFIG_DISABLE_ENV_EXPANSION=true. Keys in dropins suffixed with + append to, - remove from, or ! replace values set earlier; list values for keys without a suffix are replaced unless " + version.ProgramUpper + "_CONFIG_MERGE_STRATEGY=append",
 		EnvVars: []string{version.ProgramUpper + "_CONFIG_FILE"},
 		Value:   "/etc/rancher/" + version.Program + "/config.yaml",
 	}
 )
+
+const ConfigCommand = "config"
+
+// ConfigMigrate holds CLI values for the config migrate command
+type ConfigMigrate struct {
+	DryRun bool
+}
+
+var ConfigMigrateConfig = ConfigMigrate{}
+
+// ConfigExport holds CLI values for the config export command
+type ConfigExport struct {
+	AsInstall bool
+	Output    string
+}
+
+var ConfigExportConfig = ConfigExport{}
+
+func NewConfigCommands(migrate, export func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:  ConfigCommand,
+		Usage: "Manage the " + version.Program + " config file",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "migrate",
+				Usage:     "Rewrite deprecated flags in the config file and its dropins to their replacement",
+				UsageText: appName + " config migrate [OPTIONS]",
+				Action:    migrate,
+				Flags: []cli.Flag{
+					ConfigFlag,
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Usage:       "Print the migrated config files instead of writing them",
+						Destination: &ConfigMigrateConfig.DryRun,
+					},
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Print the merged config file and its dropins, with secrets omitted",
+				UsageText: appName + " config export [OPTIONS]",
+				Action:    export,
+				Flags: []cli.Flag{
+					ConfigFlag,
+					DataDirFlag,
+					&cli.BoolFlag{
+						Name:        "as-install",
+						Usage:       "Write the minimal config.yaml, registries.yaml, and manifests needed to recreate the configuration of this server from a fresh install. Config values equal to their defaults, and packaged manifests, are omitted",
+						Destination: &ConfigExportConfig.AsInstall,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Aliases:     []string{"o"},
+						Usage:       "Directory to write files to when exporting with --as-install",
+						Value:       version.Program + "-install",
+						Destination: &ConfigExportConfig.Output,
+					},
+				},
+			},
+		},
+	}
+}
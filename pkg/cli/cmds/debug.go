@@ -0,0 +1,69 @@
+package cmds
+
+import (
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const DebugCommand = "debug"
+
+// SetLogLevel holds CLI values for the debug set-log-level command
+type SetLogLevel struct {
+	Component string
+	Level     string
+	Duration  time.Duration
+}
+
+var (
+	SetLogLevelConfig = SetLogLevel{}
+	DebugFlags        = []cli.Flag{
+		DataDirFlag,
+		ServerToken,
+		&cli.StringFlag{
+			Name:        "server",
+			Aliases:     []string{"s"},
+			Usage:       "(cluster) Server to connect to",
+			EnvVars:     []string{version.ProgramUpper + "_URL"},
+			Value:       "https://127.0.0.1:6443",
+			Destination: &ServerConfig.ServerURL,
+		},
+	}
+	SetLogLevelFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "component",
+			Usage:       "Component to adjust: supervisor, kube-apiserver, kube-controller-manager, kube-scheduler, cloud-controller-manager, kubelet, or kube-proxy. Kubernetes components share a single log verbosity, so adjusting one adjusts all of them",
+			Required:    true,
+			Destination: &SetLogLevelConfig.Component,
+		},
+		&cli.StringFlag{
+			Name:        "level",
+			Usage:       "Log verbosity number (0-10) for Kubernetes components, or level name (trace, debug, info, warn, error) for the supervisor",
+			Required:    true,
+			Destination: &SetLogLevelConfig.Level,
+		},
+		&cli.DurationFlag{
+			Name:        "duration",
+			Usage:       "How long to keep the adjusted level before automatically reverting to the original level. At most 24h",
+			Value:       15 * time.Minute,
+			Destination: &SetLogLevelConfig.Duration,
+		},
+	}
+)
+
+func NewDebugCommands(setLogLevel func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:  DebugCommand,
+		Usage: "Debug components of a running server",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "set-log-level",
+				Usage:     "Temporarily adjust the log level of a component embedded in the server, without restarting it. The original level is restored when the duration expires",
+				UsageText: appName + " debug set-log-level [OPTIONS]",
+				Action:    setLogLevel,
+				Flags:     append(DebugFlags, SetLogLevelFlags...),
+			},
+		},
+	}
+}
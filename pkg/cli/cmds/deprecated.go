@@ -0,0 +1,115 @@
+package cmds
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeprecatedFlag maps a deprecated or removed flag to the flag that replaces it.
+type DeprecatedFlag struct {
+	// Name is the name of the deprecated flag.
+	Name string
+	// Replacement is the name of the flag that replaces the deprecated flag. If empty,
+	// the flag has been removed without replacement, and is dropped.
+	Replacement string
+	// Bool indicates that the deprecated flag is a boolean flag, which does not consume
+	// the following arg as its value.
+	Bool bool
+	// Value, if set, converts the value of the deprecated flag to a value for the
+	// replacement flag. If false is returned, the flag is dropped.
+	Value func(value string) (string, bool)
+}
+
+// DeprecatedFlags lists flags that have been deprecated or removed. Deprecated flags found on the
+// command line or in the config file are rewritten to their replacement before the args are parsed,
+// and can be rewritten in the config file with the config migrate command.
+var DeprecatedFlags = []DeprecatedFlag{
+	{Name: "cluster-secret", Replacement: "token"},
+	{Name: "no-deploy", Replacement: "disable"},
+	{Name: "no-flannel", Replacement: "flannel-backend", Bool: true, Value: enabledValue("none")},
+	{Name: "disable-selinux", Bool: true},
+	{Name: "kube-controller-arg", Replacement: "kube-controller-manager-arg"},
+	{Name: "kube-cloud-controller-arg", Replacement: "kube-cloud-controller-manager-arg"},
+}
+
+// enabledValue returns a Value func for a boolean flag that sets the replacement flag
+// to the given value if the deprecated flag is enabled, and drops it otherwise.
+func enabledValue(value string) func(string) (string, bool) {
+	return func(v string) (string, bool) {
+		enabled, err := strconv.ParseBool(v)
+		return value, err == nil && enabled
+	}
+}
+
+// FindDeprecatedFlag returns the deprecated flag with the given name, or nil if the flag is not deprecated.
+func FindDeprecatedFlag(name string) *DeprecatedFlag {
+	for i := range DeprecatedFlags {
+		if DeprecatedFlags[i].Name == name {
+			return &DeprecatedFlags[i]
+		}
+	}
+	return nil
+}
+
+// Migrate returns the value for the replacement flag, given the value of the deprecated flag.
+// False is returned if the flag should be dropped.
+func (f *DeprecatedFlag) Migrate(value string) (string, bool) {
+	if f.Replacement == "" {
+		return "", false
+	}
+	if f.Value != nil {
+		return f.Value(value)
+	}
+	return value, true
+}
+
+// Warning returns a message describing the deprecation.
+func (f *DeprecatedFlag) Warning() string {
+	if f.Replacement == "" {
+		return "Flag --" + f.Name + " has been removed and will be ignored"
+	}
+	return "Flag --" + f.Name + " is deprecated and will be removed in a future release, use --" + f.Replacement + " instead"
+}
+
+// MigrateArgs rewrites any deprecated flags in args to their replacement, logging a warning for each.
+// Flags that have been removed without replacement are dropped. Args following a "--" terminator are not modified.
+func MigrateArgs(args []string) []string {
+	result := make([]string, 0, len(args))
+	warned := map[string]bool{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		f := FindDeprecatedFlag(name)
+		if !strings.HasPrefix(arg, "-") || f == nil {
+			result = append(result, arg)
+			continue
+		}
+		if !warned[name] {
+			warned[name] = true
+			logrus.Warn(f.Warning())
+		}
+		if !hasValue {
+			if f.Bool {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				// leave the missing value for the CLI parser to report
+				if f.Replacement != "" {
+					result = append(result, "--"+f.Replacement)
+				}
+				continue
+			}
+		}
+		if value, ok := f.Migrate(value); ok {
+			result = append(result, "--"+f.Replacement+"="+value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package cmds
+
+import (
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const EtcdCommand = "etcd"
+
+var EtcdFlags = []cli.Flag{
+	DataDirFlag,
+	ServerToken,
+	&cli.StringFlag{
+		Name:        "server",
+		Aliases:     []string{"s"},
+		Usage:       "(cluster) Server to connect to",
+		EnvVars:     []string{version.ProgramUpper + "_URL"},
+		Value:       "https://127.0.0.1:6443",
+		Destination: &ServerConfig.ServerURL,
+	},
+}
+
+func NewEtcdCommands(defrag func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:  EtcdCommand,
+		Usage: "Manage the embedded etcd datastore",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "defrag",
+				Usage:  "Defragment etcd members one at a time, followers first and the leader last, checking member health between each",
+				Action: defrag,
+				Flags:  EtcdFlags,
+			},
+		},
+	}
+}
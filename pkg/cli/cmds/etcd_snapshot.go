@@ -1,8 +1,14 @@
 package cmds
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/urfave/cli/v2"
 )
@@ -52,6 +58,19 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Usage:       "(db) Compress etcd snapshot",
 		Destination: &ServerConfig.EtcdSnapshotCompress,
 	},
+	&cli.StringFlag{
+		Name:        "snapshot-compression",
+		Aliases:     []string{"etcd-snapshot-compression"},
+		Usage:       "(db) Compression format used when etcd snapshot compression is enabled, one of 'zip', 'zstd'",
+		Destination: &ServerConfig.EtcdSnapshotCompression,
+		Value:       "zip",
+	},
+	&cli.IntFlag{
+		Name:        "snapshot-zstd-level",
+		Aliases:     []string{"etcd-snapshot-zstd-level"},
+		Usage:       "(db) Compression level for zstd-compressed etcd snapshot, 1-22. Levels are mapped onto the 4 encoder levels supported by the zstd library (default: 0, zstd default level)",
+		Destination: &ServerConfig.EtcdSnapshotZstdLevel,
+	},
 	&cli.IntFlag{
 		Name:        "snapshot-retention,",
 		Aliases:     []string{"etcd-snapshot-retention"},
@@ -59,6 +78,18 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdSnapshotRetention,
 		Value:       defaultSnapshotRentention,
 	},
+	&cli.StringFlag{
+		Name:        "snapshot-retention-max-age",
+		Aliases:     []string{"etcd-snapshot-retention-max-age"},
+		Usage:       "(db) Retain all snapshots newer than this age, in addition to the retention count. Accepts durations with d or w suffixes, eg. '14d'",
+		Destination: &ServerConfig.EtcdSnapshotMaxAge,
+	},
+	&cli.StringSliceFlag{
+		Name:        "snapshot-retention-tier",
+		Aliases:     []string{"etcd-snapshot-retention-tier"},
+		Usage:       "(db) Retain one snapshot per interval up to the given age, in addition to the retention count, in the form INTERVAL=KEEP. eg. 'hourly=2d', 'daily=30d'",
+		Destination: &ServerConfig.EtcdSnapshotTiers,
+	},
 	&cli.BoolFlag{
 		Name:        "s3",
 		Aliases:     []string{"etcd-s3"},
@@ -149,6 +180,12 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Usage:       "(db) Name of secret in the kube-system namespace used to configure S3, if etcd-s3 is enabled and no other etcd-s3 options are set",
 		Destination: &ServerConfig.EtcdS3ConfigSecret,
 	},
+	&cli.StringSliceFlag{
+		Name:        "s3-target-secret",
+		Aliases:     []string{"etcd-s3-target-secret"},
+		Usage:       "(db) Name of secret in the kube-system namespace used to configure an additional S3 target that snapshots are also uploaded to, in the same format as etcd-s3-config-secret. May be repeated",
+		Destination: &ServerConfig.EtcdS3TargetSecrets,
+	},
 	&cli.BoolFlag{
 		Name:        "s3-insecure",
 		Aliases:     []string{"etcd-s3-insecure"},
@@ -162,9 +199,106 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdS3Timeout,
 		Value:       5 * time.Minute,
 	},
+	&cli.StringFlag{
+		Name:        "s3-kms-key-id",
+		Aliases:     []string{"etcd-s3-kms-key-id"},
+		Usage:       "(db) ID or ARN of the KMS key used to encrypt snapshots with SSE-KMS server-side encryption",
+		Destination: &ServerConfig.EtcdS3KMSKeyID,
+	},
+	&cli.IntFlag{
+		Name:        "s3-part-size",
+		Aliases:     []string{"etcd-s3-part-size"},
+		Usage:       "(db) Part size in MiB for multipart snapshot uploads, 5-5120 (default: selected automatically from the snapshot size)",
+		Destination: &ServerConfig.EtcdS3PartSize,
+	},
+	&cli.IntFlag{
+		Name:        "s3-concurrency",
+		Aliases:     []string{"etcd-s3-concurrency"},
+		Usage:       "(db) Number of parts uploaded concurrently for multipart snapshot uploads (default: 2)",
+		Destination: &ServerConfig.EtcdS3Concurrency,
+	},
+	&cli.IntFlag{
+		Name:        "s3-upload-rate-limit",
+		Aliases:     []string{"etcd-s3-upload-rate-limit"},
+		Usage:       "(db) Maximum rate in KiB/s for snapshot uploads. The S3 timeout is extended by the time required to upload the snapshot at this rate (default: 0, unlimited)",
+		Destination: &ServerConfig.EtcdS3UploadRateLimit,
+	},
+}
+
+// ValidateEtcdSnapshotCompression checks that the etcd snapshot compression format and level are supported.
+func ValidateEtcdSnapshotCompression(format string, level int) error {
+	switch format {
+	case "zip", "zstd":
+	default:
+		return fmt.Errorf("invalid etcd-snapshot-compression %q: must be one of 'zip', 'zstd'", format)
+	}
+	if level < 0 || level > 22 {
+		return fmt.Errorf("invalid etcd-snapshot-zstd-level %d: must be between 1 and 22, or 0 for the default level", level)
+	}
+	return nil
 }
 
-func NewEtcdSnapshotCommands(deleteFunc, listFunc, pruneFunc, saveFunc func(ctx *cli.Context) error) *cli.Command {
+// ValidateEtcdS3Upload checks that the etcd S3 multipart upload part size, concurrency, and rate limit are supported.
+func ValidateEtcdS3Upload(partSize, concurrency, rateLimit int) error {
+	if partSize != 0 && (partSize < 5 || partSize > 5120) {
+		return fmt.Errorf("invalid etcd-s3-part-size %d: must be between 5 and 5120", partSize)
+	}
+	if concurrency < 0 {
+		return fmt.Errorf("invalid etcd-s3-concurrency %d: must not be negative", concurrency)
+	}
+	if rateLimit < 0 {
+		return fmt.Errorf("invalid etcd-s3-upload-rate-limit %d: must not be negative", rateLimit)
+	}
+	return nil
+}
+
+// ValidateEtcdSnapshotWebhook checks that the etcd snapshot webhook URL and payload type are supported.
+func ValidateEtcdSnapshotWebhook(webhookURL, webhookType string) error {
+	switch webhookType {
+	case "generic", "slack":
+	default:
+		return fmt.Errorf("invalid etcd-snapshot-webhook-type %q: must be one of 'generic', 'slack'", webhookType)
+	}
+	if webhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("invalid etcd-snapshot-webhook-url: must be an https URL")
+	}
+	return nil
+}
+
+// ValidateEtcdSnapshotHooks checks that the etcd snapshot hook URLs, timeout, and failure policy are supported.
+// Hooks that are not http or https URLs are commands.
+func ValidateEtcdSnapshotHooks(hooks []string, timeout time.Duration, policy string) error {
+	switch policy {
+	case "fail", "ignore":
+	default:
+		return fmt.Errorf("invalid etcd-snapshot-hook-failure-policy %q: must be one of 'fail', 'ignore'", policy)
+	}
+	if len(hooks) > 0 && timeout <= 0 {
+		return errors.New("invalid etcd-snapshot-hook-timeout: must be greater than 0s")
+	}
+	for _, hook := range hooks {
+		if strings.TrimSpace(hook) == "" {
+			return errors.New("invalid etcd snapshot hook: must not be empty")
+		}
+		u, err := url.Parse(hook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid etcd snapshot hook URL %q: missing host", u.Redacted())
+		}
+		if ip := net.ParseIP(u.Hostname()); u.Scheme == "http" && u.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("invalid etcd snapshot hook URL %q: must be an https URL, or an http URL with a loopback host", u.Redacted())
+		}
+	}
+	return nil
+}
+
+func NewEtcdSnapshotCommands(deleteFunc, listFunc, pruneFunc, saveFunc, restoreFunc, verifyFunc, downloadFunc func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:            EtcdSnapshotCommand,
 		Usage:           "Manage etcd snapshots",
@@ -177,13 +311,13 @@ func NewEtcdSnapshotCommands(deleteFunc, listFunc, pruneFunc, saveFunc func(ctx
 				Action:          saveFunc,
 				Flags:           EtcdSnapshotFlags,
 			},
-			{
+			withCompletion(&cli.Command{
 				Name:            "delete",
 				Usage:           "Delete given snapshot(s)",
 				SkipFlagParsing: false,
 				Action:          deleteFunc,
 				Flags:           EtcdSnapshotFlags,
-			},
+			}, completeSnapshotNames, nil),
 			{
 				Name:            "ls",
 				Aliases:         []string{"list", "l"},
@@ -193,18 +327,70 @@ func NewEtcdSnapshotCommands(deleteFunc, listFunc, pruneFunc, saveFunc func(ctx
 				Flags: append(EtcdSnapshotFlags, &cli.StringFlag{
 					Name:        "output",
 					Aliases:     []string{"o"},
-					Usage:       "(db) List format. Default: standard. Optional: json",
+					Usage:       "(db) List format. Default: table. Optional: json, yaml, summary-json, summary-yaml. The summary formats include size, creation time, compression, checksum, storage location, originating node, and metadata for each snapshot",
 					Destination: &ServerConfig.EtcdListFormat,
 				}),
 			},
 			{
 				Name:            "prune",
-				Usage:           "Remove snapshots that match the name prefix that exceed the configured retention count",
+				Usage:           "Remove snapshots that match the name prefix that exceed the configured retention count. The name prefix may contain glob wildcards, eg. 'etcd-snapshot-server-1-*'. Local snapshots are pruned to the snapshot retention count, and S3 snapshots to the s3 retention count",
 				SkipFlagParsing: false,
 				Action:          pruneFunc,
 				Flags:           EtcdSnapshotFlags,
 			},
+			withCompletion(&cli.Command{
+				Name:            "restore",
+				Usage:           "Reset the cluster and restore etcd from a snapshot name, local path, or s3://bucket/key URI. The server must be stopped first.",
+				UsageText:       appName + " etcd-snapshot restore [OPTIONS] SNAPSHOT",
+				SkipFlagParsing: false,
+				Action:          restoreFunc,
+				Flags: append(ServerFlags, &cli.BoolFlag{
+					Name:        "force",
+					Usage:       "(db) Restore the snapshot even if it is not compatible with this server; equivalent to --cluster-reset-restore-force",
+					Destination: &ServerConfig.ClusterResetRestoreForce,
+				}),
+			}, completeSnapshotNames, nil),
+			withCompletion(&cli.Command{
+				Name:            "verify",
+				Usage:           "Verify the checksum and database consistency of given snapshot(s) to confirm that they can be restored",
+				SkipFlagParsing: false,
+				Action:          verifyFunc,
+				Flags:           EtcdSnapshotFlags,
+			}, completeSnapshotNames, nil),
+			{
+				Name:            "download",
+				Usage:           "Download a snapshot from S3 by name or s3://bucket/key URI, and verify its checksum. The server does not need to be running.",
+				UsageText:       appName + " etcd-snapshot download [OPTIONS] SNAPSHOT",
+				SkipFlagParsing: false,
+				Action:          downloadFunc,
+				Flags: append(EtcdSnapshotFlags, &cli.StringFlag{
+					Name:        "destination",
+					Aliases:     []string{"dest"},
+					Usage:       "(db) Directory to download the snapshot to (default: ${data-dir}/server/db/snapshots)",
+					Destination: &ServerConfig.EtcdSnapshotDestination,
+				}),
+			},
 		},
 		Flags: EtcdSnapshotFlags,
 	}
 }
+
+// SetEtcdS3URI enables S3 and sets the bucket and folder from a s3://bucket/key URI,
+// returning the snapshot name from the last element of the key.
+func SetEtcdS3URI(cfg *Server, location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid S3 snapshot URI")
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", fmt.Errorf("invalid S3 snapshot URI %s: must be in the format s3://bucket/key", location)
+	}
+	cfg.EtcdS3 = true
+	cfg.EtcdS3BucketName = u.Host
+	cfg.EtcdS3Folder = path.Dir(key)
+	if cfg.EtcdS3Folder == "." {
+		cfg.EtcdS3Folder = ""
+	}
+	return path.Base(key), nil
+}
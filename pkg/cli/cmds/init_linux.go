@@ -8,12 +8,19 @@ import (
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/moby/sys/userns"
 	"github.com/rootless-containers/rootlesskit/pkg/parent/cgrouputil"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 // EvacuateCgroup2 will handle evacuating the root cgroup in order to enable subtree_control,
-// if running as pid 1 without rootless support.
+// if running as pid 1 without rootless support. In containerized mode, the root cgroup is left alone if the
+// cgroup filesystem is not writable, as it is managed by the container runtime.
 func EvacuateCgroup2() error {
 	if os.Getpid() == 1 && !userns.RunningInUserNS() {
+		if AgentConfig.Containerized && unix.Access("/sys/fs/cgroup", unix.W_OK) != nil {
+			logrus.Info("Cgroup filesystem is not writable; not evacuating root cgroup in containerized mode")
+			return nil
+		}
 		// The root cgroup has to be empty to enable subtree_control, so evacuate it by placing
 		// ourselves in the init cgroup.
 		if err := cgrouputil.EvacuateCgroup2("init"); err != nil {
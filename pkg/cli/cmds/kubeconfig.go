@@ -0,0 +1,79 @@
+package cmds
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const KubeconfigCommand = "kubeconfig"
+
+// Kubeconfig holds CLI values for the kubeconfig subcommands
+type Kubeconfig struct {
+	Kubeconfig string
+	Namespace  string
+	Role       string
+	Name       string
+	ServerURL  string
+	Output     string
+	TTL        time.Duration
+}
+
+var (
+	KubeconfigConfig = Kubeconfig{}
+	KubeconfigFlags  = []cli.Flag{
+		DataDirFlag,
+		&cli.StringFlag{
+			Name:        "kubeconfig",
+			Usage:       "(cluster) Admin kubeconfig used to create the service account and role binding",
+			EnvVars:     []string{"KUBECONFIG"},
+			Destination: &KubeconfigConfig.Kubeconfig,
+		},
+	}
+)
+
+func NewKubeconfigCommands(generateFunc func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:            KubeconfigCommand,
+		Usage:           "Manage kubeconfig files",
+		SkipFlagParsing: false,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "generate",
+				Usage:     "Create a service account bound to a role in a namespace, and print a kubeconfig that authenticates as it",
+				UsageText: appName + " kubeconfig generate --namespace NAMESPACE [OPTIONS]",
+				Flags: append(KubeconfigFlags, &cli.StringFlag{
+					Name:        "namespace",
+					Aliases:     []string{"n"},
+					Usage:       "Namespace that the kubeconfig grants access to. The namespace must exist",
+					Destination: &KubeconfigConfig.Namespace,
+				}, &cli.StringFlag{
+					Name:        "role",
+					Usage:       "ClusterRole to bind to the service account within the namespace, such as 'admin', 'edit', or 'view'",
+					Value:       "edit",
+					Destination: &KubeconfigConfig.Role,
+				}, &cli.StringFlag{
+					Name:        "name",
+					Usage:       "Name of the service account and role binding (default: kubeconfig-${role})",
+					Destination: &KubeconfigConfig.Name,
+				}, &cli.DurationFlag{
+					Name:        "ttl",
+					Usage:       "The duration before the kubeconfig token expires (e.g. 1h, 720h). If set to '0', a token that does not expire is created, and is valid until the service account is deleted",
+					Destination: &KubeconfigConfig.TTL,
+				}, &cli.StringFlag{
+					Name:        "server",
+					Aliases:     []string{"s"},
+					Usage:       "(cluster) Server URL to use in the kubeconfig (default: the server in the admin kubeconfig)",
+					Destination: &KubeconfigConfig.ServerURL,
+				}, &cli.StringFlag{
+					Name:        "output",
+					Aliases:     []string{"o"},
+					Usage:       "File to write the kubeconfig to (default: stdout)",
+					Destination: &KubeconfigConfig.Output,
+				}),
+				SkipFlagParsing: false,
+				Action:          generateFunc,
+			},
+		},
+	}
+}
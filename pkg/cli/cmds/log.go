@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/logmetrics"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/grpc/grpclog"
@@ -16,6 +17,7 @@ type Log struct {
 	VModule         string
 	LogFile         string
 	AlsoLogToStderr bool
+	LogMetrics      bool
 }
 
 var (
@@ -42,6 +44,11 @@ var (
 		Usage:       "(logging) Log to standard error as well as file (if set)",
 		Destination: &LogConfig.AlsoLogToStderr,
 	}
+	LogMetrics = &cli.BoolFlag{
+		Name:        "log-metrics",
+		Usage:       "(logging) Count known warnings and errors logged by embedded components, such as etcd slow fdatasync and kubelet PLEG health, in metrics exposed with --supervisor-metrics",
+		Destination: &LogConfig.LogMetrics,
+	}
 
 	logSetupOnce sync.Once
 )
@@ -78,4 +85,9 @@ func setupLogging() {
 	if Debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
+	if LogConfig.LogMetrics {
+		if err := logmetrics.CaptureStderr(logmetrics.PrometheusBackend{}); err != nil {
+			logrus.Warnf("Failed to capture log output for metrics: %v", err)
+		}
+	}
 }
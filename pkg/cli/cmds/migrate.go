@@ -0,0 +1,51 @@
+package cmds
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+const MigrateCommand = "migrate"
+
+// Migrate holds CLI values for the migrate subcommands
+type Migrate struct {
+	ClusterDomain string
+	ServiceCIDR   string
+}
+
+var MigrateConfig = Migrate{}
+
+func NewMigrateCommands(clusterDomainFunc, serviceCIDRFunc func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:            MigrateCommand,
+		Usage:           "Migrate cluster configuration that cannot be changed after install",
+		SkipFlagParsing: false,
+		Subcommands: []*cli.Command{
+			{
+				Name:            "cluster-domain",
+				Usage:           "Change the cluster domain used for Service DNS names. The server must be stopped first.",
+				UsageText:       appName + " migrate cluster-domain [OPTIONS] --to DOMAIN",
+				SkipFlagParsing: false,
+				Action:          clusterDomainFunc,
+				Flags: append(ServerFlags, &cli.StringFlag{
+					Name:        "to",
+					Usage:       "New cluster domain, eg. 'newdomain.local'",
+					Destination: &MigrateConfig.ClusterDomain,
+					Required:    true,
+				}),
+			},
+			{
+				Name:            "service-cidr",
+				Usage:           "Expand the service CIDR to a larger range that contains the current range. The server must be stopped first.",
+				UsageText:       appName + " migrate service-cidr [OPTIONS] --to CIDR",
+				SkipFlagParsing: false,
+				Action:          serviceCIDRFunc,
+				Flags: append(ServerFlags, &cli.StringFlag{
+					Name:        "to",
+					Usage:       "New service CIDR(s), one per IP family in the same order as service-cidr, eg. '10.40.0.0/13'",
+					Destination: &MigrateConfig.ServiceCIDR,
+					Required:    true,
+				}),
+			},
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package cmds
+
+import (
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const NodeCommand = "node"
+
+// Node holds CLI values for the node subcommands
+type Node struct {
+	Force              bool
+	IgnoreDaemonSets   bool
+	DeleteEmptyDirData bool
+	DisableEviction    bool
+	GracePeriod        int
+	PodSelector        string
+	Timeout            time.Duration
+}
+
+var (
+	NodeConfig = Node{}
+	NodeFlags  = []cli.Flag{
+		DataDirFlag,
+		ServerToken,
+		&cli.StringFlag{
+			Name:        "server",
+			Aliases:     []string{"s"},
+			Usage:       "(cluster) Server to connect to",
+			EnvVars:     []string{version.ProgramUpper + "_URL"},
+			Value:       "https://127.0.0.1:6443",
+			Destination: &ServerConfig.ServerURL,
+		},
+	}
+	NodeDrainFlags = []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "force",
+			Usage:       "Continue even if there are pods that do not declare a controller",
+			Destination: &NodeConfig.Force,
+		},
+		&cli.BoolFlag{
+			Name:        "ignore-daemonsets",
+			Usage:       "Ignore DaemonSet-managed pods",
+			Value:       true,
+			Destination: &NodeConfig.IgnoreDaemonSets,
+		},
+		&cli.BoolFlag{
+			Name:        "delete-emptydir-data",
+			Usage:       "Continue even if there are pods using emptyDir (local data that will be deleted when the node is drained)",
+			Destination: &NodeConfig.DeleteEmptyDirData,
+		},
+		&cli.BoolFlag{
+			Name:        "disable-eviction",
+			Usage:       "Force drain to use delete, even if eviction is supported. This will bypass checking PodDisruptionBudgets, use with caution",
+			Destination: &NodeConfig.DisableEviction,
+		},
+		&cli.IntFlag{
+			Name:        "grace-period",
+			Usage:       "Period of time in seconds given to each pod to terminate gracefully. If negative, the default value specified in the pod will be used",
+			Value:       -1,
+			Destination: &NodeConfig.GracePeriod,
+		},
+		&cli.StringFlag{
+			Name:        "pod-selector",
+			Usage:       "Label selector to filter pods on the node",
+			Destination: &NodeConfig.PodSelector,
+		},
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Usage:       "The length of time to wait before giving up on draining the node. If zero, the server's default of 5 minutes is used",
+			Value:       5 * time.Minute,
+			Destination: &NodeConfig.Timeout,
+		},
+	}
+)
+
+func NewNodeCommands(cordon, uncordon, drain, promote, approve func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:  NodeCommand,
+		Usage: "Cordon, uncordon, drain, promote, or approve nodes via the server, without requiring an admin kubeconfig",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "cordon",
+				Usage:     "Mark node as unschedulable",
+				UsageText: appName + " node cordon [OPTIONS] NODE",
+				Action:    cordon,
+				Flags:     NodeFlags,
+			},
+			{
+				Name:      "uncordon",
+				Usage:     "Mark node as schedulable",
+				UsageText: appName + " node uncordon [OPTIONS] NODE",
+				Action:    uncordon,
+				Flags:     NodeFlags,
+			},
+			{
+				Name:      "drain",
+				Usage:     "Cordon node and evict all pods, respecting PodDisruptionBudgets",
+				UsageText: appName + " node drain [OPTIONS] NODE",
+				Action:    drain,
+				Flags:     append(NodeFlags, NodeDrainFlags...),
+			},
+			{
+				Name:      "promote",
+				Usage:     "Promote a control-plane standby server, so that it restarts and runs the control-plane components. Must be sent to the standby server",
+				UsageText: appName + " node promote [OPTIONS]",
+				Action:    promote,
+				Flags:     NodeFlags,
+			},
+			{
+				Name:      "approve",
+				Usage:     "Approve a node that is pending approval to join the cluster. If no node is given, list the nodes that are pending approval",
+				UsageText: appName + " node approve [OPTIONS] [NODE]",
+				Action:    approve,
+				Flags:     NodeFlags,
+			},
+		},
+	}
+}
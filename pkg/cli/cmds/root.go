@@ -51,6 +51,7 @@ func NewApp() *cli.App {
 }
 
 func MustRun(app *cli.App, args []string) {
+	setDefaultCompletion(app.Commands)
 	if err := app.Run(args); err != nil && !errors.Is(err, context.Canceled) {
 		logrus.Fatal(err)
 	}
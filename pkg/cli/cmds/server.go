@@ -2,9 +2,12 @@ package cmds
 
 import (
 	"context"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/secretstore"
+	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/urfave/cli/v2"
 )
@@ -27,13 +30,30 @@ type Server struct {
 	ClusterCIDR          cli.StringSlice
 	AgentToken           string
 	AgentTokenFile       string
+	AgentTokenSource     string
+	NodeJoinApproval     bool
+	RequireTPMKeyPinning bool
+	CloudIdentity        cli.StringSlice
+	CloudAudience        string
+	TokenAuditLog        string
+	TokenAuditEvents     bool
+	ManagementAudit      bool
+	ManagementEvents     bool
+	AuthFailures         int
+	AuthWindow           time.Duration
+	AuthLockout          time.Duration
 	Token                string
 	TokenFile            string
+	TokenSource          string
+	TokenRefresh         time.Duration
 	ClusterSecret        string
 	ServiceCIDR          cli.StringSlice
 	ServiceNodePortRange string
 	ClusterDNS           cli.StringSlice
 	ClusterDomain        string
+	AgentDNSSearch       cli.StringSlice
+	AgentDNSSearchPolicy string
+	HostOverrides        cli.StringSlice
 	// The port which kubectl clients can access k8s
 	HTTPSPort int
 	// The port which custom k3s API runs on
@@ -41,11 +61,16 @@ type Server struct {
 	// The port which kube-apiserver runs on
 	APIServerPort            int
 	APIServerBindAddress     string
+	APIServerSANFile         string
 	DataDir                  string
 	DisableAgent             bool
+	FaultInjection           bool
 	KubeConfigOutput         string
 	KubeConfigMode           string
 	KubeConfigGroup          string
+	KubeConfigComponents     bool
+	PermissionsAuditInterval time.Duration
+	PermissionsAuditAction   string
 	HelmJobImage             string
 	TLSSan                   cli.StringSlice
 	TLSSanSecurity           bool
@@ -55,22 +80,39 @@ type Server struct {
 	ExtraControllerArgs      cli.StringSlice
 	ExtraCloudControllerArgs cli.StringSlice
 	ExtraHelmArgs            cli.StringSlice
+	ComponentNodeSelectors   cli.StringSlice
+	ComponentTolerations     cli.StringSlice
 	Rootless                 bool
 	DatastoreEndpoint        string
 	DatastoreCAFile          string
 	DatastoreCertFile        string
 	DatastoreKeyFile         string
+	DatastoreMaxIdleConns    int
+	DatastoreMaxOpenConns    int
+	DatastoreConnMaxLifetime time.Duration
+	DatastoreConnMaxIdleTime time.Duration
+	DatastoreRetryTimeout    time.Duration
+	DatastoreSlowSQL         time.Duration
+	DatastoreSlowSQLWarning  time.Duration
 	KineTLS                  bool
 	AdvertiseIP              string
 	AdvertisePort            int
+	LBHealthStrictness       string
 	DisableScheduler         bool
 	ServerURL                string
 	FlannelBackend           string
 	FlannelIPv6Masq          bool
 	FlannelExternalIP        bool
+	MixedOS                  bool
 	EgressSelectorMode       string
+	EgressSelectorConfigFile string
+	KonnectivitySocket       string
+	IPAMWebhookURL           string
+	StickyPodCIDRs           bool
+	RouteExportTarget        string
 	DefaultLocalStoragePath  string
 	DisableCCM               bool
+	CloudControllerConfig    string
 	DisableNPC               bool
 	DisableHelmController    bool
 	DisableKubeProxy         bool
@@ -81,6 +123,8 @@ type Server struct {
 	ClusterInit              bool
 	ClusterReset             bool
 	ClusterResetRestorePath  string
+	ClusterResetRestoreForce bool
+	ResetBackupRetention     int
 	EncryptSecrets           bool
 	EncryptForce             bool
 	EncryptOutput            string
@@ -89,15 +133,55 @@ type Server struct {
 	SystemDefaultRegistry    string
 	StartupHooks             []StartupHook
 	SupervisorMetrics        bool
+	APIServerWatchCacheSizes cli.StringSlice
+	WatchCacheReportInterval time.Duration
+	ListPageSize             int64
+	GuardrailProfile         string
+	TelemetryEndpoint        string
+	TelemetryInterval        time.Duration
+	GitBackupRemote          string
+	GitBackupBranch          string
+	GitBackupPath            string
+	GitBackupRecipients      cli.StringSlice
+	GitBackupSSHKey          string
+	GitBackupInterval        time.Duration
+	CoreDNSAutoscaler        string
+	PreferLocalImages        bool
+	ImageDigestAllowlist     string
+	CPUPressureThreshold     float64
+	CPUPressureMaxDelay      time.Duration
 	EtcdSnapshotName         string
 	EtcdDisableSnapshots     bool
+	EtcdDisableAlarmRecovery bool
+	EtcdDisableLeaderMove    bool
+	EtcdDefragCron           string
 	EtcdExposeMetrics        bool
+	EtcdMetricsProxy         bool
+	EtcdWitness              bool
+	ControlPlaneStandby      bool
+	StandbyPromoteTimeout    time.Duration
+	EtcdMaxLearners          int
+	EtcdLearnerMaxLag        uint64
+	EtcdLearnerTimeout       time.Duration
+	EtcdDowngradeVersion     string
 	EtcdSnapshotDir          string
 	EtcdSnapshotCron         string
 	EtcdSnapshotReconcile    time.Duration
 	EtcdSnapshotRetention    int
+	EtcdSnapshotMaxAge       string
+	EtcdSnapshotTiers        cli.StringSlice
+	EtcdSnapshotSchedules    cli.StringSlice
 	EtcdSnapshotCompress     bool
+	EtcdSnapshotCompression  string
+	EtcdSnapshotZstdLevel    int
+	EtcdSnapshotWebhookURL   string
+	EtcdSnapshotWebhookType  string
+	EtcdSnapshotPreHooks     cli.StringSlice
+	EtcdSnapshotPostHooks    cli.StringSlice
+	EtcdSnapshotHookTimeout  time.Duration
+	EtcdSnapshotHookPolicy   string
 	EtcdListFormat           string
+	EtcdSnapshotDestination  string
 	EtcdS3                   bool
 	EtcdS3Endpoint           string
 	EtcdS3EndpointCA         string
@@ -112,8 +196,15 @@ type Server struct {
 	EtcdS3Folder             string
 	EtcdS3Proxy              string
 	EtcdS3ConfigSecret       string
+	EtcdS3TargetSecrets      cli.StringSlice
 	EtcdS3Timeout            time.Duration
 	EtcdS3Insecure           bool
+	EtcdS3KMSKeyID           string
+	EtcdS3PartSize           int
+	EtcdS3Concurrency        int
+	EtcdS3UploadRateLimit    int
+	EtcdRemoteTarget         string
+	EtcdRemoteRetention      int
 	ServiceLBNamespace       string
 }
 
@@ -180,6 +271,30 @@ var (
 		Usage:       "(flags) Customized flag for kube-controller-manager process",
 		Destination: &ServerConfig.ExtraControllerArgs,
 	}
+	DatastoreEndpointFlag = &cli.StringFlag{
+		Name:        "datastore-endpoint",
+		Usage:       "(db) Specify etcd, NATS, MySQL, Postgres, or SQLite (default) data source name. Use dns+srv://<domain> to discover etcd endpoints from DNS SRV records",
+		Destination: &ServerConfig.DatastoreEndpoint,
+		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_ENDPOINT"},
+	}
+	DatastoreCAFileFlag = &cli.StringFlag{
+		Name:        "datastore-cafile",
+		Usage:       "(db) TLS Certificate Authority file used to secure datastore backend communication",
+		Destination: &ServerConfig.DatastoreCAFile,
+		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_CAFILE"},
+	}
+	DatastoreCertFileFlag = &cli.StringFlag{
+		Name:        "datastore-certfile",
+		Usage:       "(db) TLS certification file used to secure datastore backend communication",
+		Destination: &ServerConfig.DatastoreCertFile,
+		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_CERTFILE"},
+	}
+	DatastoreKeyFileFlag = &cli.StringFlag{
+		Name:        "datastore-keyfile",
+		Usage:       "(db) TLS key file used to secure datastore backend communication",
+		Destination: &ServerConfig.DatastoreKeyFile,
+		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_KEYFILE"},
+	}
 	ExtraHelmArgs = &cli.StringSliceFlag{
 		Name:        "helm-controller-arg",
 		Usage:       "(flags) Customized flag for helm-controller process",
@@ -194,6 +309,7 @@ var ServerFlags = []cli.Flag{
 	VModule,
 	LogFile,
 	AlsoLogToStderr,
+	LogMetrics,
 	BindAddressFlag,
 	&cli.IntFlag{
 		Name:        "https-listen-port",
@@ -232,6 +348,12 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(listener) Port that apiserver uses to advertise to members of the cluster (default: https-listen-port)",
 		Destination: &ServerConfig.AdvertisePort,
 	},
+	&cli.StringFlag{
+		Name:        "lb-health-strictness",
+		Usage:       "(listener) Components that must be healthy for the load-balancer readiness endpoint to report ready, one of 'apiserver', 'datastore', 'all'",
+		Destination: &ServerConfig.LBHealthStrictness,
+		Value:       "datastore",
+	},
 	&cli.StringSliceFlag{
 		Name:        "tls-san",
 		Usage:       "(listener) Add additional hostnames or IPv4/IPv6 addresses as Subject Alternative Names on the server TLS cert",
@@ -243,12 +365,33 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.TLSSanSecurity,
 		Value:       true,
 	},
+	&cli.StringFlag{
+		Name:        "apiserver-san-file",
+		Usage:       "(listener) Path to a file listing additional hostnames or IPv4/IPv6 addresses, one per line, to add alongside the built-in kubernetes.default names as Subject Alternative Names on the apiserver TLS cert. {cluster-domain} is replaced with the cluster domain. The kube-apiserver serving cert is re-issued when the file changes",
+		Destination: &ServerConfig.APIServerSANFile,
+	},
 	DataDirFlag,
 	ClusterCIDR,
 	ServiceCIDR,
 	ServiceNodePortRange,
 	ClusterDNS,
 	ClusterDomain,
+	&cli.StringSliceFlag{
+		Name:        "agent-dns-search",
+		Usage:       "(networking) Search domain to add to the resolv.conf used by the kubelet on all nodes, for pods using the host's DNS configuration and as upstream search domains for cluster DNS",
+		Destination: &ServerConfig.AgentDNSSearch,
+	},
+	&cli.StringFlag{
+		Name:        "agent-dns-search-policy",
+		Usage:       "(networking) How agent-dns-search domains are combined with the host's search domains (valid values: 'append', 'replace'). 'replace' with no agent-dns-search domains removes the host's search domains",
+		Destination: &ServerConfig.AgentDNSSearchPolicy,
+		Value:       "append",
+	},
+	&cli.StringSliceFlag{
+		Name:        "host-override",
+		Usage:       "(networking) Resolve a hostname to an address for all pods using cluster DNS, in the format <hostname>=<ip>",
+		Destination: &ServerConfig.HostOverrides,
+	},
 	&cli.StringFlag{
 		Name:        "flannel-backend",
 		Usage:       "(networking) Backend (valid values: 'none', 'vxlan', 'host-gw', 'wireguard-native'",
@@ -265,12 +408,42 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(networking) Use node external IP addresses for Flannel traffic",
 		Destination: &ServerConfig.FlannelExternalIP,
 	},
+	&cli.BoolFlag{
+		Name:        "mixed-os",
+		Usage:       "(networking) Coordinate flannel, kube-proxy and packaged component settings for clusters with both Linux and Windows nodes",
+		Destination: &ServerConfig.MixedOS,
+	},
 	&cli.StringFlag{
 		Name:        "egress-selector-mode",
-		Usage:       "(networking) One of 'agent', 'cluster', 'pod', 'disabled'",
+		Usage:       "(networking) One of 'agent', 'cluster', 'pod', 'konnectivity', 'disabled'. The 'konnectivity' mode requires a user-provided konnectivity-server",
 		Destination: &ServerConfig.EgressSelectorMode,
 		Value:       "agent",
 	},
+	&cli.StringFlag{
+		Name:        "egress-selector-config-file",
+		Usage:       "(networking) Path to an EgressSelectorConfiguration file whose egress selections replace or are added to the generated apiserver egress selector configuration. The cluster selection may only be replaced when egress-selector-mode is 'disabled'",
+		Destination: &ServerConfig.EgressSelectorConfigFile,
+	},
+	&cli.StringFlag{
+		Name:        "konnectivity-uds-path",
+		Usage:       "(networking) Path to the unix socket of a user-provided konnectivity-server that proxies apiserver connections to nodes and pods when egress-selector-mode is 'konnectivity'. The konnectivity-server and konnectivity-agent are not run by " + version.Program + " and must be deployed separately (default: ${data-dir}/server/konnectivity-server/konnectivity-server.socket)",
+		Destination: &ServerConfig.KonnectivitySocket,
+	},
+	&cli.StringFlag{
+		Name:        "ipam-webhook-url",
+		Usage:       "(networking) HTTPS URL of an external IPAM webhook that allocates node IP addresses and pod CIDRs when nodes are registered. Disables pod CIDR allocation by the controller-manager",
+		Destination: &ServerConfig.IPAMWebhookURL,
+	},
+	&cli.BoolFlag{
+		Name:        "sticky-pod-cidrs",
+		Usage:       "(networking) Allocate the same pod CIDRs to nodes that are reinstalled or re-registered on the same machine, identified by system UUID. Disables pod CIDR allocation by the controller-manager",
+		Destination: &ServerConfig.StickyPodCIDRs,
+	},
+	&cli.StringFlag{
+		Name:        "route-export-target",
+		Usage:       "(networking) Publish each node's pod CIDRs and next-hop addresses for external routers, to allow native routing of pod traffic with flannel host-gw across L3 boundaries. Either 'configmap' to write routes to a ConfigMap in kube-system, an https URL to POST routes to, or a BGP peer to announce routes to, as 'bgp://<peer>[:port]?local-as=<asn>&peer-as=<asn>&router-id=<ipv4>'",
+		Destination: &ServerConfig.RouteExportTarget,
+	},
 	&cli.StringFlag{
 		Name:        "servicelb-namespace",
 		Usage:       "(networking) Namespace of the pods for the servicelb component",
@@ -296,6 +469,23 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.KubeConfigGroup,
 		EnvVars:     []string{version.ProgramUpper + "_KUBECONFIG_GROUP"},
 	},
+	&cli.BoolFlag{
+		Name:        "write-kubeconfig-components",
+		Usage:       "(client) Also apply the kubeconfig mode and group to the kubeconfigs, client certificates, and keys generated for the supervisor and embedded components, and allow the group to traverse the data directory",
+		Destination: &ServerConfig.KubeConfigComponents,
+	},
+	&cli.DurationFlag{
+		Name:        "permissions-audit-interval",
+		Usage:       "(data) Interval at which to audit the permissions and ownership of certificates, credentials, and generated configs under the data directory against CIS expectations. 0 disables auditing",
+		Destination: &ServerConfig.PermissionsAuditInterval,
+		Value:       time.Hour,
+	},
+	&cli.StringFlag{
+		Name:        "permissions-audit-action",
+		Usage:       "(data) Action to take when the permissions audit detects drift, one of 'warn', 'fix'",
+		Destination: &ServerConfig.PermissionsAuditAction,
+		Value:       "warn",
+	},
 	&cli.StringFlag{
 		Name:        "helm-job-image",
 		Usage:       "(helm) (deprecated) Default image to use for helm jobs. Use --helm-controller-arg=default-job-image instead",
@@ -308,6 +498,12 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.TokenFile,
 		EnvVars:     []string{version.ProgramUpper + "_TOKEN_FILE"},
 	},
+	&cli.StringFlag{
+		Name:        "token-source",
+		Usage:       "(cluster) Secret store reference to read the token from: vault://PATH[#KEY], aws-sm://SECRET[#KEY], gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION], or azure-kv://VAULT/SECRET[/VERSION]",
+		Destination: &ServerConfig.TokenSource,
+		EnvVars:     []string{version.ProgramUpper + "_TOKEN_SOURCE"},
+	},
 	&cli.StringFlag{
 		Name:        "agent-token",
 		Usage:       "(cluster) Shared secret used to join agents to the cluster, but not servers",
@@ -320,13 +516,86 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.AgentTokenFile,
 		EnvVars:     []string{version.ProgramUpper + "_AGENT_TOKEN_FILE"},
 	},
+	&cli.StringFlag{
+		Name:        "agent-token-source",
+		Usage:       "(cluster) Secret store reference to read the agent secret from, in the same format as --token-source",
+		Destination: &ServerConfig.AgentTokenSource,
+		EnvVars:     []string{version.ProgramUpper + "_AGENT_TOKEN_SOURCE"},
+	},
+	&cli.DurationFlag{
+		Name:        "token-source-refresh",
+		Usage:       "(cluster) Interval at which tokens are read again from the secret store",
+		Value:       secretstore.DefaultRefreshInterval,
+		Destination: &ServerConfig.TokenRefresh,
+	},
+	&cli.BoolFlag{
+		Name:        "node-join-approval",
+		Usage:       "(cluster) Hold new nodes in a pending state, without issuing kubelet certificates, until they are approved with '" + version.Program + " node approve'",
+		Destination: &ServerConfig.NodeJoinApproval,
+	},
+	&cli.BoolFlag{
+		Name:        "require-tpm-key-pinning",
+		Usage:       "(cluster) Reject certificate requests from agents that do not sign them with a TPM-resident key, using --tpm-key-pinning. The key is trusted on first use and is not attested",
+		Destination: &ServerConfig.RequireTPMKeyPinning,
+	},
+	&cli.StringSliceFlag{
+		Name:        "cloud-identity-allow",
+		Usage:       "(cluster) Allow agents with a matching cloud identity to join using --cloud-identity, in PROVIDER:PATTERN format. Patterns match the caller ARN for aws (aws:arn:aws:sts::ACCOUNT:assumed-role/ROLE/*), the service account email for gcp (gcp:*@PROJECT.iam.gserviceaccount.com), or TENANT/OBJECT IDs for azure (azure:TENANT/*)",
+		Destination: &ServerConfig.CloudIdentity,
+	},
+	&cli.StringFlag{
+		Name:        "cloud-identity-audience",
+		Usage:       "(cluster) Required audience of gcp and azure identity tokens used with --cloud-identity-allow. Defaults to an audience derived from the cluster CA hash; required for azure, as the application ID URI of an app registration unique to this cluster",
+		Destination: &ServerConfig.CloudAudience,
+	},
+	&cli.StringFlag{
+		Name:        "token-audit-log",
+		Usage:       "(cluster) Record token authentication attempts at the supervisor to a JSON log file, with the token ID, source IP, requested roles, and node name",
+		Destination: &ServerConfig.TokenAuditLog,
+	},
+	&cli.BoolFlag{
+		Name:        "token-audit-events",
+		Usage:       "(cluster) Record token authentication attempts at the supervisor as Kubernetes Events on the requesting Node, or on the bootstrap token Secret",
+		Destination: &ServerConfig.TokenAuditEvents,
+	},
+	&cli.BoolFlag{
+		Name:        "management-audit",
+		Usage:       "(cluster) Record state-changing management commands, such as token, certificate, secrets-encrypt, and etcd-snapshot operations, with the authenticated caller identity, to a signed append-only log under the data-dir",
+		Destination: &ServerConfig.ManagementAudit,
+	},
+	&cli.BoolFlag{
+		Name:        "management-audit-events",
+		Usage:       "(cluster) Record management commands in the management audit log as Kubernetes Events on the server's Node. Requires --management-audit",
+		Destination: &ServerConfig.ManagementEvents,
+	},
+	&cli.IntFlag{
+		Name:        "auth-failure-limit",
+		Usage:       "(cluster) Number of failed token or node password authentication attempts at the supervisor, within auth-failure-window, after which the client address is temporarily locked out. Requests that authenticate successfully are never rejected. 0 disables lockout",
+		Destination: &ServerConfig.AuthFailures,
+	},
+	&cli.DurationFlag{
+		Name:        "auth-failure-window",
+		Usage:       "(cluster) Window within which failed authentication attempts at the supervisor are counted towards auth-failure-limit",
+		Value:       time.Minute,
+		Destination: &ServerConfig.AuthWindow,
+	},
+	&cli.DurationFlag{
+		Name:        "auth-lockout-duration",
+		Usage:       "(cluster) Time that a client address is locked out of the supervisor after reaching auth-failure-limit. Failed authentication attempts from locked out clients are rejected with 429 Too Many Requests",
+		Value:       5 * time.Minute,
+		Destination: &ServerConfig.AuthLockout,
+	},
 	&cli.StringFlag{
 		Name:        "server",
 		Aliases:     []string{"s"},
-		Usage:       "(cluster) Server to connect to, used to join a cluster",
+		Usage:       "(cluster) Server to connect to, used to join a cluster. Use dns+https://NAME[:PORT] to discover servers from _" + version.Program + "._tcp.NAME SRV records, or from NAME A/AAAA records",
 		EnvVars:     []string{version.ProgramUpper + "_URL"},
 		Destination: &ServerConfig.ServerURL,
 	},
+	ServerRetryLimitFlag,
+	ServerRetryIntervalFlag,
+	ServerRetryMaxIntervalFlag,
+	ServerRetryJitterFlag,
 	&cli.BoolFlag{
 		Name:        "cluster-init",
 		Usage:       "(cluster) Initialize a new cluster using embedded Etcd",
@@ -341,9 +610,20 @@ var ServerFlags = []cli.Flag{
 	},
 	&cli.StringFlag{
 		Name:        "cluster-reset-restore-path",
-		Usage:       "(db) Path to snapshot file to be restored",
+		Usage:       "(db) Path to snapshot file to be restored, or S3 URI in the format s3://bucket/key to download and verify the snapshot using the configured S3 credentials",
 		Destination: &ServerConfig.ClusterResetRestorePath,
 	},
+	&cli.BoolFlag{
+		Name:        "cluster-reset-restore-force",
+		Usage:       "(db) Restore the snapshot even if the cluster metadata recorded in the snapshot indicates that it is not compatible with this server",
+		Destination: &ServerConfig.ClusterResetRestoreForce,
+	},
+	&cli.IntFlag{
+		Name:        "cluster-reset-backup-retention",
+		Usage:       "(db) Number of backups of the etcd data directory to retain. A backup is saved automatically before a cluster reset or restore",
+		Destination: &ServerConfig.ResetBackupRetention,
+		Value:       5,
+	},
 	ExtraAPIArgs,
 	ExtraEtcdArgs,
 	ExtraControllerArgs,
@@ -360,40 +640,113 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.KineTLS,
 		Hidden:      true,
 	},
-	&cli.StringFlag{
-		Name:        "datastore-endpoint",
-		Usage:       "(db) Specify etcd, NATS, MySQL, Postgres, or SQLite (default) data source name",
-		Destination: &ServerConfig.DatastoreEndpoint,
-		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_ENDPOINT"},
+	DatastoreEndpointFlag,
+	DatastoreCAFileFlag,
+	DatastoreCertFileFlag,
+	DatastoreKeyFileFlag,
+	&cli.IntFlag{
+		Name:        "datastore-max-idle-connections",
+		Usage:       "(db) Maximum number of idle connections retained by the SQL datastore connection pool. If value = 0, the kine default is used. If value < 0, idle connections are not reused",
+		Destination: &ServerConfig.DatastoreMaxIdleConns,
 	},
-	&cli.StringFlag{
-		Name:        "datastore-cafile",
-		Usage:       "(db) TLS Certificate Authority file used to secure datastore backend communication",
-		Destination: &ServerConfig.DatastoreCAFile,
-		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_CAFILE"},
+	&cli.IntFlag{
+		Name:        "datastore-max-open-connections",
+		Usage:       "(db) Maximum number of open connections used by the SQL datastore connection pool. If value <= 0, there is no limit",
+		Destination: &ServerConfig.DatastoreMaxOpenConns,
 	},
-	&cli.StringFlag{
-		Name:        "datastore-certfile",
-		Usage:       "(db) TLS certification file used to secure datastore backend communication",
-		Destination: &ServerConfig.DatastoreCertFile,
-		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_CERTFILE"},
+	&cli.DurationFlag{
+		Name:        "datastore-connection-max-lifetime",
+		Usage:       "(db) Maximum amount of time a SQL datastore connection may be reused. If value <= 0, there is no limit",
+		Destination: &ServerConfig.DatastoreConnMaxLifetime,
 	},
-	&cli.StringFlag{
-		Name:        "datastore-keyfile",
-		Usage:       "(db) TLS key file used to secure datastore backend communication",
-		Destination: &ServerConfig.DatastoreKeyFile,
-		EnvVars:     []string{version.ProgramUpper + "_DATASTORE_KEYFILE"},
+	&cli.DurationFlag{
+		Name:        "datastore-connection-max-idle-time",
+		Usage:       "(db) Maximum amount of time a SQL datastore connection may remain idle before being closed. If value <= 0, there is no limit",
+		Destination: &ServerConfig.DatastoreConnMaxIdleTime,
+	},
+	&cli.DurationFlag{
+		Name:        "datastore-retry-timeout",
+		Usage:       "(db) Retry connecting to the datastore at startup, with exponential backoff, for up to this duration. If value <= 0, startup fails on the first connection error. Retries of individual queries are handled by kine and are not affected",
+		Destination: &ServerConfig.DatastoreRetryTimeout,
+	},
+	&cli.DurationFlag{
+		Name:        "datastore-slow-sql-threshold",
+		Usage:       "(db) Log SQL datastore queries that take longer than this duration at level info. If value <= 0, slow queries are not logged",
+		Destination: &ServerConfig.DatastoreSlowSQL,
+		Value:       time.Second,
+	},
+	&cli.DurationFlag{
+		Name:        "datastore-slow-sql-warning-threshold",
+		Usage:       "(db) Log SQL datastore queries that take longer than this duration at level warning",
+		Destination: &ServerConfig.DatastoreSlowSQLWarning,
+		Value:       5 * time.Second,
 	},
 	&cli.BoolFlag{
 		Name:        "etcd-expose-metrics",
 		Usage:       "(db) Expose etcd metrics to client interface",
 		Destination: &ServerConfig.EtcdExposeMetrics,
 	},
+	&cli.BoolFlag{
+		Name:        "etcd-metrics-proxy",
+		Usage:       "(db) Serve etcd metrics at /metrics/etcd on the supervisor port, with the same authentication and authorization as supervisor metrics",
+		Destination: &ServerConfig.EtcdMetricsProxy,
+	},
 	&cli.BoolFlag{
 		Name:        "etcd-disable-snapshots",
 		Usage:       "(db) Disable automatic etcd snapshots",
 		Destination: &ServerConfig.EtcdDisableSnapshots,
 	},
+	&cli.BoolFlag{
+		Name:        "etcd-disable-alarm-recovery",
+		Usage:       "(db) Disable automatic compaction, defragmentation, and alarm clearing when etcd runs out of space. If the quota must be raised to clear the alarm, the raised quota takes effect when " + version.Program + " is restarted",
+		Destination: &ServerConfig.EtcdDisableAlarmRecovery,
+	},
+	&cli.BoolFlag{
+		Name:        "etcd-disable-shutdown-leader-transfer",
+		Usage:       "(db) Disable transferring etcd leadership to another healthy member when " + version.Program + " is shut down while this server is the etcd leader",
+		Destination: &ServerConfig.EtcdDisableLeaderMove,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-defrag-schedule-cron",
+		Usage:       "(db) Rolling defragmentation interval time in cron spec, eg. weekly '0 3 * * 0'. Members are defragmented one at a time, with the leader last (default: disabled)",
+		Destination: &ServerConfig.EtcdDefragCron,
+	},
+	&cli.BoolFlag{
+		Name:        "etcd-witness",
+		Usage:       "(db) Join the cluster as an etcd witness: a voting etcd member that runs no control-plane components and hands off etcd leadership to another member. Allows two full servers and a small witness node to survive the loss of any one node",
+		Destination: &ServerConfig.EtcdWitness,
+	},
+	&cli.BoolFlag{
+		Name:        "control-plane-standby",
+		Usage:       "(cluster) Join the cluster as a control-plane standby: an etcd member that does not run the apiserver, scheduler, or controller-manager until promoted with '" + version.Program + " node promote', or automatically. Once promoted, the server restarts as a full server",
+		Destination: &ServerConfig.ControlPlaneStandby,
+	},
+	&cli.DurationFlag{
+		Name:        "control-plane-standby-promote-timeout",
+		Usage:       "(cluster) Automatically promote the control-plane standby if no other server's apiserver has been ready for this long. 0 disables automatic promotion",
+		Destination: &ServerConfig.StandbyPromoteTimeout,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-max-learners",
+		Usage:       "(db) Maximum number of etcd learners that may join the cluster at the same time. Must be set to the same value on all servers",
+		Destination: &ServerConfig.EtcdMaxLearners,
+		Value:       1,
+	},
+	&cli.Uint64Flag{
+		Name:        "etcd-learner-max-lag",
+		Usage:       "(db) Maximum number of raft log entries that an etcd learner may be behind the leader before it is promoted to a voting member (default: 0, rely on etcd's promotion readiness check)",
+		Destination: &ServerConfig.EtcdLearnerMaxLag,
+	},
+	&cli.DurationFlag{
+		Name:        "etcd-learner-promotion-timeout",
+		Usage:       "(db) Remove etcd learners that have not been promoted to a voting member within this duration, even if they are making progress (default: 0, learners are only removed if they stall)",
+		Destination: &ServerConfig.EtcdLearnerTimeout,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-downgrade-version",
+		Usage:       "(db) Enable a downgrade of the etcd cluster to the given major.minor version, eg. '3.5', so that servers can be rolled back one at a time to a release with the older etcd version",
+		Destination: &ServerConfig.EtcdDowngradeVersion,
+	},
 	&cli.StringFlag{
 		Name:        "etcd-snapshot-name",
 		Usage:       "(db) Set the base name of etcd snapshots, appended with UNIX timestamp",
@@ -418,6 +771,21 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdSnapshotRetention,
 		Value:       defaultSnapshotRentention,
 	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-retention-max-age",
+		Usage:       "(db) Retain all snapshots newer than this age, in addition to the retention count. Accepts durations with d or w suffixes, eg. '14d'",
+		Destination: &ServerConfig.EtcdSnapshotMaxAge,
+	},
+	&cli.StringSliceFlag{
+		Name:        "etcd-snapshot-retention-tier",
+		Usage:       "(db) Retain one snapshot per interval up to the given age, in addition to the retention count, in the form INTERVAL=KEEP. eg. 'hourly=2d', 'daily=30d'",
+		Destination: &ServerConfig.EtcdSnapshotTiers,
+	},
+	&cli.StringSliceFlag{
+		Name:        "etcd-snapshot-schedule",
+		Usage:       "(db) Additional snapshot schedule with its own snapshot name prefix and retention count, in the form 'name=NAME;cron=CRON;retention=COUNT'. eg. 'name=hourly;cron=0 * * * *;retention=24'",
+		Destination: &ServerConfig.EtcdSnapshotSchedules,
+	},
 	&cli.StringFlag{
 		Name:        "etcd-snapshot-dir",
 		Usage:       "(db) Directory to save db snapshots. (default: ${data-dir}/server/db/snapshots)",
@@ -428,6 +796,50 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(db) Compress etcd snapshot",
 		Destination: &ServerConfig.EtcdSnapshotCompress,
 	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-compression",
+		Usage:       "(db) Compression format used when etcd snapshot compression is enabled, one of 'zip', 'zstd'",
+		Destination: &ServerConfig.EtcdSnapshotCompression,
+		Value:       "zip",
+	},
+	&cli.IntFlag{
+		Name:        "etcd-snapshot-zstd-level",
+		Usage:       "(db) Compression level for zstd-compressed etcd snapshots, 1-22. Levels are mapped onto the 4 encoder levels supported by the zstd library (default: 0, zstd default level)",
+		Destination: &ServerConfig.EtcdSnapshotZstdLevel,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-webhook-url",
+		Usage:       "(db) HTTPS URL to notify when snapshots are saved, fail to save, or are deleted",
+		Destination: &ServerConfig.EtcdSnapshotWebhookURL,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-webhook-type",
+		Usage:       "(db) Payload format for etcd snapshot webhook notifications, one of 'generic', 'slack'",
+		Destination: &ServerConfig.EtcdSnapshotWebhookType,
+		Value:       "generic",
+	},
+	&cli.StringSliceFlag{
+		Name:        "etcd-snapshot-pre-hook",
+		Usage:       "(db) Command or URL to run before saving etcd snapshots, to quiesce applications. Commands are run with sh; URLs are sent a POST request and must use https unless the host is a loopback address",
+		Destination: &ServerConfig.EtcdSnapshotPreHooks,
+	},
+	&cli.StringSliceFlag{
+		Name:        "etcd-snapshot-post-hook",
+		Usage:       "(db) Command or URL to run after saving etcd snapshots, to resume applications. Post-snapshot hooks are run even if the snapshot or a pre-snapshot hook fails",
+		Destination: &ServerConfig.EtcdSnapshotPostHooks,
+	},
+	&cli.DurationFlag{
+		Name:        "etcd-snapshot-hook-timeout",
+		Usage:       "(db) Maximum time to wait for each etcd snapshot hook to complete",
+		Destination: &ServerConfig.EtcdSnapshotHookTimeout,
+		Value:       time.Minute,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-hook-failure-policy",
+		Usage:       "(db) Action taken when a pre-snapshot hook fails, one of 'fail' to skip the snapshot, or 'ignore' to save the snapshot anyway",
+		Destination: &ServerConfig.EtcdSnapshotHookPolicy,
+		Value:       "fail",
+	},
 	&cli.BoolFlag{
 		Name:        "etcd-s3",
 		Usage:       "(db) Enable backup to S3",
@@ -504,6 +916,11 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(db) Name of secret in the kube-system namespace used to configure S3, if etcd-s3 is enabled and no other etcd-s3 options are set",
 		Destination: &ServerConfig.EtcdS3ConfigSecret,
 	},
+	&cli.StringSliceFlag{
+		Name:        "etcd-s3-target-secret",
+		Usage:       "(db) Name of secret in the kube-system namespace used to configure an additional S3 target that snapshots are also uploaded to, in the same format as etcd-s3-config-secret. May be repeated",
+		Destination: &ServerConfig.EtcdS3TargetSecrets,
+	},
 	&cli.BoolFlag{
 		Name:        "etcd-s3-insecure",
 		Usage:       "(db) Disables S3 over HTTPS",
@@ -515,6 +932,37 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdS3Timeout,
 		Value:       5 * time.Minute,
 	},
+	&cli.StringFlag{
+		Name:        "etcd-s3-kms-key-id",
+		Usage:       "(db) ID or ARN of the KMS key used to encrypt snapshots with SSE-KMS server-side encryption",
+		Destination: &ServerConfig.EtcdS3KMSKeyID,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-s3-part-size",
+		Usage:       "(db) Part size in MiB for multipart snapshot uploads, 5-5120 (default: selected automatically from the snapshot size)",
+		Destination: &ServerConfig.EtcdS3PartSize,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-s3-concurrency",
+		Usage:       "(db) Number of parts uploaded concurrently for multipart snapshot uploads (default: 2)",
+		Destination: &ServerConfig.EtcdS3Concurrency,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-s3-upload-rate-limit",
+		Usage:       "(db) Maximum rate in KiB/s for snapshot uploads. The S3 timeout is extended by the time required to upload the snapshot at this rate (default: 0, unlimited)",
+		Destination: &ServerConfig.EtcdS3UploadRateLimit,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-remote-target",
+		Usage:       "(db) Also save snapshots to a remote file target: a file:// URL for a directory on a mounted NFS or SMB share, or an http(s):// WebDAV URL with optional basic auth credentials",
+		Destination: &ServerConfig.EtcdRemoteTarget,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-remote-retention",
+		Usage:       "(db) Number of snapshots to retain on the remote file target",
+		Destination: &ServerConfig.EtcdRemoteRetention,
+		Value:       defaultSnapshotRentention,
+	},
 	&cli.StringFlag{
 		Name:        "default-local-storage-path",
 		Usage:       "(storage) Default local storage path for local provisioner storage class",
@@ -524,6 +972,16 @@ var ServerFlags = []cli.Flag{
 		Name:  "disable",
 		Usage: "(components) Do not deploy packaged components and delete any deployed components (valid values: " + DisableItems + ")",
 	},
+	&cli.StringSliceFlag{
+		Name:        "component-node-selector",
+		Usage:       "(components) Node selector to add to the pods of a packaged component, in the format <component>=<key>=<value> (valid components: " + DisableItems + ")",
+		Destination: &ServerConfig.ComponentNodeSelectors,
+	},
+	&cli.StringSliceFlag{
+		Name:        "component-toleration",
+		Usage:       "(components) Toleration to add to the pods of a packaged component, in the format <component>=<key>[=<value>][:<effect>] (valid components: " + DisableItems + ")",
+		Destination: &ServerConfig.ComponentTolerations,
+	},
 	&cli.BoolFlag{
 		Name:        "disable-scheduler",
 		Usage:       "(components) Disable Kubernetes default scheduler",
@@ -534,6 +992,11 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(components) Disable " + version.Program + " default cloud controller manager",
 		Destination: &ServerConfig.DisableCCM,
 	},
+	&cli.StringFlag{
+		Name:        "cloud-controller-config",
+		Usage:       "(components) Path to a YAML or JSON file with settings for the " + version.Program + " default cloud controller manager and ServiceLB, overriding values generated from other flags",
+		Destination: &ServerConfig.CloudControllerConfig,
+	},
 	&cli.BoolFlag{
 		Name:        "disable-kube-proxy",
 		Usage:       "(components) Disable running kube-proxy",
@@ -577,6 +1040,97 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(experimental/components) Enable serving " + version.Program + " internal metrics on the supervisor port; when enabled agents will also listen on the supervisor port",
 		Destination: &ServerConfig.SupervisorMetrics,
 	},
+	&cli.StringSliceFlag{
+		Name:        "apiserver-watch-cache-sizes",
+		Usage:       "(experimental/components) Watch cache size overrides for kube-apiserver, as resource[.group]=size. A size of 0 disables the watch cache for that resource",
+		Destination: &ServerConfig.APIServerWatchCacheSizes,
+	},
+	&cli.DurationFlag{
+		Name:        "apiserver-watch-cache-report-interval",
+		Usage:       "(experimental/components) Interval at which to log the kube-apiserver resources with the largest watch caches, to guide watch cache tuning. 0 disables reporting",
+		Destination: &ServerConfig.WatchCacheReportInterval,
+	},
+	&cli.Int64Flag{
+		Name:        "list-page-size",
+		Usage:       "(experimental/components) Page size used by " + version.Program + " controllers when listing resources from the apiserver. kube-apiserver does not support a server-side default page size, so clients that do not request pagination are unaffected",
+		Destination: &ServerConfig.ListPageSize,
+		Value:       20,
+	},
+	&cli.StringFlag{
+		Name:        "guardrail-profile",
+		Usage:       "(experimental/components) Object count guardrail profile, one of 'none', 'single-node'. When enabled, Secrets and ConfigMaps per namespace are limited by ResourceQuota, event TTL is shortened, and excess events are deleted",
+		Destination: &ServerConfig.GuardrailProfile,
+		Value:       "none",
+	},
+	&cli.StringFlag{
+		Name:        "telemetry-endpoint",
+		Usage:       "(experimental/components) HTTP(S) URL to periodically send anonymous feature usage reports to, containing the datastore type, CNI, disabled components, and node counts by architecture and OS. Reports are not sent unless set",
+		Destination: &ServerConfig.TelemetryEndpoint,
+	},
+	&cli.DurationFlag{
+		Name:        "telemetry-interval",
+		Usage:       "(experimental/components) Interval at which feature usage reports are sent to the telemetry endpoint",
+		Destination: &ServerConfig.TelemetryInterval,
+		Value:       24 * time.Hour,
+	},
+	&cli.StringFlag{
+		Name:        "git-backup-remote",
+		Usage:       "(db) Git remote URL to commit a SOPS encrypted copy of the bootstrap data, CA certificate hashes, and etcd snapshot metadata to when they change. Requires git on the host; credentials are taken from the host git configuration, the URL, or --git-backup-ssh-key. Backups are not made unless set",
+		Destination: &ServerConfig.GitBackupRemote,
+	},
+	&cli.StringFlag{
+		Name:        "git-backup-branch",
+		Usage:       "(db) Git branch to commit backups to",
+		Destination: &ServerConfig.GitBackupBranch,
+		Value:       "main",
+	},
+	&cli.StringFlag{
+		Name:        "git-backup-path",
+		Usage:       "(db) Directory within the git repository to write backups to, to allow multiple clusters to share a repository (default: repository root)",
+		Destination: &ServerConfig.GitBackupPath,
+	},
+	&cli.StringSliceFlag{
+		Name:        "git-backup-age-recipient",
+		Usage:       "(db) age public key (age1...) to encrypt backups to. May be specified multiple times; at least one is required when --git-backup-remote is set",
+		Destination: &ServerConfig.GitBackupRecipients,
+	},
+	&cli.StringFlag{
+		Name:        "git-backup-ssh-key",
+		Usage:       "(db) Path to the SSH private key used to push to an SSH git remote",
+		Destination: &ServerConfig.GitBackupSSHKey,
+	},
+	&cli.DurationFlag{
+		Name:        "git-backup-interval",
+		Usage:       "(db) Interval at which to check for changes to the bootstrap data and CA certificates. Snapshot changes are pushed as they occur",
+		Destination: &ServerConfig.GitBackupInterval,
+		Value:       15 * time.Minute,
+	},
+	&cli.StringFlag{
+		Name:        "coredns-autoscaler",
+		Usage:       "(experimental/components) Scale CoreDNS with cluster size, using comma-separated key=value parameters: cores-per-replica, nodes-per-replica, min, max, prevent-single-point-failure, memory-per-node. Use 'default' for default parameters",
+		Destination: &ServerConfig.CoreDNSAutoscaler,
+	},
+	&cli.BoolFlag{
+		Name:        "prefer-local-images",
+		Usage:       "(experimental/components) Enforce the IfNotPresent image pull policy for all pods, so that images present on the node are never re-pulled, regardless of the pull policy set in the pod spec. Pods in kube-system are exempt",
+		Destination: &ServerConfig.PreferLocalImages,
+	},
+	&cli.StringFlag{
+		Name:        "image-digest-allowlist",
+		Usage:       "(experimental/components) File listing allowed images with digests, one per line; requires prefer-local-images. Tagged images are pinned to the listed digest, and pods using unlisted images are rejected",
+		Destination: &ServerConfig.ImageDigestAllowlist,
+	},
+	&cli.Float64Flag{
+		Name:        "cpu-pressure-threshold",
+		Usage:       "(experimental/components) Percentage of time that tasks are stalled waiting for CPU, as reported by Linux pressure stall information, above which non-critical embedded controllers defer their work. Affects etcd snapshot uploads, addon manifest reconciliation, and certificate expiration checks. 0 disables load shedding",
+		Destination: &ServerConfig.CPUPressureThreshold,
+	},
+	&cli.DurationFlag{
+		Name:        "cpu-pressure-max-delay",
+		Usage:       "(experimental/components) Maximum time that non-critical embedded controllers defer their work due to CPU pressure",
+		Destination: &ServerConfig.CPUPressureMaxDelay,
+		Value:       5 * time.Minute,
+	},
 	NodeNameFlag,
 	WithNodeIDFlag,
 	NodeLabels,
@@ -599,6 +1153,13 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.SystemDefaultRegistry,
 	},
 	AirgapExtraRegistryFlag,
+	ImageMirrorListFlag,
+	ImageMirrorIntervalFlag,
+	DiskPressureActionsFlag,
+	DiskPressureThresholdFlag,
+	DiskPressureIntervalFlag,
+	DiskPressureLogMaxSizeFlag,
+	DiskPressureDryRunFlag,
 	NodeIPFlag,
 	NodeExternalIPFlag,
 	NodeInternalDNSFlag,
@@ -611,7 +1172,19 @@ var ServerFlags = []cli.Flag{
 	VPNAuthFile,
 	ExtraKubeletArgs,
 	ExtraKubeProxyArgs,
+	KubeletBindAddressFlag,
+	KubeletHealthzAddressFlag,
+	KubeletReadOnlyPortFlag,
 	ProtectKernelDefaultsFlag,
+	ContainerizedFlag,
+	ComponentEnvFlag,
+	HookFlag,
+	HookTimeoutFlag,
+	HookFailurePolicyFlag,
+	ShutdownPhaseTimeoutsFlag,
+	ContainerCheckpointFlag,
+	WarmStandbyFlag,
+	WarmStandbyIntervalFlag,
 	&cli.BoolFlag{
 		Name:        "secrets-encryption",
 		Usage:       "Enable secret encryption at rest",
@@ -642,6 +1215,12 @@ var ServerFlags = []cli.Flag{
 		Hidden:      true,
 		Destination: &ServerConfig.DisableAgent,
 	},
+	&cli.BoolFlag{
+		Name:        "enable-fault-injection",
+		Usage:       "(experimental) Enable the fault injection API on the supervisor, for rehearsing failure handling in staging clusters. Do not use in production",
+		Hidden:      true,
+		Destination: &ServerConfig.FaultInjection,
+	},
 	&cli.StringSliceFlag{
 		Hidden:      true,
 		Name:        "kube-controller-arg",
@@ -656,12 +1235,107 @@ var ServerFlags = []cli.Flag{
 	},
 }
 
-func NewServerCommand(action func(*cli.Context) error) *cli.Command {
+var ServerDecommissionFlags = []cli.Flag{
+	DataDirFlag,
+	ServerToken,
+}
+
+type ServerBootstrap struct {
+	File    string
+	Decrypt bool
+	Force   bool
+}
+
+var ServerBootstrapConfig ServerBootstrap
+
+var ServerBootstrapExportFlags = []cli.Flag{
+	DataDirFlag,
+	ServerToken,
+	DatastoreEndpointFlag,
+	DatastoreCAFileFlag,
+	DatastoreCertFileFlag,
+	DatastoreKeyFileFlag,
+	&cli.StringFlag{
+		Name:        "output",
+		Aliases:     []string{"o"},
+		Usage:       "Path to write the bootstrap data to, or - to write to stdout. Required when --decrypt is set (default: stdout)",
+		Destination: &ServerBootstrapConfig.File,
+	},
+	&cli.BoolFlag{
+		Name:        "decrypt",
+		Usage:       "Export the decrypted bootstrap data to the --output file. The output contains the cluster CA private keys, and must be protected accordingly",
+		Destination: &ServerBootstrapConfig.Decrypt,
+	},
+}
+
+var ServerBootstrapImportFlags = []cli.Flag{
+	DataDirFlag,
+	ServerToken,
+	DatastoreEndpointFlag,
+	DatastoreCAFileFlag,
+	DatastoreCertFileFlag,
+	DatastoreKeyFileFlag,
+	&cli.StringFlag{
+		Name:        "input",
+		Aliases:     []string{"i"},
+		Usage:       "Path to read the exported bootstrap data from, or - to read from stdin",
+		Destination: &ServerBootstrapConfig.File,
+		Required:    true,
+	},
+	&cli.BoolFlag{
+		Name:        "force",
+		Usage:       "Replace existing bootstrap data in the datastore",
+		Destination: &ServerBootstrapConfig.Force,
+	},
+}
+
+func NewServerCommand(action, decommission, bootstrapExport, bootstrapImport func(*cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:      "server",
 		Usage:     "Run management server",
 		UsageText: appName + " server [OPTIONS]",
 		Action:    action,
 		Flags:     ServerFlags,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "decommission",
+				Usage:     "Permanently remove this server from the cluster. The local etcd member is removed, the node is deleted, and the server shuts down; the data directory may then be removed",
+				UsageText: appName + " server decommission [OPTIONS]",
+				Action:    decommission,
+				Flags:     ServerDecommissionFlags,
+			},
+			{
+				Name:  "bootstrap",
+				Usage: "Export or import the cluster bootstrap data (CA certificates and keys, service account key, passwd) stored in the datastore",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "Export the bootstrap data from the datastore. The data is encrypted with the token unless --decrypt is set",
+						UsageText: appName + " server bootstrap export [OPTIONS]",
+						Action:    bootstrapExport,
+						Flags:     ServerBootstrapExportFlags,
+					},
+					{
+						Name:      "import",
+						Usage:     "Import previously exported bootstrap data into the datastore, for use by servers started with the same token",
+						UsageText: appName + " server bootstrap import [OPTIONS]",
+						Action:    bootstrapImport,
+						Flags:     ServerBootstrapImportFlags,
+					},
+				},
+			},
+		},
+	}
+}
+
+// ValidateIPAMWebhook checks that the IPAM webhook URL, if set, is an https URL.
+func ValidateIPAMWebhook(webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("invalid ipam-webhook-url: must be an https URL")
 	}
+	return nil
 }
@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const StatusCommand = "status"
+
+// Status holds CLI values for the status command
+type Status struct {
+	Cluster bool
+}
+
+var (
+	StatusConfig = Status{}
+	StatusFlags  = []cli.Flag{
+		DataDirFlag,
+		ServerToken,
+		&cli.StringFlag{
+			Name:        "server",
+			Aliases:     []string{"s"},
+			Usage:       "(cluster) Server to connect to",
+			EnvVars:     []string{version.ProgramUpper + "_URL"},
+			Value:       "https://127.0.0.1:6443",
+			Destination: &ServerConfig.ServerURL,
+		},
+		&cli.BoolFlag{
+			Name:        "cluster",
+			Usage:       "Include the health of all etcd members and nodes, instead of only the apiserver and datastore used by the server",
+			Destination: &StatusConfig.Cluster,
+		},
+	}
+)
+
+func NewStatusCommand(action func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:      StatusCommand,
+		Usage:     "Print the health of the cluster as JSON, without requiring an admin kubeconfig",
+		UsageText: appName + " status [OPTIONS]",
+		Action:    action,
+		Flags:     StatusFlags,
+	}
+}
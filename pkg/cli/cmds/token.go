@@ -17,9 +17,12 @@ type Token struct {
 	Token       string
 	NewToken    string
 	Output      string
+	Role        string
+	MaxUses     int
 	Groups      cli.StringSlice
 	Usages      cli.StringSlice
 	TTL         time.Duration
+	UpdateAgent bool
 }
 
 var (
@@ -52,6 +55,21 @@ func NewTokenCommands(createFunc, deleteFunc, generateFunc, listFunc, rotateFunc
 					Name:        "groups",
 					Usage:       "Extra groups that this token will authenticate as when used for authentication",
 					Destination: &TokenConfig.Groups,
+				}, &cli.IntFlag{
+					Name:        "max-uses",
+					Usage:       "The number of distinct nodes that can join using this token. Nodes that have joined may continue to use the token to retrieve certificates when restarted. If set to '0', the number of uses is not limited",
+					Destination: &TokenConfig.MaxUses,
+				}, &cli.StringFlag{
+					Name:        "output",
+					Aliases:     []string{"o"},
+					Usage:       "Output format: 'text', 'json', or 'yaml'. Structured output includes the token ID, expiry, usages, groups, and full token",
+					Value:       "text",
+					Destination: &TokenConfig.Output,
+				}, &cli.StringFlag{
+					Name:        "role",
+					Usage:       "The type of node that this token can join: 'agent' or 'server'. Server tokens can only join servers with embedded etcd, and cannot join agents",
+					Value:       "agent",
+					Destination: &TokenConfig.Role,
 				}, &cli.DurationFlag{
 					Name:        "ttl",
 					Usage:       "The duration before the token is automatically deleted (e.g. 1s, 2m, 3h). If set to '0', the token will never expire",
@@ -85,6 +103,7 @@ func NewTokenCommands(createFunc, deleteFunc, generateFunc, listFunc, rotateFunc
 				Flags: append(TokenFlags, &cli.StringFlag{
 					Name:        "output",
 					Aliases:     []string{"o"},
+					Usage:       "Output format: 'text', 'json', or 'yaml'. Structured output includes the token ID, expiry, usages, groups, and full token",
 					Value:       "text",
 					Destination: &TokenConfig.Output,
 				}),
@@ -114,6 +133,11 @@ func NewTokenCommands(createFunc, deleteFunc, generateFunc, listFunc, rotateFunc
 						Name:        "new-token",
 						Usage:       "New token that replaces existing token",
 						Destination: &TokenConfig.NewToken,
+					},
+					&cli.BoolFlag{
+						Name:        "update-agents",
+						Usage:       "Push the new token to agents connected to the server, if agents share the server token. Agents store the new token, and use it in place of their configured token when restarted",
+						Destination: &TokenConfig.UpdateAgent,
 					}),
 				SkipFlagParsing: false,
 				Action:          rotateFunc,
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Migrate rewrites deprecated flags in the config file and its dropins to their replacement.
+func Migrate(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return migrate(app.String("config"), &cmds.ConfigMigrateConfig)
+}
+
+func migrate(file string, cfg *cmds.ConfigMigrate) error {
+	changed, err := configfilearg.MigrateFiles(file, cfg.DryRun)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		logrus.Infof("No deprecated flags found in %s or its dropins", file)
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(changed)) {
+		if cfg.DryRun {
+			fmt.Printf("# %s\n%s", name, changed[name])
+			continue
+		}
+		logrus.Infof("Migrated deprecated flags in %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	"github.com/k3s-io/k3s/pkg/deploy"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/rancher/wrangler/pkg/data/convert"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// redacted replaces the value of secrets in exported registries.yaml files.
+const redacted = "REDACTED"
+
+// Export prints the merged config file and its dropins, or writes the files needed to recreate the
+// configuration of this server from a fresh install.
+func Export(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return export(app.String("config"), cmds.ServerConfig.DataDir, &cmds.ConfigExportConfig)
+}
+
+func export(file, dataDir string, cfg *cmds.ConfigExport) error {
+	values, err := configfilearg.ReadConfig(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	values, secrets := omitSecrets(values)
+
+	if !cfg.AsInstall {
+		b, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		fmt.Print(header(file, secrets, false) + string(b))
+		return nil
+	}
+
+	if dataDir == "" {
+		dataDir = configString(values, "data-dir")
+	}
+	serverDataDir, err := server.ResolveDataDir(dataDir)
+	if err != nil {
+		return err
+	}
+	registries := configString(values, "private-registry")
+	if registries == "" {
+		registries = "/etc/rancher/" + version.Program + "/registries.yaml"
+	}
+
+	if err := os.MkdirAll(cfg.Output, 0700); err != nil {
+		return err
+	}
+	values = omitDefaults(values, cmds.ServerFlags)
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		b = nil
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Output, "config.yaml"), []byte(header(file, secrets, true)+string(b)), 0600); err != nil {
+		return err
+	}
+	logrus.Infof("Exported %d non-default config values from %s", len(values), file)
+
+	if err := exportRegistries(registries, filepath.Join(cfg.Output, "registries.yaml")); err != nil {
+		return errors.WithMessagef(err, "failed to export %s", registries)
+	}
+	if err := exportManifests(filepath.Join(serverDataDir, "manifests"), filepath.Join(cfg.Output, "manifests")); err != nil {
+		return errors.WithMessage(err, "failed to export manifests")
+	}
+	logrus.Infof("Install files written to %s", cfg.Output)
+	return nil
+}
+
+// header returns a comment describing an exported config file, and the secrets omitted from it.
+func header(file string, secrets []string, asInstall bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Exported from %s by '%s config export' at %s\n", file, version.Program, time.Now().UTC().Format(time.RFC3339))
+	if asInstall {
+		b.WriteString("# Values equal to their defaults are omitted\n")
+	}
+	if len(secrets) > 0 {
+		fmt.Fprintf(&b, "# Secrets are omitted, and must be set when installing: %s\n", strings.Join(secrets, ", "))
+	}
+	return b.String()
+}
+
+// isSecret returns true if the config key holds a secret, rather than the path to a file containing the secret.
+func isSecret(key string) bool {
+	if strings.HasSuffix(key, "-file") || strings.HasSuffix(key, "-path") {
+		return false
+	}
+	return key == "t" || key == "vpn-auth" || strings.Contains(key, "token") || strings.Contains(key, "secret") || strings.Contains(key, "password")
+}
+
+// omitSecrets removes config values that hold secrets, returning the remaining values and the keys that were removed.
+// Passwords are also removed from the datastore endpoint.
+func omitSecrets(values yaml.MapSlice) (yaml.MapSlice, []string) {
+	var secrets []string
+	result := yaml.MapSlice{}
+	for _, item := range values {
+		key := convert.ToString(item.Key)
+		if isSecret(key) {
+			secrets = append(secrets, key)
+			continue
+		}
+		if key == "datastore-endpoint" {
+			if endpoint, ok := redactEndpoint(convert.ToString(item.Value)); ok {
+				item.Value = endpoint
+				secrets = append(secrets, key+" password")
+			}
+		}
+		result = append(result, item)
+	}
+	return result, secrets
+}
+
+// redactEndpoint replaces the password in a datastore endpoint URL, returning true if the endpoint contained a password.
+func redactEndpoint(endpoint string) (string, bool) {
+	scheme, rest, ok := strings.Cut(endpoint, "://")
+	if !ok {
+		return endpoint, false
+	}
+	i := strings.LastIndex(rest, "@")
+	if i < 0 {
+		return endpoint, false
+	}
+	userinfo, host := rest[:i], rest[i+1:]
+	user, _, ok := strings.Cut(userinfo, ":")
+	if !ok {
+		return endpoint, false
+	}
+	return scheme + "://" + user + ":" + redacted + "@" + host, true
+}
+
+// configString returns the string value of a config key, or an empty string if the key is not set.
+func configString(values yaml.MapSlice, key string) string {
+	for _, item := range values {
+		if convert.ToString(item.Key) == key {
+			return convert.ToString(item.Value)
+		}
+	}
+	return ""
+}
+
+// omitDefaults removes config values that are equal to the default value of the corresponding flag.
+// Values for keys that do not correspond to a flag are retained.
+func omitDefaults(values yaml.MapSlice, flags []cli.Flag) yaml.MapSlice {
+	defaults := flagDefaults(flags)
+	result := yaml.MapSlice{}
+	for _, item := range values {
+		if def, ok := defaults[convert.ToString(item.Key)]; ok && isDefault(def, item.Value) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// flagDefaults returns the default value of each flag, keyed by flag name and alias. Slice defaults are
+// returned as a []string, numeric defaults as a float64, and all other defaults as their own type.
+func flagDefaults(flags []cli.Flag) map[string]any {
+	defaults := map[string]any{}
+	for _, flag := range flags {
+		var def any
+		switch f := flag.(type) {
+		case *cli.StringFlag:
+			def = f.Value
+		case *cli.BoolFlag:
+			def = f.Value
+		case *cli.DurationFlag:
+			def = f.Value
+		case *cli.IntFlag:
+			def = float64(f.Value)
+		case *cli.Int64Flag:
+			def = float64(f.Value)
+		case *cli.Uint64Flag:
+			def = float64(f.Value)
+		case *cli.Float64Flag:
+			def = f.Value
+		case *cli.StringSliceFlag:
+			def = []string{}
+			if f.Value != nil {
+				def = f.Value.Value()
+			}
+		default:
+			continue
+		}
+		for _, name := range flag.Names() {
+			defaults[name] = def
+		}
+	}
+	return defaults
+}
+
+// isDefault returns true if the config value is equal to the default value of a flag.
+func isDefault(def, value any) bool {
+	str := convert.ToString(value)
+	switch d := def.(type) {
+	case []string:
+		var values []string
+		for _, v := range convert.ToInterfaceSlice(value) {
+			values = append(values, convert.ToString(v))
+		}
+		if values == nil && str != "" {
+			values = strings.Split(str, ",")
+		}
+		return slices.Equal(d, values) || (len(d) == 0 && len(values) == 0)
+	case bool:
+		b, err := strconv.ParseBool(str)
+		return err == nil && b == d
+	case time.Duration:
+		v, err := time.ParseDuration(str)
+		return err == nil && v == d
+	case float64:
+		v, err := strconv.ParseFloat(str, 64)
+		return err == nil && v == d
+	case string:
+		return str == d
+	}
+	return false
+}
+
+// exportRegistries copies the registries config file to the output path, replacing credentials. It is
+// not an error if the registries config file does not exist.
+func exportRegistries(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	registries := map[string]any{}
+	if err := yaml.Unmarshal(b, &registries); err != nil {
+		return err
+	}
+	if configs, ok := registries["configs"].(map[any]any); ok {
+		for _, config := range configs {
+			if config, ok := config.(map[any]any); ok {
+				if auth, ok := config["auth"].(map[any]any); ok {
+					for k := range auth {
+						if k != "username" {
+							auth[k] = redacted
+						}
+					}
+				}
+			}
+		}
+	}
+	if b, err = yaml.Marshal(registries); err != nil {
+		return err
+	}
+	logrus.Infof("Exported %s with credentials redacted", src)
+	return os.WriteFile(dst, b, 0600)
+}
+
+// exportManifests copies manifests that are not packaged, and skip files used to disable packaged manifests,
+// from the manifests directory to the output directory. It is not an error if the manifests directory does not exist.
+func exportManifests(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == src && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if deploy.Packaged(name) {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dst, name)), 0700); err != nil {
+			return err
+		}
+		logrus.Infof("Exported manifest %s", name)
+		return os.WriteFile(filepath.Join(dst, name), b, 0600)
+	})
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_UnitOmitDefaults(t *testing.T) {
+	flags := []cli.Flag{
+		&cli.StringFlag{Name: "cluster-domain", Value: "cluster.local"},
+		&cli.StringFlag{Name: "node-name"},
+		&cli.BoolFlag{Name: "disable-helm-controller"},
+		&cli.IntFlag{Name: "https-listen-port", Value: 6443},
+		&cli.DurationFlag{Name: "etcd-snapshot-retention-interval", Value: time.Hour},
+		&cli.StringSliceFlag{Name: "disable", Aliases: []string{"no-deploy"}},
+		&cli.StringSliceFlag{Name: "cluster-cidr", Value: cli.NewStringSlice("10.42.0.0/16")},
+	}
+	values := yaml.MapSlice{
+		{Key: "cluster-domain", Value: "cluster.local"},
+		{Key: "node-name", Value: "edge-1"},
+		{Key: "disable-helm-controller", Value: false},
+		{Key: "https-listen-port", Value: 6443},
+		{Key: "etcd-snapshot-retention-interval", Value: "60m"},
+		{Key: "no-deploy", Value: []any{"traefik"}},
+		{Key: "cluster-cidr", Value: "10.42.0.0/16"},
+		{Key: "kubelet-arg", Value: []any{"max-pods=250"}},
+	}
+	want := yaml.MapSlice{
+		{Key: "node-name", Value: "edge-1"},
+		{Key: "no-deploy", Value: []any{"traefik"}},
+		{Key: "kubelet-arg", Value: []any{"max-pods=250"}},
+	}
+	if got := omitDefaults(values, flags); !reflect.DeepEqual(got, want) {
+		t.Errorf("omitDefaults() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitOmitSecrets(t *testing.T) {
+	values := yaml.MapSlice{
+		{Key: "token", Value: "secret"},
+		{Key: "token-file", Value: "/etc/token"},
+		{Key: "etcd-s3-secret-key", Value: "secret"},
+		{Key: "datastore-endpoint", Value: "postgres://k3s:p@ss@db:5432/k3s"},
+		{Key: "node-name", Value: "edge-1"},
+	}
+	wantValues := yaml.MapSlice{
+		{Key: "token-file", Value: "/etc/token"},
+		{Key: "datastore-endpoint", Value: "postgres://k3s:" + redacted + "@db:5432/k3s"},
+		{Key: "node-name", Value: "edge-1"},
+	}
+	wantSecrets := []string{"token", "etcd-s3-secret-key", "datastore-endpoint password"}
+	gotValues, gotSecrets := omitSecrets(values)
+	if !reflect.DeepEqual(gotValues, wantValues) {
+		t.Errorf("omitSecrets() values = %v, want %v", gotValues, wantValues)
+	}
+	if !reflect.DeepEqual(gotSecrets, wantSecrets) {
+		t.Errorf("omitSecrets() secrets = %v, want %v", gotSecrets, wantSecrets)
+	}
+}
@@ -0,0 +1,81 @@
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/loglevel"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTimeout is the timeout for each request to the server.
+const defaultTimeout = 30 * time.Second
+
+func commandPrep(cfg *cmds.Server) (*clientaccess.Info, error) {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " debug")
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" {
+		fp := filepath.Join(dataDir, "token")
+		tokenByte, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+	return clientaccess.ParseAndValidateToken(cmds.ServerConfig.ServerURL, cfg.Token, clientaccess.WithUser("server"))
+}
+
+func wrapServerError(err error) error {
+	return errors.WithMessage(err, "see server log for details")
+}
+
+// SetLogLevel temporarily adjusts the log level of a component embedded in the server that the command
+// is run against. The server reverts the level when the duration expires.
+func SetLogLevel(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	cfg := &cmds.SetLogLevelConfig
+	info, err := commandPrep(&cmds.ServerConfig)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(loglevel.Request{
+		Component: cfg.Component,
+		Level:     cfg.Level,
+		Duration:  metav1.Duration{Duration: cfg.Duration},
+	})
+	if err != nil {
+		return err
+	}
+
+	r, err := info.Post("/v1-"+version.Program+"/debug/log-level", b, clientaccess.WithTimeout(defaultTimeout))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	status := &loglevel.Status{}
+	if err := json.Unmarshal(r, status); err != nil {
+		return err
+	}
+	fmt.Printf("Log level for %s set to %s; it will be reverted to %s at %s\n", strings.Join(status.Components, ", "), status.Level, status.OriginalLevel, status.Expiry.Local().Format(time.RFC3339))
+	return nil
+}
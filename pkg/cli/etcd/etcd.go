@@ -0,0 +1,79 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/cluster/managed"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/urfave/cli/v2"
+)
+
+func commandPrep(cfg *cmds.Server) (*clientaccess.Info, error) {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " etcd")
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" {
+		fp := filepath.Join(dataDir, "token")
+		tokenByte, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+	return clientaccess.ParseAndValidateToken(cmds.ServerConfig.ServerURL, cfg.Token, clientaccess.WithUser("server"))
+}
+
+func wrapServerError(err error) error {
+	return errors.WithMessage(err, "see server log for details")
+}
+
+// Defrag requests rolling defragmentation of all etcd members, and prints the result for each member.
+// Defragmentation may take several minutes on large datastores, so the request does not time out.
+func Defrag(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	info, err := commandPrep(&cmds.ServerConfig)
+	if err != nil {
+		return err
+	}
+
+	r, err := info.Post("/db/defrag", nil, clientaccess.WithTimeout(0))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	res := &managed.DefragResult{}
+	if err := json.Unmarshal(r, res); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer w.Flush()
+	fmt.Fprint(w, "Name\tEndpoint\tLeader\tSize Before\tSize After\tError\n")
+	var failed bool
+	for _, m := range res.Members {
+		if m.Error != "" {
+			failed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%d\t%s\n", m.Name, m.Endpoint, m.Leader, m.SizeBefore, m.SizeAfter, m.Error)
+	}
+	if failed {
+		return wrapServerError(errors.New("etcd defragmentation did not complete"))
+	}
+	return nil
+}
@@ -5,26 +5,34 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	cliserver "github.com/k3s-io/k3s/pkg/cli/server"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/datadir"
 	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/etcd/s3"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/server"
 	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/yaml"
 )
 
 var timeout = 2 * time.Minute
@@ -42,37 +50,56 @@ func commandSetup(app *cli.Context, cfg *cmds.Server) (*etcd.SnapshotRequest, *c
 	if app.IsSet("etcd-snapshot-compress") {
 		sr.Compress = &cfg.EtcdSnapshotCompress
 	}
+	if app.IsSet("etcd-snapshot-compression") {
+		if err := cmds.ValidateEtcdSnapshotCompression(cfg.EtcdSnapshotCompression, cfg.EtcdSnapshotZstdLevel); err != nil {
+			return nil, nil, err
+		}
+		sr.Compression = &cfg.EtcdSnapshotCompression
+	}
+	if app.IsSet("etcd-snapshot-zstd-level") {
+		if err := cmds.ValidateEtcdSnapshotCompression(cfg.EtcdSnapshotCompression, cfg.EtcdSnapshotZstdLevel); err != nil {
+			return nil, nil, err
+		}
+		sr.ZstdLevel = &cfg.EtcdSnapshotZstdLevel
+	}
 	if app.IsSet("etcd-snapshot-dir") {
 		sr.Dir = &cfg.EtcdSnapshotDir
 	}
 	if app.IsSet("etcd-snapshot-retention") {
 		sr.Retention = &cfg.EtcdSnapshotRetention
 	}
+	if app.IsSet("etcd-snapshot-retention-max-age") {
+		maxAge, err := snapshot.ParseRetentionAge(cfg.EtcdSnapshotMaxAge)
+		if err != nil {
+			return nil, nil, err
+		}
+		sr.MaxAge = &metav1.Duration{Duration: maxAge}
+	}
+	if app.IsSet("etcd-snapshot-retention-tier") {
+		tiers, err := snapshot.ParseRetentionTiers(cfg.EtcdSnapshotTiers.Value())
+		if err != nil {
+			return nil, nil, err
+		}
+		sr.Tiers = tiers
+	}
 	if cfg.EtcdS3 {
+		if err := cmds.ValidateEtcdS3Upload(cfg.EtcdS3PartSize, cfg.EtcdS3Concurrency, cfg.EtcdS3UploadRateLimit); err != nil {
+			return nil, nil, err
+		}
 		// set default s3 retention from local snapshot retention
 		// preserves legacy behavior of local snapshot retention also affecting s3
 		if !app.IsSet("etcd-s3-retention") && app.IsSet("etcd-snapshot-retention") {
 			cfg.EtcdS3Retention = cfg.EtcdSnapshotRetention
 		}
-		sr.S3 = &config.EtcdS3{
-			AccessKey:     cfg.EtcdS3AccessKey,
-			Bucket:        cfg.EtcdS3BucketName,
-			BucketLookup:  cfg.EtcdS3BucketLookupType,
-			ConfigSecret:  cfg.EtcdS3ConfigSecret,
-			Endpoint:      cfg.EtcdS3Endpoint,
-			EndpointCA:    cfg.EtcdS3EndpointCA,
-			Folder:        cfg.EtcdS3Folder,
-			Insecure:      cfg.EtcdS3Insecure,
-			Proxy:         cfg.EtcdS3Proxy,
-			Region:        cfg.EtcdS3Region,
-			SecretKey:     cfg.EtcdS3SecretKey,
-			SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
-			Retention:     cfg.EtcdS3Retention,
-			Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
-		}
+		sr.S3 = etcdS3Config(cfg)
 		// extend request timeout to allow the S3 operation to complete
 		timeout += cfg.EtcdS3Timeout
 	}
+	if targets := cfg.EtcdS3TargetSecrets.Value(); len(targets) > 0 {
+		sr.S3Targets = targets
+		// extend request timeout to allow the S3 operation to complete on each target
+		timeout += time.Duration(len(targets)) * cfg.EtcdS3Timeout
+	}
 
 	dataDir, err := server.ResolveDataDir(cfg.DataDir)
 	if err != nil {
@@ -91,6 +118,30 @@ func commandSetup(app *cli.Context, cfg *cmds.Server) (*etcd.SnapshotRequest, *c
 	return sr, info, err
 }
 
+// etcdS3Config returns the S3 configuration from the CLI flags.
+func etcdS3Config(cfg *cmds.Server) *config.EtcdS3 {
+	return &config.EtcdS3{
+		AccessKey:     cfg.EtcdS3AccessKey,
+		Bucket:        cfg.EtcdS3BucketName,
+		BucketLookup:  cfg.EtcdS3BucketLookupType,
+		ConfigSecret:  cfg.EtcdS3ConfigSecret,
+		Endpoint:      cfg.EtcdS3Endpoint,
+		EndpointCA:    cfg.EtcdS3EndpointCA,
+		Folder:        cfg.EtcdS3Folder,
+		KMSKeyID:      cfg.EtcdS3KMSKeyID,
+		Insecure:      cfg.EtcdS3Insecure,
+		Proxy:         cfg.EtcdS3Proxy,
+		Region:        cfg.EtcdS3Region,
+		SecretKey:     cfg.EtcdS3SecretKey,
+		SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
+		Retention:     cfg.EtcdS3Retention,
+		PartSize:      uint64(cfg.EtcdS3PartSize) * 1024 * 1024,
+		Concurrency:   uint(cfg.EtcdS3Concurrency),
+		UploadRate:    uint64(cfg.EtcdS3UploadRateLimit) * 1024,
+		Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
+	}
+}
+
 func wrapServerError(err error) error {
 	if errors.Is(err, context.DeadlineExceeded) {
 		// if the request timed out the server log likely won't contain anything useful,
@@ -255,6 +306,20 @@ func list(app *cli.Context, cfg *cmds.Server) error {
 			return err
 		}
 		return nil
+	case "summary-json":
+		b, err := json.MarshalIndent(newSnapshotList(sf.Items), "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	case "summary-yaml":
+		b, err := yaml.Marshal(newSnapshotList(sf.Items))
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(b))
+		return nil
 	default:
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
 		defer w.Flush()
@@ -276,6 +341,10 @@ func Prune(app *cli.Context) error {
 }
 
 func prune(app *cli.Context, cfg *cmds.Server) error {
+	if err := snapshot.ValidateNamePrefix(cfg.EtcdSnapshotName); err != nil {
+		return err
+	}
+
 	sr, info, err := commandSetup(app, cfg)
 	if err != nil {
 		return err
@@ -303,3 +372,223 @@ func prune(app *cli.Context, cfg *cmds.Server) error {
 
 	return nil
 }
+
+func Verify(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return verify(app, &cmds.ServerConfig)
+}
+
+func verify(app *cli.Context, cfg *cmds.Server) error {
+	snapshots := app.Args()
+	if snapshots.Len() == 0 {
+		return errors.New("no snapshots given for verification")
+	}
+
+	sr, info, err := commandSetup(app, cfg)
+	if err != nil {
+		return err
+	}
+
+	sr.Operation = etcd.SnapshotOperationVerify
+	sr.Name = snapshots.Slice()
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	r, err := info.Post("/db/snapshot", b, clientaccess.WithTimeout(timeout))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	resp := &managed.SnapshotResult{}
+	if err := json.Unmarshal(r, resp); err != nil {
+		return err
+	}
+
+	var failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprint(w, "Name\tLocation\tRevision\tKeys\tSize\tHash\tStatus\n")
+	for _, status := range resp.Verified {
+		result := "OK"
+		if status.Error != "" {
+			result = "FAILED: " + status.Error
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%08x\t%s\n", status.Name, status.Location, status.Revision, status.TotalKey, status.TotalSize, status.Hash, result)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d snapshots failed verification", failed, len(resp.Verified))
+	}
+	return nil
+}
+
+// Restore resets etcd cluster membership and restores the datastore from a snapshot,
+// using the same configuration that the server would normally start with.
+func Restore(app *cli.Context) error {
+	return restore(app, &cmds.ServerConfig)
+}
+
+func restore(app *cli.Context, cfg *cmds.Server) error {
+	if app.Args().Len() != 1 {
+		return errors.New("exactly one snapshot name, path, or S3 URI must be given for restore")
+	}
+
+	if cfg.DisableETCD || cfg.DatastoreEndpoint != "" {
+		return errors.New("etcd-snapshot restore is only supported for servers using embedded etcd")
+	}
+
+	dataDir, err := datadir.LocalHome(cfg.DataDir, false)
+	if err != nil {
+		return err
+	}
+
+	location := app.Args().First()
+	if strings.HasPrefix(location, "s3://") {
+		if location, err = cmds.SetEtcdS3URI(cfg, location); err != nil {
+			return err
+		}
+	} else if !cfg.EtcdS3 && !strings.ContainsRune(location, os.PathSeparator) {
+		// bare snapshot names are resolved relative to the snapshot dir
+		snapshotDir := cfg.EtcdSnapshotDir
+		if snapshotDir == "" {
+			snapshotDir = filepath.Join(dataDir, "server", "db", "snapshots")
+		}
+		location = filepath.Join(snapshotDir, location)
+	}
+
+	if !cfg.EtcdS3 {
+		if _, err := os.Stat(location); err != nil {
+			return errors.WithMessage(err, "failed to find snapshot for restore")
+		}
+	}
+
+	if conn, err := net.DialTimeout("tcp", "127.0.0.1:2379", time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("etcd is still running on this node; stop the %s service before restoring a snapshot", version.Program)
+	}
+
+	if cfg.ServerURL != "" {
+		logrus.Infof("Ignoring server URL %s for cluster-reset restore", cfg.ServerURL)
+		cfg.ServerURL = ""
+	}
+
+	cfg.ClusterReset = true
+	cfg.ClusterResetRestorePath = location
+
+	logrus.Infof("Restoring etcd snapshot %s", app.Args().First())
+	start := time.Now()
+	if err := cliserver.Run(app); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	dbDir := filepath.Join(dataDir, "server", "db")
+	if fi, err := os.Stat(filepath.Join(dbDir, "reset-flag")); err != nil || fi.ModTime().Before(start) {
+		return errors.New("etcd snapshot restore did not complete; see log output for details")
+	}
+
+	fmt.Printf("\nEtcd snapshot %s has been restored, and this node is now the sole member of the etcd cluster.\n", app.Args().First())
+	fmt.Printf("To finish restoring the cluster:\n")
+	fmt.Printf("  1. Start %s on this node.\n", version.Program)
+	fmt.Printf("  2. On each other server node: stop %s, back up and delete %s, then start %s to rejoin the cluster.\n", version.Program, dbDir, version.Program)
+	fmt.Printf("  3. Agent nodes will reconnect automatically once the servers are available.\n")
+	return nil
+}
+
+func Download(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return download(app, &cmds.ServerConfig)
+}
+
+// download fetches a snapshot from S3 to a local directory, and verifies it against the checksum
+// recorded when it was uploaded. This does not require the server to be running, so that snapshots
+// can be retrieved for restore on a new node.
+func download(app *cli.Context, cfg *cmds.Server) error {
+	if app.Args().Len() != 1 {
+		return errors.New("exactly one snapshot name or S3 URI must be given for download")
+	}
+
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " etcd-snapshot")
+
+	var err error
+	name := app.Args().First()
+	if strings.HasPrefix(name, "s3://") {
+		if name, err = cmds.SetEtcdS3URI(cfg, name); err != nil {
+			return err
+		}
+	}
+	if !cfg.EtcdS3 {
+		return errors.New("etcd-s3 must be enabled, or an s3://bucket/key URI given, to download a snapshot")
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid snapshot name %s", name)
+	}
+
+	dataDir, err := datadir.LocalHome(cfg.DataDir, false)
+	if err != nil {
+		return err
+	}
+	snapshotDir := cfg.EtcdSnapshotDir
+	if snapshotDir == "" {
+		snapshotDir = filepath.Join(dataDir, "server", "db", "snapshots")
+	}
+	destDir := cfg.EtcdSnapshotDestination
+	if destDir == "" {
+		destDir = snapshotDir
+	}
+	if err := os.MkdirAll(destDir, 0700); err != nil {
+		return errors.WithMessage(err, "failed to create snapshot download directory")
+	}
+	file := filepath.Join(destDir, name)
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("snapshot file %s already exists", file)
+	}
+
+	// The cluster ID and token hash are only used when uploading snapshots, and are not available
+	// without a running server, so the S3 controller is started as it would be for a cluster reset.
+	ctx := app.Context
+	s3Controller, err := s3.Start(ctx, &config.Control{ClusterReset: true})
+	if err != nil {
+		return errors.WithMessage(err, "failed to initialize S3 client")
+	}
+	s3Client, err := s3Controller.GetClient(ctx, etcdS3Config(cfg))
+	if err != nil {
+		return errors.WithMessage(err, "failed to initialize S3 client")
+	}
+
+	logrus.Infof("Downloading snapshot %s from S3 to %s", name, file)
+	checksum, err := s3Client.DownloadFile(ctx, name, file)
+	if err != nil {
+		os.Remove(file)
+		return errors.WithMessagef(err, "failed to download snapshot %s", name)
+	}
+
+	if checksum == "" {
+		logrus.Warnf("No checksum was recorded when snapshot %s was uploaded; the downloaded file has not been verified", name)
+	} else {
+		downloaded, err := snapshot.ComputeChecksum(file)
+		if err != nil {
+			return errors.WithMessage(err, "failed to compute checksum of downloaded snapshot")
+		}
+		if downloaded != checksum {
+			os.Remove(file)
+			return fmt.Errorf("checksum of downloaded snapshot %s does not match: expected %s, got %s", name, checksum, downloaded)
+		}
+		logrus.Infof("Verified checksum %s for snapshot %s", checksum, name)
+		if destDir == snapshotDir {
+			if err := snapshot.WriteChecksum(file, checksum); err != nil {
+				logrus.Warnf("Failed to save local snapshot checksum: %v", err)
+			}
+		}
+	}
+
+	fmt.Printf("Snapshot %s downloaded to %s\n", name, file)
+	return nil
+}
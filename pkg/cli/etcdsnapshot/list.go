@@ -0,0 +1,55 @@
+package etcdsnapshot
+
+import (
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// snapshotList is the structured output of the list command, for the json and yaml formats.
+type snapshotList struct {
+	Snapshots []snapshotListItem `json:"snapshots"`
+}
+
+// snapshotListItem describes a single snapshot in the structured output of the list command.
+type snapshotListItem struct {
+	Name        string              `json:"name"`
+	Location    string              `json:"location"`
+	Storage     string              `json:"storage"`
+	NodeName    string              `json:"nodeName,omitempty"`
+	Size        int64               `json:"size"`
+	CreatedAt   *metav1.Time        `json:"createdAt,omitempty"`
+	Compressed  bool                `json:"compressed"`
+	Compression string              `json:"compression,omitempty"`
+	Checksum    string              `json:"checksum,omitempty"`
+	Metadata    map[string]string   `json:"metadata,omitempty"`
+	S3          *k3s.ETCDSnapshotS3 `json:"s3,omitempty"`
+}
+
+// newSnapshotList converts the snapshot files returned by the server to the structured list output.
+// For S3 snapshots, the node name is the node that originally took the snapshot, if known.
+func newSnapshotList(esfs []k3s.ETCDSnapshotFile) *snapshotList {
+	sl := &snapshotList{Snapshots: []snapshotListItem{}}
+	for _, esf := range esfs {
+		item := snapshotListItem{
+			Name:        esf.Spec.SnapshotName,
+			Location:    esf.Spec.Location,
+			Storage:     "local",
+			NodeName:    esf.Spec.NodeName,
+			CreatedAt:   esf.Status.CreationTime,
+			Compression: snapshot.CompressFormat(esf.Spec.SnapshotName),
+			Checksum:    esf.Annotations[snapshot.AnnotationChecksum],
+			Metadata:    esf.Spec.Metadata,
+			S3:          esf.Spec.S3,
+		}
+		item.Compressed = item.Compression != ""
+		if esf.Spec.S3 != nil {
+			item.Storage = "s3"
+		}
+		if esf.Status.Size != nil {
+			item.Size = esf.Status.Size.Value()
+		}
+		sl.Snapshots = append(sl.Snapshots, item)
+	}
+	return sl
+}
@@ -0,0 +1,246 @@
+package kubeconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// minTTL is the minimum expiration accepted by the apiserver for token requests.
+	minTTL = 10 * time.Minute
+	// tokenSecretTimeout is the maximum time to wait for the token controller to populate a service account token secret.
+	tokenSecretTimeout = 30 * time.Second
+)
+
+// generatedLabel is set on service accounts, role bindings, and token secrets created by the kubeconfig generate
+// command, so that they can be listed and removed when the kubeconfig is no longer needed.
+var generatedLabel = version.Program + ".io/generated-kubeconfig"
+
+func Generate(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return generate(app, &cmds.KubeconfigConfig)
+}
+
+func generate(app *cli.Context, cfg *cmds.Kubeconfig) error {
+	if cfg.Namespace == "" {
+		return errors.New("missing flag; 'kubeconfig generate' requires --namespace")
+	}
+	if cfg.Role == "" {
+		return errors.New("missing flag; 'kubeconfig generate' requires --role")
+	}
+	if cfg.TTL != 0 && cfg.TTL < minTTL {
+		return fmt.Errorf("invalid ttl %s: must be at least %s, or 0 for a token that does not expire", cfg.TTL, minTTL)
+	}
+	if cfg.Name == "" {
+		cfg.Name = "kubeconfig-" + cfg.Role
+	}
+
+	cfg.Kubeconfig = util.GetKubeConfigPath(cfg.Kubeconfig)
+	restConfig, err := util.GetRESTConfig(cfg.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	caData := restConfig.TLSClientConfig.CAData
+	if len(caData) == 0 && restConfig.TLSClientConfig.CAFile != "" {
+		if caData, err = os.ReadFile(restConfig.TLSClientConfig.CAFile); err != nil {
+			return err
+		}
+	}
+	server := cfg.ServerURL
+	if server == "" {
+		server = restConfig.Host
+	}
+
+	ctx := app.Context
+	if _, err := client.CoreV1().Namespaces().Get(ctx, cfg.Namespace, metav1.GetOptions{}); err != nil {
+		return errors.WithMessagef(err, "failed to get namespace %s", cfg.Namespace)
+	}
+	if _, err := client.RbacV1().ClusterRoles().Get(ctx, cfg.Role, metav1.GetOptions{}); err != nil {
+		return errors.WithMessagef(err, "failed to get ClusterRole %s", cfg.Role)
+	}
+	if err := ensureServiceAccount(ctx, client, cfg.Namespace, cfg.Name); err != nil {
+		return errors.WithMessagef(err, "failed to create ServiceAccount %s/%s", cfg.Namespace, cfg.Name)
+	}
+	if err := ensureRoleBinding(ctx, client, cfg.Namespace, cfg.Name, cfg.Role); err != nil {
+		return errors.WithMessagef(err, "failed to create RoleBinding %s/%s", cfg.Namespace, cfg.Name)
+	}
+
+	var token string
+	if cfg.TTL > 0 {
+		token, err = requestToken(ctx, client, cfg.Namespace, cfg.Name, cfg.TTL)
+	} else {
+		token, err = secretToken(ctx, client, cfg.Namespace, cfg.Name)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "failed to get token for ServiceAccount %s/%s", cfg.Namespace, cfg.Name)
+	}
+
+	b, err := clientcmd.Write(*newKubeconfig(server, caData, cfg.Namespace, cfg.Name, token))
+	if err != nil {
+		return err
+	}
+	if cfg.Output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	if err := os.WriteFile(cfg.Output, b, 0600); err != nil {
+		return err
+	}
+	logrus.Infof("Wrote kubeconfig for ServiceAccount %s/%s with role %s to %s", cfg.Namespace, cfg.Name, cfg.Role, cfg.Output)
+	return nil
+}
+
+// ensureServiceAccount creates the service account, if it does not already exist.
+func ensureServiceAccount(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	_, err := client.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{generatedLabel: "true"},
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// ensureRoleBinding binds the ClusterRole to the service account within the namespace, if it is not already bound.
+// The role of an existing binding cannot be changed, so an error is returned if the binding exists with a different role.
+func ensureRoleBinding(ctx context.Context, client kubernetes.Interface, namespace, name, role string) error {
+	subject := rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      name,
+		Namespace: namespace,
+	}
+	roleRef := rbacv1.RoleRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "ClusterRole",
+		Name:     role,
+	}
+
+	binding, err := client.RbacV1().RoleBindings(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.RbacV1().RoleBindings(namespace).Create(ctx, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{generatedLabel: "true"},
+			},
+			Subjects: []rbacv1.Subject{subject},
+			RoleRef:  roleRef,
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if binding.RoleRef != roleRef {
+		return fmt.Errorf("RoleBinding already exists with %s %s; use --name to create a new service account", binding.RoleRef.Kind, binding.RoleRef.Name)
+	}
+	for _, s := range binding.Subjects {
+		if s == subject {
+			return nil
+		}
+	}
+	binding = binding.DeepCopy()
+	binding.Subjects = append(binding.Subjects, subject)
+	_, err = client.RbacV1().RoleBindings(namespace).Update(ctx, binding, metav1.UpdateOptions{})
+	return err
+}
+
+// requestToken requests a token for the service account that expires after the given duration.
+func requestToken(ctx context.Context, client kubernetes.Interface, namespace, name string, ttl time.Duration) (string, error) {
+	expirationSeconds := int64(ttl.Seconds())
+	tr, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("Token for ServiceAccount %s/%s expires at %s", namespace, name, tr.Status.ExpirationTimestamp.Format(time.RFC3339))
+	return tr.Status.Token, nil
+}
+
+// secretToken creates a service account token secret, if it does not already exist, and waits for the token
+// controller to populate it. The token does not expire, but is invalidated when the secret or service account is deleted.
+func secretToken(ctx context.Context, client kubernetes.Interface, namespace, name string) (string, error) {
+	secretName := name + "-token"
+	_, err := client.CoreV1().Secrets(namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secretName,
+			Namespace:   namespace,
+			Labels:      map[string]string{generatedLabel: "true"},
+			Annotations: map[string]string{corev1.ServiceAccountNameKey: name},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+
+	var token string
+	err = wait.PollUntilContextTimeout(ctx, time.Second, tokenSecretTimeout, true, func(ctx context.Context) (bool, error) {
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if secret.Type != corev1.SecretTypeServiceAccountToken || secret.Annotations[corev1.ServiceAccountNameKey] != name {
+			return false, fmt.Errorf("secret %s/%s is not a token for ServiceAccount %s", namespace, secretName, name)
+		}
+		token = string(secret.Data[corev1.ServiceAccountTokenKey])
+		return token != "", nil
+	})
+	return token, err
+}
+
+// newKubeconfig returns a kubeconfig that authenticates to the server with the token, with the namespace set
+// as the default namespace for the context.
+func newKubeconfig(server string, caData []byte, namespace, name, token string) *clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.CertificateAuthorityData = caData
+	cluster.Server = server
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Token = token
+
+	context := clientcmdapi.NewContext()
+	context.AuthInfo = name
+	context.Cluster = "default"
+	context.Namespace = namespace
+
+	config.Clusters["default"] = cluster
+	config.AuthInfos[name] = authInfo
+	config.Contexts["default"] = context
+	config.CurrentContext = "default"
+	return config
+}
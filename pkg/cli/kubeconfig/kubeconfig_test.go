@@ -0,0 +1,79 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_UnitEnsureRoleBinding(t *testing.T) {
+	existing := func(role string, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeconfig-edit", Namespace: "team-a"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+			Subjects:   subjects,
+		}
+	}
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "kubeconfig-edit", Namespace: "team-a"}
+
+	tests := []struct {
+		name    string
+		binding *rbacv1.RoleBinding
+		wantErr bool
+	}{
+		{
+			name: "new binding",
+		},
+		{
+			name:    "existing binding",
+			binding: existing("edit", subject),
+		},
+		{
+			name:    "existing binding without subject",
+			binding: existing("edit"),
+		},
+		{
+			name:    "existing binding with different role",
+			binding: existing("admin", subject),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset()
+			if tt.binding != nil {
+				client = fake.NewClientset(tt.binding)
+			}
+			err := ensureRoleBinding(t.Context(), client, "team-a", "kubeconfig-edit", "edit")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureRoleBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			binding, err := client.RbacV1().RoleBindings("team-a").Get(t.Context(), "kubeconfig-edit", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if binding.RoleRef.Name != "edit" || len(binding.Subjects) != 1 || binding.Subjects[0] != subject {
+				t.Errorf("ensureRoleBinding() binding = %+v, want role edit bound to %+v", binding, subject)
+			}
+		})
+	}
+}
+
+func Test_UnitNewKubeconfig(t *testing.T) {
+	config := newKubeconfig("https://10.0.0.1:6443", []byte("ca"), "team-a", "kubeconfig-edit", "token")
+	context := config.Contexts[config.CurrentContext]
+	if context == nil || context.Namespace != "team-a" {
+		t.Fatalf("newKubeconfig() current context = %+v, want namespace team-a", context)
+	}
+	if authInfo := config.AuthInfos[context.AuthInfo]; authInfo == nil || authInfo.Token != "token" {
+		t.Errorf("newKubeconfig() auth info = %+v, want token", authInfo)
+	}
+	if cluster := config.Clusters[context.Cluster]; cluster == nil || cluster.Server != "https://10.0.0.1:6443" {
+		t.Errorf("newKubeconfig() cluster = %+v, want server https://10.0.0.1:6443", cluster)
+	}
+}
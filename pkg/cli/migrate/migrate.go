@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// clusterDomainDropin is the name of the config file dropin written by the cluster-domain migration.
+const clusterDomainDropin = "90-migrate-cluster-domain.yaml"
+
+// clusterDomainConfig is the content of the config file dropin written by the cluster-domain migration.
+// The service-account-issuer and audience for each previous cluster domain are retained so that existing
+// ServiceAccount tokens remain valid after the migration.
+type clusterDomainConfig struct {
+	ClusterDomain    string   `yaml:"cluster-domain"`
+	KubeAPIServerArg []string `yaml:"kube-apiserver-arg+,omitempty"`
+}
+
+func ClusterDomain(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return clusterDomain(app, &cmds.ServerConfig, &cmds.MigrateConfig)
+}
+
+// clusterDomain writes a config file dropin that changes the cluster domain, while continuing to
+// accept ServiceAccount tokens issued for the previous domain. The remaining migration steps are
+// handled by the server when it is next started: the kube-apiserver serving certificate is
+// regenerated with the new SANs, the CoreDNS manifest and cluster-dns ConfigMap are updated,
+// and kubelets receive the new domain when their agent reconnects.
+func clusterDomain(app *cli.Context, cfg *cmds.Server, mcfg *cmds.Migrate) error {
+	newDomain := strings.TrimSuffix(mcfg.ClusterDomain, ".")
+	if errs := validation.IsDNS1123Subdomain(newDomain); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster domain %q: %s", mcfg.ClusterDomain, strings.Join(errs, ", "))
+	}
+
+	oldDomain := cfg.ClusterDomain
+	if newDomain == oldDomain {
+		return fmt.Errorf("cluster domain is already %s", newDomain)
+	}
+
+	if err := checkStopped(cfg, "the cluster domain"); err != nil {
+		return err
+	}
+
+	dropinDir, dropinFile, err := dropinPath(app, clusterDomainDropin)
+	if err != nil {
+		return err
+	}
+
+	// retain issuers from any previous migration, so that tokens issued for all previous domains remain valid
+	dc := &clusterDomainConfig{}
+	if err := readDropin(dropinFile, dc); err != nil {
+		return errors.WithMessage(err, "failed to read previous cluster-domain migration config")
+	}
+
+	dc.ClusterDomain = newDomain
+	for _, arg := range previousDomainArgs(oldDomain) {
+		if !slices.Contains(dc.KubeAPIServerArg, arg) {
+			dc.KubeAPIServerArg = append(dc.KubeAPIServerArg, arg)
+		}
+	}
+	// args for the new domain are already set by the server, and do not need to be appended if migrating back
+	dc.KubeAPIServerArg = slices.DeleteFunc(dc.KubeAPIServerArg, func(arg string) bool {
+		return slices.Contains(previousDomainArgs(newDomain), arg)
+	})
+
+	header := fmt.Sprintf("Written by %s migrate cluster-domain; migrated from %s to %s", version.Program, oldDomain, newDomain)
+	if err := writeDropin(dropinDir, dropinFile, header, dc); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nCluster domain will be changed from %s to %s the next time %s starts on this node.\n", oldDomain, newDomain, version.Program)
+	fmt.Printf("If cluster-domain is set on the %s command line, update it there as well, as command-line flags take precedence over config files.\n", version.Program)
+	fmt.Printf("To finish migrating the cluster:\n")
+	fmt.Printf("  1. Run this command on each other server node while %s is stopped.\n", version.Program)
+	fmt.Printf("  2. Start %s on each server node. The kube-apiserver certificate, CoreDNS, and cluster-dns ConfigMap will be updated automatically.\n", version.Program)
+	fmt.Printf("  3. Restart %s on each agent node, so that the kubelet is configured with the new cluster domain.\n", version.Program)
+	fmt.Printf("  4. Restart workloads, so that Pod DNS search domains are updated. ServiceAccount tokens issued for %s will continue to be accepted.\n", oldDomain)
+	return nil
+}
+
+// checkStopped returns an error if the server is still running on this node.
+func checkStopped(cfg *cmds.Server, what string) error {
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(cfg.HTTPSPort)), time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is still running on this node; stop the %s service before migrating %s", version.Program, version.Program, what)
+	}
+	return nil
+}
+
+// dropinPath returns the config file dropin directory, and the path to the named dropin file within it.
+func dropinPath(app *cli.Context, name string) (string, string, error) {
+	configFile := app.String("config")
+	if configFile == "" {
+		return "", "", errors.New("config file path must be set")
+	}
+	dropinDir := configFile + ".d"
+	return dropinDir, filepath.Join(dropinDir, name), nil
+}
+
+// readDropin reads a config file dropin written by a previous migration, if one exists.
+func readDropin(dropinFile string, content any) error {
+	b, err := os.ReadFile(dropinFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return yaml.Unmarshal(b, content)
+}
+
+// writeDropin writes a config file dropin, with a header comment noting the migration that wrote it.
+func writeDropin(dropinDir, dropinFile, header string, content any) error {
+	b, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+	b = append([]byte("# "+header+"\n"), b...)
+
+	if err := os.MkdirAll(dropinDir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dropinFile, b, 0600); err != nil {
+		return err
+	}
+	logrus.Infof("Wrote migration config to %s", dropinFile)
+	return nil
+}
+
+// previousDomainArgs returns the kube-apiserver args that append the service-account-issuer and api-audiences
+// for a previous cluster domain to the values set by the server. The first issuer is used to sign new tokens,
+// while tokens from additional issuers and audiences continue to be accepted.
+func previousDomainArgs(domain string) []string {
+	return []string{
+		"service-account-issuer+=https://kubernetes.default.svc." + domain,
+		"api-audiences+=https://kubernetes.default.svc." + domain,
+	}
+}
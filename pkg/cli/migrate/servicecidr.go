@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+	utilsnet "k8s.io/utils/net"
+)
+
+// serviceCIDRDropin is the name of the config file dropin written by the service-cidr migration.
+const serviceCIDRDropin = "90-migrate-service-cidr.yaml"
+
+// maxServiceCIDRBits is the largest number of host bits allowed by the kube-apiserver
+// in a service CIDR, for either IP family.
+const maxServiceCIDRBits = 20
+
+// serviceCIDRConfig is the content of the config file dropin written by the service-cidr migration.
+// The cluster DNS address is pinned to its current value, and the current kubernetes Service address
+// is retained as a SAN, so that existing Services and Pods are not affected by the migration.
+type serviceCIDRConfig struct {
+	ServiceCIDR string   `yaml:"service-cidr"`
+	ClusterDNS  string   `yaml:"cluster-dns,omitempty"`
+	TLSSAN      []string `yaml:"tls-san+,omitempty"`
+}
+
+func ServiceCIDR(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return serviceCIDR(app, &cmds.ServerConfig, &cmds.MigrateConfig)
+}
+
+// serviceCIDR writes a config file dropin that expands the service CIDR. The remaining migration steps
+// are handled by the server when it is next started: the kube-apiserver is started with the new range,
+// and a ServiceCIDR resource covering the new range is created so that Service ClusterIPs can be
+// allocated from it. Agents receive the new range for kube-proxy, flannel, and network policy when
+// they are restarted.
+func serviceCIDR(app *cli.Context, cfg *cmds.Server, mcfg *cmds.Migrate) error {
+	oldCIDRs, err := parseCIDRs(util.SplitStringSlice(cfg.ServiceCIDR.Value()))
+	if err != nil {
+		return errors.WithMessage(err, "invalid service-cidr")
+	}
+	if len(oldCIDRs) == 0 {
+		// the default service CIDR is based on the IP family of the node address
+		_, _, defaultServiceCIDR, err := util.GetDefaultAddresses(net.ParseIP(firstNodeIP()))
+		if err != nil {
+			return errors.WithMessage(err, "failed to determine default service-cidr")
+		}
+		if oldCIDRs, err = parseCIDRs([]string{defaultServiceCIDR}); err != nil {
+			return err
+		}
+	}
+
+	newCIDRs, err := parseCIDRs(util.SplitStringSlice([]string{mcfg.ServiceCIDR}))
+	if err != nil {
+		return errors.WithMessage(err, "invalid service CIDR")
+	}
+	if err := validateServiceCIDRMigration(oldCIDRs, newCIDRs); err != nil {
+		return err
+	}
+
+	if err := checkStopped(cfg, "the service CIDR"); err != nil {
+		return err
+	}
+
+	dropinDir, dropinFile, err := dropinPath(app, serviceCIDRDropin)
+	if err != nil {
+		return err
+	}
+
+	sc := &serviceCIDRConfig{}
+	if err := readDropin(dropinFile, sc); err != nil {
+		return errors.WithMessage(err, "failed to read previous service-cidr migration config")
+	}
+
+	sc.ServiceCIDR = joinCIDRs(newCIDRs)
+
+	// pin the cluster DNS address to its current value, which would otherwise be derived from the new range
+	clusterDNS := util.SplitStringSlice(cfg.ClusterDNS.Value())
+	if len(clusterDNS) == 0 {
+		for _, cidr := range oldCIDRs {
+			ip, err := utilsnet.GetIndexedIP(cidr, 10)
+			if err != nil {
+				return errors.WithMessage(err, "failed to determine current cluster-dns address")
+			}
+			clusterDNS = append(clusterDNS, ip.String())
+		}
+	}
+	sc.ClusterDNS = strings.Join(clusterDNS, ",")
+
+	// retain the current kubernetes Service address as a SAN, as the Service keeps its existing ClusterIP
+	apiServerServiceIP, err := utilsnet.GetIndexedIP(oldCIDRs[0], 1)
+	if err != nil {
+		return errors.WithMessage(err, "failed to determine current kubernetes service address")
+	}
+	if !slices.Contains(sc.TLSSAN, apiServerServiceIP.String()) {
+		sc.TLSSAN = append(sc.TLSSAN, apiServerServiceIP.String())
+	}
+
+	header := fmt.Sprintf("Written by %s migrate service-cidr; migrated from %s to %s", version.Program, joinCIDRs(oldCIDRs), sc.ServiceCIDR)
+	if err := writeDropin(dropinDir, dropinFile, header, sc); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nService CIDR will be changed from %s to %s the next time %s starts on this node.\n", joinCIDRs(oldCIDRs), sc.ServiceCIDR, version.Program)
+	fmt.Printf("If service-cidr or cluster-dns is set on the %s command line, update it there as well, as command-line flags take precedence over config files.\n", version.Program)
+	fmt.Printf("Existing Services, including the cluster DNS and kubernetes Services, will keep their current addresses.\n")
+	fmt.Printf("To finish migrating the cluster:\n")
+	fmt.Printf("  1. Run this command on each other server node while %s is stopped.\n", version.Program)
+	fmt.Printf("  2. Start %s on each server node, one at a time. A ServiceCIDR resource for the new range will be created automatically.\n", version.Program)
+	fmt.Printf("  3. Restart %s on each agent node, so that kube-proxy, flannel, and network policy are configured with the new range.\n", version.Program)
+	fmt.Printf("  4. Confirm that the ServiceCIDR is ready with 'kubectl get servicecidr' before creating Services that require addresses from the new range.\n")
+	return nil
+}
+
+// validateServiceCIDRMigration checks that each new service CIDR contains the corresponding
+// current CIDR, so that existing Service ClusterIPs remain valid after the migration.
+func validateServiceCIDRMigration(oldCIDRs, newCIDRs []*net.IPNet) error {
+	if len(newCIDRs) != len(oldCIDRs) {
+		return fmt.Errorf("new service CIDR must have one range for each IP family in the current service-cidr %s", joinCIDRs(oldCIDRs))
+	}
+	changed := false
+	for i, newCIDR := range newCIDRs {
+		oldCIDR := oldCIDRs[i]
+		if utilsnet.IsIPv6CIDR(newCIDR) != utilsnet.IsIPv6CIDR(oldCIDR) {
+			return fmt.Errorf("new service CIDR %s must be the same IP family as %s", newCIDR, oldCIDR)
+		}
+		newOnes, bits := newCIDR.Mask.Size()
+		oldOnes, _ := oldCIDR.Mask.Size()
+		if !newCIDR.Contains(oldCIDR.IP) || newOnes > oldOnes {
+			return fmt.Errorf("new service CIDR %s must contain the current service CIDR %s", newCIDR, oldCIDR)
+		}
+		if bits-newOnes > maxServiceCIDRBits {
+			return fmt.Errorf("new service CIDR %s is too large; the prefix length must be at least /%d", newCIDR, bits-maxServiceCIDRBits)
+		}
+		if newOnes != oldOnes {
+			changed = true
+		}
+	}
+	if !changed {
+		return fmt.Errorf("service CIDR is already %s", joinCIDRs(oldCIDRs))
+	}
+	return nil
+}
+
+// firstNodeIP returns the first node IP address set in the config, or the IPv4
+// loopback address if none is set.
+func firstNodeIP() string {
+	if ips := util.SplitStringSlice(cmds.AgentConfig.NodeIP.Value()); len(ips) > 0 {
+		return ips[0]
+	}
+	return "127.0.0.1"
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var res []*net.IPNet
+	for _, cidr := range cidrs {
+		_, parsed, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, parsed)
+	}
+	return res, nil
+}
+
+func joinCIDRs(cidrs []*net.IPNet) string {
+	var res []string
+	for _, cidr := range cidrs {
+		res = append(res, cidr.String())
+	}
+	return strings.Join(res, ",")
+}
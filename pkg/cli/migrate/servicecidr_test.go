@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func Test_UnitValidateServiceCIDRMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldCIDRs []string
+		newCIDRs []string
+		wantErr  bool
+	}{
+		{
+			name:     "expand IPv4",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.40.0.0/13"},
+		},
+		{
+			name:     "expand dual-stack",
+			oldCIDRs: []string{"10.43.0.0/16", "fd00:43::/112"},
+			newCIDRs: []string{"10.43.0.0/16", "fd00:43::/108"},
+		},
+		{
+			name:     "unchanged",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.43.0.0/16"},
+			wantErr:  true,
+		},
+		{
+			name:     "does not contain current range",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.48.0.0/12"},
+			wantErr:  true,
+		},
+		{
+			name:     "smaller range",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.43.0.0/20"},
+			wantErr:  true,
+		},
+		{
+			name:     "too large",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.0.0.0/8"},
+			wantErr:  true,
+		},
+		{
+			name:     "different IP family",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"fd00:43::/108"},
+			wantErr:  true,
+		},
+		{
+			name:     "different number of ranges",
+			oldCIDRs: []string{"10.43.0.0/16"},
+			newCIDRs: []string{"10.40.0.0/13", "fd00:43::/108"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCIDRs, err := parseCIDRs(tt.oldCIDRs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newCIDRs, err := parseCIDRs(tt.newCIDRs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateServiceCIDRMigration(oldCIDRs, newCIDRs); (err != nil) != tt.wantErr {
+				t.Errorf("validateServiceCIDRMigration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/server/handlers"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// defaultTimeout is the timeout for each request to the server.
+	defaultTimeout = 30 * time.Second
+	// pollInterval is the interval at which the progress of a drain is polled.
+	pollInterval = 2 * time.Second
+)
+
+func commandPrep(cfg *cmds.Server) (*clientaccess.Info, error) {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " node")
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" {
+		fp := filepath.Join(dataDir, "token")
+		tokenByte, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+	return clientaccess.ParseAndValidateToken(cmds.ServerConfig.ServerURL, cfg.Token, clientaccess.WithUser("server"))
+}
+
+func wrapServerError(err error) error {
+	return errors.WithMessage(err, "see server log for details")
+}
+
+func Cordon(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return nodeRequest(app, handlers.NodeRequest{Action: handlers.NodeActionCordon})
+}
+
+func Uncordon(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return nodeRequest(app, handlers.NodeRequest{Action: handlers.NodeActionUncordon})
+}
+
+func Drain(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	cfg := &cmds.NodeConfig
+	return nodeRequest(app, handlers.NodeRequest{
+		Action:             handlers.NodeActionDrain,
+		Force:              cfg.Force,
+		IgnoreDaemonSets:   cfg.IgnoreDaemonSets,
+		DeleteEmptyDirData: cfg.DeleteEmptyDirData,
+		DisableEviction:    cfg.DisableEviction,
+		GracePeriodSeconds: cfg.GracePeriod,
+		PodSelector:        cfg.PodSelector,
+		Timeout:            cfg.Timeout,
+	})
+}
+
+// Approve approves a node that is pending approval to join the cluster. If no node name is given,
+// the nodes that are pending approval are listed.
+func Approve(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return nodeRequest(app, handlers.NodeRequest{Action: handlers.NodeActionApprove})
+}
+
+// Promote promotes the control-plane standby server that the command is run against. The server restarts
+// after responding, so it is not possible to wait for the control-plane components to become ready.
+func Promote(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	if app.Args().Len() != 0 {
+		return errors.New("promote does not accept a node name; run it against the standby server")
+	}
+	info, err := commandPrep(&cmds.ServerConfig)
+	if err != nil {
+		return err
+	}
+	if _, err := info.Post("/v1-"+version.Program+"/standby/promote", nil, clientaccess.WithTimeout(defaultTimeout)); err != nil {
+		return wrapServerError(err)
+	}
+	fmt.Println("Control-plane standby promoted; the server will restart with the control-plane components enabled")
+	return nil
+}
+
+func nodeRequest(app *cli.Context, nodeReq handlers.NodeRequest) error {
+	if nodeReq.Action == handlers.NodeActionApprove && app.Args().Len() > 1 {
+		return fmt.Errorf("at most one node name may be given to %s", nodeReq.Action)
+	} else if nodeReq.Action != handlers.NodeActionApprove && app.Args().Len() != 1 {
+		return fmt.Errorf("exactly one node name must be given to %s", nodeReq.Action)
+	}
+	nodeReq.Name = app.Args().First()
+
+	info, err := commandPrep(&cmds.ServerConfig)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(nodeReq)
+	if err != nil {
+		return err
+	}
+
+	nodePath := "/v1-" + version.Program + "/node"
+	r, err := info.Post(nodePath, b, clientaccess.WithTimeout(defaultTimeout))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	resp := handlers.NodeResponse{}
+	if err := json.Unmarshal(r, &resp); err != nil {
+		return err
+	}
+
+	// drains run in the background on the server; poll until the drain completes, printing new output as it is received.
+	var printed int
+	for resp.Status == handlers.NodeDrainRunning {
+		if len(resp.Output) > printed {
+			fmt.Print(resp.Output[printed:])
+			printed = len(resp.Output)
+		}
+		time.Sleep(pollInterval)
+		r, err := info.Get(nodePath+"?name="+url.QueryEscape(nodeReq.Name), clientaccess.WithTimeout(defaultTimeout))
+		if err != nil {
+			return wrapServerError(err)
+		}
+		resp = handlers.NodeResponse{}
+		if err := json.Unmarshal(r, &resp); err != nil {
+			return err
+		}
+	}
+	if len(resp.Output) > printed {
+		fmt.Print(resp.Output[printed:])
+	}
+	if resp.Status == handlers.NodeDrainFailed {
+		return wrapServerError(fmt.Errorf("failed to drain node %s: %s", nodeReq.Name, resp.Error))
+	}
+	return nil
+}
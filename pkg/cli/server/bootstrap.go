@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/cluster"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// BootstrapExport writes the bootstrap data stored in the datastore to a file or stdout.
+func BootstrapExport(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return bootstrapExport(app.Context, &cmds.ServerConfig, &cmds.ServerBootstrapConfig)
+}
+
+func bootstrapExport(ctx context.Context, cfg *cmds.Server, bs *cmds.ServerBootstrap) error {
+	// decrypted bootstrap data contains the cluster CA private keys; do not write it to a
+	// terminal or log by default, and require that a file be explicitly specified instead.
+	if bs.Decrypt && (bs.File == "" || bs.File == "-") {
+		return errors.New("--output file is required when exporting decrypted bootstrap data")
+	}
+
+	controlConfig, err := bootstrapSetup(cfg, "export")
+	if err != nil {
+		return err
+	}
+
+	data, err := cluster.ExportBootstrap(ctx, controlConfig, bs.Decrypt)
+	if err != nil {
+		return err
+	}
+
+	if bs.File == "" || bs.File == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := util.AtomicWrite(bs.File, data, 0600); err != nil {
+		return err
+	}
+	logrus.Infof("Bootstrap data exported to %s", bs.File)
+	return nil
+}
+
+// BootstrapImport writes previously exported bootstrap data to the datastore.
+func BootstrapImport(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return bootstrapImport(app.Context, &cmds.ServerConfig, &cmds.ServerBootstrapConfig)
+}
+
+func bootstrapImport(ctx context.Context, cfg *cmds.Server, bs *cmds.ServerBootstrap) error {
+	controlConfig, err := bootstrapSetup(cfg, "import")
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if bs.File == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(bs.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cluster.ImportBootstrap(ctx, controlConfig, bytes.TrimSpace(data), bs.Force); err != nil {
+		return errors.WithMessage(err, "failed to import bootstrap data")
+	}
+	logrus.Infof("Bootstrap data imported from %s", bs.File)
+	return nil
+}
+
+// bootstrapSetup returns a control config with the data-dir, token, and datastore
+// settings needed to connect to the datastore from the command line.
+func bootstrapSetup(cfg *cmds.Server, op string) (*config.Control, error) {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " server bootstrap " + op)
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" {
+		tokenByte, err := os.ReadFile(filepath.Join(dataDir, "token"))
+		if err != nil {
+			return nil, errors.WithMessage(err, "token is required")
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+
+	controlConfig := &config.Control{
+		DataDir: dataDir,
+		Token:   cfg.Token,
+		Runtime: config.NewRuntime(),
+	}
+	controlConfig.Datastore.Endpoint = cfg.DatastoreEndpoint
+	controlConfig.Datastore.BackendTLSConfig.CAFile = cfg.DatastoreCAFile
+	controlConfig.Datastore.BackendTLSConfig.CertFile = cfg.DatastoreCertFile
+	controlConfig.Datastore.BackendTLSConfig.KeyFile = cfg.DatastoreKeyFile
+	deps.CreateRuntimeCertFiles(controlConfig)
+	return controlConfig, nil
+}
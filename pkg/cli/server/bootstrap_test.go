@@ -0,0 +1,17 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+)
+
+func Test_UnitBootstrapExportDecryptRequiresOutput(t *testing.T) {
+	for _, file := range []string{"", "-"} {
+		bs := &cmds.ServerBootstrap{File: file, Decrypt: true}
+		if err := bootstrapExport(context.Background(), &cmds.Server{}, bs); err == nil {
+			t.Errorf("bootstrapExport() with --decrypt and output %q succeeded, want error", file)
+		}
+	}
+}
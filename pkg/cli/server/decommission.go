@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Decommission requests that the local server permanently remove itself from the cluster.
+// The request is always sent to the supervisor on the loopback address, as the server flag
+// (if set) refers to the server that this server joined, not this server itself.
+func Decommission(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return decommission(&cmds.ServerConfig)
+}
+
+func decommission(cfg *cmds.Server) error {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " server decommission")
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Token == "" {
+		tokenByte, err := os.ReadFile(filepath.Join(dataDir, "token"))
+		if err != nil {
+			return err
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+
+	port := cfg.SupervisorPort
+	if port == 0 {
+		port = cfg.HTTPSPort
+	}
+	info, err := clientaccess.ParseAndValidateToken(fmt.Sprintf("https://127.0.0.1:%d", port), cfg.Token, clientaccess.WithUser("server"))
+	if err != nil {
+		return err
+	}
+
+	if _, err := info.Post("/db/decommission", nil); err != nil {
+		return errors.WithMessage(err, "see server log for details")
+	}
+
+	logrus.Infof("Server decommissioned. Stop the %s service; the data directory at %s may now be removed", version.Program, filepath.Dir(dataDir))
+	return nil
+}
@@ -6,26 +6,47 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/k3s-io/k3s/pkg/agent"
+	"github.com/k3s-io/k3s/pkg/agent/discovery"
 	"github.com/k3s-io/k3s/pkg/agent/https"
 	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/authlockout"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/containerized"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/datadir"
+	"github.com/k3s-io/k3s/pkg/deploy"
+	"github.com/k3s-io/k3s/pkg/dnsautoscaler"
 	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/etcd/remote"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/gitbackup"
+	"github.com/k3s-io/k3s/pkg/guardrails"
+	"github.com/k3s-io/k3s/pkg/hooks"
+	"github.com/k3s-io/k3s/pkg/imagepolicy"
+	"github.com/k3s-io/k3s/pkg/loadshed"
 	k3smetrics "github.com/k3s-io/k3s/pkg/metrics"
+	"github.com/k3s-io/k3s/pkg/node"
+	"github.com/k3s-io/k3s/pkg/permmonitor"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/profile"
 	"github.com/k3s-io/k3s/pkg/rootless"
+	"github.com/k3s-io/k3s/pkg/routeexport"
+	"github.com/k3s-io/k3s/pkg/secretstore"
 	"github.com/k3s-io/k3s/pkg/server"
 	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/spegel"
+	"github.com/k3s-io/k3s/pkg/standby"
+	"github.com/k3s-io/k3s/pkg/telemetry"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/util/logger"
@@ -36,6 +57,7 @@ import (
 
 	systemd "github.com/coreos/go-systemd/v22/daemon"
 	helmchart "github.com/k3s-io/helm-controller/pkg/controllers/chart"
+	kinemetrics "github.com/k3s-io/kine/pkg/metrics"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -55,6 +77,34 @@ func RunWithControllers(app *cli.Context, leaderControllers server.CustomControl
 	return run(app, &cmds.ServerConfig, leaderControllers, controllers)
 }
 
+// validateComponentArgs validates extra args against the flags supported by the embedded components
+// as soon as the command line and config file have been parsed, so that unsupported flags are rejected
+// before anything is started, instead of causing the component to exit after startup.
+func validateComponentArgs(cfg *cmds.Server) error {
+	componentArgs := map[string][]string{}
+	if !cfg.EtcdWitness {
+		if !cfg.DisableAPIServer {
+			componentArgs[executor.ComponentAPIServer] = cfg.ExtraAPIArgs.Value()
+		}
+		if !cfg.DisableControllerManager {
+			componentArgs[executor.ComponentControllerManager] = cfg.ExtraControllerArgs.Value()
+		}
+		if !cfg.DisableScheduler {
+			componentArgs[executor.ComponentScheduler] = cfg.ExtraSchedulerArgs.Value()
+		}
+	}
+	if !cfg.DisableAgent {
+		componentArgs[executor.ComponentKubelet] = cmds.AgentConfig.ExtraKubeletArgs.Value()
+		componentArgs[executor.ComponentKubeProxy] = cmds.AgentConfig.ExtraKubeProxyArgs.Value()
+	}
+	for component, args := range componentArgs {
+		if err := executor.ValidateArgs(component, args); err != nil {
+			return errors.WithMessage(err, "invalid flag use; unsupported "+component+" args")
+		}
+	}
+	return nil
+}
+
 func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomControllers, controllers server.CustomControllers) (rerr error) {
 	var err error
 
@@ -62,6 +112,25 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	// database credentials or other secrets.
 	proctitle.SetProcTitle(os.Args[0] + " server")
 
+	if err := validateComponentArgs(cfg); err != nil {
+		return err
+	}
+
+	containerizedMode, err := containerized.Resolve(cmds.AgentConfig.Containerized, cfg.DisableAgent)
+	if err != nil {
+		return err
+	}
+	cmds.AgentConfig.Containerized = containerizedMode
+
+	componentEnv, err := cmds.ParseComponentEnv(cmds.AgentConfig.ComponentEnv.Value())
+	if err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported component env")
+	}
+	startupHooks, err := cmds.ParseHooks(&cmds.AgentConfig)
+	if err != nil {
+		return errors.WithMessage(err, "invalid flag use; unsupported hook")
+	}
+
 	// If the agent is enabled, evacuate cgroup v2 before doing anything else that may fork.
 	// If the agent is disabled, we don't need to bother doing this as it is only the kubelet
 	// that cares about cgroups.
@@ -77,6 +146,12 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		return err
 	}
 
+	timeouts, err := signals.ParsePhaseTimeouts(cmds.AgentConfig.ShutdownPhaseTimeouts)
+	if err != nil {
+		return errors.WithMessage(err, "invalid shutdown-phase-timeouts")
+	}
+	signals.SetPhaseTimeouts(timeouts)
+
 	klog.EnableContextualLogging(true)
 	ctx := logger.NewContext(signals.SetupSignalContext(), "server")
 	wg := &sync.WaitGroup{}
@@ -94,7 +169,9 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 			<-ctx.Done()
 			rerr = ctx.Err()
 		}
-		wg.Wait()
+		if timeout := signals.PhaseTimeout(signals.PhaseETCD); !signals.Wait(wg, timeout) {
+			logrus.Warnf("Shutdown phase %s did not complete within %s", signals.PhaseETCD, timeout)
+		}
 	}()
 
 	if !cfg.DisableAgent && !cfg.Rootless {
@@ -120,12 +197,54 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		}
 	}
 
+	// If a snapshot restore was requested by an ETCDSnapshotRestore resource before the last shutdown,
+	// perform a cluster-reset restore of the snapshot that was retrieved before restarting.
+	if !cfg.ClusterReset && !cfg.DisableETCD && cfg.DatastoreEndpoint == "" {
+		dataDir, err := datadir.LocalHome(cfg.DataDir, false)
+		if err != nil {
+			return err
+		}
+		path, force, err := etcd.PendingRestore(filepath.Join(dataDir, "server"))
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			logrus.Infof("Restoring etcd snapshot %s as requested by ETCDSnapshotRestore resource", path)
+			if cfg.ServerURL != "" {
+				logrus.Infof("Ignoring server URL %s for cluster-reset restore", cfg.ServerURL)
+				cfg.ServerURL = ""
+			}
+			cfg.ClusterReset = true
+			cfg.ClusterResetRestorePath = path
+			cfg.ClusterResetRestoreForce = force
+			// the snapshot has already been retrieved from S3
+			cfg.EtcdS3 = false
+		}
+	}
+
+	// A cluster-reset restore path given as an S3 URI overrides the configured bucket and folder;
+	// the snapshot is downloaded and verified using the configured S3 credentials before restoring.
+	if strings.HasPrefix(cfg.ClusterResetRestorePath, "s3://") {
+		name, err := cmds.SetEtcdS3URI(cfg, cfg.ClusterResetRestorePath)
+		if err != nil {
+			return errors.WithMessage(err, "invalid cluster-reset-restore-path")
+		}
+		cfg.ClusterResetRestorePath = name
+	}
+
 	serverConfig := server.Config{}
 	serverConfig.DisableAgent = cfg.DisableAgent
 	serverConfig.ControlConfig.Runtime = config.NewRuntime()
 	serverConfig.ControlConfig.Token = cfg.Token
 	serverConfig.ControlConfig.AgentToken = cfg.AgentToken
 	serverConfig.ControlConfig.JoinURL = cfg.ServerURL
+	serverConfig.ControlConfig.JoinRetry = config.ServerRetry{
+		Limit:       cmds.AgentConfig.ServerRetryLimit,
+		Interval:    metav1.Duration{Duration: cmds.AgentConfig.ServerRetryInterval},
+		MaxInterval: metav1.Duration{Duration: cmds.AgentConfig.ServerRetryMaxInterval},
+		Jitter:      cmds.AgentConfig.ServerRetryJitter,
+	}
+
 	if cfg.AgentTokenFile != "" {
 		serverConfig.ControlConfig.AgentToken, err = util.ReadFile(ctx, cfg.AgentTokenFile)
 		if err != nil {
@@ -138,11 +257,66 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 			return err
 		}
 	}
+	if cfg.AgentTokenSource != "" {
+		if cfg.AgentTokenFile != "" {
+			return errors.New("invalid flag use; --agent-token-file and --agent-token-source cannot be used together")
+		}
+		serverConfig.ControlConfig.AgentToken, err = secretstore.Read(ctx, cfg.AgentTokenSource, cfg.TokenRefresh)
+		if err != nil {
+			return err
+		}
+		go secretstore.Watch(ctx, cfg.AgentTokenSource, cfg.TokenRefresh, func(string) {
+			logrus.Warnf("Agent token in %s has changed; restart all servers to use the new agent token", cfg.AgentTokenSource)
+		})
+	}
+	if cfg.TokenSource != "" {
+		if cfg.TokenFile != "" {
+			return errors.New("invalid flag use; --token-file and --token-source cannot be used together")
+		}
+		serverConfig.ControlConfig.Token, err = secretstore.Read(ctx, cfg.TokenSource, cfg.TokenRefresh)
+		if err != nil {
+			return err
+		}
+		go secretstore.Watch(ctx, cfg.TokenSource, cfg.TokenRefresh, func(string) {
+			logrus.Warnf("Token in %s has changed; use '%s token rotate' to rotate the cluster to the new token", cfg.TokenSource, version.Program)
+		})
+	}
+	// Servers only use the join URL while bootstrapping, so servers discovered via DNS are resolved once, when
+	// joining the cluster. Servers with an initialized local etcd datastore do not need to discover a server to
+	// join, and use the discovery name as-is.
+	if discovery.IsDiscoveryURL(cfg.ServerURL) {
+		d, err := discovery.New(cfg.ServerURL, serverConfig.ControlConfig.Token)
+		if err != nil {
+			return err
+		}
+		serverDataDir, err := server.ResolveDataDir(cfg.DataDir)
+		if err != nil {
+			return err
+		}
+		initialized, err := etcd.HasLocalData(serverDataDir)
+		if err != nil {
+			return err
+		}
+		if initialized && !cfg.DisableETCD {
+			serverConfig.ControlConfig.JoinURL = d.URL()
+		} else if serverConfig.ControlConfig.JoinURL, err = d.Resolve(ctx, serverConfig.ControlConfig.JoinRetry); err != nil {
+			return err
+		}
+		cfg.ServerURL = serverConfig.ControlConfig.JoinURL
+	}
 	serverConfig.ControlConfig.Datastore = etcd.DefaultEndpointConfig()
 	serverConfig.ControlConfig.Datastore.BackendTLSConfig.CAFile = cfg.DatastoreCAFile
 	serverConfig.ControlConfig.Datastore.BackendTLSConfig.CertFile = cfg.DatastoreCertFile
 	serverConfig.ControlConfig.Datastore.BackendTLSConfig.KeyFile = cfg.DatastoreKeyFile
 	serverConfig.ControlConfig.Datastore.Endpoint = cfg.DatastoreEndpoint
+	serverConfig.ControlConfig.Datastore.ConnectionPoolConfig.MaxIdle = cfg.DatastoreMaxIdleConns
+	serverConfig.ControlConfig.Datastore.ConnectionPoolConfig.MaxOpen = cfg.DatastoreMaxOpenConns
+	serverConfig.ControlConfig.Datastore.ConnectionPoolConfig.MaxLifetime = cfg.DatastoreConnMaxLifetime
+	serverConfig.ControlConfig.Datastore.ConnectionPoolConfig.MaxIdleTime = cfg.DatastoreConnMaxIdleTime
+	serverConfig.ControlConfig.DatastoreRetryTimeout = metav1.Duration{Duration: cfg.DatastoreRetryTimeout}
+	// kine only supports setting slow query logging thresholds globally
+	kinemetrics.SlowSQLThreshold = cfg.DatastoreSlowSQL
+	kinemetrics.SlowSQLWarningThreshold = cfg.DatastoreSlowSQLWarning
 	serverConfig.ControlConfig.Datastore.S3Config.AccessKey = cfg.EtcdS3AccessKey
 	serverConfig.ControlConfig.Datastore.S3Config.Bucket = cfg.EtcdS3BucketName
 	serverConfig.ControlConfig.Datastore.S3Config.CABundle = cfg.EtcdS3EndpointCA
@@ -156,11 +330,15 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	serverConfig.ControlConfig.KubeConfigOutput = cfg.KubeConfigOutput
 	serverConfig.ControlConfig.KubeConfigMode = cfg.KubeConfigMode
 	serverConfig.ControlConfig.KubeConfigGroup = cfg.KubeConfigGroup
+	serverConfig.ControlConfig.KubeConfigComponents = cfg.KubeConfigComponents
+	serverConfig.ControlConfig.PermissionsAuditInterval = metav1.Duration{Duration: cfg.PermissionsAuditInterval}
+	serverConfig.ControlConfig.PermissionsAuditAction = cfg.PermissionsAuditAction
 	serverConfig.ControlConfig.HelmJobImage = cfg.HelmJobImage
 	serverConfig.ControlConfig.Rootless = cfg.Rootless
 	serverConfig.ControlConfig.ServiceLBNamespace = cfg.ServiceLBNamespace
 	serverConfig.ControlConfig.SANs = util.SplitStringSlice(cfg.TLSSan.Value())
 	serverConfig.ControlConfig.SANSecurity = cfg.TLSSanSecurity
+	serverConfig.ControlConfig.APIServerSANFile = cfg.APIServerSANFile
 	serverConfig.ControlConfig.BindAddress = cmds.AgentConfig.BindAddress
 	serverConfig.ControlConfig.SupervisorPort = cfg.SupervisorPort
 	serverConfig.ControlConfig.HTTPSPort = cfg.HTTPSPort
@@ -173,14 +351,64 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	serverConfig.ControlConfig.ExtraCloudControllerArgs = cfg.ExtraCloudControllerArgs.Value()
 	serverConfig.ControlConfig.ExtraHelmArgs = cfg.ExtraHelmArgs.Value()
 	serverConfig.ControlConfig.ClusterDomain = cfg.ClusterDomain
+	switch cfg.AgentDNSSearchPolicy {
+	case config.DNSSearchPolicyAppend, config.DNSSearchPolicyReplace:
+	default:
+		return fmt.Errorf("invalid agent-dns-search-policy %q: must be one of '%s', '%s'", cfg.AgentDNSSearchPolicy, config.DNSSearchPolicyAppend, config.DNSSearchPolicyReplace)
+	}
+	serverConfig.ControlConfig.AgentDNSSearch = cfg.AgentDNSSearch.Value()
+	serverConfig.ControlConfig.AgentDNSSearchPolicy = cfg.AgentDNSSearchPolicy
+	if _, err := node.ParseHostOverrides(cfg.HostOverrides.Value()); err != nil {
+		return err
+	}
+	serverConfig.ControlConfig.HostOverrides = cfg.HostOverrides.Value()
 	serverConfig.ControlConfig.KineTLS = cfg.KineTLS
 	serverConfig.ControlConfig.AdvertiseIP = cfg.AdvertiseIP
 	serverConfig.ControlConfig.AdvertisePort = cfg.AdvertisePort
+	serverConfig.ControlConfig.LBHealthStrictness = cfg.LBHealthStrictness
+	switch cfg.LBHealthStrictness {
+	case config.LBHealthStrictnessAPIServer, config.LBHealthStrictnessDatastore, config.LBHealthStrictnessAll:
+	default:
+		return fmt.Errorf("invalid lb-health-strictness %q: must be one of '%s', '%s', '%s'", cfg.LBHealthStrictness, config.LBHealthStrictnessAPIServer, config.LBHealthStrictnessDatastore, config.LBHealthStrictnessAll)
+	}
 	serverConfig.ControlConfig.FlannelBackend = cfg.FlannelBackend
 	serverConfig.ControlConfig.FlannelIPv6Masq = cfg.FlannelIPv6Masq
 	serverConfig.ControlConfig.FlannelExternalIP = cfg.FlannelExternalIP
+	serverConfig.ControlConfig.MixedOS = cfg.MixedOS
+	if cfg.MixedOS && cfg.FlannelBackend != "vxlan" && cfg.FlannelBackend != "none" {
+		return fmt.Errorf("flannel-backend %q is not supported with mixed-os: must be one of 'vxlan', 'none'", cfg.FlannelBackend)
+	}
 	serverConfig.ControlConfig.EgressSelectorMode = cfg.EgressSelectorMode
+	serverConfig.ControlConfig.EgressSelectorConfigFile = cfg.EgressSelectorConfigFile
+	serverConfig.ControlConfig.KonnectivitySocket = cfg.KonnectivitySocket
+	if err := cmds.ValidateIPAMWebhook(cfg.IPAMWebhookURL); err != nil {
+		return err
+	}
+	if cfg.IPAMWebhookURL != "" && cfg.StickyPodCIDRs {
+		return errors.New("ipam-webhook-url and sticky-pod-cidrs cannot both be set")
+	}
+	serverConfig.ControlConfig.IPAMWebhookURL = cfg.IPAMWebhookURL
+	serverConfig.ControlConfig.ExternalIPAM = cfg.IPAMWebhookURL != ""
+	serverConfig.ControlConfig.StickyPodCIDRs = cfg.StickyPodCIDRs
+	serverConfig.ControlConfig.NodeJoinApproval = cfg.NodeJoinApproval
+	serverConfig.ControlConfig.RequireTPMKeyPinning = cfg.RequireTPMKeyPinning
+	serverConfig.ControlConfig.CloudIdentityRules = cfg.CloudIdentity.Value()
+	serverConfig.ControlConfig.CloudIdentityAudience = cfg.CloudAudience
+	serverConfig.ControlConfig.TokenAuditLog = cfg.TokenAuditLog
+	serverConfig.ControlConfig.TokenAuditEvents = cfg.TokenAuditEvents
+	serverConfig.ControlConfig.ManagementAudit = cfg.ManagementAudit
+	serverConfig.ControlConfig.ManagementAuditEvents = cfg.ManagementEvents
+	if cfg.ManagementEvents && !cfg.ManagementAudit {
+		return errors.New("invalid flag use; --management-audit-events requires --management-audit")
+	}
+	if cfg.RouteExportTarget != "" {
+		if _, err := routeexport.NewExporter(cfg.RouteExportTarget, nil); err != nil {
+			return err
+		}
+	}
+	serverConfig.ControlConfig.RouteExportTarget = cfg.RouteExportTarget
 	serverConfig.ControlConfig.DisableCCM = cfg.DisableCCM
+	serverConfig.ControlConfig.CloudControllerConfig = cfg.CloudControllerConfig
 	serverConfig.ControlConfig.DisableNPC = cfg.DisableNPC
 	serverConfig.ControlConfig.DisableHelmController = cfg.DisableHelmController
 	serverConfig.ControlConfig.DisableKubeProxy = cfg.DisableKubeProxy
@@ -189,12 +417,34 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	serverConfig.ControlConfig.DisableScheduler = cfg.DisableScheduler
 	serverConfig.ControlConfig.DisableControllerManager = cfg.DisableControllerManager
 	serverConfig.ControlConfig.DisableAgent = cfg.DisableAgent
+	serverConfig.ControlConfig.FaultInjection = cfg.FaultInjection
 	serverConfig.ControlConfig.EmbeddedRegistry = cfg.EmbeddedRegistry
 	serverConfig.ControlConfig.ClusterInit = cfg.ClusterInit
 	serverConfig.ControlConfig.EncryptSecrets = cfg.EncryptSecrets
 	serverConfig.ControlConfig.EncryptProvider = cfg.EncryptProvider
 	serverConfig.ControlConfig.EtcdExposeMetrics = cfg.EtcdExposeMetrics
+	serverConfig.ControlConfig.EtcdWitness = cfg.EtcdWitness
+	serverConfig.ControlConfig.ControlPlaneStandby = cfg.ControlPlaneStandby
+	serverConfig.ControlConfig.StandbyPromoteTimeout = metav1.Duration{Duration: cfg.StandbyPromoteTimeout}
+	if cfg.EtcdMaxLearners < 1 {
+		return errors.New("etcd-max-learners must be greater than 0")
+	}
+	serverConfig.ControlConfig.EtcdMaxLearners = cfg.EtcdMaxLearners
+	serverConfig.ControlConfig.EtcdLearnerMaxLag = cfg.EtcdLearnerMaxLag
+	serverConfig.ControlConfig.EtcdLearnerTimeout = metav1.Duration{Duration: cfg.EtcdLearnerTimeout}
+	if v := cfg.EtcdDowngradeVersion; v != "" {
+		major, minor, _ := strings.Cut(v, ".")
+		_, majorErr := strconv.Atoi(major)
+		_, minorErr := strconv.Atoi(minor)
+		if majorErr != nil || minorErr != nil {
+			return fmt.Errorf("invalid etcd-downgrade-version %q: must be in major.minor format", v)
+		}
+	}
+	serverConfig.ControlConfig.EtcdDowngradeVersion = cfg.EtcdDowngradeVersion
 	serverConfig.ControlConfig.EtcdDisableSnapshots = cfg.EtcdDisableSnapshots
+	serverConfig.ControlConfig.EtcdDisableAlarmRecovery = cfg.EtcdDisableAlarmRecovery
+	serverConfig.ControlConfig.EtcdDisableLeaderMove = cfg.EtcdDisableLeaderMove
+	serverConfig.ControlConfig.EtcdDefragCron = cfg.EtcdDefragCron
 	serverConfig.ControlConfig.SupervisorMetrics = cfg.SupervisorMetrics
 	serverConfig.ControlConfig.VLevel = cmds.LogConfig.VLevel
 	serverConfig.ControlConfig.VModule = cmds.LogConfig.VModule
@@ -203,16 +453,59 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		if cfg.EtcdSnapshotReconcile <= 0 {
 			return errors.New("etcd-snapshot-reconcile-interval must be greater than 0s")
 		}
+		if err := cmds.ValidateEtcdSnapshotCompression(cfg.EtcdSnapshotCompression, cfg.EtcdSnapshotZstdLevel); err != nil {
+			return err
+		}
 		serverConfig.ControlConfig.EtcdSnapshotCompress = cfg.EtcdSnapshotCompress
+		serverConfig.ControlConfig.EtcdSnapshotCompression = cfg.EtcdSnapshotCompression
+		serverConfig.ControlConfig.EtcdSnapshotZstdLevel = cfg.EtcdSnapshotZstdLevel
+		if err := cmds.ValidateEtcdSnapshotWebhook(cfg.EtcdSnapshotWebhookURL, cfg.EtcdSnapshotWebhookType); err != nil {
+			return err
+		}
+		serverConfig.ControlConfig.EtcdSnapshotWebhookURL = cfg.EtcdSnapshotWebhookURL
+		serverConfig.ControlConfig.EtcdSnapshotWebhookType = cfg.EtcdSnapshotWebhookType
+		hooks := slices.Concat(cfg.EtcdSnapshotPreHooks.Value(), cfg.EtcdSnapshotPostHooks.Value())
+		if err := cmds.ValidateEtcdSnapshotHooks(hooks, cfg.EtcdSnapshotHookTimeout, cfg.EtcdSnapshotHookPolicy); err != nil {
+			return err
+		}
+		if len(hooks) > 0 {
+			serverConfig.ControlConfig.EtcdSnapshotHooks = &config.SnapshotHooks{
+				Pre:           cfg.EtcdSnapshotPreHooks.Value(),
+				Post:          cfg.EtcdSnapshotPostHooks.Value(),
+				Timeout:       cfg.EtcdSnapshotHookTimeout,
+				FailurePolicy: cfg.EtcdSnapshotHookPolicy,
+				Env:           componentEnv[cmds.EnvComponentEtcdSnapshotHook],
+			}
+		}
 		serverConfig.ControlConfig.EtcdSnapshotName = cfg.EtcdSnapshotName
 		serverConfig.ControlConfig.EtcdSnapshotCron = cfg.EtcdSnapshotCron
 		serverConfig.ControlConfig.EtcdSnapshotDir = cfg.EtcdSnapshotDir
 		serverConfig.ControlConfig.EtcdSnapshotReconcile = metav1.Duration{Duration: cfg.EtcdSnapshotReconcile}
 		serverConfig.ControlConfig.EtcdSnapshotRetention = cfg.EtcdSnapshotRetention
+		if cfg.EtcdSnapshotMaxAge != "" {
+			maxAge, err := snapshot.ParseRetentionAge(cfg.EtcdSnapshotMaxAge)
+			if err != nil {
+				return errors.WithMessage(err, "invalid etcd-snapshot-retention-max-age")
+			}
+			serverConfig.ControlConfig.EtcdSnapshotMaxAge = metav1.Duration{Duration: maxAge}
+		}
+		tiers, err := snapshot.ParseRetentionTiers(cfg.EtcdSnapshotTiers.Value())
+		if err != nil {
+			return errors.WithMessage(err, "invalid etcd-snapshot-retention-tier")
+		}
+		serverConfig.ControlConfig.EtcdSnapshotTiers = tiers
+		schedules, err := snapshot.ParseSchedules(cfg.EtcdSnapshotSchedules.Value(), cfg.EtcdSnapshotName)
+		if err != nil {
+			return errors.WithMessage(err, "invalid etcd-snapshot-schedule")
+		}
+		serverConfig.ControlConfig.EtcdSnapshotSchedules = schedules
 		if cfg.EtcdS3 {
 			if cfg.EtcdS3Timeout <= 0 {
 				return errors.New("etcd-s3-timeout must be greater than 0s")
 			}
+			if err := cmds.ValidateEtcdS3Upload(cfg.EtcdS3PartSize, cfg.EtcdS3Concurrency, cfg.EtcdS3UploadRateLimit); err != nil {
+				return err
+			}
 			// set default s3 retention from local snapshot retention
 			// preserves legacy behavior of local snapshot retention also affecting s3
 			if !app.IsSet("etcd-s3-retention") && app.IsSet("etcd-snapshot-retention") {
@@ -226,6 +519,7 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 				Endpoint:      cfg.EtcdS3Endpoint,
 				EndpointCA:    cfg.EtcdS3EndpointCA,
 				Folder:        cfg.EtcdS3Folder,
+				KMSKeyID:      cfg.EtcdS3KMSKeyID,
 				Insecure:      cfg.EtcdS3Insecure,
 				Proxy:         cfg.EtcdS3Proxy,
 				Region:        cfg.EtcdS3Region,
@@ -233,9 +527,20 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 				SessionToken:  cfg.EtcdS3SessionToken,
 				SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
 				Retention:     cfg.EtcdS3Retention,
+				PartSize:      uint64(cfg.EtcdS3PartSize) * 1024 * 1024,
+				Concurrency:   uint(cfg.EtcdS3Concurrency),
+				UploadRate:    uint64(cfg.EtcdS3UploadRateLimit) * 1024,
 				Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
 			}
 		}
+		serverConfig.ControlConfig.EtcdS3Targets = cfg.EtcdS3TargetSecrets.Value()
+		if cfg.EtcdRemoteTarget != "" {
+			if _, err := remote.NewClient(cfg.EtcdRemoteTarget, cfg.EtcdRemoteRetention); err != nil {
+				return err
+			}
+			serverConfig.ControlConfig.EtcdRemoteTarget = cfg.EtcdRemoteTarget
+			serverConfig.ControlConfig.EtcdRemoteRetention = cfg.EtcdRemoteRetention
+		}
 	} else {
 		logrus.Info("ETCD snapshots are disabled")
 	}
@@ -243,15 +548,48 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	if cfg.ClusterResetRestorePath != "" && !cfg.ClusterReset {
 		return errors.New("invalid flag use; --cluster-reset required with --cluster-reset-restore-path")
 	}
+	if cfg.ResetBackupRetention < 1 {
+		return errors.New("invalid flag use; --cluster-reset-backup-retention must be greater than 0")
+	}
 
 	serverConfig.ControlConfig.ClusterReset = cfg.ClusterReset
 	serverConfig.ControlConfig.ClusterResetRestorePath = cfg.ClusterResetRestorePath
+	serverConfig.ControlConfig.ClusterResetRestoreForce = cfg.ClusterResetRestoreForce
+	serverConfig.ControlConfig.ResetBackupRetention = cfg.ResetBackupRetention
 	serverConfig.ControlConfig.SystemDefaultRegistry = cfg.SystemDefaultRegistry
 
 	if serverConfig.ControlConfig.SupervisorPort == 0 {
 		serverConfig.ControlConfig.SupervisorPort = serverConfig.ControlConfig.HTTPSPort
 	}
 
+	// Witnesses only run etcd; the control-plane runs on the full servers.
+	if err := etcd.ConfigureWitness(&serverConfig.ControlConfig); err != nil {
+		return err
+	}
+
+	// Standby servers do not run the control-plane until promoted.
+	serverDataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if err := standby.Configure(&serverConfig.ControlConfig, serverDataDir); err != nil {
+		return err
+	}
+
+	// Commands run on this node record management actions only if the server does.
+	if err := auditlog.Setup(serverDataDir, cfg.ManagementAudit); err != nil {
+		return errors.WithMessage(err, "failed to set up management audit log")
+	}
+
+	// Server-scoped bootstrap tokens can only be used to retrieve bootstrap data from an existing server.
+	if clientaccess.IsBootstrapToken(serverConfig.ControlConfig.Token) && (serverConfig.ControlConfig.JoinURL == "" || serverConfig.ControlConfig.Datastore.Endpoint != "") {
+		return errors.New("invalid flag use; bootstrap tokens can only be used with --server to join servers using embedded etcd")
+	}
+
+	if cfg.EtcdMetricsProxy && (serverConfig.ControlConfig.DisableETCD || serverConfig.ControlConfig.Datastore.Endpoint != "") {
+		return errors.New("invalid flag use; --etcd-metrics-proxy requires embedded etcd")
+	}
+
 	if serverConfig.ControlConfig.DisableETCD && serverConfig.ControlConfig.JoinURL == "" {
 		return errors.New("invalid flag use; --server is required with --disable-etcd")
 	}
@@ -400,6 +738,12 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		serverConfig.ControlConfig.Disables["ccm"] = true
 	}
 
+	serverConfig.ControlConfig.ComponentNodeSelectors = util.SplitStringSlice(cfg.ComponentNodeSelectors.Value())
+	serverConfig.ControlConfig.ComponentTolerations = util.SplitStringSlice(cfg.ComponentTolerations.Value())
+	if _, err := deploy.ParsePlacements(serverConfig.ControlConfig.ComponentNodeSelectors, serverConfig.ControlConfig.ComponentTolerations); err != nil {
+		return errors.WithMessage(err, "invalid flag use")
+	}
+
 	tlsMinVersionArg := util.ArgValue("tls-min-version", serverConfig.ControlConfig.ExtraAPIArgs)
 	serverConfig.ControlConfig.MinTLSVersion = tlsMinVersionArg
 	serverConfig.ControlConfig.TLSMinVersion, err = kubeapiserverflag.TLSVersion(tlsMinVersionArg)
@@ -441,6 +785,101 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		helmchart.DefaultJobImage = serverConfig.ControlConfig.HelmJobImage
 	}
 
+	if cfg.ListPageSize < 1 {
+		return errors.New("list-page-size must be greater than 0")
+	}
+	serverConfig.ControlConfig.ListPageSize = cfg.ListPageSize
+
+	serverConfig.ControlConfig.APIServerWatchCacheSizes, err = parseWatchCacheSizes(cfg.APIServerWatchCacheSizes.Value())
+	if err != nil {
+		return errors.WithMessage(err, "invalid apiserver-watch-cache-sizes")
+	}
+	serverConfig.ControlConfig.WatchCacheReportInterval = metav1.Duration{Duration: cfg.WatchCacheReportInterval}
+
+	switch cfg.PermissionsAuditAction {
+	case permmonitor.ActionWarn, permmonitor.ActionFix:
+	default:
+		return fmt.Errorf("invalid permissions-audit-action %q: must be one of '%s', '%s'", cfg.PermissionsAuditAction, permmonitor.ActionWarn, permmonitor.ActionFix)
+	}
+
+	serverConfig.ControlConfig.Guardrails, err = guardrails.GetProfile(cfg.GuardrailProfile)
+	if err != nil {
+		return errors.WithMessage(err, "invalid guardrail-profile")
+	}
+	if g := serverConfig.ControlConfig.Guardrails; g != nil {
+		serverConfig.LeaderControllers = append(serverConfig.LeaderControllers, func(ctx context.Context, sc *server.Context) error {
+			return guardrails.Register(ctx, sc.K8s, sc.Core.Core().V1().Namespace(), g)
+		})
+	}
+
+	if err := telemetry.ValidateEndpoint(cfg.TelemetryEndpoint); err != nil {
+		return errors.WithMessage(err, "invalid telemetry-endpoint")
+	}
+	if cfg.TelemetryEndpoint != "" {
+		embeddedEtcd := cfg.ClusterInit || cfg.ServerURL != ""
+		serverConfig.ControlConfig.Telemetry = &config.Telemetry{
+			Endpoint:  cfg.TelemetryEndpoint,
+			Interval:  metav1.Duration{Duration: cfg.TelemetryInterval},
+			Datastore: telemetry.DatastoreType(cfg.DatastoreEndpoint, embeddedEtcd),
+		}
+		serverConfig.LeaderControllers = append(serverConfig.LeaderControllers, func(ctx context.Context, sc *server.Context) error {
+			return telemetry.Register(ctx, sc.K8s, sc.Core.Core().V1().Node().Cache(), &serverConfig.ControlConfig)
+		})
+	}
+
+	if cfg.GitBackupRemote != "" {
+		serverConfig.ControlConfig.GitBackup = &config.GitBackup{
+			Remote:        cfg.GitBackupRemote,
+			Branch:        cfg.GitBackupBranch,
+			Path:          cfg.GitBackupPath,
+			AgeRecipients: cfg.GitBackupRecipients.Value(),
+			SSHKey:        cfg.GitBackupSSHKey,
+			Interval:      metav1.Duration{Duration: cfg.GitBackupInterval},
+		}
+		if err := gitbackup.Validate(serverConfig.ControlConfig.GitBackup); err != nil {
+			return errors.WithMessage(err, "invalid git-backup configuration")
+		}
+		serverConfig.LeaderControllers = append(serverConfig.LeaderControllers, func(ctx context.Context, sc *server.Context) error {
+			return gitbackup.Register(ctx, &serverConfig.ControlConfig, sc.K3s.K3s().V1().ETCDSnapshotFile())
+		})
+	}
+
+	serverConfig.ControlConfig.DNSAutoscaler, err = dnsautoscaler.ParseParams(cfg.CoreDNSAutoscaler)
+	if err != nil {
+		return errors.WithMessage(err, "invalid coredns-autoscaler")
+	}
+
+	serverConfig.ControlConfig.PreferLocalImages = cfg.PreferLocalImages
+	if cfg.ImageDigestAllowlist != "" {
+		if !cfg.PreferLocalImages {
+			return errors.New("image-digest-allowlist requires prefer-local-images")
+		}
+		serverConfig.ControlConfig.ImageAllowlist, err = imagepolicy.ParseAllowlist(cfg.ImageDigestAllowlist)
+		if err != nil {
+			return errors.WithMessage(err, "invalid image-digest-allowlist")
+		}
+	}
+
+	if cfg.AuthFailures < 0 {
+		return errors.New("invalid auth-failure-limit: must be 0 or greater")
+	}
+	if cfg.AuthFailures > 0 {
+		if cfg.AuthWindow <= 0 || cfg.AuthLockout <= 0 {
+			return errors.New("invalid auth-failure-window or auth-lockout-duration: must be greater than 0s")
+		}
+		serverConfig.ControlConfig.Runtime.AuthLockout = authlockout.New(cfg.AuthFailures, cfg.AuthWindow, cfg.AuthLockout)
+		serverConfig.ControlConfig.Runtime.AuthLockout.Start(ctx)
+	}
+
+	if cfg.CPUPressureThreshold < 0 || cfg.CPUPressureThreshold > 100 {
+		return errors.New("invalid cpu-pressure-threshold: must be between 0 and 100")
+	}
+	if cfg.CPUPressureThreshold > 0 {
+		if err := loadshed.Start(ctx, cfg.CPUPressureThreshold, cfg.CPUPressureMaxDelay); err != nil {
+			return errors.WithMessage(err, "invalid cpu-pressure-threshold")
+		}
+	}
+
 	// If performing a cluster reset, make sure control-plane components are
 	// disabled so we only perform a reset or restore and bail out.
 	if cfg.ClusterReset {
@@ -451,6 +890,7 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		serverConfig.ControlConfig.DisableCCM = true
 		serverConfig.ControlConfig.DisableServiceLB = true
 		serverConfig.ControlConfig.EtcdDisableSnapshots = true
+		serverConfig.ControlConfig.EtcdDefragCron = ""
 
 		// If the supervisor and apiserver are on the same port, everything is running embedded
 		// and we don't need the kubelet or containerd up to perform a cluster reset.
@@ -476,7 +916,7 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 			// at this point we're doing a restore. Check to see if we've
 			// passed in a token and if not, check if the token file exists.
 			// If it doesn't, return an error indicating the token is necessary.
-			if cfg.Token == "" {
+			if serverConfig.ControlConfig.Token == "" {
 				tokenFile := filepath.Join(dataDir, "server", "token")
 				if _, err := os.Stat(tokenFile); err != nil {
 					if os.IsNotExist(err) {
@@ -588,6 +1028,9 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	metrics.Router = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
 		return https.Start(ctx, nodeConfig, serverConfig.ControlConfig.Runtime)
 	}
+	if cfg.EtcdMetricsProxy {
+		metrics.EtcdMetricsURL = fmt.Sprintf("http://%s:2381/metrics", serverConfig.ControlConfig.Loopback(true))
+	}
 
 	// and for pprof as well
 	pprof := profile.DefaultProfiler
@@ -600,6 +1043,13 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		if err := agent.RunStandalone(ctx, wg, agentConfig); err != nil {
 			return err
 		}
+		// Startup hooks are otherwise run by the agent. Without an agent, only the post-apiserver-ready hooks apply.
+		go func() {
+			<-executor.APIServerReadyChan()
+			if err := startupHooks.Run(ctx, hooks.PostAPIServerReady, agentConfig.NodeName); err != nil {
+				signals.RequestShutdown(err)
+			}
+		}()
 	} else {
 		if err := agent.Run(ctx, wg, agentConfig); err != nil {
 			return err
@@ -617,6 +1067,7 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 
 	serverConfig.ControlConfig.Runtime.StartupHooksWg = &sync.WaitGroup{}
 	serverConfig.ControlConfig.Runtime.StartupHooksWg.Add(len(serverConfig.StartupHooks))
+	serverConfig.ControlConfig.Runtime.BootstrapAddonsReady = make(chan struct{})
 
 	go func() {
 		if !serverConfig.ControlConfig.DisableETCD {
@@ -627,12 +1078,15 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 			<-executor.APIServerReadyChan()
 			logrus.Info("Kube API server is now running")
 			serverConfig.ControlConfig.Runtime.StartupHooksWg.Wait()
+			<-serverConfig.ControlConfig.Runtime.BootstrapAddonsReady
 		}
 		logrus.Info(version.Program + " is up and running")
 		os.Setenv("NOTIFY_SOCKET", notifySocket)
 		systemd.SdNotify(true, "READY=1\n")
 	}()
 
+	go standby.Monitor(ctx, &serverConfig.ControlConfig)
+
 	return server.StartServer(ctx, wg, &serverConfig, cfg)
 }
 
@@ -640,6 +1094,10 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 func validateNetworkConfiguration(serverConfig server.Config) error {
 	switch serverConfig.ControlConfig.EgressSelectorMode {
 	case config.EgressSelectorModeCluster, config.EgressSelectorModePod:
+	case config.EgressSelectorModeKonnectivity:
+		if serverConfig.ControlConfig.KonnectivitySocket != "" && !filepath.IsAbs(serverConfig.ControlConfig.KonnectivitySocket) {
+			return fmt.Errorf("konnectivity-uds-path %q must be an absolute path when egress-selector-mode is %s", serverConfig.ControlConfig.KonnectivitySocket, config.EgressSelectorModeKonnectivity)
+		}
 	case config.EgressSelectorModeAgent, config.EgressSelectorModeDisabled:
 		if serverConfig.DisableAgent {
 			logrus.Warn("Webhooks and apiserver aggregation may not function properly without an agent; please set egress-selector-mode to 'cluster' or 'pod'")
@@ -668,3 +1126,28 @@ func pollAPIAddressFromEtcd(ctx context.Context, serverConfig server.Config, age
 		return false, nil
 	})
 }
+
+// parseWatchCacheSizes converts watch cache size overrides in resource[.group]=size format
+// to the resource[.group]#size format expected by kube-apiserver.
+func parseWatchCacheSizes(sizes []string) ([]string, error) {
+	var result []string
+	for _, size := range sizes {
+		for _, entry := range strings.Split(size, ",") {
+			if entry == "" {
+				continue
+			}
+			resource, value, ok := strings.Cut(entry, "=")
+			if !ok {
+				resource, value, ok = strings.Cut(entry, "#")
+			}
+			if !ok || resource == "" {
+				return nil, fmt.Errorf("%q is not in resource[.group]=size format", entry)
+			}
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				return nil, fmt.Errorf("%q does not specify a valid size", entry)
+			}
+			result = append(result, strings.ToLower(resource)+"#"+value)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/proctitle"
+	"github.com/k3s-io/k3s/pkg/server"
+	"github.com/k3s-io/k3s/pkg/server/handlers"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/urfave/cli/v2"
+)
+
+var errUnhealthy = errors.New("cluster is not healthy")
+
+func commandPrep(cfg *cmds.Server) (*clientaccess.Info, error) {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " status")
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" {
+		fp := filepath.Join(dataDir, "token")
+		tokenByte, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Token = string(bytes.TrimRight(tokenByte, "\n"))
+	}
+	return clientaccess.ParseAndValidateToken(cmds.ServerConfig.ServerURL, cfg.Token, clientaccess.WithUser("server"))
+}
+
+// Run prints the health of the cluster, as reported by the server. An error is returned
+// if the cluster is not healthy, so that the command exits non-zero.
+func Run(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return status(&cmds.ServerConfig, &cmds.StatusConfig)
+}
+
+func status(cfg *cmds.Server, statusCfg *cmds.Status) error {
+	info, err := commandPrep(cfg)
+	if err != nil {
+		return err
+	}
+
+	path := "/v1-" + version.Program + "/health"
+	if statusCfg.Cluster {
+		path += "?cluster=true"
+	}
+	b, err := info.Get(path)
+	if err != nil {
+		return errors.WithMessage(err, "see server log for details")
+	}
+	health := handlers.ClusterHealth{}
+	if err := json.Unmarshal(b, &health); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+
+	if !health.Healthy {
+		return errUnhealthy
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/kubeadm"
@@ -25,6 +26,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/duration"
+	clientset "k8s.io/client-go/kubernetes"
 	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
 	bootstraputil "k8s.io/cluster-bootstrap/token/util"
 	"k8s.io/utils/ptr"
@@ -48,18 +50,11 @@ func create(app *cli.Context, cfg *cmds.Token) error {
 		return err
 	}
 
-	restConfig, err := util.GetRESTConfig(cfg.Kubeconfig)
+	caData, err := getCAData(cfg.Kubeconfig)
 	if err != nil {
 		return err
 	}
 
-	if len(restConfig.TLSClientConfig.CAData) == 0 && restConfig.TLSClientConfig.CAFile != "" {
-		restConfig.TLSClientConfig.CAData, err = os.ReadFile(restConfig.TLSClientConfig.CAFile)
-		if err != nil {
-			return err
-		}
-	}
-
 	bts, err := kubeadm.NewBootstrapTokenString(cfg.Token)
 	if err != nil {
 		return err
@@ -71,6 +66,7 @@ func create(app *cli.Context, cfg *cmds.Token) error {
 		TTL:         &metav1.Duration{Duration: cfg.TTL},
 		Usages:      cfg.Usages.Value(),
 		Groups:      cfg.Groups.Value(),
+		MaxUses:     cfg.MaxUses,
 	}
 
 	secretName := bootstraputil.BootstrapTokenSecretName(bt.Token.ID)
@@ -78,20 +74,75 @@ func create(app *cli.Context, cfg *cmds.Token) error {
 		return fmt.Errorf("a token with id %q already exists", bt.Token.ID)
 	}
 
-	secret := kubeadm.BootstrapTokenToSecret(&bt)
-	if _, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+	secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Create(context.TODO(), kubeadm.BootstrapTokenToSecret(&bt), metav1.CreateOptions{})
+	recordAction(context.TODO(), client, "token create", bt.Token.ID, err)
+	if err != nil {
 		return err
 	}
 
-	token, err := clientaccess.FormatTokenBytes(bt.Token.String(), restConfig.TLSClientConfig.CAData)
+	created, err := kubeadm.BootstrapTokenFromSecret(secret)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(token)
+	output, err := newTokenOutput(created, caData)
+	if err != nil {
+		return err
+	}
+
+	switch cfg.Output {
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(output)
+	case "yaml":
+		return yaml.NewEncoder(os.Stdout).Encode(output)
+	default:
+		fmt.Println(output.Token)
+	}
 	return nil
 }
 
+// tokenOutput is the structured output of the token create and list commands. Token is the full token,
+// including the cluster CA hash, that is used to join nodes to the cluster.
+type tokenOutput struct {
+	ID          string       `json:"id" yaml:"id"`
+	Token       string       `json:"token" yaml:"token"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Expires     *metav1.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Usages      []string     `json:"usages,omitempty" yaml:"usages,omitempty"`
+	Groups      []string     `json:"groups,omitempty" yaml:"groups,omitempty"`
+	MaxUses     int          `json:"maxUses,omitempty" yaml:"maxUses,omitempty"`
+	UsedBy      []string     `json:"usedBy,omitempty" yaml:"usedBy,omitempty"`
+}
+
+func newTokenOutput(bt *kubeadm.BootstrapToken, caData []byte) (*tokenOutput, error) {
+	token, err := clientaccess.FormatTokenBytes(bt.Token.String(), caData)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenOutput{
+		ID:          bt.Token.ID,
+		Token:       token,
+		Description: bt.Description,
+		Expires:     bt.Expires,
+		Usages:      bt.Usages,
+		Groups:      bt.Groups,
+		MaxUses:     bt.MaxUses,
+		UsedBy:      bt.UsedBy,
+	}, nil
+}
+
+// getCAData returns the cluster CA certificates from the kubeconfig, which are hashed into the full token.
+func getCAData(kubeconfig string) ([]byte, error) {
+	restConfig, err := util.GetRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(restConfig.TLSClientConfig.CAData) == 0 && restConfig.TLSClientConfig.CAFile != "" {
+		return os.ReadFile(restConfig.TLSClientConfig.CAFile)
+	}
+	return restConfig.TLSClientConfig.CAData, nil
+}
+
 func Delete(app *cli.Context) error {
 	if err := cmds.InitLogging(); err != nil {
 		return err
@@ -120,7 +171,9 @@ func deleteToken(app *cli.Context, cfg *cmds.Token) error {
 			token = bts.ID
 		}
 		secretName := bootstraputil.BootstrapTokenSecretName(token)
-		if err := client.CoreV1().Secrets(metav1.NamespaceSystem).Delete(app.Context, secretName, metav1.DeleteOptions{}); err != nil {
+		err := client.CoreV1().Secrets(metav1.NamespaceSystem).Delete(app.Context, secretName, metav1.DeleteOptions{})
+		recordAction(app.Context, client, "token delete", token, err)
+		if err != nil {
 			return errors.WithMessagef(err, "failed to delete bootstrap token %q", token)
 		}
 
@@ -129,6 +182,16 @@ func deleteToken(app *cli.Context, cfg *cmds.Token) error {
 	return nil
 }
 
+// recordAction records a token management action to the audit log, if enabled on this server.
+// The action is recorded as taken by the identity that the client is authenticated as.
+func recordAction(ctx context.Context, client clientset.Interface, action, target string, err error) {
+	dataDir, derr := server.ResolveDataDir("")
+	if derr != nil || !auditlog.Enabled(dataDir) {
+		return
+	}
+	auditlog.RecordLocal(dataDir, auditlog.ClientUser(ctx, client), action, target, err)
+}
+
 func Generate(app *cli.Context) error {
 	if err := cmds.InitLogging(); err != nil {
 		return err
@@ -155,7 +218,8 @@ func Rotate(app *cli.Context) error {
 		return err
 	}
 	b, err := json.Marshal(handlers.TokenRotateRequest{
-		NewToken: ptr.To(cmds.TokenConfig.NewToken),
+		NewToken:     ptr.To(cmds.TokenConfig.NewToken),
+		UpdateAgents: ptr.To(cmds.TokenConfig.UpdateAgent),
 	})
 	if err != nil {
 		return err
@@ -165,7 +229,11 @@ func Rotate(app *cli.Context) error {
 	}
 	// wait for etcd db propagation delay
 	time.Sleep(1 * time.Second)
-	fmt.Println("Token rotated, restart", version.Program, "nodes with new token")
+	if cmds.TokenConfig.UpdateAgent {
+		fmt.Println("Token rotated and pushed to connected agents, restart", version.Program, "servers with new token")
+	} else {
+		fmt.Println("Token rotated, restart", version.Program, "nodes with new token")
+	}
 	return nil
 }
 
@@ -221,33 +289,40 @@ func list(app *cli.Context, cfg *cmds.Token) error {
 		return errors.WithMessagef(err, "failed to list bootstrap tokens")
 	}
 
-	tokens := make([]*kubeadm.BootstrapToken, len(secrets.Items))
-	for i, secret := range secrets.Items {
+	tokens := make([]*kubeadm.BootstrapToken, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
 		token, err := kubeadm.BootstrapTokenFromSecret(&secret)
 		if err != nil {
-			fmt.Printf("%v", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			continue
 		}
-		tokens[i] = token
+		tokens = append(tokens, token)
 	}
 
 	switch cfg.Output {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(tokens); err != nil {
+	case "json", "yaml":
+		caData, err := getCAData(cfg.Kubeconfig)
+		if err != nil {
 			return err
 		}
-		return nil
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(tokens); err != nil {
-			return err
+		outputs := make([]*tokenOutput, 0, len(tokens))
+		for _, token := range tokens {
+			output, err := newTokenOutput(token, caData)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, output)
+		}
+		if cfg.Output == "json" {
+			return json.NewEncoder(os.Stdout).Encode(outputs)
 		}
-		return nil
+		return yaml.NewEncoder(os.Stdout).Encode(outputs)
 	default:
-		format := "%s\t%s\t%s\t%s\t%s\t%s\n"
+		format := "%s\t%s\t%s\t%s\t%s\t%s\t%s\n"
 		w := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
 		defer w.Flush()
 
-		fmt.Fprintf(w, format, "TOKEN", "TTL", "EXPIRES", "USAGES", "DESCRIPTION", "EXTRA GROUPS")
+		fmt.Fprintf(w, format, "TOKEN", "TTL", "EXPIRES", "USES", "USAGES", "DESCRIPTION", "EXTRA GROUPS")
 		for _, token := range tokens {
 			ttl := "<forever>"
 			expires := "<never>"
@@ -256,7 +331,12 @@ func list(app *cli.Context, cfg *cmds.Token) error {
 				expires = token.Expires.Format(time.RFC3339)
 			}
 
-			fmt.Fprintf(w, format, token.Token.ID, ttl, expires, joinOrNone(token.Usages...), joinOrNone(token.Description), joinOrNone(token.Groups...))
+			uses := "<unlimited>"
+			if token.MaxUses > 0 {
+				uses = fmt.Sprintf("%d/%d", len(token.UsedBy), token.MaxUses)
+			}
+
+			fmt.Fprintf(w, format, token.Token.ID, ttl, expires, uses, joinOrNone(token.Usages...), joinOrNone(token.Description), joinOrNone(token.Groups...))
 		}
 	}
 
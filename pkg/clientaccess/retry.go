@@ -0,0 +1,55 @@
+package clientaccess
+
+import (
+	"context"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultRetryInterval is the initial interval between attempts to contact the server, if not set.
+	DefaultRetryInterval = 5 * time.Second
+	// DefaultRetryMaxInterval is the maximum interval between attempts to contact the server, if not set.
+	DefaultRetryMaxInterval = time.Minute
+)
+
+// RetryWithBackoff calls fn until it succeeds, the context is cancelled, or the retry limit is reached.
+// The interval between attempts is doubled after each failure up to the maximum interval, and is jittered
+// so that many nodes started at the same time do not contact the server in lockstep. Each failure is
+// logged with the provided message. The error from the last attempt is returned if all attempts fail.
+func RetryWithBackoff(ctx context.Context, retry config.ServerRetry, msg string, fn func(context.Context) error) error {
+	interval := retry.Interval.Duration
+	if interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	maxInterval := retry.MaxInterval.Duration
+	if maxInterval <= 0 {
+		maxInterval = DefaultRetryMaxInterval
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if retry.Limit > 0 && attempt >= retry.Limit {
+			return errors.WithMessagef(err, "giving up after %d attempts", attempt)
+		}
+
+		delay := interval
+		if retry.Jitter > 0 {
+			delay = wait.Jitter(interval, retry.Jitter)
+		}
+		logrus.Infof("%s; retrying in %s: %v", msg, delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		interval = min(interval*2, maxInterval)
+	}
+}
@@ -0,0 +1,80 @@
+package clientaccess
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitRetryWithBackoff(t *testing.T) {
+	retry := config.ServerRetry{
+		Interval:    metav1.Duration{Duration: time.Millisecond},
+		MaxInterval: metav1.Duration{Duration: 4 * time.Millisecond},
+		Jitter:      0.5,
+	}
+	tests := []struct {
+		name      string
+		limit     int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "succeeds on first attempt",
+			wantCalls: 1,
+		},
+		{
+			name:      "unlimited retries",
+			failures:  10,
+			wantCalls: 11,
+		},
+		{
+			name:      "succeeds within limit",
+			limit:     3,
+			failures:  2,
+			wantCalls: 3,
+		},
+		{
+			name:      "limit reached",
+			limit:     3,
+			failures:  10,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry.Limit = tt.limit
+			calls := 0
+			err := RetryWithBackoff(context.Background(), retry, "Waiting for server", func(context.Context) error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("connection refused")
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RetryWithBackoff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("RetryWithBackoff() made %d attempts, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_UnitRetryWithBackoffCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	retry := config.ServerRetry{Interval: metav1.Duration{Duration: time.Hour}}
+	err := RetryWithBackoff(ctx, retry, "Waiting for server", func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RetryWithBackoff() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -208,6 +208,39 @@ func ParseUsernamePassword(token string) (string, string, bool) {
 	return info.Username, info.Password, true
 }
 
+// IsBootstrapToken returns true if the token string is a bootstrap token, either bare or in K10 format.
+func IsBootstrapToken(token string) bool {
+	info, err := parseToken(token)
+	return err == nil && info.BootstrapTokenString != nil
+}
+
+// HasCAHash returns true if the token string is in K10 format and includes a CA hash.
+func HasCAHash(token string) bool {
+	return CAHash(token) != ""
+}
+
+// CAHash returns the CA hash from a K10 format token string, or an empty string if the token does not include one.
+// The token may consist of only a K10-prefixed CA hash.
+func CAHash(token string) string {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return ""
+	}
+	digest, _, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "::")
+	if !ok || len(digest) != caHashLength {
+		return ""
+	}
+	return digest
+}
+
+// HashCAFile returns the hash of the CA bundle in a file, in the same format as the CA hash in a token.
+func HashCAFile(certFile string) (string, error) {
+	b, err := os.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	return hashCA(b)
+}
+
 // parseToken parses a token into an Info struct
 func parseToken(token string) (*Info, error) {
 	var info Info
@@ -581,3 +614,16 @@ func FormatTokenBytes(creds string, b []byte) (string, error) {
 
 	return tokenPrefix + digest + "::" + creds, nil
 }
+
+// ReplacePassword returns a token with the credentials replaced by the provided password, retaining the CA hash
+// of the existing token, if any. The existing token may consist of only a K10-prefixed CA hash.
+func ReplacePassword(token, password string) string {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return password
+	}
+	digest, _, _ := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "::")
+	if digest == "" {
+		return password
+	}
+	return tokenPrefix + digest + "::node:" + password
+}
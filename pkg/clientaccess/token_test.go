@@ -114,6 +114,33 @@ func Test_UnitValidTokens(t *testing.T) {
 	}
 }
 
+// Test_UnitReplacePassword tests that the password in a token is replaced, and the CA hash is retained
+func Test_UnitReplacePassword(t *testing.T) {
+	assert := assert.New(t)
+	server := newTLSServer(t, defaultUsername, defaultPassword, false)
+	defer server.Close()
+	digest, _ := hashCA(getServerCA(server))
+
+	testCases := []struct {
+		token       string
+		expectToken string
+	}{
+		{"", defaultPassword},
+		{"oldpassword", defaultPassword},
+		{"K10" + digest, "K10" + digest + "::node:" + defaultPassword},
+		{"K10" + digest + "::" + defaultUsername + ":oldpassword", "K10" + digest + "::node:" + defaultPassword},
+		{"K10" + digest + "::" + defaultToken, "K10" + digest + "::node:" + defaultPassword},
+	}
+
+	for _, testCase := range testCases {
+		token := ReplacePassword(testCase.token, defaultPassword)
+		assert.Equal(testCase.expectToken, token, testCase)
+		info, err := ParseAndValidateToken(server.URL, token)
+		assert.NoError(err)
+		assert.Equal(defaultPassword, info.Password, testCase)
+	}
+}
+
 // Test_UnitInvalidTokens tests that tokens which are empty, invalid, or incorrect are properly rejected
 func Test_UnitInvalidTokens(t *testing.T) {
 	assert := assert.New(t)
@@ -253,6 +280,45 @@ func Test_UnitUserPass(t *testing.T) {
 	}
 }
 
+// Test_UnitIsBootstrapToken tests that bootstrap tokens are identified in bare and K10 format
+func Test_UnitIsBootstrapToken(t *testing.T) {
+	assert := assert.New(t)
+	testCases := []struct {
+		token  string
+		expect bool
+	}{
+		{defaultToken, true},
+		{"K10XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX::" + defaultToken, true},
+		{"K10XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX::username:password", false},
+		{"password", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(testCase.expect, IsBootstrapToken(testCase.token), testCase)
+	}
+}
+
+// Test_UnitHasCAHash tests that the CA hash is identified in K10 format tokens
+func Test_UnitHasCAHash(t *testing.T) {
+	assert := assert.New(t)
+	testCases := []struct {
+		token  string
+		expect bool
+	}{
+		{defaultToken, false},
+		{"K10XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX::" + defaultToken, true},
+		{"K10XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX::username:password", true},
+		{"K10::username:password", false},
+		{"password", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(testCase.expect, HasCAHash(testCase.token), testCase)
+	}
+}
+
 // Test_UnitParseAndGet tests URL handling along some hard-to-reach code paths
 func Test_UnitParseAndGet(t *testing.T) {
 	assert := assert.New(t)
@@ -3,7 +3,9 @@ package cloudprovider
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/logger"
@@ -18,6 +20,7 @@ import (
 	"github.com/rancher/wrangler/pkg/generic"
 	"github.com/rancher/wrangler/pkg/start"
 	"github.com/sirupsen/logrus"
+	core "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -26,15 +29,54 @@ import (
 	cloudprovider "k8s.io/cloud-provider"
 )
 
+// ConfigAPIVersion is the current version of the cloud provider config file schema.
+// Config files that do not specify a version are assumed to be the current version.
+const ConfigAPIVersion = "cloudprovider.k3s.cattle.io/v1"
+
 // Config describes externally-configurable cloud provider configuration.
-// This is normally unmarshalled from a JSON config file.
+// This is normally unmarshalled from a JSON config file, which is generated from the server's
+// configuration and may be overridden by the administrator with the --cloud-controller-config flag.
+// Fields may be added to this schema, but existing fields will not be removed or change meaning
+// without a change to the APIVersion.
 type Config struct {
+	// APIVersion is the version of the config file schema.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// LBDefaultPriorityClassName is the priority class of ServiceLB pods, for services that do not set one by annotation.
 	LBDefaultPriorityClassName string `json:"lbDefaultPriorityClassName"`
-	LBEnabled                  bool   `json:"lbEnabled"`
-	LBImage                    string `json:"lbImage"`
-	LBNamespace                string `json:"lbNamespace"`
-	NodeEnabled                bool   `json:"nodeEnabled"`
-	Rootless                   bool   `json:"rootless"`
+	// LBEnabled enables the ServiceLB load-balancer controller.
+	LBEnabled bool `json:"lbEnabled"`
+	// LBHealthCheck enables readiness probes against the health check node port of services with local traffic policy.
+	LBHealthCheck bool `json:"lbHealthCheck"`
+	// LBImage is the image used for ServiceLB pods.
+	LBImage string `json:"lbImage"`
+	// LBImagePullSecrets are the names of secrets in the LBNamespace used to pull the LBImage.
+	LBImagePullSecrets []string `json:"lbImagePullSecrets,omitempty"`
+	// LBNamespace is the namespace that ServiceLB pods are created in.
+	LBNamespace string `json:"lbNamespace"`
+	// LBNodeSelector restricts ServiceLB pods to nodes with matching labels, in addition to the node labels managed by ServiceLB.
+	LBNodeSelector map[string]string `json:"lbNodeSelector,omitempty"`
+	// LBResources are the compute resource requests and limits of each ServiceLB container.
+	LBResources core.ResourceRequirements `json:"lbResources,omitempty"`
+	// LBSysctls are additional namespaced sysctls set on ServiceLB pods. IP forwarding sysctls are always set.
+	LBSysctls map[string]string `json:"lbSysctls,omitempty"`
+	// NodeEnabled enables the node controller, which sets node addresses and provider IDs.
+	NodeEnabled bool `json:"nodeEnabled"`
+	// Rootless indicates that the server is running rootless, which is not compatible with some ServiceLB features.
+	Rootless bool `json:"rootless"`
+}
+
+// Validate returns an error if the config file schema version is not supported,
+// or if any of the config values are invalid.
+func (c *Config) Validate() error {
+	if c.APIVersion != "" && c.APIVersion != ConfigAPIVersion {
+		return fmt.Errorf("unsupported cloud provider config apiVersion %q, expected %q", c.APIVersion, ConfigAPIVersion)
+	}
+	for name := range c.LBSysctls {
+		if !strings.HasPrefix(name, "net.") {
+			return fmt.Errorf("invalid cloud provider config: sysctl %q is not a namespaced net sysctl", name)
+		}
+	}
+	return nil
 }
 
 type k3s struct {
@@ -60,6 +102,7 @@ func init() {
 			Config: Config{
 				LBDefaultPriorityClassName: DefaultLBPriorityClassName,
 				LBEnabled:                  true,
+				LBHealthCheck:              true,
 				LBImage:                    DefaultLBImage,
 				LBNamespace:                DefaultLBNS,
 				NodeEnabled:                true,
@@ -72,6 +115,9 @@ func init() {
 			if err == nil {
 				err = json.Unmarshal(bytes, &k.Config)
 			}
+			if err == nil {
+				err = k.Config.Validate()
+			}
 		}
 
 		if !k.LBEnabled && !k.NodeEnabled {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -320,8 +321,9 @@ func (k *k3s) patchStatus(svc *core.Service, previousStatus, newStatus *core.Loa
 }
 
 // podIPs returns a list of IPs for Nodes hosting ServiceLB Pods.
-// If at least one node has External IPs available, only external IPs are returned.
-// If no nodes have External IPs set, the Internal IPs of all nodes running pods are returned.
+// For each IP family, if at least one node has External IPs of that family available, only external IPs
+// of that family are returned. If no nodes have External IPs of that family set, the Internal IPs of
+// that family for all nodes running pods are returned.
 func (k *k3s) podIPs(pods []*core.Pod, svc *core.Service, readyNodes map[string]bool) ([]string, error) {
 	extIPs := sets.Set[string]{}
 	intIPs := sets.Set[string]{}
@@ -353,14 +355,7 @@ func (k *k3s) podIPs(pods []*core.Pod, svc *core.Service, readyNodes map[string]
 		}
 	}
 
-	var ips []string
-	if extIPs.Len() > 0 {
-		ips = extIPs.UnsortedList()
-	} else {
-		ips = intIPs.UnsortedList()
-	}
-
-	ips, err := filterByIPFamily(ips, svc)
+	ips, err := filterByIPFamily(preferExternalIPs(extIPs.UnsortedList(), intIPs.UnsortedList()), svc)
 	if err != nil {
 		return nil, err
 	}
@@ -372,6 +367,22 @@ func (k *k3s) podIPs(pods []*core.Pod, svc *core.Service, readyNodes map[string]
 	return ips, nil
 }
 
+// preferExternalIPs returns the external IPs of each IP family, if there are any, or the internal IPs
+// of that family otherwise. This allows dual-homed nodes that only have an external IP in one family,
+// such as a public IPv4 address and a private IPv6 address, to be advertised in both families.
+func preferExternalIPs(extIPs, intIPs []string) []string {
+	var ips []string
+	for _, isIPv4 := range []bool{true, false} {
+		notFamily := func(ip string) bool { return utilsnet.IsIPv4String(ip) != isIPv4 }
+		familyIPs := slices.DeleteFunc(slices.Clone(extIPs), notFamily)
+		if len(familyIPs) == 0 {
+			familyIPs = slices.DeleteFunc(slices.Clone(intIPs), notFamily)
+		}
+		ips = append(ips, familyIPs...)
+	}
+	return ips
+}
+
 // filterByIPFamily filters node IPs based on dual-stack parameters of the service
 func filterByIPFamily(ips []string, svc *core.Service) ([]string, error) {
 	var ipv4Addresses []string
@@ -432,6 +443,7 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 	oneInt := intstr.FromInt(1)
 	priorityClassName := k.getPriorityClassName(svc)
 	localTraffic := servicehelper.RequestsOnlyLocalTraffic(svc)
+	healthCheck := localTraffic && k.LBHealthCheck && !k.Rootless && svc.Spec.HealthCheckNodePort != 0
 	sourceRangesSet, err := servicehelper.GetLoadBalancerSourceRanges(svc)
 	if err != nil {
 		return nil, err
@@ -452,6 +464,7 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 			}
 		}
 	}
+	securityContext.Sysctls = k.lbSysctls(securityContext.Sysctls)
 
 	ds := &apps.DaemonSet{
 		ObjectMeta: meta.ObjectMeta{
@@ -486,6 +499,7 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 					ServiceAccountName:           "svclb",
 					AutomountServiceAccountToken: utilsptr.To(false),
 					SecurityContext:              securityContext,
+					NodeSelector:                 k.lbNodeSelector(),
 					Tolerations: []core.Toleration{
 						{
 							Key:      util.ControlPlaneRoleLabelKey,
@@ -560,6 +574,9 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 					},
 				},
 			)
+			if healthCheck {
+				container.ReadinessProbe = healthCheckProbe(svc.Spec.HealthCheckNodePort)
+			}
 		} else {
 			container.Env = append(container.Env,
 				core.EnvVar{
@@ -573,18 +590,24 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 			)
 		}
 
+		container.Resources = *k.LBResources.DeepCopy()
 		ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, container)
 	}
 
+	for _, secret := range k.LBImagePullSecrets {
+		ds.Spec.Template.Spec.ImagePullSecrets = append(ds.Spec.Template.Spec.ImagePullSecrets, core.LocalObjectReference{Name: secret})
+	}
+
 	// Add node selector only if label "svccontroller.k3s.cattle.io/enablelb" exists on the nodes
 	enableNodeSelector, err := k.nodeHasDaemonSetLabel()
 	if err != nil {
 		return nil, err
 	}
 	if enableNodeSelector {
-		ds.Spec.Template.Spec.NodeSelector = map[string]string{
-			daemonsetNodeLabel: "true",
+		if ds.Spec.Template.Spec.NodeSelector == nil {
+			ds.Spec.Template.Spec.NodeSelector = map[string]string{}
 		}
+		ds.Spec.Template.Spec.NodeSelector[daemonsetNodeLabel] = "true"
 		// Add node selector for "svccontroller.k3s.cattle.io/lbpool=<pool>" if service has lbpool label
 		if svc.Labels[daemonsetNodePoolLabel] != "" {
 			ds.Spec.Template.Spec.NodeSelector[daemonsetNodePoolLabel] = svc.Labels[daemonsetNodePoolLabel]
@@ -603,6 +626,45 @@ func (k *k3s) newDaemonSet(svc *core.Service) (*apps.DaemonSet, error) {
 	return ds, nil
 }
 
+// healthCheckProbe returns a readiness probe that checks the service's health check node port on the
+// pod's node. kube-proxy only reports the service as healthy on nodes with ready local endpoints, so
+// ServiceLB pods are only ready - and their node is only listed in the service's LoadBalancer status -
+// when traffic sent to the node will not be dropped, as with cloud load-balancers.
+// The node IP is taken from the DEST_IPS env var, which is set to the host IP when traffic is local.
+func healthCheckProbe(port int32) *core.Probe {
+	script := `case "$DEST_IPS" in *:*) host="[$DEST_IPS]" ;; *) host="$DEST_IPS" ;; esac; ` +
+		fmt.Sprintf(`exec wget -q -T 2 -O /dev/null "http://${host}:%d/healthz"`, port)
+	return &core.Probe{
+		ProbeHandler: core.ProbeHandler{
+			Exec: &core.ExecAction{
+				Command: []string{"sh", "-c", script},
+			},
+		},
+		PeriodSeconds:    5,
+		TimeoutSeconds:   3,
+		FailureThreshold: 2,
+	}
+}
+
+// lbNodeSelector returns a copy of the configured ServiceLB node selector, or nil if none is configured.
+func (k *k3s) lbNodeSelector() map[string]string {
+	if len(k.LBNodeSelector) == 0 {
+		return nil
+	}
+	return maps.Clone(k.LBNodeSelector)
+}
+
+// lbSysctls appends the configured ServiceLB sysctls to the provided list, sorted by name.
+// Sysctls already present in the list are not overridden.
+func (k *k3s) lbSysctls(sysctls []core.Sysctl) []core.Sysctl {
+	for _, name := range slices.Sorted(maps.Keys(k.LBSysctls)) {
+		if !slices.ContainsFunc(sysctls, func(s core.Sysctl) bool { return s.Name == name }) {
+			sysctls = append(sysctls, core.Sysctl{Name: name, Value: k.LBSysctls[name]})
+		}
+	}
+	return sysctls
+}
+
 // updateDaemonSets ensures that our DaemonSets have a NodeSelector present if one is enabled,
 // and do not have one if it is not. Nodes are checked for this label when the DaemonSet is generated,
 // but node labels may change between Service updates and the NodeSelector needs to be updated appropriately.
@@ -620,7 +682,10 @@ func (k *k3s) updateDaemonSets() error {
 
 	for _, ds := range daemonsets {
 		ds.Labels[nodeSelectorLabel] = fmt.Sprintf("%t", enableNodeSelector)
-		ds.Spec.Template.Spec.NodeSelector = map[string]string{}
+		ds.Spec.Template.Spec.NodeSelector = k.lbNodeSelector()
+		if ds.Spec.Template.Spec.NodeSelector == nil {
+			ds.Spec.Template.Spec.NodeSelector = map[string]string{}
+		}
 		if enableNodeSelector {
 			ds.Spec.Template.Spec.NodeSelector[daemonsetNodeLabel] = "true"
 		}
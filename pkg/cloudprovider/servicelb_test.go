@@ -114,6 +114,51 @@ func Test_UnitFilterByIPFamily_Ordering(t *testing.T) {
 	}
 }
 
+func Test_UnitPreferExternalIPs(t *testing.T) {
+	tests := []struct {
+		name   string
+		extIPs []string
+		intIPs []string
+		want   []string
+	}{
+		{
+			name:   "Internal only",
+			intIPs: []string{addrv4, addrv6},
+			want:   []string{addrv4, addrv6},
+		},
+		{
+			name:   "External only",
+			extIPs: []string{addrv4_2, addrv6_2},
+			want:   []string{addrv4_2, addrv6_2},
+		},
+		{
+			name:   "External preferred",
+			extIPs: []string{addrv4_2, addrv6_2},
+			intIPs: []string{addrv4, addrv6},
+			want:   []string{addrv4_2, addrv6_2},
+		},
+		{
+			name:   "Dual-homed with external IPv4",
+			extIPs: []string{addrv4_2},
+			intIPs: []string{addrv4, addrv6},
+			want:   []string{addrv4_2, addrv6},
+		},
+		{
+			name:   "Dual-homed with external IPv6",
+			extIPs: []string{addrv6_2},
+			intIPs: []string{addrv4, addrv6},
+			want:   []string{addrv4, addrv6_2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preferExternalIPs(tt.extIPs, tt.intIPs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preferExternalIPs() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_UnitGenerateName(t *testing.T) {
 	uid := types.UID("35a5ccb3-4a82-40b7-8d83-cda9582e4251")
 	tests := []struct {
@@ -160,3 +205,54 @@ func Test_UnitGenerateName(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitLBSysctls(t *testing.T) {
+	k := &k3s{Config: Config{LBSysctls: map[string]string{
+		"net.ipv4.tcp_keepalive_time": "600",
+		"net.ipv4.ip_forward":         "0",
+		"net.core.somaxconn":          "1024",
+	}}}
+	want := []core.Sysctl{
+		{Name: "net.ipv4.ip_forward", Value: "1"},
+		{Name: "net.core.somaxconn", Value: "1024"},
+		{Name: "net.ipv4.tcp_keepalive_time", Value: "600"},
+	}
+	got := k.lbSysctls([]core.Sysctl{{Name: "net.ipv4.ip_forward", Value: "1"}})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lbSysctls() = %+v\nWant = %+v", got, want)
+	}
+}
+
+func Test_UnitConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:   "no version",
+			config: Config{},
+		},
+		{
+			name:   "current version with sysctls",
+			config: Config{APIVersion: ConfigAPIVersion, LBSysctls: map[string]string{"net.core.somaxconn": "1024"}},
+		},
+		{
+			name:    "unsupported version",
+			config:  Config{APIVersion: "cloudprovider.k3s.cattle.io/v2"},
+			wantErr: true,
+		},
+		{
+			name:    "non-net sysctl",
+			config:  Config{LBSysctls: map[string]string{"kernel.shm_rmid_forced": "1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
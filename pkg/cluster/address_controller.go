@@ -11,14 +11,14 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
-func registerAddressHandlers(ctx context.Context, c *Cluster) {
+func registerAddressHandlers(ctx context.Context, c *Cluster, sans []string) {
 	nodes := c.config.Runtime.Core.Core().V1().Node()
 	a := &addressesHandler{
 		nodeController: nodes,
-		allowed:        sets.New(c.config.SANs...),
+		allowed:        sets.New(sans...),
 	}
 
-	logrus.Infof("Starting dynamiclistener CN filter node controller with SANs: %v", c.config.SANs)
+	logrus.Infof("Starting dynamiclistener CN filter node controller with SANs: %v", sans)
 	nodes.OnChange(ctx, "server-cn-filter", a.sync)
 	c.cnFilterFunc = a.filterCN
 }
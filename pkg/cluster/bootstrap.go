@@ -126,7 +126,13 @@ func (c *Cluster) shouldBootstrapLoad(ctx context.Context) (bool, bool, error) {
 
 		// Fail if the token isn't syntactically valid, or if the CA hash on the remote server doesn't match
 		// the hash in the token. The password isn't actually checked until later when actually bootstrapping.
-		info, err := clientaccess.ParseAndValidateToken(c.config.JoinURL, c.config.Token, opts...)
+		// The server may not be reachable yet if all nodes are started at the same time, so retry with backoff.
+		var info *clientaccess.Info
+		err = clientaccess.RetryWithBackoff(ctx, c.config.JoinRetry, "Waiting to validate token with server "+c.config.JoinURL, func(ctx context.Context) error {
+			var err error
+			info, err = clientaccess.ParseAndValidateToken(c.config.JoinURL, c.config.Token, opts...)
+			return err
+		})
 		if err != nil {
 			return false, false, errors.WithMessage(err, "failed to validate token")
 		}
@@ -220,6 +226,17 @@ func migrateBootstrapData(ctx context.Context, data io.Reader, files bootstrap.P
 	return nil
 }
 
+// migrateBootstrapSchema migrates bootstrap data to the current schema version. If any migrations
+// were applied, the migrated data is saved back to the datastore once the datastore is available.
+func (c *Cluster) migrateBootstrapSchema(files bootstrap.PathsDataformat) error {
+	migrated, err := bootstrap.Migrate(files)
+	if err != nil {
+		return err
+	}
+	c.migratedBootstrap = c.migratedBootstrap || migrated
+	return nil
+}
+
 const systemTimeSkew = int64(3)
 
 // isMigrated checks to see if the given bootstrap data
@@ -260,6 +277,9 @@ func (c *Cluster) ReconcileBootstrapData(ctx context.Context, buf io.ReadSeeker,
 			}
 			buf.Seek(0, 0)
 		}
+		if err := c.migrateBootstrapSchema(files); err != nil {
+			return err
+		}
 
 		logrus.Debugf("One or more certificate directories do not exist; writing data to disk from datastore")
 		return bootstrap.WriteToDiskFromStorage(files, crb)
@@ -267,7 +287,8 @@ func (c *Cluster) ReconcileBootstrapData(ctx context.Context, buf io.ReadSeeker,
 
 	if c.managedDB != nil && !isHTTP {
 		token := c.config.Token
-		if token == "" {
+		// servers that joined using a bootstrap token use the server token written to disk when they joined
+		if token == "" || clientaccess.IsBootstrapToken(token) {
 			tokenFromFile, err := util.ReadTokenFromFile(c.config.Runtime.ServerToken, c.config.Runtime.ServerCA, c.config.DataDir)
 			if err != nil {
 				return err
@@ -350,6 +371,9 @@ func (c *Cluster) ReconcileBootstrapData(ctx context.Context, buf io.ReadSeeker,
 		}
 		buf.Seek(0, 0)
 	}
+	if err := c.migrateBootstrapSchema(files); err != nil {
+		return err
+	}
 
 	// Compare on-disk content to the datastore.
 	// If the files differ and the timestamp in the datastore is newer, data on disk will be updated.
@@ -357,6 +381,9 @@ func (c *Cluster) ReconcileBootstrapData(ctx context.Context, buf io.ReadSeeker,
 	var updateDisk bool
 	var newerOnDisk []string
 	for pathKey, fileData := range files {
+		if pathKey == bootstrap.VersionKey {
+			continue
+		}
 		path, ok := paths[pathKey]
 		if !ok || path == "" {
 			logrus.Warnf("Unable to lookup path to reconcile %s", pathKey)
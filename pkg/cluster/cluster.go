@@ -24,6 +24,14 @@ import (
 	utilsnet "k8s.io/utils/net"
 )
 
+const maxDatastoreRetryDelay = 30 * time.Second
+
+var (
+	// datastoreListen and datastoreRetryDelay are variables so that they can be overridden by tests.
+	datastoreListen     = endpoint.Listen
+	datastoreRetryDelay = time.Second
+)
+
 type Cluster struct {
 	clientAccessInfo *clientaccess.Info
 	config           *config.Control
@@ -31,7 +39,12 @@ type Cluster struct {
 	joining          bool
 	storageRunning   bool
 	saveBootstrap    bool
-	cnFilterFunc     func(...string) []string
+	// migratedBootstrap is set if the bootstrap data was migrated to a newer schema
+	// version, and must be saved back to the datastore, replacing the existing data.
+	migratedBootstrap bool
+	srvEndpoint       string
+	srvProxy          *srvProxy
+	cnFilterFunc      func(...string) []string
 }
 
 // ListenAndServe creates the dynamic tls listener, registers http request
@@ -68,10 +81,11 @@ func (c *Cluster) Start(ctx context.Context, wg *sync.WaitGroup) error {
 		return err
 	}
 
-	// if necessary, store bootstrap data to datastore. saveBootstrap is only set
-	// when using kine, so this can be done before the ready channel has been closed.
-	if c.saveBootstrap {
-		if err := Save(ctx, c.config, false); err != nil {
+	// if necessary, store bootstrap data to datastore, replacing the existing data if it was
+	// migrated to a newer schema version. saveBootstrap is only set when using kine, so this
+	// can be done before the ready channel has been closed.
+	if c.saveBootstrap || (c.migratedBootstrap && c.managedDB == nil) {
+		if err := Save(ctx, c.config, c.migratedBootstrap); err != nil {
 			return err
 		}
 	}
@@ -83,7 +97,7 @@ func (c *Cluster) Start(ctx context.Context, wg *sync.WaitGroup) error {
 				case <-executor.ETCDReadyChan():
 					// always save to managed etcd, to ensure that any file modified locally are in sync with the datastore.
 					// this will fail if multiple keys exist, to prevent nodes from running with different bootstrap data.
-					if err := Save(ctx, c.config, false); err != nil && !errors.Is(err, context.Canceled) {
+					if err := Save(ctx, c.config, c.migratedBootstrap); err != nil && !errors.Is(err, context.Canceled) {
 						signals.RequestShutdown(errors.WithMessage(err, "failed to save bootstrap data"))
 						return
 					}
@@ -202,11 +216,43 @@ func (c *Cluster) startStorage(ctx context.Context, bootstrap bool) error {
 		c.setupKinePeering()
 	}
 
+	// resolve external etcd endpoints from DNS SRV records, if requested. The original endpoint
+	// is retained so that the records can be periodically re-resolved once bootstrapping is done.
+	if isSRVEndpoint(c.config.Datastore.Endpoint) {
+		c.srvEndpoint = c.config.Datastore.Endpoint
+	}
+	if c.srvEndpoint != "" {
+		endpoints, err := resolveSRVEndpoint(ctx, c.srvEndpoint)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Resolved datastore endpoints for %s: %v", c.srvEndpoint, endpoints)
+		if !bootstrap {
+			// once bootstrapped, connections are made through a local proxy, so that the
+			// endpoints can be refreshed without restarting the apiserver.
+			return c.startSRVProxy(ctx, endpoints)
+		}
+		c.config.Datastore.Endpoint = strings.Join(endpoints, ",")
+	}
+
+	// once bootstrapped, kine is restarted if the datastore client certificate is rotated,
+	// so retain the original datastore configuration, and a context that can be used to stop kine.
+	datastore := c.config.Datastore
+	storageCtx, cancel := ctx, context.CancelFunc(func() {})
+	reloadCerts := !bootstrap && reloadsDatastoreCerts(datastore)
+	if reloadCerts {
+		storageCtx, cancel = context.WithCancel(ctx)
+	}
+
 	// start listening on the kine socket as an etcd endpoint, or return the external etcd endpoints
-	etcdConfig, err := endpoint.Listen(ctx, c.config.Datastore)
+	etcdConfig, err := c.listenDatastore(storageCtx, c.config.Datastore)
 	if err != nil {
+		cancel()
 		return errors.WithMessage(err, "creating storage endpoint")
 	}
+	if reloadCerts {
+		go c.watchDatastoreCerts(ctx, datastore, cancel)
+	}
 
 	// Persist the returned etcd configuration. We decide if we're doing leader election for embedded controllers
 	// based on what the kine wrapper tells us about the datastore. Single-node datastores like sqlite don't require
@@ -224,6 +270,27 @@ func (c *Cluster) startStorage(ctx context.Context, bootstrap bool) error {
 	return nil
 }
 
+// listenDatastore starts kine, or returns the external etcd endpoints. If a datastore retry timeout
+// is configured, failures are retried with exponential backoff until the timeout expires, so that
+// the server can start while the datastore is temporarily unavailable.
+func (c *Cluster) listenDatastore(ctx context.Context, datastore endpoint.Config) (endpoint.ETCDConfig, error) {
+	deadline := time.Now().Add(c.config.DatastoreRetryTimeout.Duration)
+	delay := datastoreRetryDelay
+	for {
+		etcdConfig, err := datastoreListen(ctx, datastore)
+		if err == nil || time.Now().Add(delay).After(deadline) {
+			return etcdConfig, err
+		}
+		logrus.Warnf("Failed to connect to datastore, retrying in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return etcdConfig, errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDatastoreRetryDelay)
+	}
+}
+
 func (c *Cluster) setupKinePeering() {
 	if strings.HasPrefix(c.config.Datastore.Endpoint, "t4://") {
 		address := c.config.BindAddressOrLoopback(true, true) + ":3380"
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/kine/pkg/endpoint"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitListenDatastore(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryTimeout time.Duration
+		failures     int
+		wantCalls    int
+		wantErr      bool
+	}{
+		{
+			name:      "succeeds without retry",
+			wantCalls: 1,
+		},
+		{
+			name:      "no retry when timeout is unset",
+			failures:  1,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:         "succeeds after retries",
+			retryTimeout: time.Minute,
+			failures:     2,
+			wantCalls:    3,
+		},
+		{
+			name:         "fails after timeout",
+			retryTimeout: 10 * time.Millisecond,
+			failures:     100,
+			wantCalls:    -1,
+			wantErr:      true,
+		},
+	}
+	defer func(f func(context.Context, endpoint.Config) (endpoint.ETCDConfig, error), d time.Duration) {
+		datastoreListen, datastoreRetryDelay = f, d
+	}(datastoreListen, datastoreRetryDelay)
+	datastoreRetryDelay = time.Millisecond
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			datastoreListen = func(_ context.Context, datastore endpoint.Config) (endpoint.ETCDConfig, error) {
+				calls++
+				if calls <= tt.failures {
+					return endpoint.ETCDConfig{}, errors.New("connection refused")
+				}
+				return endpoint.ETCDConfig{Endpoints: []string{datastore.Endpoint}}, nil
+			}
+			c := New(&config.Control{DatastoreRetryTimeout: metav1.Duration{Duration: tt.retryTimeout}})
+			etcdConfig, err := c.listenDatastore(context.Background(), endpoint.Config{Endpoint: "postgres://db:5432/k3s"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenDatastore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCalls < 0 {
+				// the number of attempts before the timeout depends on timing; only check that Listen was retried
+				if calls < 2 || calls >= tt.failures {
+					t.Errorf("listenDatastore() called Listen %d times, want between 2 and %d", calls, tt.failures)
+				}
+			} else if calls != tt.wantCalls {
+				t.Errorf("listenDatastore() called Listen %d times, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr && len(etcdConfig.Endpoints) != 1 {
+				t.Errorf("listenDatastore() endpoints = %v, want 1 endpoint", etcdConfig.Endpoints)
+			}
+		})
+	}
+}
+
+func Test_UnitListenDatastoreCanceled(t *testing.T) {
+	defer func(f func(context.Context, endpoint.Config) (endpoint.ETCDConfig, error), d time.Duration) {
+		datastoreListen, datastoreRetryDelay = f, d
+	}(datastoreListen, datastoreRetryDelay)
+	datastoreRetryDelay = time.Hour
+	datastoreListen = func(context.Context, endpoint.Config) (endpoint.ETCDConfig, error) {
+		return endpoint.ETCDConfig{}, errors.New("connection refused")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	c := New(&config.Control{DatastoreRetryTimeout: metav1.Duration{Duration: 24 * time.Hour}})
+	if _, err := c.listenDatastore(ctx, endpoint.Config{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("listenDatastore() error = %v, want %v", err, context.Canceled)
+	}
+}
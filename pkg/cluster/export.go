@@ -0,0 +1,161 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/bootstrap"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/store"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/kine/pkg/drivers/sqlite"
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"github.com/k3s-io/kine/pkg/tls"
+	"github.com/sirupsen/logrus"
+	etcderrors "go.etcd.io/etcd/server/v3/etcdserver/errors"
+)
+
+// ExportBootstrap reads the bootstrap data for the current token from the datastore. The data is
+// returned as stored, encrypted with the token, unless plaintext is true, in which case the decrypted
+// JSON document is returned.
+func ExportBootstrap(ctx context.Context, config *config.Control, plaintext bool) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	normalizedToken, err := util.NormalizeToken(config.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	storageClient, err := newDatastoreClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer storageClient.Close()
+
+	kv, err := storageClient.Get(ctx, storageKey(normalizedToken))
+	if err != nil {
+		if errors.Is(err, etcderrors.ErrKeyNotFound) {
+			return nil, errors.New("no bootstrap data found in the datastore for the current token")
+		}
+		return nil, err
+	}
+	if len(kv.Value) == 0 {
+		return nil, errors.New("bootstrap key is locked by a server that has not yet saved its bootstrap data")
+	}
+
+	data, err := decrypt(normalizedToken, kv.Value)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to decrypt bootstrap data, check that the token is correct")
+	}
+	if plaintext {
+		return data, nil
+	}
+	return kv.Value, nil
+}
+
+// ImportBootstrap writes previously exported bootstrap data to the datastore, under the key for the current token.
+// The data may be either the encrypted export, or the decrypted JSON document; in either case it is validated
+// before being stored. Existing bootstrap data is only replaced if force is true.
+func ImportBootstrap(ctx context.Context, config *config.Control, data []byte, force bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	normalizedToken, err := util.NormalizeToken(config.Token)
+	if err != nil {
+		return err
+	}
+
+	data, err = importData(normalizedToken, data)
+	if err != nil {
+		return err
+	}
+
+	storageClient, err := newDatastoreClient(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer storageClient.Close()
+
+	key := storageKey(normalizedToken)
+	kv, err := storageClient.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, etcderrors.ErrKeyNotFound) {
+			return err
+		}
+		return storageClient.Create(ctx, key, data)
+	}
+	if len(kv.Value) > 0 && !force {
+		return errors.New("bootstrap data already exists in the datastore for the current token")
+	}
+	logrus.Infof("Replacing existing bootstrap data at %s", key)
+	return storageClient.Update(ctx, key, kv.ModRevision, data)
+}
+
+// importData validates exported bootstrap data, and returns it encrypted with the token. Decrypted data
+// must be a valid JSON document; encrypted data must have been encrypted with the same token.
+func importData(normalizedToken string, data []byte) ([]byte, error) {
+	if json.Valid(data) {
+		files := bootstrap.PathsDataformat{}
+		if err := json.Unmarshal(data, &files); err != nil {
+			return nil, errors.WithMessage(err, "failed to parse bootstrap data")
+		}
+		return encrypt(normalizedToken, data)
+	}
+	if _, err := decrypt(normalizedToken, data); err != nil {
+		return nil, errors.WithMessage(err, "failed to decrypt bootstrap data, check that the token is the one it was exported with")
+	}
+	return data, nil
+}
+
+// newDatastoreClient returns a client for the datastore that holds the cluster's bootstrap data, for use
+// outside of a running server. External etcd endpoints are connected to directly; for other external
+// datastores a temporary kine listener is started, and stopped when the context is cancelled. If no
+// endpoint is configured, the local embedded etcd or sqlite datastore is used.
+func newDatastoreClient(ctx context.Context, config *config.Control) (*store.RemoteStore, error) {
+	ds := config.Datastore
+	if ds.Endpoint == "" {
+		if _, err := os.Stat(filepath.Join(config.DataDir, "db", "etcd")); err == nil {
+			return store.NewRemoteStore(endpoint.ETCDConfig{
+				Endpoints: []string{fmt.Sprintf("https://%s:2379", config.Loopback(true))},
+				TLSConfig: tls.Config{
+					CAFile:   config.Runtime.ETCDServerCA,
+					CertFile: config.Runtime.ClientETCDCert,
+					KeyFile:  config.Runtime.ClientETCDKey,
+				},
+			})
+		}
+		ds.Endpoint = "sqlite://" + filepath.Join(config.DataDir, "db", "state.db") + "?" + sqlite.DefaultParams
+	}
+
+	if isSRVEndpoint(ds.Endpoint) {
+		endpoints, err := resolveSRVEndpoint(ctx, ds.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		ds.Endpoint = strings.Join(endpoints, ",")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kine-")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(tmpDir)
+	}()
+
+	ds.Listener = "unix://" + filepath.Join(tmpDir, "kine.sock")
+	ds.ServerTLSConfig = tls.Config{}
+	ds.MetricsRegisterer = nil
+	etcdConfig, err := endpoint.Listen(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewRemoteStore(etcdConfig)
+}
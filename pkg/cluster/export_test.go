@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_UnitImportData(t *testing.T) {
+	plaintext := []byte(`{"ServerCA":{"Timestamp":"2024-01-01T00:00:00Z","Content":"Y2E="}}`)
+	encrypted, err := encrypt("token", plaintext)
+	if err != nil {
+		t.Fatalf("encrypt() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name:  "decrypted data is encrypted with token",
+			token: "token",
+			data:  plaintext,
+		},
+		{
+			name:  "encrypted data is stored as-is",
+			token: "token",
+			data:  encrypted,
+		},
+		{
+			name:    "encrypted data with different token",
+			token:   "other-token",
+			data:    encrypted,
+			wantErr: true,
+		},
+		{
+			name:    "json that is not bootstrap data",
+			token:   "token",
+			data:    []byte(`["ServerCA"]`),
+			wantErr: true,
+		},
+		{
+			name:    "garbage",
+			token:   "token",
+			data:    []byte("not bootstrap data"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importData(tt.token, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			decrypted, err := decrypt(tt.token, got)
+			if err != nil {
+				t.Fatalf("decrypt() error = %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("importData() decrypted = %s, want %s", decrypted, plaintext)
+			}
+		})
+	}
+}
@@ -10,9 +10,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/rancher/dynamiclistener"
@@ -49,17 +51,23 @@ func (c *Cluster) newListener(ctx context.Context) (net.Listener, http.Handler,
 	if err != nil {
 		return nil, nil, err
 	}
-	c.config.SANs = append(c.config.SANs, "kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc."+c.config.ClusterDomain)
+	// The apiserver names are not added to the tls-san list, as they may change while the server is running,
+	// and the kube-apiserver serving cert must not retain names that have been removed from the apiserver-san-file.
+	apiserverSANs, err := deps.APIServerSANs(c.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	sans := append(slices.Clone(c.config.SANs), apiserverSANs...)
 	if c.config.SANSecurity {
 		c.config.Runtime.ClusterControllerStarts["server-cn-filter"] = func(ctx context.Context) {
-			registerAddressHandlers(ctx, c)
+			registerAddressHandlers(ctx, c, sans)
 		}
 	}
 	storage := tlsStorage(ctx, c.config.DataDir, c.config.Runtime)
 	return wrapHandler(dynamiclistener.NewListenerWithChain(tcp, storage, certs, key, dynamiclistener.Config{
 		ExpirationDaysCheck: config.CertificateRenewDays,
 		Organization:        []string{version.Program},
-		SANs:                c.config.SANs,
+		SANs:                sans,
 		CN:                  version.Program,
 		TLSConfig: &tls.Config{
 			ClientAuth:   tls.RequestClientCert,
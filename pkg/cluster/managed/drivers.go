@@ -49,6 +49,49 @@ func Clear() {
 // SnapshotResult is returned by the Snapshot function,
 // and lists the names of created and deleted snapshots.
 type SnapshotResult struct {
-	Created []string `json:"created,omitempty"`
-	Deleted []string `json:"deleted,omitempty"`
+	Created  []string         `json:"created,omitempty"`
+	Deleted  []string         `json:"deleted,omitempty"`
+	Verified []SnapshotStatus `json:"verified,omitempty"`
+}
+
+// SnapshotStatus contains the result of verifying a single snapshot.
+// Error is set if the snapshot could not be verified.
+type SnapshotStatus struct {
+	Name      string `json:"name"`
+	Location  string `json:"location,omitempty"`
+	Hash      uint32 `json:"hash,omitempty"`
+	Revision  int64  `json:"revision,omitempty"`
+	TotalKey  int    `json:"totalKey,omitempty"`
+	TotalSize int64  `json:"totalSize,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DefragResult is returned by the etcd defragmentation handler,
+// and lists the defragmentation status of each member, in the order
+// that they were defragmented.
+type DefragResult struct {
+	Members []DefragStatus `json:"members,omitempty"`
+}
+
+// DefragStatus contains the result of defragmenting a single member.
+// Error is set if the member could not be defragmented.
+type DefragStatus struct {
+	Name       string `json:"name"`
+	Endpoint   string `json:"endpoint"`
+	Leader     bool   `json:"leader,omitempty"`
+	SizeBefore int64  `json:"sizeBefore,omitempty"`
+	SizeAfter  int64  `json:"sizeAfter,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MemberHealth contains the health of a single etcd member, as reported by the member itself.
+// Error is set if the member could not be reached or reported errors.
+type MemberHealth struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Healthy  bool   `json:"healthy"`
+	Leader   bool   `json:"leader,omitempty"`
+	Learner  bool   `json:"learner,omitempty"`
+	DBSize   int64  `json:"dbSize,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
@@ -0,0 +1,268 @@
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/kine/pkg/endpoint"
+	kinetls "github.com/k3s-io/kine/pkg/tls"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// srvScheme is the datastore endpoint scheme used to discover external etcd endpoints via DNS SRV records.
+	srvScheme = "dns+srv://"
+	// srvRefreshInterval is the interval at which SRV records are re-resolved to refresh the endpoint list.
+	srvRefreshInterval = time.Minute
+	// srvDialTimeout is the timeout for the datastore SRV proxy's connections to each endpoint.
+	srvDialTimeout = 5 * time.Second
+)
+
+// srvServices are the etcd client SRV services, and the scheme used for each, in the order that they are
+// queried. These match the service names used by etcd's own DNS discovery.
+var srvServices = []struct {
+	service string
+	scheme  string
+}{
+	{service: "etcd-client-ssl", scheme: "https"},
+	{service: "etcd-client", scheme: "http"},
+}
+
+// lookupSRV is the resolver function used to look up SRV records. It is a variable so that it can be replaced in tests.
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// isSRVEndpoint returns true if the datastore endpoint should be discovered via DNS SRV records.
+func isSRVEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, srvScheme)
+}
+
+// resolveSRVEndpoint resolves a dns+srv:// datastore endpoint to a list of etcd client URLs, sorted for stable
+// comparison. The _etcd-client-ssl._tcp record for the domain is used if present, falling back to _etcd-client._tcp.
+func resolveSRVEndpoint(ctx context.Context, endpoint string) ([]string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to parse datastore endpoint")
+	}
+	domain := u.Hostname()
+	if domain == "" {
+		return nil, errors.New("dns+srv datastore endpoint must specify a domain")
+	}
+
+	var errs []error
+	for _, s := range srvServices {
+		_, addrs, err := lookupSRV(ctx, s.service, "tcp", domain)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		endpoints := []string{}
+		for _, addr := range addrs {
+			host := strings.TrimSuffix(addr.Target, ".")
+			endpoints = append(endpoints, s.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(addr.Port))))
+		}
+		if len(endpoints) == 0 {
+			continue
+		}
+		slices.Sort(endpoints)
+		return slices.Compact(endpoints), nil
+	}
+	return nil, errors.WithMessagef(errors.Join(errs...), "failed to resolve etcd SRV records for %s", domain)
+}
+
+// srvProxy proxies etcd client connections to the external etcd endpoints discovered via DNS SRV records.
+// The apiserver and supervisor connect to the proxy's unix socket without TLS, and each connection is
+// forwarded to one of the current endpoints using the datastore client TLS configuration. This allows the
+// endpoint list to be updated when the SRV records change, without restarting the apiserver.
+type srvProxy struct {
+	tlsConfig kinetls.Config
+
+	mu        sync.Mutex
+	endpoints []string
+	next      int
+}
+
+func newSRVProxy(tlsConfig kinetls.Config, endpoints []string) *srvProxy {
+	return &srvProxy{tlsConfig: tlsConfig, endpoints: endpoints}
+}
+
+// setEndpoints replaces the list of endpoints that new connections are forwarded to, and returns true
+// if the list changed. Existing connections are not affected.
+func (p *srvProxy) setEndpoints(endpoints []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slices.Equal(endpoints, p.endpoints) {
+		return false
+	}
+	p.endpoints = endpoints
+	p.next = 0
+	return true
+}
+
+// listen starts accepting connections on a unix socket at the given path, until the context is cancelled.
+func (p *srvProxy) listen(ctx context.Context, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					logrus.Errorf("Datastore SRV proxy failed to accept connection: %v", err)
+				}
+				return
+			}
+			go p.proxy(ctx, conn)
+		}
+	}()
+	return nil
+}
+
+// proxy forwards a single client connection to an upstream endpoint.
+func (p *srvProxy) proxy(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	upstream, err := p.dial(ctx)
+	if err != nil {
+		logrus.Warnf("Datastore SRV proxy failed to connect to any endpoint: %v", err)
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// dial connects to the next available endpoint, trying each endpoint in turn until one succeeds.
+func (p *srvProxy) dial(ctx context.Context) (net.Conn, error) {
+	p.mu.Lock()
+	endpoints := p.endpoints
+	next := p.next
+	p.mu.Unlock()
+
+	var errs []error
+	for i := range endpoints {
+		idx := (next + i) % len(endpoints)
+		conn, err := p.dialEndpoint(ctx, endpoints[idx])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.mu.Lock()
+		if slices.Equal(endpoints, p.endpoints) {
+			p.next = (idx + 1) % len(endpoints)
+		}
+		p.mu.Unlock()
+		return conn, nil
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no datastore endpoints available")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialEndpoint connects to a single endpoint, using TLS if the endpoint is https. The client certificate
+// is loaded for each connection, so that rotated certificates are used without restarting the proxy.
+func (p *srvProxy) dialEndpoint(ctx context.Context, endpoint string) (net.Conn, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: srvDialTimeout}
+	if u.Scheme != "https" {
+		return dialer.DialContext(ctx, "tcp", u.Host)
+	}
+	tlsConfig, err := p.tlsConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = u.Hostname()
+	// etcd serves gRPC over HTTP/2, which the client negotiates via ALPN when connecting directly.
+	tlsConfig.NextProtos = []string{"h2"}
+	return (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", u.Host)
+}
+
+// startSRVProxy starts the datastore SRV proxy, and configures the apiserver and supervisor etcd clients
+// to connect to it. The proxy's endpoints are periodically refreshed from the SRV records.
+func (c *Cluster) startSRVProxy(ctx context.Context, endpoints []string) error {
+	if c.srvProxy == nil {
+		c.srvProxy = newSRVProxy(c.config.Datastore.BackendTLSConfig, endpoints)
+		c.refreshSRVEndpoints(ctx)
+	} else {
+		c.srvProxy.setEndpoints(endpoints)
+	}
+
+	path := filepath.Join(c.config.DataDir, "db", "etcd-srv.sock")
+	if err := c.srvProxy.listen(ctx, path); err != nil {
+		return errors.WithMessage(err, "failed to start datastore SRV proxy")
+	}
+	logrus.Infof("Proxying datastore connections for %s via %s", c.srvEndpoint, path)
+
+	c.config.Runtime.EtcdConfig = endpoint.ETCDConfig{
+		Endpoints:   []string{"unix://" + path},
+		LeaderElect: true,
+	}
+	c.config.Datastore.BackendTLSConfig = kinetls.Config{}
+	c.config.Datastore.Endpoint = c.config.Runtime.EtcdConfig.Endpoints[0]
+	c.config.NoLeaderElect = false
+	return nil
+}
+
+// refreshSRVEndpoints periodically re-resolves the SRV datastore endpoint, and updates the endpoints that
+// the datastore SRV proxy forwards new connections to when the list changes.
+func (c *Cluster) refreshSRVEndpoints(ctx context.Context) {
+	proxy := c.srvProxy
+	// We use Poll here instead of Until because we want to wait the interval before running the function.
+	go wait.PollUntilWithContext(ctx, srvRefreshInterval, func(ctx context.Context) (bool, error) {
+		endpoints, err := resolveSRVEndpoint(ctx, c.srvEndpoint)
+		if err != nil {
+			logrus.Warnf("Failed to refresh datastore endpoints: %v", err)
+			return false, nil
+		}
+		if proxy.setEndpoints(endpoints) {
+			logrus.Infof("Datastore endpoints for %s changed to %v", c.srvEndpoint, endpoints)
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/kine/pkg/tls"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func Test_UnitResolveSRVEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		records  map[string][]*net.SRV
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "TLS records",
+			endpoint: "dns+srv://etcd.example.com",
+			records: map[string][]*net.SRV{
+				"etcd-client-ssl": {
+					{Target: "etcd-1.example.com.", Port: 2379},
+					{Target: "etcd-0.example.com.", Port: 2379},
+				},
+				"etcd-client": {
+					{Target: "etcd-0.example.com.", Port: 2379},
+				},
+			},
+			want: []string{"https://etcd-0.example.com:2379", "https://etcd-1.example.com:2379"},
+		},
+		{
+			name:     "Fall back to insecure records",
+			endpoint: "dns+srv://etcd.example.com",
+			records: map[string][]*net.SRV{
+				"etcd-client": {
+					{Target: "etcd-0.example.com.", Port: 2379},
+				},
+			},
+			want: []string{"http://etcd-0.example.com:2379"},
+		},
+		{
+			name:     "No records",
+			endpoint: "dns+srv://etcd.example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "No domain",
+			endpoint: "dns+srv://",
+			wantErr:  true,
+		},
+	}
+	defer func(f func(context.Context, string, string, string) (string, []*net.SRV, error)) { lookupSRV = f }(lookupSRV)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupSRV = func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				if addrs, ok := tt.records[service]; ok && name == "etcd.example.com" {
+					return "_" + service + "._" + proto + "." + name, addrs, nil
+				}
+				return "", nil, errors.New("no such host")
+			}
+			got, err := resolveSRVEndpoint(context.Background(), tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveSRVEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveSRVEndpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitSRVProxy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// each upstream replies with its name, so that the endpoint a connection was forwarded to can be identified
+	upstream := func(name string) string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Write([]byte(name))
+				conn.Close()
+			}
+		}()
+		return "http://" + listener.Addr().String()
+	}
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedEndpoint := "http://" + closed.Addr().String()
+	closed.Close()
+
+	endpoint0 := upstream("etcd-0")
+	endpoint1 := upstream("etcd-1")
+	path := filepath.Join(t.TempDir(), "etcd-srv.sock")
+	proxy := newSRVProxy(tls.Config{}, []string{closedEndpoint, endpoint0})
+	if err := proxy.listen(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		t.Helper()
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		b, _ := io.ReadAll(conn)
+		return string(b)
+	}
+
+	// unavailable endpoints are skipped
+	if got := read(); got != "etcd-0" {
+		t.Errorf("proxied connection reached %q, want %q", got, "etcd-0")
+	}
+
+	// new connections use the updated endpoints
+	if !proxy.setEndpoints([]string{endpoint1}) {
+		t.Error("setEndpoints() = false for changed endpoints, want true")
+	}
+	if proxy.setEndpoints([]string{endpoint1}) {
+		t.Error("setEndpoints() = true for unchanged endpoints, want false")
+	}
+	if got := read(); got != "etcd-1" {
+		t.Errorf("proxied connection reached %q, want %q", got, "etcd-1")
+	}
+
+	// no connection is made if no endpoint is available
+	proxy.setEndpoints([]string{closedEndpoint})
+	if got := read(); got != "" {
+		t.Errorf("proxied connection reached %q, want no upstream", got)
+	}
+
+	// the listener is closed when the context is cancelled
+	cancel()
+	if err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil, nil
+	}); err != nil {
+		t.Error("proxy listener was not closed when the context was cancelled")
+	}
+}
@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// kineListenerCloseTimeout is the maximum amount of time to wait for a stopped kine listener to close.
+const kineListenerCloseTimeout = 30 * time.Second
+
+// datastoreCertsPollInterval is the interval at which the datastore client certificate and key are checked for changes.
+// It is a variable so that it can be overridden by tests.
+var datastoreCertsPollInterval = 30 * time.Second
+
+// reloadsDatastoreCerts returns true if kine must be restarted to use new datastore client credentials. When the
+// datastore is etcd, kine is not used, and the etcd clients used by the apiserver and supervisor already load the
+// client certificate from disk for each new connection.
+func reloadsDatastoreCerts(datastore endpoint.Config) bool {
+	if datastore.BackendTLSConfig.CertFile == "" || datastore.BackendTLSConfig.KeyFile == "" {
+		return false
+	}
+	return !strings.HasPrefix(datastore.Endpoint, "http://") && !strings.HasPrefix(datastore.Endpoint, "https://")
+}
+
+// readKeyPair reads the datastore client certificate and key, and ensures that they are a valid key pair.
+func readKeyPair(datastore endpoint.Config) ([]byte, error) {
+	certPEM, err := os.ReadFile(datastore.BackendTLSConfig.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(datastore.BackendTLSConfig.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, err
+	}
+	return append(certPEM, keyPEM...), nil
+}
+
+// watchDatastoreCerts polls the datastore client certificate and key for changes. When a new valid key pair is
+// found, kine is restarted with the original datastore configuration, so that connections to the datastore are
+// re-established using the new credentials. The apiserver reconnects to the kine socket once it is restarted.
+func (c *Cluster) watchDatastoreCerts(ctx context.Context, datastore endpoint.Config, cancel context.CancelFunc) {
+	current, err := readKeyPair(datastore)
+	if err != nil {
+		logrus.Warnf("Failed to read datastore client certificate: %v", err)
+	}
+
+	// kine metrics have already been registered, and cannot be registered again
+	datastore.MetricsRegisterer = nil
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		keyPair, err := readKeyPair(datastore)
+		if err != nil {
+			// the certificate and key may not be rotated at the same time; try again on the next poll
+			logrus.Debugf("Datastore client certificate is not valid: %v", err)
+			return
+		}
+		if bytes.Equal(keyPair, current) {
+			return
+		}
+
+		logrus.Infof("Datastore client certificate %s changed, restarting kine", datastore.BackendTLSConfig.CertFile)
+		// Stop the current kine listener and wait for it to close before starting a new one,
+		// as closing the old listener would otherwise remove the new listener's socket.
+		cancel()
+		if err := waitForListenerClose(ctx, datastore.Listener); err != nil {
+			// the old listener is still running; try again on the next poll
+			logrus.Errorf("Failed to wait for kine to stop: %v", err)
+			return
+		}
+
+		var storageCtx context.Context
+		storageCtx, cancel = context.WithCancel(ctx)
+		if _, err := datastoreListen(storageCtx, datastore); err != nil {
+			// kine is not running; try again on the next poll
+			logrus.Errorf("Failed to restart kine with new datastore client certificate: %v", err)
+			cancel()
+			return
+		}
+		current = keyPair
+	}, datastoreCertsPollInterval)
+}
+
+// waitForListenerClose waits until the kine listener is no longer accepting connections.
+func waitForListenerClose(ctx context.Context, listener string) error {
+	if listener == "" {
+		listener = endpoint.KineSocket
+	}
+	network, address, ok := strings.Cut(listener, "://")
+	if !ok {
+		network, address = "tcp", listener
+	}
+	return wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, kineListenerCloseTimeout, true, func(ctx context.Context) (bool, error) {
+		conn, err := (&net.Dialer{Timeout: time.Second}).DialContext(ctx, network, address)
+		if err != nil {
+			return true, nil
+		}
+		conn.Close()
+		return false, nil
+	})
+}
@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"github.com/k3s-io/kine/pkg/tls"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func Test_UnitReloadsDatastoreCerts(t *testing.T) {
+	clientTLS := tls.Config{CertFile: "client.crt", KeyFile: "client.key"}
+	tests := []struct {
+		name      string
+		datastore endpoint.Config
+		want      bool
+	}{
+		{
+			name:      "Postgres with client certificate",
+			datastore: endpoint.Config{Endpoint: "postgres://db.example.com:5432/k3s", BackendTLSConfig: clientTLS},
+			want:      true,
+		},
+		{
+			name:      "MySQL without client certificate",
+			datastore: endpoint.Config{Endpoint: "mysql://tcp(db.example.com:3306)/k3s", BackendTLSConfig: tls.Config{CAFile: "ca.crt"}},
+		},
+		{
+			name:      "Etcd with client certificate",
+			datastore: endpoint.Config{Endpoint: "https://etcd-0.example.com:2379,https://etcd-1.example.com:2379", BackendTLSConfig: clientTLS},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reloadsDatastoreCerts(tt.datastore); got != tt.want {
+				t.Errorf("reloadsDatastoreCerts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitWatchDatastoreCerts(t *testing.T) {
+	defer func(f func(context.Context, endpoint.Config) (endpoint.ETCDConfig, error), d time.Duration) {
+		datastoreListen, datastoreCertsPollInterval = f, d
+	}(datastoreListen, datastoreCertsPollInterval)
+	datastoreCertsPollInterval = 10 * time.Millisecond
+
+	tempDir := t.TempDir()
+	datastore := endpoint.Config{
+		Endpoint:         "postgres://db.example.com:5432/k3s",
+		Listener:         "unix://" + filepath.Join(tempDir, "kine.sock"),
+		BackendTLSConfig: tls.Config{CertFile: filepath.Join(tempDir, "client.crt"), KeyFile: filepath.Join(tempDir, "client.key")},
+	}
+	writeKeyPair := func() {
+		t.Helper()
+		cert, key, err := certutil.GenerateSelfSignedCertKey("client", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(datastore.BackendTLSConfig.CertFile, cert, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(datastore.BackendTLSConfig.KeyFile, key, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeKeyPair()
+
+	// the initial kine listener takes some time to close after it is stopped
+	oldListener, err := net.Listen("unix", filepath.Join(tempDir, "kine.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oldClosed atomic.Bool
+	stopOld := func() {
+		time.AfterFunc(200*time.Millisecond, func() {
+			oldClosed.Store(true)
+			oldListener.Close()
+		})
+	}
+
+	restarted := make(chan bool, 1)
+	datastoreListen = func(context.Context, endpoint.Config) (endpoint.ETCDConfig, error) {
+		restarted <- oldClosed.Load()
+		return endpoint.ETCDConfig{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&Cluster{}).watchDatastoreCerts(ctx, datastore, stopOld)
+
+	time.Sleep(50 * time.Millisecond)
+	writeKeyPair()
+
+	select {
+	case closed := <-restarted:
+		if !closed {
+			t.Error("watchDatastoreCerts() restarted kine before the old listener was closed")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watchDatastoreCerts() did not restart kine after the client certificate changed")
+	}
+}
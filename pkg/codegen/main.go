@@ -6,25 +6,48 @@ import (
 	v1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
 	controllergen "github.com/rancher/wrangler/pkg/controller-gen"
 	"github.com/rancher/wrangler/pkg/controller-gen/args"
+	"github.com/sirupsen/logrus"
 )
 
 var (
 	basePackage = "github.com/k3s-io/k3s/types"
+
+	types = []any{
+		v1.Addon{},
+		v1.ETCDSnapshotFile{},
+		v1.ETCDSnapshotRestore{},
+	}
 )
 
 func main() {
 	os.Unsetenv("GOPATH")
+
+	// Types, clients, listers, and informers are generated into the pkg/apis module, so that they can be
+	// imported by external controllers without depending on the rest of k3s. Wrangler controllers are not
+	// part of the published module, as they would add a dependency on wrangler.
+	controllergen.Run(args.Options{
+		OutputPackage: "github.com/k3s-io/k3s/pkg/apis/generated",
+		Boilerplate:   "scripts/boilerplate.go.txt",
+		Groups: map[string]args.Group{
+			"k3s.cattle.io": {
+				Types:             types,
+				GenerateTypes:     true,
+				GenerateClients:   true,
+				GenerateListers:   true,
+				GenerateInformers: true,
+			},
+		},
+	})
+	if err := os.RemoveAll("pkg/apis/generated/controllers"); err != nil {
+		logrus.Fatal(err)
+	}
+
 	controllergen.Run(args.Options{
 		OutputPackage: "github.com/k3s-io/k3s/pkg/generated",
 		Boilerplate:   "scripts/boilerplate.go.txt",
 		Groups: map[string]args.Group{
 			"k3s.cattle.io": {
-				Types: []any{
-					v1.Addon{},
-					v1.ETCDSnapshotFile{},
-				},
-				GenerateTypes:   true,
-				GenerateClients: true,
+				Types: types,
 			},
 		},
 	})
@@ -11,11 +11,12 @@ import (
 )
 
 var DefaultParser = &Parser{
-	After:         []string{"server", "agent", "etcd-snapshot:1"},
+	After:         []string{"server", "agent", "etcd-snapshot:1", "migrate:1", "check:1"},
 	ConfigFlags:   []string{"--config", "-c"},
 	EnvName:       version.ProgramUpper + "_CONFIG_FILE",
 	DefaultConfig: "/etc/rancher/" + version.Program + "/config.yaml",
-	ValidFlags:    map[string][]cli.Flag{"server": cmds.ServerFlags, "etcd-snapshot": cmds.EtcdSnapshotFlags},
+	ValidFlags:    map[string][]cli.Flag{"server": cmds.ServerFlags, "etcd-snapshot": cmds.EtcdSnapshotFlags, "etcd-snapshot restore": cmds.ServerFlags, "migrate cluster-domain": cmds.ServerFlags, "migrate service-cidr": cmds.ServerFlags, "check cis": cmds.ServerFlags, "check audit-log": cmds.ServerFlags},
+	MigrateArgs:   cmds.MigrateArgs,
 }
 
 func MustParse(args []string) []string {
@@ -50,6 +50,27 @@ func Test_UnitMustParse(t *testing.T) {
 			config: "./testdata/defaultdata.yaml",
 			want:   []string{"k3s", "etcd-snapshot", "save", "--etcd-s3=true", "--etcd-s3-bucket=my-backup"},
 		},
+		{
+			name:   "Etcd-snapshot restore with config uses server flags",
+			args:   []string{"k3s", "etcd-snapshot", "restore", "on-demand-1700000000"},
+			config: "./testdata/defaultdata.yaml",
+			want: []string{"k3s", "etcd-snapshot", "restore", "--token=12345", "--node-label=DEAFBEEF",
+				"--etcd-s3=true", "--etcd-s3-bucket=my-backup", "--kubelet-arg=max-pods=999", "on-demand-1700000000"},
+		},
+		{
+			name:   "Migrate cluster-domain with config uses server flags",
+			args:   []string{"k3s", "migrate", "cluster-domain", "--to=newdomain.local"},
+			config: "./testdata/defaultdata.yaml",
+			want: []string{"k3s", "migrate", "cluster-domain", "--token=12345", "--node-label=DEAFBEEF",
+				"--etcd-s3=true", "--etcd-s3-bucket=my-backup", "--kubelet-arg=max-pods=999", "--to=newdomain.local"},
+		},
+		{
+			name:   "Check cis with config uses server flags",
+			args:   []string{"k3s", "check", "cis", "--fix"},
+			config: "./testdata/defaultdata.yaml",
+			want: []string{"k3s", "check", "cis", "--token=12345", "--node-label=DEAFBEEF",
+				"--etcd-s3=true", "--etcd-s3-bucket=my-backup", "--kubelet-arg=max-pods=999", "--fix"},
+		},
 		{
 			name: "Agent with known flags",
 			args: []string{"k3s", "agent", "--token=12345"},
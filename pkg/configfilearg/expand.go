@@ -0,0 +1,120 @@
+package configfilearg
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"gopkg.in/yaml.v2"
+)
+
+// DisableEnvExpansionEnv is the environment variable that, if set to true, disables the expansion
+// of environment variable references in config file values.
+var DisableEnvExpansionEnv = version.ProgramUpper + "_CONFIG_DISABLE_ENV_EXPANSION"
+
+// expandEnvEnabled returns true unless environment variable expansion has been disabled.
+func expandEnvEnabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv(DisableEnvExpansionEnv))
+	return !disabled
+}
+
+// expandEnv expands environment variable references in the values of a config file, including
+// values in lists and nested maps. Keys are not expanded.
+func expandEnv(data yaml.MapSlice) error {
+	for i, item := range data {
+		value, err := expandEnvValue(item.Value)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to expand value of %v", item.Key)
+		}
+		data[i].Value = value
+	}
+	return nil
+}
+
+func expandEnvValue(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return expandEnvString(v, os.LookupEnv)
+	case []any:
+		for i := range v {
+			value, err := expandEnvValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = value
+		}
+		return v, nil
+	case yaml.MapSlice:
+		return v, expandEnv(v)
+	default:
+		return v, nil
+	}
+}
+
+// expandEnvString replaces references of the form ${VAR} with the value of the environment variable. The forms
+// ${VAR:-default} and ${VAR-default} use the default if the variable is unset or empty, or only if the variable is
+// unset, respectively. An error is returned if a variable without a default is not set, so that a missing value
+// is not silently replaced with an empty string. $${ is replaced with a literal ${, and any other $ is left as-is.
+func expandEnvString(s string, lookupEnv func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	b := &strings.Builder{}
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", errors.New("unterminated variable reference in " + strconv.Quote(s[i:]))
+		}
+		expr := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		name, defaultValue, hasDefault := expr, "", false
+		useDefaultIfEmpty := false
+		if j := strings.IndexByte(expr, '-'); j >= 0 {
+			name, defaultValue, hasDefault = expr[:j], expr[j+1:], true
+			if strings.HasSuffix(name, ":") {
+				name, useDefaultIfEmpty = name[:len(name)-1], true
+			}
+		}
+		if !isEnvName(name) {
+			return "", errors.New("invalid variable reference ${" + expr + "}")
+		}
+
+		value, ok := lookupEnv(name)
+		switch {
+		case ok && (value != "" || !useDefaultIfEmpty):
+			b.WriteString(value)
+		case hasDefault:
+			b.WriteString(defaultValue)
+		default:
+			return "", errors.New("environment variable " + name + " is not set")
+		}
+	}
+}
+
+// isEnvName returns true if s is a valid environment variable name: a letter or underscore,
+// followed by any number of letters, digits, or underscores.
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
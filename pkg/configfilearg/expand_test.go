@@ -0,0 +1,87 @@
+package configfilearg
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func Test_UnitExpandEnvString(t *testing.T) {
+	env := map[string]string{
+		"NODE_IP": "10.0.0.5",
+		"EMPTY":   "",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no references", value: "plain $value", want: "plain $value"},
+		{name: "set", value: "${NODE_IP}", want: "10.0.0.5"},
+		{name: "embedded", value: "ip=${NODE_IP}/32", want: "ip=10.0.0.5/32"},
+		{name: "multiple", value: "${NODE_IP},${NODE_IP}", want: "10.0.0.5,10.0.0.5"},
+		{name: "unset", value: "${MISSING}", wantErr: true},
+		{name: "empty", value: "${EMPTY}", want: ""},
+		{name: "unset with default", value: "${MISSING:-fallback}", want: "fallback"},
+		{name: "empty with default", value: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "set with default", value: "${NODE_IP:-fallback}", want: "10.0.0.5"},
+		{name: "unset with unset-only default", value: "${MISSING-fallback}", want: "fallback"},
+		{name: "empty with unset-only default", value: "${EMPTY-fallback}", want: ""},
+		{name: "default containing dashes", value: "${MISSING:-a-b:-c}", want: "a-b:-c"},
+		{name: "escaped", value: "$${NODE_IP}", want: "${NODE_IP}"},
+		{name: "unterminated", value: "${NODE_IP", wantErr: true},
+		{name: "invalid name", value: "${1NODE}", wantErr: true},
+		{name: "empty name", value: "${}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnvString(tt.value, lookupEnv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnvString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expandEnvString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitReadConfigExpandEnv(t *testing.T) {
+	t.Setenv("TEST_NODE_IP", "10.0.0.5")
+	t.Setenv("TEST_RACK", "r1")
+
+	values, err := ReadConfig("./testdata/env.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := yaml.MapSlice{
+		{Key: "node-ip", Value: "10.0.0.5"},
+		{Key: "node-label", Value: []any{"zone=default", "rack=r1"}},
+		{Key: "token", Value: "${NOT_EXPANDED}"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("ReadConfig() = %#v, want %#v", values, want)
+	}
+
+	t.Setenv(DisableEnvExpansionEnv, "true")
+	values, err = ReadConfig("./testdata/env.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Value != "${TEST_NODE_IP}" {
+		t.Errorf("ReadConfig() with expansion disabled = %#v", values)
+	}
+
+	t.Setenv(DisableEnvExpansionEnv, "")
+	os.Unsetenv("TEST_NODE_IP")
+	if _, err := ReadConfig("./testdata/env.yaml"); err == nil {
+		t.Errorf("ReadConfig() with unset variable did not return an error")
+	}
+}
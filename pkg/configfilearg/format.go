@@ -0,0 +1,154 @@
+package configfilearg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// configExtensions are the extensions of config file dropins that are loaded from the dropin directory.
+var configExtensions = []string{".yaml", ".yml", ".json", ".toml"}
+
+// unmarshalConfig parses the contents of a config file, in the format indicated by its extension.
+// Files with a .json or .toml extension are parsed as JSON or TOML; all other files are parsed as YAML.
+// The extension of a remote config file is taken from the path of its URL.
+func unmarshalConfig(file string, b []byte) (yaml.MapSlice, error) {
+	switch configExt(file) {
+	case ".json":
+		return unmarshalJSON(b)
+	case ".toml":
+		return unmarshalTOML(b)
+	}
+	data := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// isYAMLConfig returns true if the config file is parsed as YAML.
+func isYAMLConfig(file string) bool {
+	ext := configExt(file)
+	return ext != ".json" && ext != ".toml"
+}
+
+// configExt returns the lowercase extension of a config file, or of the path of a remote config file's URL.
+func configExt(file string) string {
+	if isRemoteConfig(file) {
+		if u, err := url.Parse(file); err == nil {
+			file = u.Path
+		}
+	}
+	return strings.ToLower(filepath.Ext(file))
+}
+
+// unmarshalJSON parses a JSON config file, which must contain a single object. Keys are returned
+// in the order that they appear in the file, and nested objects are returned as a yaml.MapSlice,
+// so that values are of the same types as those parsed from a YAML config file.
+func unmarshalJSON(b []byte) (yaml.MapSlice, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return yaml.MapSlice{}, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.New("config file must contain a JSON object")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON object in config file")
+	}
+	return data, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '{' {
+			data := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				data = append(data, yaml.MapItem{Key: key, Value: value})
+			}
+			// consume the closing delimiter
+			_, err := dec.Token()
+			return data, err
+		}
+		list := []any{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Number:
+		if i, err := token.Int64(); err == nil {
+			return int(i), nil
+		}
+		return token.Float64()
+	default:
+		return token, nil
+	}
+}
+
+// unmarshalTOML parses a TOML config file. TOML tables are unordered, so keys are returned in sorted
+// order. Tables are returned as a yaml.MapSlice, so that values are of the same types as those parsed
+// from a YAML config file.
+func unmarshalTOML(b []byte) (yaml.MapSlice, error) {
+	values := map[string]any{}
+	if err := toml.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	return tomlMapSlice(values), nil
+}
+
+func tomlMapSlice(values map[string]any) yaml.MapSlice {
+	data := make(yaml.MapSlice, 0, len(values))
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		data = append(data, yaml.MapItem{Key: k, Value: tomlValue(values[k])})
+	}
+	return data
+}
+
+func tomlValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return tomlMapSlice(v)
+	case []any:
+		for i := range v {
+			v[i] = tomlValue(v[i])
+		}
+		return v
+	case int64:
+		return int(v)
+	default:
+		return v
+	}
+}
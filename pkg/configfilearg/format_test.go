@@ -0,0 +1,71 @@
+package configfilearg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_UnitReadConfigFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "JSON",
+			file: "./testdata/data.json",
+			want: []string{
+				"--node-name=node-1",
+				"--node-label=region=us-east",
+				"--node-label=enabled=true",
+				"--node-label=version=1.0",
+				"--node-label=empty=",
+				"--debug=true",
+				"--etcd-snapshot-retention=5",
+				"--kubelet-arg=max-pods=250",
+			},
+		},
+		{
+			name: "TOML",
+			file: "./testdata/data.toml",
+			want: []string{
+				"--debug=true",
+				"--etcd-snapshot-retention=5",
+				"--kubelet-arg=max-pods=250",
+				"--node-label=region=us-east",
+				"--node-label=enabled=true",
+				"--node-label=version=1.0",
+				"--node-label=empty=",
+				"--node-name=node-1",
+			},
+		},
+		{
+			name: "YAML with JSON and TOML dropins",
+			file: "./testdata/formats.yaml",
+			want: []string{
+				"--node-name=node-1",
+				"--node-label=region=us-east",
+				"--node-label=enabled=true",
+				"--node-label=version=1.0",
+				"--debug=false",
+			},
+		},
+		{
+			name:    "Invalid JSON",
+			file:    "./testdata/invalid.json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readConfigFile(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readConfigFile() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
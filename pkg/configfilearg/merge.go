@@ -0,0 +1,89 @@
+package configfilearg
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/rancher/wrangler/pkg/data/convert"
+)
+
+// MergeStrategyEnv is the environment variable that sets how list values for keys without a merge suffix
+// are merged with the value set by the config file or an earlier dropin. Valid values are replace, the
+// default, and append.
+var MergeStrategyEnv = version.ProgramUpper + "_CONFIG_MERGE_STRATEGY"
+
+// mergeOp is the operation used to merge a value with the value set by the config file or an earlier dropin.
+type mergeOp int
+
+const (
+	// mergeDefault uses the strategy set by MergeStrategyEnv
+	mergeDefault mergeOp = iota
+	mergeReplace
+	mergeAppend
+	mergeRemove
+)
+
+// parseKey returns the flag name and merge operation for a config file key. A key with a + suffix appends
+// to the existing value, a - suffix removes from the existing value, and a ! suffix replaces the existing value.
+func parseKey(key string) (string, mergeOp) {
+	if k, ok := strings.CutSuffix(key, "+"); ok {
+		return k, mergeAppend
+	}
+	if k, ok := strings.CutSuffix(key, "-"); ok {
+		return k, mergeRemove
+	}
+	if k, ok := strings.CutSuffix(key, "!"); ok {
+		return k, mergeReplace
+	}
+	return key, mergeDefault
+}
+
+// mergeStrategy returns the merge operation used for keys without a merge suffix.
+func mergeStrategy() (mergeOp, error) {
+	switch strategy := os.Getenv(MergeStrategyEnv); strategy {
+	case "", "replace":
+		return mergeReplace, nil
+	case "append":
+		return mergeAppend, nil
+	default:
+		return mergeDefault, fmt.Errorf("invalid %s %q: must be replace or append", MergeStrategyEnv, strategy)
+	}
+}
+
+// mergeValue returns the result of merging a value with the existing value, if any. The append strategy
+// only applies to keys without a merge suffix if both values are lists, so that scalar values are replaced.
+func mergeValue(oldValue any, exists bool, value any, op, strategy mergeOp) any {
+	if op == mergeDefault {
+		op = mergeReplace
+		if _, ok := oldValue.([]any); ok && strategy == mergeAppend {
+			if _, ok := value.([]any); ok {
+				op = mergeAppend
+			}
+		}
+	}
+	if !exists {
+		return value
+	}
+	switch op {
+	case mergeAppend:
+		return slices.Concat(toSlice(oldValue), toSlice(value))
+	case mergeRemove:
+		remove := toStrings(toSlice(value))
+		return slices.DeleteFunc(slices.Clone(toSlice(oldValue)), func(v any) bool {
+			return slices.Contains(remove, convert.ToString(v))
+		})
+	default:
+		return value
+	}
+}
+
+func toStrings(values []any) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, convert.ToString(v))
+	}
+	return result
+}
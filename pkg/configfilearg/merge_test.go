@@ -0,0 +1,77 @@
+package configfilearg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_UnitReadConfigMergeStrategy(t *testing.T) {
+	tests := []struct {
+		name          string
+		strategy      string
+		want          []string
+		wantNodeLabel string
+		wantErr       bool
+	}{
+		{
+			name: "Default strategy",
+			want: []string{
+				"--node-name=node-2",
+				"--node-label=tier=edge",
+				"--disable=traefik",
+				"--kube-apiserver-arg=audit-log-maxage=7",
+			},
+			wantNodeLabel: "tier=edge",
+		},
+		{
+			name:     "Replace strategy",
+			strategy: "replace",
+			want: []string{
+				"--node-name=node-2",
+				"--node-label=tier=edge",
+				"--disable=traefik",
+				"--kube-apiserver-arg=audit-log-maxage=7",
+			},
+			wantNodeLabel: "tier=edge",
+		},
+		{
+			name:     "Append strategy",
+			strategy: "append",
+			want: []string{
+				"--node-name=node-2",
+				"--node-label=region=us-east",
+				"--node-label=zone=a",
+				"--node-label=tier=edge",
+				"--disable=traefik",
+				"--kube-apiserver-arg=audit-log-maxage=7",
+			},
+			wantNodeLabel: "region=us-east,zone=a,tier=edge",
+		},
+		{
+			name:     "Invalid strategy",
+			strategy: "merge",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(MergeStrategyEnv, tt.strategy)
+			got, err := readConfigFile("./testdata/merge.yaml")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readConfigFile() = %+v\nWant = %+v", got, tt.want)
+			}
+
+			p := &Parser{DefaultConfig: "./testdata/merge.yaml"}
+			nodeLabel, err := p.FindString(nil, "node-label")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parser.FindString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if nodeLabel != tt.wantNodeLabel {
+				t.Errorf("Parser.FindString() = %q, want %q", nodeLabel, tt.wantNodeLabel)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package configfilearg
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// MigrateFiles rewrites deprecated keys in the config file and its dropins to their replacement.
+// Files are only written if they contain deprecated keys; the migrated content of each changed
+// file is returned, keyed by path. If dryRun is true, the files are not modified.
+func MigrateFiles(file string, dryRun bool) (map[string][]byte, error) {
+	files, err := dotDFiles(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file); err == nil {
+		files = append([]string{file}, files...)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	changed := map[string][]byte{}
+	for _, file := range files {
+		// JSON and TOML files cannot be rewritten without losing their formatting; deprecated
+		// keys in these files are still migrated when the config is parsed.
+		if !isYAMLConfig(file) {
+			logrus.Warnf("Skipping migration of non-YAML config file %s", file)
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migrated, ok, err := migrateConfig(data)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to migrate %s", file)
+		}
+		if !ok {
+			continue
+		}
+		changed[file] = migrated
+		if dryRun {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := util.AtomicWrite(file, migrated, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
+
+// migrateConfig rewrites deprecated keys in a config file to their replacement, returning the
+// migrated content and true if any keys were changed. Comments and key order are preserved.
+func migrateConfig(data []byte) ([]byte, bool, error) {
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(data, doc); err != nil {
+		return nil, false, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return data, false, nil
+	}
+
+	root := doc.Content[0]
+	changed := false
+	content := make([]*yamlv3.Node, 0, len(root.Content))
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		name, op := parseKey(key.Value)
+		suffix := key.Value[len(name):]
+		f := cmds.FindDeprecatedFlag(name)
+		if f == nil {
+			content = append(content, key, value)
+			continue
+		}
+		changed = true
+		if !migrateValue(f, value) {
+			continue
+		}
+		if existing := findKey(root, f.Replacement); existing != nil && op != mergeRemove {
+			// the replacement is already set; merge list values into it, otherwise the existing value wins.
+			if existing.Kind == yamlv3.SequenceNode {
+				if value.Kind == yamlv3.SequenceNode {
+					existing.Content = append(existing.Content, value.Content...)
+				} else {
+					existing.Content = append(existing.Content, value)
+				}
+			}
+			continue
+		}
+		key.Value = f.Replacement + suffix
+		content = append(content, key, value)
+	}
+	if !changed {
+		return data, false, nil
+	}
+	root.Content = content
+
+	buf := &bytes.Buffer{}
+	encoder := yamlv3.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, false, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// migrateValue converts the value of a deprecated key in place. False is returned if the key should be dropped.
+func migrateValue(f *cmds.DeprecatedFlag, value *yamlv3.Node) bool {
+	switch value.Kind {
+	case yamlv3.ScalarNode:
+		v, ok := f.Migrate(value.Value)
+		if ok && v != value.Value {
+			value.Value = v
+			value.Tag = "!!str"
+			value.Style = 0
+		}
+		return ok
+	case yamlv3.SequenceNode:
+		content := make([]*yamlv3.Node, 0, len(value.Content))
+		for _, item := range value.Content {
+			if migrateValue(f, item) {
+				content = append(content, item)
+			}
+		}
+		value.Content = content
+		return len(content) > 0
+	default:
+		return f.Replacement != ""
+	}
+}
+
+// findKey returns the value of the given key, with or without an append or replace suffix, in a mapping node.
+func findKey(mapping *yamlv3.Node, name string) *yamlv3.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if k, op := parseKey(mapping.Content[i].Value); k == name && op != mergeRemove {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package configfilearg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+)
+
+func Test_UnitMigrateArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no deprecated flags",
+			args: []string{"--token=abc", "--disable", "traefik"},
+			want: []string{"--token=abc", "--disable", "traefik"},
+		},
+		{
+			name: "renamed flags",
+			args: []string{"--cluster-secret=abc", "--no-deploy", "traefik", "--kube-controller-arg=v=2"},
+			want: []string{"--token=abc", "--disable=traefik", "--kube-controller-manager-arg=v=2"},
+		},
+		{
+			name: "boolean flag with value conversion",
+			args: []string{"--no-flannel", "--node-name=foo"},
+			want: []string{"--flannel-backend=none", "--node-name=foo"},
+		},
+		{
+			name: "disabled boolean flag",
+			args: []string{"--no-flannel=false", "--node-name=foo"},
+			want: []string{"--node-name=foo"},
+		},
+		{
+			name: "removed flag",
+			args: []string{"--disable-selinux", "--node-name=foo"},
+			want: []string{"--node-name=foo"},
+		},
+		{
+			name: "args after terminator",
+			args: []string{"--no-deploy=traefik", "--", "--no-deploy=servicelb"},
+			want: []string{"--disable=traefik", "--", "--no-deploy=servicelb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cmds.MigrateArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MigrateArgs() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "no deprecated keys",
+			data:    "token: abc\n",
+			want:    "token: abc\n",
+			changed: false,
+		},
+		{
+			name:    "renamed keys with comments",
+			data:    "# cluster token\ncluster-secret: abc\nno-deploy:\n  - traefik # not needed\n  - servicelb\n",
+			want:    "# cluster token\ntoken: abc\ndisable:\n  - traefik # not needed\n  - servicelb\n",
+			changed: true,
+		},
+		{
+			name:    "append key",
+			data:    "kube-controller-arg+:\n  - v=2\n",
+			want:    "kube-controller-manager-arg+:\n  - v=2\n",
+			changed: true,
+		},
+		{
+			name:    "merge into existing list",
+			data:    "disable:\n  - traefik\nno-deploy: servicelb\n",
+			want:    "disable:\n  - traefik\n  - servicelb\n",
+			changed: true,
+		},
+		{
+			name:    "existing scalar value wins",
+			data:    "token: abc\ncluster-secret: def\n",
+			want:    "token: abc\n",
+			changed: true,
+		},
+		{
+			name:    "boolean key with value conversion",
+			data:    "no-flannel: true\ndisable-selinux: true\n",
+			want:    "flannel-backend: none\n",
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := migrateConfig([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("migrateConfig() error = %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("migrateConfig() changed = %v, want %v", changed, tt.changed)
+			}
+			if string(got) != tt.want {
+				t.Errorf("migrateConfig() = %q\nWant = %q", got, tt.want)
+			}
+		})
+	}
+}
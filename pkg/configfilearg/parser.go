@@ -2,12 +2,10 @@ package configfilearg
 
 import (
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -27,6 +25,9 @@ type Parser struct {
 	// ValidFlags are maps of flags that are valid for that particular conmmand. This enables us to ignore flags in
 	// the config file that do no apply to the current command.
 	ValidFlags map[string][]cli.Flag
+	// MigrateArgs, if set, rewrites deprecated flags in the config file and command line args
+	// to their replacement, before they are validated.
+	MigrateArgs func(args []string) []string
 }
 
 // Parse will parse an os.Args style slice looking for Parser.FlagNames after Parse.After.
@@ -42,6 +43,11 @@ func (p *Parser) Parse(args []string) ([]string, error) {
 		return args, nil
 	}
 
+	if p.MigrateArgs != nil {
+		suffix = p.MigrateArgs(suffix)
+		args = slices.Concat(prefix, suffix)
+	}
+
 	if configFile := p.findConfigFileFlag(args); configFile != "" {
 		values, err := readConfigFile(configFile)
 		if err != nil {
@@ -50,8 +56,18 @@ func (p *Parser) Parse(args []string) ([]string, error) {
 			}
 			return nil, err
 		}
+		if p.MigrateArgs != nil {
+			values = p.MigrateArgs(values)
+		}
 		if len(args) > 1 {
-			values, err = p.stripInvalidFlags(args[1], values)
+			command := args[1]
+			// subcommands may have their own set of valid flags, keyed by "command subcommand"
+			if len(args) > 2 {
+				if _, ok := p.ValidFlags[command+" "+args[2]]; ok {
+					command += " " + args[2]
+				}
+			}
+			values, err = p.stripInvalidFlags(command, values)
 			if err != nil {
 				return nil, err
 			}
@@ -109,10 +125,18 @@ func (p *Parser) FindString(args []string, target string) (string, error) {
 		return val, nil
 	}
 
+	strategy, err := mergeStrategy()
+	if err != nil {
+		return "", err
+	}
+
 	var files []string
-	var lastVal string
+	var lastValue any
+	var found bool
 
-	if configFile := p.findConfigFileFlag(args); configFile != "" {
+	if configFile := p.findConfigFileFlag(args); isRemoteConfig(configFile) {
+		files = append(files, configFile)
+	} else if configFile != "" {
 		if _, err := os.Stat(configFile); err == nil {
 			files = append(files, configFile)
 		}
@@ -130,24 +154,28 @@ func (p *Parser) FindString(args []string, target string) (string, error) {
 			return "", err
 		}
 
-		data := yaml.MapSlice{}
-		if err := yaml.Unmarshal(bytes, &data); err != nil {
+		data, err := unmarshalConfig(file, bytes)
+		if err != nil {
 			return "", err
 		}
+		if expandEnvEnabled() {
+			if err := expandEnv(data); err != nil {
+				return "", fmt.Errorf("failed to read config file %s: %w", file, err)
+			}
+		}
 		for _, i := range data {
-			k, v := convert.ToString(i.Key), convert.ToString(i.Value)
-			isAppend := strings.HasSuffix(k, "+")
-			k = strings.TrimSuffix(k, "+")
-			if k == target {
-				if isAppend {
-					lastVal = lastVal + "," + v
-				} else {
-					lastVal = v
-				}
+			k, op := parseKey(convert.ToString(i.Key))
+			if k != target || (op == mergeRemove && !found) {
+				continue
 			}
+			lastValue = mergeValue(lastValue, found, i.Value, op, strategy)
+			found = true
 		}
 	}
-	return lastVal, nil
+	if values, ok := lastValue.([]any); ok {
+		return strings.Join(toStrings(values), ","), nil
+	}
+	return convert.ToString(lastValue), nil
 }
 
 func (p *Parser) findOverrideFlag(args []string) (string, bool) {
@@ -232,7 +260,7 @@ func dotDFiles(basefile string) (result []string, _ error) {
 		return nil, err
 	}
 	for _, file := range files {
-		if file.IsDir() || !util.HasSuffixI(file.Name(), ".yaml", ".yml") {
+		if file.IsDir() || !util.HasSuffixI(file.Name(), configExtensions...) {
 			continue
 		}
 		result = append(result, filepath.Join(basefile+".d", file.Name()))
@@ -244,6 +272,43 @@ func dotDFiles(basefile string) (result []string, _ error) {
 // file, and any config file dropins in the dropin directory that corresponds to that
 // config file.  The config file or at least one dropin must exist.
 func readConfigFile(file string) (result []string, _ error) {
+	values, err := ReadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range values {
+		k, v := item.Key.(string), item.Value
+
+		prefix := "--"
+		if len(k) == 1 {
+			prefix = "-"
+		}
+
+		if slice, ok := v.([]any); ok {
+			for _, v := range slice {
+				result = append(result, prefix+k+"="+convert.ToString(v))
+			}
+		} else {
+			str := convert.ToString(v)
+			result = append(result, prefix+k+"="+str)
+		}
+	}
+
+	return result, nil
+}
+
+// ReadConfig returns the merged values from the specified config file and any config file
+// dropins, in the order that each key was first seen. Merge suffixes are removed from keys, with
+// the values appended to, removed from, or replacing any existing value; list values for keys
+// without a suffix replace or are appended to any existing value, as set by MergeStrategyEnv.
+// Environment variable references in values are expanded, unless disabled. The config file or
+// at least one dropin must exist. Remote config files, specified by URL, do not have dropins.
+func ReadConfig(file string) (yaml.MapSlice, error) {
+	if isRemoteConfig(file) {
+		return readConfigFiles([]string{file})
+	}
+
 	files, err := dotDFiles(file)
 	if err != nil {
 		return nil, err
@@ -259,59 +324,56 @@ func readConfigFile(file string) (result []string, _ error) {
 		// The config file exists, load it first.
 		files = append([]string{file}, files...)
 	}
+	return readConfigFiles(files)
+}
 
+// readConfigFiles returns the merged values from the specified config files.
+func readConfigFiles(files []string) (yaml.MapSlice, error) {
 	var (
 		keySeen  = map[string]bool{}
 		keyOrder []string
 		values   = map[string]any{}
 	)
+	strategy, err := mergeStrategy()
+	if err != nil {
+		return nil, err
+	}
 	for _, file := range files {
 		bytes, err := readConfigFileData(file)
 		if err != nil {
 			return nil, err
 		}
 
-		data := yaml.MapSlice{}
-		if err := yaml.Unmarshal(bytes, &data); err != nil {
+		data, err := unmarshalConfig(file, bytes)
+		if err != nil {
 			return nil, err
 		}
+		if expandEnvEnabled() {
+			if err := expandEnv(data); err != nil {
+				return nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+			}
+		}
 
 		for _, i := range data {
-			k, v := convert.ToString(i.Key), i.Value
-			isAppend := strings.HasSuffix(k, "+")
-			k = strings.TrimSuffix(k, "+")
+			k, op := parseKey(convert.ToString(i.Key))
+			oldValue, ok := values[k]
+			// removing values from a key that has not been set has no effect
+			if op == mergeRemove && !ok {
+				continue
+			}
 
 			if !keySeen[k] {
 				keySeen[k] = true
 				keyOrder = append(keyOrder, k)
 			}
-
-			if oldValue, ok := values[k]; ok && isAppend {
-				values[k] = append(toSlice(oldValue), toSlice(v)...)
-			} else {
-				values[k] = v
-			}
+			values[k] = mergeValue(oldValue, ok, i.Value, op, strategy)
 		}
 	}
 
+	result := make(yaml.MapSlice, 0, len(keyOrder))
 	for _, k := range keyOrder {
-		v := values[k]
-
-		prefix := "--"
-		if len(k) == 1 {
-			prefix = "-"
-		}
-
-		if slice, ok := v.([]any); ok {
-			for _, v := range slice {
-				result = append(result, prefix+k+"="+convert.ToString(v))
-			}
-		} else {
-			str := convert.ToString(v)
-			result = append(result, prefix+k+"="+str)
-		}
+		result = append(result, yaml.MapItem{Key: k, Value: values[k]})
 	}
-
 	return result, nil
 }
 
@@ -332,20 +394,8 @@ func toSlice(v any) []any {
 
 // readConfigFileData returns the contents of a local or remote file
 func readConfigFileData(file string) ([]byte, error) {
-	u, err := url.Parse(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse config location %s: %w", file, err)
-	}
-
-	switch u.Scheme {
-	case "http", "https":
-		resp, err := http.Get(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read http config %s: %w", file, err)
-		}
-		defer resp.Body.Close()
-		return io.ReadAll(resp.Body)
-	default:
-		return os.ReadFile(file)
+	if isRemoteConfig(file) {
+		return readRemoteConfig(file)
 	}
+	return os.ReadFile(file)
 }
@@ -0,0 +1,150 @@
+package configfilearg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/datadir"
+	k3sutil "github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	remoteConfigTimeout = 30 * time.Second
+	remoteConfigMaxSize = 4 * 1024 * 1024
+	checksumPrefix      = "sha256="
+)
+
+var (
+	// remoteConfigCacheDir is the directory that remote config files are cached in. If empty,
+	// the config-cache directory within the default data-dir is used.
+	remoteConfigCacheDir string
+
+	// remoteConfigs holds the contents of remote config files that have already been
+	// read by this process, so that they are not fetched each time the config is parsed.
+	remoteConfigs     = map[string][]byte{}
+	remoteConfigsLock sync.Mutex
+)
+
+// isRemoteConfig returns true if the config file location is a http or https URL.
+func isRemoteConfig(file string) bool {
+	return strings.HasPrefix(file, "https://") || strings.HasPrefix(file, "http://")
+}
+
+// readRemoteConfig returns the contents of a remote config file. The location may include a fragment of the
+// form #sha256=<hex>, in which case the file contents must match the checksum. Plain http locations must be
+// pinned to a checksum, as the contents are otherwise unauthenticated. The contents are cached locally each
+// time the file is fetched, and the cached copy is used if the file cannot be fetched, so that nodes can be
+// restarted while the remote location is unavailable.
+func readRemoteConfig(file string) ([]byte, error) {
+	remoteConfigsLock.Lock()
+	defer remoteConfigsLock.Unlock()
+	if data, ok := remoteConfigs[file]; ok {
+		return data, nil
+	}
+
+	u, err := url.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config location %s: %w", file, err)
+	}
+	var checksum []byte
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, checksumPrefix) {
+			return nil, errors.New("invalid checksum in config location " + file + ": must be in the format #" + checksumPrefix + "<hex>")
+		}
+		if checksum, err = hex.DecodeString(strings.TrimPrefix(u.Fragment, checksumPrefix)); err != nil || len(checksum) != sha256.Size {
+			return nil, errors.New("invalid checksum in config location " + file + ": must be a hex-encoded sha256 digest")
+		}
+		u.Fragment = ""
+	} else if u.Scheme == "http" {
+		return nil, errors.New("config location " + file + " must use https, or be pinned to a checksum with #" + checksumPrefix + "<hex>")
+	}
+
+	cacheFile, err := remoteConfigCacheFile(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetchRemoteConfig(u.String())
+	if err == nil {
+		if err := verifyChecksum(data, checksum); err != nil {
+			return nil, fmt.Errorf("failed to verify config file %s: %w", u.Redacted(), err)
+		}
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+			logrus.Warnf("Failed to create config cache directory: %v", err)
+		} else if err := k3sutil.AtomicWrite(cacheFile, data, 0600); err != nil {
+			logrus.Warnf("Failed to cache config file %s: %v", u.Redacted(), err)
+		}
+	} else {
+		cached, cacheErr := os.ReadFile(cacheFile)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", u.Redacted(), err)
+		}
+		if cacheErr := verifyChecksum(cached, checksum); cacheErr != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w, and cached copy is not valid: %w", u.Redacted(), err, cacheErr)
+		}
+		logrus.Warnf("Failed to read config file %s, using cached copy from %s: %v", u.Redacted(), cacheFile, err)
+		data = cached
+	}
+
+	remoteConfigs[file] = data
+	return data, nil
+}
+
+// fetchRemoteConfig retrieves the contents of a remote config file.
+func fetchRemoteConfig(location string) ([]byte, error) {
+	client := &http.Client{Timeout: remoteConfigTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteConfigMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > remoteConfigMaxSize {
+		return nil, fmt.Errorf("config file exceeds maximum size of %d bytes", remoteConfigMaxSize)
+	}
+	return data, nil
+}
+
+// verifyChecksum returns an error if a checksum is provided, and does not match the data.
+func verifyChecksum(data, checksum []byte) error {
+	if len(checksum) == 0 {
+		return nil
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], checksum) {
+		return fmt.Errorf("sha256 checksum %x does not match expected checksum %x", sum, checksum)
+	}
+	return nil
+}
+
+// remoteConfigCacheFile returns the path that a remote config file is cached at. The
+// file name is derived from the location, so that each location is cached separately.
+func remoteConfigCacheFile(location string) (string, error) {
+	dir := remoteConfigCacheDir
+	if dir == "" {
+		dataDir, err := datadir.Resolve("")
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(dataDir, "config-cache")
+	}
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".yaml"), nil
+}
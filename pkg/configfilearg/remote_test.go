@@ -0,0 +1,92 @@
+package configfilearg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func Test_UnitReadRemoteConfig(t *testing.T) {
+	content := []byte("node-label:\n- region=edge\ndebug: true\n")
+	sum := sha256.Sum256(content)
+	checksum := "#sha256=" + hex.EncodeToString(sum[:])
+
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if !available {
+			resp.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		resp.Write(content)
+	}))
+	defer server.Close()
+
+	remoteConfigCacheDir = t.TempDir()
+	defer func() { remoteConfigCacheDir = "" }()
+	reset := func() { remoteConfigs = map[string][]byte{} }
+
+	tests := []struct {
+		name      string
+		location  string
+		available bool
+		want      yaml.MapSlice
+		wantErr   bool
+	}{
+		{
+			name:     "unpinned http",
+			location: server.URL + "/config.yaml",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid checksum",
+			location: server.URL + "/config.yaml#sha256=1234",
+			wantErr:  true,
+		},
+		{
+			name:      "checksum mismatch",
+			location:  server.URL + "/config.yaml#sha256=" + hex.EncodeToString(make([]byte, sha256.Size)),
+			available: true,
+			wantErr:   true,
+		},
+		{
+			name:      "pinned",
+			location:  server.URL + "/config.yaml" + checksum,
+			available: true,
+			want: yaml.MapSlice{
+				{Key: "node-label", Value: []any{"region=edge"}},
+				{Key: "debug", Value: true},
+			},
+		},
+		{
+			name:     "cached",
+			location: server.URL + "/config.yaml" + checksum,
+			want: yaml.MapSlice{
+				{Key: "node-label", Value: []any{"region=edge"}},
+				{Key: "debug", Value: true},
+			},
+		},
+		{
+			name:     "not cached",
+			location: server.URL + "/other.yaml" + checksum,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset()
+			available = tt.available
+			got, err := ReadConfig(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
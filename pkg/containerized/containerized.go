@@ -0,0 +1,34 @@
+package containerized
+
+import (
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Resolve determines whether containerized mode should be enabled, and validates that the agent can run.
+// Containerized mode is enabled when requested, or automatically when running in an unprivileged container.
+// When containerized mode is requested, an error is returned if the agent is enabled but the container does
+// not have the privileges required to run it. When containerized mode is enabled automatically, a warning
+// is logged instead, so that existing configurations continue to start as before.
+func Resolve(requested, disableAgent bool) (bool, error) {
+	detected := Detect()
+	if !requested && !detected {
+		return false, nil
+	}
+
+	err := Privileged()
+	if !requested {
+		if err == nil {
+			return false, nil
+		}
+		logrus.Infof("Detected unprivileged container (%v); enabling containerized mode", err)
+	}
+	if err != nil && !disableAgent {
+		err = errors.WithMessage(err, "the agent cannot run in an unprivileged container; use --disable-agent to run only the control plane, or run the container with additional privileges")
+		if requested {
+			return false, err
+		}
+		logrus.Warn(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package containerized
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"golang.org/x/sys/unix"
+)
+
+// containerFiles are created by container runtimes in the root of the container filesystem.
+var containerFiles = []string{"/.dockerenv", "/run/.containerenv"}
+
+// Detect returns true if the process is running in a container.
+func Detect() bool {
+	// set by podman, systemd-nspawn, and lxc
+	if os.Getenv("container") != "" {
+		return true
+	}
+	for _, file := range containerFiles {
+		if _, err := os.Stat(file); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Privileged returns an error if the process does not have the privileges required to run the agent:
+// CAP_SYS_ADMIN to mount container filesystems, CAP_NET_ADMIN to configure networking, and a
+// writable cgroup filesystem to create pod cgroups.
+func Privileged() error {
+	caps, err := effectiveCapabilities()
+	if err != nil {
+		return err
+	}
+	if caps&(1<<unix.CAP_SYS_ADMIN) == 0 {
+		return errors.New("missing CAP_SYS_ADMIN capability")
+	}
+	if caps&(1<<unix.CAP_NET_ADMIN) == 0 {
+		return errors.New("missing CAP_NET_ADMIN capability")
+	}
+	if err := unix.Access("/sys/fs/cgroup", unix.W_OK); err != nil {
+		return errors.WithMessage(err, "cgroup filesystem is not writable")
+	}
+	return nil
+}
+
+// effectiveCapabilities returns the effective capability set of the process.
+func effectiveCapabilities() (uint64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseEffectiveCapabilities(f)
+}
+
+// parseEffectiveCapabilities parses the effective capability set from the contents of /proc/PID/status.
+func parseEffectiveCapabilities(r io.Reader) (uint64, error) {
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		if value, ok := strings.CutPrefix(scan.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	return 0, errors.New("effective capabilities not found in /proc/self/status")
+}
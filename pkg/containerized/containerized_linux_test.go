@@ -0,0 +1,42 @@
+package containerized
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_UnitParseEffectiveCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		want    uint64
+		wantErr bool
+	}{
+		{
+			name:   "privileged",
+			status: "Name:\tk3s\nCapInh:\t0000000000000000\nCapPrm:\t000001ffffffffff\nCapEff:\t000001ffffffffff\n",
+			want:   0x000001ffffffffff,
+		},
+		{
+			name:   "default docker capabilities",
+			status: "Name:\tk3s\nCapEff:\t00000000a80425fb\n",
+			want:   0x00000000a80425fb,
+		},
+		{
+			name:    "missing",
+			status:  "Name:\tk3s\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEffectiveCapabilities(strings.NewReader(tt.status))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEffectiveCapabilities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseEffectiveCapabilities() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
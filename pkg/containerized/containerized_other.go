@@ -0,0 +1,13 @@
+//go:build !linux
+
+package containerized
+
+// Detect returns false, as containerized mode is only supported on Linux.
+func Detect() bool {
+	return false
+}
+
+// Privileged returns nil, as containerized mode is only supported on Linux.
+func Privileged() error {
+	return nil
+}
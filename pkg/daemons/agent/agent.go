@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -53,11 +54,32 @@ func Agent(ctx context.Context, nodeConfig *daemonconfig.Node, proxy proxy.Proxy
 
 func startKubeProxy(ctx context.Context, cfg *daemonconfig.Agent) error {
 	argsMap := kubeProxyArgs(cfg)
-	args := util.GetArgs(argsMap, cfg.ExtraKubeProxyArgs)
+	extraArgs := cfg.ExtraKubeProxyArgs
+	if cfg.MixedOS {
+		extraArgs = supportedProxyModeArgs(extraArgs)
+	}
+	args := util.GetArgs(argsMap, extraArgs)
 	logrus.Infof("Running kube-proxy %s", daemonconfig.ArgString(args))
 	return executor.KubeProxy(ctx, args)
 }
 
+// supportedProxyModeArgs drops any proxy-mode arg that is not supported on this node's OS, so that the
+// same kube-proxy-arg values may be shared by Linux and Windows nodes in a mixed-OS cluster. Nodes with
+// an unsupported mode fall back to the default mode for their OS.
+func supportedProxyModeArgs(extraArgs []string) []string {
+	return slices.DeleteFunc(slices.Clone(extraArgs), func(arg string) bool {
+		if util.ArgName(arg) != "proxy-mode" {
+			return false
+		}
+		_, mode, _ := strings.Cut(arg, "=")
+		if slices.Contains(proxyModes, mode) {
+			return false
+		}
+		logrus.Warnf("Ignoring kube-proxy-arg %s: proxy mode %q is not supported on this node", arg, mode)
+		return true
+	})
+}
+
 func startKubelet(ctx context.Context, cfg *daemonconfig.Agent) error {
 	argsMap, defaultConfig, err := kubeletArgsAndConfig(cfg)
 	if err != nil {
@@ -209,10 +231,17 @@ func defaultKubeletConfig(cfg *daemonconfig.Agent) (*kubeletconfig.KubeletConfig
 		},
 	}
 
-	if cfg.ListenAddress != "" {
+	if cfg.KubeletBindAddress != "" {
+		defaultConfig.Address = cfg.KubeletBindAddress
+	} else if cfg.ListenAddress != "" {
 		defaultConfig.Address = cfg.ListenAddress
 	}
 
+	if cfg.KubeletHealthzAddress != "" {
+		defaultConfig.HealthzBindAddress = cfg.KubeletHealthzAddress
+	}
+	defaultConfig.ReadOnlyPort = int32(cfg.KubeletReadOnlyPort)
+
 	if cfg.ClientCA != "" {
 		defaultConfig.Authentication.X509.ClientCAFile = cfg.ClientCA
 	}
@@ -5,6 +5,7 @@ package agent
 import (
 	"errors"
 	"net"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/cgroups"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/moby/sys/userns"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 	kubeletconfig "k8s.io/kubelet/config/v1beta1"
@@ -21,6 +23,9 @@ import (
 
 const socketPrefix = "unix://"
 
+// proxyModes are the kube-proxy modes supported on Linux.
+var proxyModes = []string{"iptables", "ipvs", "nftables"}
+
 func createRootlessConfig(argsMap map[string]string, controllers map[string]bool) error {
 	argsMap["feature-gates=KubeletInUserNamespace"] = "true"
 	// "/sys/fs/cgroup" is namespaced
@@ -32,6 +37,19 @@ func createRootlessConfig(argsMap map[string]string, controllers map[string]bool
 	return errors.New("delegated cgroup v2 controllers are required for rootless")
 }
 
+// createContainerizedConfig adjusts the kubelet configuration for running in a container, where kernel
+// tunables are managed by the host, and the container may have been started in a user namespace.
+func createContainerizedConfig(argsMap map[string]string, cfg *config.Agent) error {
+	if cfg.ProtectKernelDefaults {
+		return errors.New("protect-kernel-defaults is not supported in containerized mode, as kernel tunables are managed by the host")
+	}
+	if userns.RunningInUserNS() {
+		logrus.Info("Running in a user namespace; enabling KubeletInUserNamespace feature gate")
+		argsMap["feature-gates=KubeletInUserNamespace"] = "true"
+	}
+	return nil
+}
+
 func kubeProxyArgs(cfg *config.Agent) map[string]string {
 	bindAddress := "127.0.0.1"
 	if utilsnet.IsIPv6(net.ParseIP(cfg.NodeIP)) {
@@ -146,10 +164,25 @@ func kubeletArgsAndConfig(cfg *config.Agent) (map[string]string, *kubeletconfig.
 		argsMap["image-credential-provider-config"] = cfg.ImageCredProvConfig
 	}
 
+	if cfg.ContainerCheckpoint {
+		// containerd checkpoints containers via runc, which requires criu on the host
+		if _, err := exec.LookPath("criu"); err != nil {
+			return nil, nil, errors.New("container-checkpoint requires criu to be installed and available in PATH")
+		}
+		if defaultConfig.FeatureGates == nil {
+			defaultConfig.FeatureGates = map[string]bool{}
+		}
+		defaultConfig.FeatureGates["ContainerCheckpoint"] = true
+	}
+
 	if cfg.Rootless {
 		if err := createRootlessConfig(argsMap, controllers); err != nil {
 			return nil, nil, err
 		}
+	} else if cfg.Containerized {
+		if err := createContainerizedConfig(argsMap, cfg); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if cfg.Systemd {
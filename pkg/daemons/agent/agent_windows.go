@@ -3,6 +3,7 @@
 package agent
 
 import (
+	"errors"
 	"net"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,9 @@ const (
 	socketPrefix = "npipe://"
 )
 
+// proxyModes are the kube-proxy modes supported on Windows.
+var proxyModes = []string{"kernelspace"}
+
 func kubeProxyArgs(cfg *config.Agent) map[string]string {
 	bindAddress := "127.0.0.1"
 	if utilsnet.IsIPv6(net.ParseIP(cfg.NodeIP)) {
@@ -97,5 +101,9 @@ func kubeletArgsAndConfig(cfg *config.Agent) (map[string]string, *kubeletconfig.
 		argsMap["image-credential-provider-config"] = cfg.ImageCredProvConfig
 	}
 
+	if cfg.ContainerCheckpoint {
+		return nil, nil, errors.New("container-checkpoint is not supported on Windows")
+	}
+
 	return argsMap, defaultConfig, nil
 }
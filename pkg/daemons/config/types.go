@@ -3,11 +3,14 @@ package config
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
 
+	"github.com/k3s-io/k3s/pkg/authlockout"
+	"github.com/k3s-io/k3s/pkg/faults"
 	"github.com/k3s-io/k3s/pkg/generated/controllers/k3s.cattle.io"
 	"github.com/k3s-io/kine/pkg/endpoint"
 	"github.com/rancher/wharfie/pkg/registries"
@@ -23,12 +26,24 @@ import (
 )
 
 const (
-	EgressSelectorModeAgent    = "agent"
-	EgressSelectorModeCluster  = "cluster"
-	EgressSelectorModeDisabled = "disabled"
-	EgressSelectorModePod      = "pod"
-	CertificateRenewDays       = 120
-	StreamServerPort           = "10010"
+	EgressSelectorModeAgent        = "agent"
+	EgressSelectorModeCluster      = "cluster"
+	EgressSelectorModeDisabled     = "disabled"
+	EgressSelectorModeKonnectivity = "konnectivity"
+	EgressSelectorModePod          = "pod"
+	LBHealthStrictnessAPIServer    = "apiserver"
+	LBHealthStrictnessDatastore    = "datastore"
+	LBHealthStrictnessAll          = "all"
+	DNSSearchPolicyAppend          = "append"
+	DNSSearchPolicyReplace         = "replace"
+	CertificateRenewDays           = 120
+	StreamServerPort               = "10010"
+	ControllerManagerSecurePort    = "10257"
+	SchedulerSecurePort            = "10259"
+
+	// DefaultListPageSize is the page size used when listing resources from the apiserver,
+	// if the list-page-size server flag is not set.
+	DefaultListPageSize int64 = 20
 )
 
 type Node struct {
@@ -46,6 +61,7 @@ type Node struct {
 	Images                   string
 	AgentConfig              Agent
 	Token                    string
+	TokenFile                string
 	ServerHTTPSPort          int
 	SupervisorPort           int
 	DefaultRuntime           string
@@ -59,6 +75,7 @@ type EtcdS3 struct {
 	Endpoint      string          `json:"endpoint,omitempty"`
 	EndpointCA    string          `json:"endpointCA,omitempty"`
 	Folder        string          `json:"folder,omitempty"`
+	KMSKeyID      string          `json:"kmsKeyID,omitempty"`
 	Proxy         string          `json:"proxy,omitempty"`
 	Region        string          `json:"region,omitempty"`
 	SecretKey     string          `json:"secretKey,omitempty"`
@@ -66,7 +83,86 @@ type EtcdS3 struct {
 	Insecure      bool            `json:"insecure,omitempty"`
 	SkipSSLVerify bool            `json:"skipSSLVerify,omitempty"`
 	Retention     int             `json:"retention,omitempty"`
+	PartSize      uint64          `json:"partSize,omitempty"`
+	Concurrency   uint            `json:"concurrency,omitempty"`
+	UploadRate    uint64          `json:"uploadRate,omitempty"`
 	Timeout       metav1.Duration `json:"timeout,omitempty"`
+	Target        string          `json:"target,omitempty"`
+}
+
+// SnapshotRetentionTier retains the newest snapshot in each Interval,
+// for snapshots that are not older than Keep.
+type SnapshotRetentionTier struct {
+	Interval metav1.Duration `json:"interval"`
+	Keep     metav1.Duration `json:"keep"`
+}
+
+// SnapshotSchedule is an additional snapshot schedule. Snapshots taken on the schedule
+// are named with Name as prefix, and Retention applies only to snapshots with that prefix.
+type SnapshotSchedule struct {
+	Name      string `json:"name"`
+	Cron      string `json:"cron"`
+	Retention int    `json:"retention"`
+}
+
+// SnapshotHooks are commands or webhook URLs that are run before and after etcd snapshots are saved, so that
+// applications can be quiesced while the snapshot is taken. If FailurePolicy is "fail", the snapshot is not
+// taken when a pre-snapshot hook fails. Env is added to the environment of hook commands.
+type SnapshotHooks struct {
+	Pre           []string
+	Post          []string
+	Timeout       time.Duration
+	FailurePolicy string
+	Env           []string
+}
+
+// Guardrails contains object count limits and event retention settings,
+// used to prevent runaway controllers from filling the datastore.
+type Guardrails struct {
+	Profile       string
+	MaxSecrets    int64
+	MaxConfigMaps int64
+	MaxEvents     int64
+	EventTTL      metav1.Duration
+}
+
+// Telemetry contains settings for anonymous feature usage reports. Datastore is the datastore type,
+// as the datastore endpoint is replaced with the kine or etcd client endpoint once the datastore is started.
+type Telemetry struct {
+	Endpoint  string
+	Interval  metav1.Duration
+	Datastore string
+}
+
+// DNSAutoscaler contains parameters for scaling CoreDNS with cluster size. Replica counts follow
+// the linear mode of the cluster-proportional-autoscaler; memory limits are optionally scaled by node count.
+type DNSAutoscaler struct {
+	CoresPerReplica           float64
+	NodesPerReplica           float64
+	MinReplicas               int32
+	MaxReplicas               int32
+	PreventSinglePointFailure bool
+	MemoryPerNode             int64
+}
+
+// GitBackup contains settings for committing an encrypted copy of the bootstrap data, CA certificate hashes,
+// and etcd snapshot metadata to a Git remote. Data is encrypted with SOPS, using age recipients.
+type GitBackup struct {
+	Remote        string
+	Branch        string
+	Path          string
+	AgeRecipients []string
+	SSHKey        string
+	Interval      metav1.Duration
+}
+
+// ServerRetry contains settings used to retry requests to the server URL while joining the cluster.
+// The interval is doubled after each failed attempt, up to MaxInterval; a Limit <= 0 retries indefinitely.
+type ServerRetry struct {
+	Limit       int
+	Interval    metav1.Duration
+	MaxInterval metav1.Duration
+	Jitter      float64
 }
 
 type Containerd struct {
@@ -84,6 +180,7 @@ type Containerd struct {
 	NonrootDevices bool
 	SELinux        bool
 	Debug          bool
+	Env            []string
 }
 
 type CRIDockerd struct {
@@ -133,6 +230,9 @@ type Agent struct {
 	RuntimeSocket           string
 	ImageServiceSocket      string
 	ListenAddress           string
+	KubeletBindAddress      string
+	KubeletHealthzAddress   string
+	KubeletReadOnlyPort     int
 	ClientCA                string
 	CNIBinDir               string
 	CNIConfDir              string
@@ -156,7 +256,19 @@ type Agent struct {
 	CipherSuites            []string
 	Rootless                bool
 	ProtectKernelDefaults   bool
+	Containerized           bool
+	ContainerCheckpoint     bool
+	WarmStandby             bool
+	WarmStandbyInterval     metav1.Duration
+	ImageMirrorList         string
+	ImageMirrorInterval     metav1.Duration
+	DiskPressureActions     []string
+	DiskPressureThreshold   int
+	DiskPressureInterval    metav1.Duration
+	DiskPressureLogMaxSize  int64
+	DiskPressureDryRun      bool
 	DisableServiceLB        bool
+	MixedOS                 bool
 	EnableIPv4              bool
 	EnableIPv6              bool
 	VLevel                  int
@@ -183,16 +295,27 @@ type CriticalControlArgs struct {
 	FlannelBackend        string       `cli:"flannel-backend"`
 	FlannelIPv6Masq       bool         `cli:"flannel-ipv6-masq"`
 	FlannelExternalIP     bool         `cli:"flannel-external-ip"`
+	MixedOS               bool         `cli:"mixed-os"`
 	EgressSelectorMode    string       `cli:"egress-selector-mode"`
 	ServiceIPRange        *net.IPNet   `cli:"service-cidr"`
 	ServiceIPRanges       []*net.IPNet `cli:"service-cidr"`
 	SupervisorMetrics     bool         `cli:"supervisor-metrics"`
+	PreferLocalImages     bool         `cli:"prefer-local-images"`
 }
 
 type Control struct {
 	CriticalControlArgs
 	AdvertisePort int
 	AdvertiseIP   string
+	// Components that must be healthy for the load-balancer readiness endpoint to report ready
+	LBHealthStrictness string
+	// Search domains, and the policy for combining them with the host's search domains, in the resolv.conf used by the kubelet on all nodes
+	AgentDNSSearch       []string
+	AgentDNSSearchPolicy string
+	// Hostname to IP overrides added to the coredns NodeHosts
+	HostOverrides []string `json:"-"`
+	// Enables the fault injection API on the supervisor
+	FaultInjection bool `json:"-"`
 	// The port which kubectl clients can access k8s
 	HTTPSPort int
 	// The port which custom k3s API runs on
@@ -200,17 +323,26 @@ type Control struct {
 	// The port which kube-apiserver runs on
 	APIServerPort            int
 	APIServerBindAddress     string
+	APIServerSANFile         string
+	EgressSelectorConfigFile string
+	KonnectivitySocket       string
 	AgentToken               string `json:"-"`
 	Token                    string `json:"-"`
 	ServiceNodePortRange     *utilnet.PortRange
 	KubeConfigOutput         string
 	KubeConfigMode           string
 	KubeConfigGroup          string
+	KubeConfigComponents     bool
+	PermissionsAuditInterval metav1.Duration `json:"-"`
+	PermissionsAuditAction   string          `json:"-"`
 	HelmJobImage             string
 	DataDir                  string
 	KineTLS                  bool
 	Datastore                endpoint.Config `json:"-"`
+	DatastoreRetryTimeout    metav1.Duration `json:"-"`
 	Disables                 map[string]bool
+	ComponentNodeSelectors   []string
+	ComponentTolerations     []string
 	DisableAgent             bool
 	DisableAPIServer         bool
 	DisableControllerManager bool
@@ -220,14 +352,28 @@ type Control struct {
 	DisableServiceLB         bool
 	Rootless                 bool
 	ServiceLBNamespace       string
+	CloudControllerConfig    string `json:"-"`
+	IPAMWebhookURL           string `json:"-"`
+	StickyPodCIDRs           bool   `json:"-"`
+	NodeJoinApproval         bool   `json:"-"`
+	RequireTPMKeyPinning     bool   `json:"-"`
+	RouteExportTarget        string `json:"-"`
+	ExternalIPAM             bool
 	ExtraAPIArgs             []string
 	ExtraControllerArgs      []string
 	ExtraCloudControllerArgs []string
 	ExtraEtcdArgs            []string
 	ExtraSchedulerArgs       []string
 	ExtraHelmArgs            []string
+	CloudIdentityRules       []string `json:"-"`
+	CloudIdentityAudience    string   `json:"-"`
+	TokenAuditLog            string   `json:"-"`
+	TokenAuditEvents         bool     `json:"-"`
+	ManagementAudit          bool     `json:"-"`
+	ManagementAuditEvents    bool     `json:"-"`
 	NoLeaderElect            bool
 	JoinURL                  string
+	JoinRetry                ServerRetry `json:"-"`
 	IPSECPSK                 string
 	DefaultLocalStoragePath  string
 	Skips                    map[string]bool
@@ -235,20 +381,51 @@ type Control struct {
 	ClusterInit              bool
 	ClusterReset             bool
 	ClusterResetRestorePath  string
+	ClusterResetRestoreForce bool
+	ResetBackupRetention     int `json:"-"`
 	MinTLSVersion            string
 	CipherSuites             []string
 	TLSMinVersion            uint16          `json:"-"`
 	TLSCipherSuites          []uint16        `json:"-"`
 	EtcdSnapshotName         string          `json:"-"`
 	EtcdDisableSnapshots     bool            `json:"-"`
+	EtcdDisableAlarmRecovery bool            `json:"-"`
+	EtcdDisableLeaderMove    bool            `json:"-"`
+	EtcdDefragCron           string          `json:"-"`
 	EtcdExposeMetrics        bool            `json:"-"`
+	EtcdWitness              bool            `json:"-"`
+	ControlPlaneStandby      bool            `json:"-"`
+	StandbyPromoteTimeout    metav1.Duration `json:"-"`
+	EtcdMaxLearners          int             `json:"-"`
+	EtcdLearnerMaxLag        uint64          `json:"-"`
+	EtcdLearnerTimeout       metav1.Duration `json:"-"`
+	EtcdDowngradeVersion     string          `json:"-"`
 	EtcdSnapshotDir          string          `json:"-"`
 	EtcdSnapshotCron         string          `json:"-"`
 	EtcdSnapshotReconcile    metav1.Duration `json:"-"`
 	EtcdSnapshotRetention    int             `json:"-"`
+	EtcdSnapshotMaxAge       metav1.Duration `json:"-"`
 	EtcdSnapshotCompress     bool            `json:"-"`
+	EtcdSnapshotCompression  string          `json:"-"`
+	EtcdSnapshotZstdLevel    int             `json:"-"`
+	EtcdSnapshotWebhookURL   string          `json:"-"`
+	EtcdSnapshotWebhookType  string          `json:"-"`
+	EtcdSnapshotHooks        *SnapshotHooks  `json:"-"`
 	EtcdListFormat           string          `json:"-"`
 	EtcdS3                   *EtcdS3         `json:"-"`
+	EtcdS3Targets            []string        `json:"-"`
+	EtcdRemoteTarget         string          `json:"-"`
+	EtcdRemoteRetention      int             `json:"-"`
+	APIServerWatchCacheSizes []string
+	WatchCacheReportInterval metav1.Duration
+	ListPageSize             int64
+	EtcdSnapshotTiers        []SnapshotRetentionTier `json:"-"`
+	EtcdSnapshotSchedules    []SnapshotSchedule      `json:"-"`
+	Guardrails               *Guardrails
+	Telemetry                *Telemetry `json:"-"`
+	GitBackup                *GitBackup `json:"-"`
+	DNSAutoscaler            *DNSAutoscaler
+	ImageAllowlist           map[string]string `json:"-"`
 	ServerNodeName           string
 	VLevel                   int
 	VModule                  string
@@ -261,6 +438,15 @@ type Control struct {
 	Cluster     Cluster         `json:"-"`
 }
 
+// GetListPageSize returns the page size used when listing resources from the apiserver,
+// or DefaultListPageSize if no page size was configured.
+func (c *Control) GetListPageSize() int64 {
+	if c == nil || c.ListPageSize < 1 {
+		return DefaultListPageSize
+	}
+	return c.ListPageSize
+}
+
 // BindAddressOrLoopback returns an IPv4 or IPv6 address suitable for embedding in
 // server URLs. If a bind address was configured, that is returned. If the
 // chooseHostInterface parameter is true, and a suitable default interface can be
@@ -317,6 +503,7 @@ type ControlRuntime struct {
 	ControlRuntimeBootstrap
 
 	StartupHooksWg                       *sync.WaitGroup
+	BootstrapAddonsReady                 chan struct{}
 	ClusterControllerStarts              map[string]leader.Callback
 	LeaderElectedClusterControllerStarts map[string]leader.Callback
 
@@ -348,7 +535,10 @@ type ControlRuntime struct {
 	Handler                   http.Handler
 	HTTPBootstrap             http.Handler
 	Tunnel                    http.Handler
+	Faults                    *faults.Injector
 	Authenticator             authenticator.Request
+	AuthLockout               *authlockout.Tracker
+	TokenAuditLog             io.Writer
 
 	EgressSelectorConfig  string
 	CloudControllerConfig string
@@ -11,10 +11,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -28,12 +31,15 @@ import (
 	"github.com/k3s-io/k3s/pkg/version"
 	certutil "github.com/rancher/dynamiclistener/cert"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
 	apiserverv1beta1 "k8s.io/apiserver/pkg/apis/apiserver/v1beta1"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/util/keyutil"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -87,13 +93,9 @@ func KubeConfig(dest, url, caCert, clientCert, clientKey string) error {
 	}
 
 	// cis-1.24 and newer require kubeconfigs to be 0600
-	output, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer output.Close()
-
-	return kubeconfigTemplate.Execute(output, &data)
+	return util.AtomicWriteFunc(dest, 0600, func(w io.Writer) error {
+		return kubeconfigTemplate.Execute(w, &data)
+	})
 }
 
 // CreateRuntimeCertFiles is responsible for filling out all the
@@ -288,13 +290,23 @@ func genEncryptedNetworkInfo(controlConfig *config.Control) error {
 	}
 
 	controlConfig.IPSECPSK = psk
-	return os.WriteFile(runtime.IPSECKey, []byte(psk+"\n"), 0600)
+	return util.AtomicWrite(runtime.IPSECKey, []byte(psk+"\n"), 0600)
 }
 
 func getServerPass(passwd *passwd.Passwd, config *config.Control) (string, error) {
 	var err error
 
 	serverPass := config.Token
+	if clientaccess.IsBootstrapToken(serverPass) {
+		// servers that join using a server-scoped bootstrap token use the server token from the bootstrap data,
+		// as the bootstrap token may expire and cannot be used to encrypt bootstrap data in the datastore.
+		pass, ok := passwd.Pass("server")
+		if !ok {
+			return "", errors.New("server token not found in cluster bootstrap data")
+		}
+		config.Token = pass
+		return pass, nil
+	}
 	if serverPass == "" {
 		serverPass, _ = passwd.Pass("server")
 	}
@@ -342,7 +354,7 @@ func genClientCerts(config *config.Control) error {
 	// If our CA certs are signed by a root or intermediate CA, ClientCA will contain a chain.
 	// The controller-manager's signer wants just a single cert, not a full chain; so create a file
 	// that is guaranteed to contain only a single certificate.
-	if err := certutil.WriteCert(runtime.SigningClientCA, certutil.EncodeCertPEM(certs[0])); err != nil {
+	if err := util.WriteCert(runtime.SigningClientCA, certutil.EncodeCertPEM(certs[0])); err != nil {
 		return err
 	}
 
@@ -424,26 +436,93 @@ func genClientCerts(config *config.Control) error {
 		}
 	}
 
-	return nil
+	return setComponentKubeConfigPermissions(config)
 }
 
-func genServerCerts(config *config.Control) error {
+// ComponentKubeConfigFiles returns the kubeconfigs generated for the supervisor and embedded components,
+// along with the client certificates and keys that they reference.
+func ComponentKubeConfigFiles(config *config.Control) []string {
 	runtime := config.Runtime
-	regen, err := createServerSigningCertKey(config)
+	return []string{
+		runtime.KubeConfigAdmin, runtime.ClientAdminCert, runtime.ClientAdminKey,
+		runtime.KubeConfigSupervisor, runtime.ClientSupervisorCert, runtime.ClientSupervisorKey,
+		runtime.KubeConfigController, runtime.ClientControllerCert, runtime.ClientControllerKey,
+		runtime.KubeConfigScheduler, runtime.ClientSchedulerCert, runtime.ClientSchedulerKey,
+		runtime.KubeConfigAPIServer, runtime.ClientKubeAPICert, runtime.ClientKubeAPIKey,
+		runtime.KubeConfigCloudController, runtime.ClientCloudControllerCert, runtime.ClientCloudControllerKey,
+	}
+}
+
+// ComponentKubeConfigDirs returns the directories containing the component kubeconfigs, client certificates, and keys.
+func ComponentKubeConfigDirs(config *config.Control) []string {
+	return []string{config.DataDir, filepath.Dir(config.Runtime.KubeConfigAdmin), filepath.Dir(config.Runtime.ClientAdminCert)}
+}
+
+// ComponentKubeConfigPermissions returns the mode and group that should be set on the component kubeconfigs,
+// client certificates, and keys. The group is empty if the group should not be changed.
+func ComponentKubeConfigPermissions(config *config.Control) (os.FileMode, string, error) {
+	if !config.KubeConfigComponents {
+		return 0600, "", nil
+	}
+	mode := os.FileMode(0600)
+	if config.KubeConfigMode != "" {
+		m, err := strconv.ParseUint(config.KubeConfigMode, 8, 32)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid write-kubeconfig-mode %q: %w", config.KubeConfigMode, err)
+		}
+		mode = os.FileMode(m)
+	}
+	return mode, config.KubeConfigGroup, nil
+}
+
+// setComponentKubeConfigPermissions sets the mode and group of the kubeconfigs generated for the supervisor
+// and embedded components, along with the client certificates and keys that they reference. If
+// --write-kubeconfig-components is not set, the files are restricted to 0600, as required by the CIS benchmark;
+// this also reverts any previously applied permissions if the flag is removed. If a group is set, the group is
+// also allowed to traverse the directories containing the files.
+func setComponentKubeConfigPermissions(config *config.Control) error {
+	mode, group, err := ComponentKubeConfigPermissions(config)
 	if err != nil {
 		return err
 	}
 
-	altNames := &certutil.AltNames{
-		DNSNames: []string{"kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc." + config.ClusterDomain},
+	for _, file := range ComponentKubeConfigFiles(config) {
+		if err := util.SetFileModeForPath(file, mode); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if group != "" {
+			if err := util.SetFileGroupForPath(file, group); err != nil {
+				return fmt.Errorf("failed to set %s to group %s: %w", file, group, err)
+			}
+		}
 	}
 
-	addSANs(altNames, config.SANs)
+	if group == "" {
+		return nil
+	}
 
-	if _, err := createClientCertKey(regen, "kube-apiserver", nil,
-		altNames, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		runtime.ServerCA, runtime.ServerCAKey,
-		runtime.ServingKubeAPICert, runtime.ServingKubeAPIKey); err != nil {
+	// The group must be able to traverse, but not list, the directories containing the files.
+	for _, dir := range ComponentKubeConfigDirs(config) {
+		if err := util.SetFileModeForPath(dir, 0710); err != nil {
+			return err
+		}
+		if err := util.SetFileGroupForPath(dir, group); err != nil {
+			return fmt.Errorf("failed to set %s to group %s: %w", dir, group, err)
+		}
+	}
+	return nil
+}
+
+func genServerCerts(config *config.Control) error {
+	runtime := config.Runtime
+	regen, err := createServerSigningCertKey(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := createAPIServerServingCertKey(regen, config); err != nil {
 		return err
 	}
 
@@ -451,7 +530,7 @@ func genServerCerts(config *config.Control) error {
 		return err
 	}
 
-	altNames = &certutil.AltNames{}
+	altNames := &certutil.AltNames{}
 	addSANs(altNames, []string{"localhost", "127.0.0.1", "::1"})
 
 	if _, err := createClientCertKey(regen, "kube-scheduler", nil,
@@ -471,6 +550,66 @@ func genServerCerts(config *config.Control) error {
 	return nil
 }
 
+// createAPIServerServingCertKey creates the kube-apiserver serving certificate, with the built-in apiserver
+// service names, the names from the apiserver-san-file, and the tls-san values as SANs. The certificate is
+// re-issued if regen is true, or if the SANs have changed.
+func createAPIServerServingCertKey(regen bool, config *config.Control) (bool, error) {
+	runtime := config.Runtime
+	sans, err := APIServerSANs(config)
+	if err != nil {
+		return false, err
+	}
+
+	altNames := &certutil.AltNames{}
+	addSANs(altNames, sans)
+	addSANs(altNames, config.SANs)
+
+	return createClientCertKey(regen, "kube-apiserver", nil,
+		altNames, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		runtime.ServerCA, runtime.ServerCAKey,
+		runtime.ServingKubeAPICert, runtime.ServingKubeAPIKey)
+}
+
+// UpdateAPIServerServingCert re-issues the kube-apiserver serving certificate if the SANs have changed
+// since it was last issued, as happens when the apiserver-san-file is modified. The kube-apiserver
+// reloads the certificate from disk, so it does not need to be restarted.
+// Returns true if the certificate was re-issued.
+func UpdateAPIServerServingCert(config *config.Control) (bool, error) {
+	return createAPIServerServingCertKey(false, config)
+}
+
+// APIServerSANs returns the names that the apiserver is expected to be reachable at: the built-in
+// kubernetes.default service names for the cluster domain, and any additional names or addresses listed
+// in the apiserver-san-file. The file lists one name per line; blank lines and lines starting with '#'
+// are ignored, and any occurrence of {cluster-domain} is replaced with the cluster domain.
+func APIServerSANs(config *config.Control) ([]string, error) {
+	sans := []string{"kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc." + config.ClusterDomain}
+	if config.APIServerSANFile == "" {
+		return sans, nil
+	}
+
+	b, err := os.ReadFile(config.APIServerSANFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apiserver-san-file: %w", err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		san := strings.ReplaceAll(line, "{cluster-domain}", config.ClusterDomain)
+		if net.ParseIP(san) == nil {
+			if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(san, "*.")); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid name %q in apiserver-san-file: %s", line, strings.Join(errs, ", "))
+			}
+		}
+		if !slices.Contains(sans, san) {
+			sans = append(sans, san)
+		}
+	}
+	return sans, nil
+}
+
 func genETCDCerts(config *config.Control) error {
 	runtime := config.Runtime
 	regen, err := createSigningCertKey("etcd-server", runtime.ETCDServerCA, runtime.ETCDServerCAKey)
@@ -564,7 +703,7 @@ func createServerSigningCertKey(config *config.Control) (bool, error) {
 		return regen, err
 	}
 
-	if err := certutil.WriteCert(runtime.SigningServerCA, certutil.EncodeCertPEM(certs[0])); err != nil {
+	if err := util.WriteCert(runtime.SigningServerCA, certutil.EncodeCertPEM(certs[0])); err != nil {
 		return regen, err
 	}
 
@@ -666,7 +805,7 @@ func createClientCertKey(regen bool, commonName string, organization []string, a
 		return false, err
 	}
 
-	return true, certutil.WriteCert(certFile, util.EncodeCertsPEM(cert, caCerts))
+	return true, util.WriteCert(certFile, util.EncodeCertsPEM(cert, caCerts))
 }
 
 func cleanupLegacyCerts(config *config.Control) error {
@@ -701,7 +840,7 @@ func genServiceAccount(runtime *config.ControlRuntime) error {
 		if err != nil {
 			return err
 		}
-		if err := certutil.WriteKey(runtime.ServiceKey, certutil.EncodePrivateKeyPEM(key)); err != nil {
+		if err := util.WriteKey(runtime.ServiceKey, certutil.EncodePrivateKeyPEM(key)); err != nil {
 			return err
 		}
 	}
@@ -721,7 +860,7 @@ func genServiceAccount(runtime *config.ControlRuntime) error {
 		return err
 	}
 
-	return certutil.WriteKey(runtime.ServiceCurrentKey, keyData)
+	return util.WriteKey(runtime.ServiceCurrentKey, keyData)
 }
 
 func createSigningCertKey(prefix, certFile, keyFile string) (bool, error) {
@@ -748,7 +887,7 @@ func createSigningCertKey(prefix, certFile, keyFile string) (bool, error) {
 		return false, err
 	}
 
-	if err := certutil.WriteCert(certFile, certutil.EncodeCertPEM(cert)); err != nil {
+	if err := util.WriteCert(certFile, certutil.EncodeCertPEM(cert)); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -785,7 +924,7 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 			}
 			encryptionConfigHash := sha256.Sum256(curEncryptionByte)
 			ann := "start-" + hex.EncodeToString(encryptionConfigHash[:])
-			return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
+			return util.AtomicWrite(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
 		}
 		return nil
 	}
@@ -847,17 +986,36 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 	}
 	encryptionConfigHash := sha256.Sum256(b)
 	ann := "start-" + hex.EncodeToString(encryptionConfigHash[:])
-	return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
+	return util.AtomicWrite(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
 }
 
 func genEgressSelectorConfig(controlConfig *config.Control) error {
 	var clusterConn apiserverv1beta1.Connection
 
-	if controlConfig.EgressSelectorMode == config.EgressSelectorModeDisabled {
+	switch controlConfig.EgressSelectorMode {
+	case config.EgressSelectorModeDisabled:
 		clusterConn = apiserverv1beta1.Connection{
 			ProxyProtocol: apiserverv1beta1.ProtocolDirect,
 		}
-	} else {
+	case config.EgressSelectorModeKonnectivity:
+		// konnectivity-server is not run by the supervisor; an externally managed konnectivity-server is
+		// expected to listen on this socket, with its agents connecting to it from each node.
+		if controlConfig.KonnectivitySocket == "" {
+			controlConfig.KonnectivitySocket = filepath.Join(controlConfig.DataDir, "konnectivity-server", "konnectivity-server.socket")
+			if err := os.MkdirAll(filepath.Dir(controlConfig.KonnectivitySocket), 0700); err != nil {
+				return err
+			}
+		}
+		logrus.Infof("Proxying apiserver egress through konnectivity-server socket %s", controlConfig.KonnectivitySocket)
+		clusterConn = apiserverv1beta1.Connection{
+			ProxyProtocol: apiserverv1beta1.ProtocolGRPC,
+			Transport: &apiserverv1beta1.Transport{
+				UDS: &apiserverv1beta1.UDSTransport{
+					UDSName: controlConfig.KonnectivitySocket,
+				},
+			},
+		}
+	default:
 		clusterConn = apiserverv1beta1.Connection{
 			ProxyProtocol: apiserverv1beta1.ProtocolHTTPConnect,
 			Transport: &apiserverv1beta1.Transport{
@@ -886,28 +1044,166 @@ func genEgressSelectorConfig(controlConfig *config.Control) error {
 		},
 	}
 
+	if controlConfig.EgressSelectorConfigFile != "" {
+		if err := mergeEgressSelectorConfig(controlConfig, &egressConfig); err != nil {
+			return fmt.Errorf("invalid egress-selector-config-file %s: %w", controlConfig.EgressSelectorConfigFile, err)
+		}
+	}
+
 	b, err := json.Marshal(egressConfig)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(controlConfig.Runtime.EgressSelectorConfig, b, 0600)
+	return util.AtomicWrite(controlConfig.Runtime.EgressSelectorConfig, b, 0600)
+}
+
+// mergeEgressSelectorConfig merges the egress selections from the user-provided EgressSelectorConfiguration
+// into the generated configuration. User-provided selections replace generated selections of the same name.
+// The cluster selection may only be replaced when the egress-selector-mode is disabled or konnectivity, as the other
+// modes rely on the apiserver's connections to nodes and pods being proxied through the supervisor's agent tunnel.
+func mergeEgressSelectorConfig(controlConfig *config.Control, egressConfig *apiserverv1beta1.EgressSelectorConfiguration) error {
+	b, err := os.ReadFile(controlConfig.EgressSelectorConfigFile)
+	if err != nil {
+		return err
+	}
+
+	userConfig := apiserverv1beta1.EgressSelectorConfiguration{}
+	if err := yaml.UnmarshalStrict(b, &userConfig); err != nil {
+		return err
+	}
+	if userConfig.Kind != "" && userConfig.Kind != egressConfig.Kind {
+		return fmt.Errorf("unsupported kind %q", userConfig.Kind)
+	}
+	if userConfig.APIVersion != "" && userConfig.APIVersion != egressConfig.APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q", userConfig.APIVersion)
+	}
+
+	seen := sets.NewString()
+	for _, selection := range userConfig.EgressSelections {
+		if seen.Has(selection.Name) {
+			return fmt.Errorf("duplicate egress selection %q", selection.Name)
+		}
+		seen.Insert(selection.Name)
+		if err := validateEgressSelection(selection); err != nil {
+			return fmt.Errorf("egress selection %q: %w", selection.Name, err)
+		}
+		if selection.Name == "cluster" && !usesExternalClusterEgress(controlConfig) {
+			return fmt.Errorf("egress selection %q cannot be overridden unless egress-selector-mode is %s or %s", selection.Name, config.EgressSelectorModeDisabled, config.EgressSelectorModeKonnectivity)
+		}
+
+		i := slices.IndexFunc(egressConfig.EgressSelections, func(s apiserverv1beta1.EgressSelection) bool {
+			return s.Name == selection.Name
+		})
+		if i >= 0 {
+			egressConfig.EgressSelections[i] = selection
+		} else {
+			egressConfig.EgressSelections = append(egressConfig.EgressSelections, selection)
+		}
+		logrus.Infof("Using egress selection %q from %s", selection.Name, controlConfig.EgressSelectorConfigFile)
+	}
+	return nil
+}
+
+// usesExternalClusterEgress returns true if the apiserver's cluster egress does not go through the supervisor's agent tunnel.
+func usesExternalClusterEgress(controlConfig *config.Control) bool {
+	return controlConfig.EgressSelectorMode == config.EgressSelectorModeDisabled || controlConfig.EgressSelectorMode == config.EgressSelectorModeKonnectivity
+}
+
+// validateEgressSelection checks that the selection name and connection settings are supported by the apiserver,
+// so that errors are reported at startup instead of by the apiserver when it fails to start.
+func validateEgressSelection(selection apiserverv1beta1.EgressSelection) error {
+	switch selection.Name {
+	case "cluster", "controlplane", "etcd":
+	default:
+		return errors.New("name must be one of cluster, controlplane, or etcd")
+	}
+
+	conn := selection.Connection
+	switch conn.ProxyProtocol {
+	case apiserverv1beta1.ProtocolDirect:
+		if conn.Transport != nil {
+			return fmt.Errorf("transport must not be set for proxyProtocol %s", conn.ProxyProtocol)
+		}
+		return nil
+	case apiserverv1beta1.ProtocolHTTPConnect, apiserverv1beta1.ProtocolGRPC:
+	default:
+		return fmt.Errorf("unsupported proxyProtocol %q", conn.ProxyProtocol)
+	}
+
+	transport := conn.Transport
+	if transport == nil {
+		return fmt.Errorf("transport must be set for proxyProtocol %s", conn.ProxyProtocol)
+	}
+	if (transport.TCP == nil) == (transport.UDS == nil) {
+		return errors.New("exactly one of transport tcp or uds must be set")
+	}
+	if transport.UDS != nil {
+		if transport.UDS.UDSName == "" {
+			return errors.New("transport uds udsName must be set")
+		}
+		return nil
+	}
+	if conn.ProxyProtocol == apiserverv1beta1.ProtocolGRPC {
+		return fmt.Errorf("transport tcp is not supported for proxyProtocol %s", conn.ProxyProtocol)
+	}
+	if !strings.HasPrefix(transport.TCP.URL, "https://") && !strings.HasPrefix(transport.TCP.URL, "http://") {
+		return fmt.Errorf("transport tcp url %q must use http or https", transport.TCP.URL)
+	}
+	if tls := transport.TCP.TLSConfig; tls != nil {
+		if strings.HasPrefix(transport.TCP.URL, "http://") {
+			return errors.New("transport tcp tlsConfig must not be set for an http url")
+		}
+		if (tls.ClientKey == "") != (tls.ClientCert == "") {
+			return errors.New("transport tcp tlsConfig clientKey and clientCert must be set together")
+		}
+		for _, file := range []string{tls.CABundle, tls.ClientKey, tls.ClientCert} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				return fmt.Errorf("transport tcp tlsConfig: %w", err)
+			}
+		}
+	}
+	return nil
 }
 
 func genCloudConfig(controlConfig *config.Control) error {
 	cloudConfig := cloudprovider.Config{
 		LBDefaultPriorityClassName: cloudprovider.DefaultLBPriorityClassName,
 		LBEnabled:                  !controlConfig.DisableServiceLB,
+		LBHealthCheck:              !controlConfig.DisableKubeProxy,
 		LBNamespace:                controlConfig.ServiceLBNamespace,
 		LBImage:                    cloudprovider.DefaultLBImage,
 		Rootless:                   controlConfig.Rootless,
 		NodeEnabled:                !controlConfig.DisableCCM,
 	}
+	if controlConfig.MixedOS {
+		// ServiceLB pods use a Linux image, and must not be scheduled to Windows nodes.
+		cloudConfig.LBNodeSelector = map[string]string{corev1.LabelOSStable: "linux"}
+	}
 	if controlConfig.SystemDefaultRegistry != "" {
 		cloudConfig.LBImage = controlConfig.SystemDefaultRegistry + "/" + cloudConfig.LBImage
 	}
+	if controlConfig.CloudControllerConfig != "" {
+		// Values from the administrator-provided config file override the generated values, with the
+		// exception of rootless mode, which reflects how the server is actually running.
+		b, err := os.ReadFile(controlConfig.CloudControllerConfig)
+		if err != nil {
+			return fmt.Errorf("failed to read cloud controller config: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(b, &cloudConfig); err != nil {
+			return fmt.Errorf("failed to parse cloud controller config %s: %w", controlConfig.CloudControllerConfig, err)
+		}
+		cloudConfig.Rootless = controlConfig.Rootless
+	}
+	if err := cloudConfig.Validate(); err != nil {
+		return err
+	}
+	cloudConfig.APIVersion = cloudprovider.ConfigAPIVersion
 	b, err := json.Marshal(cloudConfig)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(controlConfig.Runtime.CloudControllerConfig, b, 0600)
+	return util.AtomicWrite(controlConfig.Runtime.CloudControllerConfig, b, 0600)
 }
@@ -2,10 +2,16 @@ package deps
 
 import (
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"testing"
 
+	"github.com/k3s-io/k3s/pkg/daemons/config"
 	certutil "github.com/rancher/dynamiclistener/cert"
+	apiserverv1beta1 "k8s.io/apiserver/pkg/apis/apiserver/v1beta1"
+	"sigs.k8s.io/yaml"
 )
 
 func Test_UnitAddSANs(t *testing.T) {
@@ -49,3 +55,310 @@ func Test_UnitAddSANs(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitSetComponentKubeConfigPermissions(t *testing.T) {
+	control := &config.Control{
+		DataDir: t.TempDir(),
+		Runtime: &config.ControlRuntime{},
+	}
+	CreateRuntimeCertFiles(control)
+	for _, dir := range []string{"cred", "tls"} {
+		if err := os.MkdirAll(filepath.Join(control.DataDir, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []string{control.Runtime.KubeConfigScheduler, control.Runtime.ClientSchedulerCert, control.Runtime.ClientSchedulerKey}
+	for _, file := range []string{
+		control.Runtime.KubeConfigAdmin, control.Runtime.ClientAdminCert, control.Runtime.ClientAdminKey,
+		control.Runtime.KubeConfigSupervisor, control.Runtime.ClientSupervisorCert, control.Runtime.ClientSupervisorKey,
+		control.Runtime.KubeConfigController, control.Runtime.ClientControllerCert, control.Runtime.ClientControllerKey,
+		control.Runtime.KubeConfigScheduler, control.Runtime.ClientSchedulerCert, control.Runtime.ClientSchedulerKey,
+		control.Runtime.KubeConfigAPIServer, control.Runtime.ClientKubeAPICert, control.Runtime.ClientKubeAPIKey,
+		control.Runtime.KubeConfigCloudController, control.Runtime.ClientCloudControllerCert, control.Runtime.ClientCloudControllerKey,
+	} {
+		if err := os.WriteFile(file, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		components bool
+		mode       string
+		wantMode   os.FileMode
+		wantErr    bool
+	}{
+		{name: "mode ignored without components", mode: "644", wantMode: 0600},
+		{name: "mode and group with components", components: true, mode: "640", wantMode: 0640},
+		{name: "reverted without components", mode: "640", wantMode: 0600},
+		{name: "invalid mode", components: true, mode: "rw-r-----", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control.KubeConfigComponents = tt.components
+			control.KubeConfigMode = tt.mode
+			control.KubeConfigGroup = strconv.Itoa(os.Getgid())
+			err := setComponentKubeConfigPermissions(control)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setComponentKubeConfigPermissions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, file := range files {
+				fi, err := os.Stat(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != tt.wantMode {
+					t.Errorf("%s mode = %s, want %s", file, fi.Mode().Perm(), tt.wantMode)
+				}
+			}
+		})
+	}
+}
+
+func Test_UnitAPIServerSANs(t *testing.T) {
+	builtin := []string{"kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc.cluster.local"}
+	tests := []struct {
+		name     string
+		contents string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "no file",
+			want: builtin,
+		},
+		{
+			name:     "names and addresses",
+			contents: "# additional names\napi.internal.company\n\nkubernetes.default.svc.{cluster-domain}.custom\n  10.0.0.1  \n*.api.internal.company\nkubernetes\n",
+			want:     append(builtin, "api.internal.company", "kubernetes.default.svc.cluster.local.custom", "10.0.0.1", "*.api.internal.company"),
+		},
+		{
+			name:     "invalid name",
+			contents: "api_internal.company\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlConfig := &config.Control{ClusterDomain: "cluster.local"}
+			if tt.contents != "" {
+				controlConfig.APIServerSANFile = filepath.Join(t.TempDir(), "sans")
+				if err := os.WriteFile(controlConfig.APIServerSANFile, []byte(tt.contents), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := APIServerSANs(controlConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("APIServerSANs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("APIServerSANs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitGenEgressSelectorConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		contents  string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "no file",
+			mode:      config.EgressSelectorModeAgent,
+			wantNames: []string{"cluster"},
+		},
+		{
+			name: "additional selection over uds",
+			mode: config.EgressSelectorModeAgent,
+			contents: `apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: controlplane
+  connection:
+    proxyProtocol: GRPC
+    transport:
+      uds:
+        udsName: /run/konnectivity-server/konnectivity-server.socket
+`,
+			wantNames: []string{"cluster", "controlplane"},
+		},
+		{
+			name: "cluster selection with egress-selector-mode disabled",
+			mode: config.EgressSelectorModeDisabled,
+			contents: `egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: HTTPConnect
+    transport:
+      tcp:
+        url: http://proxy.internal:8131
+`,
+			wantNames: []string{"cluster"},
+		},
+		{
+			name:      "egress-selector-mode konnectivity",
+			mode:      config.EgressSelectorModeKonnectivity,
+			wantNames: []string{"cluster"},
+		},
+		{
+			name: "cluster selection with egress-selector-mode konnectivity",
+			mode: config.EgressSelectorModeKonnectivity,
+			contents: `egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: HTTPConnect
+    transport:
+      tcp:
+        url: http://konnectivity-server.internal:8131
+`,
+			wantNames: []string{"cluster"},
+		},
+		{
+			name: "cluster selection with egress-selector-mode agent",
+			mode: config.EgressSelectorModeAgent,
+			contents: `egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: Direct
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown selection",
+			mode: config.EgressSelectorModeAgent,
+			contents: `egressSelections:
+- name: master
+  connection:
+    proxyProtocol: Direct
+`,
+			wantErr: true,
+		},
+		{
+			name: "grpc over tcp",
+			mode: config.EgressSelectorModeAgent,
+			contents: `egressSelections:
+- name: etcd
+  connection:
+    proxyProtocol: GRPC
+    transport:
+      tcp:
+        url: https://proxy.internal:8131
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			mode: config.EgressSelectorModeAgent,
+			contents: `egressSelections:
+- name: etcd
+  connection:
+    proxyProtocol: Direct
+    timeout: 10s
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			controlConfig := &config.Control{
+				Runtime: &config.ControlRuntime{EgressSelectorConfig: filepath.Join(dataDir, "egress-selector-config.yaml")},
+			}
+			controlConfig.EgressSelectorMode = tt.mode
+			controlConfig.KonnectivitySocket = "/etc/kubernetes/konnectivity-server/konnectivity-server.socket"
+			if tt.contents != "" {
+				controlConfig.EgressSelectorConfigFile = filepath.Join(dataDir, "egress-selector-overrides.yaml")
+				if err := os.WriteFile(controlConfig.EgressSelectorConfigFile, []byte(tt.contents), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := genEgressSelectorConfig(controlConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("genEgressSelectorConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			b, err := os.ReadFile(controlConfig.Runtime.EgressSelectorConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			egressConfig := apiserverv1beta1.EgressSelectorConfiguration{}
+			if err := yaml.Unmarshal(b, &egressConfig); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, selection := range egressConfig.EgressSelections {
+				names = append(names, selection.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("genEgressSelectorConfig() selections = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_UnitGenEgressSelectorConfigKonnectivitySocket(t *testing.T) {
+	tests := []struct {
+		name   string
+		socket string
+		want   func(dataDir string) string
+	}{
+		{
+			name: "default socket under data-dir",
+			want: func(dataDir string) string {
+				return filepath.Join(dataDir, "konnectivity-server", "konnectivity-server.socket")
+			},
+		},
+		{
+			name:   "user-provided socket",
+			socket: "/run/konnectivity-server/konnectivity-server.socket",
+			want: func(string) string {
+				return "/run/konnectivity-server/konnectivity-server.socket"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			controlConfig := &config.Control{
+				DataDir:            dataDir,
+				KonnectivitySocket: tt.socket,
+				Runtime:            &config.ControlRuntime{EgressSelectorConfig: filepath.Join(dataDir, "egress-selector-config.yaml")},
+			}
+			controlConfig.EgressSelectorMode = config.EgressSelectorModeKonnectivity
+			if err := genEgressSelectorConfig(controlConfig); err != nil {
+				t.Fatalf("genEgressSelectorConfig() error = %v", err)
+			}
+			b, err := os.ReadFile(controlConfig.Runtime.EgressSelectorConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			egressConfig := apiserverv1beta1.EgressSelectorConfiguration{}
+			if err := yaml.Unmarshal(b, &egressConfig); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want(dataDir)
+			for _, selection := range egressConfig.EgressSelections {
+				if selection.Name != "cluster" {
+					continue
+				}
+				if selection.Connection.Transport == nil || selection.Connection.Transport.UDS == nil || selection.Connection.Transport.UDS.UDSName != want {
+					t.Errorf("cluster selection transport = %+v, want uds %s", selection.Connection.Transport, want)
+				}
+				if _, err := os.Stat(filepath.Dir(want)); tt.socket == "" && err != nil {
+					t.Errorf("socket directory was not created: %v", err)
+				}
+				return
+			}
+			t.Errorf("cluster selection not found in %s", b)
+		})
+	}
+}
@@ -9,14 +9,20 @@ import (
 	"sync"
 
 	"github.com/k3s-io/k3s/pkg/authenticator"
+	"github.com/k3s-io/k3s/pkg/authenticator/basicauth"
+	"github.com/k3s-io/k3s/pkg/authenticator/cloudidentity"
+	"github.com/k3s-io/k3s/pkg/certbackup"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/cluster"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/faults"
 	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/natefinch/lumberjack"
 	"github.com/sirupsen/logrus"
 	authorizationv1 "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
@@ -41,6 +47,11 @@ func Prepare(ctx context.Context, wg *sync.WaitGroup, cfg *config.Control) error
 		return errors.WithMessage(err, "preparing server")
 	}
 
+	if cfg.FaultInjection {
+		logrus.Warn("Fault injection is enabled; this server should not be used in production")
+		cfg.Runtime.Faults = faults.NewInjector()
+	}
+
 	tunnel, err := setupTunnel(ctx, cfg)
 	if err != nil {
 		return errors.WithMessage(err, "setup tunnel server")
@@ -57,8 +68,34 @@ func Prepare(ctx context.Context, wg *sync.WaitGroup, cfg *config.Control) error
 	if err != nil {
 		return err
 	}
+	if len(cfg.CloudIdentityRules) > 0 {
+		// Cloud identity credentials are bound to the cluster by the hash of the server CA,
+		// which agents take from the CA hash in their token.
+		clusterID, err := clientaccess.HashCAFile(cfg.Runtime.ServerCA)
+		if err != nil {
+			return errors.WithMessage(err, "failed to hash server CA for cloud identity")
+		}
+		cloudAuth, err := cloudidentity.New(cfg.CloudIdentityRules, cfg.CloudIdentityAudience, clusterID)
+		if err != nil {
+			return err
+		}
+		auth = authenticator.Combine(auth, basicauth.New(cloudAuth))
+	}
 	cfg.Runtime.Authenticator = auth
 
+	if cfg.TokenAuditLog != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.TokenAuditLog), 0700); err != nil {
+			return errors.WithMessage(err, "failed to create token audit log directory")
+		}
+		cfg.Runtime.TokenAuditLog = &lumberjack.Logger{
+			Filename:   cfg.TokenAuditLog,
+			MaxSize:    50,
+			MaxBackups: 3,
+			MaxAge:     28,
+			Compress:   true,
+		}
+	}
+
 	return nil
 }
 
@@ -119,7 +156,7 @@ func controllerManager(ctx context.Context, cfg *config.Control) error {
 		"tls-cert-file":                    runtime.ServingKubeControllerCert,
 		"tls-private-key-file":             runtime.ServingKubeControllerKey,
 		"bind-address":                     cfg.Loopback(false),
-		"secure-port":                      "10257",
+		"secure-port":                      config.ControllerManagerSecurePort,
 		"use-service-account-credentials":  "true",
 		"cluster-signing-kube-apiserver-client-cert-file": runtime.SigningClientCA,
 		"cluster-signing-kube-apiserver-client-key-file":  runtime.ClientCAKey,
@@ -137,6 +174,10 @@ func controllerManager(ctx context.Context, cfg *config.Control) error {
 		argsMap["configure-cloud-routes"] = "false"
 		argsMap["controllers"] = argsMap["controllers"] + ",-service,-route,-cloud-node-lifecycle"
 	}
+	if cfg.IPAMWebhookURL != "" || cfg.StickyPodCIDRs {
+		// pod CIDRs are allocated by the external IPAM webhook, or by the sticky pod CIDR allocator
+		argsMap["allocate-node-cidrs"] = "false"
+	}
 
 	if cfg.VLevel != 0 {
 		argsMap["v"] = strconv.Itoa(cfg.VLevel)
@@ -158,7 +199,7 @@ func scheduler(ctx context.Context, cfg *config.Control) error {
 		"authorization-kubeconfig":  runtime.KubeConfigScheduler,
 		"authentication-kubeconfig": runtime.KubeConfigScheduler,
 		"bind-address":              cfg.Loopback(false),
-		"secure-port":               "10259",
+		"secure-port":               config.SchedulerSecurePort,
 		"tls-cert-file":             runtime.ServingKubeSchedulerCert,
 		"tls-private-key-file":      runtime.ServingKubeSchedulerKey,
 		"profiling":                 "false",
@@ -204,6 +245,9 @@ func apiServer(ctx context.Context, cfg *config.Control) error {
 	argsMap := map[string]string{}
 
 	setupStorageBackend(argsMap, cfg)
+	if runtime.Faults != nil && argsMap["etcd-servers"] != "" {
+		argsMap["etcd-servers"] = runtime.Faults.DatastoreEndpoints(ctx, argsMap["etcd-servers"])
+	}
 
 	certDir := filepath.Join(cfg.DataDir, "tls", "temporary-certs")
 	os.MkdirAll(certDir, 0700)
@@ -237,6 +281,8 @@ func apiServer(ctx context.Context, cfg *config.Control) error {
 	if cfg.EgressSelectorMode != config.EgressSelectorModeDisabled {
 		argsMap["enable-aggregator-routing"] = "true"
 		argsMap["egress-selector-config-file"] = runtime.EgressSelectorConfig
+	} else if cfg.EgressSelectorConfigFile != "" {
+		argsMap["egress-selector-config-file"] = runtime.EgressSelectorConfig
 	}
 	argsMap["tls-cert-file"] = runtime.ServingKubeAPICert
 	argsMap["tls-private-key-file"] = runtime.ServingKubeAPIKey
@@ -265,6 +311,12 @@ func apiServer(ctx context.Context, cfg *config.Control) error {
 		argsMap["encryption-provider-config"] = runtime.EncryptionConfig
 		argsMap["encryption-provider-config-automatic-reload"] = "true"
 	}
+	if cfg.Guardrails != nil && cfg.Guardrails.EventTTL.Duration > 0 {
+		argsMap["event-ttl"] = cfg.Guardrails.EventTTL.Duration.String()
+	}
+	if len(cfg.APIServerWatchCacheSizes) > 0 {
+		argsMap["watch-cache-sizes"] = strings.Join(cfg.APIServerWatchCacheSizes, ",")
+	}
 	if cfg.VLevel != 0 {
 		argsMap["v"] = strconv.Itoa(cfg.VLevel)
 	}
@@ -319,6 +371,13 @@ func prepare(ctx context.Context, wg *sync.WaitGroup, config *config.Control) er
 
 	deps.CreateRuntimeCertFiles(config)
 
+	// Snapshot existing certificates and kubeconfigs, so that any files replaced by the datastore
+	// or regenerated below can be backed up, and restored with the certificate undo-rotate command.
+	snapshot, err := certbackup.Take(filepath.Dir(config.DataDir), filepath.Join(config.DataDir, "tls"), filepath.Join(config.DataDir, "cred"))
+	if err != nil {
+		return errors.WithMessage(err, "failed to snapshot certificates")
+	}
+
 	config.Cluster = cluster.New(config)
 	if err := config.Cluster.Bootstrap(ctx, config.ClusterReset); err != nil {
 		return errors.WithMessage(err, "failed to bootstrap cluster data")
@@ -328,6 +387,10 @@ func prepare(ctx context.Context, wg *sync.WaitGroup, config *config.Control) er
 		return errors.WithMessage(err, "failed to generate server dependencies")
 	}
 
+	if _, err := snapshot.Save(); err != nil {
+		return errors.WithMessage(err, "failed to back up certificates")
+	}
+
 	if err := config.Cluster.ListenAndServe(ctx); err != nil {
 		return errors.WithMessage(err, "failed to start supervisor listener")
 	}
@@ -380,6 +443,9 @@ func cloudControllerManager(ctx context.Context, cfg *config.Control) error {
 	if cfg.DisableServiceLB {
 		argsMap["controllers"] = argsMap["controllers"] + ",-service"
 	}
+	if cfg.IPAMWebhookURL != "" || cfg.StickyPodCIDRs {
+		argsMap["allocate-node-cidrs"] = "false"
+	}
 	if cfg.VLevel != 0 {
 		argsMap["v"] = strconv.Itoa(cfg.VLevel)
 	}
@@ -98,12 +98,24 @@ func (t *TunnelServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// HasSession returns true if the agent on the named node has a tunnel session connected to this server.
+func (t *TunnelServer) HasSession(nodeName string) bool {
+	return t.server.HasSession(nodeName)
+}
+
+// DialAgent dials an address through the tunnel session of the agent on the named node.
+func (t *TunnelServer) DialAgent(ctx context.Context, nodeName, address string) (net.Conn, error) {
+	return t.server.Dialer(nodeName)(ctx, "tcp", address)
+}
+
 // watch waits for the runtime core to become available,
 // and registers OnChange handlers to observe changes to Nodes (and Endpoints if necessary).
 func (t *TunnelServer) watch(ctx context.Context) {
 	logrus.Infof("Tunnel server egress proxy mode: %s", t.config.EgressSelectorMode)
 
-	if t.config.EgressSelectorMode == config.EgressSelectorModeDisabled {
+	switch t.config.EgressSelectorMode {
+	case config.EgressSelectorModeDisabled, config.EgressSelectorModeKonnectivity:
+		// The apiserver does not use the supervisor as its egress proxy in these modes.
 		return
 	}
 
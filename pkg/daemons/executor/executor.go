@@ -74,6 +74,7 @@ type ETCDConfig struct {
 	Logger               string         `json:"logger"`
 	LogOutputs           []string       `json:"log-outputs"`
 	SocketOpts           ETCDSocketOpts `json:"socket-options"`
+	MaxLearners          int            `json:"max-learners,omitempty"`
 
 	ExperimentalInitialCorruptCheck         bool          `json:"experimental-initial-corrupt-check"`
 	ExperimentalWatchProgressNotifyInterval time.Duration `json:"experimental-watch-progress-notify-interval"`
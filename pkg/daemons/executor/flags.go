@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// Names of the components that extra args can be validated for.
+const (
+	ComponentAPIServer         = "kube-apiserver"
+	ComponentControllerManager = "kube-controller-manager"
+	ComponentScheduler         = "kube-scheduler"
+	ComponentKubelet           = "kubelet"
+	ComponentKubeProxy         = "kube-proxy"
+)
+
+// maxSuggestions is the maximum number of alternatives suggested for an unknown flag.
+const maxSuggestions = 3
+
+// FlagSetProvider is implemented by executors that can provide the flag set of a component, so that
+// extra args can be validated before the component is started. The flag set is only used for validation,
+// and is not used to start the component.
+type FlagSetProvider interface {
+	FlagSet(component string) *pflag.FlagSet
+}
+
+// ValidateArgs checks that all of the extra args for a component are flags supported by that component.
+// Unknown flags are rejected with a list of similarly named flags that are supported; deprecated flags
+// are accepted with a warning. If the executor does not provide flag sets, the args are not validated.
+// The flag set is only requested if there are args to validate, as building it may be expensive.
+func ValidateArgs(component string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	provider, ok := executor.(FlagSetProvider)
+	if !ok {
+		return nil
+	}
+	fs := provider.FlagSet(component)
+	if fs == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, arg := range args {
+		name := util.ArgName(arg)
+		flag := fs.Lookup(name)
+		switch {
+		case flag == nil:
+			msg := fmt.Sprintf("unknown %s flag --%s", component, name)
+			if suggestions := suggestFlags(fs, name); len(suggestions) > 0 {
+				msg += "; did you mean --" + strings.Join(suggestions, ", --") + "?"
+			}
+			errs = append(errs, errors.New(msg))
+		case flag.Deprecated != "":
+			logrus.Warnf("%s flag --%s is deprecated: %s", component, flag.Name, flag.Deprecated)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// suggestFlags returns the names of non-deprecated flags that are similar to the given name,
+// ordered by similarity.
+func suggestFlags(fs *pflag.FlagSet, name string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	suggestions := []suggestion{}
+	fs.VisitAll(func(flag *pflag.Flag) {
+		if flag.Deprecated != "" || flag.Hidden {
+			return
+		}
+		distance := levenshtein(name, flag.Name)
+		if distance <= max(2, len(name)/4) || (len(name) > 3 && strings.Contains(flag.Name, name)) {
+			suggestions = append(suggestions, suggestion{name: flag.Name, distance: distance})
+		}
+	})
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int { return a.distance - b.distance })
+
+	names := []string{}
+	for _, s := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+type flagSetExecutor struct {
+	Executor
+	fs    *pflag.FlagSet
+	calls int
+}
+
+func (e *flagSetExecutor) FlagSet(component string) *pflag.FlagSet {
+	e.calls++
+	return e.fs
+}
+
+func Test_UnitValidateArgs(t *testing.T) {
+	fs := pflag.NewFlagSet(ComponentKubelet, pflag.ContinueOnError)
+	fs.Int("max-pods", 110, "")
+	fs.String("node-ip", "", "")
+	fs.String("pod-infra-container-image", "", "")
+	fs.MarkDeprecated("pod-infra-container-image", "will be removed in a future release")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name: "Supported flags",
+			args: []string{"max-pods=250", "--node-ip=10.0.0.1", "node-ip+=10.0.0.2"},
+		},
+		{
+			name: "Deprecated flag",
+			args: []string{"pod-infra-container-image=pause:latest"},
+		},
+		{
+			name:    "Unknown flag with suggestion",
+			args:    []string{"max-pod=250"},
+			wantErr: "unknown kubelet flag --max-pod; did you mean --max-pods?",
+		},
+		{
+			name:    "Unknown flag without suggestion",
+			args:    []string{"container-runtime=remote"},
+			wantErr: "unknown kubelet flag --container-runtime",
+		},
+	}
+	defer Set(executor)
+	Set(&flagSetExecutor{fs: fs})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArgs(ComponentKubelet, tt.args)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ValidateArgs() error = %v, want nil", err)
+			} else if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("ValidateArgs() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitValidateArgsNoArgs(t *testing.T) {
+	e := &flagSetExecutor{fs: pflag.NewFlagSet(ComponentKubelet, pflag.ContinueOnError)}
+	defer Set(executor)
+	Set(e)
+	if err := ValidateArgs(ComponentKubelet, nil); err != nil {
+		t.Errorf("ValidateArgs() error = %v, want nil", err)
+	}
+	if e.calls != 0 {
+		t.Errorf("ValidateArgs() requested the flag set %d times with no args, want 0", e.calls)
+	}
+}
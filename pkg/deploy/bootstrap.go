@@ -0,0 +1,251 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/rancher/wrangler/pkg/objectset"
+	"github.com/sirupsen/logrus"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// BootstrapCriticalAnnotation may be set to "true" on any object in a manifest to mark the manifest as
+	// bootstrap-critical. Bootstrap-critical manifests are applied before all other manifests when the server
+	// starts, and the server does not report ready until the objects they contain are ready. This is intended
+	// for admission webhooks that must be serving before any other workloads are created. Webhook configurations
+	// within a bootstrap-critical manifest are only applied once all other objects in the manifest are ready,
+	// so that a webhook cannot block creation of the pods that serve it.
+	BootstrapCriticalAnnotation = "addon.k3s.cattle.io/bootstrap-critical"
+
+	// bootstrapCriticalInterval is the interval at which bootstrap-critical manifests are re-applied and
+	// checked, until all are ready.
+	bootstrapCriticalInterval = 5 * time.Second
+
+	// bootstrapCriticalTimeout is how long startup waits for bootstrap-critical manifests to become ready.
+	// Manifests that are not ready by then continue to be retried along with all other manifests.
+	bootstrapCriticalTimeout = 5 * time.Minute
+)
+
+// isWebhookConfiguration returns true if the object is an admission webhook configuration.
+func isWebhookConfiguration(obj runtime.Object) bool {
+	switch obj.GetObjectKind().GroupVersionKind().GroupKind().String() {
+	case "ValidatingWebhookConfiguration.admissionregistration.k8s.io", "MutatingWebhookConfiguration.admissionregistration.k8s.io":
+		return true
+	}
+	return false
+}
+
+// withoutWebhooks returns a copy of the object set that does not contain any admission webhook configurations.
+func withoutWebhooks(objects *objectset.ObjectSet) *objectset.ObjectSet {
+	result := objectset.NewObjectSet()
+	for _, obj := range objects.All() {
+		if !isWebhookConfiguration(obj) {
+			result.Add(obj)
+		}
+	}
+	return result
+}
+
+// isBootstrapCritical returns true if any object in the object set has the bootstrap-critical annotation.
+func isBootstrapCritical(objects *objectset.ObjectSet) bool {
+	for _, obj := range objects.All() {
+		if u, ok := obj.(*unstructured.Unstructured); ok && u.GetAnnotations()[BootstrapCriticalAnnotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// criticalFiles returns the paths of all bootstrap-critical manifests that are neither disabled nor skipped,
+// mapped to the base directory that contains them.
+func (w *watcher) criticalFiles() (map[string]string, error) {
+	critical := map[string]string{}
+	for _, base := range w.bases {
+		files, err := walkFiles(base)
+		if err != nil {
+			return nil, err
+		}
+		keys, skips := sortedFiles(files)
+		for _, path := range keys {
+			if files[path].IsDir() || shouldDisableFile(base, path, w.disables) || shouldSkipFile(files[path].Name(), skips) {
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			// Files that cannot be parsed are left for the normal apply pass to report.
+			if objects, err := objectSet(content); err == nil && isBootstrapCritical(objects) {
+				critical[path] = base
+			}
+		}
+	}
+	return critical, nil
+}
+
+// deployCritical applies all bootstrap-critical manifests, and waits for the objects within them to become ready.
+// Manifests are re-applied until all are ready, or the context is cancelled. Manifests are removed from the
+// watcher's list of bootstrap-critical manifests once they are ready.
+func (w *watcher) deployCritical(ctx context.Context) error {
+	for {
+		var errs []error
+		for _, path := range slices.Sorted(maps.Keys(w.critical)) {
+			if err := w.deployCriticalFile(ctx, w.critical[path], path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(w.critical, path)
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		logrus.Infof("Waiting for bootstrap-critical manifests to become ready: %v", errors.Join(errs...))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bootstrapCriticalInterval):
+		}
+	}
+}
+
+// deployCriticalFile applies a bootstrap-critical manifest without its webhook configurations, and waits for the
+// objects within it to become ready before applying the complete manifest. An error is returned if the objects
+// are not yet ready.
+func (w *watcher) deployCriticalFile(ctx context.Context, base, path string) error {
+	if err := w.deployFile(base, path, false, true); err != nil {
+		return errors.WithMessagef(err, "failed to process %s", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	objects, err := objectSet(content)
+	if err != nil {
+		return err
+	}
+	if err := checkReady(ctx, w.k8s, withoutWebhooks(objects)); err != nil {
+		return errors.WithMessagef(err, "waiting for %s", path)
+	}
+	if err := w.deploy(base, path, false); err != nil {
+		return errors.WithMessagef(err, "failed to process %s", path)
+	}
+	if err := checkReady(ctx, w.k8s, objects); err != nil {
+		return errors.WithMessagef(err, "waiting for %s", path)
+	}
+	return nil
+}
+
+// checkReady returns an error if any Deployment, DaemonSet, or HelmChart in the object set is not ready, or if
+// any service used by an admission webhook in the object set does not have a ready endpoint. Other objects are
+// considered ready once they have been applied.
+func checkReady(ctx context.Context, k8s kubernetes.Interface, objects *objectset.ObjectSet) error {
+	for _, obj := range objects.All() {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		name, namespace := u.GetName(), u.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		switch u.GroupVersionKind().GroupKind().String() {
+		case "Deployment.apps":
+			d, err := k8s.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			replicas := int32(1)
+			if d.Spec.Replicas != nil {
+				replicas = *d.Spec.Replicas
+			}
+			if d.Status.ObservedGeneration < d.Generation || d.Status.AvailableReplicas < replicas {
+				return fmt.Errorf("deployment %s/%s has %d of %d replicas available", namespace, name, d.Status.AvailableReplicas, replicas)
+			}
+		case "DaemonSet.apps":
+			ds, err := k8s.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if ds.Status.ObservedGeneration < ds.Generation || ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled {
+				return fmt.Errorf("daemonset %s/%s has %d of %d pods available", namespace, name, ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
+			}
+		case "HelmChart.helm.cattle.io":
+			// The chart is installed by a job with a name derived from the chart name.
+			job, err := k8s.BatchV1().Jobs(namespace).Get(ctx, "helm-install-"+name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if job.Status.Succeeded < 1 {
+				return fmt.Errorf("helmchart %s/%s has not been installed", namespace, name)
+			}
+		case "ValidatingWebhookConfiguration.admissionregistration.k8s.io", "MutatingWebhookConfiguration.admissionregistration.k8s.io":
+			services, err := webhookServices(u)
+			if err != nil {
+				return err
+			}
+			for _, service := range services {
+				if err := checkServiceReady(ctx, k8s, service.Namespace, service.Name); err != nil {
+					return errors.WithMessagef(err, "webhook %s", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// webhookServices returns the services used by the webhooks in a webhook configuration. Webhooks that call a
+// URL instead of a service are not included.
+func webhookServices(u *unstructured.Unstructured) ([]metav1.ObjectMeta, error) {
+	webhooks, _, err := unstructured.NestedSlice(u.Object, "webhooks")
+	if err != nil {
+		return nil, err
+	}
+	var services []metav1.ObjectMeta
+	for _, webhook := range webhooks {
+		webhook, ok := webhook.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, ok, err := unstructured.NestedString(webhook, "clientConfig", "service", "name")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		namespace, _, err := unstructured.NestedString(webhook, "clientConfig", "service", "namespace")
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, metav1.ObjectMeta{Namespace: namespace, Name: name})
+	}
+	return services, nil
+}
+
+// checkServiceReady returns an error if a service does not have at least one ready endpoint.
+func checkServiceReady(ctx context.Context, k8s kubernetes.Interface, namespace, name string) error {
+	selector := labels.Set{discoveryv1.LabelServiceName: name}.String()
+	endpointSlices, err := k8s.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	for _, slice := range endpointSlices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("service %s/%s has no ready endpoints", namespace, name)
+}
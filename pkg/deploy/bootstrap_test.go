@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+const webhookManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: policy-webhook
+  namespace: kube-system
+  annotations:
+    addon.k3s.cattle.io/bootstrap-critical: "true"
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: policy-webhook
+webhooks:
+- name: validate.policy.example.com
+  clientConfig:
+    service:
+      name: policy-webhook
+      namespace: kube-system
+      port: 443
+- name: external.policy.example.com
+  clientConfig:
+    url: https://policy.example.com/validate
+`
+
+func Test_UnitIsBootstrapCritical(t *testing.T) {
+	objects, err := objectSet([]byte(webhookManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBootstrapCritical(objects) {
+		t.Errorf("isBootstrapCritical() = false, want true")
+	}
+
+	objects, err = objectSet([]byte(placementManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isBootstrapCritical(objects) {
+		t.Errorf("isBootstrapCritical() = true, want false")
+	}
+}
+
+func Test_UnitCheckReady(t *testing.T) {
+	deployment := func(available int32) runtime.Object {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy-webhook", Namespace: "kube-system"},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+			Status:     appsv1.DeploymentStatus{AvailableReplicas: available},
+		}
+	}
+	endpointSlice := func(ready bool) runtime.Object {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "policy-webhook-abcde",
+				Namespace: "kube-system",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "policy-webhook"},
+			},
+			Endpoints: []discoveryv1.Endpoint{{Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready)}}},
+		}
+	}
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		wantErr bool
+	}{
+		{
+			name:    "not yet created",
+			wantErr: true,
+		},
+		{
+			name:    "deployment not available",
+			objects: []runtime.Object{deployment(0), endpointSlice(false)},
+			wantErr: true,
+		},
+		{
+			name:    "webhook service not ready",
+			objects: []runtime.Object{deployment(1), endpointSlice(false)},
+			wantErr: true,
+		},
+		{
+			name:    "ready",
+			objects: []runtime.Object{deployment(1), endpointSlice(true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := objectSet([]byte(webhookManifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			k8s := fake.NewSimpleClientset(tt.objects...)
+			if err := checkReady(context.Background(), k8s, objects); (err != nil) != tt.wantErr {
+				t.Errorf("checkReady() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitWithoutWebhooks(t *testing.T) {
+	objects, err := objectSet([]byte(webhookManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := withoutWebhooks(objects)
+	if len(filtered.All()) != 1 {
+		t.Fatalf("withoutWebhooks() returned %d objects, want 1", len(filtered.All()))
+	}
+	for _, obj := range filtered.All() {
+		if isWebhookConfiguration(obj) {
+			t.Errorf("withoutWebhooks() returned webhook configuration %v", obj.GetObjectKind().GroupVersionKind())
+		}
+	}
+	if len(objects.All()) != 2 {
+		t.Errorf("withoutWebhooks() modified the original object set")
+	}
+}
@@ -15,9 +15,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/agent/util"
 	apisv1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
 	controllersv1 "github.com/k3s-io/k3s/pkg/generated/controllers/k3s.cattle.io/v1"
-	"github.com/k3s-io/k3s/pkg/agent/util"
+	"github.com/k3s-io/k3s/pkg/loadshed"
 	pkgutil "github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/rancher/wrangler/pkg/apply"
@@ -33,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/record"
 )
 
@@ -44,22 +46,38 @@ const (
 )
 
 // WatchFiles sets up an OnChange callback to start a periodic goroutine to watch files for changes once the controller has started up.
-func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Apply, addons controllersv1.AddonController, disables map[string]bool, bases ...string) error {
+// Pods of components with a placement are scheduled using the placement's node selectors and tolerations.
+// Bootstrap-critical manifests are applied and waited on before any other manifests; the ready channel, if not nil,
+// is closed once they are ready, or immediately if there are none.
+func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Apply, addons controllersv1.AddonController, disables map[string]bool, placements map[string]Placement, ready chan<- struct{}, bases ...string) error {
 	w := &watcher{
+		k8s:        client,
 		apply:      apply,
 		addonCache: addons.Cache(),
 		addons:     addons,
 		bases:      bases,
 		disables:   disables,
+		placements: placements,
 		modTime:    map[string]time.Time{},
 		gvkCache:   map[schema.GroupVersionKind]bool{},
 		discovery:  client.Discovery(),
+		nodes:      client.CoreV1().Nodes(),
+	}
+
+	critical, err := w.criticalFiles()
+	if err != nil {
+		return err
+	}
+	w.critical = critical
+	if len(critical) == 0 && ready != nil {
+		close(ready)
+		ready = nil
 	}
 
 	addons.Enqueue(metav1.NamespaceNone, startKey)
 	addons.OnChange(ctx, "addon-start", func(key string, _ *apisv1.Addon) (*apisv1.Addon, error) {
 		if key == startKey {
-			go w.start(ctx, client)
+			go w.start(ctx, client, ready)
 		}
 		return nil, nil
 	})
@@ -70,15 +88,19 @@ func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Ap
 type watcher struct {
 	sync.Mutex
 
+	k8s        kubernetes.Interface
 	apply      apply.Apply
 	addonCache controllersv1.AddonCache
 	addons     controllersv1.AddonClient
 	bases      []string
 	disables   map[string]bool
+	placements map[string]Placement
 	modTime    map[string]time.Time
 	gvkCache   map[schema.GroupVersionKind]bool
 	recorder   record.EventRecorder
 	discovery  discovery.DiscoveryInterface
+	nodes      typedcorev1.NodeInterface
+	critical   map[string]string
 }
 
 type watchedFile struct {
@@ -86,11 +108,34 @@ type watchedFile struct {
 	removeOnDisable bool
 }
 
-// start calls listFiles at regular intervals to trigger application of manifests that have changed on disk.
-func (w *watcher) start(ctx context.Context, client kubernetes.Interface) {
+// start applies bootstrap-critical manifests and waits for them to become ready, then calls listFiles at regular
+// intervals to trigger application of manifests that have changed on disk. If the bootstrap-critical manifests
+// are not ready within bootstrapCriticalTimeout, startup continues and they are retried along with other manifests.
+func (w *watcher) start(ctx context.Context, client kubernetes.Interface, ready chan<- struct{}) {
 	w.recorder = pkgutil.BuildControllerEventRecorder(client, ControllerName, metav1.NamespaceSystem)
+	if len(w.critical) > 0 {
+		logrus.Infof("Applying %d bootstrap-critical manifests before other manifests", len(w.critical))
+		criticalCtx, cancel := context.WithTimeout(ctx, bootstrapCriticalTimeout)
+		err := w.deployCritical(criticalCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logrus.Warnf("Bootstrap-critical manifests were not ready after %v, continuing startup: %v", bootstrapCriticalTimeout, err)
+		} else {
+			logrus.Info("Bootstrap-critical manifests are ready")
+		}
+		if ready != nil {
+			close(ready)
+		}
+	}
 	force := true
 	for {
+		// Reconciliation after the initial pass is not critical, and can be deferred under CPU pressure.
+		if !force {
+			loadshed.Wait(ctx, "addon manifest reconciliation")
+		}
 		if err := w.listFiles(force); err == nil {
 			force = false
 		} else {
@@ -123,20 +168,7 @@ func (w *watcher) listFilesIn(base string, force bool) error {
 		return err
 	}
 
-	// Make a map of .skip files - these are used later to indicate that a given file should be ignored
-	// For example, 'addon.yaml.skip' will cause 'addon.yaml' to be ignored completely - unless it is also
-	// disabled, since disable processing happens first.
-	skips := map[string]bool{}
-	keys := make([]string, len(files))
-	keyIndex := 0
-	for path, file := range files {
-		if strings.HasSuffix(file.Name(), ".skip") {
-			skips[strings.TrimSuffix(file.Name(), ".skip")] = true
-		}
-		keys[keyIndex] = path
-		keyIndex++
-	}
-	slices.Sort(keys)
+	keys, skips := sortedFiles(files)
 
 	var errs []error
 	for _, path := range keys {
@@ -158,7 +190,18 @@ func (w *watcher) listFilesIn(base string, force bool) error {
 		if !force && modTime.Equal(w.modTime[path]) {
 			continue
 		}
-		if err := w.deploy(path, !force); err != nil {
+		// Bootstrap-critical manifests that were not ready at startup continue to have their webhooks
+		// deferred until the rest of the manifest is ready.
+		if _, ok := w.critical[path]; ok {
+			if err := w.deployCriticalFile(context.TODO(), base, path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(w.critical, path)
+			w.modTime[path] = modTime
+			continue
+		}
+		if err := w.deploy(base, path, !force); err != nil {
 			errs = append(errs, errors.WithMessagef(err, "failed to process %s", path))
 		} else {
 			w.modTime[path] = modTime
@@ -168,6 +211,22 @@ func (w *watcher) listFilesIn(base string, force bool) error {
 	return errors.Join(errs...)
 }
 
+// sortedFiles returns the sorted paths of all files, and a map of .skip files - these are used to indicate that
+// a given file should be ignored. For example, 'addon.yaml.skip' will cause 'addon.yaml' to be ignored completely -
+// unless it is also disabled, since disable processing happens first.
+func sortedFiles(files map[string]watchedFile) ([]string, map[string]bool) {
+	skips := map[string]bool{}
+	keys := make([]string, 0, len(files))
+	for path, file := range files {
+		if strings.HasSuffix(file.Name(), ".skip") {
+			skips[strings.TrimSuffix(file.Name(), ".skip")] = true
+		}
+		keys = append(keys, path)
+	}
+	slices.Sort(keys)
+	return keys, skips
+}
+
 func walkFiles(base string) (map[string]watchedFile, error) {
 	files := map[string]watchedFile{}
 	if err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
@@ -210,7 +269,14 @@ func walkFiles(base string) (map[string]watchedFile, error) {
 
 // deploy loads yaml from a manifest on disk, creates an AddOn resource to track its application, and then applies
 // all resources contained within to the cluster.
-func (w *watcher) deploy(path string, compareChecksum bool) error {
+func (w *watcher) deploy(base, path string, compareChecksum bool) error {
+	return w.deployFile(base, path, compareChecksum, false)
+}
+
+// deployFile applies a manifest as described by deploy. If deferWebhooks is true, admission webhook configurations
+// are not applied, existing objects are not pruned, and the Addon checksum is not updated, so that the complete
+// manifest is applied by the next call to deploy.
+func (w *watcher) deployFile(base, path string, compareChecksum, deferWebhooks bool) error {
 	name := basename(path)
 	addon, err := w.getOrCreateAddon(name)
 	if err != nil {
@@ -249,6 +315,26 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 		return err
 	}
 
+	// Set rollout strategy for annotated components based on the current cluster size. This is evaluated
+	// only when the manifest changes, which is also the only time that the deploy controller triggers a rollout.
+	nodes, err := w.nodes.List(context.TODO(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		return err
+	}
+	if err := setRolloutStrategy(objects, len(nodes.Items)); err != nil {
+		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "RolloutStrategyFailed", "Set rollout strategy for manifest at %q failed: %v", path, err)
+		return err
+	}
+
+	// Add node selectors and tolerations for components with a configured placement. Placements can only be
+	// changed by restarting the server, at which point all manifests are re-applied.
+	if placement, ok := placementForFile(base, path, w.placements); ok {
+		if err := setPlacement(objects, placement); err != nil {
+			w.recorder.Eventf(&addon, corev1.EventTypeWarning, "PlacementFailed", "Set placement for manifest at %q failed: %v", path, err)
+			return err
+		}
+	}
+
 	// Merge GVK list early for validation
 	addonGVKs := objects.GVKs()
 	for _, gvkString := range strings.Split(addon.Annotations[GVKAnnotation], gvkSep) {
@@ -272,10 +358,18 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 	// doesn't know to search that GVK for owner references, it won't find and delete them.
 	w.recorder.Eventf(&addon, corev1.EventTypeNormal, "ApplyingManifest", "Applying manifest at %q", path)
 
-	if err := w.apply.WithOwner(&addon).WithGVK(addonGVKs...).Apply(objects); err != nil {
+	applier := w.apply.WithOwner(&addon).WithGVK(addonGVKs...)
+	if deferWebhooks {
+		objects = withoutWebhooks(objects)
+		applier = applier.WithNoDelete()
+	}
+	if err := applier.Apply(objects); err != nil {
 		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ApplyManifestFailed", "Applying manifest at %q failed: %v", path, err)
 		return err
 	}
+	if deferWebhooks {
+		return nil
+	}
 
 	// Emit event, Update Addon checksum and GVKs only if apply was successful
 	w.recorder.Eventf(&addon, corev1.EventTypeNormal, "AppliedManifest", "Applied manifest at %q", path)
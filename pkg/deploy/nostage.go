@@ -5,3 +5,7 @@ package deploy
 func Stage(dataDir string, templateVars map[string]string, skips map[string]bool) error {
 	return nil
 }
+
+func Packaged(name string) bool {
+	return false
+}
@@ -0,0 +1,163 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/wrangler/pkg/objectset"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Placement contains node selectors and tolerations that are added to the pods of a packaged component.
+type Placement struct {
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+}
+
+// ParsePlacements parses component node selectors in the format <component>=<key>=<value>, and component
+// tolerations in the format <component>=<key>[=<value>][:<effect>], into placements keyed by component name.
+// Component names are the names used to disable packaged components: the manifest basename or directory.
+func ParsePlacements(nodeSelectors, tolerations []string) (map[string]Placement, error) {
+	placements := map[string]Placement{}
+	for _, s := range nodeSelectors {
+		component, selector, _ := strings.Cut(s, "=")
+		key, value, ok := strings.Cut(selector, "=")
+		if component == "" || key == "" || !ok {
+			return nil, fmt.Errorf("invalid component node selector %q; must be in the format <component>=<key>=<value>", s)
+		}
+		placement := placements[component]
+		if placement.NodeSelector == nil {
+			placement.NodeSelector = map[string]string{}
+		}
+		placement.NodeSelector[key] = value
+		placements[component] = placement
+	}
+	for _, s := range tolerations {
+		component, toleration, _ := strings.Cut(s, "=")
+		if component == "" || toleration == "" {
+			return nil, fmt.Errorf("invalid component toleration %q; must be in the format <component>=<key>[=<value>][:<effect>]", s)
+		}
+		t := corev1.Toleration{Operator: corev1.TolerationOpExists}
+		toleration, effect, _ := strings.Cut(toleration, ":")
+		switch corev1.TaintEffect(effect) {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			t.Effect = corev1.TaintEffect(effect)
+		default:
+			return nil, fmt.Errorf("invalid component toleration %q; unknown effect %q", s, effect)
+		}
+		if key, value, ok := strings.Cut(toleration, "="); ok {
+			t.Key, t.Value, t.Operator = key, value, corev1.TolerationOpEqual
+		} else {
+			t.Key = key
+		}
+		if t.Key == "" {
+			return nil, fmt.Errorf("invalid component toleration %q; key must be set", s)
+		}
+		placement := placements[component]
+		placement.Tolerations = append(placement.Tolerations, t)
+		placements[component] = placement
+	}
+	return placements, nil
+}
+
+// placementForFile returns the placement for a manifest file, by checking the file's parent directories
+// and basename against the placements map, in the same way as files are checked against the disables map.
+func placementForFile(base, fileName string, placements map[string]Placement) (Placement, bool) {
+	relFile := strings.TrimPrefix(fileName, base)
+	namePath := strings.Split(relFile, string(os.PathSeparator))
+	for i := 1; i < len(namePath); i++ {
+		if placement, ok := placements[filepath.Join(namePath[0:i]...)]; ok {
+			return placement, true
+		}
+	}
+	baseFile := filepath.Base(fileName)
+	placement, ok := placements[strings.TrimSuffix(baseFile, filepath.Ext(baseFile))]
+	return placement, ok
+}
+
+// setPlacement adds the node selectors and tolerations from the placement to the pod templates of all
+// workloads in the object set, and to the chart values of all HelmCharts. Existing node selectors with
+// the same key are replaced; existing tolerations are retained.
+func setPlacement(objects *objectset.ObjectSet, placement Placement) error {
+	for _, obj := range objects.All() {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		switch u.GroupVersionKind().GroupKind().String() {
+		case "Deployment.apps", "DaemonSet.apps", "StatefulSet.apps":
+			if err := setPodPlacement(u.Object, placement, "spec", "template", "spec"); err != nil {
+				return err
+			}
+		case "HelmChart.helm.cattle.io":
+			if err := helmChartPlacement(u, placement); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// helmChartPlacement sets the nodeSelector and tolerations chart values on a HelmChart. The value names match
+// those used by the packaged charts. The values are merged into the chart's valuesContent, as the list of
+// tolerations cannot be set using individual values.
+func helmChartPlacement(u *unstructured.Unstructured, placement Placement) error {
+	valuesContent, _, err := unstructured.NestedString(u.Object, "spec", "valuesContent")
+	if err != nil {
+		return err
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal([]byte(valuesContent), &values); err != nil {
+		return err
+	}
+	if err := setPodPlacement(values, placement); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedField(u.Object, string(b), "spec", "valuesContent")
+}
+
+// setPodPlacement merges the placement into the nodeSelector and tolerations fields found at the given path.
+func setPodPlacement(obj map[string]any, placement Placement, fields ...string) error {
+	if len(placement.NodeSelector) > 0 {
+		nodeSelector, _, err := unstructured.NestedStringMap(obj, append(fields, "nodeSelector")...)
+		if err != nil {
+			return err
+		}
+		if nodeSelector == nil {
+			nodeSelector = map[string]string{}
+		}
+		for key, value := range placement.NodeSelector {
+			nodeSelector[key] = value
+		}
+		if err := unstructured.SetNestedStringMap(obj, nodeSelector, append(fields, "nodeSelector")...); err != nil {
+			return err
+		}
+	}
+
+	if len(placement.Tolerations) > 0 {
+		tolerations, _, err := unstructured.NestedSlice(obj, append(fields, "tolerations")...)
+		if err != nil {
+			return err
+		}
+		for _, toleration := range placement.Tolerations {
+			t, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&toleration)
+			if err != nil {
+				return err
+			}
+			tolerations = append(tolerations, t)
+		}
+		if err := unstructured.SetNestedSlice(obj, tolerations, append(fields, "tolerations")...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
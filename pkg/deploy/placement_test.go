@@ -0,0 +1,159 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const placementManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: coredns
+  namespace: kube-system
+spec:
+  template:
+    spec:
+      nodeSelector:
+        kubernetes.io/os: linux
+      tolerations:
+      - key: CriticalAddonsOnly
+        operator: Exists
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: traefik
+  namespace: kube-system
+spec:
+  valuesContent: |-
+    priorityClassName: "system-cluster-critical"
+    tolerations:
+    - key: "CriticalAddonsOnly"
+      operator: "Exists"
+`
+
+func Test_UnitParsePlacements(t *testing.T) {
+	tests := []struct {
+		name          string
+		nodeSelectors []string
+		tolerations   []string
+		want          map[string]Placement
+		wantErr       bool
+	}{
+		{
+			name:          "Node selectors and tolerations",
+			nodeSelectors: []string{"traefik=node-pool=ingress", "traefik=kubernetes.io/os=linux"},
+			tolerations:   []string{"traefik=dedicated=ingress:NoSchedule", "coredns=gpu"},
+			want: map[string]Placement{
+				"traefik": {
+					NodeSelector: map[string]string{"node-pool": "ingress", "kubernetes.io/os": "linux"},
+					Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ingress", Effect: corev1.TaintEffectNoSchedule}},
+				},
+				"coredns": {
+					Tolerations: []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists}},
+				},
+			},
+		},
+		{
+			name:          "Node selector without value",
+			nodeSelectors: []string{"traefik=node-pool"},
+			wantErr:       true,
+		},
+		{
+			name:        "Toleration without key",
+			tolerations: []string{"traefik=:NoSchedule"},
+			wantErr:     true,
+		},
+		{
+			name:        "Toleration with invalid effect",
+			tolerations: []string{"traefik=dedicated:NoRun"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlacements(tt.nodeSelectors, tt.tolerations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlacements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlacements() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitPlacementForFile(t *testing.T) {
+	placements := map[string]Placement{
+		"traefik":        {NodeSelector: map[string]string{"node-pool": "ingress"}},
+		"metrics-server": {NodeSelector: map[string]string{"node-pool": "system"}},
+	}
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{fileName: "/var/lib/rancher/k3s/server/manifests/traefik.yaml", want: "ingress"},
+		{fileName: "/var/lib/rancher/k3s/server/manifests/metrics-server/metrics-server-deployment.yaml", want: "system"},
+		{fileName: "/var/lib/rancher/k3s/server/manifests/coredns.yaml"},
+	}
+	for _, tt := range tests {
+		placement, ok := placementForFile("/var/lib/rancher/k3s/server/manifests", tt.fileName, placements)
+		if ok != (tt.want != "") || placement.NodeSelector["node-pool"] != tt.want {
+			t.Errorf("placementForFile(%s) = %+v, %v, want node-pool %q", tt.fileName, placement, ok, tt.want)
+		}
+	}
+}
+
+func Test_UnitSetPlacement(t *testing.T) {
+	objects, err := objectSet([]byte(placementManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	placement := Placement{
+		NodeSelector: map[string]string{"node-pool": "ingress"},
+		Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ingress", Effect: corev1.TaintEffectNoSchedule}},
+	}
+	if err := setPlacement(objects, placement); err != nil {
+		t.Fatal(err)
+	}
+
+	wantNodeSelector := map[string]string{"kubernetes.io/os": "linux", "node-pool": "ingress"}
+	wantTolerations := []any{
+		map[string]any{"key": "CriticalAddonsOnly", "operator": "Exists"},
+		map[string]any{"key": "dedicated", "operator": "Equal", "value": "ingress", "effect": "NoSchedule"},
+	}
+	for _, obj := range objects.All() {
+		u := obj.(*unstructured.Unstructured)
+		switch u.GetKind() {
+		case "Deployment":
+			nodeSelector, _, _ := unstructured.NestedStringMap(u.Object, "spec", "template", "spec", "nodeSelector")
+			if !reflect.DeepEqual(nodeSelector, wantNodeSelector) {
+				t.Errorf("Deployment nodeSelector = %v, want %v", nodeSelector, wantNodeSelector)
+			}
+			tolerations, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "tolerations")
+			if !reflect.DeepEqual(tolerations, wantTolerations) {
+				t.Errorf("Deployment tolerations = %v, want %v", tolerations, wantTolerations)
+			}
+		case "HelmChart":
+			valuesContent, _, _ := unstructured.NestedString(u.Object, "spec", "valuesContent")
+			values := map[string]any{}
+			if err := yaml.Unmarshal([]byte(valuesContent), &values); err != nil {
+				t.Fatal(err)
+			}
+			if values["priorityClassName"] != "system-cluster-critical" {
+				t.Errorf("HelmChart values = %v, want existing values retained", values)
+			}
+			if !reflect.DeepEqual(values["nodeSelector"], map[string]any{"node-pool": "ingress"}) {
+				t.Errorf("HelmChart nodeSelector = %v, want node-pool=ingress", values["nodeSelector"])
+			}
+			if !reflect.DeepEqual(values["tolerations"], wantTolerations) {
+				t.Errorf("HelmChart tolerations = %v, want %v", values["tolerations"], wantTolerations)
+			}
+		}
+	}
+}
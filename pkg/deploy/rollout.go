@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"github.com/rancher/wrangler/pkg/objectset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// RolloutAnnotation may be set on a Deployment or HelmChart in a manifest to have the deploy
+	// controller set its rolling update strategy based on the size of the cluster, and add a
+	// PodDisruptionBudget that prevents voluntary disruption of more than one pod at a time.
+	RolloutAnnotation = "addon.k3s.cattle.io/rollout"
+
+	// RolloutClusterSize is the only currently supported value for the rollout annotation.
+	RolloutClusterSize = "cluster-size"
+)
+
+// rolloutStrategy returns the maxSurge and maxUnavailable values for a rolling update
+// on a cluster with the given number of nodes. Existing pods are never taken down before
+// their replacement is ready; larger clusters surge more pods at once to shorten the rollout.
+func rolloutStrategy(nodes int) (intstr.IntOrString, intstr.IntOrString) {
+	if nodes < 4 {
+		return intstr.FromInt32(1), intstr.FromInt32(0)
+	}
+	return intstr.FromString("25%"), intstr.FromInt32(0)
+}
+
+// setRolloutStrategy updates the rolling update strategy for all annotated Deployments and HelmCharts in the
+// object set, and adds PodDisruptionBudgets for annotated Deployments. Packaged components are updated by
+// re-applying their manifest; without this, a change to the pod template of a component with a rollout
+// strategy that allows all pods to be unavailable restarts every replica at once.
+func setRolloutStrategy(objects *objectset.ObjectSet, nodes int) error {
+	maxSurge, maxUnavailable := rolloutStrategy(nodes)
+	for _, obj := range objects.All() {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok || u.GetAnnotations()[RolloutAnnotation] != RolloutClusterSize {
+			continue
+		}
+		switch u.GroupVersionKind().GroupKind().String() {
+		case "Deployment.apps":
+			pdb, err := deploymentRollout(u, maxSurge, maxUnavailable)
+			if err != nil {
+				return err
+			}
+			objects.Add(pdb)
+		case "HelmChart.helm.cattle.io":
+			if err := helmChartRollout(u, maxSurge, maxUnavailable); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deploymentRollout sets the rolling update strategy on a Deployment, and returns
+// a PodDisruptionBudget for its pods.
+func deploymentRollout(u *unstructured.Unstructured, maxSurge, maxUnavailable intstr.IntOrString) (runtime.Object, error) {
+	if err := unstructured.SetNestedField(u.Object, "RollingUpdate", "spec", "strategy", "type"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(u.Object, intOrStringValue(maxSurge), "spec", "strategy", "rollingUpdate", "maxSurge"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(u.Object, intOrStringValue(maxUnavailable), "spec", "strategy", "rollingUpdate", "maxUnavailable"); err != nil {
+		return nil, err
+	}
+
+	selector, _, err := unstructured.NestedMap(u.Object, "spec", "selector")
+	if err != nil {
+		return nil, err
+	}
+	pdb := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"maxUnavailable": int64(1),
+			"selector":       selector,
+		},
+	}}
+	pdb.SetAPIVersion("policy/v1")
+	pdb.SetKind("PodDisruptionBudget")
+	pdb.SetName(u.GetName())
+	pdb.SetNamespace(u.GetNamespace())
+	pdb.SetLabels(u.GetLabels())
+	return pdb, nil
+}
+
+// helmChartRollout sets chart values for the rolling update strategy and PodDisruptionBudget
+// on a HelmChart. The value names match those used by the packaged charts.
+func helmChartRollout(u *unstructured.Unstructured, maxSurge, maxUnavailable intstr.IntOrString) error {
+	for key, value := range map[string]any{
+		"updateStrategy.type":                         "RollingUpdate",
+		"updateStrategy.rollingUpdate.maxSurge":       intOrStringValue(maxSurge),
+		"updateStrategy.rollingUpdate.maxUnavailable": intOrStringValue(maxUnavailable),
+		"podDisruptionBudget.enabled":                 "true",
+		"podDisruptionBudget.maxUnavailable":          int64(1),
+	} {
+		if err := unstructured.SetNestedField(u.Object, value, "spec", "set", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intOrStringValue converts an IntOrString to a value that can be stored in an unstructured object.
+func intOrStringValue(v intstr.IntOrString) any {
+	if v.Type == intstr.String {
+		return v.StrVal
+	}
+	return int64(v.IntVal)
+}
@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const rolloutManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: coredns
+  namespace: kube-system
+  annotations:
+    addon.k3s.cattle.io/rollout: cluster-size
+spec:
+  strategy:
+    type: RollingUpdate
+    rollingUpdate:
+      maxUnavailable: 1
+  selector:
+    matchLabels:
+      k8s-app: kube-dns
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: unannotated
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      app: unannotated
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: traefik
+  namespace: kube-system
+  annotations:
+    addon.k3s.cattle.io/rollout: cluster-size
+spec:
+  set:
+    global.systemDefaultRegistry: ""
+`
+
+func Test_UnitSetRolloutStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		nodes        int
+		wantStrategy map[string]any
+		wantSet      map[string]any
+	}{
+		{
+			name:         "Single node",
+			nodes:        1,
+			wantStrategy: map[string]any{"maxSurge": int64(1), "maxUnavailable": int64(0)},
+			wantSet: map[string]any{
+				"global.systemDefaultRegistry":                "",
+				"updateStrategy.type":                         "RollingUpdate",
+				"updateStrategy.rollingUpdate.maxSurge":       int64(1),
+				"updateStrategy.rollingUpdate.maxUnavailable": int64(0),
+				"podDisruptionBudget.enabled":                 "true",
+				"podDisruptionBudget.maxUnavailable":          int64(1),
+			},
+		},
+		{
+			name:         "Large cluster",
+			nodes:        10,
+			wantStrategy: map[string]any{"maxSurge": "25%", "maxUnavailable": int64(0)},
+			wantSet: map[string]any{
+				"global.systemDefaultRegistry":                "",
+				"updateStrategy.type":                         "RollingUpdate",
+				"updateStrategy.rollingUpdate.maxSurge":       "25%",
+				"updateStrategy.rollingUpdate.maxUnavailable": int64(0),
+				"podDisruptionBudget.enabled":                 "true",
+				"podDisruptionBudget.maxUnavailable":          int64(1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := objectSet([]byte(rolloutManifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := setRolloutStrategy(objects, tt.nodes); err != nil {
+				t.Fatalf("setRolloutStrategy() error = %v", err)
+			}
+
+			var pdbs []*unstructured.Unstructured
+			for _, obj := range objects.All() {
+				u := obj.(*unstructured.Unstructured)
+				switch u.GetKind() {
+				case "PodDisruptionBudget":
+					pdbs = append(pdbs, u)
+				case "Deployment":
+					strategy, _, _ := unstructured.NestedMap(u.Object, "spec", "strategy", "rollingUpdate")
+					if u.GetName() == "unannotated" {
+						if strategy != nil {
+							t.Errorf("unexpected strategy on unannotated Deployment: %+v", strategy)
+						}
+					} else if !reflect.DeepEqual(strategy, tt.wantStrategy) {
+						t.Errorf("Deployment strategy = %+v\nWant = %+v", strategy, tt.wantStrategy)
+					}
+				case "HelmChart":
+					set, _, _ := unstructured.NestedMap(u.Object, "spec", "set")
+					if !reflect.DeepEqual(set, tt.wantSet) {
+						t.Errorf("HelmChart set = %+v\nWant = %+v", set, tt.wantSet)
+					}
+				}
+			}
+
+			if len(pdbs) != 1 {
+				t.Fatalf("expected 1 PodDisruptionBudget, got %d", len(pdbs))
+			}
+			selector, _, _ := unstructured.NestedMap(pdbs[0].Object, "spec", "selector")
+			if want := map[string]any{"matchLabels": map[string]any{"k8s-app": "kube-dns"}}; !reflect.DeepEqual(selector, want) {
+				t.Errorf("PodDisruptionBudget selector = %+v\nWant = %+v", selector, want)
+			}
+		})
+	}
+}
@@ -52,3 +52,15 @@ staging:
 
 	return nil
 }
+
+// Packaged returns true if the manifest at the given path, relative to the manifests directory,
+// is a packaged manifest that is written to disk on startup.
+func Packaged(name string) bool {
+	name = filepath.ToSlash(name)
+	for _, asset := range bd.AssetNames() {
+		if strings.TrimPrefix(asset, "/") == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package dnsautoscaler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// syncInterval is the interval at which the CoreDNS deployment is scaled to match the cluster size
+	syncInterval = 30 * time.Second
+
+	deploymentName = "coredns"
+	containerName  = "coredns"
+)
+
+var (
+	// baseMemoryLimit is the memory limit set in the packaged CoreDNS manifest.
+	baseMemoryLimit = resource.MustParse("170Mi")
+	// memoryStep is the granularity at which memory limits are adjusted, to avoid
+	// restarting CoreDNS every time a node is added or removed.
+	memoryStep = resource.MustParse("32Mi")
+)
+
+// Default returns the default autoscaling parameters, matching the defaults
+// recommended for the cluster-proportional-autoscaler.
+func Default() config.DNSAutoscaler {
+	return config.DNSAutoscaler{
+		CoresPerReplica:           256,
+		NodesPerReplica:           16,
+		MinReplicas:               1,
+		PreventSinglePointFailure: true,
+	}
+}
+
+// ParseParams parses autoscaling parameters from a comma-separated list of key=value pairs.
+// Parameters not specified are left at their default values. A nil DNSAutoscaler is
+// returned if the parameter string is empty.
+func ParseParams(params string) (*config.DNSAutoscaler, error) {
+	if params == "" {
+		return nil, nil
+	}
+	a := Default()
+	if params == "default" {
+		return &a, nil
+	}
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q: must be in key=value format", param)
+		}
+		var err error
+		switch key {
+		case "cores-per-replica":
+			a.CoresPerReplica, err = strconv.ParseFloat(value, 64)
+		case "nodes-per-replica":
+			a.NodesPerReplica, err = strconv.ParseFloat(value, 64)
+		case "min":
+			a.MinReplicas, err = parseInt32(value)
+		case "max":
+			a.MaxReplicas, err = parseInt32(value)
+		case "prevent-single-point-failure":
+			a.PreventSinglePointFailure, err = strconv.ParseBool(value)
+		case "memory-per-node":
+			var q resource.Quantity
+			q, err = resource.ParseQuantity(value)
+			a.MemoryPerNode = q.Value()
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", key)
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid value for parameter %q", key)
+		}
+	}
+	if a.CoresPerReplica < 0 || a.NodesPerReplica < 0 || a.MinReplicas < 0 || a.MaxReplicas < 0 || a.MemoryPerNode < 0 {
+		return nil, errors.New("parameters must not be negative")
+	}
+	if a.CoresPerReplica == 0 && a.NodesPerReplica == 0 {
+		return nil, errors.New("at least one of cores-per-replica or nodes-per-replica must be set")
+	}
+	if a.MaxReplicas > 0 && a.MaxReplicas < a.MinReplicas {
+		return nil, errors.New("max must not be less than min")
+	}
+	return &a, nil
+}
+
+func parseInt32(value string) (int32, error) {
+	i, err := strconv.ParseInt(value, 10, 32)
+	return int32(i), err
+}
+
+// Register starts a goroutine that periodically scales the CoreDNS deployment to match the
+// number of schedulable nodes and cores in the cluster.
+func Register(ctx context.Context, k8s kubernetes.Interface, nodes coreclient.NodeCache, params *config.DNSAutoscaler) {
+	h := &handler{
+		k8s:    k8s,
+		nodes:  nodes,
+		params: params,
+	}
+	logrus.Infof("Starting CoreDNS autoscaler")
+	go wait.UntilWithContext(ctx, h.sync, syncInterval)
+}
+
+type handler struct {
+	k8s    kubernetes.Interface
+	nodes  coreclient.NodeCache
+	params *config.DNSAutoscaler
+}
+
+func (h *handler) sync(ctx context.Context) {
+	nodeList, err := h.nodes.List(labels.Everything())
+	if err != nil {
+		logrus.Errorf("Failed to list nodes for CoreDNS autoscaling: %v", err)
+		return
+	}
+	var nodes int
+	var cores int64
+	for _, node := range nodeList {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		nodes++
+		cores += node.Status.Allocatable.Cpu().Value()
+	}
+	if nodes == 0 {
+		return
+	}
+
+	deployments := h.k8s.AppsV1().Deployments(metav1.NamespaceSystem)
+	deployment, err := deployments.Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to get CoreDNS deployment for autoscaling: %v", err)
+		return
+	}
+
+	replicas := desiredReplicas(h.params, nodes, cores)
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != replicas {
+		scale, err := deployments.GetScale(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			logrus.Errorf("Failed to get CoreDNS deployment scale: %v", err)
+			return
+		}
+		scale.Spec.Replicas = replicas
+		if _, err := deployments.UpdateScale(ctx, deploymentName, scale, metav1.UpdateOptions{}); err != nil {
+			logrus.Errorf("Failed to scale CoreDNS deployment: %v", err)
+			return
+		}
+		logrus.Infof("Scaled CoreDNS to %d replicas for %d nodes with %d cores", replicas, nodes, cores)
+		// Re-get the deployment so that the memory limit update does not conflict with the scale update
+		if deployment, err = deployments.Get(ctx, deploymentName, metav1.GetOptions{}); err != nil {
+			logrus.Errorf("Failed to get CoreDNS deployment for autoscaling: %v", err)
+			return
+		}
+	}
+
+	if h.params.MemoryPerNode > 0 {
+		limit := desiredMemoryLimit(h.params, nodes)
+		if updated := setMemoryLimit(deployment, limit); updated != nil {
+			if _, err := deployments.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				logrus.Errorf("Failed to update CoreDNS memory limit: %v", err)
+				return
+			}
+			logrus.Infof("Set CoreDNS memory limit to %s for %d nodes", limit.String(), nodes)
+		}
+	}
+}
+
+// desiredReplicas returns the number of replicas for the given number of nodes and cores,
+// using the linear mode of the cluster-proportional-autoscaler.
+func desiredReplicas(params *config.DNSAutoscaler, nodes int, cores int64) int32 {
+	var replicas int32
+	if params.CoresPerReplica > 0 {
+		replicas = max(replicas, int32(math.Ceil(float64(cores)/params.CoresPerReplica)))
+	}
+	if params.NodesPerReplica > 0 {
+		replicas = max(replicas, int32(math.Ceil(float64(nodes)/params.NodesPerReplica)))
+	}
+	if params.PreventSinglePointFailure && nodes > 1 {
+		replicas = max(replicas, 2)
+	}
+	replicas = max(replicas, params.MinReplicas)
+	if params.MaxReplicas > 0 {
+		replicas = min(replicas, params.MaxReplicas)
+	}
+	return replicas
+}
+
+// desiredMemoryLimit returns the memory limit for the given number of nodes: the base limit
+// from the packaged manifest, plus the per-node memory, rounded up to the memory step.
+func desiredMemoryLimit(params *config.DNSAutoscaler, nodes int) resource.Quantity {
+	limit := baseMemoryLimit.Value() + params.MemoryPerNode*int64(nodes)
+	step := memoryStep.Value()
+	limit = (limit + step - 1) / step * step
+	return *resource.NewQuantity(limit, resource.BinarySI)
+}
+
+// setMemoryLimit returns a copy of the deployment with the memory limit of the CoreDNS container
+// set to the given value, or nil if the limit is already set to that value.
+func setMemoryLimit(deployment *appsv1.Deployment, limit resource.Quantity) *appsv1.Deployment {
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != containerName {
+			continue
+		}
+		if current, ok := container.Resources.Limits[corev1.ResourceMemory]; ok && current.Cmp(limit) == 0 {
+			return nil
+		}
+		deployment = deployment.DeepCopy()
+		if deployment.Spec.Template.Spec.Containers[i].Resources.Limits == nil {
+			deployment.Spec.Template.Spec.Containers[i].Resources.Limits = corev1.ResourceList{}
+		}
+		deployment.Spec.Template.Spec.Containers[i].Resources.Limits[corev1.ResourceMemory] = limit
+		return deployment
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package dnsautoscaler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_UnitParseParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		want    *config.DNSAutoscaler
+		wantErr bool
+	}{
+		{
+			name:   "Disabled",
+			params: "",
+		},
+		{
+			name:   "Default",
+			params: "default",
+			want: &config.DNSAutoscaler{
+				CoresPerReplica:           256,
+				NodesPerReplica:           16,
+				MinReplicas:               1,
+				PreventSinglePointFailure: true,
+			},
+		},
+		{
+			name:   "Overrides",
+			params: "nodes-per-replica=8, max=5,prevent-single-point-failure=false,memory-per-node=1Mi",
+			want: &config.DNSAutoscaler{
+				CoresPerReplica: 256,
+				NodesPerReplica: 8,
+				MinReplicas:     1,
+				MaxReplicas:     5,
+				MemoryPerNode:   1024 * 1024,
+			},
+		},
+		{
+			name:    "Unknown parameter",
+			params:  "replicas=3",
+			wantErr: true,
+		},
+		{
+			name:    "Max less than min",
+			params:  "min=3,max=2",
+			wantErr: true,
+		},
+		{
+			name:    "No scaling parameters",
+			params:  "cores-per-replica=0,nodes-per-replica=0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseParams(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseParams() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitDesiredReplicas(t *testing.T) {
+	defaults := Default()
+	tests := []struct {
+		name   string
+		params config.DNSAutoscaler
+		nodes  int
+		cores  int64
+		want   int32
+	}{
+		{
+			name:   "Single node",
+			params: defaults,
+			nodes:  1,
+			cores:  4,
+			want:   1,
+		},
+		{
+			name:   "Prevent single point of failure",
+			params: defaults,
+			nodes:  2,
+			cores:  8,
+			want:   2,
+		},
+		{
+			name:   "Scaled by nodes",
+			params: defaults,
+			nodes:  100,
+			cores:  400,
+			want:   7,
+		},
+		{
+			name:   "Scaled by cores",
+			params: defaults,
+			nodes:  10,
+			cores:  1280,
+			want:   5,
+		},
+		{
+			name:   "Limited by max",
+			params: config.DNSAutoscaler{NodesPerReplica: 1, MaxReplicas: 3},
+			nodes:  10,
+			want:   3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := desiredReplicas(&tt.params, tt.nodes, tt.cores); got != tt.want {
+				t.Errorf("desiredReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitDesiredMemoryLimit(t *testing.T) {
+	params := &config.DNSAutoscaler{MemoryPerNode: resource.MustParse("1Mi").Value()}
+	tests := []struct {
+		nodes int
+		want  string
+	}{
+		{nodes: 1, want: "192Mi"},
+		{nodes: 22, want: "192Mi"},
+		{nodes: 23, want: "224Mi"},
+	}
+	for _, tt := range tests {
+		if got := desiredMemoryLimit(params, tt.nodes); got.Cmp(resource.MustParse(tt.want)) != 0 {
+			t.Errorf("desiredMemoryLimit(%d) = %s, want %s", tt.nodes, got.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	alarmCheckInterval   = 30 * time.Second
+	alarmRecoveryTimeout = 5 * time.Minute
+	// alarmRecoveryBackoff is the minimum time between attempts to recover from a NOSPACE alarm,
+	// as compaction and defragmentation of a large datastore are expensive.
+	alarmRecoveryBackoff = 10 * time.Minute
+
+	// defaultQuotaBackendBytes matches the quota set by the embedded etcd executor,
+	// if not overridden by the user.
+	defaultQuotaBackendBytes = int64(8 * 1024 * 1024 * 1024)
+
+	// quotaHeadroomPercent is the percentage of the quota that the datastore may use after
+	// compaction and defragmentation, for the NOSPACE alarm to be cleared without raising the quota.
+	quotaHeadroomPercent = 90
+	// quotaOverrideFactor is the multiplier applied to the configured quota when temporarily raising it.
+	quotaOverrideFactor = 2
+	// alarmCompactRetention is the number of revisions retained when compacting to recover from a NOSPACE
+	// alarm, so that watchers that are slightly behind the head revision do not need to relist.
+	alarmCompactRetention = 1000
+)
+
+// quotaOverrideFile returns the path to the file used to persist a temporarily raised etcd quota.
+func quotaOverrideFile(config *config.Control) string {
+	return filepath.Join(config.DataDir, "db", "quota-backend-bytes-override")
+}
+
+// quotaBackendBytes returns the etcd backend quota configured by the user,
+// or the default quota if not set.
+func quotaBackendBytes(config *config.Control) int64 {
+	quota := defaultQuotaBackendBytes
+	for _, arg := range config.ExtraEtcdArgs {
+		if k, v, ok := strings.Cut(arg, "="); ok && strings.TrimLeft(k, "-") == "quota-backend-bytes" {
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil && i > 0 {
+				quota = i
+			}
+		}
+	}
+	return quota
+}
+
+// quotaOverride returns the temporarily raised etcd backend quota, or 0 if the quota has not been raised.
+func quotaOverride(config *config.Control) int64 {
+	b, err := os.ReadFile(quotaOverrideFile(config))
+	if err != nil {
+		return 0
+	}
+	quota, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil || quota <= quotaBackendBytes(config) {
+		return 0
+	}
+	return quota
+}
+
+// etcdArgs returns the user-provided extra etcd args, with the backend quota
+// replaced by the temporarily raised quota if one is set. The quota that etcd
+// is started with is recorded for use when recovering from NOSPACE alarms.
+func (e *ETCD) etcdArgs() []string {
+	args := e.config.ExtraEtcdArgs
+	e.quota = quotaBackendBytes(e.config)
+	if quota := quotaOverride(e.config); quota > 0 {
+		logrus.Warnf("Starting etcd with temporarily raised quota-backend-bytes=%d", quota)
+		args = append(append([]string{}, args...), "quota-backend-bytes="+strconv.FormatInt(quota, 10))
+		e.quota = quota
+	}
+	return args
+}
+
+// manageAlarms periodically checks for NOSPACE alarms on the local etcd member, and attempts to
+// reclaim space so that the alarm can be cleared without manual intervention.
+func (e *ETCD) manageAlarms(ctx context.Context) {
+	if e.config.EtcdDisableAlarmRecovery {
+		return
+	}
+	var lastRecovery time.Time
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if e.client == nil || time.Since(lastRecovery) < alarmRecoveryBackoff {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, alarmRecoveryTimeout)
+		defer cancel()
+
+		endpoints := getEndpoints(e.config)
+		status, err := e.client.Status(ctx, endpoints[0])
+		if err != nil {
+			logrus.Debugf("Failed to check local etcd status for alarm management: %v", err)
+			return
+		}
+
+		alarmList, err := e.client.AlarmList(ctx)
+		if err != nil {
+			logrus.Errorf("Failed to list etcd alarms: %v", err)
+			return
+		}
+
+		for _, alarm := range alarmList.Alarms {
+			if alarm.MemberID == status.Header.MemberId && alarm.Alarm == etcdserverpb.AlarmType_NOSPACE {
+				lastRecovery = time.Now()
+				if err := e.recoverNoSpace(ctx, endpoints[0], status.Header.MemberId); err != nil {
+					logrus.Errorf("Failed to recover from etcd %s alarm: %v", alarm.Alarm, err)
+				}
+			}
+		}
+	}, alarmCheckInterval)
+}
+
+// recoverNoSpace compacts and defragments the local etcd member in response to a NOSPACE alarm.
+// If enough space was reclaimed, the alarm is cleared. If not, and there is sufficient free disk
+// space, a raised quota is persisted for use the next time etcd is started. Etcd does not support
+// changing the quota at runtime, so the datastore remains read-only until the server is restarted.
+func (e *ETCD) recoverNoSpace(ctx context.Context, endpoint string, memberID uint64) error {
+	logrus.Warnf("Etcd NOSPACE alarm raised on this member; attempting to reclaim space")
+
+	status, err := e.client.Status(ctx, endpoint)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get etcd status")
+	}
+
+	if rev := compactRevision(status.Header.Revision); rev > 0 {
+		logrus.Infof("Compacting etcd to revision %d", rev)
+		if _, err := e.client.Compact(ctx, rev, clientv3.WithCompactPhysical()); err != nil && !errors.Is(err, rpctypes.ErrCompacted) {
+			return errors.WithMessage(err, "failed to compact etcd")
+		}
+	}
+
+	logrus.Infof("Defragmenting etcd, datastore using %d of %d bytes", status.DbSizeInUse, status.DbSize)
+	if _, err := e.client.Defragment(ctx, endpoint); err != nil {
+		return errors.WithMessage(err, "failed to defragment etcd")
+	}
+
+	status, err = e.client.Status(ctx, endpoint)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get etcd status")
+	}
+
+	quota := e.quota
+	if quota == 0 {
+		quota = quotaBackendBytes(e.config)
+	}
+	logrus.Infof("Etcd datastore using %d bytes after compaction and defragmentation, quota is %d bytes", status.DbSize, quota)
+
+	if status.DbSize*100 >= quota*quotaHeadroomPercent {
+		if err := e.raiseQuota(status.DbSize); err != nil {
+			return err
+		}
+		return errors.New("insufficient space reclaimed to clear the alarm; the datastore will remain read-only until " + version.Program + " is restarted with the raised quota")
+	}
+
+	if _, err := e.client.AlarmDisarm(ctx, &clientv3.AlarmMember{MemberID: memberID, Alarm: etcdserverpb.AlarmType_NOSPACE}); err != nil {
+		return errors.WithMessage(err, "failed to disarm alarm")
+	}
+	logrus.Infof("Etcd NOSPACE alarm disarmed successfully")
+	return nil
+}
+
+// compactRevision returns the revision to compact to, retaining alarmCompactRetention revisions
+// before the current revision. Zero is returned if there are not enough revisions to compact.
+func compactRevision(rev int64) int64 {
+	if rev <= alarmCompactRetention {
+		return 0
+	}
+	return rev - alarmCompactRetention
+}
+
+// raiseQuota persists a raised etcd quota, if the disk hosting the etcd datastore has room for
+// the datastore to grow to the new quota.
+func (e *ETCD) raiseQuota(dbSize int64) error {
+	if e.quota > quotaBackendBytes(e.config) {
+		return errors.New("etcd quota has already been raised; keys must be deleted to reclaim space")
+	}
+	if quotaOverride(e.config) > 0 {
+		return errors.New("etcd quota has already been raised; the raised quota will take effect when etcd is restarted")
+	}
+	newQuota := quotaBackendBytes(e.config) * quotaOverrideFactor
+
+	free, err := availableDiskSpace(dbDir(e.config))
+	if err != nil {
+		return errors.WithMessage(err, "failed to check available disk space")
+	}
+	// the datastore may grow to the new quota, and defragmentation may need space for a full copy of the db
+	if need := newQuota - dbSize + newQuota; uint64(need) > free {
+		return errors.New("insufficient free disk space to raise etcd quota")
+	}
+
+	if err := os.WriteFile(quotaOverrideFile(e.config), []byte(strconv.FormatInt(newQuota, 10)), 0600); err != nil {
+		return err
+	}
+	logrus.Warnf("Etcd quota-backend-bytes temporarily raised to %d; restart %s to apply the raised quota. The raised quota will be removed once the datastore size falls below the configured quota", newQuota, version.Program)
+	return nil
+}
+
+// clearQuotaOverride removes a temporarily raised etcd quota, once the datastore has been
+// reduced in size enough to fit comfortably within the configured quota.
+func (e *ETCD) clearQuotaOverride(dbSize int64) {
+	if quotaOverride(e.config) == 0 {
+		return
+	}
+	quota := quotaBackendBytes(e.config)
+	if dbSize*100 >= quota*quotaHeadroomPercent {
+		logrus.Warnf("Etcd datastore using %d bytes, which exceeds the configured quota of %d bytes; temporarily raised quota is still in use", dbSize, quota)
+		return
+	}
+	if err := os.Remove(quotaOverrideFile(e.config)); err != nil && !os.IsNotExist(err) {
+		logrus.Errorf("Failed to remove temporarily raised etcd quota: %v", err)
+		return
+	}
+	logrus.Infof("Etcd datastore fits within the configured quota of %d bytes; temporarily raised quota will be removed when etcd is restarted", quota)
+}
@@ -0,0 +1,81 @@
+package etcd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+)
+
+func Test_UnitQuotaOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		extraArgs []string
+		override  string
+		wantQuota int64
+		wantArgs  []string
+	}{
+		{
+			name:      "default quota",
+			wantQuota: defaultQuotaBackendBytes,
+		},
+		{
+			name:      "user quota",
+			extraArgs: []string{"quota-backend-bytes=1073741824"},
+			wantQuota: 1073741824,
+			wantArgs:  []string{"quota-backend-bytes=1073741824"},
+		},
+		{
+			name:      "raised quota",
+			extraArgs: []string{"--quota-backend-bytes=1073741824"},
+			override:  "2147483648",
+			wantQuota: 2147483648,
+			wantArgs:  []string{"--quota-backend-bytes=1073741824", "quota-backend-bytes=2147483648"},
+		},
+		{
+			name:      "raised quota below configured quota is ignored",
+			extraArgs: []string{"quota-backend-bytes=4294967296"},
+			override:  "2147483648",
+			wantQuota: 4294967296,
+			wantArgs:  []string{"quota-backend-bytes=4294967296"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &ETCD{config: &config.Control{DataDir: t.TempDir(), ExtraEtcdArgs: tt.extraArgs}}
+			if tt.override != "" {
+				if err := os.MkdirAll(filepath.Dir(quotaOverrideFile(e.config)), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(quotaOverrideFile(e.config), []byte(tt.override), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := e.etcdArgs(); !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("etcdArgs() = %v, want %v", got, tt.wantArgs)
+			}
+			if e.quota != tt.wantQuota {
+				t.Errorf("etcdArgs() quota = %d, want %d", e.quota, tt.wantQuota)
+			}
+		})
+	}
+}
+
+func Test_UnitCompactRevision(t *testing.T) {
+	tests := []struct {
+		rev  int64
+		want int64
+	}{
+		{rev: 1, want: 0},
+		{rev: alarmCompactRetention, want: 0},
+		{rev: alarmCompactRetention + 1, want: 1},
+		{rev: 50000, want: 50000 - alarmCompactRetention},
+	}
+	for _, tt := range tests {
+		if got := compactRevision(tt.rev); got != tt.want {
+			t.Errorf("compactRevision(%d) = %d, want %d", tt.rev, got, tt.want)
+		}
+	}
+}
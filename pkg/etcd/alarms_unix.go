@@ -0,0 +1,15 @@
+//go:build !windows
+
+package etcd
+
+import "golang.org/x/sys/unix"
+
+// availableDiskSpace returns the number of bytes available to unprivileged users
+// on the filesystem containing the given path.
+func availableDiskSpace(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
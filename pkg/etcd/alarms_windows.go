@@ -0,0 +1,9 @@
+//go:build windows
+
+package etcd
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+func availableDiskSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupportedPlatform
+}
@@ -0,0 +1,114 @@
+package etcd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/signals"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	errDecommissioned             = errors.New("this server has been decommissioned; the data directory must be removed before it can be started again")
+	errDecommissionOnlyVoter      = errors.New("cannot decommission the only voting etcd member")
+	errDecommissionNotInitialized = errors.New("etcd member name is not set")
+)
+
+// decommissionedFile returns the path to the file that marks this server as decommissioned.
+// The file is written before any other changes are made, so that the server will refuse to
+// start and rejoin the cluster with stale credentials if it is restarted at any point afterwards.
+func decommissionedFile(config *config.Control) string {
+	return filepath.Join(config.DataDir, "db", "decommissioned")
+}
+
+// Decommission permanently removes this server from the cluster: the node password secret is
+// deleted, the Node is deregistered, and the local member is removed from etcd, after moving
+// leadership to another member if necessary. Once the local member has been removed, etcd on
+// this server stops, and the process must be shut down; the data directory is then safe to remove.
+func (e *ETCD) Decommission(ctx context.Context) error {
+	if e.name == "" {
+		return errDecommissionNotInitialized
+	}
+	if e.config.Runtime.K8s == nil {
+		return util.ErrCoreNotReady
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, memberRemovalTimeout)
+	defer cancel()
+
+	members, err := e.client.MemberList(ctx)
+	if err != nil {
+		return err
+	}
+	var otherVoters int
+	for _, member := range members.Members {
+		if member.Name != e.name && !member.IsLearner {
+			otherVoters++
+		}
+	}
+	if otherVoters == 0 {
+		return errDecommissionOnlyVoter
+	}
+
+	if err := os.WriteFile(decommissionedFile(e.config), []byte(time.Now().UTC().Format(time.RFC3339)), 0600); err != nil {
+		return err
+	}
+
+	nodeName := e.config.ServerNodeName
+	lf := logrus.Fields{"node": nodeName, "name": e.name, "address": e.address}
+	logrus.WithFields(lf).Info("Decommissioning server")
+
+	if err := nodepassword.Delete(nodeName); err != nil && !apierrors.IsNotFound(err) {
+		return errors.WithMessage(err, "failed to delete node password secret")
+	}
+	if err := e.config.Runtime.K8s.CoreV1().Nodes().Delete(ctx, nodeName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return errors.WithMessage(err, "failed to delete node")
+	}
+	// The member removal controller may also remove the member in response to the node being deleted;
+	// RemovePeer does not return an error if the member has already been removed.
+	if err := e.RemovePeer(ctx, e.name, e.address, true); err != nil {
+		return errors.WithMessage(err, "failed to remove etcd member")
+	}
+
+	logrus.WithFields(lf).Info("Server decommissioned")
+	return nil
+}
+
+// decommissionHandler handles decommission requests from the CLI. The server shuts down
+// once the response has been sent.
+func (e *ETCD) decommissionHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), rw, req, http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditlog.Annotate(req, "server decommission", "")
+		err := e.Decommission(req.Context())
+		switch {
+		case errors.Is(err, util.ErrCoreNotReady):
+			util.SendError(err, rw, req, http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, errDecommissionOnlyVoter), errors.Is(err, errDecommissionNotInitialized):
+			util.SendError(err, rw, req, http.StatusBadRequest)
+			return
+		case err != nil:
+			util.SendErrorWithID(err, "etcd-decommission", rw, req, http.StatusInternalServerError)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		go signals.RequestShutdown(errors.New(version.Program + " server has been decommissioned"))
+	})
+}
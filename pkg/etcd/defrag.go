@@ -0,0 +1,185 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/cluster/managed"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+const (
+	// defragTimeout is the maximum time allowed to defragment a single member.
+	// Defragmentation blocks all reads and writes to the member, and may take
+	// some time on large datastores.
+	defragTimeout = 5 * time.Minute
+	// defragHealthTimeout is the maximum time to wait for a member to report healthy
+	// after it has been defragmented, before moving on to the next member.
+	defragHealthTimeout = 1 * time.Minute
+)
+
+var errDefragInProgress = errors.New("defragmentation already in progress")
+
+// DefragmentMembers defragments all voting members of the etcd cluster, one at a time.
+// Followers are defragmented first, and the leader last, so that the cluster does not
+// lose its leader while other members are unavailable. Each member must be healthy
+// before it is defragmented, and must return to health before the next member is
+// defragmented; if a member does not return to health, defragmentation is aborted.
+func (e *ETCD) DefragmentMembers(ctx context.Context) (*managed.DefragResult, error) {
+	if e.client == nil {
+		return nil, errors.New("etcd datastore is not started")
+	}
+	if !e.defragMu.TryLock() {
+		return nil, errDefragInProgress
+	}
+	defer e.defragMu.Unlock()
+
+	members, leaderID, err := e.getDefragMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &managed.DefragResult{}
+	for _, member := range members {
+		status := managed.DefragStatus{
+			Name:     member.Name,
+			Endpoint: member.ClientURLs[0],
+			Leader:   member.ID == leaderID,
+		}
+		err := e.defragmentMember(ctx, &status)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		res.Members = append(res.Members, status)
+		if err != nil {
+			return res, errors.WithMessagef(err, "defragmentation of etcd member %s failed", member.Name)
+		}
+	}
+	return res, nil
+}
+
+// getDefragMembers returns the list of voting members with client URLs, ordered so that
+// the leader is last, along with the ID of the current leader.
+func (e *ETCD) getDefragMembers(ctx context.Context) ([]*etcdserverpb.Member, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	status, err := e.getETCDStatus(ctx, getEndpoints(e.config)[0])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := e.client.MemberList(ctx)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get etcd MemberList")
+	}
+
+	var leader *etcdserverpb.Member
+	members := []*etcdserverpb.Member{}
+	for _, member := range resp.Members {
+		if member.IsLearner || len(member.ClientURLs) == 0 {
+			continue
+		}
+		if member.ID == status.Leader {
+			leader = member
+			continue
+		}
+		members = append(members, member)
+	}
+	if leader == nil {
+		return nil, 0, errors.New("failed to find etcd leader in member list")
+	}
+	return append(members, leader), status.Leader, nil
+}
+
+// defragmentMember checks the health of a single member, defragments it, and waits for it
+// to report healthy again. The database size before and after defragmentation are recorded
+// in the provided status.
+func (e *ETCD) defragmentMember(ctx context.Context, status *managed.DefragStatus) error {
+	statusCtx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+	before, err := e.getETCDStatus(statusCtx, status.Endpoint)
+	if err != nil {
+		return err
+	}
+	status.SizeBefore = before.DbSize
+
+	logrus.Infof("Defragmenting etcd member %s, datastore using %d of %d bytes", status.Name, before.DbSizeInUse, before.DbSize)
+	defragCtx, cancel := context.WithTimeout(ctx, defragTimeout)
+	defer cancel()
+	if _, err := e.client.Defragment(defragCtx, status.Endpoint); err != nil {
+		return errors.WithMessage(err, "failed to defragment etcd")
+	}
+
+	healthCtx, cancel := context.WithTimeout(ctx, defragHealthTimeout)
+	defer cancel()
+	for {
+		after, err := e.getETCDStatus(healthCtx, status.Endpoint)
+		if err == nil {
+			status.SizeAfter = after.DbSize
+			logrus.Infof("Defragmented etcd member %s, datastore size reduced from %d to %d bytes", status.Name, status.SizeBefore, status.SizeAfter)
+			return nil
+		}
+		logrus.Debugf("Waiting for etcd member %s to become healthy after defragmentation: %v", status.Name, err)
+		select {
+		case <-healthCtx.Done():
+			return errors.WithMessage(err, "etcd member did not become healthy after defragmentation")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// setDefragFunction schedules rolling defragmentation of the cluster. The job is run on
+// all servers, but only the server hosting the current leader defragments the cluster.
+func (e *ETCD) setDefragFunction(ctx context.Context) {
+	skipJob := cron.SkipIfStillRunning(cronLogger)
+	e.cron.AddJob(e.config.EtcdDefragCron, skipJob(cron.FuncJob(func() {
+		if e.client == nil {
+			return
+		}
+		statusCtx, cancel := context.WithTimeout(ctx, statusTimeout)
+		status, err := e.client.Status(statusCtx, getEndpoints(e.config)[0])
+		cancel()
+		if err != nil {
+			logrus.Errorf("Failed to check local etcd status for scheduled defragmentation: %v", err)
+			return
+		} else if status.Header.MemberId != status.Leader {
+			return
+		}
+		if _, err := e.DefragmentMembers(ctx); err != nil {
+			logrus.Errorf("Failed to run scheduled etcd defragmentation: %v", err)
+		}
+	})))
+}
+
+// defragHandler handles defragmentation requests from the CLI.
+func (e *ETCD) defragHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), rw, req, http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditlog.Annotate(req, "etcd defrag", "")
+		res, err := e.DefragmentMembers(req.Context())
+		if errors.Is(err, errDefragInProgress) {
+			util.SendError(err, rw, req, http.StatusConflict)
+			return
+		} else if err != nil && res == nil {
+			util.SendErrorWithID(err, "etcd-defrag", rw, req, http.StatusInternalServerError)
+			return
+		} else if err != nil {
+			logrus.Errorf("Failed to defragment etcd: %v", err)
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(res)
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -25,6 +26,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/etcd/s3"
 	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/kubeadm"
 	"github.com/k3s-io/k3s/pkg/server/auth"
 	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/util"
@@ -77,6 +79,10 @@ const (
 
 	maxBackupRetention = 5
 
+	// preResetBackupInfix is appended to the etcd data directory name, along with a timestamp, to name the backups
+	// saved before a cluster reset or restore.
+	preResetBackupInfix = "-old-"
+
 	etcdStatusType = v1.NodeConditionType("EtcdIsVoter")
 
 	StatusUnjoined  MemberStatus = "unjoined"
@@ -86,7 +92,9 @@ const (
 )
 
 var (
-	learnerProgressKey = version.Program + "/etcd/learnerProgress"
+	// learnerProgressKey stores progress for all learners. Versions that only tracked a single learner
+	// used a different key, so that servers running different versions do not overwrite each other's progress.
+	learnerProgressKey = version.Program + "/etcd/learnersProgress"
 	// AddressKey will contain the value of api addresses list
 	AddressKey = version.Program + "/apiaddresses"
 
@@ -114,6 +122,8 @@ type ETCD struct {
 	cron       *cron.Cron
 	s3         *s3.Controller
 	snapshotMu *sync.Mutex
+	defragMu   *sync.Mutex
+	quota      int64
 }
 
 type learnerProgress struct {
@@ -121,6 +131,7 @@ type learnerProgress struct {
 	Name             string      `json:"name,omitempty"`
 	RaftAppliedIndex uint64      `json:"raftAppliedIndex,omitempty"`
 	LastProgress     metav1.Time `json:"lastProgress,omitempty"`
+	FirstSeen        metav1.Time `json:"firstSeen,omitempty"`
 }
 
 // Members contains a slice that holds all
@@ -164,6 +175,7 @@ func NewETCD() *ETCD {
 	return &ETCD{
 		cron:       cron.New(cron.WithLogger(cronLogger)),
 		snapshotMu: &sync.Mutex{},
+		defragMu:   &sync.Mutex{},
 	}
 }
 
@@ -226,6 +238,7 @@ func (e *ETCD) Test(ctx context.Context, enableMaintenance bool) error {
 	if err := e.clearAlarms(ctx, status.Header.MemberId); err != nil {
 		return errors.WithMessage(err, "failed to disarm etcd alarms")
 	}
+	e.clearQuotaOverride(status.DbSize)
 
 	members, err := e.client.MemberList(ctx)
 	if err != nil {
@@ -299,6 +312,12 @@ func (e *ETCD) ResetFile() string {
 	return filepath.Join(e.config.DataDir, "db", "reset-flag")
 }
 
+// HasLocalData returns true if the server data directory contains an initialized etcd datastore.
+func HasLocalData(dataDir string) (bool, error) {
+	e := &ETCD{config: &config.Control{DataDir: dataDir}}
+	return e.IsInitialized()
+}
+
 // IsInitialized checks to see if a WAL directory exists. If so, we assume that etcd
 // has already been brought up at least once.
 func (e *ETCD) IsInitialized() (bool, error) {
@@ -386,36 +405,19 @@ func (e *ETCD) Reset(ctx context.Context, wg *sync.WaitGroup, rebootstrap func()
 
 	// If asked to restore from a snapshot, do so
 	if e.config.ClusterResetRestorePath != "" {
-		if e.config.EtcdS3 != nil {
-			logrus.Infof("Retrieving etcd snapshot %s from S3", e.config.ClusterResetRestorePath)
-			s3client, err := e.getS3Client(ctx)
-			if err != nil {
-				if errors.Is(err, s3.ErrNoConfigSecret) {
-					return errors.New("cannot use S3 config secret when restoring snapshot; configuration must be set in CLI or config file")
-				}
-				return errors.WithMessage(err, "failed to initialize S3 client")
-			}
-			dir, err := snapshotDir(e.config, true)
-			if err != nil {
-				return errors.WithMessage(err, "failed to get the snapshot dir")
-			}
-			path, err := s3client.Download(ctx, e.config.ClusterResetRestorePath, dir)
-			if err != nil {
-				return errors.WithMessage(err, "failed to download snapshot from S3")
-			}
-			e.config.ClusterResetRestorePath = path
-			logrus.Infof("S3 download complete for %s", e.config.ClusterResetRestorePath)
-		}
-
-		info, err := os.Stat(e.config.ClusterResetRestorePath)
-		if os.IsNotExist(err) {
-			return fmt.Errorf("etcd: snapshot path does not exist: %s", e.config.ClusterResetRestorePath)
+		location := e.config.ClusterResetRestorePath
+		err := e.restoreSnapshot(ctx)
+		auditlog.RecordLocal(e.config.DataDir, nil, "etcd-snapshot restore", location, err)
+		if err != nil {
+			return err
 		}
-		if info.IsDir() {
-			return fmt.Errorf("etcd: snapshot path must be a file, not a directory: %s", e.config.ClusterResetRestorePath)
+	} else {
+		backupDir, err := backupBeforeReset(dbDir(e.config), false, e.config.ResetBackupRetention)
+		if err != nil {
+			return errors.WithMessage(err, "failed to back up etcd database before reset")
 		}
-		if err := e.Restore(ctx); err != nil {
-			return err
+		if backupDir != "" {
+			logrus.Infof("Pre-reset etcd database saved to %s", backupDir)
 		}
 	}
 
@@ -430,8 +432,48 @@ func (e *ETCD) Reset(ctx context.Context, wg *sync.WaitGroup, rebootstrap func()
 	return e.newCluster(ctx, wg, true)
 }
 
+// restoreSnapshot restores the datastore from the snapshot at the cluster reset restore path,
+// downloading it from S3 first if necessary.
+func (e *ETCD) restoreSnapshot(ctx context.Context) error {
+	if e.config.EtcdS3 != nil {
+		logrus.Infof("Retrieving etcd snapshot %s from S3", e.config.ClusterResetRestorePath)
+		s3client, err := e.getS3Client(ctx)
+		if err != nil {
+			if errors.Is(err, s3.ErrNoConfigSecret) {
+				return errors.New("cannot use S3 config secret when restoring snapshot; configuration must be set in CLI or config file")
+			}
+			return errors.WithMessage(err, "failed to initialize S3 client")
+		}
+		dir, err := snapshotDir(e.config, true)
+		if err != nil {
+			return errors.WithMessage(err, "failed to get the snapshot dir")
+		}
+		path, err := s3client.Download(ctx, e.config.ClusterResetRestorePath, dir)
+		if err != nil {
+			return errors.WithMessage(err, "failed to download snapshot from S3")
+		}
+		e.config.ClusterResetRestorePath = path
+		logrus.Infof("S3 download complete for %s", e.config.ClusterResetRestorePath)
+	}
+
+	info, err := os.Stat(e.config.ClusterResetRestorePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("etcd: snapshot path does not exist: %s", e.config.ClusterResetRestorePath)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("etcd: snapshot path must be a file, not a directory: %s", e.config.ClusterResetRestorePath)
+	}
+	err = e.Restore(ctx)
+	recordRestoreResult(e.config.DataDir, e.config.ClusterResetRestorePath, err)
+	return err
+}
+
 // Start starts the datastore
 func (e *ETCD) Start(ctx context.Context, wg *sync.WaitGroup, clientAccessInfo *clientaccess.Info) error {
+	if _, err := os.Stat(decommissionedFile(e.config)); err == nil {
+		return errDecommissioned
+	}
+
 	isInitialized, err := e.IsInitialized()
 	if err != nil {
 		return errors.WithMessagef(err, "failed to check for initialized etcd datastore")
@@ -443,11 +485,23 @@ func (e *ETCD) Start(ctx context.Context, wg *sync.WaitGroup, clientAccessInfo *
 
 	if !e.config.EtcdDisableSnapshots {
 		e.setSnapshotFunction(ctx)
+	}
+	if e.config.EtcdDefragCron != "" {
+		e.setDefragFunction(ctx)
+	}
+	if !e.config.EtcdDisableSnapshots || e.config.EtcdDefragCron != "" {
 		e.cron.Start()
 	}
 
 	go e.manageLearners(ctx)
+	go e.manageAlarms(ctx)
+	if !e.config.EtcdDisableLeaderMove {
+		go e.transferLeadershipOnShutdown(ctx)
+	}
 	go e.getS3Client(ctx)
+	if e.config.EtcdWitness {
+		go e.manageWitness(ctx)
+	}
 
 	if isInitialized {
 		// check etcd dir permission
@@ -465,10 +519,17 @@ func (e *ETCD) Start(ctx context.Context, wg *sync.WaitGroup, clientAccessInfo *
 		if err != nil {
 			return err
 		}
+		unlock, err := e.lockVersionChange(ctx, clientAccessInfo)
+		if err != nil {
+			return err
+		}
+		go e.manageVersion(ctx, unlock)
 		logrus.Infof("Starting etcd for existing cluster member")
 		return e.cluster(ctx, wg, false, opt)
 	}
 
+	go e.manageVersion(ctx, func() {})
+
 	if clientAccessInfo == nil {
 		return e.newCluster(ctx, wg, false)
 	}
@@ -652,6 +713,13 @@ func (e *ETCD) Register(handler http.Handler) (http.Handler, error) {
 		registerMetadataHandlers(ctx, e)
 	}
 
+	// Snapshot restores are performed by a cluster-reset on the target node, which must be running etcd.
+	if !e.config.DisableETCD {
+		e.config.Runtime.ClusterControllerStarts["etcd-snapshot-restore"] = func(ctx context.Context) {
+			registerRestoreHandlers(ctx, e)
+		}
+	}
+
 	// The apiserver endpoint controller needs to run on a node with a local apiserver,
 	// in order to successfully seed etcd with the endpoint list. The member removal controller
 	// also needs to run on a non-etcd node as to avoid disruption if running on the node that
@@ -731,13 +799,21 @@ func (e *ETCD) handler(next http.Handler) http.Handler {
 	r.NotFoundHandler = next
 
 	ir := r.SubRouter("/db/info")
-	ir.Use(auth.IsLocalOrHasRole(e.config, version.Program+":server"))
+	ir.Use(auth.IsLocalOrHasRole(e.config, version.Program+":server", kubeadm.ServerBootstrapTokenAuthGroup))
 	ir.Handle("/", e.infoHandler())
 
 	sr := r.SubRouter("/db/snapshot")
-	sr.Use(auth.HasRole(e.config, version.Program+":server"))
+	sr.Use(auth.HasRole(e.config, version.Program+":server"), auditlog.Middleware(e.config.DataDir))
 	sr.Handle("/", e.snapshotHandler())
 
+	dr := r.SubRouter("/db/defrag")
+	dr.Use(auth.HasRole(e.config, version.Program+":server"), auditlog.Middleware(e.config.DataDir))
+	dr.Handle("/", e.defragHandler())
+
+	cr := r.SubRouter("/db/decommission")
+	cr.Use(auth.HasRole(e.config, version.Program+":server"), auditlog.Middleware(e.config.DataDir))
+	cr.Handle("/", e.decommissionHandler())
+
 	return r
 }
 
@@ -749,6 +825,14 @@ func (e *ETCD) infoHandler() http.Handler {
 			return
 		}
 
+		// new members are not allowed to join once shutdown has started
+		release, ok := signals.Hold(signals.PhaseJoins)
+		defer release()
+		if !ok {
+			util.SendError(errors.New("server is shutting down"), rw, req, http.StatusServiceUnavailable)
+			return
+		}
+
 		if e.client == nil {
 			util.SendError(errors.New("failed to get etcd MemberList: etcd not started"), rw, req, http.StatusInternalServerError)
 			return
@@ -1051,13 +1135,14 @@ func (e *ETCD) cluster(ctx context.Context, wg *sync.WaitGroup, reset bool, opti
 		Logger:                            "zap",
 		LogOutputs:                        []string{"stderr"},
 		ListenClientHTTPURLs:              e.listenClientHTTPURLs(),
+		MaxLearners:                       e.config.EtcdMaxLearners,
 		SocketOpts: executor.ETCDSocketOpts{
 			ReuseAddress: true,
 			ReusePort:    true,
 		},
 		ExperimentalInitialCorruptCheck:         true,
 		ExperimentalWatchProgressNotifyInterval: e.config.Datastore.NotifyInterval,
-	}, e.config.ExtraEtcdArgs, e.Test)
+	}, e.etcdArgs(), e.Test)
 }
 
 func addPort(address string, offset int) (string, error) {
@@ -1160,10 +1245,11 @@ func (e *ETCD) manageLearners(ctx context.Context) {
 		client := e.client
 
 		endpoints := getEndpoints(e.config)
-		if status, err := client.Status(ctx, endpoints[0]); err != nil {
+		leaderStatus, err := client.Status(ctx, endpoints[0])
+		if err != nil {
 			logrus.Errorf("Failed to check local etcd status for learner management: %v", err)
 			return
-		} else if status.Header.MemberId != status.Leader {
+		} else if leaderStatus.Header.MemberId != leaderStatus.Leader {
 			return
 		}
 
@@ -1192,13 +1278,15 @@ func (e *ETCD) manageLearners(ctx context.Context) {
 			nodesMap[node.Name] = node
 		}
 
+		learners := map[uint64]bool{}
 		for _, member := range members.Members {
 			status := StatusVoter
 			message := ""
 
 			if member.IsLearner {
 				status = StatusLearner
-				if err := e.trackLearnerProgress(ctx, progress, member); err != nil {
+				learners[member.ID] = true
+				if err := e.trackLearnerProgress(ctx, progress, member, leaderStatus.RaftAppliedIndex); err != nil {
 					logrus.Errorf("Failed to track learner progress towards promotion: %v", err)
 				}
 			}
@@ -1236,6 +1324,21 @@ func (e *ETCD) manageLearners(ctx context.Context) {
 				}
 			}
 		}
+
+		// Forget progress for members that have been promoted or removed, and store progress for the rest.
+		// Progress is not stored if there are no learners and nothing to forget.
+		changed := len(learners) > 0
+		for id := range progress {
+			if !learners[id] {
+				delete(progress, id)
+				changed = true
+			}
+		}
+		if changed {
+			if err := e.setLearnerProgress(ctx, progress); err != nil {
+				logrus.Errorf("Failed to store learner progress to etcd: %v", err)
+			}
+		}
 	}, manageTickerTime)
 }
 
@@ -1250,58 +1353,92 @@ func (e *ETCD) getETCDNodes() ([]*v1.Node, error) {
 	return nodes.Cache().List(etcdSelector.AsSelector())
 }
 
-// trackLearnerProcess attempts to promote a learner. If it cannot be promoted, progress through the raft index is tracked.
-// If the learner does not make any progress in a reasonable amount of time, it is evicted from the cluster.
-func (e *ETCD) trackLearnerProgress(ctx context.Context, progress *learnerProgress, member *etcdserverpb.Member) error {
-	// Try to promote it. If it can be promoted, no further tracking is necessary
-	if _, err := e.client.MemberPromote(ctx, member.ID); err != nil {
-		logrus.Debugf("Unable to promote learner %s: %v", member.Name, err)
-	} else {
-		logrus.Infof("Promoted learner %s", member.Name)
-		return nil
-	}
-
+// trackLearnerProgress attempts to promote a learner. If it cannot be promoted, progress through the raft index is tracked.
+// If the learner does not make any progress in a reasonable amount of time, or is not promoted within the configured
+// promotion timeout, it is evicted from the cluster. Promoted and evicted learners are removed from the progress map.
+func (e *ETCD) trackLearnerProgress(ctx context.Context, progress map[uint64]*learnerProgress, member *etcdserverpb.Member, leaderIndex uint64) error {
 	now := time.Now()
 
 	// If this is the first time we've tracked this member's progress, reset stats
-	if progress.Name != member.Name || progress.ID != member.ID {
-		progress.ID = member.ID
-		progress.Name = member.Name
-		progress.RaftAppliedIndex = 0
-		progress.LastProgress.Time = now
+	p := progress[member.ID]
+	if p == nil || p.Name != member.Name {
+		p = &learnerProgress{
+			ID:           member.ID,
+			Name:         member.Name,
+			LastProgress: metav1.Time{Time: now},
+			FirstSeen:    metav1.Time{Time: now},
+		}
+		progress[member.ID] = p
 	}
 
 	// Update progress by retrieving status from the member's first reachable client URL
+	var status *clientv3.StatusResponse
 	for _, ep := range member.ClientURLs {
-		status, err := e.getETCDStatus(ctx, ep)
+		resp, err := e.getETCDStatus(ctx, ep)
 		if err != nil {
 			logrus.Debugf("Failed to get etcd status from learner %s at %s: %v", member.Name, ep, err)
 			continue
 		}
+		status = resp
 
-		if progress.RaftAppliedIndex < status.RaftAppliedIndex {
-			logrus.Debugf("Learner %s has progressed from RaftAppliedIndex %d to %d", progress.Name, progress.RaftAppliedIndex, status.RaftAppliedIndex)
-			progress.RaftAppliedIndex = status.RaftAppliedIndex
-			progress.LastProgress.Time = now
+		if p.RaftAppliedIndex < status.RaftAppliedIndex {
+			logrus.Debugf("Learner %s has progressed from RaftAppliedIndex %d to %d", p.Name, p.RaftAppliedIndex, status.RaftAppliedIndex)
+			p.RaftAppliedIndex = status.RaftAppliedIndex
+			p.LastProgress.Time = now
 		}
 		break
 	}
 
+	// Try to promote it if it meets the promotion criteria. If it can be promoted, no further tracking is necessary
+	if err := learnerPromotable(status, leaderIndex, e.config.EtcdLearnerMaxLag); err != nil {
+		logrus.Debugf("Not promoting learner %s: %v", member.Name, err)
+	} else if _, err := e.client.MemberPromote(ctx, member.ID); err != nil {
+		logrus.Debugf("Unable to promote learner %s: %v", member.Name, err)
+	} else {
+		logrus.Infof("Promoted learner %s", member.Name)
+		delete(progress, member.ID)
+		return nil
+	}
+
 	// Warn if the learner hasn't made any progress
-	if !progress.LastProgress.Time.Equal(now) {
-		logrus.Warnf("Learner %s stalled at RaftAppliedIndex=%d for %s", progress.Name, progress.RaftAppliedIndex, now.Sub(progress.LastProgress.Time).String())
+	if !p.LastProgress.Time.Equal(now) {
+		logrus.Warnf("Learner %s stalled at RaftAppliedIndex=%d for %s", p.Name, p.RaftAppliedIndex, now.Sub(p.LastProgress.Time).String())
 	}
 
 	// See if it's time to evict yet
-	if now.Sub(progress.LastProgress.Time) > learnerMaxStallTime {
+	reason := ""
+	if now.Sub(p.LastProgress.Time) > learnerMaxStallTime {
+		reason = "stalled for " + learnerMaxStallTime.String()
+	} else if timeout := e.config.EtcdLearnerTimeout.Duration; timeout > 0 && now.Sub(p.FirstSeen.Time) > timeout {
+		reason = "not promoted within " + timeout.String()
+	}
+	if reason != "" {
 		if _, err := e.client.MemberRemove(ctx, member.ID); err != nil {
 			return err
 		}
-		logrus.Warnf("Removed learner %s from etcd cluster", member.Name)
-		return nil
+		logrus.Warnf("Removed learner %s from etcd cluster: %s", member.Name, reason)
+		delete(progress, member.ID)
 	}
+	return nil
+}
 
-	return e.setLearnerProgress(ctx, progress)
+// learnerPromotable returns an error if a learner does not meet the promotion criteria. If a maximum lag is set,
+// the learner must report a healthy status, and its applied index must be within the maximum lag of the leader's.
+// If no maximum lag is set, promotion is attempted and etcd's own readiness check decides if it succeeds.
+func learnerPromotable(status *clientv3.StatusResponse, leaderIndex, maxLag uint64) error {
+	if maxLag == 0 {
+		return nil
+	}
+	if status == nil {
+		return errors.New("status unavailable")
+	}
+	if len(status.Errors) > 0 {
+		return fmt.Errorf("unhealthy: %s", strings.Join(status.Errors, ", "))
+	}
+	if status.RaftAppliedIndex+maxLag < leaderIndex {
+		return fmt.Errorf("RaftAppliedIndex %d is more than %d behind leader at %d", status.RaftAppliedIndex, maxLag, leaderIndex)
+	}
+	return nil
 }
 
 func (e *ETCD) getETCDStatus(ctx context.Context, url string) (*clientv3.StatusResponse, error) {
@@ -1380,9 +1517,9 @@ func (e *ETCD) setEtcdStatusCondition(node *v1.Node, memberName string, memberSt
 	return util.SetNodeCondition(e.config.Runtime.Core, node.Name, newCondition)
 }
 
-// getLearnerProgress returns the stored learnerProgress struct as retrieved from etcd
-func (e *ETCD) getLearnerProgress(ctx context.Context) (*learnerProgress, error) {
-	progress := &learnerProgress{}
+// getLearnerProgress returns the stored learnerProgress for each learner, keyed by member ID, as retrieved from etcd
+func (e *ETCD) getLearnerProgress(ctx context.Context) (map[uint64]*learnerProgress, error) {
+	progress := map[uint64]*learnerProgress{}
 
 	value, err := e.client.Get(ctx, learnerProgressKey)
 	if err != nil {
@@ -1393,17 +1530,25 @@ func (e *ETCD) getLearnerProgress(ctx context.Context) (*learnerProgress, error)
 		return progress, nil
 	}
 
-	if err := json.NewDecoder(bytes.NewBuffer(value.Kvs[0].Value)).Decode(progress); err != nil {
+	learners := []*learnerProgress{}
+	if err := json.NewDecoder(bytes.NewBuffer(value.Kvs[0].Value)).Decode(&learners); err != nil {
 		return nil, err
 	}
+	for _, p := range learners {
+		progress[p.ID] = p
+	}
 	return progress, nil
 }
 
-// setLearnerProgress stores the learnerProgress struct to etcd
-func (e *ETCD) setLearnerProgress(ctx context.Context, status *learnerProgress) error {
+// setLearnerProgress stores the learnerProgress for each learner to etcd
+func (e *ETCD) setLearnerProgress(ctx context.Context, progress map[uint64]*learnerProgress) error {
 	w := &bytes.Buffer{}
 
-	if err := json.NewEncoder(w).Encode(status); err != nil {
+	learners := make([]*learnerProgress, 0, len(progress))
+	for _, p := range progress {
+		learners = append(learners, p)
+	}
+	if err := json.NewEncoder(w).Encode(learners); err != nil {
 		return err
 	}
 
@@ -1510,8 +1655,6 @@ func ClientURLs(ctx context.Context, clientAccessInfo *clientaccess.Info, selfIP
 // the given snapshot path. This operation exists upon
 // completion.
 func (e *ETCD) Restore(ctx context.Context) error {
-	// check the old etcd data dir
-	oldDataDir := dbDir(e.config) + "-old-" + strconv.Itoa(int(time.Now().Unix()))
 	if e.config.ClusterResetRestorePath == "" {
 		return errors.New("no etcd restore path was specified")
 	}
@@ -1520,8 +1663,12 @@ func (e *ETCD) Restore(ctx context.Context) error {
 		return err
 	}
 
+	if err := e.checkSnapshotCompatibility(e.config.ClusterResetRestorePath, e.config.ClusterResetRestoreForce); err != nil {
+		return err
+	}
+
 	var restorePath string
-	if strings.HasSuffix(e.config.ClusterResetRestorePath, snapshot.CompressedExtension) {
+	if _, compressed := snapshot.CutCompressedExtension(e.config.ClusterResetRestorePath); compressed {
 		dir, err := snapshotDir(e.config, true)
 		if err != nil {
 			return errors.WithMessage(err, "failed to get the snapshot dir")
@@ -1537,12 +1684,13 @@ func (e *ETCD) Restore(ctx context.Context) error {
 		restorePath = e.config.ClusterResetRestorePath
 	}
 
-	// move the data directory to a temp path
-	if err := os.Rename(dbDir(e.config), oldDataDir); err != nil {
+	// move the data directory to a backup path
+	backupDir, err := backupBeforeReset(dbDir(e.config), true, e.config.ResetBackupRetention)
+	if err != nil {
 		return err
 	}
 
-	logrus.Infof("Pre-restore etcd database moved to %s", oldDataDir)
+	logrus.Infof("Pre-restore etcd database moved to %s", backupDir)
 	return snapshotv3.NewV3(e.client.GetLogger()).Restore(snapshotv3.RestoreConfig{
 		SnapshotPath:   restorePath,
 		Name:           e.name,
@@ -1596,6 +1744,69 @@ func backupDirWithRetention(dir string, maxBackupRetention int) (string, error)
 	return backupDir, nil
 }
 
+// backupBeforeReset saves a timestamped backup of the etcd data directory before a cluster reset or restore, so that
+// the previous state can be recovered if the reset was a mistake. The directory is moved if it is about to be replaced,
+// or copied otherwise. The oldest backups beyond the retention limit are removed. An empty path is returned if there
+// is no data directory to back up.
+func backupBeforeReset(dir string, move bool, retention int) (string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	backupDir := dir + preResetBackupInfix + strconv.Itoa(int(time.Now().Unix()))
+	if move {
+		if err := os.Rename(dir, backupDir); err != nil {
+			return "", err
+		}
+	} else {
+		if err := os.CopyFS(backupDir, os.DirFS(dir)); err != nil {
+			os.RemoveAll(backupDir)
+			return "", err
+		}
+		if err := os.Chmod(backupDir, 0700); err != nil {
+			return "", err
+		}
+	}
+	return backupDir, pruneResetBackups(dir, retention)
+}
+
+// pruneResetBackups removes the oldest pre-reset backups of a directory, retaining at most the requested number.
+func pruneResetBackups(dir string, retention int) error {
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+	prefix := filepath.Base(dir) + preResetBackupInfix
+	type backup struct {
+		name      string
+		timestamp int
+	}
+	var backups []backup
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		if ts, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), prefix)); err == nil {
+			backups = append(backups, backup{name: entry.Name(), timestamp: ts})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp > backups[j].timestamp
+	})
+	for i, b := range backups {
+		if i < retention {
+			continue
+		}
+		logrus.Infof("Removing pre-reset etcd database backup %s", b.name)
+		if err := os.RemoveAll(filepath.Join(filepath.Dir(dir), b.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetAPIServerURLsFromETCD will try to fetch the version.Program/apiaddresses key from etcd
 // and unmarshal it to a list of apiserver endpoints.
 func GetAPIServerURLsFromETCD(ctx context.Context, cfg *config.Control) ([]string, error) {
@@ -0,0 +1,92 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cluster/managed"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// healthKey is read to measure datastore latency. The key does not need to exist; as with
+// etcdctl endpoint health, a linearizable read is sufficient to confirm that the datastore
+// has quorum and is able to serve requests.
+const healthKey = "health"
+
+// DatastoreLatency returns the time taken to complete a linearizable read from the datastore.
+// This works against both embedded etcd and kine, as both expose the etcd KV API.
+func DatastoreLatency(ctx context.Context, control *config.Control) (time.Duration, error) {
+	client, conn, err := getClient(ctx, control)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := client.KV.Get(ctx, healthKey); err != nil {
+		return 0, errors.WithMessage(err, "failed to read from datastore")
+	}
+	return time.Since(start), nil
+}
+
+// MemberHealth returns the health of all members of the etcd cluster. The status of each
+// member is requested directly from its client URL, so that a member that is partitioned
+// from the rest of the cluster is reported as unhealthy.
+func MemberHealth(ctx context.Context, control *config.Control) ([]managed.MemberHealth, error) {
+	client, conn, err := getClient(ctx, control)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	members, err := client.MemberList(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get etcd MemberList")
+	}
+
+	res := []managed.MemberHealth{}
+	for _, member := range members.Members {
+		health := managed.MemberHealth{
+			Name:    member.Name,
+			Learner: member.IsLearner,
+		}
+		if len(member.ClientURLs) == 0 {
+			health.Error = "member has not started"
+		} else {
+			health.Endpoint = member.ClientURLs[0]
+			if err := memberStatus(ctx, control, member.ID, &health); err != nil {
+				health.Error = err.Error()
+			} else {
+				health.Healthy = true
+			}
+		}
+		res = append(res, health)
+	}
+	return res, nil
+}
+
+// memberStatus requests the status of a single member, recording the database size and
+// leadership in the provided health.
+func memberStatus(ctx context.Context, control *config.Control, memberID uint64, health *managed.MemberHealth) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultDialTimeout)
+	defer cancel()
+
+	client, conn, err := getClient(ctx, control, health.Endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	status, err := client.Status(ctx, health.Endpoint)
+	if err != nil {
+		return errors.WithMessage(err, "failed to check etcd member status")
+	}
+	if len(status.Errors) != 0 {
+		return errors.New("etcd member has status errors: " + strings.Join(status.Errors, ","))
+	}
+	health.DBSize = status.DbSize
+	health.Leader = status.Leader == memberID
+	return nil
+}
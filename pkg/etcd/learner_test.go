@@ -0,0 +1,55 @@
+package etcd
+
+import (
+	"testing"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func Test_UnitLearnerPromotable(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      *clientv3.StatusResponse
+		leaderIndex uint64
+		maxLag      uint64
+		wantErr     bool
+	}{
+		{
+			name:        "no max lag",
+			leaderIndex: 1000,
+		},
+		{
+			name:        "status unavailable",
+			leaderIndex: 1000,
+			maxLag:      100,
+			wantErr:     true,
+		},
+		{
+			name:        "unhealthy",
+			status:      &clientv3.StatusResponse{RaftAppliedIndex: 1000, Errors: []string{"NOSPACE"}},
+			leaderIndex: 1000,
+			maxLag:      100,
+			wantErr:     true,
+		},
+		{
+			name:        "within max lag",
+			status:      &clientv3.StatusResponse{RaftAppliedIndex: 900},
+			leaderIndex: 1000,
+			maxLag:      100,
+		},
+		{
+			name:        "too far behind",
+			status:      &clientv3.StatusResponse{RaftAppliedIndex: 899},
+			leaderIndex: 1000,
+			maxLag:      100,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := learnerPromotable(tt.status, tt.leaderIndex, tt.maxLag); (err != nil) != tt.wantErr {
+				t.Errorf("learnerPromotable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
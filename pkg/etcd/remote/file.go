@@ -0,0 +1,82 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fileStore stores snapshots in a local directory, which is expected to be a
+// mounted NFS or SMB share.
+type fileStore struct {
+	root string
+}
+
+// put writes the file to a temporary file in the destination directory and renames it
+// into place, so that partially written snapshots are never visible under their final name.
+func (f *fileStore) put(ctx context.Context, key string, r io.Reader, size int64) error {
+	dest := filepath.Join(f.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, contextReader{ctx: ctx, r: r}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func (f *fileStore) list(ctx context.Context, dir string) ([]object, error) {
+	entries, err := os.ReadDir(filepath.Join(f.root, filepath.FromSlash(dir)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var objects []object
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, object{Name: fi.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return objects, nil
+}
+
+func (f *fileStore) delete(ctx context.Context, key string) error {
+	return os.Remove(filepath.Join(f.root, filepath.FromSlash(key)))
+}
+
+// contextReader wraps a reader so that copies are aborted when the context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,192 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTimeout bounds each operation against the remote target, so that a hung
+// NFS mount or unresponsive WebDAV server does not block snapshots indefinitely.
+const defaultTimeout = 5 * time.Minute
+
+// object is a file stored on the remote target.
+type object struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// store is the minimal set of file operations required to store snapshots on a remote target.
+// Keys are slash-separated paths relative to the root of the target.
+type store interface {
+	put(ctx context.Context, key string, r io.Reader, size int64) error
+	list(ctx context.Context, dir string) ([]object, error)
+	delete(ctx context.Context, key string) error
+}
+
+// Client stores snapshots on a remote file target - either a directory on a mounted
+// NFS or SMB share, or a WebDAV collection. Snapshot metadata is stored alongside the
+// snapshots, and retention is applied the same way as for S3.
+type Client struct {
+	store     store
+	location  string
+	retention int
+}
+
+// NewClient returns a client for the given target, which must be either a file:// URL
+// with an absolute path, or a http:// or https:// WebDAV URL. WebDAV credentials may be
+// provided as URL userinfo, and are used for basic authentication.
+func NewClient(target string, retention int) (*Client, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, errors.New("invalid remote snapshot target: failed to parse URL")
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" || !path.IsAbs(u.Path) {
+			return nil, errors.New("invalid remote snapshot target: file URL must have an absolute path")
+		}
+		return &Client{
+			store:     &fileStore{root: filepath.FromSlash(u.Path)},
+			location:  "file://" + u.Path,
+			retention: retention,
+		}, nil
+	case "http", "https":
+		if u.Host == "" {
+			return nil, errors.New("invalid remote snapshot target: WebDAV URL must have a host")
+		}
+		w := &webdavStore{client: &http.Client{}}
+		if u.User != nil {
+			w.username = u.User.Username()
+			w.password, _ = u.User.Password()
+		}
+		w.base = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/")}
+		return &Client{
+			store:     w,
+			location:  w.base.String(),
+			retention: retention,
+		}, nil
+	}
+	return nil, errors.New("invalid remote snapshot target: must be a file, http, or https URL")
+}
+
+// String returns the location of the target, without credentials.
+func (c *Client) String() string {
+	return c.location
+}
+
+// Upload copies the given snapshot and its metadata, if any, to the remote target.
+func (c *Client) Upload(ctx context.Context, snapshotPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	basename := filepath.Base(snapshotPath)
+	metadata := filepath.Join(filepath.Dir(snapshotPath), "..", snapshot.MetadataDir, basename)
+
+	logrus.Infof("Uploading snapshot to %s/%s", c.location, basename)
+	if err := c.putFile(ctx, basename, snapshotPath); err != nil {
+		return errors.WithMessagef(err, "failed to upload snapshot to %s", c.location)
+	}
+
+	if err := c.putFile(ctx, path.Join(snapshot.MetadataDir, basename), metadata); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("Failed to upload snapshot metadata to %s: %v", c.location, err)
+	}
+	return nil
+}
+
+func (c *Client) putFile(ctx context.Context, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return c.store.put(ctx, key, f, fi.Size())
+}
+
+// SnapshotRetention prunes snapshots with the given prefix from the remote target.
+// The retention count is taken from the client configuration; the max age and tiers
+// are taken from the provided retention policy.
+// Returns a list of pruned snapshot names.
+func (c *Client) SnapshotRetention(ctx context.Context, prefix string, retention snapshot.Retention) ([]string, error) {
+	retention.Count = c.retention
+	if !retention.Enabled() {
+		return nil, nil
+	}
+
+	logrus.Infof("Applying snapshot retention %s to snapshots stored in %s/%s", retention, c.location, prefix)
+
+	listCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	objects, err := c.store.list(listCtx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshotFiles []snapshot.File
+	for _, obj := range objects {
+		if !snapshot.MatchName(prefix, obj.Name) {
+			continue
+		}
+		snapshotFiles = append(snapshotFiles, snapshot.File{
+			Name:      obj.Name,
+			Location:  c.location + "/" + obj.Name,
+			CreatedAt: &metav1.Time{Time: obj.ModTime},
+		})
+	}
+
+	// sort newest-first so we can prune entries that are not retained by the policy
+	sort.Slice(snapshotFiles, func(i, j int) bool {
+		return snapshotFiles[j].CreatedAt.Before(snapshotFiles[i].CreatedAt)
+	})
+
+	deleted := []string{}
+	for _, df := range retention.Prune(time.Now(), snapshotFiles) {
+		logrus.Infof("Removing remote snapshot: %s", df.Location)
+		if err := c.DeleteSnapshot(ctx, df.Name); err != nil && !snapshot.IsNotExist(err) {
+			return deleted, err
+		}
+		deleted = append(deleted, df.Name)
+	}
+
+	return deleted, nil
+}
+
+// DeleteSnapshot deletes the selected snapshot (and its metadata) from the remote target.
+// An error satisfying snapshot.IsNotExist is returned if the snapshot does not exist.
+func (c *Client) DeleteSnapshot(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	err := c.store.delete(ctx, name)
+
+	// try to delete the metadata regardless of whether or not the snapshot existed,
+	// to ensure that things are cleaned up in the case of ephemeral errors.
+	if merr := c.store.delete(ctx, path.Join(snapshot.MetadataDir, name)); merr != nil && !os.IsNotExist(merr) {
+		return merr
+	}
+	return err
+}
+
+// notExist returns an error satisfying os.IsNotExist for the given key.
+func notExist(op, key string) error {
+	return &os.PathError{Op: op, Path: key, Err: os.ErrNotExist}
+}
@@ -0,0 +1,167 @@
+package remote
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// propfindBody requests the properties needed to list snapshots.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// webdavStore stores snapshots in a WebDAV collection.
+type webdavStore struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// multistatus is the subset of the WebDAV PROPFIND response used to list files.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (w *webdavStore) url(key string, collection bool) string {
+	u := *w.base
+	u.Path = path.Join(u.Path, key)
+	if collection {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+func (w *webdavStore) do(ctx context.Context, method, target string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// do not return the url.Error directly, as the URL may contain credentials
+		if uerr, ok := err.(*url.Error); ok {
+			err = fmt.Errorf("%s %s: %w", method, w.base.Host, uerr.Err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// put uploads the file, creating the parent collection if necessary.
+func (w *webdavStore) put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if dir := path.Dir(key); dir != "." {
+		resp, err := w.do(ctx, "MKCOL", w.url(dir, true), nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		// 405 Method Not Allowed is returned if the collection already exists
+		if !isSuccess(resp.StatusCode) && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create collection %s: %s", dir, resp.Status)
+		}
+	}
+
+	resp, err := w.do(ctx, http.MethodPut, w.url(key, false), r, size, http.Header{"Content-Type": []string{"application/octet-stream"}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if !isSuccess(resp.StatusCode) {
+		return fmt.Errorf("failed to upload %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+// list returns the files in the given collection. Child collections are not included.
+func (w *webdavStore) list(ctx context.Context, dir string) ([]object, error) {
+	header := http.Header{
+		"Content-Type": []string{"application/xml"},
+		"Depth":        []string{"1"},
+	}
+	resp, err := w.do(ctx, "PROPFIND", w.url(dir, true), strings.NewReader(propfindBody), int64(len(propfindBody)), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to list %s: %s", w.url(dir, true), resp.Status)
+	}
+
+	ms := multistatus{}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to decode PROPFIND response: %w", err)
+	}
+	return ms.objects(), nil
+}
+
+// objects returns the files listed in the multistatus response.
+func (ms *multistatus) objects() []object {
+	var objects []object
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil || strings.HasSuffix(href, "/") {
+			continue
+		}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.ResourceType.Collection != nil {
+				continue
+			}
+			obj := object{Name: path.Base(href)}
+			obj.Size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			obj.ModTime, _ = http.ParseTime(ps.Prop.LastModified)
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+func (w *webdavStore) delete(ctx context.Context, key string) error {
+	resp, err := w.do(ctx, http.MethodDelete, w.url(key, false), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notExist("delete", key)
+	}
+	if !isSuccess(resp.StatusCode) {
+		return fmt.Errorf("failed to delete %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+func isSuccess(code int) bool {
+	return code >= 200 && code <= 299
+}
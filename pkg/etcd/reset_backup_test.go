@@ -0,0 +1,79 @@
+package etcd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func Test_UnitBackupBeforeReset(t *testing.T) {
+	tests := []struct {
+		name      string
+		move      bool
+		retention int
+		existing  []int
+	}{
+		{
+			name:      "copy without existing backups",
+			retention: 5,
+		},
+		{
+			name:      "copy with backups beyond retention",
+			retention: 2,
+			existing:  []int{100, 300, 200},
+		},
+		{
+			name:      "move with backups beyond retention",
+			move:      true,
+			retention: 1,
+			existing:  []int{100, 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "etcd")
+			if err := os.MkdirAll(filepath.Join(dir, "member", "snap"), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "member", "snap", "db"), []byte("data"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			for _, ts := range tt.existing {
+				if err := os.Mkdir(dir+preResetBackupInfix+strconv.Itoa(ts), 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			backupDir, err := backupBeforeReset(dir, tt.move, tt.retention)
+			if err != nil {
+				t.Fatalf("backupBeforeReset() error = %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(backupDir, "member", "snap", "db")); err != nil || string(data) != "data" {
+				t.Errorf("backup db = %q, %v, want %q", data, err, "data")
+			}
+			if _, err := os.Stat(dir); tt.move != os.IsNotExist(err) {
+				t.Errorf("data dir stat error = %v, move %v", err, tt.move)
+			}
+
+			matches, err := filepath.Glob(dir + preResetBackupInfix + "*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := min(tt.retention, len(tt.existing)+1); len(matches) != want {
+				t.Errorf("got %d backups %v, want %d", len(matches), matches, want)
+			}
+			if !slices.Contains(matches, backupDir) {
+				t.Errorf("backups %v do not include the newest backup %s", matches, backupDir)
+			}
+		})
+	}
+}
+
+func Test_UnitBackupBeforeResetMissing(t *testing.T) {
+	backupDir, err := backupBeforeReset(filepath.Join(t.TempDir(), "etcd"), false, 5)
+	if err != nil || backupDir != "" {
+		t.Errorf("backupBeforeReset() = %q, %v, want no backup", backupDir, err)
+	}
+}
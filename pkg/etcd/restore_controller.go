@@ -0,0 +1,341 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/etcd/s3"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	controllersv1 "github.com/k3s-io/k3s/pkg/generated/controllers/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/signals"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// restoreRequest is written to disk when a restore is requested by an ETCDSnapshotRestore resource.
+// It carries the request across the server restarts required to perform a cluster-reset restore,
+// as the resource itself will be replaced by the contents of the restored datastore.
+type restoreRequest struct {
+	Restore      *k3s.ETCDSnapshotRestore `json:"restore"`
+	SnapshotPath string                   `json:"snapshotPath"`
+	Attempted    bool                     `json:"attempted,omitempty"`
+	Restored     bool                     `json:"restored,omitempty"`
+	Error        string                   `json:"error,omitempty"`
+}
+
+// restoreRequestFile returns the path to etcdDBDir/restore-request.json.
+func restoreRequestFile(dataDir string) string {
+	return filepath.Join(dataDir, "db", "restore-request.json")
+}
+
+// readRestoreRequest returns the restore request, or nil if no restore has been requested.
+func readRestoreRequest(dataDir string) (*restoreRequest, error) {
+	b, err := os.ReadFile(restoreRequestFile(dataDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	req := &restoreRequest{}
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, errors.WithMessage(err, "failed to unmarshal restore request")
+	}
+	return req, nil
+}
+
+func writeRestoreRequest(dataDir string, req *restoreRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	file := restoreRequestFile(dataDir)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0600)
+}
+
+// PendingRestore returns the path of the snapshot to restore, and whether or not the restore should be
+// forced, if a restore has been requested by an ETCDSnapshotRestore resource and not yet attempted.
+// The request is marked as attempted, so that the server starts normally after the restore, even if it fails.
+func PendingRestore(dataDir string) (string, bool, error) {
+	req, err := readRestoreRequest(dataDir)
+	if err != nil || req == nil || req.Attempted {
+		return "", false, err
+	}
+	req.Attempted = true
+	if err := writeRestoreRequest(dataDir, req); err != nil {
+		return "", false, errors.WithMessage(err, "failed to update restore request")
+	}
+	return req.SnapshotPath, req.Restore.Spec.Force, nil
+}
+
+// recordRestoreResult records the result of a cluster-reset restore, if the restore
+// of the given snapshot was requested by an ETCDSnapshotRestore resource.
+func recordRestoreResult(dataDir, snapshotPath string, err error) {
+	req, rerr := readRestoreRequest(dataDir)
+	if rerr != nil || req == nil || !req.Attempted || req.SnapshotPath != snapshotPath {
+		return
+	}
+	req.Restored = err == nil
+	if err != nil {
+		req.Error = err.Error()
+	}
+	if err := writeRestoreRequest(dataDir, req); err != nil {
+		logrus.Errorf("Failed to record result of restore requested by ETCDSnapshotRestore %s: %v", req.Restore.Name, err)
+	}
+}
+
+type etcdSnapshotRestoreHandler struct {
+	ctx       context.Context
+	etcd      *ETCD
+	restores  controllersv1.ETCDSnapshotRestoreController
+	snapshots controllersv1.ETCDSnapshotFileController
+}
+
+func registerRestoreHandlers(ctx context.Context, etcd *ETCD) {
+	restores := etcd.config.Runtime.K3s.K3s().V1().ETCDSnapshotRestore()
+	h := &etcdSnapshotRestoreHandler{
+		ctx:       ctx,
+		etcd:      etcd,
+		restores:  restores,
+		snapshots: etcd.config.Runtime.K3s.K3s().V1().ETCDSnapshotFile(),
+	}
+
+	logrus.Infof("Starting managed etcd snapshot restore controller")
+	restores.OnChange(ctx, "managed-etcd-snapshot-restore-controller", h.sync)
+
+	// Ensure that the result of a restore performed before the server was restarted is recorded,
+	// even if the resource does not exist in the restored datastore.
+	if req, err := readRestoreRequest(etcd.config.DataDir); err != nil {
+		logrus.Errorf("Failed to read restore request: %v", err)
+	} else if req != nil && req.Attempted {
+		restores.Enqueue(req.Restore.Name)
+	}
+}
+
+func (h *etcdSnapshotRestoreHandler) sync(key string, esr *k3s.ETCDSnapshotRestore) (*k3s.ETCDSnapshotRestore, error) {
+	req, err := readRestoreRequest(h.etcd.config.DataDir)
+	if err != nil {
+		return esr, err
+	}
+	if req != nil && req.Attempted && req.Restore.Name == key {
+		return h.complete(req, esr)
+	}
+
+	if esr == nil || !esr.DeletionTimestamp.IsZero() {
+		return esr, nil
+	}
+	switch esr.Status.Phase {
+	case k3s.ETCDSnapshotRestorePhaseSucceeded, k3s.ETCDSnapshotRestorePhaseFailed:
+		return esr, nil
+	}
+
+	esf, err := h.snapshots.Get(esr.Spec.ETCDSnapshotFile, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return h.fail(esr, k3s.ETCDSnapshotRestoreConditionAccepted, "SnapshotNotFound", fmt.Sprintf("ETCDSnapshotFile %s not found", esr.Spec.ETCDSnapshotFile))
+		}
+		return esr, err
+	}
+
+	// Only the target node handles the restore; if no node name is set, the node that took the snapshot is the target.
+	nodeName := esr.Spec.NodeName
+	if nodeName == "" {
+		nodeName = esf.Spec.NodeName
+	}
+	if nodeName != os.Getenv("NODE_NAME") {
+		return esr, nil
+	}
+
+	if req != nil {
+		h.restores.EnqueueAfter(esr.Name, time.Minute)
+		return h.updateStatus(esr, k3s.ETCDSnapshotRestorePhasePending, metav1.Condition{
+			Type:    k3s.ETCDSnapshotRestoreConditionAccepted,
+			Status:  metav1.ConditionFalse,
+			Reason:  "RestoreInProgress",
+			Message: fmt.Sprintf("Waiting for restore requested by ETCDSnapshotRestore %s to complete", req.Restore.Name),
+		})
+	}
+	if esf.Status.ReadyToUse == nil || !*esf.Status.ReadyToUse {
+		return h.fail(esr, k3s.ETCDSnapshotRestoreConditionAccepted, "SnapshotNotReady", fmt.Sprintf("ETCDSnapshotFile %s is not ready to use", esf.Name))
+	}
+
+	now := time.Now()
+	if w := esr.Spec.MaintenanceWindow; w != nil {
+		if w.End != nil && now.After(w.End.Time) {
+			return h.fail(esr, k3s.ETCDSnapshotRestoreConditionAccepted, "MaintenanceWindowExpired", fmt.Sprintf("Maintenance window ended at %s", w.End.Format(time.RFC3339)))
+		}
+		if w.Start != nil && now.Before(w.Start.Time) {
+			h.restores.EnqueueAfter(esr.Name, w.Start.Sub(now))
+			return h.updateStatus(esr, k3s.ETCDSnapshotRestorePhasePending, metav1.Condition{
+				Type:    k3s.ETCDSnapshotRestoreConditionAccepted,
+				Status:  metav1.ConditionTrue,
+				Reason:  "WaitingForMaintenanceWindow",
+				Message: fmt.Sprintf("Restore will start on %s at %s", nodeName, w.Start.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	esr = esr.DeepCopy()
+	esr.Status.SnapshotName = esf.Spec.SnapshotName
+	esr.Status.StartTime = &metav1.Time{Time: now}
+	esr, err = h.updateStatus(esr, k3s.ETCDSnapshotRestorePhasePreparing, metav1.Condition{
+		Type:    k3s.ETCDSnapshotRestoreConditionAccepted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Accepted",
+		Message: fmt.Sprintf("Restore accepted by %s", nodeName),
+	})
+	if err != nil {
+		return esr, err
+	}
+
+	snapshotPath, err := h.prepare(esr, esf)
+	if err != nil {
+		return h.fail(esr, k3s.ETCDSnapshotRestoreConditionPrepared, "PrepareFailed", err.Error())
+	}
+
+	meta.SetStatusCondition(&esr.Status.Conditions, metav1.Condition{
+		Type:               k3s.ETCDSnapshotRestoreConditionPrepared,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Prepared",
+		Message:            fmt.Sprintf("Snapshot %s is available at %s", esf.Spec.SnapshotName, snapshotPath),
+		ObservedGeneration: esr.Generation,
+	})
+	esr, err = h.updateStatus(esr, k3s.ETCDSnapshotRestorePhaseRestoring, metav1.Condition{
+		Type:    k3s.ETCDSnapshotRestoreConditionRestored,
+		Status:  metav1.ConditionUnknown,
+		Reason:  "Restarting",
+		Message: fmt.Sprintf("Restarting %s to restore snapshot %s", nodeName, esf.Spec.SnapshotName),
+	})
+	if err != nil {
+		return esr, err
+	}
+
+	// Write the request to disk and restart; the restore is performed by the server on startup.
+	if err := writeRestoreRequest(h.etcd.config.DataDir, &restoreRequest{Restore: esr, SnapshotPath: snapshotPath}); err != nil {
+		return h.fail(esr, k3s.ETCDSnapshotRestoreConditionRestored, "RestoreFailed", fmt.Sprintf("Failed to write restore request: %v", err))
+	}
+	logrus.Infof("Restarting to restore etcd snapshot %s as requested by ETCDSnapshotRestore %s", esf.Spec.SnapshotName, esr.Name)
+	signals.RequestShutdown(fmt.Errorf("restarting to restore etcd snapshot %s as requested by ETCDSnapshotRestore %s", esf.Spec.SnapshotName, esr.Name))
+	return esr, nil
+}
+
+// prepare ensures that the snapshot is available on local disk, downloading it from S3 if necessary,
+// and checks that the snapshot is compatible with this server. The path to the local snapshot is returned.
+func (h *etcdSnapshotRestoreHandler) prepare(esr *k3s.ETCDSnapshotRestore, esf *k3s.ETCDSnapshotFile) (string, error) {
+	var snapshotPath string
+	if esf.Spec.S3 == nil {
+		snapshotPath = strings.TrimPrefix(esf.Spec.Location, "file://")
+		if _, err := os.Stat(snapshotPath); err != nil {
+			return "", errors.WithMessage(err, "failed to find snapshot")
+		}
+	} else {
+		var s3client *s3.Client
+		var err error
+		if target := esf.Annotations[snapshot.AnnotationS3Target]; target != "" {
+			s3client, err = h.etcd.getS3ClientFor(h.ctx, s3.TargetConfig(target))
+		} else if h.etcd.config.EtcdS3 != nil {
+			s3client, err = h.etcd.getS3Client(h.ctx)
+		} else {
+			err = errors.New("etcd-s3 is not enabled")
+		}
+		if err != nil {
+			return "", errors.WithMessage(err, "failed to initialize S3 client")
+		}
+		dir, err := snapshotDir(h.etcd.config, true)
+		if err != nil {
+			return "", errors.WithMessage(err, "failed to get the snapshot dir")
+		}
+		logrus.Infof("Retrieving etcd snapshot %s from S3 for restore", esf.Spec.SnapshotName)
+		if snapshotPath, err = s3client.Download(h.ctx, esf.Spec.SnapshotName, dir); err != nil {
+			return "", errors.WithMessage(err, "failed to download snapshot from S3")
+		}
+	}
+
+	if err := h.etcd.checkSnapshotCompatibility(snapshotPath, esr.Spec.Force); err != nil {
+		return "", err
+	}
+	return snapshotPath, nil
+}
+
+// complete records the result of a restore performed before the server was restarted, and removes the
+// restore request. The resource is recreated from the request if it does not exist in the restored datastore.
+func (h *etcdSnapshotRestoreHandler) complete(req *restoreRequest, esr *k3s.ETCDSnapshotRestore) (*k3s.ETCDSnapshotRestore, error) {
+	if esr == nil {
+		esr = req.Restore.DeepCopy()
+		esr.ResourceVersion = ""
+		esr.UID = ""
+		status := esr.Status
+		created, err := h.restores.Create(esr)
+		if err != nil {
+			return nil, err
+		}
+		esr = created
+		esr.Status = status
+	} else {
+		esr = esr.DeepCopy()
+		esr.Status = *req.Restore.Status.DeepCopy()
+	}
+
+	phase, condition := k3s.ETCDSnapshotRestorePhaseSucceeded, metav1.Condition{
+		Type:    k3s.ETCDSnapshotRestoreConditionRestored,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Restored",
+		Message: fmt.Sprintf("Snapshot %s has been restored, and %s is now the sole member of the etcd cluster; other server nodes must be reset to rejoin the cluster", esr.Status.SnapshotName, os.Getenv("NODE_NAME")),
+	}
+	if !req.Restored {
+		message := req.Error
+		if message == "" {
+			message = "Restore did not complete; see server logs for details"
+		}
+		phase, condition = k3s.ETCDSnapshotRestorePhaseFailed, metav1.Condition{
+			Type:    k3s.ETCDSnapshotRestoreConditionRestored,
+			Status:  metav1.ConditionFalse,
+			Reason:  "RestoreFailed",
+			Message: message,
+		}
+	}
+	esr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	esr, err := h.updateStatus(esr, phase, condition)
+	if err != nil {
+		return esr, err
+	}
+
+	if err := os.Remove(restoreRequestFile(h.etcd.config.DataDir)); err != nil && !os.IsNotExist(err) {
+		return esr, err
+	}
+	return esr, nil
+}
+
+// fail sets the given condition to false, and marks the restore as failed.
+func (h *etcdSnapshotRestoreHandler) fail(esr *k3s.ETCDSnapshotRestore, conditionType, reason, message string) (*k3s.ETCDSnapshotRestore, error) {
+	logrus.Errorf("ETCDSnapshotRestore %s failed: %s", esr.Name, message)
+	esr = esr.DeepCopy()
+	esr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	return h.updateStatus(esr, k3s.ETCDSnapshotRestorePhaseFailed, metav1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// updateStatus sets the phase and condition, and updates the resource status.
+func (h *etcdSnapshotRestoreHandler) updateStatus(esr *k3s.ETCDSnapshotRestore, phase k3s.ETCDSnapshotRestorePhase, condition metav1.Condition) (*k3s.ETCDSnapshotRestore, error) {
+	esr = esr.DeepCopy()
+	esr.Status.Phase = phase
+	condition.ObservedGeneration = esr.Generation
+	meta.SetStatusCondition(&esr.Status.Conditions, condition)
+	return h.restores.UpdateStatus(esr)
+}
@@ -0,0 +1,88 @@
+package etcd
+
+import (
+	"testing"
+
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitPendingRestore(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *restoreRequest
+		restoreErr   error
+		wantPath     string
+		wantForce    bool
+		wantRestored bool
+		wantError    string
+	}{
+		{
+			name: "no request",
+		},
+		{
+			name: "pending request",
+			request: &restoreRequest{
+				Restore:      &k3s.ETCDSnapshotRestore{ObjectMeta: metav1.ObjectMeta{Name: "restore-1"}, Spec: k3s.ETCDSnapshotRestoreSpec{Force: true}},
+				SnapshotPath: "/snapshots/on-demand-server-1",
+			},
+			wantPath:     "/snapshots/on-demand-server-1",
+			wantForce:    true,
+			wantRestored: true,
+		},
+		{
+			name: "failed restore",
+			request: &restoreRequest{
+				Restore:      &k3s.ETCDSnapshotRestore{ObjectMeta: metav1.ObjectMeta{Name: "restore-1"}},
+				SnapshotPath: "/snapshots/on-demand-server-1",
+			},
+			restoreErr: errors.New("snapshot is corrupt"),
+			wantPath:   "/snapshots/on-demand-server-1",
+			wantError:  "snapshot is corrupt",
+		},
+		{
+			name: "already attempted",
+			request: &restoreRequest{
+				Restore:      &k3s.ETCDSnapshotRestore{ObjectMeta: metav1.ObjectMeta{Name: "restore-1"}},
+				SnapshotPath: "/snapshots/on-demand-server-1",
+				Attempted:    true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if tt.request != nil {
+				if err := writeRestoreRequest(dataDir, tt.request); err != nil {
+					t.Fatalf("writeRestoreRequest() error = %v", err)
+				}
+			}
+
+			path, force, err := PendingRestore(dataDir)
+			if err != nil {
+				t.Fatalf("PendingRestore() error = %v", err)
+			}
+			if path != tt.wantPath || force != tt.wantForce {
+				t.Errorf("PendingRestore() = %q, %v, want %q, %v", path, force, tt.wantPath, tt.wantForce)
+			}
+			if path == "" {
+				return
+			}
+
+			// A second call must not restore the same snapshot again.
+			if path, _, _ := PendingRestore(dataDir); path != "" {
+				t.Errorf("PendingRestore() returned %q for an attempted request", path)
+			}
+
+			recordRestoreResult(dataDir, path, tt.restoreErr)
+			req, err := readRestoreRequest(dataDir)
+			if err != nil {
+				t.Fatalf("readRestoreRequest() error = %v", err)
+			}
+			if req.Restored != tt.wantRestored || req.Error != tt.wantError {
+				t.Errorf("recorded result = %v, %q, want %v, %q", req.Restored, req.Error, tt.wantRestored, tt.wantError)
+			}
+		})
+	}
+}
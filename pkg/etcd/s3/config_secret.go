@@ -45,6 +45,7 @@ func (c *Controller) getConfigFromSecret(secretName string) (*config.EtcdS3, err
 		BucketLookup: string(secret.Data["etcd-s3-bucket-lookup-type"]),
 		Endpoint:     defaultEtcdS3.Endpoint,
 		Folder:       string(secret.Data["etcd-s3-folder"]),
+		KMSKeyID:     string(secret.Data["etcd-s3-kms-key-id"]),
 		Proxy:        string(secret.Data["etcd-s3-proxy"]),
 		Region:       defaultEtcdS3.Region,
 		SecretKey:    string(secret.Data["etcd-s3-secret-key"]),
@@ -80,6 +81,30 @@ func (c *Controller) getConfigFromSecret(secretName string) (*config.EtcdS3, err
 		}
 	}
 
+	if v, ok := secret.Data["etcd-s3-part-size"]; ok {
+		if partSize, err := strconv.ParseUint(string(v), 10, 64); err != nil {
+			logrus.Warnf("Failed to parse etcd-s3-part-size value from S3 config secret %s: %v", secretName, err)
+		} else {
+			etcdS3.PartSize = partSize * 1024 * 1024
+		}
+	}
+
+	if v, ok := secret.Data["etcd-s3-concurrency"]; ok {
+		if concurrency, err := strconv.ParseUint(string(v), 10, 0); err != nil {
+			logrus.Warnf("Failed to parse etcd-s3-concurrency value from S3 config secret %s: %v", secretName, err)
+		} else {
+			etcdS3.Concurrency = uint(concurrency)
+		}
+	}
+
+	if v, ok := secret.Data["etcd-s3-upload-rate-limit"]; ok {
+		if rate, err := strconv.ParseUint(string(v), 10, 64); err != nil {
+			logrus.Warnf("Failed to parse etcd-s3-upload-rate-limit value from S3 config secret %s: %v", secretName, err)
+		} else {
+			etcdS3.UploadRate = rate * 1024
+		}
+	}
+
 	// configure ssl verification, if value can be parsed
 	if v, ok := secret.Data["etcd-s3-skip-ssl-verify"]; ok {
 		if b, err := strconv.ParseBool(string(v)); err != nil {
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+)
+
+// progressInterval is the interval at which snapshot upload progress is reported.
+var progressInterval = 5 * time.Second
+
+// ProgressFunc is called while a snapshot is being uploaded, with a copy of the
+// snapshot File that has the in-progress status and the number of bytes uploaded so far.
+type ProgressFunc func(sf snapshot.File)
+
+// progressReader counts the bytes uploaded by the minio client, which reads from
+// the Progress reader in the PutObjectOptions as each chunk of the object is sent.
+type progressReader struct {
+	uploaded atomic.Int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	p.uploaded.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// reportProgress calls the progress func with the number of bytes uploaded
+// every progressInterval, until the stop channel is closed.
+func reportProgress(sf snapshot.File, p *progressReader, progress ProgressFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sf.BytesUploaded = p.uploaded.Load()
+			progress(sf)
+		}
+	}
+}
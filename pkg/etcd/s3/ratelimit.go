@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rateLimitedReader limits the rate at which data can be read from the underlying reader.
+// Reads are split into chunks of at most a tenth of the per-second rate, and the reader
+// sleeps after each read until the average rate since the start is no more than the limit.
+type rateLimitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  uint64
+	start time.Time
+	read  uint64
+}
+
+// newRateLimitedReader returns a reader that reads from r at no more than rate bytes per second.
+func newRateLimitedReader(ctx context.Context, r io.Reader, rate uint64) *rateLimitedReader {
+	return &rateLimitedReader{
+		ctx:   ctx,
+		r:     r,
+		rate:  rate,
+		start: time.Now(),
+	}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if chunk := int(l.rate/10) + 1; len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := l.r.Read(p)
+	l.read += uint64(n)
+
+	allowed := time.Duration(float64(l.read) / float64(l.rate) * float64(time.Second))
+	if wait := allowed - time.Since(l.start); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-l.ctx.Done():
+			return n, l.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+// transferTime returns the minimum time required to transfer size bytes at the given rate.
+func transferTime(size int64, rate uint64) time.Duration {
+	if rate == 0 || size <= 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / float64(rate) * float64(time.Second))
+}
@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_UnitRateLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte("k3s"), 1000)
+	rate := uint64(10000)
+
+	start := time.Now()
+	got, err := io.ReadAll(newRateLimitedReader(context.Background(), bytes.NewReader(data), rate))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadAll() returned %d bytes, want %d", len(got), len(data))
+	}
+	if elapsed, want := time.Since(start), transferTime(int64(len(data)), rate); elapsed < want {
+		t.Errorf("ReadAll() took %v, want at least %v", elapsed, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.ReadAll(newRateLimitedReader(ctx, bytes.NewReader(data), rate)); err != context.Canceled {
+		t.Errorf("ReadAll() with canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_UnitTransferTime(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		rate uint64
+		want time.Duration
+	}{
+		{name: "unlimited", size: 1024, rate: 0, want: 0},
+		{name: "empty", size: 0, rate: 1024, want: 0},
+		{name: "one second", size: 1024, rate: 1024, want: time.Second},
+		{name: "ten megabytes at one megabyte per second", size: 10 * 1024 * 1024, rate: 1024 * 1024, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transferTime(tt.size, tt.rate); got != tt.want {
+				t.Errorf("transferTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/rancher/wrangler/pkg/generated/controllers/core"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
@@ -38,8 +39,13 @@ var (
 	clusterIDKey = textproto.CanonicalMIMEHeaderKey(version.Program + "-cluster-id")
 	tokenHashKey = textproto.CanonicalMIMEHeaderKey(version.Program + "-token-hash")
 	nodeNameKey  = textproto.CanonicalMIMEHeaderKey(version.Program + "-node-name")
+	checksumKey  = textproto.CanonicalMIMEHeaderKey(version.Program + "-checksum")
 )
 
+// defaultUploadConcurrency is the number of parts of a multipart upload that are
+// sent concurrently, if not set by the user.
+const defaultUploadConcurrency = 2
+
 var defaultEtcdS3 = &config.EtcdS3{
 	Endpoint: "s3.amazonaws.com",
 	Region:   "us-east-1",
@@ -49,6 +55,15 @@ var defaultEtcdS3 = &config.EtcdS3{
 	Retention: 5,
 }
 
+// TargetConfig returns the configuration for an additional S3 target, which is
+// loaded from the named secret when a client is requested.
+func TargetConfig(secretName string) *config.EtcdS3 {
+	etcdS3 := *defaultEtcdS3
+	etcdS3.ConfigSecret = secretName
+	etcdS3.Target = secretName
+	return &etcdS3
+}
+
 var (
 	controller *Controller
 	cErr       error
@@ -131,6 +146,8 @@ func (c *Controller) GetClient(ctx context.Context, etcdS3 *config.EtcdS3) (*Cli
 	defaultEtcdS3.ConfigSecret = etcdS3.ConfigSecret
 	// also ignore retention, as it may have been defaulted from the etcd-snapshot-retention flag.
 	defaultEtcdS3.Retention = etcdS3.Retention
+	// also ignore the target name, as additional targets are always configured from a secret.
+	defaultEtcdS3.Target = etcdS3.Target
 
 	// If config is default, try to load config from secret, and fail if it cannot be retrieved or if the secret name is not set.
 	// If config is not default, and secret name is set, warn that the secret is being ignored
@@ -142,6 +159,7 @@ func (c *Controller) GetClient(ctx context.Context, etcdS3 *config.EtcdS3) (*Cli
 				return nil, errors.WithMessagef(err, "failed to get config from etcd-s3-config-secret %q", etcdS3.ConfigSecret)
 			}
 			logrus.Infof("Using etcd s3 configuration from etcd-s3-config-secret %q", etcdS3.ConfigSecret)
+			e.Target = etcdS3.Target
 			etcdS3 = e
 		} else {
 			logrus.Warnf("Ignoring s3 configuration from etcd-s3-config-secret %q due to existing configuration from CLI or config file", etcdS3.ConfigSecret)
@@ -261,13 +279,15 @@ func (c *Controller) GetClient(ctx context.Context, etcdS3 *config.EtcdS3) (*Cli
 }
 
 // upload uploads the given snapshot to the configured S3
-// compatible backend.
-func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata *v1.ConfigMap, now time.Time) (*snapshot.File, error) {
+// compatible backend. If a progress func is provided, it is called before
+// the upload starts, and periodically until the upload completes.
+func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata *v1.ConfigMap, now time.Time, progress ProgressFunc) (*snapshot.File, error) {
 	basename := filepath.Base(snapshotPath)
 	metadata := filepath.Join(filepath.Dir(snapshotPath), "..", snapshot.MetadataDir, basename)
 	snapshotKey := path.Join(c.etcdS3.Folder, basename)
 	metadataKey := path.Join(c.etcdS3.Folder, snapshot.MetadataDir, basename)
 
+	_, compressed := snapshot.CutCompressedExtension(basename)
 	sf := &snapshot.File{
 		Name:     basename,
 		Location: fmt.Sprintf("s3://%s/%s", c.etcdS3.Bucket, snapshotKey),
@@ -276,20 +296,47 @@ func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata
 			Time: now,
 		},
 		S3:             &snapshot.S3Config{EtcdS3: *c.etcdS3},
-		Compressed:     strings.HasSuffix(snapshotPath, snapshot.CompressedExtension),
+		Compressed:     compressed,
 		MetadataSource: extraMetadata,
 		NodeSource:     c.controller.nodeName,
 	}
 
+	checksum := snapshot.ReadChecksum(snapshotPath)
+
+	var uploaded *progressReader
+	if progress != nil {
+		sf.Status = snapshot.InProgressStatus
+		progress(*sf)
+
+		uploaded = &progressReader{}
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func(sf snapshot.File) {
+			defer wg.Done()
+			reportProgress(sf, uploaded, progress, stop)
+		}(*sf)
+		// ensure that no progress is reported after the upload has completed
+		defer func() {
+			close(stop)
+			wg.Wait()
+		}()
+	}
+
 	logrus.Infof("Uploading snapshot to s3://%s/%s", c.etcdS3.Bucket, snapshotKey)
-	uploadInfo, err := c.uploadSnapshot(ctx, snapshotKey, snapshotPath)
+	uploadInfo, err := c.uploadSnapshot(ctx, snapshotKey, snapshotPath, checksum, uploaded)
 	if err != nil {
 		sf.Status = snapshot.FailedStatus
+		sf.Reason = snapshot.ReasonUploadFailed
 		sf.Message = base64.StdEncoding.EncodeToString([]byte(err.Error()))
+		if uploaded != nil {
+			sf.BytesUploaded = uploaded.uploaded.Load()
+		}
 	} else {
 		sf.Status = snapshot.SuccessfulStatus
 		sf.Size = uploadInfo.Size
 		sf.TokenHash = c.controller.tokenHash
+		sf.Checksum = checksum
 	}
 	if uploadInfo, err := c.uploadSnapshotMetadata(ctx, metadataKey, metadata); err != nil {
 		logrus.Warnf("Failed to upload snapshot metadata to S3: %v", err)
@@ -300,23 +347,50 @@ func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata
 }
 
 // uploadSnapshot uploads the snapshot file to S3 using the minio API.
-func (c *Client) uploadSnapshot(ctx context.Context, key, path string) (info minio.UploadInfo, err error) {
-	opts := minio.PutObjectOptions{
-		NumThreads: 2,
-		UserMetadata: map[string]string{
-			clusterIDKey: c.controller.clusterID,
-			nodeNameKey:  c.controller.nodeName,
-			tokenHashKey: c.controller.tokenHash,
-		},
+// The checksum, if set, is stored in the object's user metadata.
+// The number of bytes uploaded is counted by the progress reader, if set.
+func (c *Client) uploadSnapshot(ctx context.Context, key, path, checksum string, uploaded *progressReader) (info minio.UploadInfo, err error) {
+	var contentType string
+	switch {
+	case strings.HasSuffix(key, snapshot.CompressedExtension):
+		contentType = "application/zip"
+	case strings.HasSuffix(key, snapshot.ZstdCompressedExtension):
+		contentType = "application/zstd"
+	default:
+		contentType = "application/octet-stream"
+	}
+	opts, err := c.putObjectOptions(contentType)
+	if err != nil {
+		return minio.UploadInfo{}, err
 	}
-	if strings.HasSuffix(key, snapshot.CompressedExtension) {
-		opts.ContentType = "application/zip"
-	} else {
-		opts.ContentType = "application/octet-stream"
+	if checksum != "" {
+		opts.UserMetadata[checksumKey] = checksum
 	}
-	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	if uploaded != nil {
+		opts.Progress = uploaded
+	}
+	if c.etcdS3.UploadRate == 0 {
+		ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+		defer cancel()
+		return c.mc.FPutObject(ctx, c.etcdS3.Bucket, key, path, opts)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	// extend the timeout by the time required to upload the snapshot at the limited rate
+	timeout := c.etcdS3.Timeout.Duration + transferTime(fi.Size(), c.etcdS3.UploadRate)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	return c.mc.FPutObject(ctx, c.etcdS3.Bucket, key, path, opts)
+	logrus.Infof("Limiting snapshot upload rate to %d KiB/s", c.etcdS3.UploadRate/1024)
+	return c.mc.PutObject(ctx, c.etcdS3.Bucket, key, newRateLimitedReader(ctx, f, c.etcdS3.UploadRate), fi.Size(), opts)
 }
 
 // uploadSnapshotMetadata marshals and uploads the snapshot metadata to S3 using the minio API.
@@ -329,22 +403,45 @@ func (c *Client) uploadSnapshotMetadata(ctx context.Context, key, path string) (
 		return minio.UploadInfo{}, err
 	}
 
+	opts, err := c.putObjectOptions("application/json")
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+	return c.mc.FPutObject(ctx, c.etcdS3.Bucket, key, path, opts)
+}
+
+// putObjectOptions returns the options used to upload objects to S3, including the
+// multipart upload part size and concurrency, and server-side encryption settings.
+func (c *Client) putObjectOptions(contentType string) (minio.PutObjectOptions, error) {
 	opts := minio.PutObjectOptions{
-		NumThreads:  2,
-		ContentType: "application/json",
+		NumThreads:  defaultUploadConcurrency,
+		PartSize:    c.etcdS3.PartSize,
+		ContentType: contentType,
 		UserMetadata: map[string]string{
 			clusterIDKey: c.controller.clusterID,
 			nodeNameKey:  c.controller.nodeName,
 			tokenHashKey: c.controller.tokenHash,
 		},
 	}
-	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
-	defer cancel()
-	return c.mc.FPutObject(ctx, c.etcdS3.Bucket, key, path, opts)
+	if c.etcdS3.Concurrency > 0 {
+		opts.NumThreads = c.etcdS3.Concurrency
+	}
+	if c.etcdS3.KMSKeyID != "" {
+		sse, err := encrypt.NewSSEKMS(c.etcdS3.KMSKeyID, nil)
+		if err != nil {
+			return opts, errors.WithMessage(err, "failed to configure SSE-KMS encryption")
+		}
+		opts.ServerSideEncryption = sse
+	}
+	return opts, nil
 }
 
 // Download downloads the given snapshot from the configured S3
-// compatible backend. If the file is successfully downloaded, it returns
+// compatible backend. If a checksum was recorded when the snapshot was uploaded,
+// the downloaded file is verified against it, and the checksum is stored alongside
+// the snapshot. If the file is successfully downloaded and verified, it returns
 // the path the file was downloaded to.
 func (c *Client) Download(ctx context.Context, snapshotName, snapshotDir string) (string, error) {
 	snapshotKey := path.Join(c.etcdS3.Folder, snapshotName)
@@ -352,9 +449,20 @@ func (c *Client) Download(ctx context.Context, snapshotName, snapshotDir string)
 	snapshotFile := filepath.Join(snapshotDir, snapshotName)
 	metadataFile := filepath.Join(snapshotDir, "..", snapshot.MetadataDir, snapshotName)
 
+	statCtx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+	info, err := c.mc.StatObject(statCtx, c.etcdS3.Bucket, snapshotKey, minio.StatObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+
 	if err := c.downloadSnapshot(ctx, snapshotKey, snapshotFile); err != nil {
 		return "", err
 	}
+	if err := verifyDownload(snapshotFile, info.UserMetadata[checksumKey]); err != nil {
+		os.Remove(snapshotFile)
+		return "", err
+	}
 	if err := c.downloadSnapshotMetadata(ctx, metadataKey, metadataFile); err != nil {
 		return "", err
 	}
@@ -362,6 +470,49 @@ func (c *Client) Download(ctx context.Context, snapshotName, snapshotDir string)
 	return snapshotFile, nil
 }
 
+// verifyDownload compares the checksum of a downloaded snapshot against the checksum recorded
+// when it was uploaded, and stores the checksum for the local copy of the snapshot.
+// Snapshots uploaded by old releases do not have a recorded checksum, and cannot be verified.
+func verifyDownload(snapshotFile, checksum string) error {
+	name := filepath.Base(snapshotFile)
+	if checksum == "" {
+		logrus.Warnf("No checksum was recorded when snapshot %s was uploaded; the downloaded file has not been verified", name)
+		return nil
+	}
+	downloaded, err := snapshot.ComputeChecksum(snapshotFile)
+	if err != nil {
+		return errors.WithMessage(err, "failed to compute checksum of downloaded snapshot")
+	}
+	if downloaded != checksum {
+		return fmt.Errorf("checksum of downloaded snapshot %s does not match: expected %s, got %s", name, checksum, downloaded)
+	}
+	logrus.Infof("Verified checksum %s for snapshot %s", checksum, name)
+	if err := snapshot.WriteChecksum(snapshotFile, checksum); err != nil {
+		logrus.Warnf("Failed to save local snapshot checksum: %v", err)
+	}
+	return nil
+}
+
+// DownloadFile downloads the given snapshot from the configured S3 compatible
+// backend to the given file, without any snapshot metadata. The checksum recorded
+// when the snapshot was uploaded is returned, or an empty string if no checksum
+// was recorded.
+func (c *Client) DownloadFile(ctx context.Context, snapshotName, file string) (string, error) {
+	snapshotKey := path.Join(c.etcdS3.Folder, snapshotName)
+
+	statCtx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+	info, err := c.mc.StatObject(statCtx, c.etcdS3.Bucket, snapshotKey, minio.StatObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.downloadSnapshot(ctx, snapshotKey, file); err != nil {
+		return "", err
+	}
+	return info.UserMetadata[checksumKey], nil
+}
+
 // downloadSnapshot downloads the snapshot file from S3 using the minio API.
 func (c *Client) downloadSnapshot(ctx context.Context, key, file string) error {
 	logrus.Debugf("Downloading snapshot from s3://%s/%s", c.etcdS3.Bucket, key)
@@ -387,21 +538,21 @@ func (c *Client) downloadSnapshotMetadata(ctx context.Context, key, file string)
 
 // SnapshotRetention prunes snapshots in the configured S3 compatible backend for this specific node.
 // Returns a list of pruned snapshot names.
-func (c *Client) SnapshotRetention(ctx context.Context, prefix string) ([]string, error) {
-	if c.etcdS3.Retention < 1 {
+func (c *Client) SnapshotRetention(ctx context.Context, prefix string, retention snapshot.Retention) ([]string, error) {
+	retention.Count = c.etcdS3.Retention
+	if !retention.Enabled() {
 		return nil, nil
 	}
 
-	prefix = path.Join(c.etcdS3.Folder, prefix)
-	logrus.Infof("Applying snapshot retention=%d to snapshots stored in s3://%s/%s", c.etcdS3.Retention, c.etcdS3.Bucket, prefix)
+	logrus.Infof("Applying snapshot retention %s to snapshots stored in s3://%s/%s", retention, c.etcdS3.Bucket, path.Join(c.etcdS3.Folder, prefix))
 
-	var snapshotFiles []minio.ObjectInfo
+	var snapshotFiles []snapshot.File
 
 	toCtx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
 	defer cancel()
 
 	opts := minio.ListObjectsOptions{
-		Prefix:    prefix,
+		Prefix:    path.Join(c.etcdS3.Folder, snapshot.LiteralPrefix(prefix)),
 		Recursive: true,
 	}
 	for info := range c.mc.ListObjects(toCtx, c.etcdS3.Bucket, opts) {
@@ -409,28 +560,33 @@ func (c *Client) SnapshotRetention(ctx context.Context, prefix string) ([]string
 			return nil, info.Err
 		}
 
-		// skip metadata
-		if path.Base(path.Dir(info.Key)) == snapshot.MetadataDir {
+		// skip metadata, and snapshots that only match the literal portion of a wildcard prefix
+		if path.Base(path.Dir(info.Key)) == snapshot.MetadataDir || !snapshot.MatchName(prefix, path.Base(info.Key)) {
 			continue
 		}
 
-		snapshotFiles = append(snapshotFiles, info)
+		snapshotFiles = append(snapshotFiles, snapshot.File{
+			Name:      path.Base(info.Key),
+			Location:  info.Key,
+			CreatedAt: &metav1.Time{Time: info.LastModified},
+		})
 	}
 
-	if len(snapshotFiles) <= c.etcdS3.Retention {
-		return nil, nil
-	}
-
-	// sort newest-first so we can prune entries past the retention count
+	// sort newest-first so we can prune entries that are not retained by the policy
 	sort.Slice(snapshotFiles, func(i, j int) bool {
-		return snapshotFiles[j].LastModified.Before(snapshotFiles[i].LastModified)
+		return snapshotFiles[j].CreatedAt.Before(snapshotFiles[i].CreatedAt)
 	})
 
+	pruned := retention.Prune(time.Now(), snapshotFiles)
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
 	deleted := []string{}
-	for _, df := range snapshotFiles[c.etcdS3.Retention:] {
-		logrus.Infof("Removing S3 snapshot: s3://%s/%s", c.etcdS3.Bucket, df.Key)
+	for _, df := range pruned {
+		logrus.Infof("Removing S3 snapshot: s3://%s/%s", c.etcdS3.Bucket, df.Location)
 
-		key := path.Base(df.Key)
+		key := df.Name
 		if err := c.DeleteSnapshot(ctx, key); err != nil && !snapshot.IsNotExist(err) {
 			return deleted, err
 		}
@@ -506,7 +662,7 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 			continue
 		}
 
-		basename, compressed := strings.CutSuffix(filename, snapshot.CompressedExtension)
+		basename, compressed := snapshot.CutCompressedExtension(filename)
 		ts, err := strconv.ParseInt(basename[strings.LastIndexByte(basename, '-')+1:], 10, 64)
 		if err != nil {
 			ts = obj.LastModified.Unix()
@@ -525,6 +681,7 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 			Compressed: compressed,
 			NodeSource: obj.UserMetadata[nodeNameKey],
 			TokenHash:  obj.UserMetadata[tokenHashKey],
+			Checksum:   obj.UserMetadata[checksumKey],
 		}
 		sfKey := sf.GenerateConfigMapKey()
 		snapshots[sfKey] = sf
@@ -532,7 +689,7 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 
 	for _, metadataKey := range metadatas {
 		filename := path.Base(metadataKey)
-		dsf := &snapshot.File{Name: filename, NodeName: "s3"}
+		dsf := &snapshot.File{Name: filename, NodeName: "s3", S3: &snapshot.S3Config{EtcdS3: *c.etcdS3}}
 		sfKey := dsf.GenerateConfigMapKey()
 		if sf, ok := snapshots[sfKey]; ok {
 			logrus.Debugf("Loading snapshot metadata from s3://%s/%s", c.etcdS3.Bucket, metadataKey)
@@ -2,6 +2,8 @@ package s3
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
@@ -750,6 +752,51 @@ func Test_UnitClientUpload(t *testing.T) {
 				now:           time.Now(),
 			},
 		},
+		{
+			name: "Successful Upload with Multipart Options",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey:   "test",
+					Bucket:      "testbucket",
+					Endpoint:    listenerAddr,
+					Insecure:    true,
+					Region:      defaultEtcdS3.Region,
+					Retention:   defaultEtcdS3.Retention,
+					PartSize:    5 * 1024 * 1024,
+					Concurrency: 4,
+					Timeout:     *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:           ctx,
+				snapshotPath:  snapshotPath,
+				extraMetadata: &v1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+				now:           time.Now(),
+			},
+		},
+		{
+			name: "Successful Upload with Rate Limit",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey:  "test",
+					Bucket:     "testbucket",
+					Endpoint:   listenerAddr,
+					Insecure:   true,
+					Region:     defaultEtcdS3.Region,
+					Retention:  defaultEtcdS3.Retention,
+					UploadRate: 1024 * 1024,
+					Timeout:    *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:           ctx,
+				snapshotPath:  snapshotPath,
+				extraMetadata: &v1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+				now:           time.Now(),
+			},
+		},
 		{
 			name: "Fails Upload to Nonexistent Bucket",
 			fields: fields{
@@ -805,7 +852,7 @@ func Test_UnitClientUpload(t *testing.T) {
 				}
 				return
 			}
-			got, err := c.Upload(tt.args.ctx, tt.args.snapshotPath, tt.args.extraMetadata, tt.args.now)
+			got, err := c.Upload(tt.args.ctx, tt.args.snapshotPath, tt.args.extraMetadata, tt.args.now, nil)
 			t.Logf("Got File=%#v err=%v", got, err)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.Upload() error = %v, wantErr %v", err, tt.wantErr)
@@ -956,6 +1003,48 @@ func Test_UnitClientDownload(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Verified Download",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "testbucket",
+					Endpoint:  listenerAddr,
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Retention: defaultEtcdS3.Retention,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:          ctx,
+				snapshotName: "snapshot-verified-01",
+				snapshotDir:  snapshotDir,
+			},
+			want: filepath.Join(snapshotDir, "snapshot-verified-01"),
+		},
+		{
+			name: "Checksum Mismatch",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "testbucket",
+					Endpoint:  listenerAddr,
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Retention: defaultEtcdS3.Retention,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:          ctx,
+				snapshotName: "snapshot-mismatch-01",
+				snapshotDir:  snapshotDir,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1286,8 +1375,9 @@ func Test_UnitClientSnapshotRetention(t *testing.T) {
 		controller *Controller
 	}
 	type args struct {
-		ctx    context.Context
-		prefix string
+		ctx       context.Context
+		prefix    string
+		retention snapshot.Retention
 	}
 	tests := []struct {
 		name    string
@@ -1467,7 +1557,7 @@ func Test_UnitClientSnapshotRetention(t *testing.T) {
 				}
 				return
 			}
-			got, err := c.SnapshotRetention(tt.args.ctx, tt.args.prefix)
+			got, err := c.SnapshotRetention(tt.args.ctx, tt.args.prefix, tt.args.retention)
 			t.Logf("Got snapshots=%#v err=%v", got, err)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.SnapshotRetention() error = %v, wantErr %v", err, tt.wantErr)
@@ -1599,6 +1689,13 @@ func s3Router(t *testing.T) http.Handler {
 					rw.WriteHeader(http.StatusNotFound)
 				} else {
 					rw.Header().Add("last-modified", time.Now().In(gmt).Format(time.RFC1123))
+					// objects named as verified or mismatched return a correct or incorrect recorded checksum
+					if strings.Contains(r.PathValue("object"), "verified") {
+						sum := sha256.Sum256([]byte("test snapshot file\n"))
+						rw.Header().Add("x-amz-meta-"+checksumKey, snapshot.ChecksumAlgorithm+":"+hex.EncodeToString(sum[:]))
+					} else if strings.Contains(r.PathValue("object"), "mismatch") {
+						rw.Header().Add("x-amz-meta-"+checksumKey, snapshot.ChecksumAlgorithm+":0000")
+					}
 				}
 			}
 		case http.MethodGet:
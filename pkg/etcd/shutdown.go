@@ -0,0 +1,49 @@
+package etcd
+
+import (
+	"context"
+
+	"github.com/k3s-io/k3s/pkg/signals"
+	"github.com/sirupsen/logrus"
+)
+
+// transferLeadershipOnShutdown waits for the leadership shutdown phase to start, and transfers etcd leadership to
+// a healthy voting member if this member is the leader. Without this, writes fail across the whole cluster until
+// the remaining members notice that the leader has stopped and elect a new one.
+func (e *ETCD) transferLeadershipOnShutdown(ctx context.Context) {
+	phaseCtx, release := signals.PhaseContext(ctx, signals.PhaseLeadership)
+	defer release()
+	<-phaseCtx.Done()
+
+	// the signal context is not cancelled until the final shutdown phase, so if it is done, shutdown was not
+	// requested via the shutdown phases, or the phase timed out.
+	if ctx.Err() != nil || e.client == nil {
+		return
+	}
+
+	status, err := e.status(ctx)
+	if err != nil {
+		logrus.Warnf("Failed to check local etcd status for leader transfer on shutdown: %v", err)
+		return
+	} else if status.Header.MemberId != status.Leader {
+		return
+	}
+
+	members, err := e.client.MemberList(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to get etcd members for leader transfer on shutdown: %v", err)
+		return
+	}
+
+	self, target := leaderTransferTarget(status.Header.MemberId, members.Members, func(url string) bool {
+		_, err := e.getETCDStatus(ctx, url)
+		return err == nil
+	})
+	if self == nil || target == nil {
+		logrus.Warn("Etcd member is the cluster leader, but no healthy voting member is available to transfer leadership to before shutdown")
+		return
+	}
+	if err := e.moveLeader(ctx, self, target); err != nil {
+		logrus.Errorf("Failed to transfer etcd leadership on shutdown: %v", err)
+	}
+}
@@ -20,15 +20,20 @@ import (
 	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/remote"
 	"github.com/k3s-io/k3s/pkg/etcd/s3"
 	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
 	"github.com/k3s-io/k3s/pkg/etcd/snapshotmetrics"
+	"github.com/k3s-io/k3s/pkg/loadshed"
+	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/util/metrics"
 	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/klauspost/compress/zstd"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
+	etcdversion "go.etcd.io/etcd/api/v3/version"
 	snapshotv3 "go.etcd.io/etcd/client/v3/snapshot"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -43,9 +48,8 @@ import (
 )
 
 const (
-	errorTTL             = 24 * time.Hour
-	s3ReconcileTTL       = time.Minute
-	snapshotListPageSize = 20
+	errorTTL       = 24 * time.Hour
+	s3ReconcileTTL = time.Minute
 )
 
 var (
@@ -62,6 +66,9 @@ var (
 		Jitter:   0.1,
 	}
 
+	errSnapshotInProgress   = errors.New("snapshot save already in progress")
+	errSnapshotShuttingDown = errors.New("snapshot save not started: server is shutting down")
+
 	// cronLogger wraps logrus's Printf output as cron-compatible logger
 	cronLogger = cron.VerbosePrintfLogger(logrus.StandardLogger())
 )
@@ -101,10 +108,23 @@ func snapshotDir(config *config.Control, create bool) (string, error) {
 	return snapshotDir, nil
 }
 
-// compressSnapshot compresses the given snapshot and provides the
+// compressSnapshot compresses the given snapshot using the configured compression
+// format, and provides the caller with the path to the file.
+func (e *ETCD) compressSnapshot(snapshotDir, snapshotFilename string, mtime time.Time) (string, error) {
+	logrus.Infof("Compressing etcd snapshot file %s with format %s", snapshotFilename, e.config.EtcdSnapshotCompression)
+	switch e.config.EtcdSnapshotCompression {
+	case snapshot.CompressFormatZstd:
+		return e.compressSnapshotZstd(snapshotDir, snapshotFilename)
+	case snapshot.CompressFormatZip, "":
+		return e.compressSnapshotZip(snapshotDir, snapshotFilename, mtime)
+	default:
+		return "", fmt.Errorf("unsupported etcd snapshot compression format %q", e.config.EtcdSnapshotCompression)
+	}
+}
+
+// compressSnapshotZip compresses the given snapshot into a zip archive and provides the
 // caller with the path to the file.
-func (e *ETCD) compressSnapshot(snapshotDir, snapshotFilename string, mtime time.Time) (zipPath string, err error) {
-	logrus.Info("Compressing etcd snapshot file: " + snapshotFilename)
+func (e *ETCD) compressSnapshotZip(snapshotDir, snapshotFilename string, mtime time.Time) (zipPath string, err error) {
 	snapshotPath := filepath.Join(snapshotDir, snapshotFilename)
 	zipPath = snapshotPath + snapshot.CompressedExtension
 
@@ -152,53 +172,137 @@ func (e *ETCD) compressSnapshot(snapshotDir, snapshotFilename string, mtime time
 	return zipPath, err
 }
 
+// compressSnapshotZstd compresses the given snapshot into a zstd stream at the configured
+// compression level, and provides the caller with the path to the file.
+func (e *ETCD) compressSnapshotZstd(snapshotDir, snapshotFilename string) (zstdPath string, err error) {
+	snapshotPath := filepath.Join(snapshotDir, snapshotFilename)
+	zstdPath = snapshotPath + snapshot.ZstdCompressedExtension
+
+	defer func() {
+		if err != nil {
+			os.Remove(zstdPath)
+		}
+	}()
+
+	sf, err := os.Open(snapshotPath)
+	if err != nil {
+		return "", err
+	}
+	defer sf.Close()
+
+	of, err := os.OpenFile(zstdPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer of.Close()
+
+	level := zstd.SpeedDefault
+	if e.config.EtcdSnapshotZstdLevel > 0 {
+		level = zstd.EncoderLevelFromZstd(e.config.EtcdSnapshotZstdLevel)
+	}
+
+	zw, err := zstd.NewWriter(of, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0)))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(zw, sf); err != nil {
+		zw.Close()
+		return "", err
+	}
+	return zstdPath, zw.Close()
+}
+
 // decompressSnapshot decompresses the given snapshot and provides the caller
 // with the full path to the uncompressed snapshot.
-func (e *ETCD) decompressSnapshot(snapshotDir, snapshotFilename string) (unzipPath string, err error) {
-	logrus.Info("Decompressing etcd snapshot file: " + snapshotFilename)
+func (e *ETCD) decompressSnapshot(snapshotDir, snapshotFilename string) (string, error) {
 	snapshotPath := filepath.Join(snapshotDir, snapshotFilename)
-	unzipPath = strings.TrimSuffix(snapshotPath, snapshot.CompressedExtension)
+	outputPath, _ := snapshot.CutCompressedExtension(snapshotPath)
+	return outputPath, e.decompressSnapshotTo(snapshotPath, outputPath)
+}
+
+// decompressSnapshotTo decompresses the snapshot at snapshotPath to outputPath.
+// The compression format is determined by the snapshot file extension.
+func (e *ETCD) decompressSnapshotTo(snapshotPath, outputPath string) error {
+	logrus.Info("Decompressing etcd snapshot file: " + snapshotPath)
+	if strings.HasSuffix(snapshotPath, snapshot.ZstdCompressedExtension) {
+		return e.decompressSnapshotZstd(snapshotPath, outputPath)
+	}
+	return e.decompressSnapshotZip(snapshotPath, outputPath)
+}
 
+// decompressSnapshotZip extracts the snapshot from the given zip archive.
+func (e *ETCD) decompressSnapshotZip(snapshotPath, outputPath string) (err error) {
 	defer func() {
 		if err != nil {
-			os.Remove(unzipPath)
+			os.Remove(outputPath)
 		}
 	}()
 
 	sf, err := os.Open(snapshotPath)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer sf.Close()
 
 	fi, err := sf.Stat()
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	zf, err := zip.NewReader(sf, fi.Size())
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	if len(zf.File) != 1 {
-		return "", errors.New("unexpected compressed etcd snapshot contents")
+		return errors.New("unexpected compressed etcd snapshot contents")
 	}
 
 	cf, err := zf.File[0].Open()
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer cf.Close()
 
-	of, err := os.OpenFile(unzipPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	of, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer of.Close()
 
 	_, err = io.Copy(of, cf)
-	return unzipPath, err
+	return err
+}
+
+// decompressSnapshotZstd decompresses the snapshot from the given zstd stream.
+func (e *ETCD) decompressSnapshotZstd(snapshotPath, outputPath string) (err error) {
+	defer func() {
+		if err != nil {
+			os.Remove(outputPath)
+		}
+	}()
+
+	sf, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	zr, err := zstd.NewReader(sf)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	of, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+
+	_, err = io.Copy(of, zr)
+	return err
 }
 
 // Snapshot attempts to save a new snapshot to the configured directory, and then clean up any old and failed
@@ -208,22 +312,30 @@ func (e *ETCD) decompressSnapshot(snapshotDir, snapshotFilename string) (unzipPa
 // subcommand for prune that can be run manually if the user wants to remove old snapshots.
 // Returns metadata about the new and pruned snapshots.
 func (e *ETCD) Snapshot(ctx context.Context) (*managed.SnapshotResult, error) {
-	res, err := e.snapshot(ctx)
+	res, err := e.snapshot(ctx, e.config.EtcdSnapshotName, snapshot.NewRetention(e.config))
 	if err != nil {
 		return res, err
 	}
 	return res, e.reconcileSnapshotData(ctx, res)
 }
 
-// snapshot is the actual snapshot save/upload implementation.
+// snapshot is the actual snapshot save/upload implementation. Snapshots are saved with the given
+// name prefix, and the retention policy is applied to snapshots with the same prefix.
 // This is not inline in the Snapshot function so that the save and reconcile operation
 // metrics do not overlap.
-func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr error) {
+func (e *ETCD) snapshot(ctx context.Context, snapshotPrefix string, retention snapshot.Retention) (_ *managed.SnapshotResult, rerr error) {
 	snapshotStart := time.Now()
 	defer metrics.ObserveWithStatus(snapshotmetrics.SaveCount, snapshotStart, rerr)
 
+	// shutdown waits for in-progress snapshots and uploads to complete, but new snapshots are not started
+	release, ok := signals.Hold(signals.PhaseSnapshots)
+	defer release()
+	if !ok {
+		return nil, errSnapshotShuttingDown
+	}
+
 	if !e.snapshotMu.TryLock() {
-		return nil, errors.New("snapshot save already in progress")
+		return nil, errSnapshotInProgress
 	}
 	defer e.snapshotMu.Unlock()
 	// make sure the core.Factory is initialized before attempting to add snapshot metadata
@@ -271,42 +383,79 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 		return nil, errors.WithMessage(err, "failed to get server token hash for etcd snapshot")
 	}
 
+	// record the cluster metadata alongside any extra metadata, so that it can be checked on restore
+	clusterMetadata := e.clusterMetadata()
+	clusterMetadata.TokenHash = tokenHash
+	extraMetadata = clusterMetadata.Apply(extraMetadata)
+
 	nodeName := os.Getenv("NODE_NAME")
 	now := time.Now().Round(time.Second)
-	snapshotName := fmt.Sprintf("%s-%s-%d", e.config.EtcdSnapshotName, nodeName, now.Unix())
+	snapshotName := fmt.Sprintf("%s-%s-%d", snapshotPrefix, nodeName, now.Unix())
 	snapshotPath := filepath.Join(snapshotDir, snapshotName)
 	logrus.Infof("Saving etcd snapshot to %s", snapshotPath)
 
-	var sf *snapshot.File
+	// Record the snapshot as in progress under the name and location that it will have once
+	// saved and compressed, so that the same ETCDSnapshotFile is updated when it completes.
+	snapshotFilename := snapshotName
+	if e.config.EtcdSnapshotCompress {
+		snapshotFilename += snapshot.CompressedExtensionFor(e.config.EtcdSnapshotCompression)
+	}
+	sf := &snapshot.File{
+		Name:     snapshotFilename,
+		Location: "file://" + filepath.Join(snapshotDir, snapshotFilename),
+		NodeName: nodeName,
+		CreatedAt: &metav1.Time{
+			Time: now,
+		},
+		Status:         snapshot.InProgressStatus,
+		Compressed:     e.config.EtcdSnapshotCompress,
+		MetadataSource: extraMetadata,
+		TokenHash:      tokenHash,
+	}
+	if err := e.addSnapshotData(*sf); err != nil {
+		logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+	}
+
+	// failed updates the ETCDSnapshotFile to record the reason that the snapshot failed.
+	failed := func(reason string, err error) error {
+		sf.Status = snapshot.FailedStatus
+		sf.Reason = reason
+		sf.Message = base64.StdEncoding.EncodeToString([]byte(err.Error()))
+		if err := e.addSnapshotData(*sf); err != nil {
+			return errors.WithMessage(err, "failed to sync ETCDSnapshotFile")
+		}
+		return nil
+	}
+
+	if err := e.preSnapshotHooks(ctx, sf); err != nil {
+		// resume any applications quiesced by hooks that ran before the failure
+		e.postSnapshotHooks(ctx, sf, snapshot.FailedStatus)
+		logrus.Errorf("Failed to take etcd snapshot: %v", err)
+		if err := failed(snapshot.ReasonHookFailed, err); err != nil {
+			logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+		}
+		return nil, err
+	}
 
 	saveStart := time.Now()
 	_, err = snapshotv3.SaveWithVersion(ctx, e.client.GetLogger(), *cfg, snapshotPath)
 	metrics.ObserveWithStatus(snapshotmetrics.SaveLocalCount, saveStart, err)
 
+	saveStatus := snapshot.SuccessfulStatus
 	if err != nil {
-		sf = &snapshot.File{
-			Name:     snapshotName,
-			Location: "",
-			NodeName: nodeName,
-			CreatedAt: &metav1.Time{
-				Time: now,
-			},
-			Status:         snapshot.FailedStatus,
-			Message:        base64.StdEncoding.EncodeToString([]byte(err.Error())),
-			Size:           0,
-			MetadataSource: extraMetadata,
-		}
-		logrus.Errorf("Failed to take etcd snapshot: %v", err)
-		if err := e.addSnapshotData(*sf); err != nil {
-			return nil, errors.WithMessage(err, "failed to sync ETCDSnapshotFile")
-		}
+		saveStatus = snapshot.FailedStatus
 	}
+	e.postSnapshotHooks(ctx, sf, saveStatus)
 
 	res := &managed.SnapshotResult{}
-	// If the snapshot attempt was successful, sf will be nil as we did not set it to store the error message.
-	if sf == nil {
+	if err != nil {
+		logrus.Errorf("Failed to take etcd snapshot: %v", err)
+		if err := failed(snapshot.ReasonSaveFailed, err); err != nil {
+			return nil, err
+		}
+	} else {
 		if e.config.EtcdSnapshotCompress {
-			zipPath, err := e.compressSnapshot(snapshotDir, snapshotName, now)
+			compressedPath, err := e.compressSnapshot(snapshotDir, snapshotName, now)
 
 			// ensure that the unncompressed snapshot is cleaned up even if compression fails
 			if err := os.Remove(snapshotPath); err != nil && !os.IsNotExist(err) {
@@ -314,9 +463,13 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 			}
 
 			if err != nil {
-				return nil, errors.WithMessage(err, "failed to compress snapshot")
+				err = errors.WithMessage(err, "failed to compress snapshot")
+				if err := failed(snapshot.ReasonCompressFailed, err); err != nil {
+					logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+				}
+				return nil, err
 			}
-			snapshotPath = zipPath
+			snapshotPath = compressedPath
 			logrus.Info("Compressed snapshot: " + snapshotPath)
 		}
 
@@ -325,19 +478,15 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 			return nil, errors.WithMessage(err, "unable to retrieve snapshot information from local snapshot")
 		}
 
-		sf = &snapshot.File{
-			Name:     f.Name(),
-			Location: "file://" + snapshotPath,
-			NodeName: nodeName,
-			CreatedAt: &metav1.Time{
-				Time: now,
-			},
-			Status:         snapshot.SuccessfulStatus,
-			Size:           f.Size(),
-			Compressed:     e.config.EtcdSnapshotCompress,
-			MetadataSource: extraMetadata,
-			TokenHash:      tokenHash,
+		// Failing to save the snapshot checksum is not fatal, the snapshot can still be used without it.
+		checksum, err := saveSnapshotChecksum(snapshotPath)
+		if err != nil {
+			logrus.Warnf("Failed to save local snapshot checksum: %v", err)
 		}
+
+		sf.Status = snapshot.SuccessfulStatus
+		sf.Size = f.Size()
+		sf.Checksum = checksum
 		res.Created = append(res.Created, sf.Name)
 
 		// Failing to save snapshot metadata is not fatal, the snapshot can still be used without it.
@@ -352,7 +501,7 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 		}
 
 		// Snapshot retention may prune some files before returning an error. Failing to prune is not fatal.
-		deleted, err := snapshotRetention(e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName, snapshotDir)
+		deleted, err := snapshotRetention(retention, snapshotPrefix, snapshotDir)
 		res.Deleted = append(res.Deleted, deleted...)
 		if err != nil {
 			e.warningEventf("ETCDSnapshotRetentionFailedLocal", "Failed to apply local snapshot retention policy: %v", err)
@@ -374,15 +523,17 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 						Message:        base64.StdEncoding.EncodeToString([]byte(err.Error())),
 						Size:           0,
 						Status:         snapshot.FailedStatus,
+						Reason:         snapshot.ReasonClientFailed,
 						S3:             &snapshot.S3Config{EtcdS3: *e.config.EtcdS3},
 						MetadataSource: extraMetadata,
 					}
 				}
 			} else {
+				loadshed.Wait(ctx, "etcd snapshot upload to S3")
 				logrus.Infof("Saving etcd snapshot %s to S3", snapshotName)
 				// upload will return a snapshot.File even on error - if there was an
 				// error, it will be reflected in the status and message.
-				sf, err = s3client.Upload(ctx, snapshotPath, extraMetadata, now)
+				sf, err = s3client.Upload(ctx, snapshotPath, extraMetadata, now, e.uploadProgress)
 				metrics.ObserveWithStatus(snapshotmetrics.SaveS3Count, s3Start, err)
 				if err != nil {
 					logrus.Errorf("Error received during snapshot upload to S3: %s", err)
@@ -393,7 +544,7 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 				// Attempt to apply retention even if the upload failed; failure may be due to bucket
 				// being full or some other condition that retention policy would resolve.
 				// Snapshot retention may prune some files before returning an error. Failing to prune is not fatal.
-				deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotName)
+				deleted, err := s3client.SnapshotRetention(ctx, snapshotPrefix, retention)
 				res.Deleted = append(res.Deleted, deleted...)
 				if err != nil {
 					e.warningEventf("ETCDSnapshotRetentionFailedS3", "Failed to apply S3 snapshot retention policy: %v", err)
@@ -406,11 +557,122 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 				logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
 			}
 		}
+
+		for _, target := range e.config.EtcdS3Targets {
+			e.uploadSnapshotToTarget(ctx, target, snapshotPath, snapshotPrefix, retention, extraMetadata, now, res)
+		}
+
+		if remoteClient := e.getRemoteClient(); remoteClient != nil {
+			e.uploadSnapshotToRemote(ctx, remoteClient, snapshotPath, snapshotPrefix, retention, res)
+		}
 	}
 
 	return res, nil
 }
 
+// uploadProgress records the progress of a snapshot upload in the ETCDSnapshotFile.
+// Failing to record progress is not fatal; the upload continues regardless.
+func (e *ETCD) uploadProgress(sf snapshot.File) {
+	if err := e.addSnapshotData(sf); err != nil {
+		logrus.Warnf("Failed to sync ETCDSnapshotFile progress: %v", err)
+	}
+}
+
+// uploadSnapshotToTarget uploads a snapshot to an additional S3 target, and applies the retention policy
+// to snapshots stored on the target. The result of the upload is recorded in a separate ETCDSnapshotFile
+// for each target, so failures are reported per target and do not affect uploads to other targets.
+func (e *ETCD) uploadSnapshotToTarget(ctx context.Context, target, snapshotPath, snapshotPrefix string, retention snapshot.Retention, extraMetadata *v1.ConfigMap, now time.Time, res *managed.SnapshotResult) {
+	etcdS3 := s3.TargetConfig(target)
+	snapshotName := filepath.Base(snapshotPath)
+	s3Start := time.Now()
+
+	var sf *snapshot.File
+	if s3client, err := e.getS3ClientFor(ctx, etcdS3); err != nil {
+		metrics.ObserveWithStatus(snapshotmetrics.SaveS3Count, s3Start, err)
+		err = errors.WithMessagef(err, "failed to initialize client for S3 target %s", target)
+		logrus.Errorf("Unable to upload etcd snapshot %s: %v", snapshotName, err)
+		sf = &snapshot.File{
+			Name:     snapshotName,
+			NodeName: "s3",
+			CreatedAt: &metav1.Time{
+				Time: now,
+			},
+			Message:        base64.StdEncoding.EncodeToString([]byte(err.Error())),
+			Status:         snapshot.FailedStatus,
+			Reason:         snapshot.ReasonClientFailed,
+			S3:             &snapshot.S3Config{EtcdS3: *etcdS3},
+			MetadataSource: extraMetadata,
+		}
+	} else {
+		loadshed.Wait(ctx, "etcd snapshot upload to S3 target "+target)
+		logrus.Infof("Saving etcd snapshot %s to S3 target %s", snapshotName, target)
+		sf, err = s3client.Upload(ctx, snapshotPath, extraMetadata, now, e.uploadProgress)
+		metrics.ObserveWithStatus(snapshotmetrics.SaveS3Count, s3Start, err)
+		if err != nil {
+			logrus.Errorf("Error received during snapshot upload to S3 target %s: %s", target, err)
+		} else {
+			res.Created = append(res.Created, sf.Name)
+			logrus.Infof("S3 upload to target %s complete for %s", target, snapshotName)
+		}
+		deleted, err := s3client.SnapshotRetention(ctx, snapshotPrefix, retention)
+		res.Deleted = append(res.Deleted, deleted...)
+		if err != nil {
+			e.warningEventf("ETCDSnapshotRetentionFailedS3", "Failed to apply snapshot retention policy for S3 target %s: %v", target, err)
+		}
+	}
+	if err := e.addSnapshotData(*sf); err != nil {
+		logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+	}
+}
+
+// uploadSnapshotToRemote copies a snapshot to the remote file target, and applies the retention policy
+// for snapshots with the same prefix. Snapshots on the remote target are not tracked by ETCDSnapshotFile
+// resources, so failures are only reported as events.
+func (e *ETCD) uploadSnapshotToRemote(ctx context.Context, remoteClient *remote.Client, snapshotPath, snapshotPrefix string, retention snapshot.Retention, res *managed.SnapshotResult) {
+	snapshotName := filepath.Base(snapshotPath)
+	loadshed.Wait(ctx, "etcd snapshot upload to "+remoteClient.String())
+	logrus.Infof("Saving etcd snapshot %s to %s", snapshotName, remoteClient)
+	if err := remoteClient.Upload(ctx, snapshotPath); err != nil {
+		e.warningEventf("ETCDSnapshotUploadFailedRemote", "Failed to upload snapshot %s: %v", snapshotName, err)
+	} else {
+		logrus.Infof("Remote upload complete for %s", snapshotName)
+	}
+	// Attempt to apply retention even if the upload failed, as with S3.
+	deleted, err := remoteClient.SnapshotRetention(ctx, snapshotPrefix, retention)
+	res.Deleted = append(res.Deleted, deleted...)
+	if err != nil {
+		e.warningEventf("ETCDSnapshotRetentionFailedRemote", "Failed to apply snapshot retention policy for %s: %v", remoteClient, err)
+	}
+}
+
+// getRemoteClient returns a client for the remote file target, or nil if no remote target is configured.
+func (e *ETCD) getRemoteClient() *remote.Client {
+	if e.config.EtcdRemoteTarget == "" {
+		return nil
+	}
+	remoteClient, err := remote.NewClient(e.config.EtcdRemoteTarget, e.config.EtcdRemoteRetention)
+	if err != nil {
+		logrus.Warnf("Unable to initialize remote snapshot client: %v", err)
+		return nil
+	}
+	return remoteClient
+}
+
+// getS3TargetClients returns clients for the additional S3 targets, keyed by target name.
+// Targets whose client cannot be initialized are logged and omitted.
+func (e *ETCD) getS3TargetClients(ctx context.Context) map[string]*s3.Client {
+	clients := map[string]*s3.Client{}
+	for _, target := range e.config.EtcdS3Targets {
+		s3client, err := e.getS3ClientFor(ctx, s3.TargetConfig(target))
+		if err != nil {
+			logrus.Warnf("Unable to initialize client for S3 target %s: %v", target, err)
+			continue
+		}
+		clients[target] = s3client
+	}
+	return clients
+}
+
 // listLocalSnapshots provides a list of the currently stored
 // snapshots on disk along with their relevant
 // metadata.
@@ -427,7 +689,7 @@ func (e *ETCD) listLocalSnapshots() (map[string]snapshot.File, error) {
 			return err
 		}
 
-		basename, compressed := strings.CutSuffix(file.Name(), snapshot.CompressedExtension)
+		basename, compressed := snapshot.CutCompressedExtension(file.Name())
 		ts, err := strconv.ParseInt(basename[strings.LastIndexByte(basename, '-')+1:], 10, 64)
 		if err != nil {
 			ts = file.ModTime().Unix()
@@ -453,6 +715,7 @@ func (e *ETCD) listLocalSnapshots() (map[string]snapshot.File, error) {
 			Size:       file.Size(),
 			Status:     snapshot.SuccessfulStatus,
 			Compressed: compressed,
+			Checksum:   snapshot.ReadChecksum(path),
 		}
 		sfKey := sf.GenerateConfigMapKey()
 		snapshots[sfKey] = sf
@@ -470,6 +733,12 @@ func (e *ETCD) listLocalSnapshots() (map[string]snapshot.File, error) {
 // The context passed here is only used to validate the configuration,
 // it does not need to continue to remain uncancelled after the call returns.
 func (e *ETCD) getS3Client(ctx context.Context) (*s3.Client, error) {
+	return e.getS3ClientFor(ctx, e.config.EtcdS3)
+}
+
+// getS3ClientFor initializes the S3 controller if it hasn't yet been initialized,
+// and returns a client for the given S3 configuration.
+func (e *ETCD) getS3ClientFor(ctx context.Context, etcdS3 *config.EtcdS3) (*s3.Client, error) {
 	if e.s3 == nil {
 		s3, err := s3.Start(ctx, e.config)
 		if err != nil {
@@ -478,7 +747,7 @@ func (e *ETCD) getS3Client(ctx context.Context) (*s3.Client, error) {
 		e.s3 = s3
 	}
 
-	return e.s3.GetClient(ctx, e.config.EtcdS3)
+	return e.s3.GetClient(ctx, etcdS3)
 }
 
 // PruneSnapshots deleted old snapshots in excess of the configured retention count.
@@ -493,7 +762,7 @@ func (e *ETCD) PruneSnapshots(ctx context.Context) (*managed.SnapshotResult, err
 	res := &managed.SnapshotResult{}
 	// Note that snapshotRetention functions may return a list of deleted files, as well as
 	// an error, if some snapshots are deleted before the error is encountered.
-	res.Deleted, err = snapshotRetention(e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName, snapshotDir)
+	res.Deleted, err = snapshotRetention(snapshot.NewRetention(e.config), e.config.EtcdSnapshotName, snapshotDir)
 	if err != nil {
 		logrus.Errorf("Error applying snapshot retention policy: %v", err)
 	}
@@ -502,13 +771,27 @@ func (e *ETCD) PruneSnapshots(ctx context.Context) (*managed.SnapshotResult, err
 		if s3client, err := e.getS3Client(ctx); err != nil {
 			logrus.Warnf("Unable to initialize S3 client: %v", err)
 		} else {
-			deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotName)
+			deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotName, snapshot.NewRetention(e.config))
 			if err != nil {
 				logrus.Errorf("Error applying S3 snapshot retention policy: %v", err)
 			}
 			res.Deleted = append(res.Deleted, deleted...)
 		}
 	}
+	for target, s3client := range e.getS3TargetClients(ctx) {
+		deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotName, snapshot.NewRetention(e.config))
+		if err != nil {
+			logrus.Errorf("Error applying snapshot retention policy for S3 target %s: %v", target, err)
+		}
+		res.Deleted = append(res.Deleted, deleted...)
+	}
+	if remoteClient := e.getRemoteClient(); remoteClient != nil {
+		deleted, err := remoteClient.SnapshotRetention(ctx, e.config.EtcdSnapshotName, snapshot.NewRetention(e.config))
+		if err != nil {
+			logrus.Errorf("Error applying snapshot retention policy for %s: %v", remoteClient, err)
+		}
+		res.Deleted = append(res.Deleted, deleted...)
+	}
 	return res, e.reconcileSnapshotData(ctx, res)
 }
 
@@ -540,6 +823,18 @@ func (e *ETCD) ListSnapshots(ctx context.Context) (*k3s.ETCDSnapshotFileList, er
 		}
 	}
 
+	for target, s3client := range e.getS3TargetClients(ctx) {
+		sfs, err := s3client.ListSnapshots(ctx)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to list snapshots on S3 target %s", target)
+		}
+		for k, sf := range sfs {
+			esf := k3s.NewETCDSnapshotFile("", k, k3s.ETCDSnapshotFile{})
+			sf.ToETCDSnapshotFile(esf)
+			snapshotFiles.Items = append(snapshotFiles.Items, *esf)
+		}
+	}
+
 	sfs, err := e.listLocalSnapshots()
 	if err != nil {
 		return nil, err
@@ -573,6 +868,9 @@ func (e *ETCD) DeleteSnapshots(ctx context.Context, snapshots []string) (*manage
 		}
 	}
 
+	targetClients := e.getS3TargetClients(ctx)
+	remoteClient := e.getRemoteClient()
+
 	res := &managed.SnapshotResult{}
 	for _, s := range snapshots {
 		if err := e.deleteSnapshot(filepath.Join(snapshotDir, s)); err != nil {
@@ -598,6 +896,32 @@ func (e *ETCD) DeleteSnapshots(ctx context.Context, snapshots []string) (*manage
 				logrus.Infof("Snapshot %s deleted from S3", s)
 			}
 		}
+
+		for target, s3client := range targetClients {
+			if err := s3client.DeleteSnapshot(ctx, s); err != nil {
+				if snapshot.IsNotExist(err) {
+					logrus.Infof("Snapshot %s not found on S3 target %s", s, target)
+				} else {
+					logrus.Errorf("Failed to delete snapshot %s from S3 target %s: %v", s, target, err)
+				}
+			} else {
+				res.Deleted = append(res.Deleted, s)
+				logrus.Infof("Snapshot %s deleted from S3 target %s", s, target)
+			}
+		}
+
+		if remoteClient != nil {
+			if err := remoteClient.DeleteSnapshot(ctx, s); err != nil {
+				if snapshot.IsNotExist(err) {
+					logrus.Infof("Snapshot %s not found on %s", s, remoteClient)
+				} else {
+					logrus.Errorf("Failed to delete snapshot %s from %s: %v", s, remoteClient, err)
+				}
+			} else {
+				res.Deleted = append(res.Deleted, s)
+				logrus.Infof("Snapshot %s deleted from %s", s, remoteClient)
+			}
+		}
 	}
 
 	return res, e.reconcileSnapshotData(ctx, res)
@@ -613,6 +937,9 @@ func (e *ETCD) deleteSnapshot(snapshotPath string) error {
 		if merr := os.Remove(metadataPath); err != nil && !snapshot.IsNotExist(err) {
 			err = merr
 		}
+		if cerr := os.Remove(snapshot.ChecksumPath(snapshotPath)); cerr != nil && !os.IsNotExist(cerr) {
+			logrus.Warnf("Failed to remove local snapshot checksum: %v", cerr)
+		}
 	}
 
 	return err
@@ -647,19 +974,24 @@ func (e *ETCD) addSnapshotData(sf snapshot.File) error {
 		}
 
 		// mutate object
-		existing := esf.DeepCopyObject()
+		existing := esf.DeepCopy()
 		sf.ToETCDSnapshotFile(esf)
 
 		// create or update as necessary
 		if esf.CreationTimestamp.IsZero() {
 			var created *k3s.ETCDSnapshotFile
 			created, err = snapshots.Create(esf)
-			if err == nil {
+			if err == nil && created.Status.Phase != k3s.ETCDSnapshotPhaseInProgress {
 				// Only emit an event for the snapshot when creating the resource
 				e.snapshotEvent(created)
 			}
 		} else if !equality.Semantic.DeepEqual(existing, esf) {
-			_, err = snapshots.Update(esf)
+			var updated *k3s.ETCDSnapshotFile
+			updated, err = snapshots.Update(esf)
+			if err == nil && existing.Status.Phase == k3s.ETCDSnapshotPhaseInProgress && updated.Status.Phase != k3s.ETCDSnapshotPhaseInProgress {
+				// Emit the event for snapshots that were created while in progress once they complete
+				e.snapshotEvent(updated)
+			}
 		}
 		return err
 	})
@@ -670,26 +1002,33 @@ func (e *ETCD) addSnapshotData(sf snapshot.File) error {
 func generateETCDSnapshotFileConfigMapKey(esf k3s.ETCDSnapshotFile) string {
 	name := snapshot.InvalidKeyChars.ReplaceAllString(esf.Spec.SnapshotName, "_")
 	if esf.Spec.S3 != nil {
-		return "s3-" + name
+		return snapshot.S3ConfigMapKey(esf.Annotations[snapshot.AnnotationS3Target], name)
 	}
 	return "local-" + name
 }
 
-// snapshotEvent emits an Event attached to the EtcdSnapshotFile resource
+// snapshotEvent emits an Event attached to the EtcdSnapshotFile resource,
+// and sends a notification to the snapshot webhook if one is configured.
 func (e *ETCD) snapshotEvent(esf *k3s.ETCDSnapshotFile) {
+	eventType := v1.EventTypeNormal
+	reason := "ETCDSnapshotCreated"
+	message := fmt.Sprintf("Snapshot %s saved on %s", esf.Spec.SnapshotName, esf.Spec.NodeName)
 	switch {
-	case e.config.Runtime.Event == nil:
 	case !esf.DeletionTimestamp.IsZero():
-		e.config.Runtime.Event.Eventf(esf, v1.EventTypeNormal, "ETCDSnapshotDeleted", "Snapshot %s deleted", esf.Spec.SnapshotName)
+		reason = "ETCDSnapshotDeleted"
+		message = fmt.Sprintf("Snapshot %s deleted", esf.Spec.SnapshotName)
 	case esf.Status.Error != nil:
-		message := fmt.Sprintf("Failed to save snapshot %s on %s", esf.Spec.SnapshotName, esf.Spec.NodeName)
+		eventType = v1.EventTypeWarning
+		reason = "ETCDSnapshotFailed"
+		message = fmt.Sprintf("Failed to save snapshot %s on %s", esf.Spec.SnapshotName, esf.Spec.NodeName)
 		if esf.Status.Error.Message != nil {
 			message += ": " + *esf.Status.Error.Message
 		}
-		e.config.Runtime.Event.Event(esf, v1.EventTypeWarning, "ETCDSnapshotFailed", message)
-	default:
-		e.config.Runtime.Event.Eventf(esf, v1.EventTypeNormal, "ETCDSnapshotCreated", "Snapshot %s saved on %s", esf.Spec.SnapshotName, esf.Spec.NodeName)
 	}
+	if e.config.Runtime.Event != nil {
+		e.config.Runtime.Event.Event(esf, eventType, reason, message)
+	}
+	e.snapshotWebhook(reason, message, esf)
 }
 
 // warningEventf emits a warning Event attached to the Node resource,
@@ -743,6 +1082,11 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 
 	nodeNames := []string{os.Getenv("NODE_NAME")}
 
+	// listedTargets tracks the S3 targets that were listed successfully; the etcd-s3 target is
+	// tracked with an empty name. ETCDSnapshotFiles for other targets are not reconciled, so that
+	// records are not removed for targets that are unavailable.
+	listedTargets := map[string]bool{}
+
 	// Get snapshots from S3
 	if e.config.EtcdS3 != nil {
 		s3Start := time.Now()
@@ -761,11 +1105,29 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 				for k, v := range s3Snapshots {
 					snapshotFiles[k] = v
 				}
-				nodeNames = append(nodeNames, "s3")
+				listedTargets[""] = true
 			}
 		}
 	}
 
+	for target, s3client := range e.getS3TargetClients(ctx) {
+		s3Start := time.Now()
+		s3Snapshots, err := s3client.ListSnapshots(ctx)
+		metrics.ObserveWithStatus(snapshotmetrics.ReconcileS3Count, s3Start, err)
+		if err != nil {
+			logrus.Errorf("Error retrieving snapshots from S3 target %s for reconciliation: %v", target, err)
+			continue
+		}
+		for k, v := range s3Snapshots {
+			snapshotFiles[k] = v
+		}
+		listedTargets[target] = true
+	}
+
+	if len(listedTargets) > 0 {
+		nodeNames = append(nodeNames, "s3")
+	}
+
 	// Try to load metadata from the legacy configmap, in case any local or s3 snapshots
 	// were created by an old release that does not write the metadata alongside the snapshot file.
 	snapshotConfigMap, err := e.config.Runtime.Core.Core().V1().ConfigMap().Get(metav1.NamespaceSystem, snapshotConfigMapName, metav1.GetOptions{})
@@ -805,7 +1167,7 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 
 	snapshots := e.config.Runtime.K3s.K3s().V1().ETCDSnapshotFile()
 	snapshotPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (k8sruntime.Object, error) { return snapshots.List(opts) }))
-	snapshotPager.PageSize = snapshotListPageSize
+	snapshotPager.PageSize = e.config.GetListPageSize()
 	now := time.Now().Round(time.Second)
 
 	// List all snapshots matching the selector
@@ -817,6 +1179,9 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 		if !ok {
 			return errors.New("failed to convert object to ETCDSnapshotFile")
 		}
+		if esf.Spec.S3 != nil && !listedTargets[esf.Annotations[snapshot.AnnotationS3Target]] {
+			return nil
+		}
 		sfKey := generateETCDSnapshotFileConfigMapKey(*esf)
 		logrus.Debugf("Found ETCDSnapshotFile for %s with key %s", esf.Spec.SnapshotName, sfKey)
 		if sf, ok := snapshotFiles[sfKey]; ok && sf.GenerateName() == esf.Name {
@@ -826,6 +1191,12 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 			// doesn't exist on disk/s3
 			if res != nil && slices.Contains(res.Deleted, esf.Spec.SnapshotName) {
 				// snapshot has been intentionally deleted, skip checking for expiration
+			} else if esf.Status.Phase == k3s.ETCDSnapshotPhaseInProgress {
+				expires := esf.ObjectMeta.CreationTimestamp.Add(errorTTL)
+				if now.Before(expires) {
+					// it's a snapshot that is still being saved or uploaded, leave it
+					return nil
+				}
 			} else if esf.Status.Error != nil && esf.Status.Error.Time != nil {
 				expires := esf.Status.Error.Time.Add(errorTTL)
 				if now.Before(expires) {
@@ -918,7 +1289,7 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 	return err
 }
 
-// setSnapshotFunction schedules snapshots at the configured interval.
+// setSnapshotFunction schedules snapshots at the configured interval, and on any additional schedules.
 func (e *ETCD) setSnapshotFunction(ctx context.Context) {
 	skipJob := cron.SkipIfStillRunning(cronLogger)
 	e.cron.AddJob(e.config.EtcdSnapshotCron, skipJob(cron.FuncJob(func() {
@@ -930,24 +1301,55 @@ func (e *ETCD) setSnapshotFunction(ctx context.Context) {
 			logrus.Errorf("Failed to take scheduled snapshot: %v", err)
 		}
 	})))
+
+	for _, schedule := range e.config.EtcdSnapshotSchedules {
+		retention := snapshot.Retention{Count: schedule.Retention}
+		e.cron.AddJob(schedule.Cron, skipJob(cron.FuncJob(func() {
+			time.Sleep(time.Duration(rand.Float64() * float64(snapshotJitterMax)))
+			if err := e.scheduledSnapshot(ctx, schedule.Name, retention); err != nil {
+				logrus.Errorf("Failed to take scheduled snapshot for schedule %s: %v", schedule.Name, err)
+			}
+		})))
+	}
+}
+
+// scheduledSnapshot takes a snapshot for an additional schedule. Schedules commonly fire at the same time
+// (for example, hourly and daily snapshots at midnight), so if another snapshot is in progress, the snapshot
+// is retried until the other snapshot completes, instead of failing.
+func (e *ETCD) scheduledSnapshot(ctx context.Context, snapshotPrefix string, retention snapshot.Retention) error {
+	for {
+		res, err := e.snapshot(ctx, snapshotPrefix, retention)
+		if errors.Is(err, errSnapshotInProgress) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(snapshotJitterMax):
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return e.reconcileSnapshotData(ctx, res)
+	}
 }
 
 // snapshotRetention iterates through the snapshots and removes the oldest
 // leaving the desired number of snapshots. Returns a list of pruned snapshot names.
-func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string) ([]string, error) {
-	if retention < 1 {
+func snapshotRetention(retention snapshot.Retention, snapshotPrefix string, snapshotDir string) ([]string, error) {
+	if !retention.Enabled() {
 		return nil, nil
 	}
 
-	logrus.Infof("Applying snapshot retention=%d to local snapshots with prefix %s in %s", retention, snapshotPrefix, snapshotDir)
+	logrus.Infof("Applying snapshot retention %s to local snapshots with prefix %s in %s", retention, snapshotPrefix, snapshotDir)
 
 	var snapshotFiles []snapshot.File
 	if err := filepath.Walk(snapshotDir, func(path string, info os.FileInfo, err error) error {
 		if info.IsDir() || err != nil {
 			return err
 		}
-		if strings.HasPrefix(info.Name(), snapshotPrefix) {
-			basename, compressed := strings.CutSuffix(info.Name(), snapshot.CompressedExtension)
+		if snapshot.MatchName(snapshotPrefix, info.Name()) {
+			basename, compressed := snapshot.CutCompressedExtension(info.Name())
 			ts, err := strconv.ParseInt(basename[strings.LastIndexByte(basename, '-')+1:], 10, 64)
 			if err != nil {
 				ts = info.ModTime().Unix()
@@ -958,17 +1360,18 @@ func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string)
 	}); err != nil {
 		return nil, err
 	}
-	if len(snapshotFiles) <= retention {
-		return nil, nil
-	}
-
-	// sort newest-first so we can prune entries past the retention count
+	// sort newest-first so we can prune entries that are not retained by the policy
 	sort.Slice(snapshotFiles, func(i, j int) bool {
 		return snapshotFiles[j].CreatedAt.Before(snapshotFiles[i].CreatedAt)
 	})
 
+	pruned := retention.Prune(time.Now(), snapshotFiles)
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
 	deleted := []string{}
-	for _, df := range snapshotFiles[retention:] {
+	for _, df := range pruned {
 		snapshotPath := filepath.Join(snapshotDir, df.Name)
 		metadataPath := filepath.Join(snapshotDir, "..", snapshot.MetadataDir, df.Name)
 		logrus.Infof("Removing local snapshot %s", snapshotPath)
@@ -978,6 +1381,9 @@ func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string)
 		if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
 			return deleted, err
 		}
+		if err := os.Remove(snapshot.ChecksumPath(snapshotPath)); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
 		deleted = append(deleted, df.Name)
 	}
 
@@ -986,6 +1392,49 @@ func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string)
 
 // saveSnapshotMetadata writes extra metadata to disk.
 // The upload is silently skipped if no extra metadata is provided.
+// clusterMetadata returns the metadata of the cluster that this server belongs to, for recording in
+// snapshots and checking snapshot compatibility on restore. The token and CA hashes are left empty if
+// they are not available, as is the case when restoring on a new node.
+func (e *ETCD) clusterMetadata() snapshot.ClusterMetadata {
+	cm := snapshot.ClusterMetadata{
+		Version:     version.Version,
+		EtcdVersion: etcdversion.Version,
+	}
+	if tokenHash, err := util.GetTokenHash(e.config); err == nil {
+		cm.TokenHash = tokenHash
+	}
+	if caHash, err := snapshot.ComputeChecksum(e.config.Runtime.ServerCA); err == nil {
+		cm.CAHash = caHash
+	}
+	return cm
+}
+
+// checkSnapshotCompatibility compares the cluster metadata recorded in the snapshot's metadata file against
+// this server, and returns an error if the snapshot cannot be safely restored. If the restore is forced,
+// incompatibilities are logged as warnings instead.
+func (e *ETCD) checkSnapshotCompatibility(snapshotPath string, force bool) error {
+	name := filepath.Base(snapshotPath)
+	metadata, err := snapshot.ReadMetadata(snapshotPath)
+	if err != nil {
+		logrus.Warnf("Failed to read metadata for snapshot %s: %v", name, err)
+	}
+
+	errs, warnings := snapshot.ClusterMetadataFrom(metadata).CheckCompatibility(e.clusterMetadata())
+	for _, warning := range warnings {
+		logrus.Warnf("Snapshot %s: %s", name, warning)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if force {
+		for _, err := range errs {
+			logrus.Warnf("Forcing restore of incompatible snapshot %s: %v", name, err)
+		}
+		return nil
+	}
+	return errors.WithMessagef(errors.Join(errs...), "snapshot %s is not compatible with this server; use --cluster-reset-restore-force to restore anyway", name)
+}
+
 func saveSnapshotMetadata(snapshotPath string, extraMetadata *v1.ConfigMap) error {
 	if extraMetadata == nil || len(extraMetadata.Data) == 0 {
 		return nil
@@ -1004,3 +1453,13 @@ func saveSnapshotMetadata(snapshotPath string, extraMetadata *v1.ConfigMap) erro
 	}
 	return os.WriteFile(metadataPath, m, 0700)
 }
+
+// saveSnapshotChecksum computes the checksum of the snapshot and writes it to disk,
+// so that it does not need to be recomputed every time snapshots are listed.
+func saveSnapshotChecksum(snapshotPath string) (string, error) {
+	checksum, err := snapshot.ComputeChecksum(snapshotPath)
+	if err != nil {
+		return "", err
+	}
+	return checksum, snapshot.WriteChecksum(snapshotPath, checksum)
+}
@@ -0,0 +1,140 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	v1 "k8s.io/api/core/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+var (
+	MetadataKeyVersion     = "etcd." + version.Program + ".cattle.io/" + version.Program + "-version"
+	MetadataKeyEtcdVersion = "etcd." + version.Program + ".cattle.io/etcd-version"
+	MetadataKeyTokenHash   = "etcd." + version.Program + ".cattle.io/token-hash"
+	MetadataKeyCAHash      = "etcd." + version.Program + ".cattle.io/server-ca-hash"
+)
+
+// ClusterMetadata describes the cluster that a snapshot was taken from. It is stored
+// alongside any extra metadata in the snapshot metadata file, and is used to check that
+// a snapshot is compatible with the server that it is being restored on.
+type ClusterMetadata struct {
+	Version     string
+	EtcdVersion string
+	TokenHash   string
+	CAHash      string
+}
+
+// ClusterMetadataFrom returns the cluster metadata stored in the given snapshot metadata.
+// Fields are left empty if not present, as is the case for snapshots taken by older releases.
+func ClusterMetadataFrom(metadata map[string]string) ClusterMetadata {
+	return ClusterMetadata{
+		Version:     metadata[MetadataKeyVersion],
+		EtcdVersion: metadata[MetadataKeyEtcdVersion],
+		TokenHash:   metadata[MetadataKeyTokenHash],
+		CAHash:      metadata[MetadataKeyCAHash],
+	}
+}
+
+// IsEmpty returns true if no cluster metadata is set.
+func (cm ClusterMetadata) IsEmpty() bool {
+	return cm == ClusterMetadata{}
+}
+
+// Apply returns a copy of the extra metadata ConfigMap with the cluster metadata added.
+// A new ConfigMap is returned if the extra metadata ConfigMap is nil.
+func (cm ClusterMetadata) Apply(extraMetadata *v1.ConfigMap) *v1.ConfigMap {
+	var cmap *v1.ConfigMap
+	if extraMetadata != nil {
+		cmap = extraMetadata.DeepCopy()
+	} else {
+		cmap = &v1.ConfigMap{}
+	}
+	if cmap.Data == nil {
+		cmap.Data = map[string]string{}
+	}
+	maps.Copy(cmap.Data, map[string]string{
+		MetadataKeyVersion:     cm.Version,
+		MetadataKeyEtcdVersion: cm.EtcdVersion,
+		MetadataKeyTokenHash:   cm.TokenHash,
+		MetadataKeyCAHash:      cm.CAHash,
+	})
+	maps.DeleteFunc(cmap.Data, func(k, v string) bool { return v == "" })
+	return cmap
+}
+
+// CheckCompatibility compares the cluster metadata of a snapshot against the metadata of the
+// server it is being restored on. Errors are returned for differences that will prevent the
+// cluster from functioning after the restore:
+//   - The snapshot was taken on a newer minor version, as downgrades are not supported.
+//   - The snapshot was taken more than one minor version ago, as Kubernetes storage migrations
+//     are only supported between adjacent minor versions.
+//   - The snapshot was taken on a newer etcd minor version.
+//   - The snapshot was taken with a different token, as bootstrap data cannot be decrypted.
+//
+// Warnings are returned for differences that do not prevent the restore, such as a different
+// server CA, which will be replaced by the CA stored in the snapshot's bootstrap data.
+func (cm ClusterMetadata) CheckCompatibility(current ClusterMetadata) (errs []error, warnings []string) {
+	if cm.IsEmpty() {
+		return nil, []string{"snapshot does not contain cluster metadata; compatibility with this server cannot be checked"}
+	}
+
+	if err := checkMinorVersion(version.Program, cm.Version, current.Version, 1); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkMinorVersion("etcd", cm.EtcdVersion, current.EtcdVersion, -1); err != nil {
+		errs = append(errs, err)
+	}
+	if cm.TokenHash != "" && current.TokenHash != "" && cm.TokenHash != current.TokenHash {
+		errs = append(errs, errors.New("snapshot was taken from a cluster with a different token; the token used when the snapshot was taken must be provided"))
+	}
+	if cm.CAHash != "" && current.CAHash != "" && cm.CAHash != current.CAHash {
+		warnings = append(warnings, "snapshot was taken from a cluster with a different server CA; certificates will be replaced by those stored in the snapshot")
+	}
+	return errs, warnings
+}
+
+// checkMinorVersion returns an error if the snapshot version is newer than the current version,
+// or older by more than maxSkew minor versions. A negative maxSkew allows any older version.
+// Versions that are not set or cannot be parsed are not checked.
+func checkMinorVersion(component, snapshotVersion, currentVersion string, maxSkew int) error {
+	sv, err := utilversion.ParseGeneric(snapshotVersion)
+	if err != nil {
+		return nil
+	}
+	cv, err := utilversion.ParseGeneric(currentVersion)
+	if err != nil {
+		return nil
+	}
+	if sv.Major() != cv.Major() || sv.Minor() > cv.Minor() {
+		return fmt.Errorf("snapshot was taken on %s %s, which cannot be restored on %s %s", component, snapshotVersion, component, currentVersion)
+	}
+	if maxSkew >= 0 && int(cv.Minor()-sv.Minor()) > maxSkew {
+		return fmt.Errorf("snapshot was taken on %s %s, which is more than %d minor version older than %s %s; restore on %s v%d.%d and upgrade one minor version at a time",
+			component, snapshotVersion, maxSkew, component, currentVersion, component, sv.Major(), sv.Minor()+1)
+	}
+	return nil
+}
+
+// ReadMetadata returns the metadata stored alongside a local snapshot.
+// An empty map is returned if the snapshot does not have a metadata file.
+func ReadMetadata(snapshotPath string) (map[string]string, error) {
+	metadataPath := filepath.Join(filepath.Dir(snapshotPath), "..", MetadataDir, filepath.Base(snapshotPath))
+	b, err := os.ReadFile(metadataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	metadata := map[string]string{}
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot metadata from %s: %w", metadataPath, err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,120 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func Test_UnitCheckCompatibility(t *testing.T) {
+	current := ClusterMetadata{
+		Version:     "v1.31.2+k3s1",
+		EtcdVersion: "3.5.16",
+		TokenHash:   "abc123",
+		CAHash:      "sha256:ca1",
+	}
+	tests := []struct {
+		name         string
+		snapshot     ClusterMetadata
+		wantErrs     int
+		wantWarnings int
+	}{
+		{
+			name:     "same cluster and version",
+			snapshot: current,
+		},
+		{
+			name:     "previous minor version",
+			snapshot: ClusterMetadata{Version: "v1.30.6+k3s1", EtcdVersion: "3.5.15", TokenHash: "abc123", CAHash: "sha256:ca1"},
+		},
+		{
+			name:     "multiple minor versions older",
+			snapshot: ClusterMetadata{Version: "v1.28.15+k3s1", EtcdVersion: "3.5.16", TokenHash: "abc123", CAHash: "sha256:ca1"},
+			wantErrs: 1,
+		},
+		{
+			name:     "newer minor version",
+			snapshot: ClusterMetadata{Version: "v1.32.0+k3s1", EtcdVersion: "3.5.16", TokenHash: "abc123", CAHash: "sha256:ca1"},
+			wantErrs: 1,
+		},
+		{
+			name:     "newer patch version",
+			snapshot: ClusterMetadata{Version: "v1.31.5+k3s2", EtcdVersion: "3.5.17", TokenHash: "abc123", CAHash: "sha256:ca1"},
+		},
+		{
+			name:     "newer etcd minor version",
+			snapshot: ClusterMetadata{Version: "v1.31.2+k3s1", EtcdVersion: "3.6.0", TokenHash: "abc123", CAHash: "sha256:ca1"},
+			wantErrs: 1,
+		},
+		{
+			name:     "different token",
+			snapshot: ClusterMetadata{Version: "v1.31.2+k3s1", EtcdVersion: "3.5.16", TokenHash: "def456", CAHash: "sha256:ca1"},
+			wantErrs: 1,
+		},
+		{
+			name:         "different CA",
+			snapshot:     ClusterMetadata{Version: "v1.31.2+k3s1", EtcdVersion: "3.5.16", TokenHash: "abc123", CAHash: "sha256:ca2"},
+			wantWarnings: 1,
+		},
+		{
+			name:     "unparseable version",
+			snapshot: ClusterMetadata{Version: "dev", EtcdVersion: "3.5.16", TokenHash: "abc123"},
+		},
+		{
+			name:         "no metadata",
+			snapshot:     ClusterMetadata{},
+			wantWarnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, warnings := tt.snapshot.CheckCompatibility(current)
+			if len(errs) != tt.wantErrs {
+				t.Errorf("CheckCompatibility() errs = %v, want %d", errs, tt.wantErrs)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("CheckCompatibility() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func Test_UnitClusterMetadataRoundTrip(t *testing.T) {
+	cm := ClusterMetadata{Version: "v1.31.2+k3s1", EtcdVersion: "3.5.16", TokenHash: "abc123"}
+	extraMetadata := &v1.ConfigMap{Data: map[string]string{"foo": "bar"}}
+
+	cmap := cm.Apply(extraMetadata)
+	if len(extraMetadata.Data) != 1 {
+		t.Errorf("Apply() modified the extra metadata ConfigMap: %v", extraMetadata.Data)
+	}
+	if _, ok := cmap.Data[MetadataKeyCAHash]; ok {
+		t.Errorf("Apply() set empty key %s", MetadataKeyCAHash)
+	}
+	if cmap.Data["foo"] != "bar" {
+		t.Errorf("Apply() did not retain extra metadata: %v", cmap.Data)
+	}
+
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshots", "etcd-snapshot-1")
+	if err := os.MkdirAll(filepath.Join(dir, MetadataDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MetadataDir, "etcd-snapshot-1"), []byte(`{"foo":"bar","etcd.k3s.cattle.io/k3s-version":"v1.31.2+k3s1","etcd.k3s.cattle.io/etcd-version":"3.5.16","etcd.k3s.cattle.io/token-hash":"abc123"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := ReadMetadata(snapshotPath)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if got := ClusterMetadataFrom(metadata); !reflect.DeepEqual(got, cm) {
+		t.Errorf("ClusterMetadataFrom() = %+v, want %+v", got, cm)
+	}
+
+	metadata, err = ReadMetadata(filepath.Join(dir, "snapshots", "etcd-snapshot-2"))
+	if err != nil || !ClusterMetadataFrom(metadata).IsEmpty() {
+		t.Errorf("ReadMetadata() for snapshot without metadata = %v, %v, want empty", metadata, err)
+	}
+}
@@ -0,0 +1,184 @@
+package snapshot
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// retentionIntervals contains named aliases that may be used in place of a
+// duration when specifying the interval of a retention tier.
+var retentionIntervals = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// Retention describes which snapshots are kept when pruning. A snapshot is retained
+// if it is one of the Count most recent snapshots, if it is newer than MaxAge, or if
+// it is selected by any of the tiers. All other snapshots are pruned.
+type Retention struct {
+	Count  int
+	MaxAge time.Duration
+	Tiers  []config.SnapshotRetentionTier
+}
+
+// NewRetention returns the snapshot retention policy from the control config.
+func NewRetention(control *config.Control) Retention {
+	return Retention{
+		Count:  control.EtcdSnapshotRetention,
+		MaxAge: control.EtcdSnapshotMaxAge.Duration,
+		Tiers:  control.EtcdSnapshotTiers,
+	}
+}
+
+// Enabled returns true if any retention rule is set. Snapshots are never pruned
+// if no rules are set.
+func (r Retention) Enabled() bool {
+	return r.Count > 0 || r.MaxAge > 0 || len(r.Tiers) > 0
+}
+
+func (r Retention) String() string {
+	rules := []string{"count=" + strconv.Itoa(r.Count)}
+	if r.MaxAge > 0 {
+		rules = append(rules, "max-age="+r.MaxAge.String())
+	}
+	for _, tier := range r.Tiers {
+		rules = append(rules, fmt.Sprintf("tier=%s/%s", tier.Interval.Duration, tier.Keep.Duration))
+	}
+	return strings.Join(rules, ", ")
+}
+
+// Prune returns the files that are not retained by the policy. Files must be
+// sorted newest-first. Files without a creation time are always retained.
+func (r Retention) Prune(now time.Time, files []File) []File {
+	if !r.Enabled() {
+		return nil
+	}
+
+	retain := make([]bool, len(files))
+	for i, f := range files {
+		if i < r.Count || f.CreatedAt == nil || (r.MaxAge > 0 && now.Sub(f.CreatedAt.Time) <= r.MaxAge) {
+			retain[i] = true
+		}
+	}
+
+	// each tier keeps the newest snapshot in each interval, for snapshots within the tier's keep duration
+	for _, tier := range r.Tiers {
+		interval := tier.Interval.Duration
+		if interval <= 0 {
+			continue
+		}
+		seen := map[time.Time]bool{}
+		for i, f := range files {
+			if f.CreatedAt == nil || now.Sub(f.CreatedAt.Time) > tier.Keep.Duration {
+				continue
+			}
+			bucket := f.CreatedAt.UTC().Truncate(interval)
+			if !seen[bucket] {
+				seen[bucket] = true
+				retain[i] = true
+			}
+		}
+	}
+
+	var pruned []File
+	for i, f := range files {
+		if !retain[i] {
+			pruned = append(pruned, f)
+		}
+	}
+	return pruned
+}
+
+// globChars are the characters that have special meaning in path.Match patterns.
+const globChars = `*?[\`
+
+// MatchName returns true if the snapshot name matches the name prefix. The prefix
+// may contain glob wildcards as supported by path.Match, for example
+// "etcd-snapshot-server-1-*" or "on-demand-*-17*"; the name matches if any leading
+// part of it matches the pattern. Invalid patterns never match.
+func MatchName(prefix, name string) bool {
+	if !strings.ContainsAny(prefix, globChars) {
+		return strings.HasPrefix(name, prefix)
+	}
+	ok, _ := path.Match(prefix+"*", name)
+	return ok
+}
+
+// LiteralPrefix returns the portion of the name prefix before the first glob
+// wildcard, for use when listing snapshots from storage that only supports
+// filtering by literal prefix.
+func LiteralPrefix(prefix string) string {
+	if i := strings.IndexAny(prefix, globChars); i >= 0 {
+		return prefix[:i]
+	}
+	return prefix
+}
+
+// ValidateNamePrefix checks that the glob wildcards in the name prefix are well-formed.
+func ValidateNamePrefix(prefix string) error {
+	if _, err := path.Match(prefix, ""); err != nil {
+		return fmt.Errorf("invalid snapshot name prefix %q: %w", prefix, err)
+	}
+	return nil
+}
+
+// ParseRetentionAge parses a retention age. In addition to the units
+// supported by time.ParseDuration, whole days and weeks may be specified
+// with a "d" or "w" suffix, for example "14d".
+func ParseRetentionAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			i, err := strconv.Atoi(n)
+			if err != nil || i < 0 {
+				return 0, fmt.Errorf("invalid retention age %q", s)
+			}
+			return time.Duration(i) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid retention age %q", s)
+	}
+	return d, nil
+}
+
+// ParseRetentionTiers parses retention tiers in the form INTERVAL=KEEP, where
+// INTERVAL is one of hourly, daily, weekly, or a duration, and KEEP is the age
+// up to which one snapshot per interval is retained. For example, "hourly=2d"
+// keeps one snapshot per hour for the last two days.
+func ParseRetentionTiers(tiers []string) ([]config.SnapshotRetentionTier, error) {
+	var res []config.SnapshotRetentionTier
+	for _, t := range tiers {
+		i, k, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention tier %q: must be in the form INTERVAL=KEEP", t)
+		}
+		interval, ok := retentionIntervals[i]
+		if !ok {
+			d, err := ParseRetentionAge(i)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retention tier %q: invalid interval", t)
+			}
+			interval = d
+		}
+		keep, err := ParseRetentionAge(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention tier %q: invalid keep duration", t)
+		}
+		if interval <= 0 || keep < interval {
+			return nil, fmt.Errorf("invalid retention tier %q: keep duration must be at least one interval", t)
+		}
+		res = append(res, config.SnapshotRetentionTier{
+			Interval: metav1.Duration{Duration: interval},
+			Keep:     metav1.Duration{Duration: keep},
+		})
+	}
+	return res, nil
+}
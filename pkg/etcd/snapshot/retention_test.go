@@ -0,0 +1,194 @@
+package snapshot
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitRetentionPrune(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// snapshots are named by age in hours, and sorted newest-first
+	var files []File
+	for _, age := range []int{0, 1, 2, 3, 25, 26, 49, 50, 100} {
+		files = append(files, File{
+			Name:      "etcd-snapshot-" + strconv.Itoa(age),
+			CreatedAt: &metav1.Time{Time: now.Add(-time.Duration(age) * time.Hour)},
+		})
+	}
+
+	tier := func(interval, keep time.Duration) config.SnapshotRetentionTier {
+		return config.SnapshotRetentionTier{Interval: metav1.Duration{Duration: interval}, Keep: metav1.Duration{Duration: keep}}
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		want      []string
+	}{
+		{
+			name:      "disabled",
+			retention: Retention{},
+		},
+		{
+			name:      "count",
+			retention: Retention{Count: 3},
+			want:      []string{"etcd-snapshot-3", "etcd-snapshot-25", "etcd-snapshot-26", "etcd-snapshot-49", "etcd-snapshot-50", "etcd-snapshot-100"},
+		},
+		{
+			name:      "count keeps everything",
+			retention: Retention{Count: 10},
+		},
+		{
+			name:      "max age",
+			retention: Retention{MaxAge: 48 * time.Hour},
+			want:      []string{"etcd-snapshot-49", "etcd-snapshot-50", "etcd-snapshot-100"},
+		},
+		{
+			name:      "count and max age",
+			retention: Retention{Count: 5, MaxAge: 24 * time.Hour},
+			want:      []string{"etcd-snapshot-26", "etcd-snapshot-49", "etcd-snapshot-50", "etcd-snapshot-100"},
+		},
+		{
+			name:      "daily tier",
+			retention: Retention{Tiers: []config.SnapshotRetentionTier{tier(24*time.Hour, 72*time.Hour)}},
+			want:      []string{"etcd-snapshot-1", "etcd-snapshot-2", "etcd-snapshot-3", "etcd-snapshot-26", "etcd-snapshot-50", "etcd-snapshot-100"},
+		},
+		{
+			name: "hourly and daily tiers",
+			retention: Retention{Tiers: []config.SnapshotRetentionTier{
+				tier(time.Hour, 2*time.Hour),
+				tier(24*time.Hour, 72*time.Hour),
+			}},
+			want: []string{"etcd-snapshot-3", "etcd-snapshot-26", "etcd-snapshot-50", "etcd-snapshot-100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range tt.retention.Prune(now, files) {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Retention.Prune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitParseRetentionTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		tiers   []string
+		want    []config.SnapshotRetentionTier
+		wantErr bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:  "named intervals",
+			tiers: []string{"hourly=2d", "daily=30d", "weekly=12w"},
+			want: []config.SnapshotRetentionTier{
+				{Interval: metav1.Duration{Duration: time.Hour}, Keep: metav1.Duration{Duration: 48 * time.Hour}},
+				{Interval: metav1.Duration{Duration: 24 * time.Hour}, Keep: metav1.Duration{Duration: 720 * time.Hour}},
+				{Interval: metav1.Duration{Duration: 168 * time.Hour}, Keep: metav1.Duration{Duration: 2016 * time.Hour}},
+			},
+		},
+		{
+			name:  "duration interval",
+			tiers: []string{"6h=7d"},
+			want: []config.SnapshotRetentionTier{
+				{Interval: metav1.Duration{Duration: 6 * time.Hour}, Keep: metav1.Duration{Duration: 168 * time.Hour}},
+			},
+		},
+		{
+			name:    "missing keep",
+			tiers:   []string{"hourly"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid interval",
+			tiers:   []string{"monthly=1y"},
+			wantErr: true,
+		},
+		{
+			name:    "keep shorter than interval",
+			tiers:   []string{"daily=1h"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetentionTiers(tt.tiers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRetentionTiers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRetentionTiers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitMatchName(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		snapshot string
+		want     bool
+	}{
+		{
+			name:     "literal prefix",
+			prefix:   "etcd-snapshot",
+			snapshot: "etcd-snapshot-server-1-1700000000",
+			want:     true,
+		},
+		{
+			name:     "literal prefix mismatch",
+			prefix:   "on-demand",
+			snapshot: "etcd-snapshot-server-1-1700000000",
+		},
+		{
+			name:     "wildcard node name",
+			prefix:   "etcd-snapshot-server-1-*",
+			snapshot: "etcd-snapshot-server-1-1700000000.zip",
+			want:     true,
+		},
+		{
+			name:     "wildcard node name mismatch",
+			prefix:   "etcd-snapshot-server-1-*",
+			snapshot: "etcd-snapshot-server-2-1700000000",
+		},
+		{
+			name:     "wildcard in middle",
+			prefix:   "on-demand-*-17",
+			snapshot: "on-demand-server-3-1700000000",
+			want:     true,
+		},
+		{
+			name:     "character class",
+			prefix:   "etcd-snapshot-server-[12]-",
+			snapshot: "etcd-snapshot-server-2-1700000000",
+			want:     true,
+		},
+		{
+			name:     "invalid pattern",
+			prefix:   "etcd-snapshot-[",
+			snapshot: "etcd-snapshot-[server-1700000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchName(tt.prefix, tt.snapshot); got != tt.want {
+				t.Errorf("MatchName(%q, %q) = %v, want %v", tt.prefix, tt.snapshot, got, tt.want)
+			}
+		})
+	}
+}
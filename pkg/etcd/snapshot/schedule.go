@@ -0,0 +1,60 @@
+package snapshot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseSchedules parses additional snapshot schedules in the form "name=NAME;cron=CRON;retention=COUNT",
+// for example "name=hourly;cron=0 * * * *;retention=24". Snapshots taken on each schedule are named with
+// the schedule name as prefix, and retention for each schedule applies only to snapshots with its prefix,
+// so schedule names must not be a prefix of each other, or of the base snapshot name.
+func ParseSchedules(schedules []string, baseName string) ([]config.SnapshotSchedule, error) {
+	var res []config.SnapshotSchedule
+	for _, s := range schedules {
+		schedule := config.SnapshotSchedule{}
+		for _, field := range strings.Split(s, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid snapshot schedule %q: fields must be in the form KEY=VALUE", s)
+			}
+			switch k {
+			case "name":
+				schedule.Name = v
+			case "cron":
+				schedule.Cron = v
+			case "retention":
+				i, err := strconv.Atoi(v)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid snapshot schedule %q: invalid retention count", s)
+				}
+				schedule.Retention = i
+			default:
+				return nil, fmt.Errorf("invalid snapshot schedule %q: unknown field %q", s, k)
+			}
+		}
+
+		if errs := validation.IsDNS1123Label(schedule.Name); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid snapshot schedule %q: invalid name: %s", s, strings.Join(errs, ", "))
+		}
+		if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+			return nil, fmt.Errorf("invalid snapshot schedule %q: invalid cron spec: %v", s, err)
+		}
+		names := []string{baseName}
+		for _, r := range res {
+			names = append(names, r.Name)
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, schedule.Name) || strings.HasPrefix(schedule.Name, name) {
+				return nil, fmt.Errorf("invalid snapshot schedule %q: name overlaps with snapshot name %q", s, name)
+			}
+		}
+		res = append(res, schedule)
+	}
+	return res, nil
+}
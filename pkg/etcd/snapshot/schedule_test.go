@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+)
+
+func Test_UnitParseSchedules(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []string
+		want      []config.SnapshotSchedule
+		wantErr   bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:      "hourly and daily",
+			schedules: []string{"name=hourly;cron=0 * * * *;retention=24", "name=daily; cron=0 0 * * *; retention=30"},
+			want: []config.SnapshotSchedule{
+				{Name: "hourly", Cron: "0 * * * *", Retention: 24},
+				{Name: "daily", Cron: "0 0 * * *", Retention: 30},
+			},
+		},
+		{
+			name:      "no retention",
+			schedules: []string{"name=weekly;cron=@weekly"},
+			want:      []config.SnapshotSchedule{{Name: "weekly", Cron: "@weekly"}},
+		},
+		{
+			name:      "missing name",
+			schedules: []string{"cron=0 * * * *;retention=24"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid name",
+			schedules: []string{"name=Hourly_Snapshots;cron=0 * * * *"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid cron",
+			schedules: []string{"name=hourly;cron=every hour"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid retention",
+			schedules: []string{"name=hourly;cron=0 * * * *;retention=-1"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown field",
+			schedules: []string{"name=hourly;cron=0 * * * *;keep=24"},
+			wantErr:   true,
+		},
+		{
+			name:      "overlaps base name",
+			schedules: []string{"name=etcd-snapshot-hourly;cron=0 * * * *"},
+			wantErr:   true,
+		},
+		{
+			name:      "overlaps other schedule",
+			schedules: []string{"name=daily;cron=0 0 * * *", "name=daily-offsite;cron=0 12 * * *"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedules(tt.schedules, "etcd-snapshot")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSchedules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
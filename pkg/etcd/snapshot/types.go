@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -28,9 +30,23 @@ type Status string
 const (
 	SuccessfulStatus Status = "successful"
 	FailedStatus     Status = "failed"
+	InProgressStatus Status = "in-progress"
 
-	CompressedExtension = ".zip"
-	MetadataDir         = ".metadata"
+	// Failure reasons recorded in the ETCDSnapshotFile status.
+	ReasonSaveFailed     = "SaveFailed"
+	ReasonCompressFailed = "CompressFailed"
+	ReasonUploadFailed   = "UploadFailed"
+	ReasonClientFailed   = "ClientFailed"
+	ReasonHookFailed     = "HookFailed"
+
+	CompressedExtension     = ".zip"
+	ZstdCompressedExtension = ".zst"
+	MetadataDir             = ".metadata"
+	ChecksumDir             = ".checksums"
+	ChecksumAlgorithm       = "sha256"
+
+	CompressFormatZip  = "zip"
+	CompressFormatZstd = "zstd"
 )
 
 var (
@@ -38,6 +54,8 @@ var (
 
 	LabelStorageNode    = "etcd." + version.Program + ".cattle.io/snapshot-storage-node"
 	AnnotationTokenHash = "etcd." + version.Program + ".cattle.io/snapshot-token-hash"
+	AnnotationChecksum  = "etcd." + version.Program + ".cattle.io/snapshot-checksum"
+	AnnotationS3Target  = "etcd." + version.Program + ".cattle.io/snapshot-s3-target"
 
 	ExtraMetadataConfigMapName = version.Program + "-etcd-snapshot-extra-metadata"
 )
@@ -68,6 +86,11 @@ type File struct {
 	Status     Status       `json:"status,omitempty"`
 	S3         *S3Config    `json:"s3Config,omitempty"`
 	Compressed bool         `json:"compressed"`
+	Checksum   string       `json:"checksum,omitempty"`
+	// Reason is the step that failed, for snapshots with failed status.
+	Reason string `json:"reason,omitempty"`
+	// BytesUploaded is the number of bytes uploaded to S3, for snapshots stored on S3.
+	BytesUploaded int64 `json:"bytesUploaded,omitempty"`
 
 	// these fields are used for the internal representation of the snapshot
 	// to populate other fields before serialization to the legacy configmap.
@@ -81,11 +104,30 @@ type File struct {
 func (sf *File) GenerateConfigMapKey() string {
 	name := InvalidKeyChars.ReplaceAllString(sf.Name, "_")
 	if sf.NodeName == "s3" {
-		return "s3-" + name
+		return S3ConfigMapKey(sf.s3Target(), name)
 	}
 	return "local-" + name
 }
 
+// S3ConfigMapKey returns the configmap key for a snapshot stored on S3. Snapshots stored on
+// additional S3 targets include the target name in the key, as the same snapshot may be
+// stored on more than one target.
+func S3ConfigMapKey(target, name string) string {
+	if target != "" {
+		return "s3." + InvalidKeyChars.ReplaceAllString(target, "_") + "-" + name
+	}
+	return "s3-" + name
+}
+
+// s3Target returns the name of the additional S3 target that the snapshot is stored on,
+// or an empty string for local snapshots and snapshots stored on the etcd-s3 target.
+func (sf *File) s3Target() string {
+	if sf.S3 != nil {
+		return sf.S3.Target
+	}
+	return ""
+}
+
 // GenerateName generates a derived name for the snapshot that is safe for use
 // as a resource name.
 func (sf *File) GenerateName() string {
@@ -98,14 +140,14 @@ func (sf *File) GenerateName() string {
 	// names. Snapshots should already include the hostname, but this ensures we
 	// don't accidentally hide records if a snapshot with the same name somehow
 	// exists on multiple nodes.
-	digest := sha256.Sum256([]byte(nodename + sf.Location))
+	digest := sha256.Sum256([]byte(nodename + sf.Location + sf.s3Target()))
 	// If the lowercase filename isn't usable as a resource name, and short enough that we can include a prefix and suffix,
 	// generate a safe name derived from the hostname and timestamp.
 	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 || len(name)+13 > validation.DNS1123SubdomainMaxLength {
 		nodename, _, _ := strings.Cut(nodename, ".")
 		name = fmt.Sprintf("etcd-snapshot-%s-%d", nodename, sf.CreatedAt.Unix())
-		if sf.Compressed {
-			name += CompressedExtension
+		if basename, compressed := CutCompressedExtension(sf.Name); compressed {
+			name += sf.Name[len(basename):]
 		}
 	}
 	if sf.NodeName == "s3" {
@@ -124,11 +166,14 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 	sf.Location = esf.Spec.Location
 	sf.CreatedAt = esf.Status.CreationTime
 	sf.NodeSource = esf.Spec.NodeName
-	sf.Compressed = strings.HasSuffix(esf.Spec.SnapshotName, CompressedExtension)
+	_, sf.Compressed = CutCompressedExtension(esf.Spec.SnapshotName)
 
-	if esf.Status.ReadyToUse != nil && *esf.Status.ReadyToUse {
+	switch {
+	case esf.Status.Phase == k3s.ETCDSnapshotPhaseInProgress:
+		sf.Status = InProgressStatus
+	case esf.Status.ReadyToUse != nil && *esf.Status.ReadyToUse:
 		sf.Status = SuccessfulStatus
-	} else {
+	default:
 		sf.Status = FailedStatus
 	}
 
@@ -136,6 +181,10 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 		sf.Size = esf.Status.Size.Value()
 	}
 
+	if esf.Status.BytesUploaded != nil {
+		sf.BytesUploaded = esf.Status.BytesUploaded.Value()
+	}
+
 	if esf.Status.Error != nil {
 		if esf.Status.Error.Time != nil {
 			sf.CreatedAt = esf.Status.Error.Time
@@ -145,6 +194,9 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 			message = *esf.Status.Error.Message
 		}
 		sf.Message = base64.StdEncoding.EncodeToString([]byte(message))
+		if esf.Status.Error.Reason != nil {
+			sf.Reason = *esf.Status.Error.Reason
+		}
 	}
 
 	if len(esf.Spec.Metadata) > 0 {
@@ -159,6 +211,12 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 		sf.TokenHash = tokenHash
 	}
 
+	if esf.Status.Checksum != "" {
+		sf.Checksum = esf.Status.Checksum
+	} else if checksum := esf.Annotations[AnnotationChecksum]; checksum != "" {
+		sf.Checksum = checksum
+	}
+
 	if esf.Spec.S3 == nil {
 		sf.NodeName = esf.Spec.NodeName
 	} else {
@@ -173,6 +231,7 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 				Region:        esf.Spec.S3.Region,
 				Folder:        esf.Spec.S3.Prefix,
 				Insecure:      esf.Spec.S3.Insecure,
+				Target:        esf.Annotations[AnnotationS3Target],
 			},
 		}
 	}
@@ -187,7 +246,17 @@ func (sf *File) ToETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 	esf.Spec.Location = sf.Location
 	esf.Status.CreationTime = sf.CreatedAt
 	esf.Status.ReadyToUse = ptr.To(sf.Status == SuccessfulStatus)
+	esf.Status.Phase = sf.Phase()
 	esf.Status.Size = resource.NewQuantity(sf.Size, resource.DecimalSI)
+	esf.Status.Checksum = sf.Checksum
+	esf.Status.BytesUploaded = nil
+	if sf.S3 != nil {
+		uploaded := sf.BytesUploaded
+		if sf.Status == SuccessfulStatus {
+			uploaded = sf.Size
+		}
+		esf.Status.BytesUploaded = resource.NewQuantity(uploaded, resource.DecimalSI)
+	}
 
 	if sf.NodeSource != "" {
 		esf.Spec.NodeName = sf.NodeSource
@@ -208,6 +277,11 @@ func (sf *File) ToETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 			Time:    sf.CreatedAt,
 			Message: &message,
 		}
+		if sf.Reason != "" {
+			esf.Status.Error.Reason = ptr.To(sf.Reason)
+		}
+	} else if sf.Status != FailedStatus {
+		esf.Status.Error = nil
 	}
 
 	if sf.MetadataSource != nil {
@@ -235,10 +309,17 @@ func (sf *File) ToETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 		esf.ObjectMeta.Annotations[AnnotationTokenHash] = sf.TokenHash
 	}
 
+	if sf.Checksum != "" {
+		esf.ObjectMeta.Annotations[AnnotationChecksum] = sf.Checksum
+	}
+
 	if sf.S3 == nil {
 		esf.ObjectMeta.Labels[LabelStorageNode] = esf.Spec.NodeName
 	} else {
 		esf.ObjectMeta.Labels[LabelStorageNode] = "s3"
+		if sf.S3.Target != "" {
+			esf.ObjectMeta.Annotations[AnnotationS3Target] = sf.S3.Target
+		}
 		esf.Spec.S3 = &k3s.ETCDSnapshotS3{
 			Endpoint:      sf.S3.Endpoint,
 			EndpointCA:    sf.S3.EndpointCA,
@@ -252,6 +333,18 @@ func (sf *File) ToETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 	}
 }
 
+// Phase returns the ETCDSnapshotFile phase corresponding to the snapshot status.
+func (sf *File) Phase() k3s.ETCDSnapshotPhase {
+	switch sf.Status {
+	case SuccessfulStatus:
+		return k3s.ETCDSnapshotPhaseSucceeded
+	case InProgressStatus:
+		return k3s.ETCDSnapshotPhaseInProgress
+	default:
+		return k3s.ETCDSnapshotPhaseFailed
+	}
+}
+
 // Marshal returns the JSON encoding of the snapshot File, with metadata inlined as base64.
 func (sf *File) Marshal() ([]byte, error) {
 	if sf.MetadataSource != nil {
@@ -264,6 +357,75 @@ func (sf *File) Marshal() ([]byte, error) {
 	return json.Marshal(sf)
 }
 
+// CutCompressedExtension returns the snapshot name without any compressed file extension,
+// and a boolean indicating whether or not the name had a compressed file extension.
+func CutCompressedExtension(name string) (string, bool) {
+	if basename, ok := strings.CutSuffix(name, CompressedExtension); ok {
+		return basename, true
+	}
+	return strings.CutSuffix(name, ZstdCompressedExtension)
+}
+
+// CompressFormat returns the compression format of the snapshot, based on the file extension.
+// An empty string is returned if the snapshot is not compressed.
+func CompressFormat(name string) string {
+	switch {
+	case strings.HasSuffix(name, CompressedExtension):
+		return CompressFormatZip
+	case strings.HasSuffix(name, ZstdCompressedExtension):
+		return CompressFormatZstd
+	}
+	return ""
+}
+
+// CompressedExtensionFor returns the file extension of snapshots compressed with the given format.
+func CompressedExtensionFor(format string) string {
+	if format == CompressFormatZstd {
+		return ZstdCompressedExtension
+	}
+	return CompressedExtension
+}
+
+// ChecksumPath returns the path of the file that the checksum of a local snapshot is stored in.
+func ChecksumPath(snapshotPath string) string {
+	return filepath.Join(filepath.Dir(snapshotPath), "..", ChecksumDir, filepath.Base(snapshotPath))
+}
+
+// ComputeChecksum returns the checksum of the file at the given path,
+// in the form "<algorithm>:<hex digest>".
+func ComputeChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return ChecksumAlgorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteChecksum stores the checksum for a local snapshot.
+func WriteChecksum(snapshotPath, checksum string) error {
+	checksumPath := ChecksumPath(snapshotPath)
+	if err := os.MkdirAll(filepath.Dir(checksumPath), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(checksumPath, []byte(checksum+"\n"), 0600)
+}
+
+// ReadChecksum returns the checksum stored for a local snapshot. An empty string is
+// returned if the checksum is not available, as is the case for snapshots from old releases.
+func ReadChecksum(snapshotPath string) string {
+	b, err := os.ReadFile(ChecksumPath(snapshotPath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
 // IsNotExist returns true if the error is from http.StatusNotFound or os.IsNotExist
 func IsNotExist(err error) bool {
 	if resp := minio.ToErrorResponse(err); resp.StatusCode == http.StatusNotFound || os.IsNotExist(err) {
@@ -0,0 +1,231 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func Test_UnitCutCompressedExtension(t *testing.T) {
+	tests := []struct {
+		name           string
+		filename       string
+		wantBasename   string
+		wantCompressed bool
+	}{
+		{
+			name:           "uncompressed",
+			filename:       "etcd-snapshot-server-1-1700000000",
+			wantBasename:   "etcd-snapshot-server-1-1700000000",
+			wantCompressed: false,
+		},
+		{
+			name:           "zip",
+			filename:       "etcd-snapshot-server-1-1700000000.zip",
+			wantBasename:   "etcd-snapshot-server-1-1700000000",
+			wantCompressed: true,
+		},
+		{
+			name:           "zstd",
+			filename:       "etcd-snapshot-server-1-1700000000.zst",
+			wantBasename:   "etcd-snapshot-server-1-1700000000",
+			wantCompressed: true,
+		},
+		{
+			name:           "unknown extension",
+			filename:       "etcd-snapshot-server-1-1700000000.gz",
+			wantBasename:   "etcd-snapshot-server-1-1700000000.gz",
+			wantCompressed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basename, compressed := CutCompressedExtension(tt.filename)
+			if basename != tt.wantBasename {
+				t.Errorf("CutCompressedExtension() basename = %v, want %v", basename, tt.wantBasename)
+			}
+			if compressed != tt.wantCompressed {
+				t.Errorf("CutCompressedExtension() compressed = %v, want %v", compressed, tt.wantCompressed)
+			}
+		})
+	}
+}
+
+func Test_UnitChecksum(t *testing.T) {
+	dir := t.TempDir()
+	snapshotDir := filepath.Join(dir, "snapshots")
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	snapshotPath := filepath.Join(snapshotDir, "etcd-snapshot-server-1-1700000000")
+	if err := os.WriteFile(snapshotPath, []byte("snapshot"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ReadChecksum(snapshotPath); got != "" {
+		t.Errorf("ReadChecksum() before save = %v, want empty", got)
+	}
+
+	checksum, err := ComputeChecksum(snapshotPath)
+	if err != nil {
+		t.Fatalf("ComputeChecksum() error = %v", err)
+	}
+	want := "sha256:16a0eeb0791b6c92451fd284dd9f599e0a7dbe7f6ebea6e2d2d06c7f74aec112"
+	if checksum != want {
+		t.Errorf("ComputeChecksum() = %v, want %v", checksum, want)
+	}
+
+	wantPath := filepath.Join(dir, ChecksumDir, "etcd-snapshot-server-1-1700000000")
+	if got := filepath.Clean(ChecksumPath(snapshotPath)); got != wantPath {
+		t.Errorf("ChecksumPath() = %v, want %v", got, wantPath)
+	}
+	if err := WriteChecksum(snapshotPath, checksum); err != nil {
+		t.Fatalf("WriteChecksum() error = %v", err)
+	}
+	if got := ReadChecksum(snapshotPath); got != want {
+		t.Errorf("ReadChecksum() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitS3TargetKeys(t *testing.T) {
+	newFile := func(target string) *File {
+		return &File{
+			Name:      "etcd-snapshot-server-1-1700000000",
+			Location:  "s3://bucket/etcd-snapshot-server-1-1700000000",
+			NodeName:  "s3",
+			CreatedAt: &metav1.Time{Time: time.Unix(1700000000, 0)},
+			Status:    SuccessfulStatus,
+			S3:        &S3Config{EtcdS3: config.EtcdS3{Bucket: "bucket", Target: target}},
+		}
+	}
+
+	primary := newFile("")
+	offsite := newFile("offsite")
+	if got, want := primary.GenerateConfigMapKey(), "s3-etcd-snapshot-server-1-1700000000"; got != want {
+		t.Errorf("GenerateConfigMapKey() = %s, want %s", got, want)
+	}
+	if got, want := offsite.GenerateConfigMapKey(), "s3.offsite-etcd-snapshot-server-1-1700000000"; got != want {
+		t.Errorf("GenerateConfigMapKey() = %s, want %s", got, want)
+	}
+	if primary.GenerateName() == offsite.GenerateName() {
+		t.Errorf("GenerateName() = %s for both targets, want unique names", primary.GenerateName())
+	}
+
+	// the target must survive a round trip through the ETCDSnapshotFile, so that
+	// the configmap key and resource name can be regenerated from the resource.
+	esf := &k3s.ETCDSnapshotFile{}
+	offsite.ToETCDSnapshotFile(esf)
+	if got := esf.Annotations[AnnotationS3Target]; got != "offsite" {
+		t.Errorf("ToETCDSnapshotFile() target annotation = %q, want %q", got, "offsite")
+	}
+	sf := &File{}
+	sf.FromETCDSnapshotFile(esf)
+	if got, want := sf.GenerateConfigMapKey(), offsite.GenerateConfigMapKey(); got != want {
+		t.Errorf("GenerateConfigMapKey() after round trip = %s, want %s", got, want)
+	}
+	if got, want := sf.GenerateName(), offsite.GenerateName(); got != want {
+		t.Errorf("GenerateName() after round trip = %s, want %s", got, want)
+	}
+}
+
+func Test_UnitSnapshotStatus(t *testing.T) {
+	s3 := &S3Config{EtcdS3: config.EtcdS3{Bucket: "bucket"}}
+	tests := []struct {
+		name              string
+		file              File
+		wantPhase         k3s.ETCDSnapshotPhase
+		wantReady         bool
+		wantBytesUploaded *int64
+		wantReason        string
+	}{
+		{
+			name:      "local in progress",
+			file:      File{Status: InProgressStatus},
+			wantPhase: k3s.ETCDSnapshotPhaseInProgress,
+		},
+		{
+			name:      "local successful",
+			file:      File{Status: SuccessfulStatus, Size: 1024, Checksum: "sha256:abc"},
+			wantPhase: k3s.ETCDSnapshotPhaseSucceeded,
+			wantReady: true,
+		},
+		{
+			name:       "local failed",
+			file:       File{Status: FailedStatus, Reason: ReasonSaveFailed, Message: "ZmFpbGVk"},
+			wantPhase:  k3s.ETCDSnapshotPhaseFailed,
+			wantReason: ReasonSaveFailed,
+		},
+		{
+			name:              "s3 uploading",
+			file:              File{NodeName: "s3", S3: s3, Status: InProgressStatus, BytesUploaded: 512},
+			wantPhase:         k3s.ETCDSnapshotPhaseInProgress,
+			wantBytesUploaded: ptr.To[int64](512),
+		},
+		{
+			name:              "s3 uploaded",
+			file:              File{NodeName: "s3", S3: s3, Status: SuccessfulStatus, Size: 1024, Checksum: "sha256:abc"},
+			wantPhase:         k3s.ETCDSnapshotPhaseSucceeded,
+			wantReady:         true,
+			wantBytesUploaded: ptr.To[int64](1024),
+		},
+		{
+			name:              "s3 upload failed",
+			file:              File{NodeName: "s3", S3: s3, Status: FailedStatus, Reason: ReasonUploadFailed, Message: "ZmFpbGVk", BytesUploaded: 256},
+			wantPhase:         k3s.ETCDSnapshotPhaseFailed,
+			wantBytesUploaded: ptr.To[int64](256),
+			wantReason:        ReasonUploadFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.file.Name = "etcd-snapshot-server-1-1700000000"
+			tt.file.CreatedAt = &metav1.Time{Time: time.Unix(1700000000, 0)}
+
+			// start from an in-progress resource with an error, to ensure that status fields are updated
+			esf := &k3s.ETCDSnapshotFile{Status: k3s.ETCDSnapshotStatus{
+				Phase: k3s.ETCDSnapshotPhaseInProgress,
+				Error: &k3s.ETCDSnapshotError{},
+			}}
+			tt.file.ToETCDSnapshotFile(esf)
+			if esf.Status.Phase != tt.wantPhase {
+				t.Errorf("ToETCDSnapshotFile() phase = %s, want %s", esf.Status.Phase, tt.wantPhase)
+			}
+			if *esf.Status.ReadyToUse != tt.wantReady {
+				t.Errorf("ToETCDSnapshotFile() readyToUse = %t, want %t", *esf.Status.ReadyToUse, tt.wantReady)
+			}
+			if esf.Status.Checksum != tt.file.Checksum {
+				t.Errorf("ToETCDSnapshotFile() checksum = %s, want %s", esf.Status.Checksum, tt.file.Checksum)
+			}
+			switch {
+			case tt.wantBytesUploaded == nil && esf.Status.BytesUploaded != nil:
+				t.Errorf("ToETCDSnapshotFile() bytesUploaded = %v, want nil", esf.Status.BytesUploaded)
+			case tt.wantBytesUploaded != nil && (esf.Status.BytesUploaded == nil || esf.Status.BytesUploaded.Value() != *tt.wantBytesUploaded):
+				t.Errorf("ToETCDSnapshotFile() bytesUploaded = %v, want %d", esf.Status.BytesUploaded, *tt.wantBytesUploaded)
+			}
+			switch {
+			case tt.wantReason == "" && esf.Status.Error != nil && tt.file.Status != FailedStatus:
+				t.Errorf("ToETCDSnapshotFile() error = %+v, want nil", esf.Status.Error)
+			case tt.wantReason != "" && (esf.Status.Error == nil || esf.Status.Error.Reason == nil || *esf.Status.Error.Reason != tt.wantReason):
+				t.Errorf("ToETCDSnapshotFile() error = %+v, want reason %s", esf.Status.Error, tt.wantReason)
+			}
+
+			sf := &File{}
+			sf.FromETCDSnapshotFile(esf)
+			if sf.Status != tt.file.Status {
+				t.Errorf("FromETCDSnapshotFile() status = %s, want %s", sf.Status, tt.file.Status)
+			}
+			if sf.Checksum != tt.file.Checksum {
+				t.Errorf("FromETCDSnapshotFile() checksum = %s, want %s", sf.Checksum, tt.file.Checksum)
+			}
+			if sf.Reason != tt.wantReason {
+				t.Errorf("FromETCDSnapshotFile() reason = %s, want %s", sf.Reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package etcd
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+)
+
+func Test_UnitCompressSnapshot(t *testing.T) {
+	for _, format := range []string{snapshot.CompressFormatZip, snapshot.CompressFormatZstd} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			content := []byte("etcd snapshot contents")
+			if err := os.WriteFile(filepath.Join(dir, "snapshot"), content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			e := &ETCD{config: &config.Control{EtcdSnapshotCompression: format}}
+			compressedPath, err := e.compressSnapshot(dir, "snapshot", time.Now())
+			if err != nil {
+				t.Fatalf("compressSnapshot() error = %v", err)
+			}
+			outputPath := filepath.Join(dir, "restored")
+			if err := e.decompressSnapshotTo(compressedPath, outputPath); err != nil {
+				t.Fatalf("decompressSnapshotTo() error = %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(content) {
+				t.Errorf("decompressSnapshotTo() content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func Test_UnitDecompressSnapshotZipMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []string
+		wantErr bool
+	}{
+		{name: "single member", members: []string{"snapshot"}},
+		{name: "empty archive", wantErr: true},
+		{name: "multiple members", members: []string{"snapshot", "extra"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			zipPath := filepath.Join(dir, "snapshot"+snapshot.CompressedExtension)
+			f, err := os.Create(zipPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zw := zip.NewWriter(f)
+			for _, member := range tt.members {
+				w, err := zw.Create(member)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write([]byte(member)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			outputPath := filepath.Join(dir, "snapshot")
+			e := &ETCD{config: &config.Control{}}
+			err = e.decompressSnapshotTo(zipPath, outputPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decompressSnapshotTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(outputPath); tt.wantErr && statErr == nil {
+				t.Errorf("decompressSnapshotTo() left output file %s after error", outputPath)
+			}
+		})
+	}
+}
@@ -220,7 +220,7 @@ func (e *etcdSnapshotHandler) reconcile() error {
 	// Get a list of existing snapshots
 	snapshots := map[string]*k3s.ETCDSnapshotFile{}
 	snapshotPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (k8sruntime.Object, error) { return e.snapshots.List(opts) }))
-	snapshotPager.PageSize = snapshotListPageSize
+	snapshotPager.PageSize = e.etcd.config.GetListPageSize()
 
 	if err := snapshotPager.EachListItem(e.ctx, metav1.ListOptions{}, func(obj k8sruntime.Object) error {
 		esf, ok := obj.(*k3s.ETCDSnapshotFile)
@@ -338,7 +338,7 @@ func pruneConfigMap(snapshotConfigMap *v1.ConfigMap, pruneCount int) error {
 	var snapshotFiles []snapshot.File
 	retention := len(snapshotConfigMap.Data) - pruneCount
 	for name := range snapshotConfigMap.Data {
-		basename, compressed := strings.CutSuffix(name, snapshot.CompressedExtension)
+		basename, compressed := snapshot.CutCompressedExtension(name)
 		ts, _ := strconv.ParseInt(basename[strings.LastIndexByte(basename, '-')+1:], 10, 64)
 		snapshotFiles = append(snapshotFiles, snapshot.File{Name: name, CreatedAt: &metav1.Time{Time: time.Unix(ts, 0)}, Compressed: compressed})
 	}
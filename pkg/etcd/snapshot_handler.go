@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 
 	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type SnapshotOperation string
@@ -22,6 +26,7 @@ const (
 	SnapshotOperationList   SnapshotOperation = "list"
 	SnapshotOperationPrune  SnapshotOperation = "prune"
 	SnapshotOperationDelete SnapshotOperation = "delete"
+	SnapshotOperationVerify SnapshotOperation = "verify"
 )
 
 type SnapshotRequest struct {
@@ -31,6 +36,14 @@ type SnapshotRequest struct {
 	Compress  *bool             `json:"compress,omitempty"`
 	Retention *int              `json:"retention,omitempty"`
 	S3        *config.EtcdS3    `json:"s3,omitempty"`
+	// Compression and ZstdLevel are omitted by older clients; the server defaults are used if unset.
+	Compression *string `json:"compression,omitempty"`
+	ZstdLevel   *int    `json:"zstdLevel,omitempty"`
+	// MaxAge and Tiers are omitted by older clients; the server retention policy is used if unset.
+	MaxAge *metav1.Duration               `json:"maxAge,omitempty"`
+	Tiers  []config.SnapshotRetentionTier `json:"tiers,omitempty"`
+	// S3Targets is omitted by older clients; snapshots are only uploaded to additional S3 targets if set.
+	S3Targets []string `json:"s3Targets,omitempty"`
 
 	ctx context.Context
 }
@@ -42,7 +55,7 @@ func (sr *SnapshotRequest) context() context.Context {
 	return context.Background()
 }
 
-// snapshotHandler handles snapshot save/list/prune requests from the CLI.
+// snapshotHandler handles snapshot save/list/prune/delete/verify requests from the CLI.
 func (e *ETCD) snapshotHandler() http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		sr, err := getSnapshotRequest(req)
@@ -53,11 +66,16 @@ func (e *ETCD) snapshotHandler() http.Handler {
 		case SnapshotOperationList:
 			err = e.withRequest(sr).handleList(rw, req)
 		case SnapshotOperationSave:
+			auditlog.Annotate(req, "etcd-snapshot save", strings.Join(sr.Name, ","))
 			err = e.withRequest(sr).handleSave(rw, req)
 		case SnapshotOperationPrune:
+			auditlog.Annotate(req, "etcd-snapshot prune", "")
 			err = e.withRequest(sr).handlePrune(rw, req)
 		case SnapshotOperationDelete:
+			auditlog.Annotate(req, "etcd-snapshot delete", strings.Join(sr.Name, ","))
 			err = e.withRequest(sr).handleDelete(rw, req, sr.Name)
+		case SnapshotOperationVerify:
+			err = e.withRequest(sr).handleVerify(rw, req, sr.Name)
 		default:
 			err = e.handleInvalid(rw, req)
 		}
@@ -102,6 +120,10 @@ func (e *ETCD) handleSave(rw http.ResponseWriter, req *http.Request) error {
 }
 
 func (e *ETCD) handlePrune(rw http.ResponseWriter, req *http.Request) error {
+	if err := snapshot.ValidateNamePrefix(e.config.EtcdSnapshotName); err != nil {
+		util.SendError(err, rw, req, http.StatusBadRequest)
+		return nil
+	}
 	if e.config.EtcdS3 != nil {
 		if _, err := e.getS3Client(req.Context()); err != nil {
 			err = errors.WithMessage(err, "failed to initialize S3 client")
@@ -135,6 +157,23 @@ func (e *ETCD) handleDelete(rw http.ResponseWriter, req *http.Request, snapshots
 	return err
 }
 
+func (e *ETCD) handleVerify(rw http.ResponseWriter, req *http.Request, snapshots []string) error {
+	if e.config.EtcdS3 != nil {
+		if _, err := e.getS3Client(req.Context()); err != nil {
+			err = errors.WithMessage(err, "failed to initialize S3 client")
+			util.SendError(err, rw, req, http.StatusBadRequest)
+			return nil
+		}
+	}
+	sr, err := e.VerifySnapshots(req.Context(), snapshots)
+	if sr == nil {
+		util.SendError(err, rw, req, http.StatusInternalServerError)
+		return nil
+	}
+	sendSnapshotResponse(rw, req, sr)
+	return err
+}
+
 func (e *ETCD) handleInvalid(rw http.ResponseWriter, req *http.Request) error {
 	util.SendErrorWithID(errors.New("invalid snapshot operation"), "etcd-snapshot", rw, req, http.StatusBadRequest)
 	return nil
@@ -146,21 +185,29 @@ func (e *ETCD) withRequest(sr *SnapshotRequest) *ETCD {
 	re := &ETCD{
 		client: e.client,
 		config: &config.Control{
-			CriticalControlArgs:   e.config.CriticalControlArgs,
-			Runtime:               e.config.Runtime,
-			DataDir:               e.config.DataDir,
-			Datastore:             e.config.Datastore,
-			DisableAgent:          e.config.DisableAgent,
-			EtcdSnapshotCompress:  e.config.EtcdSnapshotCompress,
-			EtcdSnapshotName:      e.config.EtcdSnapshotName,
-			EtcdSnapshotRetention: e.config.EtcdSnapshotRetention,
-			EtcdS3:                sr.S3,
+			CriticalControlArgs:     e.config.CriticalControlArgs,
+			Runtime:                 e.config.Runtime,
+			DataDir:                 e.config.DataDir,
+			Datastore:               e.config.Datastore,
+			DisableAgent:            e.config.DisableAgent,
+			EtcdSnapshotCompress:    e.config.EtcdSnapshotCompress,
+			EtcdSnapshotCompression: e.config.EtcdSnapshotCompression,
+			EtcdSnapshotZstdLevel:   e.config.EtcdSnapshotZstdLevel,
+			EtcdSnapshotName:        e.config.EtcdSnapshotName,
+			EtcdSnapshotRetention:   e.config.EtcdSnapshotRetention,
+			EtcdSnapshotMaxAge:      e.config.EtcdSnapshotMaxAge,
+			EtcdSnapshotTiers:       e.config.EtcdSnapshotTiers,
+			EtcdS3:                  sr.S3,
+			EtcdS3Targets:           sr.S3Targets,
+			EtcdRemoteTarget:        e.config.EtcdRemoteTarget,
+			EtcdRemoteRetention:     e.config.EtcdRemoteRetention,
 		},
 		s3:         e.s3,
 		name:       e.name,
 		address:    e.address,
 		cron:       e.cron,
 		snapshotMu: e.snapshotMu,
+		defragMu:   e.defragMu,
 	}
 	if len(sr.Name) > 0 {
 		re.config.EtcdSnapshotName = sr.Name[0]
@@ -168,12 +215,24 @@ func (e *ETCD) withRequest(sr *SnapshotRequest) *ETCD {
 	if sr.Compress != nil {
 		re.config.EtcdSnapshotCompress = *sr.Compress
 	}
+	if sr.Compression != nil {
+		re.config.EtcdSnapshotCompression = *sr.Compression
+	}
+	if sr.ZstdLevel != nil {
+		re.config.EtcdSnapshotZstdLevel = *sr.ZstdLevel
+	}
 	if sr.Dir != nil {
 		re.config.EtcdSnapshotDir = *sr.Dir
 	}
 	if sr.Retention != nil {
 		re.config.EtcdSnapshotRetention = *sr.Retention
 	}
+	if sr.MaxAge != nil {
+		re.config.EtcdSnapshotMaxAge = *sr.MaxAge
+	}
+	if sr.Tiers != nil {
+		re.config.EtcdSnapshotTiers = sr.Tiers
+	}
 	return re
 }
 
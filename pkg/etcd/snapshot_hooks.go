@@ -0,0 +1,154 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	preSnapshotHook  = "pre"
+	postSnapshotHook = "post"
+
+	// hookOutputLimit is the maximum length of hook command output included in error messages.
+	hookOutputLimit = 512
+)
+
+// snapshotHookPayload is the body sent to snapshot hook URLs. The same values are passed to hook commands
+// as environment variables.
+type snapshotHookPayload struct {
+	Hook     string          `json:"hook"`
+	Snapshot string          `json:"snapshot"`
+	NodeName string          `json:"nodeName"`
+	Status   snapshot.Status `json:"status,omitempty"`
+}
+
+// isHookURL returns true if the hook is an http or https URL, rather than a command.
+func isHookURL(hook string) bool {
+	u, err := url.Parse(hook)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// preSnapshotHooks runs the pre-snapshot hooks in order, stopping at the first failure. The error is only
+// returned if the failure policy is "fail"; otherwise the snapshot is saved anyway.
+func (e *ETCD) preSnapshotHooks(ctx context.Context, sf *snapshot.File) error {
+	hooks := e.config.EtcdSnapshotHooks
+	if hooks == nil {
+		return nil
+	}
+	payload := snapshotHookPayload{Hook: preSnapshotHook, Snapshot: sf.Name, NodeName: sf.NodeName}
+	for i, hook := range hooks.Pre {
+		if err := runSnapshotHook(ctx, hook, hooks.Timeout, hooks.Env, payload); err != nil {
+			err = errors.WithMessagef(err, "pre-snapshot hook %d failed", i+1)
+			if hooks.FailurePolicy == "ignore" {
+				logrus.Warnf("Saving etcd snapshot %s despite hook failure: %v", sf.Name, err)
+				e.warningEventf(snapshot.ReasonHookFailed, "Saving snapshot %s despite hook failure: %v", sf.Name, err)
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// postSnapshotHooks runs all post-snapshot hooks, with the status of the snapshot. Post-snapshot hooks are run even
+// if the snapshot was not saved, and are not cancelled with the snapshot context, so that applications quiesced by
+// pre-snapshot hooks are always resumed. Failures are logged and recorded as events, but do not fail the snapshot.
+func (e *ETCD) postSnapshotHooks(ctx context.Context, sf *snapshot.File, status snapshot.Status) {
+	hooks := e.config.EtcdSnapshotHooks
+	if hooks == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	payload := snapshotHookPayload{Hook: postSnapshotHook, Snapshot: sf.Name, NodeName: sf.NodeName, Status: status}
+	for i, hook := range hooks.Post {
+		if err := runSnapshotHook(ctx, hook, hooks.Timeout, hooks.Env, payload); err != nil {
+			logrus.Errorf("Post-snapshot hook %d failed for etcd snapshot %s: %v", i+1, sf.Name, err)
+			e.warningEventf(snapshot.ReasonHookFailed, "Post-snapshot hook %d failed for snapshot %s: %v", i+1, sf.Name, err)
+		}
+	}
+}
+
+// runSnapshotHook runs a single hook command, or sends the payload to a hook URL, with the given timeout.
+// Errors do not include the hook itself, as commands and URLs may contain credentials.
+func runSnapshotHook(ctx context.Context, hook string, timeout time.Duration, env []string, payload snapshotHookPayload) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	if isHookURL(hook) {
+		if err := sendSnapshotHook(ctx, hook, payload); err != nil {
+			return err
+		}
+	} else if err := execSnapshotHook(ctx, hook, env, payload); err != nil {
+		return err
+	}
+	logrus.Debugf("Ran %s-snapshot hook for etcd snapshot %s in %s", payload.Hook, payload.Snapshot, time.Since(start))
+	return nil
+}
+
+// execSnapshotHook runs a hook command with sh. The hook type, snapshot name, node name, and snapshot
+// status are passed in environment variables, along with any variables set for hooks with --component-env.
+func execSnapshotHook(ctx context.Context, hook string, env []string, payload snapshotHookPayload) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Env = append(os.Environ(),
+		version.ProgramUpper+"_SNAPSHOT_HOOK="+payload.Hook,
+		version.ProgramUpper+"_SNAPSHOT_NAME="+payload.Snapshot,
+		version.ProgramUpper+"_SNAPSHOT_NODE_NAME="+payload.NodeName,
+		version.ProgramUpper+"_SNAPSHOT_STATUS="+string(payload.Status),
+	)
+	cmd.Env = append(cmd.Env, env...)
+	// do not wait indefinitely for background processes started by the hook that hold the output open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if out := strings.TrimSpace(string(output)); out != "" {
+			if len(out) > hookOutputLimit {
+				out = out[:hookOutputLimit] + "..."
+			}
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// sendSnapshotHook sends the payload to a hook URL as a POST request. Any 2xx response is considered successful.
+func sendSnapshotHook(ctx context.Context, hook string, payload snapshotHookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// do not return the error directly, as it includes the URL which may contain a secret token
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.New("failed to send request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hook returned %s", resp.Status)
+	}
+	return nil
+}
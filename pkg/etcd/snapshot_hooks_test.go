@@ -0,0 +1,74 @@
+package etcd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/version"
+)
+
+func Test_UnitRunSnapshotHook(t *testing.T) {
+	var received snapshotHookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.URL.Path == "/fail" {
+			resp.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	payload := snapshotHookPayload{Hook: postSnapshotHook, Snapshot: "etcd-snapshot-node1-1704888000", NodeName: "node1", Status: snapshot.SuccessfulStatus}
+	tests := []struct {
+		name    string
+		hook    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{
+			name: "command",
+			hook: `test "$` + version.ProgramUpper + `_SNAPSHOT_HOOK" = post && test "$` + version.ProgramUpper + `_SNAPSHOT_STATUS" = successful`,
+		},
+		{
+			name:    "failing command",
+			hook:    "echo quiesce failed; exit 1",
+			wantErr: true,
+		},
+		{
+			name:    "command timeout",
+			hook:    "sleep 10",
+			timeout: 100 * time.Millisecond,
+			wantErr: true,
+		},
+		{
+			name: "url",
+			hook: server.URL + "/ok",
+		},
+		{
+			name:    "failing url",
+			hook:    server.URL + "/fail",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			received = snapshotHookPayload{}
+			if err := runSnapshotHook(t.Context(), tt.hook, timeout, nil, payload); (err != nil) != tt.wantErr {
+				t.Errorf("runSnapshotHook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if isHookURL(tt.hook) && received != payload {
+				t.Errorf("runSnapshotHook() sent %+v, want %+v", received, payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+}
+
+// snapshotWebhookPayload is the body sent to generic snapshot webhooks.
+type snapshotWebhookPayload struct {
+	Event    string              `json:"event"`
+	Message  string              `json:"message"`
+	Time     metav1.Time         `json:"time"`
+	Snapshot snapshotWebhookFile `json:"snapshot"`
+}
+
+// snapshotWebhookFile contains the snapshot metadata sent to webhooks.
+type snapshotWebhookFile struct {
+	Name      string            `json:"name"`
+	Location  string            `json:"location,omitempty"`
+	NodeName  string            `json:"nodeName,omitempty"`
+	Storage   string            `json:"storage"`
+	Status    snapshot.Status   `json:"status"`
+	Size      int64             `json:"size,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt *metav1.Time      `json:"createdAt,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// slackWebhookPayload is the body sent to Slack-compatible incoming webhooks.
+type slackWebhookPayload struct {
+	Text string `json:"text"`
+}
+
+// newSnapshotWebhookPayload returns the webhook request body for a snapshot event, in the requested format.
+func newSnapshotWebhookPayload(webhookType, reason, message string, esf *k3s.ETCDSnapshotFile, now time.Time) ([]byte, error) {
+	sf := snapshotWebhookFile{
+		Name:      esf.Spec.SnapshotName,
+		Location:  esf.Spec.Location,
+		NodeName:  esf.Spec.NodeName,
+		Storage:   "local",
+		Status:    snapshot.FailedStatus,
+		CreatedAt: esf.Status.CreationTime,
+		Metadata:  esf.Spec.Metadata,
+	}
+	if esf.Spec.S3 != nil {
+		sf.Storage = "s3"
+	}
+	if esf.Status.ReadyToUse != nil && *esf.Status.ReadyToUse {
+		sf.Status = snapshot.SuccessfulStatus
+	}
+	if esf.Status.Size != nil {
+		sf.Size = esf.Status.Size.Value()
+	}
+	if esf.Status.Error != nil {
+		if esf.Status.Error.Time != nil {
+			sf.CreatedAt = esf.Status.Error.Time
+		}
+		if esf.Status.Error.Message != nil {
+			sf.Error = *esf.Status.Error.Message
+		}
+	}
+
+	if webhookType == "slack" {
+		lines := []string{fmt.Sprintf("[%s] %s", version.Program, message)}
+		if sf.Location != "" {
+			lines = append(lines, "Location: "+sf.Location)
+		}
+		if sf.Size > 0 {
+			lines = append(lines, fmt.Sprintf("Size: %d bytes", sf.Size))
+		}
+		return json.Marshal(slackWebhookPayload{Text: strings.Join(lines, "\n")})
+	}
+
+	return json.Marshal(snapshotWebhookPayload{
+		Event:    reason,
+		Message:  message,
+		Time:     metav1.NewTime(now),
+		Snapshot: sf,
+	})
+}
+
+// snapshotWebhook sends a snapshot event to the configured webhook, if any.
+// The request is sent in the background so that snapshot operations are not blocked
+// by a slow or unreachable webhook endpoint; failures are only logged.
+func (e *ETCD) snapshotWebhook(reason, message string, esf *k3s.ETCDSnapshotFile) {
+	if e.config.EtcdSnapshotWebhookURL == "" {
+		return
+	}
+
+	body, err := newSnapshotWebhookPayload(e.config.EtcdSnapshotWebhookType, reason, message, esf, time.Now())
+	if err != nil {
+		logrus.Errorf("Failed to marshal etcd snapshot webhook payload: %v", err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.EtcdSnapshotWebhookURL, bytes.NewReader(body))
+		if err != nil {
+			logrus.Errorf("Failed to create etcd snapshot webhook request: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := webhookClient.Do(req)
+		if err != nil {
+			// do not log the error directly, as it includes the URL which may contain a secret token
+			logrus.Warnf("Failed to send %s notification for snapshot %s to etcd snapshot webhook", reason, esf.Spec.SnapshotName)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			logrus.Warnf("Etcd snapshot webhook returned %s for %s notification for snapshot %s", resp.Status, reason, esf.Spec.SnapshotName)
+			return
+		}
+		logrus.Debugf("Sent %s notification for snapshot %s to etcd snapshot webhook", reason, esf.Spec.SnapshotName)
+	}()
+}
@@ -0,0 +1,126 @@
+package etcd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	k3s "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func Test_UnitNewSnapshotWebhookPayload(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	created := &metav1.Time{Time: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name        string
+		webhookType string
+		reason      string
+		message     string
+		esf         *k3s.ETCDSnapshotFile
+		want        map[string]any
+	}{
+		{
+			name:        "generic saved",
+			webhookType: "generic",
+			reason:      "ETCDSnapshotCreated",
+			message:     "Snapshot etcd-snapshot-1 saved on node1",
+			esf: &k3s.ETCDSnapshotFile{
+				Spec: k3s.ETCDSnapshotSpec{
+					SnapshotName: "etcd-snapshot-1",
+					NodeName:     "node1",
+					Location:     "file:///var/lib/rancher/k3s/server/db/snapshots/etcd-snapshot-1",
+				},
+				Status: k3s.ETCDSnapshotStatus{
+					ReadyToUse:   ptr.To(true),
+					Size:         resource.NewQuantity(1024, resource.DecimalSI),
+					CreationTime: created,
+				},
+			},
+			want: map[string]any{
+				"event":   "ETCDSnapshotCreated",
+				"message": "Snapshot etcd-snapshot-1 saved on node1",
+				"time":    "2024-01-10T12:00:00Z",
+				"snapshot": map[string]any{
+					"name":      "etcd-snapshot-1",
+					"location":  "file:///var/lib/rancher/k3s/server/db/snapshots/etcd-snapshot-1",
+					"nodeName":  "node1",
+					"storage":   "local",
+					"status":    "successful",
+					"size":      float64(1024),
+					"createdAt": "2024-01-10T11:59:00Z",
+				},
+			},
+		},
+		{
+			name:        "generic failed on s3",
+			webhookType: "generic",
+			reason:      "ETCDSnapshotFailed",
+			message:     "Failed to save snapshot etcd-snapshot-1 on s3: access denied",
+			esf: &k3s.ETCDSnapshotFile{
+				Spec: k3s.ETCDSnapshotSpec{
+					SnapshotName: "etcd-snapshot-1",
+					NodeName:     "s3",
+					S3:           &k3s.ETCDSnapshotS3{Bucket: "snapshots"},
+				},
+				Status: k3s.ETCDSnapshotStatus{
+					ReadyToUse: ptr.To(false),
+					Error: &k3s.ETCDSnapshotError{
+						Time:    created,
+						Message: ptr.To("access denied"),
+					},
+				},
+			},
+			want: map[string]any{
+				"event":   "ETCDSnapshotFailed",
+				"message": "Failed to save snapshot etcd-snapshot-1 on s3: access denied",
+				"time":    "2024-01-10T12:00:00Z",
+				"snapshot": map[string]any{
+					"name":      "etcd-snapshot-1",
+					"nodeName":  "s3",
+					"storage":   "s3",
+					"status":    "failed",
+					"error":     "access denied",
+					"createdAt": "2024-01-10T11:59:00Z",
+				},
+			},
+		},
+		{
+			name:        "slack deleted",
+			webhookType: "slack",
+			reason:      "ETCDSnapshotDeleted",
+			message:     "Snapshot etcd-snapshot-1 deleted",
+			esf: &k3s.ETCDSnapshotFile{
+				Spec: k3s.ETCDSnapshotSpec{
+					SnapshotName: "etcd-snapshot-1",
+					Location:     "s3://snapshots/etcd-snapshot-1",
+				},
+				Status: k3s.ETCDSnapshotStatus{
+					Size: resource.NewQuantity(1024, resource.DecimalSI),
+				},
+			},
+			want: map[string]any{
+				"text": "[k3s] Snapshot etcd-snapshot-1 deleted\nLocation: s3://snapshots/etcd-snapshot-1\nSize: 1024 bytes",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newSnapshotWebhookPayload(tt.webhookType, tt.reason, tt.message, tt.esf, now)
+			if err != nil {
+				t.Fatalf("newSnapshotWebhookPayload() error = %v", err)
+			}
+			got := map[string]any{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("newSnapshotWebhookPayload() returned invalid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newSnapshotWebhookPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	etcdserverpb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	etcdversion "go.etcd.io/etcd/api/v3/version"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// versionChangeLockTTL is the TTL, in seconds, of the lease attached to the version change lock.
+	// If the member holding the lock exits without releasing it, the lock is released when the lease expires.
+	versionChangeLockTTL = 60
+
+	// versionChangeQuorumTimeout is the time allowed for the other members to respond to a read. If they
+	// cannot, the cluster does not have quorum without this member, and it must start without coordination.
+	versionChangeQuorumTimeout = 10 * time.Second
+
+	// versionChangeHealthTimeout is the maximum time to wait for the other members to become healthy
+	// before starting with a different etcd minor version.
+	versionChangeHealthTimeout = 5 * time.Minute
+
+	// versionChangeInterval is the interval at which the version change lock and member health are checked.
+	versionChangeInterval = 5 * time.Second
+)
+
+// versionChangeLockKey is held by the member that is currently starting with a different etcd minor version.
+var versionChangeLockKey = version.Program + "/etcd/versionChangeLock"
+
+// versionFile returns the path to etcdDBDir/version, which records the etcd version last run by this member.
+func versionFile(config *config.Control) string {
+	return filepath.Join(dbDir(config), "version")
+}
+
+// membersFile returns the path to etcdDBDir/members, which records the client URLs of the other voting members.
+func membersFile(config *config.Control) string {
+	return filepath.Join(dbDir(config), "members")
+}
+
+// minorVersion returns the major and minor components of a version string.
+func minorVersion(v string) string {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return v
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// previousVersion returns the etcd minor version last run by this member, if it differs from the current
+// etcd minor version. Members that have not recorded a version are assumed to be running the current version.
+func (e *ETCD) previousVersion() (string, bool) {
+	b, err := os.ReadFile(versionFile(e.config))
+	if err != nil {
+		return "", false
+	}
+	previous := minorVersion(strings.TrimSpace(string(b)))
+	return previous, previous != minorVersion(etcdversion.Version)
+}
+
+// lockVersionChange coordinates starting an existing member with a different etcd minor version than it last ran.
+// Only one member at a time may do so: a lock is acquired in the datastore, and the other voting members must be
+// healthy before the local etcd is started. The returned function releases the lock, and must be called once the
+// local etcd is ready. If the other members cannot be reached, or do not have quorum without this member, the
+// member is started without coordination.
+func (e *ETCD) lockVersionChange(ctx context.Context, clientAccessInfo *clientaccess.Info) (func(), error) {
+	unlock := func() {}
+	previous, changed := e.previousVersion()
+	if !changed {
+		return unlock, nil
+	}
+	current := minorVersion(etcdversion.Version)
+	logrus.Infof("Etcd version changed from %s to %s; coordinating with other cluster members", previous, current)
+
+	endpoints := e.otherMemberClientURLs(ctx, clientAccessInfo)
+	for _, endpoint := range endpoints {
+		client, conn, err := getClient(ctx, e.config, endpoint)
+		if err != nil {
+			logrus.Debugf("Failed to create etcd client for %s: %v", endpoint, err)
+			continue
+		}
+
+		// Check that the other members have quorum, by making a linearizable read.
+		readCtx, cancel := context.WithTimeout(ctx, versionChangeQuorumTimeout)
+		_, err = client.Get(readCtx, versionChangeLockKey)
+		cancel()
+		if err != nil {
+			logrus.Debugf("Failed to read from etcd at %s: %v", endpoint, err)
+			conn.Close()
+			continue
+		}
+
+		lockCtx, cancelLock := context.WithCancel(ctx)
+		leases := clientv3.NewLeaseFromLeaseClient(etcdserverpb.NewLeaseClient(conn), client, versionChangeQuorumTimeout)
+		leaseID, err := e.acquireVersionChangeLock(lockCtx, client, leases, previous, current)
+		if err != nil {
+			cancelLock()
+			conn.Close()
+			return nil, errors.WithMessage(err, "failed to acquire etcd version change lock")
+		}
+		e.waitForMembersHealthy(ctx, client)
+
+		return func() {
+			ctx, cancel := context.WithTimeout(context.Background(), versionChangeQuorumTimeout)
+			defer cancel()
+			if _, err := leases.Revoke(ctx, leaseID); err != nil {
+				logrus.Warnf("Failed to release etcd version change lock: %v", err)
+			} else {
+				logrus.Infof("Released etcd version change lock")
+			}
+			cancelLock()
+			conn.Close()
+		}, nil
+	}
+
+	logrus.Warnf("Unable to reach quorum of other etcd cluster members; starting etcd %s without coordination", current)
+	return unlock, nil
+}
+
+// acquireVersionChangeLock waits until the version change lock can be acquired, and returns the ID of the lease
+// attached to it. The lease is kept alive until the context is cancelled.
+func (e *ETCD) acquireVersionChangeLock(ctx context.Context, client *clientv3.Client, leases clientv3.Lease, previous, current string) (clientv3.LeaseID, error) {
+	lease, err := leases.Grant(ctx, versionChangeLockTTL)
+	if err != nil {
+		return 0, err
+	}
+	keepAlive, err := leases.KeepAlive(ctx, lease.ID)
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		for range keepAlive {
+		}
+	}()
+
+	for {
+		resp, err := client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(versionChangeLockKey), "=", 0)).
+			Then(clientv3.OpPut(versionChangeLockKey, e.name, clientv3.WithLease(lease.ID))).
+			Else(clientv3.OpGet(versionChangeLockKey)).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+		if resp.Succeeded {
+			logrus.Infof("Acquired etcd version change lock to change from %s to %s", previous, current)
+			return lease.ID, nil
+		}
+
+		holder := ""
+		if kvs := resp.Responses[0].GetResponseRange().GetKvs(); len(kvs) > 0 {
+			holder = string(kvs[0].Value)
+		}
+		// The lock may still be held by this member, if it restarted before the lease expired.
+		if holder == e.name {
+			if _, err := client.Delete(ctx, versionChangeLockKey); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		logrus.Infof("Waiting for etcd member %s to finish changing etcd version", holder)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(versionChangeInterval):
+		}
+	}
+}
+
+// waitForMembersHealthy waits until all other voting members report a healthy status, or the health timeout expires.
+func (e *ETCD) waitForMembersHealthy(ctx context.Context, client *clientv3.Client) {
+	if err := wait.PollUntilContextTimeout(ctx, versionChangeInterval, versionChangeHealthTimeout, true, func(ctx context.Context) (bool, error) {
+		members, err := client.MemberList(ctx)
+		if err != nil {
+			logrus.Infof("Waiting for etcd member list: %v", err)
+			return false, nil
+		}
+		for _, member := range members.Members {
+			if member.IsLearner || member.Name == e.name {
+				continue
+			}
+			if err := e.memberHealthy(ctx, member); err != nil {
+				logrus.Infof("Waiting for etcd member %s to become healthy: %v", member.Name, err)
+				return false, nil
+			}
+		}
+		return true, nil
+	}); err != nil {
+		logrus.Warnf("Other etcd cluster members did not become healthy within %s; starting etcd anyway", versionChangeHealthTimeout)
+	}
+}
+
+// memberHealthy returns an error if a member cannot be reached, or reports errors.
+func (e *ETCD) memberHealthy(ctx context.Context, member *etcdserverpb.Member) error {
+	if len(member.ClientURLs) == 0 {
+		return errors.New("member has no client URLs")
+	}
+	client, conn, err := getClient(ctx, e.config, member.ClientURLs[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	status, err := client.Status(ctx, member.ClientURLs[0])
+	if err != nil {
+		return err
+	}
+	if len(status.Errors) > 0 {
+		return errors.New(strings.Join(status.Errors, ", "))
+	}
+	return nil
+}
+
+// otherMemberClientURLs returns the client URLs of the other voting members, as last recorded by this member,
+// or as retrieved from the server being joined if none were recorded.
+func (e *ETCD) otherMemberClientURLs(ctx context.Context, clientAccessInfo *clientaccess.Info) []string {
+	var clientURLs []string
+	if b, err := os.ReadFile(membersFile(e.config)); err == nil {
+		if err := json.Unmarshal(b, &clientURLs); err != nil {
+			logrus.Warnf("Failed to read recorded etcd member client URLs: %v", err)
+		}
+	}
+	if len(clientURLs) == 0 && clientAccessInfo != nil {
+		urls, _, err := ClientURLs(ctx, clientAccessInfo, e.config.PrivateIP)
+		if err != nil {
+			logrus.Warnf("Failed to retrieve etcd member client URLs: %v", err)
+		}
+		clientURLs = urls
+	}
+	return clientURLs
+}
+
+// manageVersion records the etcd version and the client URLs of the other voting members once the local etcd is
+// ready, and releases the version change lock. While running, the recorded client URLs are kept up to date, and
+// the leader enables a cluster downgrade if a downgrade version is configured.
+func (e *ETCD) manageVersion(ctx context.Context, unlock func()) {
+	select {
+	case <-executor.ETCDReadyChan():
+	case <-ctx.Done():
+		unlock()
+		return
+	}
+
+	if err := os.WriteFile(versionFile(e.config), []byte(etcdversion.Version+"\n"), 0600); err != nil {
+		logrus.Warnf("Failed to record etcd version: %v", err)
+	}
+	unlock()
+
+	var recorded []string
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, manageTickerTime)
+		defer cancel()
+
+		client := e.client
+		if client == nil {
+			return
+		}
+
+		members, err := client.MemberList(ctx)
+		if err != nil {
+			logrus.Debugf("Failed to get etcd members for version management: %v", err)
+			return
+		}
+		clientURLs := []string{}
+		for _, member := range members.Members {
+			if !member.IsLearner && member.Name != e.name {
+				clientURLs = append(clientURLs, member.ClientURLs...)
+			}
+		}
+		slices.Sort(clientURLs)
+		if !slices.Equal(clientURLs, recorded) {
+			if err := writeMembersFile(membersFile(e.config), clientURLs); err != nil {
+				logrus.Warnf("Failed to record etcd member client URLs: %v", err)
+			} else {
+				recorded = clientURLs
+			}
+		}
+
+		if e.config.EtcdDowngradeVersion != "" {
+			if err := e.enableDowngrade(ctx, client); err != nil {
+				logrus.Errorf("Failed to enable etcd cluster downgrade to %s: %v", e.config.EtcdDowngradeVersion, err)
+			}
+		}
+	}, manageTickerTime)
+}
+
+// enableDowngrade enables a downgrade of the cluster to the configured version, if the local member is the leader
+// and a downgrade to that version is not already enabled. Once enabled, etcd lowers the cluster version, and members
+// may be restarted one at a time with the older version.
+func (e *ETCD) enableDowngrade(ctx context.Context, client *clientv3.Client) error {
+	status, err := client.Status(ctx, getEndpoints(e.config)[0])
+	if err != nil {
+		return err
+	}
+	if status.Header.MemberId != status.Leader {
+		return nil
+	}
+	target := minorVersion(e.config.EtcdDowngradeVersion)
+	if info := status.GetDowngradeInfo(); info.GetEnabled() && minorVersion(info.GetTargetVersion()) == target {
+		return nil
+	}
+	if minorVersion(status.Version) == target {
+		return nil
+	}
+	if _, err := client.Downgrade(ctx, clientv3.DowngradeValidate, target); err != nil {
+		return err
+	}
+	if _, err := client.Downgrade(ctx, clientv3.DowngradeEnable, target); err != nil {
+		return err
+	}
+	logrus.Infof("Enabled etcd cluster downgrade to %s; servers may now be downgraded one at a time", target)
+	return nil
+}
+
+// writeMembersFile records a list of client URLs to a file.
+func writeMembersFile(path string, clientURLs []string) error {
+	w := &bytes.Buffer{}
+	if err := json.NewEncoder(w).Encode(clientURLs); err != nil {
+		return err
+	}
+	return os.WriteFile(path, w.Bytes(), 0600)
+}
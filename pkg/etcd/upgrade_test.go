@@ -0,0 +1,89 @@
+package etcd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	etcdversion "go.etcd.io/etcd/api/v3/version"
+)
+
+func Test_UnitMinorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "3.6.14", want: "3.6"},
+		{version: "v3.5.21", want: "3.5"},
+		{version: "3.5", want: "3.5"},
+		{version: "3", want: "3"},
+	}
+	for _, tt := range tests {
+		if got := minorVersion(tt.version); got != tt.want {
+			t.Errorf("minorVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func Test_UnitPreviousVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		recorded    string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name: "not recorded",
+		},
+		{
+			name:     "same minor version",
+			recorded: minorVersion(etcdversion.Version) + ".0\n",
+			want:     minorVersion(etcdversion.Version),
+		},
+		{
+			name:        "different minor version",
+			recorded:    "3.4.37\n",
+			want:        "3.4",
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &ETCD{config: &config.Control{DataDir: t.TempDir()}}
+			if tt.recorded != "" {
+				if err := os.MkdirAll(dbDir(e.config), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(versionFile(e.config), []byte(tt.recorded), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, changed := e.previousVersion()
+			if got != tt.want || changed != tt.wantChanged {
+				t.Errorf("previousVersion() = %q, %v, want %q, %v", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func Test_UnitOtherMemberClientURLs(t *testing.T) {
+	e := &ETCD{config: &config.Control{DataDir: t.TempDir()}}
+	if urls := e.otherMemberClientURLs(t.Context(), nil); len(urls) != 0 {
+		t.Errorf("otherMemberClientURLs() = %v, want none", urls)
+	}
+
+	want := []string{"https://10.0.0.2:2379", "https://10.0.0.3:2379"}
+	path := membersFile(e.config)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMembersFile(path, want); err != nil {
+		t.Fatal(err)
+	}
+	urls := e.otherMemberClientURLs(t.Context(), nil)
+	if !slices.Equal(urls, want) {
+		t.Errorf("otherMemberClientURLs() = %v, want %v", urls, want)
+	}
+}
@@ -0,0 +1,101 @@
+package etcd
+
+import (
+	"context"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// manageWitness ensures that an etcd witness does not remain the cluster leader. A witness is a
+// full voting member, so it may win an election - but as it is expected to run on a smaller
+// node without a local apiserver, leadership is handed off to a healthy full member whenever
+// this member is found to be the leader.
+func (e *ETCD) manageWitness(ctx context.Context) {
+	if executor.IsSelfHosted() {
+		// if the executor requires cri/kubelet to be up to run etcd, wait for container runtime
+		select {
+		case <-executor.CRIReadyChan():
+		case <-ctx.Done():
+			return
+		}
+	}
+	logrus.Infof("Running as etcd witness; leadership will be transferred to other members")
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, manageTickerTime)
+		defer cancel()
+
+		if e.client == nil {
+			logrus.Debug("Etcd client was nil")
+			return
+		}
+
+		status, err := e.status(ctx)
+		if err != nil {
+			logrus.Debugf("Failed to check local etcd status for witness leader transfer: %v", err)
+			return
+		} else if status.Header.MemberId != status.Leader {
+			return
+		}
+
+		members, err := e.client.MemberList(ctx)
+		if err != nil {
+			logrus.Errorf("Failed to get etcd members for witness leader transfer: %v", err)
+			return
+		}
+
+		self, target := leaderTransferTarget(status.Header.MemberId, members.Members, func(url string) bool {
+			_, err := e.getETCDStatus(ctx, url)
+			return err == nil
+		})
+		if self == nil || target == nil {
+			logrus.Warn("Etcd witness is the cluster leader, but no healthy voting member is available to transfer leadership to")
+			return
+		}
+		if err := e.moveLeader(ctx, self, target); err != nil {
+			logrus.Errorf("Failed to transfer etcd leadership from witness: %v", err)
+		}
+	}, manageTickerTime)
+}
+
+// leaderTransferTarget returns the local member entry, and the first healthy voting member
+// that leadership can be transferred to. Learners and members without client URLs are not eligible,
+// as they cannot become leader or cannot be checked.
+func leaderTransferTarget(selfID uint64, members []*etcdserverpb.Member, healthy func(url string) bool) (self, target *etcdserverpb.Member) {
+	for _, member := range members {
+		if member.ID == selfID {
+			self = member
+			continue
+		}
+		if target != nil || member.IsLearner || len(member.ClientURLs) == 0 {
+			continue
+		}
+		if healthy(member.ClientURLs[0]) {
+			target = member
+		}
+	}
+	return self, target
+}
+
+// ConfigureWitness validates the server configuration for an etcd witness, and disables the
+// control-plane components, as witnesses only run etcd. Witnesses must join an existing cluster,
+// and require embedded etcd.
+func ConfigureWitness(control *config.Control) error {
+	if !control.EtcdWitness {
+		return nil
+	}
+	if control.JoinURL == "" {
+		return errors.New("invalid flag use; --server is required with --etcd-witness")
+	}
+	if control.DisableETCD || control.Datastore.Endpoint != "" {
+		return errors.New("invalid flag use; --etcd-witness requires embedded etcd")
+	}
+	control.DisableAPIServer = true
+	control.DisableControllerManager = true
+	control.DisableScheduler = true
+	return nil
+}
@@ -197,9 +197,15 @@ func (e *Embedded) Bootstrap(ctx context.Context, nodeConfig *daemonconfig.Node,
 func (e *Embedded) Kubelet(ctx context.Context, args []string) error {
 	command := kubelet.NewKubeletCommand(context.Background())
 	command.SetArgs(args)
+	ctx, release := signals.PhaseContext(ctx, signals.PhaseKubelet)
 
 	go func() {
-		<-e.APIServerReadyChan()
+		defer release()
+		select {
+		case <-e.APIServerReadyChan():
+		case <-ctx.Done():
+			return
+		}
 		defer func() {
 			if err := recover(); err != nil {
 				logrus.WithField("stack", string(debug.Stack())).Fatalf("kubelet panic: %v", err)
@@ -272,10 +278,17 @@ func (e *Embedded) APIServer(ctx context.Context, args []string) error {
 func (e *Embedded) Scheduler(ctx context.Context, nodeReady <-chan struct{}, args []string) error {
 	command := sapp.NewSchedulerCommand()
 	command.SetArgs(args)
+	ctx, release := signals.PhaseContext(ctx, signals.PhaseControllers)
 
 	go func() {
-		<-e.APIServerReadyChan()
-		<-nodeReady
+		defer release()
+		for _, ready := range []<-chan struct{}{e.APIServerReadyChan(), nodeReady} {
+			select {
+			case <-ready:
+			case <-ctx.Done():
+				return
+			}
+		}
 		defer func() {
 			if err := recover(); err != nil {
 				logrus.WithField("stack", string(debug.Stack())).Fatalf("scheduler panic: %v", err)
@@ -294,9 +307,15 @@ func (e *Embedded) Scheduler(ctx context.Context, nodeReady <-chan struct{}, arg
 func (e *Embedded) ControllerManager(ctx context.Context, args []string) error {
 	command := cmapp.NewControllerManagerCommand()
 	command.SetArgs(args)
+	ctx, release := signals.PhaseContext(ctx, signals.PhaseControllers)
 
 	go func() {
-		<-e.APIServerReadyChan()
+		defer release()
+		select {
+		case <-e.APIServerReadyChan():
+		case <-ctx.Done():
+			return
+		}
 		defer func() {
 			if err := recover(); err != nil {
 				logrus.WithField("stack", string(debug.Stack())).Fatalf("controller-manager panic: %v", err)
@@ -329,7 +348,7 @@ func (*Embedded) CloudControllerManager(ctx context.Context, ccmRBACReady <-chan
 		return cloud
 	}
 
-
+	ctx, release := signals.PhaseContext(ctx, signals.PhaseControllers)
 	command := ccmapp.NewCloudControllerManagerCommand(
 		ccmOptions,
 		cloudInitializer,
@@ -339,7 +358,12 @@ func (*Embedded) CloudControllerManager(ctx context.Context, ccmRBACReady <-chan
 	command.SetArgs(args)
 
 	go func() {
-		<-ccmRBACReady
+		defer release()
+		select {
+		case <-ccmRBACReady:
+		case <-ctx.Done():
+			return
+		}
 		defer func() {
 			if err := recover(); err != nil {
 				logrus.WithField("stack", string(debug.Stack())).Fatalf("cloud-controller-manager panic: %v", err)
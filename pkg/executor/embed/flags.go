@@ -0,0 +1,64 @@
+//go:build !no_embedded_executor
+
+package embed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	cliflag "k8s.io/component-base/cli/flag"
+	apiapp "k8s.io/kubernetes/cmd/kube-apiserver/app"
+	cmapp "k8s.io/kubernetes/cmd/kube-controller-manager/app"
+	proxy "k8s.io/kubernetes/cmd/kube-proxy/app"
+	sapp "k8s.io/kubernetes/cmd/kube-scheduler/app"
+	kubeletoptions "k8s.io/kubernetes/cmd/kubelet/app/options"
+)
+
+// explicit interface check
+var _ executor.FlagSetProvider = &Embedded{}
+
+// flagSets contains functions that build the flag set of each embedded component. Building a flag set
+// requires constructing the component's command and options, so each flag set is only built the first
+// time it is needed, and is then reused.
+var flagSets = map[string]func() *pflag.FlagSet{
+	executor.ComponentAPIServer: sync.OnceValue(func() *pflag.FlagSet {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return apiapp.NewAPIServerCommand(ctx.Done()).Flags()
+	}),
+	executor.ComponentControllerManager: sync.OnceValue(func() *pflag.FlagSet {
+		return cmapp.NewControllerManagerCommand().Flags()
+	}),
+	executor.ComponentScheduler: sync.OnceValue(func() *pflag.FlagSet {
+		return sapp.NewSchedulerCommand().Flags()
+	}),
+	executor.ComponentKubeProxy: sync.OnceValue(func() *pflag.FlagSet {
+		return proxy.NewProxyCommand().Flags()
+	}),
+	executor.ComponentKubelet: sync.OnceValue(func() *pflag.FlagSet {
+		// The kubelet command disables cobra flag parsing, and parses its own flag set; build an equivalent
+		// flag set here as it is not accessible from the command.
+		kubeletConfig, err := kubeletoptions.NewKubeletConfiguration()
+		if err != nil {
+			logrus.Warnf("Failed to create kubelet configuration for flag validation: %v", err)
+			return nil
+		}
+		fs := pflag.NewFlagSet(executor.ComponentKubelet, pflag.ContinueOnError)
+		fs.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
+		kubeletoptions.NewKubeletFlags().AddFlags(fs)
+		kubeletoptions.AddKubeletConfigFlags(fs, kubeletConfig)
+		kubeletoptions.AddGlobalFlags(fs)
+		return fs
+	}),
+}
+
+// FlagSet returns the flag set of the embedded component, for use when validating extra args.
+func (*Embedded) FlagSet(component string) *pflag.FlagSet {
+	if newFlagSet, ok := flagSets[component]; ok {
+		return newFlagSet()
+	}
+	return nil
+}
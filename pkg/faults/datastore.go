@@ -0,0 +1,138 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DatastoreEndpoints starts a proxy for each local datastore endpoint, that applies the datastore-delay fault to
+// requests from the apiserver. The comma-separated endpoints are returned with local endpoints replaced by the
+// proxy addresses. Datastore traffic is usually encrypted, so the proxy cannot distinguish writes from reads; all
+// data sent to the datastore is delayed. Endpoints that are not unix sockets or on the loopback interface are
+// returned unchanged, as the datastore server certificate is not expected to be valid for the proxy address.
+func (i *Injector) DatastoreEndpoints(ctx context.Context, endpoints string) string {
+	var proxied []string
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		proxy, err := i.datastoreProxy(ctx, endpoint)
+		if err != nil {
+			logrus.Warnf("Datastore delay faults will not be applied to %s: %v", endpoint, err)
+			proxied = append(proxied, endpoint)
+			continue
+		}
+		logrus.Infof("Proxying datastore endpoint %s via %s for fault injection", endpoint, proxy)
+		proxied = append(proxied, proxy)
+	}
+	return strings.Join(proxied, ",")
+}
+
+// datastoreProxy starts a proxy for a datastore endpoint, and returns the proxy endpoint.
+func (i *Injector) datastoreProxy(ctx context.Context, endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	var network, address, listenAddress string
+	switch u.Scheme {
+	case "unix", "unixs":
+		// unix socket paths may be relative, in which case they are parsed as the host
+		network = "unix"
+		address = u.Host + u.Path
+		listenAddress = strings.TrimSuffix(address, ".sock") + "-faults.sock"
+		if err := os.Remove(listenAddress); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	case "http", "https":
+		if !isLoopback(u.Hostname()) {
+			return "", errors.New("datastore is not on the loopback interface")
+		}
+		network = "tcp"
+		address = u.Host
+		listenAddress = net.JoinHostPort(u.Hostname(), "0")
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, network, listenAddress)
+	if err != nil {
+		return "", err
+	}
+	context.AfterFunc(ctx, func() { listener.Close() })
+	go i.serveDatastoreProxy(ctx, listener, network, address)
+
+	proxy := *u
+	switch {
+	case network == "tcp":
+		proxy.Host = listener.Addr().String()
+	case u.Host != "":
+		proxy.Host = listenAddress
+	default:
+		proxy.Path = listenAddress
+	}
+	return proxy.String(), nil
+}
+
+// serveDatastoreProxy accepts connections from the apiserver, and proxies them to the datastore.
+func (i *Injector) serveDatastoreProxy(ctx context.Context, listener net.Listener, network, address string) {
+	dialer := &net.Dialer{}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.Errorf("Datastore fault injection proxy failed to accept connection: %v", err)
+			}
+			return
+		}
+		go func() {
+			defer conn.Close()
+			upstream, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				logrus.Errorf("Datastore fault injection proxy failed to dial %s: %v", address, err)
+				return
+			}
+			defer upstream.Close()
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+			i.copyDelayed(upstream, conn)
+		}()
+	}
+}
+
+// copyDelayed copies from src to dst, delaying each write by the current datastore delay.
+func (i *Injector) copyDelayed(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if delay := i.datastoreDelay(); delay > 0 {
+				time.Sleep(delay)
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// isLoopback returns true if the host is localhost or a loopback address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
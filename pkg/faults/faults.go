@@ -0,0 +1,237 @@
+// Package faults injects faults into a running server, so that failure handling in HA clusters can be rehearsed
+// against a real binary in a staging environment. Faults are only available when enabled with a hidden flag,
+// and are injected through the supervisor API.
+package faults
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Fault is a type of fault that can be injected.
+type Fault string
+
+const (
+	// FaultDropTunnels closes all agent tunnel connections to this server. Agents reconnect as they would
+	// following a network interruption.
+	FaultDropTunnels Fault = "drop-tunnels"
+	// FaultDatastoreDelay delays requests from the apiserver to the datastore.
+	FaultDatastoreDelay Fault = "datastore-delay"
+	// FaultPause pauses a component, which responds to all requests with a 503 status.
+	FaultPause Fault = "pause"
+)
+
+const (
+	// ComponentAPIServer is the apiserver, as accessed through the supervisor listener.
+	ComponentAPIServer = "apiserver"
+	// ComponentSupervisor is the supervisor API, excluding the fault injection endpoint.
+	ComponentSupervisor = "supervisor"
+)
+
+// Request is a request to inject a fault. Delay is the delay added to datastore requests by the datastore-delay
+// fault, and Component is the component paused by the pause fault. Duration is how long the fault remains active,
+// and is required for all faults other than drop-tunnels.
+type Request struct {
+	Fault     Fault           `json:"fault"`
+	Component string          `json:"component,omitempty"`
+	Delay     metav1.Duration `json:"delay,omitempty"`
+	Duration  metav1.Duration `json:"duration,omitempty"`
+}
+
+// Status lists the faults that are currently active, along with when they expire.
+type Status struct {
+	DatastoreDelay  metav1.Duration        `json:"datastoreDelay,omitempty"`
+	DatastoreExpiry *metav1.Time           `json:"datastoreExpiry,omitempty"`
+	Paused          map[string]metav1.Time `json:"paused,omitempty"`
+	Tunnels         int                    `json:"tunnels"`
+}
+
+// Injector tracks active faults, and provides handler and connection wrappers that apply them.
+type Injector struct {
+	mu          sync.Mutex
+	conns       map[net.Conn]struct{}
+	delay       time.Duration
+	delayExpiry time.Time
+	paused      map[string]time.Time
+}
+
+// NewInjector returns a new fault injector, with no faults active.
+func NewInjector() *Injector {
+	return &Injector{
+		conns:  map[net.Conn]struct{}{},
+		paused: map[string]time.Time{},
+	}
+}
+
+// Inject injects the requested fault.
+func (i *Injector) Inject(req Request) error {
+	if req.Duration.Duration < 0 || req.Delay.Duration < 0 {
+		return errors.New("duration and delay must not be negative")
+	}
+	switch req.Fault {
+	case FaultDropTunnels:
+		i.dropTunnels()
+		return nil
+	case FaultDatastoreDelay:
+		if req.Delay.Duration == 0 {
+			return fmt.Errorf("delay must be set for fault %q", req.Fault)
+		}
+	case FaultPause:
+		if !slices.Contains([]string{ComponentAPIServer, ComponentSupervisor}, req.Component) {
+			return fmt.Errorf("invalid component %q for fault %q: must be one of '%s', '%s'", req.Component, req.Fault, ComponentAPIServer, ComponentSupervisor)
+		}
+	default:
+		return fmt.Errorf("unknown fault %q", req.Fault)
+	}
+	if req.Duration.Duration == 0 {
+		return fmt.Errorf("duration must be set for fault %q", req.Fault)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	expiry := time.Now().Add(req.Duration.Duration)
+	if req.Fault == FaultDatastoreDelay {
+		logrus.Warnf("Injecting fault %s: delaying datastore requests by %s for %s", req.Fault, req.Delay.Duration, req.Duration.Duration)
+		i.delay = req.Delay.Duration
+		i.delayExpiry = expiry
+	} else {
+		logrus.Warnf("Injecting fault %s: pausing %s for %s", req.Fault, req.Component, req.Duration.Duration)
+		i.paused[req.Component] = expiry
+	}
+	return nil
+}
+
+// dropTunnels closes all tracked tunnel connections.
+func (i *Injector) dropTunnels() {
+	i.mu.Lock()
+	conns := slices.Collect(maps.Keys(i.conns))
+	i.mu.Unlock()
+
+	logrus.Warnf("Injecting fault %s: closing %d tunnel connections", FaultDropTunnels, len(conns))
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Clear removes all active faults. Tunnel connections that have already been closed are not affected.
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	logrus.Warn("Clearing all injected faults")
+	i.delay = 0
+	i.delayExpiry = time.Time{}
+	clear(i.paused)
+}
+
+// Status returns the currently active faults.
+func (i *Injector) Status() Status {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now()
+	status := Status{Paused: map[string]metav1.Time{}, Tunnels: len(i.conns)}
+	if i.delayExpiry.After(now) {
+		status.DatastoreDelay = metav1.Duration{Duration: i.delay}
+		status.DatastoreExpiry = &metav1.Time{Time: i.delayExpiry}
+	}
+	for component, expiry := range i.paused {
+		if expiry.After(now) {
+			status.Paused[component] = metav1.Time{Time: expiry}
+		}
+	}
+	return status
+}
+
+// datastoreDelay returns the delay currently applied to datastore requests.
+func (i *Injector) datastoreDelay() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if time.Now().Before(i.delayExpiry) {
+		return i.delay
+	}
+	return 0
+}
+
+// isPaused returns true if the component is currently paused.
+func (i *Injector) isPaused(component string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Now().Before(i.paused[component])
+}
+
+// Pause wraps a handler for a component, so that it responds with a 503 status while the component is paused.
+func (i *Injector) Pause(component string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if i.isPaused(component) {
+			http.Error(resp, component+" is paused by fault injection", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// Tunnels wraps the tunnel server handler, tracking the connections that it hijacks so that they can be closed
+// by the drop-tunnels fault.
+func (i *Injector) Tunnels(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&hijackTracker{ResponseWriter: resp, injector: i}, req)
+	})
+}
+
+func (i *Injector) track(conn net.Conn) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.conns[conn] = struct{}{}
+}
+
+func (i *Injector) untrack(conn net.Conn) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.conns, conn)
+}
+
+// hijackTracker records connections hijacked from the wrapped response writer with the injector.
+type hijackTracker struct {
+	http.ResponseWriter
+	injector *Injector
+}
+
+func (h *hijackTracker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn = &trackedConn{Conn: conn, injector: h.injector}
+	h.injector.track(conn)
+	return conn, rw, nil
+}
+
+func (h *hijackTracker) Flush() {
+	if flusher, ok := h.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// trackedConn stops tracking the connection when it is closed.
+type trackedConn struct {
+	net.Conn
+	injector *Injector
+}
+
+func (c *trackedConn) Close() error {
+	c.injector.untrack(c)
+	return c.Conn.Close()
+}
@@ -0,0 +1,145 @@
+package faults
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitInject(t *testing.T) {
+	minute := metav1.Duration{Duration: time.Minute}
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{
+			name: "drop tunnels",
+			req:  Request{Fault: FaultDropTunnels},
+		},
+		{
+			name: "datastore delay",
+			req:  Request{Fault: FaultDatastoreDelay, Delay: metav1.Duration{Duration: time.Second}, Duration: minute},
+		},
+		{
+			name:    "datastore delay without delay",
+			req:     Request{Fault: FaultDatastoreDelay, Duration: minute},
+			wantErr: true,
+		},
+		{
+			name: "pause apiserver",
+			req:  Request{Fault: FaultPause, Component: ComponentAPIServer, Duration: minute},
+		},
+		{
+			name:    "pause without duration",
+			req:     Request{Fault: FaultPause, Component: ComponentSupervisor},
+			wantErr: true,
+		},
+		{
+			name:    "pause unknown component",
+			req:     Request{Fault: FaultPause, Component: "kubelet", Duration: minute},
+			wantErr: true,
+		},
+		{
+			name:    "unknown fault",
+			req:     Request{Fault: "disk-full", Duration: minute},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewInjector().Inject(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("Inject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitPause(t *testing.T) {
+	injector := NewInjector()
+	handler := injector.Pause(ComponentAPIServer, http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusOK)
+	}))
+	serve := func() int {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/version", nil))
+		return resp.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("status before pause = %d, want %d", code, http.StatusOK)
+	}
+	if err := injector.Inject(Request{Fault: FaultPause, Component: ComponentAPIServer, Duration: metav1.Duration{Duration: time.Minute}}); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("status while paused = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if _, ok := injector.Status().Paused[ComponentAPIServer]; !ok {
+		t.Errorf("Status() does not include paused %s", ComponentAPIServer)
+	}
+	injector.Clear()
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("status after clear = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func Test_UnitDatastoreEndpoints(t *testing.T) {
+	// echo server standing in for the datastore
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 64)
+				n, _ := conn.Read(buf)
+				conn.Write(buf[:n])
+			}()
+		}
+	}()
+
+	injector := NewInjector()
+	remote := "https://etcd.example.com:2379"
+	endpoints := injector.DatastoreEndpoints(t.Context(), "http://"+listener.Addr().String()+","+remote)
+	proxy, unchanged, _ := strings.Cut(endpoints, ",")
+	if unchanged != remote {
+		t.Errorf("remote endpoint = %q, want %q", unchanged, remote)
+	}
+	if proxy == "http://"+listener.Addr().String() {
+		t.Fatalf("local endpoint was not proxied")
+	}
+
+	delay := 200 * time.Millisecond
+	if err := injector.Inject(Request{Fault: FaultDatastoreDelay, Delay: metav1.Duration{Duration: delay}, Duration: metav1.Duration{Duration: time.Minute}}); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Read() = %q, %v, want %q", buf, err, "ping")
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("request completed in %s, want at least %s", elapsed, delay)
+	}
+}
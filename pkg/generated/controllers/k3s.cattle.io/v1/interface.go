@@ -21,6 +21,7 @@ func init() {
 type Interface interface {
 	Addon() AddonController
 	ETCDSnapshotFile() ETCDSnapshotFileController
+	ETCDSnapshotRestore() ETCDSnapshotRestoreController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -40,3 +41,7 @@ func (v *version) Addon() AddonController {
 func (v *version) ETCDSnapshotFile() ETCDSnapshotFileController {
 	return generic.NewNonNamespacedController[*v1.ETCDSnapshotFile, *v1.ETCDSnapshotFileList](schema.GroupVersionKind{Group: "k3s.cattle.io", Version: "v1", Kind: "ETCDSnapshotFile"}, "etcdsnapshotfiles", v.controllerFactory)
 }
+
+func (v *version) ETCDSnapshotRestore() ETCDSnapshotRestoreController {
+	return generic.NewNonNamespacedController[*v1.ETCDSnapshotRestore, *v1.ETCDSnapshotRestoreList](schema.GroupVersionKind{Group: "k3s.cattle.io", Version: "v1", Kind: "ETCDSnapshotRestore"}, "etcdsnapshotrestores", v.controllerFactory)
+}
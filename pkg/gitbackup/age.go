@@ -0,0 +1,234 @@
+package gitbackup
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// This file implements encryption to age X25519 recipients, as described at https://age-encryption.org/v1.
+// Only encryption is needed, as the backup is decrypted offline with the age or sops CLI.
+
+const (
+	ageIntro         = "age-encryption.org/v1\n"
+	ageX25519Label   = "age-encryption.org/v1/X25519"
+	ageRecipientHRP  = "age"
+	ageFileKeySize   = 16
+	agePayloadChunk  = 64 * 1024
+	ageStanzaColumns = 64
+
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+	ageArmorFooter = "-----END AGE ENCRYPTED FILE-----"
+)
+
+var b64 = base64.RawStdEncoding
+
+// ageRecipient is an age X25519 recipient public key.
+type ageRecipient struct {
+	encoded string
+	key     *ecdh.PublicKey
+}
+
+// parseAgeRecipient parses an age X25519 recipient, which is a bech32 encoded public key with the "age" prefix.
+func parseAgeRecipient(s string) (*ageRecipient, error) {
+	hrp, key, err := bech32Decode(s)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid age recipient %q", s)
+	}
+	if hrp != ageRecipientHRP {
+		return nil, errors.New("invalid age recipient " + s + ": must start with age1")
+	}
+	pub, err := ecdh.X25519().NewPublicKey(key)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid age recipient %q", s)
+	}
+	return &ageRecipient{encoded: strings.ToLower(s), key: pub}, nil
+}
+
+// ageEncrypt encrypts plaintext to the recipients, and returns the ASCII armored age file.
+func ageEncrypt(plaintext []byte, recipients ...*ageRecipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no age recipients")
+	}
+	fileKey := make([]byte, ageFileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	header := &bytes.Buffer{}
+	header.WriteString(ageIntro)
+	for _, r := range recipients {
+		if err := r.writeStanza(header, fileKey); err != nil {
+			return nil, err
+		}
+	}
+	header.WriteString("---")
+	mac := hmac.New(sha256.New, hkdfKey(fileKey, nil, "header"))
+	mac.Write(header.Bytes())
+	header.WriteString(" " + b64.EncodeToString(mac.Sum(nil)) + "\n")
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(hkdfKey(fileKey, nonce, "payload"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(header.Bytes())
+	out.Write(nonce)
+	// The payload is encrypted in chunks, each with a nonce made up of an 11 byte big-endian counter,
+	// and a flag that is set on the final chunk. The final chunk is only empty if the plaintext is empty.
+	chunkNonce := make([]byte, chacha20poly1305.NonceSize)
+	for counter := uint64(0); ; counter++ {
+		chunk := plaintext
+		last := len(plaintext) <= agePayloadChunk
+		if !last {
+			chunk = plaintext[:agePayloadChunk]
+		}
+		binary.BigEndian.PutUint64(chunkNonce[3:11], counter)
+		if last {
+			chunkNonce[11] = 1
+		}
+		out.Write(aead.Seal(nil, chunkNonce, chunk, nil))
+		if last {
+			break
+		}
+		plaintext = plaintext[agePayloadChunk:]
+	}
+
+	return ageArmor(out.Bytes()), nil
+}
+
+// writeStanza writes the X25519 recipient stanza, which wraps the file key with a key derived from
+// an ephemeral key exchange with the recipient.
+func (r *ageRecipient) writeStanza(w io.Writer, fileKey []byte) error {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	share := ephemeral.PublicKey().Bytes()
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid age recipient %s", r.encoded)
+	}
+	salt := append(append([]byte{}, share...), r.key.Bytes()...)
+	aead, err := chacha20poly1305.New(hkdfKey(shared, salt, ageX25519Label))
+	if err != nil {
+		return err
+	}
+	body := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	_, err = io.WriteString(w, "-> X25519 "+b64.EncodeToString(share)+"\n"+wrapColumns(b64.EncodeToString(body), ageStanzaColumns, true))
+	return err
+}
+
+// hkdfKey derives a 32 byte key with HKDF-SHA-256.
+func hkdfKey(secret, salt []byte, info string) []byte {
+	key, err := hkdf.Key(sha256.New, secret, salt, info, chacha20poly1305.KeySize)
+	if err != nil {
+		// only possible if the requested key length is too long for the hash
+		panic(err)
+	}
+	return key
+}
+
+// ageArmor returns the age file in the PEM-like ASCII armor format.
+func ageArmor(data []byte) []byte {
+	return []byte(ageArmorHeader + "\n" + wrapColumns(base64.StdEncoding.EncodeToString(data), ageStanzaColumns, false) + ageArmorFooter + "\n")
+}
+
+// wrapColumns wraps s into newline terminated lines of the given width. If requireShort is set,
+// an empty line is added when the last line is full, as age requires for stanza bodies.
+func wrapColumns(s string, width int, requireShort bool) string {
+	b := &strings.Builder{}
+	for len(s) >= width {
+		b.WriteString(s[:width] + "\n")
+		s = s[width:]
+	}
+	if len(s) > 0 || requireShort {
+		b.WriteString(s + "\n")
+	}
+	return b.String()
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Decode decodes a bech32 string, as described in BIP 173, and returns the human-readable prefix and data.
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("invalid separator position")
+	}
+	hrp := s[:pos]
+	values := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "", nil, errors.New("invalid character")
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid checksum")
+	}
+
+	// convert the 5 bit groups, less the checksum, to bytes; any padding bits must be zero.
+	var data []byte
+	acc, bits := 0, 0
+	for _, v := range values[:len(values)-6] {
+		acc = (acc<<5 | int(v)) & 0xfff
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			data = append(data, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || (acc<<(8-bits))&0xff != 0 {
+		return "", nil, errors.New("invalid padding")
+	}
+	return hrp, data, nil
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	values := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	return values
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
@@ -0,0 +1,106 @@
+package gitbackup
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+)
+
+// gitRepo is a local clone of the backup branch. Git operations are performed with the git CLI,
+// so that the host's credential helpers, SSH configuration, and known hosts are honored.
+type gitRepo struct {
+	dir    string
+	remote string
+	branch string
+	env    []string
+}
+
+func newGitRepo(dir, remote, branch, sshKey string) *gitRepo {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if sshKey != "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+sshKey+" -o IdentitiesOnly=yes -o BatchMode=yes")
+	}
+	return &gitRepo{dir: dir, remote: remote, branch: branch, env: env}
+}
+
+// checkout initializes the repo if necessary, and resets the local branch to the current state of
+// the remote branch. If the remote branch does not exist yet, it will be created on the next push.
+func (g *gitRepo) checkout(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); err != nil {
+		if err := os.MkdirAll(g.dir, 0700); err != nil {
+			return err
+		}
+		if _, err := g.run(ctx, "init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := g.run(ctx, "remote", "add", "origin", g.remote); err != nil {
+			return err
+		}
+	} else if _, err := g.run(ctx, "remote", "set-url", "origin", g.remote); err != nil {
+		return err
+	}
+
+	// ls-remote exits 2 if the branch does not exist
+	var exitErr *exec.ExitError
+	if _, err := g.run(ctx, "ls-remote", "--exit-code", "--heads", "origin", g.branch); errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		_, err := g.run(ctx, "symbolic-ref", "HEAD", "refs/heads/"+g.branch)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if _, err := g.run(ctx, "fetch", "--quiet", "origin", g.branch); err != nil {
+		return err
+	}
+	_, err := g.run(ctx, "checkout", "--quiet", "--force", "-B", g.branch, "FETCH_HEAD")
+	return err
+}
+
+// commitAndPush commits all changes under path, and pushes them to the remote branch.
+// Returns false if there were no changes to commit.
+func (g *gitRepo) commitAndPush(ctx context.Context, path, message, author string) (bool, error) {
+	if _, err := g.run(ctx, "add", "--all", "--", path); err != nil {
+		return false, err
+	}
+	// diff exits 1 if there are staged changes
+	var exitErr *exec.ExitError
+	if _, err := g.run(ctx, "diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	} else if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return false, err
+	}
+	if _, err := g.run(ctx, "-c", "user.name="+version.Program, "-c", "user.email="+author, "commit", "--quiet", "--message", message); err != nil {
+		return false, err
+	}
+	if _, err := g.run(ctx, "push", "--quiet", "origin", "HEAD:refs/heads/"+g.branch); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// run runs a git command in the repo directory. Errors include the command output, with any
+// credentials in the remote URL redacted.
+func (g *gitRepo) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), g.env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		output := strings.TrimSpace(strings.ReplaceAll(string(out), g.remote, redactURL(g.remote)))
+		return "", errors.WithMessagef(err, "git %s failed: %s", args[0], output)
+	}
+	return string(out), nil
+}
+
+// redactURL returns the remote URL with any password removed, for logging.
+func redactURL(remote string) string {
+	if u, err := url.Parse(remote); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return remote
+}
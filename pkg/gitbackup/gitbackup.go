@@ -0,0 +1,274 @@
+// Package gitbackup keeps an encrypted copy of the cluster bootstrap data, CA certificate hashes, and etcd snapshot
+// metadata committed to a Git remote, as an auditable off-site record for disaster recovery. Each category of data
+// is written to its own SOPS encrypted JSON document, which is only rewritten and committed when the data changes.
+package gitbackup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	apisv1 "github.com/k3s-io/k3s/pkg/apis/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/bootstrap"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	controllersv1 "github.com/k3s-io/k3s/pkg/generated/controllers/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	controllerName = "git-backup"
+
+	// BootstrapFile contains the decrypted bootstrap data, under the "bootstrap" key. The value can be
+	// imported into a new datastore with `k3s server bootstrap import`.
+	BootstrapFile = "bootstrap.sops.json"
+	// CAHashesFile contains the SHA-256 hash of each CA certificate bundle, keyed by file name. The hash
+	// of server-ca.crt is the CA hash included in secure join tokens.
+	CAHashesFile = "ca-hashes.sops.json"
+	// SnapshotsFile contains the spec and status of each ETCDSnapshotFile, keyed by name.
+	SnapshotsFile = "snapshots.sops.json"
+
+	// snapshotDebounce is how long to wait after a snapshot change before syncing, so that
+	// changes to multiple snapshots are committed together.
+	snapshotDebounce = 10 * time.Second
+)
+
+// Validate checks that the git backup configuration is complete, and that the age recipients are valid.
+func Validate(cfg *config.GitBackup) error {
+	if cfg.Remote == "" {
+		return errors.New("git backup remote is required")
+	}
+	if cfg.Branch == "" {
+		return errors.New("git backup branch is required")
+	}
+	if filepath.IsAbs(cfg.Path) || !filepath.IsLocal(filepath.Clean("./"+cfg.Path)) {
+		return fmt.Errorf("invalid git backup path %q: must be relative to the repository root", cfg.Path)
+	}
+	if cfg.Interval.Duration <= 0 {
+		return errors.New("git backup interval must be greater than 0s")
+	}
+	_, err := parseRecipients(cfg.AgeRecipients)
+	return err
+}
+
+func parseRecipients(recipients []string) ([]*ageRecipient, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one git backup age recipient is required")
+	}
+	var parsed []*ageRecipient
+	for _, recipient := range recipients {
+		r, err := parseAgeRecipient(strings.TrimSpace(recipient))
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+type controller struct {
+	control    *config.Control
+	cfg        *config.GitBackup
+	recipients []*ageRecipient
+	snapshots  controllersv1.ETCDSnapshotFileCache
+	repo       *gitRepo
+	stateFile  string
+	digests    map[string]string
+	trigger    chan struct{}
+}
+
+// Register starts the git backup controller. The remote is synced immediately, and then at the configured
+// interval, as well as shortly after any change to an ETCDSnapshotFile.
+func Register(ctx context.Context, control *config.Control, snapshots controllersv1.ETCDSnapshotFileController) error {
+	cfg := control.GitBackup
+	recipients, err := parseRecipients(cfg.AgeRecipients)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(control.DataDir, controllerName)
+	c := &controller{
+		control:    control,
+		cfg:        cfg,
+		recipients: recipients,
+		snapshots:  snapshots.Cache(),
+		repo:       newGitRepo(filepath.Join(dir, "repo"), cfg.Remote, cfg.Branch, cfg.SSHKey),
+		stateFile:  filepath.Join(dir, "digests.json"),
+		digests:    map[string]string{},
+		trigger:    make(chan struct{}, 1),
+	}
+	if b, err := os.ReadFile(c.stateFile); err == nil {
+		if err := json.Unmarshal(b, &c.digests); err != nil {
+			logrus.Warnf("Failed to read git backup state, all documents will be rewritten: %v", err)
+			c.digests = map[string]string{}
+		}
+	}
+
+	snapshots.OnChange(ctx, controllerName, c.onSnapshotChange)
+	snapshots.OnRemove(ctx, controllerName, c.onSnapshotChange)
+
+	go c.run(ctx)
+	return nil
+}
+
+func (c *controller) onSnapshotChange(key string, esf *apisv1.ETCDSnapshotFile) (*apisv1.ETCDSnapshotFile, error) {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+	return esf, nil
+}
+
+func (c *controller) run(ctx context.Context) {
+	logrus.Infof("Starting %s controller, syncing to %s branch %s every %s", controllerName, redactURL(c.cfg.Remote), c.cfg.Branch, c.cfg.Interval.Duration)
+	ticker := time.NewTicker(c.cfg.Interval.Duration)
+	defer ticker.Stop()
+	for {
+		if err := c.sync(ctx); err != nil && ctx.Err() == nil {
+			logrus.Errorf("Failed to sync git backup to %s: %v", redactURL(c.cfg.Remote), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(snapshotDebounce):
+			}
+		}
+	}
+}
+
+// sync collects the current data, and commits and pushes any documents whose contents have changed
+// since they were last pushed. Documents are encrypted with a new data key each time they are written,
+// so unchanged documents are not rewritten, to avoid committing changes that only affect the ciphertext.
+func (c *controller) sync(ctx context.Context) error {
+	documents, err := c.collect()
+	if err != nil {
+		return err
+	}
+
+	digests := map[string]string{}
+	var changed []string
+	for name, values := range documents {
+		digests[name] = digest(values)
+		if digests[name] != c.digests[name] {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	slices.Sort(changed)
+
+	if err := c.repo.checkout(ctx); err != nil {
+		return err
+	}
+	dir := filepath.Join(c.repo.dir, c.cfg.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, name := range changed {
+		data, err := sopsEncrypt(documents[name], c.recipients, now)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to encrypt %s", name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("Update %s from %s", strings.Join(changed, ", "), c.control.ServerNodeName)
+	author := version.Program + "@" + c.control.ServerNodeName
+	pushed, err := c.repo.commitAndPush(ctx, filepath.Join(".", c.cfg.Path), message, author)
+	if err != nil {
+		return err
+	}
+	if pushed {
+		logrus.Infof("Pushed git backup of %s to %s", strings.Join(changed, ", "), redactURL(c.cfg.Remote))
+	}
+
+	// only record the digests once the documents have been pushed, so that a failed push is retried
+	c.digests = digests
+	b, err := json.Marshal(c.digests)
+	if err != nil {
+		return err
+	}
+	return util.AtomicWrite(c.stateFile, b, 0600)
+}
+
+// collect returns the values of each document to be written to the repo.
+func (c *controller) collect() (map[string]map[string]string, error) {
+	buf := &bytes.Buffer{}
+	if err := bootstrap.ReadFromDisk(buf, &c.control.Runtime.ControlRuntimeBootstrap); err != nil {
+		return nil, errors.WithMessage(err, "failed to read bootstrap data")
+	}
+
+	caHashes := map[string]string{}
+	for _, file := range []string{
+		c.control.Runtime.ServerCA,
+		c.control.Runtime.ClientCA,
+		c.control.Runtime.RequestHeaderCA,
+		c.control.Runtime.ETCDServerCA,
+		c.control.Runtime.ETCDPeerCA,
+	} {
+		b, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.WithMessage(err, "failed to read CA certificate")
+		}
+		sum := sha256.Sum256(b)
+		caHashes[filepath.Base(file)] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	snapshots := map[string]string{}
+	esfList, err := c.snapshots.List(labels.Everything())
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list snapshots")
+	}
+	for _, esf := range esfList {
+		b, err := json.Marshal(struct {
+			Spec   apisv1.ETCDSnapshotSpec   `json:"spec"`
+			Status apisv1.ETCDSnapshotStatus `json:"status"`
+		}{esf.Spec, esf.Status})
+		if err != nil {
+			return nil, err
+		}
+		snapshots[esf.Name] = string(b)
+	}
+
+	return map[string]map[string]string{
+		BootstrapFile: {"bootstrap": buf.String()},
+		CAHashesFile:  caHashes,
+		SnapshotsFile: snapshots,
+	}, nil
+}
+
+// digest returns a hash of the document values, used to detect changes without storing the plaintext.
+func digest(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%d:%s%d:%s", len(key), key, len(values[key]), values[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,302 @@
+package gitbackup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"golang.org/x/crypto/chacha20poly1305"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bech32Encode encodes data as bech32, for generating test recipients.
+func bech32Encode(hrp string, data []byte) string {
+	var values []byte
+	acc, bits := 0, 0
+	for _, b := range data {
+		acc = (acc<<8 | int(b)) & 0xfff
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			values = append(values, byte(acc>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		values = append(values, byte(acc<<(5-bits))&31)
+	}
+	polymod := bech32Polymod(append(append(bech32HRPExpand(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	for i := 0; i < 6; i++ {
+		values = append(values, byte(polymod>>(5*(5-i)))&31)
+	}
+	s := hrp + "1"
+	for _, v := range values {
+		s += string(bech32Charset[v])
+	}
+	return s
+}
+
+func newTestIdentity(t *testing.T) (*ecdh.PrivateKey, string) {
+	t.Helper()
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity, bech32Encode("age", identity.PublicKey().Bytes())
+}
+
+// ageDecrypt decrypts an armored age file with an X25519 identity.
+func ageDecrypt(t *testing.T, armored []byte, identity *ecdh.PrivateKey) []byte {
+	t.Helper()
+	s := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(s, ageArmorHeader+"\n") || !strings.HasSuffix(s, "\n"+ageArmorFooter) {
+		t.Fatalf("invalid armor: %q", s)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(s, ageArmorHeader), ageArmorFooter), "\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(data))
+	header := &bytes.Buffer{}
+	readLine := func() string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read header: %v", err)
+		}
+		header.WriteString(line)
+		return strings.TrimSuffix(line, "\n")
+	}
+	if line := readLine(); line+"\n" != ageIntro {
+		t.Fatalf("invalid intro %q", line)
+	}
+
+	var fileKey []byte
+	for {
+		line := readLine()
+		if strings.HasPrefix(line, "---") {
+			header.Truncate(header.Len() - len(line) - 1)
+			header.WriteString("---")
+			mac := hmac.New(sha256.New, hkdfKey(fileKey, nil, "header"))
+			mac.Write(header.Bytes())
+			if got := b64.EncodeToString(mac.Sum(nil)); "--- "+got != line {
+				t.Fatalf("header MAC mismatch")
+			}
+			break
+		}
+		args := strings.Fields(line)
+		if len(args) != 3 || args[0] != "->" || args[1] != "X25519" {
+			t.Fatalf("unexpected stanza %q", line)
+		}
+		body, err := b64.DecodeString(readLine())
+		if err != nil {
+			t.Fatal(err)
+		}
+		share, _ := b64.DecodeString(args[2])
+		sharePub, err := ecdh.X25519().NewPublicKey(share)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shared, err := identity.ECDH(sharePub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		salt := append(append([]byte{}, share...), identity.PublicKey().Bytes()...)
+		aead, _ := chacha20poly1305.New(hkdfKey(shared, salt, ageX25519Label))
+		if key, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), body, nil); err == nil {
+			fileKey = key
+		}
+	}
+	if fileKey == nil {
+		t.Fatal("no stanza could be unwrapped with the identity")
+	}
+
+	payload := &bytes.Buffer{}
+	payload.ReadFrom(r)
+	nonce := payload.Next(16)
+	aead, _ := chacha20poly1305.New(hkdfKey(fileKey, nonce, "payload"))
+	chunkNonce := make([]byte, chacha20poly1305.NonceSize)
+	chunkNonce[11] = 1
+	plaintext, err := aead.Open(nil, chunkNonce, payload.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt payload: %v", err)
+	}
+	return plaintext
+}
+
+var sopsValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:str\]$`)
+
+func sopsDecryptValue(t *testing.T, value string, dataKey []byte, additionalData string) string {
+	t.Helper()
+	m := sopsValueRegexp.FindStringSubmatch(value)
+	if m == nil {
+		t.Fatalf("invalid encrypted value %q", value)
+	}
+	data, _ := base64.StdEncoding.DecodeString(m[1])
+	iv, _ := base64.StdEncoding.DecodeString(m[2])
+	tag, _ := base64.StdEncoding.DecodeString(m[3])
+	block, _ := aes.NewCipher(dataKey)
+	gcm, _ := cipher.NewGCMWithNonceSize(block, len(iv))
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		t.Fatalf("failed to decrypt value: %v", err)
+	}
+	return string(plaintext)
+}
+
+func Test_UnitParseAgeRecipient(t *testing.T) {
+	_, recipient := newTestIdentity(t)
+	badChecksum := recipient[:len(recipient)-1] + "q"
+	if badChecksum == recipient {
+		badChecksum = recipient[:len(recipient)-1] + "p"
+	}
+	tests := []struct {
+		name      string
+		recipient string
+		wantErr   bool
+	}{
+		{name: "valid", recipient: recipient},
+		{name: "age example", recipient: "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"},
+		{name: "uppercase", recipient: strings.ToUpper(recipient)},
+		{name: "bad checksum", recipient: badChecksum, wantErr: true},
+		{name: "mixed case", recipient: "A" + recipient[1:], wantErr: true},
+		{name: "wrong prefix", recipient: bech32Encode("age-secret-key-", make([]byte, 32)), wantErr: true},
+		{name: "short key", recipient: bech32Encode("age", make([]byte, 16)), wantErr: true},
+		{name: "ssh key", recipient: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAgeRecipient(tt.recipient); (err != nil) != tt.wantErr {
+				t.Errorf("parseAgeRecipient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitAgeEncrypt(t *testing.T) {
+	identity, recipient := newTestIdentity(t)
+	_, other := newTestIdentity(t)
+	r1, err := parseAgeRecipient(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := parseAgeRecipient(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, plaintext := range [][]byte{{}, []byte("data key"), bytes.Repeat([]byte{0xaa}, 1000)} {
+		armored, err := ageEncrypt(plaintext, r2, r1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ageDecrypt(t, armored, identity); !bytes.Equal(got, plaintext) {
+			t.Errorf("ageDecrypt() = %x, want %x", got, plaintext)
+		}
+	}
+}
+
+func Test_UnitSopsEncrypt(t *testing.T) {
+	identity, recipient := newTestIdentity(t)
+	r, err := parseAgeRecipient(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{
+		"server-ca.crt":      "sha256:abc",
+		"client-ca.crt":      "sha256:def",
+		"note_unencrypted":   "visible",
+		"request-header.crt": "",
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	data, err := sopsEncrypt(values, []*ageRecipient{r}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, data)
+	}
+	metadata := sopsMetadata{}
+	if err := json.Unmarshal(doc["sops"], &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.LastModified != "2026-10-16T12:00:00Z" || len(metadata.Age) != 1 || metadata.Age[0].Recipient != recipient {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+	dataKey := ageDecrypt(t, []byte(metadata.Age[0].Enc), identity)
+
+	mac := sha512.New()
+	for _, key := range []string{"client-ca.crt", "note_unencrypted", "request-header.crt", "server-ca.crt"} {
+		var value string
+		json.Unmarshal(doc[key], &value)
+		if !strings.HasSuffix(key, sopsUnencryptedSuffix) {
+			value = sopsDecryptValue(t, value, dataKey, key+":")
+		}
+		if value != values[key] {
+			t.Errorf("value of %s = %q, want %q", key, value, values[key])
+		}
+		mac.Write([]byte(value))
+	}
+	if got, want := sopsDecryptValue(t, metadata.MAC, dataKey, metadata.LastModified), fmt.Sprintf("%X", mac.Sum(nil)); got != want {
+		t.Errorf("mac = %s, want %s", got, want)
+	}
+}
+
+func Test_UnitValidate(t *testing.T) {
+	_, recipient := newTestIdentity(t)
+	valid := config.GitBackup{
+		Remote:        "git@example.com:ops/backup.git",
+		Branch:        "main",
+		Path:          "clusters/prod",
+		AgeRecipients: []string{recipient},
+		Interval:      metav1.Duration{Duration: time.Minute},
+	}
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.GitBackup)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(cfg *config.GitBackup) {}},
+		{name: "root path", modify: func(cfg *config.GitBackup) { cfg.Path = "" }},
+		{name: "no recipients", modify: func(cfg *config.GitBackup) { cfg.AgeRecipients = nil }, wantErr: true},
+		{name: "invalid recipient", modify: func(cfg *config.GitBackup) { cfg.AgeRecipients = []string{"age1invalid"} }, wantErr: true},
+		{name: "absolute path", modify: func(cfg *config.GitBackup) { cfg.Path = "/etc" }, wantErr: true},
+		{name: "escaping path", modify: func(cfg *config.GitBackup) { cfg.Path = "../other" }, wantErr: true},
+		{name: "no branch", modify: func(cfg *config.GitBackup) { cfg.Branch = "" }, wantErr: true},
+		{name: "no interval", modify: func(cfg *config.GitBackup) { cfg.Interval.Duration = 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if err := Validate(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitDigest(t *testing.T) {
+	a := digest(map[string]string{"a": "bc", "d": ""})
+	if b := digest(map[string]string{"d": "", "a": "bc"}); a != b {
+		t.Errorf("digest() depends on map order")
+	}
+	if b := digest(map[string]string{"a": "b", "cd": ""}); a == b {
+		t.Errorf("digest() does not distinguish key and value boundaries")
+	}
+}
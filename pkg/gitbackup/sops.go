@@ -0,0 +1,120 @@
+package gitbackup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// This file writes flat string maps as SOPS encrypted JSON documents, with the data key encrypted to age
+// recipients. The documents can be decrypted with `sops --decrypt`, given one of the age identities.
+
+const (
+	sopsVersion           = "3.9.0"
+	sopsUnencryptedSuffix = "_unencrypted"
+	sopsNonceSize         = 32
+	sopsDataKeySize       = 32
+)
+
+// sopsMetadata is the sops key of an encrypted document. Fields are in the order written by sops.
+type sopsMetadata struct {
+	Age               []sopsAgeKey `json:"age"`
+	LastModified      string       `json:"lastmodified"`
+	MAC               string       `json:"mac"`
+	UnencryptedSuffix string       `json:"unencrypted_suffix"`
+	Version           string       `json:"version"`
+}
+
+// sopsAgeKey is the data key, encrypted to a single age recipient.
+type sopsAgeKey struct {
+	Recipient string `json:"recipient"`
+	Enc       string `json:"enc"`
+}
+
+// sopsEncrypt returns a SOPS JSON document containing the values, encrypted with a new data key.
+// Keys are written in sorted order; the document MAC is computed over the values in the same order.
+func sopsEncrypt(values map[string]string, recipients []*ageRecipient, now time.Time) ([]byte, error) {
+	dataKey := make([]byte, sopsDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	metadata := sopsMetadata{
+		LastModified:      now.UTC().Format(time.RFC3339),
+		UnencryptedSuffix: sopsUnencryptedSuffix,
+		Version:           sopsVersion,
+	}
+	for _, r := range recipients {
+		enc, err := ageEncrypt(dataKey, r)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Age = append(metadata.Age, sopsAgeKey{Recipient: r.encoded, Enc: string(enc)})
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	doc := &bytes.Buffer{}
+	doc.WriteString("{\n")
+	mac := sha512.New()
+	for _, key := range keys {
+		value := values[key]
+		mac.Write([]byte(value))
+		// values of keys with the unencrypted suffix are stored as-is, as sops would
+		if !strings.HasSuffix(key, sopsUnencryptedSuffix) {
+			var err error
+			// the additional data for each value is its path in the document, followed by a colon
+			if value, err = sopsEncryptValue(value, dataKey, key+":"); err != nil {
+				return nil, err
+			}
+		}
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(value)
+		fmt.Fprintf(doc, "\t%s: %s,\n", k, v)
+	}
+
+	var err error
+	if metadata.MAC, err = sopsEncryptValue(fmt.Sprintf("%X", mac.Sum(nil)), dataKey, metadata.LastModified); err != nil {
+		return nil, err
+	}
+	m, err := json.MarshalIndent(metadata, "\t", "\t")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(doc, "\t\"sops\": %s\n}\n", m)
+	return doc.Bytes(), nil
+}
+
+// sopsEncryptValue encrypts a string value with AES-256-GCM, using the sops value format.
+func sopsEncryptValue(value string, dataKey []byte, additionalData string) (string, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, sopsNonceSize)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, sopsNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	out := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag)), nil
+}
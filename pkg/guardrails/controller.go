@@ -0,0 +1,220 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/pager"
+)
+
+const (
+	// eventCleanupInterval is the interval at which namespaces are checked for excess events
+	eventCleanupInterval = 5 * time.Minute
+	// listPageSize is the page size used when listing namespaces and events for cleanup
+	listPageSize = 500
+)
+
+var (
+	resourceQuotaName = version.Program + "-guardrails"
+	labelManagedBy    = "app.kubernetes.io/managed-by"
+)
+
+// Profiles contains the named guardrail profiles that can be selected with the guardrail-profile flag.
+var Profiles = map[string]config.Guardrails{
+	"single-node": {
+		Profile:       "single-node",
+		MaxSecrets:    1000,
+		MaxConfigMaps: 1000,
+		MaxEvents:     2000,
+		EventTTL:      metav1.Duration{Duration: 15 * time.Minute},
+	},
+}
+
+// GetProfile returns the guardrails for the named profile. A nil Guardrails is returned
+// if the profile name is empty or "none".
+func GetProfile(name string) (*config.Guardrails, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	if g, ok := Profiles[name]; ok {
+		return &g, nil
+	}
+	return nil, fmt.Errorf("unknown guardrail profile %q", name)
+}
+
+// systemNamespaces are not subject to the guardrail ResourceQuota, so that packaged components
+// and Kubernetes itself are never prevented from creating the Secrets and ConfigMaps they need.
+var systemNamespaces = []string{metav1.NamespaceSystem, metav1.NamespacePublic, corev1.NamespaceNodeLease}
+
+// Register creates a ResourceQuota in each non-system namespace to limit the number of Secrets and ConfigMaps,
+// and starts a periodic cleanup of the oldest Events in namespaces that exceed the event limit.
+// It must only be called from a leader-elected controller, as the event cleanup is not coordinated between servers.
+func Register(ctx context.Context, k8s kubernetes.Interface, namespaces coreclient.NamespaceController, guardrails *config.Guardrails) error {
+	if guardrails == nil {
+		return errors.New("guardrails must not be nil")
+	}
+	if guardrails.MaxSecrets < 0 || guardrails.MaxConfigMaps < 0 || guardrails.MaxEvents < 0 {
+		return fmt.Errorf("guardrail profile %s limits must not be negative", guardrails.Profile)
+	}
+
+	h := &handler{
+		ctx:        ctx,
+		k8s:        k8s,
+		guardrails: guardrails,
+	}
+	namespaces.OnChange(ctx, "guardrails", h.onChangeNamespace)
+
+	if guardrails.MaxEvents > 0 {
+		go wait.UntilWithContext(ctx, h.cleanupEvents, eventCleanupInterval)
+	}
+
+	logrus.Infof("Object count guardrails enabled with profile %s", guardrails.Profile)
+	return nil
+}
+
+type handler struct {
+	ctx        context.Context
+	k8s        kubernetes.Interface
+	guardrails *config.Guardrails
+}
+
+// onChangeNamespace ensures that the guardrail ResourceQuota exists and is up to date in every namespace.
+func (h *handler) onChangeNamespace(key string, ns *corev1.Namespace) (*corev1.Namespace, error) {
+	if ns == nil || ns.DeletionTimestamp != nil || ns.Status.Phase == corev1.NamespaceTerminating {
+		return ns, nil
+	}
+	if slices.Contains(systemNamespaces, ns.Name) {
+		return ns, nil
+	}
+
+	hard := corev1.ResourceList{}
+	if h.guardrails.MaxSecrets > 0 {
+		hard["count/secrets"] = *resource.NewQuantity(h.guardrails.MaxSecrets, resource.DecimalSI)
+	}
+	if h.guardrails.MaxConfigMaps > 0 {
+		hard["count/configmaps"] = *resource.NewQuantity(h.guardrails.MaxConfigMaps, resource.DecimalSI)
+	}
+	if len(hard) == 0 {
+		return ns, nil
+	}
+
+	quotas := h.k8s.CoreV1().ResourceQuotas(ns.Name)
+	quota, err := quotas.Get(h.ctx, resourceQuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		quota = &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      resourceQuotaName,
+				Namespace: ns.Name,
+				Labels:    map[string]string{labelManagedBy: version.Program},
+			},
+			Spec: corev1.ResourceQuotaSpec{Hard: hard},
+		}
+		_, err = quotas.Create(h.ctx, quota, metav1.CreateOptions{})
+		return ns, err
+	} else if err != nil {
+		return ns, err
+	}
+
+	if !equality.Semantic.DeepEqual(quota.Spec.Hard, hard) {
+		quota = quota.DeepCopy()
+		quota.Spec.Hard = hard
+		_, err = quotas.Update(h.ctx, quota, metav1.UpdateOptions{})
+	}
+	return ns, err
+}
+
+// cleanupEvents deletes the oldest Events in any namespace that has more than the maximum allowed number of events.
+// Events are not limited by ResourceQuota, as rejecting Event creation would hide information about the misbehaving
+// controller that is creating them.
+func (h *handler) cleanupEvents(ctx context.Context) {
+	nsPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return h.k8s.CoreV1().Namespaces().List(ctx, opts)
+	}))
+	nsPager.PageSize = listPageSize
+	if err := nsPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		ns, ok := obj.(*corev1.Namespace)
+		if !ok {
+			return errors.New("failed to convert object to Namespace")
+		}
+		if err := h.cleanupNamespaceEvents(ctx, ns.Name); err != nil {
+			logrus.Warnf("Failed to clean up events in namespace %s: %v", ns.Name, err)
+		}
+		return nil
+	}); err != nil {
+		logrus.Warnf("Failed to list namespaces for event cleanup: %v", err)
+	}
+}
+
+// cleanupNamespaceEvents deletes the oldest events in a namespace, in excess of the event limit.
+func (h *handler) cleanupNamespaceEvents(ctx context.Context, namespace string) error {
+	events := h.k8s.CoreV1().Events(namespace)
+	eventPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return events.List(ctx, opts)
+	}))
+	eventPager.PageSize = listPageSize
+
+	// Only the name and timestamp of each event is retained, to limit memory use in namespaces with many events
+	var items []eventRef
+	if err := eventPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		event, ok := obj.(*corev1.Event)
+		if !ok {
+			return errors.New("failed to convert object to Event")
+		}
+		items = append(items, eventRef{name: event.Name, time: eventTime(*event)})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	excess := int64(len(items)) - h.guardrails.MaxEvents
+	if excess <= 0 {
+		return nil
+	}
+
+	// sort oldest-first so that we can delete events past the limit
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].time.Before(items[j].time)
+	})
+
+	logrus.Infof("Deleting %d events from namespace %s in excess of guardrail limit %d", excess, namespace, h.guardrails.MaxEvents)
+	for _, event := range items[:excess] {
+		if err := events.Delete(ctx, event.name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventRef identifies an event that may be deleted by the event cleanup.
+type eventRef struct {
+	name string
+	time time.Time
+}
+
+// eventTime returns the most recent timestamp recorded on an event.
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
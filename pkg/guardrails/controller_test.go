@@ -0,0 +1,110 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_UnitGetProfile(t *testing.T) {
+	for _, name := range []string{"", "none"} {
+		if g, err := GetProfile(name); g != nil || err != nil {
+			t.Errorf("GetProfile(%q) = %v, %v, want nil, nil", name, g, err)
+		}
+	}
+	if g, err := GetProfile("single-node"); err != nil || g == nil || g.Profile != "single-node" {
+		t.Errorf("GetProfile(single-node) = %v, %v", g, err)
+	}
+	if _, err := GetProfile("bogus"); err == nil {
+		t.Error("GetProfile(bogus) expected error")
+	}
+}
+
+func Test_UnitOnChangeNamespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		wantQuota bool
+	}{
+		{name: "user namespace", namespace: "default", wantQuota: true},
+		{name: "kube-system", namespace: metav1.NamespaceSystem},
+		{name: "kube-public", namespace: metav1.NamespacePublic},
+		{name: "kube-node-lease", namespace: corev1.NamespaceNodeLease},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k8s := fake.NewClientset()
+			h := &handler{ctx: ctx, k8s: k8s, guardrails: &config.Guardrails{MaxSecrets: 10, MaxConfigMaps: 20}}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace}}
+			if _, err := h.onChangeNamespace(tt.namespace, ns); err != nil {
+				t.Fatalf("onChangeNamespace() error = %v", err)
+			}
+			quota, err := k8s.CoreV1().ResourceQuotas(tt.namespace).Get(ctx, resourceQuotaName, metav1.GetOptions{})
+			if !tt.wantQuota {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected no ResourceQuota in %s, got %v, %v", tt.namespace, quota, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get ResourceQuota: %v", err)
+			}
+			if got := quota.Spec.Hard["count/secrets"]; got.Value() != 10 {
+				t.Errorf("count/secrets = %s, want 10", got.String())
+			}
+			if got := quota.Spec.Hard["count/configmaps"]; got.Value() != 20 {
+				t.Errorf("count/configmaps = %s, want 20", got.String())
+			}
+		})
+	}
+}
+
+func Test_UnitCleanupEvents(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	k8s := fake.NewClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	for i := range 5 {
+		event := &corev1.Event{
+			ObjectMeta:    metav1.ObjectMeta{Name: fmt.Sprintf("event-%d", i), Namespace: "default"},
+			LastTimestamp: metav1.NewTime(now.Add(time.Duration(i) * time.Minute)),
+		}
+		if _, err := k8s.CoreV1().Events("default").Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &handler{ctx: ctx, k8s: k8s, guardrails: &config.Guardrails{MaxEvents: 3}}
+	h.cleanupEvents(ctx)
+
+	events, err := k8s.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, event := range events.Items {
+		names = append(names, event.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"event-2", "event-3", "event-4"}; !slices.Equal(names, want) {
+		t.Errorf("remaining events = %v, want %v", names, want)
+	}
+}
+
+func Test_UnitRegisterValidation(t *testing.T) {
+	ctx := context.Background()
+	if err := Register(ctx, fake.NewClientset(), nil, nil); err == nil {
+		t.Error("Register() with nil guardrails expected error")
+	}
+	if err := Register(ctx, fake.NewClientset(), nil, &config.Guardrails{MaxEvents: -1}); err == nil {
+		t.Error("Register() with negative limit expected error")
+	}
+}
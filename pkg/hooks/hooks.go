@@ -0,0 +1,123 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PreNetwork hooks are run before the host network is configured, and before networking components are started.
+	PreNetwork = "pre-network"
+	// PostContainerd hooks are run once the container runtime is ready.
+	PostContainerd = "post-containerd"
+	// PostAPIServerReady hooks are run once the apiserver is ready.
+	PostAPIServerReady = "post-apiserver-ready"
+
+	FailurePolicyFail   = "fail"
+	FailurePolicyIgnore = "ignore"
+
+	// outputLimit is the maximum length of hook command output included in error messages.
+	outputLimit = 512
+)
+
+// Points are the supported hook points, in the order in which they are run.
+var Points = []string{PreNetwork, PostContainerd, PostAPIServerReady}
+
+// Hooks are commands registered by the operator to run at supported points during startup. Commands for each
+// point are run in order with sh, each with the same timeout. If FailurePolicy is "fail", a failed hook stops
+// startup; otherwise the failure is logged, and startup continues. Env is added to the environment of hook commands.
+type Hooks struct {
+	Commands      map[string][]string
+	Timeout       time.Duration
+	FailurePolicy string
+	Env           []string
+}
+
+// Parse parses hook commands in POINT:COMMAND format, and validates the timeout and failure policy.
+// If no hooks are specified, nil is returned.
+func Parse(values []string, timeout time.Duration, policy string, env []string) (*Hooks, error) {
+	switch policy {
+	case FailurePolicyFail, FailurePolicyIgnore:
+	default:
+		return nil, fmt.Errorf("invalid hook-failure-policy %q: must be one of '%s', '%s'", policy, FailurePolicyFail, FailurePolicyIgnore)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if timeout <= 0 {
+		return nil, errors.New("invalid hook-timeout: must be greater than 0s")
+	}
+
+	h := &Hooks{Commands: map[string][]string{}, Timeout: timeout, FailurePolicy: policy, Env: env}
+	for _, value := range values {
+		point, command, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("invalid hook %q: must be in POINT:COMMAND format", value)
+		}
+		if !slices.Contains(Points, point) {
+			return nil, fmt.Errorf("invalid hook %q: unsupported hook point %q; must be one of %s", value, point, strings.Join(Points, ", "))
+		}
+		h.Commands[point] = append(h.Commands[point], command)
+	}
+	return h, nil
+}
+
+// Run runs the commands for a hook point in order, stopping at the first failure. The error is only
+// returned if the failure policy is "fail"; otherwise it is logged. It is safe to call Run on nil Hooks.
+func (h *Hooks) Run(ctx context.Context, point, nodeName string) error {
+	if h == nil {
+		return nil
+	}
+	for i, command := range h.Commands[point] {
+		start := time.Now()
+		if err := h.run(ctx, command, point, nodeName); err != nil {
+			err = errors.WithMessagef(err, "%s hook %d failed", point, i+1)
+			if h.FailurePolicy == FailurePolicyIgnore {
+				logrus.Warnf("Continuing startup despite hook failure: %v", err)
+				return nil
+			}
+			return err
+		}
+		logrus.Infof("Ran %s hook %d in %s", point, i+1, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// run runs a single hook command with sh. The hook point and node name are passed in environment variables.
+// Errors do not include the command itself, as it may contain credentials.
+func (h *Hooks) run(ctx context.Context, command, point, nodeName string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		version.ProgramUpper+"_HOOK="+point,
+		version.ProgramUpper+"_NODE_NAME="+nodeName,
+	)
+	cmd.Env = append(cmd.Env, h.Env...)
+	// do not wait indefinitely for background processes started by the hook that hold the output open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if out := strings.TrimSpace(string(output)); out != "" {
+			if len(out) > outputLimit {
+				out = out[:outputLimit] + "..."
+			}
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
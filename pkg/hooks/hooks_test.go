@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_UnitParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		timeout time.Duration
+		policy  string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:    "no hooks",
+			timeout: time.Minute,
+			policy:  FailurePolicyFail,
+		},
+		{
+			name:    "hooks",
+			values:  []string{"pre-network:/usr/local/bin/setup-vlan", "post-containerd:ctr version", "pre-network:echo ok"},
+			timeout: time.Minute,
+			policy:  FailurePolicyIgnore,
+			want: map[string][]string{
+				PreNetwork:     {"/usr/local/bin/setup-vlan", "echo ok"},
+				PostContainerd: {"ctr version"},
+			},
+		},
+		{
+			name:    "unsupported point",
+			values:  []string{"pre-kubelet:echo ok"},
+			timeout: time.Minute,
+			policy:  FailurePolicyFail,
+			wantErr: true,
+		},
+		{
+			name:    "missing command",
+			values:  []string{"pre-network:"},
+			timeout: time.Minute,
+			policy:  FailurePolicyFail,
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			values:  []string{"pre-network:echo ok"},
+			policy:  FailurePolicyFail,
+			wantErr: true,
+		},
+		{
+			name:    "invalid policy",
+			timeout: time.Minute,
+			policy:  "retry",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Parse(tt.values, tt.timeout, tt.policy, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if h != nil {
+					t.Errorf("Parse() = %v, want nil", h)
+				}
+				return
+			}
+			for point, commands := range tt.want {
+				if got := h.Commands[point]; len(got) != len(commands) {
+					t.Errorf("Parse() %s commands = %v, want %v", point, got, commands)
+				}
+			}
+		})
+	}
+}
+
+func Test_UnitRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	tests := []struct {
+		name     string
+		commands []string
+		policy   string
+		timeout  time.Duration
+		wantErr  bool
+		wantOut  string
+	}{
+		{
+			name:     "success",
+			commands: []string{`printf '%s' "$K3S_HOOK $K3S_NODE_NAME $EXTRA" > ` + out},
+			policy:   FailurePolicyFail,
+			timeout:  10 * time.Second,
+			wantOut:  "pre-network node1 value",
+		},
+		{
+			name:     "failure stops startup",
+			commands: []string{"exit 1", "touch " + out},
+			policy:   FailurePolicyFail,
+			timeout:  10 * time.Second,
+			wantErr:  true,
+		},
+		{
+			name:     "failure ignored",
+			commands: []string{"exit 1"},
+			policy:   FailurePolicyIgnore,
+			timeout:  10 * time.Second,
+		},
+		{
+			name:     "timeout",
+			commands: []string{"sleep 10"},
+			policy:   FailurePolicyFail,
+			timeout:  100 * time.Millisecond,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(out)
+			h := &Hooks{
+				Commands:      map[string][]string{PreNetwork: tt.commands},
+				Timeout:       tt.timeout,
+				FailurePolicy: tt.policy,
+				Env:           []string{"EXTRA=value"},
+			}
+			if err := h.Run(context.Background(), PreNetwork, "node1"); (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			b, _ := os.ReadFile(out)
+			if string(b) != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", b, tt.wantOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package imagepolicy
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+var (
+	webhookName    = version.Program + "-image-policy"
+	labelManagedBy = "app.kubernetes.io/managed-by"
+)
+
+// Path is the path on the supervisor port at which the image policy webhook is served.
+var Path = "/v1-" + version.Program + "/image-policy"
+
+// Register creates or updates the MutatingWebhookConfiguration that sends pod admission requests
+// to the image policy handler, if prefer-local-images is enabled. Pods in the kube-system namespace are not
+// subject to the image policy. If it is not enabled, any existing
+// webhook configuration is removed. The webhook is called on the supervisor port on the loopback address,
+// so each apiserver calls the supervisor running alongside it.
+func Register(ctx context.Context, k8s kubernetes.Interface, control *config.Control) error {
+	webhooks := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	if !control.PreferLocalImages {
+		if err := webhooks.Delete(ctx, webhookName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	caBundle, err := os.ReadFile(control.Runtime.ServerCA)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://%s:%d%s", control.Loopback(true), control.SupervisorPort, Path)
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	reinvocationPolicy := admissionregistrationv1.IfNeededReinvocationPolicy
+	desired := []admissionregistrationv1.MutatingWebhook{{
+		Name: "image-policy." + version.Program + ".cattle.io",
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			URL:      &url,
+			CABundle: caBundle,
+		},
+		// Ephemeral containers are added to existing pods by updating the ephemeralcontainers subresource.
+		Rules: []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+		}, {
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods/ephemeralcontainers"},
+			},
+		}},
+		// Pods in kube-system are exempt, so that packaged components and the pods the cluster depends on
+		// can always be created, even if their images are not on the allowlist or the webhook is unavailable.
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      corev1.LabelMetadataName,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{metav1.NamespaceSystem},
+			}},
+		},
+		FailurePolicy:           &failurePolicy,
+		SideEffects:             &sideEffects,
+		ReinvocationPolicy:      &reinvocationPolicy,
+		AdmissionReviewVersions: []string{"v1"},
+		TimeoutSeconds:          ptr.To(int32(10)),
+	}}
+
+	webhook, err := webhooks.Get(ctx, webhookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		webhook = &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   webhookName,
+				Labels: map[string]string{labelManagedBy: version.Program},
+			},
+			Webhooks: desired,
+		}
+		_, err = webhooks.Create(ctx, webhook, metav1.CreateOptions{})
+	} else if err == nil && !equality.Semantic.DeepDerivative(desired, webhook.Webhooks) {
+		webhook = webhook.DeepCopy()
+		webhook.Webhooks = desired
+		_, err = webhooks.Update(ctx, webhook, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Image pull policy enforcement enabled with %d allowlist entries", len(control.ImageAllowlist))
+	return nil
+}
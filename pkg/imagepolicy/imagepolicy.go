@@ -0,0 +1,233 @@
+package imagepolicy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	docker "github.com/distribution/reference"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// jsonPatchOp is a single RFC 6902 JSON patch operation
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// ParseAllowlist reads a list of allowed images from a file. Each non-empty line that is not a comment
+// must be an image reference with a digest, optionally also including a tag. The returned map contains
+// the normalized name@digest reference, and the name:tag reference if a tag was included, mapped to the digest.
+func ParseAllowlist(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseAllowlist(f)
+}
+
+func parseAllowlist(r io.Reader) (map[string]string, error) {
+	allowlist := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		ref, err := docker.ParseAnyReference(entry)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid image reference %q on line %d", entry, line)
+		}
+		digested, ok := ref.(docker.Digested)
+		if !ok {
+			return nil, fmt.Errorf("image reference %q on line %d does not include a digest", entry, line)
+		}
+		named, ok := ref.(docker.Named)
+		if !ok {
+			return nil, fmt.Errorf("image reference %q on line %d does not include a name", entry, line)
+		}
+		name := docker.TrimNamed(named)
+		digest := digested.Digest().String()
+		allowlist[name.String()+"@"+digest] = digest
+		if tagged, ok := ref.(docker.Tagged); ok {
+			allowlist[name.String()+":"+tagged.Tag()] = digest
+		}
+	}
+	return allowlist, scanner.Err()
+}
+
+// Handler returns a handler for pod admission requests from the apiserver. The image pull
+// policy for all containers is set to IfNotPresent. If an image allowlist is configured,
+// images referenced by tag are pinned to the allowed digest, and pods using any image not
+// on the allowlist are rejected.
+func Handler(control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(req.Body).Decode(review); err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			util.SendError(errors.New("admission review request must be set"), resp, req, http.StatusBadRequest)
+			return
+		}
+
+		review.Response = admit(control, review.Request)
+		review.Request = nil
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(review)
+	})
+}
+
+// admit returns the admission response for a single pod admission request.
+func admit(control *config.Control, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	res := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if !control.PreferLocalImages {
+		return res
+	}
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return deny(res, err)
+	}
+	// Ephemeral containers are added by updating the ephemeralcontainers subresource; the
+	// old object is used to identify the ephemeral containers that are being added.
+	var oldPod *corev1.Pod
+	if req.SubResource == "ephemeralcontainers" {
+		oldPod = &corev1.Pod{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldPod); err != nil {
+			return deny(res, err)
+		}
+	}
+
+	patch, err := podPatch(control.ImageAllowlist, pod, oldPod)
+	if err != nil {
+		logrus.Warnf("Rejected pod %s/%s: %v", req.Namespace, pod.Name, err)
+		return deny(res, err)
+	}
+	if len(patch) > 0 {
+		b, err := json.Marshal(patch)
+		if err != nil {
+			return deny(res, err)
+		}
+		patchType := admissionv1.PatchTypeJSONPatch
+		res.Patch = b
+		res.PatchType = &patchType
+	}
+	return res
+}
+
+func deny(res *admissionv1.AdmissionResponse, err error) *admissionv1.AdmissionResponse {
+	res.Allowed = false
+	res.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonForbidden,
+		Code:    http.StatusForbidden,
+		Message: err.Error(),
+	}
+	return res
+}
+
+// podContainer holds the fields of a container or ephemeral container that are checked by the image policy.
+type podContainer struct {
+	name            string
+	image           string
+	imagePullPolicy corev1.PullPolicy
+}
+
+// podPatch returns the JSON patch operations needed to enforce the IfNotPresent pull policy and
+// pin allowed images to their digest, for all init, app, and ephemeral containers in the pod.
+// If the old pod is set, the request is an update of the ephemeralcontainers subresource, and only
+// ephemeral containers that are not present in the old pod are patched, as all other containers are immutable.
+// An error is returned if any container uses an image that is not allowed.
+func podPatch(allowlist map[string]string, pod, oldPod *corev1.Pod) ([]jsonPatchOp, error) {
+	type containerField struct {
+		path       string
+		containers []podContainer
+	}
+	fields := []containerField{}
+	existing := map[string]bool{}
+	if oldPod == nil {
+		fields = append(fields,
+			containerField{"/spec/initContainers", containersOf(pod.Spec.InitContainers)},
+			containerField{"/spec/containers", containersOf(pod.Spec.Containers)},
+		)
+	} else {
+		for _, container := range oldPod.Spec.EphemeralContainers {
+			existing[container.Name] = true
+		}
+	}
+	ephemeral := make([]podContainer, 0, len(pod.Spec.EphemeralContainers))
+	for _, container := range pod.Spec.EphemeralContainers {
+		ephemeral = append(ephemeral, podContainer{name: container.Name, image: container.Image, imagePullPolicy: container.ImagePullPolicy})
+	}
+	fields = append(fields, containerField{"/spec/ephemeralContainers", ephemeral})
+
+	patch := []jsonPatchOp{}
+	for _, field := range fields {
+		for i, container := range field.containers {
+			if existing[container.name] {
+				continue
+			}
+			if container.imagePullPolicy != corev1.PullIfNotPresent && container.imagePullPolicy != corev1.PullNever {
+				patch = append(patch, jsonPatchOp{Op: "add", Path: fmt.Sprintf("%s/%d/imagePullPolicy", field.path, i), Value: corev1.PullIfNotPresent})
+			}
+			if len(allowlist) == 0 {
+				continue
+			}
+			image, err := allowedImage(allowlist, container.image)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "container %s", container.name)
+			}
+			if image != container.image {
+				patch = append(patch, jsonPatchOp{Op: "replace", Path: fmt.Sprintf("%s/%d/image", field.path, i), Value: image})
+			}
+		}
+	}
+	return patch, nil
+}
+
+func containersOf(containers []corev1.Container) []podContainer {
+	result := make([]podContainer, 0, len(containers))
+	for _, container := range containers {
+		result = append(result, podContainer{name: container.Name, image: container.Image, imagePullPolicy: container.ImagePullPolicy})
+	}
+	return result
+}
+
+// allowedImage checks an image reference against the allowlist, and returns the reference that should be used
+// in its place. References by digest are returned unmodified if the digest is allowed. References by tag are
+// pinned to the allowed digest for that tag.
+func allowedImage(allowlist map[string]string, image string) (string, error) {
+	ref, err := docker.ParseDockerRef(image)
+	if err != nil {
+		return "", errors.WithMessagef(err, "invalid image reference %q", image)
+	}
+	if _, ok := ref.(docker.Digested); ok {
+		if _, ok := allowlist[ref.String()]; !ok {
+			return "", fmt.Errorf("image %s is not in the image digest allowlist", image)
+		}
+		return image, nil
+	}
+	digest, ok := allowlist[ref.String()]
+	if !ok {
+		return "", fmt.Errorf("image %s is not in the image digest allowlist", image)
+	}
+	return ref.String() + "@" + digest, nil
+}
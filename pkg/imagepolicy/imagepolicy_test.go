@@ -0,0 +1,234 @@
+package imagepolicy
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testDigest  = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+	otherDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+)
+
+func Test_UnitParseAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "Tagged and untagged entries",
+			input: "# comment\n\nnginx:1.27@" + testDigest + "\nregistry.example.com/app@" + otherDigest + "\n",
+			want: map[string]string{
+				"docker.io/library/nginx@" + testDigest:   testDigest,
+				"docker.io/library/nginx:1.27":            testDigest,
+				"registry.example.com/app@" + otherDigest: otherDigest,
+			},
+		},
+		{
+			name:    "Entry without digest",
+			input:   "nginx:1.27\n",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid entry",
+			input:   "not a valid reference\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAllowlist(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAllowlist() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitPodPatch(t *testing.T) {
+	allowlist := map[string]string{
+		"docker.io/library/nginx@" + testDigest: testDigest,
+		"docker.io/library/nginx:1.27":          testDigest,
+	}
+	tests := []struct {
+		name      string
+		allowlist map[string]string
+		pod       *corev1.Pod
+		oldPod    *corev1.Pod
+		want      []jsonPatchOp
+		wantErr   bool
+	}{
+		{
+			name: "Pull policy only",
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init", Image: "busybox", ImagePullPolicy: corev1.PullAlways}},
+					Containers: []corev1.Container{
+						{Name: "app", Image: "nginx", ImagePullPolicy: corev1.PullIfNotPresent},
+						{Name: "sidecar", Image: "busybox", ImagePullPolicy: corev1.PullNever},
+					},
+				},
+			},
+			want: []jsonPatchOp{
+				{Op: "add", Path: "/spec/initContainers/0/imagePullPolicy", Value: corev1.PullIfNotPresent},
+			},
+		},
+		{
+			name:      "Tagged image pinned to allowed digest",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx:1.27", ImagePullPolicy: corev1.PullAlways}},
+				},
+			},
+			want: []jsonPatchOp{
+				{Op: "add", Path: "/spec/containers/0/imagePullPolicy", Value: corev1.PullIfNotPresent},
+				{Op: "replace", Path: "/spec/containers/0/image", Value: "docker.io/library/nginx:1.27@" + testDigest},
+			},
+		},
+		{
+			name:      "Allowed digest unmodified",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx@" + testDigest, ImagePullPolicy: corev1.PullIfNotPresent}},
+				},
+			},
+			want: []jsonPatchOp{},
+		},
+		{
+			name:      "Unlisted tag rejected",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx:latest", ImagePullPolicy: corev1.PullAlways}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "Unlisted digest rejected",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx@" + otherDigest, ImagePullPolicy: corev1.PullIfNotPresent}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "Ephemeral container added",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx:latest", ImagePullPolicy: corev1.PullAlways}},
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug-1", Image: "busybox", ImagePullPolicy: corev1.PullAlways}},
+						{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug-2", Image: "nginx:1.27", ImagePullPolicy: corev1.PullAlways}},
+					},
+				},
+			},
+			oldPod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug-1", Image: "busybox", ImagePullPolicy: corev1.PullAlways}},
+					},
+				},
+			},
+			want: []jsonPatchOp{
+				{Op: "add", Path: "/spec/ephemeralContainers/1/imagePullPolicy", Value: corev1.PullIfNotPresent},
+				{Op: "replace", Path: "/spec/ephemeralContainers/1/image", Value: "docker.io/library/nginx:1.27@" + testDigest},
+			},
+		},
+		{
+			name:      "Unlisted ephemeral container rejected",
+			allowlist: allowlist,
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: "busybox", ImagePullPolicy: corev1.PullIfNotPresent}},
+					},
+				},
+			},
+			oldPod:  &corev1.Pod{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := podPatch(tt.allowlist, tt.pod, tt.oldPod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("podPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("podPatch() = %+v\nWant = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitRegister(t *testing.T) {
+	ctx := context.Background()
+	serverCA := filepath.Join(t.TempDir(), "server-ca.crt")
+	if err := os.WriteFile(serverCA, []byte("ca"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, serviceIPRange, _ := net.ParseCIDR("10.43.0.0/16")
+	control := &config.Control{SupervisorPort: 9345, Runtime: &config.ControlRuntime{ServerCA: serverCA}}
+	control.PreferLocalImages = true
+	control.ServiceIPRange = serviceIPRange
+	k8s := fake.NewClientset()
+	if err := Register(ctx, k8s, control); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	webhook, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, webhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhook.Webhooks) != 1 {
+		t.Fatalf("got %d webhooks, want 1", len(webhook.Webhooks))
+	}
+	var resources []string
+	for _, rule := range webhook.Webhooks[0].Rules {
+		resources = append(resources, rule.Resources...)
+	}
+	if want := []string{"pods", "pods/ephemeralcontainers"}; !reflect.DeepEqual(resources, want) {
+		t.Errorf("webhook resources = %v, want %v", resources, want)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(webhook.Webhooks[0].NamespaceSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selector.Matches(labels.Set{corev1.LabelMetadataName: metav1.NamespaceSystem}) {
+		t.Error("webhook namespaceSelector matches kube-system")
+	}
+	if !selector.Matches(labels.Set{corev1.LabelMetadataName: metav1.NamespaceDefault}) {
+		t.Error("webhook namespaceSelector does not match default")
+	}
+
+	control.PreferLocalImages = false
+	if err := Register(ctx, k8s, control); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, webhookName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("webhook configuration not removed when disabled: %v", err)
+	}
+}
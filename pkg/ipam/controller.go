@@ -0,0 +1,66 @@
+package ipam
+
+import (
+	"context"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodCIDRAllocator allocates pod CIDRs for nodes.
+type PodCIDRAllocator interface {
+	// AllocatePodCIDRs returns the pod CIDRs for a node that does not have any.
+	AllocatePodCIDRs(ctx context.Context, node *corev1.Node) ([]string, error)
+}
+
+// podCIDRRecorder is implemented by allocators that need to track the pod CIDRs of nodes that
+// already have pod CIDRs, such as nodes registered before the allocator was enabled.
+type podCIDRRecorder interface {
+	RecordPodCIDRs(ctx context.Context, node *corev1.Node) error
+}
+
+// Register starts a controller that sets the pod CIDRs of newly registered nodes,
+// using the given allocator.
+func Register(ctx context.Context, allocator PodCIDRAllocator, nodes coreclient.NodeController) error {
+	h := &handler{
+		ctx:       ctx,
+		allocator: allocator,
+		nodes:     nodes,
+	}
+	nodes.OnChange(ctx, version.Program+"-ipam", h.onChange)
+	return nil
+}
+
+type handler struct {
+	ctx       context.Context
+	allocator PodCIDRAllocator
+	nodes     coreclient.NodeController
+}
+
+// onChange allocates pod CIDRs for nodes that do not have them. The pod CIDRs of a node
+// cannot be changed once set, so nodes that already have pod CIDRs are only recorded,
+// if the allocator tracks existing allocations.
+func (h *handler) onChange(key string, node *corev1.Node) (*corev1.Node, error) {
+	if node == nil || node.DeletionTimestamp != nil {
+		return node, nil
+	}
+	if len(node.Spec.PodCIDRs) > 0 {
+		if recorder, ok := h.allocator.(podCIDRRecorder); ok {
+			return node, recorder.RecordPodCIDRs(h.ctx, node)
+		}
+		return node, nil
+	}
+
+	podCIDRs, err := h.allocator.AllocatePodCIDRs(h.ctx, node)
+	if err != nil {
+		return node, err
+	}
+
+	logrus.Infof("Setting pod CIDRs for node %s to %v", node.Name, podCIDRs)
+	node = node.DeepCopy()
+	node.Spec.PodCIDR = podCIDRs[0]
+	node.Spec.PodCIDRs = podCIDRs
+	return h.nodes.Update(node)
+}
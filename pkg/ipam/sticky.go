@@ -0,0 +1,333 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	utilsnet "k8s.io/utils/net"
+)
+
+const (
+	// maxNodeCIDRBits is the largest difference between the cluster CIDR and node CIDR mask sizes
+	// supported by the kube-controller-manager, which limits the number of node CIDRs per cluster CIDR.
+	maxNodeCIDRBits = 16
+
+	defaultNodeCIDRMaskSizeIPv4 = 24
+	defaultNodeCIDRMaskSizeIPv6 = 64
+)
+
+// PodCIDRConfigMapName is the name of the ConfigMap that sticky pod CIDR allocations are recorded in.
+var PodCIDRConfigMapName = version.Program + "-pod-cidr-allocations"
+
+// podCIDRRecord is the pod CIDR allocation recorded for a machine.
+type podCIDRRecord struct {
+	NodeName string   `json:"nodeName"`
+	PodCIDRs []string `json:"podCIDRs"`
+}
+
+// StickyAllocator allocates pod CIDRs from the cluster CIDRs, and records allocations in a ConfigMap
+// keyed by machine identity, so that a node that is reinstalled or re-registered is allocated the same
+// pod CIDRs that it had before. Allocations are retained after the node is deleted, and are only
+// reused for other nodes once all other pod CIDRs have been allocated.
+type StickyAllocator struct {
+	clusterCIDRs []*net.IPNet
+	maskSizes    []int
+	configMaps   coreclient.ConfigMapController
+	nodes        coreclient.NodeCache
+
+	mu        sync.Mutex
+	configMap *corev1.ConfigMap
+	records   map[string]podCIDRRecord
+}
+
+// NewStickyAllocator returns a sticky pod CIDR allocator. The node CIDR mask sizes are
+// taken from the kube-controller-manager args, if set.
+func NewStickyAllocator(clusterCIDRs []*net.IPNet, extraControllerArgs []string, configMaps coreclient.ConfigMapController, nodes coreclient.NodeCache) (*StickyAllocator, error) {
+	maskSizes, err := nodeCIDRMaskSizes(clusterCIDRs, extraControllerArgs)
+	if err != nil {
+		return nil, err
+	}
+	return &StickyAllocator{
+		clusterCIDRs: clusterCIDRs,
+		maskSizes:    maskSizes,
+		configMaps:   configMaps,
+		nodes:        nodes,
+	}, nil
+}
+
+// AllocatePodCIDRs allocates pod CIDRs for a node, reusing the pod CIDRs previously
+// allocated to the same machine if they are not in use by another node.
+func (a *StickyAllocator) AllocatePodCIDRs(ctx context.Context, node *corev1.Node) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+
+	nodes, err := a.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	inUse := map[string]bool{}
+	for _, n := range nodes {
+		if n.Name == node.Name {
+			continue
+		}
+		for _, cidr := range n.Spec.PodCIDRs {
+			inUse[cidr] = true
+		}
+	}
+
+	key := a.machineKey(node)
+	podCIDRs, err := allocatePodCIDRs(a.clusterCIDRs, a.maskSizes, a.records, key, inUse)
+	if err != nil {
+		return nil, err
+	}
+	if rec, ok := a.records[key]; ok && slices.Equal(rec.PodCIDRs, podCIDRs) {
+		logrus.Infof("Reusing pod CIDRs %v previously allocated to %s for node %s", podCIDRs, key, node.Name)
+	}
+	return podCIDRs, a.save(key, podCIDRRecord{NodeName: node.Name, PodCIDRs: podCIDRs})
+}
+
+// RecordPodCIDRs records the pod CIDRs of a node that already has pod CIDRs allocated,
+// so that they are reused if the node is re-registered.
+func (a *StickyAllocator) RecordPodCIDRs(ctx context.Context, node *corev1.Node) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.load(); err != nil {
+		return err
+	}
+	key := a.machineKey(node)
+	if rec, ok := a.records[key]; ok && rec.NodeName == node.Name && slices.Equal(rec.PodCIDRs, node.Spec.PodCIDRs) {
+		return nil
+	}
+	return a.save(key, podCIDRRecord{NodeName: node.Name, PodCIDRs: node.Spec.PodCIDRs})
+}
+
+// machineKey returns the key that the node's allocation is recorded under. The system UUID is used
+// if available, as it is retained when the node's OS is reinstalled; otherwise the node name is used.
+// If the system UUID is already recorded for a different node that still exists, as may happen
+// with cloned virtual machines, the node name is used instead.
+func (a *StickyAllocator) machineKey(node *corev1.Node) string {
+	nodeKey := "node." + node.Name
+	uuid := strings.ToLower(node.Status.NodeInfo.SystemUUID)
+	if uuid == "" || len(validation.IsConfigMapKey("system-uuid."+uuid)) != 0 {
+		return nodeKey
+	}
+	uuidKey := "system-uuid." + uuid
+	if rec, ok := a.records[uuidKey]; ok && rec.NodeName != node.Name {
+		if _, err := a.nodes.Get(rec.NodeName); err == nil {
+			logrus.Warnf("System UUID %s of node %s is also used by node %s; recording pod CIDRs by node name", uuid, node.Name, rec.NodeName)
+			return nodeKey
+		}
+	}
+	return uuidKey
+}
+
+// load reads the recorded allocations from the ConfigMap, if they have not already been loaded.
+func (a *StickyAllocator) load() error {
+	if a.records != nil {
+		return nil
+	}
+	configMap, err := a.configMaps.Get(metav1.NamespaceSystem, PodCIDRConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.WithMessage(err, "failed to get pod CIDR allocations")
+		}
+		configMap = nil
+	}
+	records := map[string]podCIDRRecord{}
+	if configMap != nil {
+		for key, value := range configMap.Data {
+			rec := podCIDRRecord{}
+			if err := json.Unmarshal([]byte(value), &rec); err != nil {
+				logrus.Warnf("Ignoring invalid pod CIDR allocation for %s: %v", key, err)
+				continue
+			}
+			records[key] = rec
+		}
+	}
+	a.configMap = configMap
+	a.records = records
+	return nil
+}
+
+// save records an allocation in the ConfigMap. If the ConfigMap cannot be updated, the recorded
+// allocations are discarded so that they are reloaded on the next attempt.
+func (a *StickyAllocator) save(key string, rec podCIDRRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	var configMap *corev1.ConfigMap
+	if a.configMap == nil {
+		configMap, err = a.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      PodCIDRConfigMapName,
+				Namespace: metav1.NamespaceSystem,
+			},
+			Data: map[string]string{key: string(b)},
+		})
+	} else {
+		configMap = a.configMap.DeepCopy()
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = string(b)
+		configMap, err = a.configMaps.Update(configMap)
+	}
+	if err != nil {
+		a.configMap = nil
+		a.records = nil
+		return errors.WithMessage(err, "failed to record pod CIDR allocation")
+	}
+
+	a.configMap = configMap
+	a.records[key] = rec
+	return nil
+}
+
+// allocatePodCIDRs returns the pod CIDRs for the given machine key. The previously recorded pod CIDRs
+// are returned if they are valid and not in use by another node. Otherwise, the first pod CIDR in each
+// cluster CIDR that is neither in use nor recorded for another machine is allocated. If all pod CIDRs
+// are in use or recorded, a pod CIDR recorded for another machine is reallocated.
+func allocatePodCIDRs(clusterCIDRs []*net.IPNet, maskSizes []int, records map[string]podCIDRRecord, key string, inUse map[string]bool) ([]string, error) {
+	if rec, ok := records[key]; ok && len(rec.PodCIDRs) == len(clusterCIDRs) {
+		valid := true
+		for i, podCIDR := range rec.PodCIDRs {
+			_, cidr, err := net.ParseCIDR(podCIDR)
+			if err != nil || inUse[cidr.String()] {
+				valid = false
+				break
+			}
+			if ones, _ := cidr.Mask.Size(); ones != maskSizes[i] || !cidrContained(clusterCIDRs[i:i+1], cidr) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return rec.PodCIDRs, nil
+		}
+	}
+
+	reserved := map[string]bool{}
+	for k, rec := range records {
+		if k == key {
+			continue
+		}
+		for _, podCIDR := range rec.PodCIDRs {
+			reserved[podCIDR] = true
+		}
+	}
+
+	var podCIDRs []string
+	for i, clusterCIDR := range clusterCIDRs {
+		podCIDR := nextFreeSubnet(clusterCIDR, maskSizes[i], inUse, reserved)
+		if podCIDR == "" {
+			podCIDR = nextFreeSubnet(clusterCIDR, maskSizes[i], inUse)
+			if podCIDR != "" {
+				logrus.Warnf("All pod CIDRs in cluster-cidr %s have been allocated; reallocating %s which was previously allocated to another machine", clusterCIDR, podCIDR)
+			}
+		}
+		if podCIDR == "" {
+			return nil, fmt.Errorf("no pod CIDRs available in cluster-cidr %s", clusterCIDR)
+		}
+		podCIDRs = append(podCIDRs, podCIDR)
+	}
+	return podCIDRs, nil
+}
+
+// nextFreeSubnet returns the first subnet of the given mask size within the CIDR that is
+// not present in any of the excluded sets, or an empty string if there are none.
+func nextFreeSubnet(cidr *net.IPNet, maskSize int, excluded ...map[string]bool) string {
+	ones, _ := cidr.Mask.Size()
+	for i := 0; i < 1<<(maskSize-ones); i++ {
+		subnet := nthSubnet(cidr, maskSize, i).String()
+		if !slices.ContainsFunc(excluded, func(e map[string]bool) bool { return e[subnet] }) {
+			return subnet
+		}
+	}
+	return ""
+}
+
+// nthSubnet returns the nth subnet of the given mask size within the CIDR.
+func nthSubnet(cidr *net.IPNet, maskSize int, n int) *net.IPNet {
+	_, bits := cidr.Mask.Size()
+	ip := cidr.IP.To16()
+	if bits == 32 {
+		ip = cidr.IP.To4()
+	}
+	v := new(big.Int).SetBytes(ip)
+	v.Add(v, new(big.Int).Lsh(big.NewInt(int64(n)), uint(bits-maskSize)))
+	return &net.IPNet{
+		IP:   v.FillBytes(make([]byte, bits/8)),
+		Mask: net.CIDRMask(maskSize, bits),
+	}
+}
+
+// nodeCIDRMaskSizes returns the node CIDR mask size for each cluster CIDR, using the same
+// defaults and kube-controller-manager args as the controller-manager's node IPAM controller.
+func nodeCIDRMaskSizes(clusterCIDRs []*net.IPNet, extraControllerArgs []string) ([]int, error) {
+	args := map[string]string{}
+	for _, arg := range extraControllerArgs {
+		k, v, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		args[k] = v
+	}
+
+	maskSizeIPv4 := defaultNodeCIDRMaskSizeIPv4
+	maskSizeIPv6 := defaultNodeCIDRMaskSizeIPv6
+	for _, arg := range []string{"node-cidr-mask-size", "node-cidr-mask-size-ipv4", "node-cidr-mask-size-ipv6"} {
+		v, ok := args[arg]
+		if !ok {
+			continue
+		}
+		maskSize, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", arg, v)
+		}
+		switch arg {
+		case "node-cidr-mask-size":
+			if len(clusterCIDRs) == 1 {
+				maskSizeIPv4 = maskSize
+				maskSizeIPv6 = maskSize
+			}
+		case "node-cidr-mask-size-ipv4":
+			maskSizeIPv4 = maskSize
+		case "node-cidr-mask-size-ipv6":
+			maskSizeIPv6 = maskSize
+		}
+	}
+
+	var maskSizes []int
+	for _, cidr := range clusterCIDRs {
+		maskSize := maskSizeIPv4
+		if utilsnet.IsIPv6CIDR(cidr) {
+			maskSize = maskSizeIPv6
+		}
+		ones, bits := cidr.Mask.Size()
+		if maskSize < ones || maskSize > bits || maskSize-ones > maxNodeCIDRBits {
+			return nil, fmt.Errorf("node CIDR mask size %d is not valid for cluster-cidr %s", maskSize, cidr)
+		}
+		maskSizes = append(maskSizes, maskSize)
+	}
+	return maskSizes, nil
+}
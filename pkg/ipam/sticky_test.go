@@ -0,0 +1,179 @@
+package ipam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_UnitNthSubnet(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		maskSize int
+		n        int
+		want     string
+	}{
+		{name: "first ipv4", cidr: "10.42.0.0/16", maskSize: 24, n: 0, want: "10.42.0.0/24"},
+		{name: "last ipv4", cidr: "10.42.0.0/16", maskSize: 24, n: 255, want: "10.42.255.0/24"},
+		{name: "ipv4 /26", cidr: "10.42.0.0/16", maskSize: 26, n: 5, want: "10.42.1.64/26"},
+		{name: "ipv6", cidr: "fd42::/56", maskSize: 64, n: 3, want: "fd42:0:0:3::/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nthSubnet(mustParseCIDRs(t, tt.cidr)[0], tt.maskSize, tt.n).String(); got != tt.want {
+				t.Errorf("nthSubnet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitNodeCIDRMaskSizes(t *testing.T) {
+	tests := []struct {
+		name         string
+		clusterCIDRs []string
+		args         []string
+		want         []int
+		wantErr      bool
+	}{
+		{
+			name:         "defaults",
+			clusterCIDRs: []string{"10.42.0.0/16", "fd42::/56"},
+			want:         []int{24, 64},
+		},
+		{
+			name:         "single-stack mask size",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			args:         []string{"node-cidr-mask-size=26"},
+			want:         []int{26},
+		},
+		{
+			name:         "dual-stack mask sizes",
+			clusterCIDRs: []string{"10.42.0.0/16", "fd42::/56"},
+			args:         []string{"--node-cidr-mask-size=26", "node-cidr-mask-size-ipv4=25", "node-cidr-mask-size-ipv6=60"},
+			want:         []int{25, 60},
+		},
+		{
+			name:         "invalid mask size",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			args:         []string{"node-cidr-mask-size=foo"},
+			wantErr:      true,
+		},
+		{
+			name:         "too many node CIDRs",
+			clusterCIDRs: []string{"10.0.0.0/8"},
+			args:         []string{"node-cidr-mask-size=28"},
+			wantErr:      true,
+		},
+		{
+			name:         "mask size smaller than cluster-cidr",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			args:         []string{"node-cidr-mask-size=12"},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nodeCIDRMaskSizes(mustParseCIDRs(t, tt.clusterCIDRs...), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nodeCIDRMaskSizes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodeCIDRMaskSizes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitAllocatePodCIDRs(t *testing.T) {
+	tests := []struct {
+		name         string
+		clusterCIDRs []string
+		maskSizes    []int
+		records      map[string]podCIDRRecord
+		key          string
+		inUse        map[string]bool
+		want         []string
+		wantErr      bool
+	}{
+		{
+			name:         "first allocation",
+			clusterCIDRs: []string{"10.42.0.0/16", "fd42::/56"},
+			maskSizes:    []int{24, 64},
+			key:          "system-uuid.a",
+			want:         []string{"10.42.0.0/24", "fd42::/64"},
+		},
+		{
+			name:         "reuse recorded allocation",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			maskSizes:    []int{24},
+			records: map[string]podCIDRRecord{
+				"system-uuid.a": {NodeName: "node1", PodCIDRs: []string{"10.42.7.0/24"}},
+			},
+			key:   "system-uuid.a",
+			inUse: map[string]bool{"10.42.0.0/24": true},
+			want:  []string{"10.42.7.0/24"},
+		},
+		{
+			name:         "skip in use and recorded",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			maskSizes:    []int{24},
+			records: map[string]podCIDRRecord{
+				"system-uuid.b": {NodeName: "node2", PodCIDRs: []string{"10.42.1.0/24"}},
+			},
+			key:   "system-uuid.a",
+			inUse: map[string]bool{"10.42.0.0/24": true},
+			want:  []string{"10.42.2.0/24"},
+		},
+		{
+			name:         "recorded allocation in use by another node",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			maskSizes:    []int{24},
+			records: map[string]podCIDRRecord{
+				"system-uuid.a": {NodeName: "node1", PodCIDRs: []string{"10.42.0.0/24"}},
+			},
+			key:   "system-uuid.a",
+			inUse: map[string]bool{"10.42.0.0/24": true},
+			want:  []string{"10.42.1.0/24"},
+		},
+		{
+			name:         "recorded allocation with different mask size",
+			clusterCIDRs: []string{"10.42.0.0/16"},
+			maskSizes:    []int{24},
+			records: map[string]podCIDRRecord{
+				"system-uuid.a": {NodeName: "node1", PodCIDRs: []string{"10.42.4.0/23"}},
+			},
+			key:  "system-uuid.a",
+			want: []string{"10.42.0.0/24"},
+		},
+		{
+			name:         "reallocate recorded when exhausted",
+			clusterCIDRs: []string{"10.42.0.0/24"},
+			maskSizes:    []int{25},
+			records: map[string]podCIDRRecord{
+				"system-uuid.b": {NodeName: "node2", PodCIDRs: []string{"10.42.0.128/25"}},
+			},
+			key:   "system-uuid.a",
+			inUse: map[string]bool{"10.42.0.0/25": true},
+			want:  []string{"10.42.0.128/25"},
+		},
+		{
+			name:         "exhausted",
+			clusterCIDRs: []string{"10.42.0.0/24"},
+			maskSizes:    []int{25},
+			key:          "system-uuid.a",
+			inUse:        map[string]bool{"10.42.0.0/25": true, "10.42.0.128/25": true},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allocatePodCIDRs(mustParseCIDRs(t, tt.clusterCIDRs...), tt.maskSizes, tt.records, tt.key, tt.inUse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("allocatePodCIDRs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocatePodCIDRs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	corev1 "k8s.io/api/core/v1"
+	utilsnet "k8s.io/utils/net"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+}
+
+// Request is the body sent to the IPAM webhook when a node is registered.
+type Request struct {
+	// NodeName is the name of the node being registered.
+	NodeName string `json:"nodeName"`
+	// NodeIPs contains the addresses detected on, or configured for, the node.
+	NodeIPs []string `json:"nodeIPs,omitempty"`
+	// ClusterCIDRs contains the cluster-cidr ranges that pod CIDRs must be allocated from.
+	ClusterCIDRs []string `json:"clusterCIDRs,omitempty"`
+}
+
+// Response is the body returned by the IPAM webhook. The webhook may be called more than once
+// for the same node, and should return the same allocation each time.
+type Response struct {
+	// NodeIPs contains the addresses that the node should use, at most one per IP family.
+	// If empty, the node keeps the addresses that it detected or was configured with.
+	NodeIPs []string `json:"nodeIPs,omitempty"`
+	// PodCIDRs contains the pod CIDRs allocated to the node, at most one per IP family.
+	PodCIDRs []string `json:"podCIDRs,omitempty"`
+}
+
+// Webhook is a client for an external IPAM webhook.
+type Webhook struct {
+	url          string
+	clusterCIDRs []*net.IPNet
+}
+
+// NewWebhook returns a client for the IPAM webhook at the given URL. Allocated pod CIDRs
+// are required to be within the given cluster CIDRs.
+func NewWebhook(url string, clusterCIDRs []*net.IPNet) *Webhook {
+	return &Webhook{
+		url:          url,
+		clusterCIDRs: clusterCIDRs,
+	}
+}
+
+// Allocate calls the webhook to allocate addresses for a node, and validates the response.
+func (w *Webhook) Allocate(ctx context.Context, nodeName string, nodeIPs []net.IP) (*Response, error) {
+	ipamReq := Request{NodeName: nodeName}
+	for _, ip := range nodeIPs {
+		ipamReq.NodeIPs = append(ipamReq.NodeIPs, ip.String())
+	}
+	for _, cidr := range w.clusterCIDRs {
+		ipamReq.ClusterCIDRs = append(ipamReq.ClusterCIDRs, cidr.String())
+	}
+	b, err := json.Marshal(ipamReq)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create IPAM webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		// do not return the url.Error directly, as the URL may contain a secret token
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return nil, errors.WithMessage(err, "failed to call IPAM webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("IPAM webhook returned %s for node %s", resp.Status, nodeName)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read IPAM webhook response")
+	}
+	ipamResp := &Response{}
+	if err := json.Unmarshal(body, ipamResp); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode IPAM webhook response")
+	}
+	if err := validateResponse(ipamResp, w.clusterCIDRs); err != nil {
+		return nil, errors.WithMessagef(err, "invalid IPAM webhook response for node %s", nodeName)
+	}
+	return ipamResp, nil
+}
+
+// AllocatePodCIDRs calls the webhook to allocate pod CIDRs for a node, passing the node's internal IPs.
+func (w *Webhook) AllocatePodCIDRs(ctx context.Context, node *corev1.Node) ([]string, error) {
+	var nodeIPs []net.IP
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			if ip := net.ParseIP(address.Address); ip != nil {
+				nodeIPs = append(nodeIPs, ip)
+			}
+		}
+	}
+
+	resp, err := w.Allocate(ctx, node.Name, nodeIPs)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.PodCIDRs) == 0 {
+		return nil, fmt.Errorf("IPAM webhook did not allocate pod CIDRs for node %s", node.Name)
+	}
+	return resp.PodCIDRs, nil
+}
+
+// validateResponse checks that the addresses returned by the webhook are valid, that there is at most
+// one of each per IP family, and that the pod CIDRs are within the cluster CIDRs.
+func validateResponse(resp *Response, clusterCIDRs []*net.IPNet) error {
+	families := map[utilsnet.IPFamily]bool{}
+	for _, nodeIP := range resp.NodeIPs {
+		ip := net.ParseIP(nodeIP)
+		if ip == nil {
+			return fmt.Errorf("invalid node IP %q", nodeIP)
+		}
+		family := utilsnet.IPFamilyOf(ip)
+		if families[family] {
+			return fmt.Errorf("multiple IPv%s node IPs", family)
+		}
+		families[family] = true
+	}
+
+	families = map[utilsnet.IPFamily]bool{}
+	for _, podCIDR := range resp.PodCIDRs {
+		_, cidr, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			return fmt.Errorf("invalid pod CIDR %q", podCIDR)
+		}
+		family := utilsnet.IPFamilyOfCIDR(cidr)
+		if families[family] {
+			return fmt.Errorf("multiple IPv%s pod CIDRs", family)
+		}
+		families[family] = true
+		if !cidrContained(clusterCIDRs, cidr) {
+			return fmt.Errorf("pod CIDR %s is not within the cluster-cidr", cidr)
+		}
+	}
+	return nil
+}
+
+// cidrContained returns true if the CIDR is fully contained within any of the given ranges.
+func cidrContained(ranges []*net.IPNet, cidr *net.IPNet) bool {
+	ones, bits := cidr.Mask.Size()
+	for _, r := range ranges {
+		rangeOnes, rangeBits := r.Mask.Size()
+		if rangeBits == bits && rangeOnes <= ones && r.Contains(cidr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func mustParseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	var res []*net.IPNet
+	for _, cidr := range cidrs {
+		_, parsed, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, parsed)
+	}
+	return res
+}
+
+func Test_UnitValidateResponse(t *testing.T) {
+	clusterCIDRs := mustParseCIDRs(t, "10.42.0.0/16", "fd42::/56")
+	tests := []struct {
+		name    string
+		resp    *Response
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			resp: &Response{},
+		},
+		{
+			name: "dual-stack",
+			resp: &Response{
+				NodeIPs:  []string{"192.168.1.10", "fd00::10"},
+				PodCIDRs: []string{"10.42.3.0/24", "fd42:0:0:3::/64"},
+			},
+		},
+		{
+			name:    "invalid node IP",
+			resp:    &Response{NodeIPs: []string{"192.168.1.300"}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple node IPs of the same family",
+			resp:    &Response{NodeIPs: []string{"192.168.1.10", "192.168.1.11"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pod CIDR",
+			resp:    &Response{PodCIDRs: []string{"10.42.3.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple pod CIDRs of the same family",
+			resp:    &Response{PodCIDRs: []string{"10.42.3.0/24", "10.42.4.0/24"}},
+			wantErr: true,
+		},
+		{
+			name:    "pod CIDR outside cluster-cidr",
+			resp:    &Response{PodCIDRs: []string{"10.43.3.0/24"}},
+			wantErr: true,
+		},
+		{
+			name:    "pod CIDR larger than cluster-cidr",
+			resp:    &Response{PodCIDRs: []string{"10.42.0.0/15"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateResponse(tt.resp, clusterCIDRs); (err != nil) != tt.wantErr {
+				t.Errorf("validateResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitWebhookAllocate(t *testing.T) {
+	var got Request
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if got.NodeName == "unknown" {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(resp).Encode(Response{
+			NodeIPs:  []string{"192.168.1.10"},
+			PodCIDRs: []string{"10.42.3.0/24"},
+		})
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL, mustParseCIDRs(t, "10.42.0.0/16"))
+	resp, err := webhook.Allocate(context.Background(), "node1", []net.IP{net.ParseIP("192.168.1.5")})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	wantReq := Request{NodeName: "node1", NodeIPs: []string{"192.168.1.5"}, ClusterCIDRs: []string{"10.42.0.0/16"}}
+	if !reflect.DeepEqual(got, wantReq) {
+		t.Errorf("Allocate() sent request = %+v, want %+v", got, wantReq)
+	}
+	wantResp := &Response{NodeIPs: []string{"192.168.1.10"}, PodCIDRs: []string{"10.42.3.0/24"}}
+	if !reflect.DeepEqual(resp, wantResp) {
+		t.Errorf("Allocate() = %+v, want %+v", resp, wantResp)
+	}
+
+	if _, err := webhook.Allocate(context.Background(), "unknown", nil); err == nil {
+		t.Errorf("Allocate() for unknown node expected error")
+	}
+}
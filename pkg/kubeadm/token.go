@@ -2,6 +2,7 @@ package kubeadm
 
 import (
 	"errors"
+	"slices"
 
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/version"
@@ -11,15 +12,44 @@ import (
 )
 
 var (
-	NodeBootstrapTokenAuthGroup = "system:bootstrappers:" + version.Program + ":default-node-token"
+	NodeBootstrapTokenAuthGroup   = "system:bootstrappers:" + version.Program + ":default-node-token"
+	ServerBootstrapTokenAuthGroup = "system:bootstrappers:" + version.Program + ":server"
+)
+
+// Token roles restrict the type of node that a bootstrap token can be used to join. The role is encoded in the
+// token's extra groups, and enforced by the supervisor: agent tokens authenticate as the default node token group,
+// and cannot be used to join servers. Server tokens authenticate as the server token group, and can only be used to
+// retrieve the bootstrap data and etcd member list needed to join servers.
+const (
+	TokenRoleAgent  = "agent"
+	TokenRoleServer = "server"
 )
 
 // SetDefaults ensures that the default values are set on the token configuration.
 // These are set here, rather than in the default Token struct, to avoid
 // importing the cluster-bootstrap packages into the CLI.
 func SetDefaults(clx *cli.Context, cfg *cmds.Token) error {
-	if !clx.IsSet("groups") {
-		cfg.Groups = *cli.NewStringSlice(NodeBootstrapTokenAuthGroup)
+	switch cfg.Role {
+	case "", TokenRoleAgent:
+		if !clx.IsSet("groups") {
+			cfg.Groups = *cli.NewStringSlice(NodeBootstrapTokenAuthGroup)
+		}
+		if slices.Contains(cfg.Groups.Value(), ServerBootstrapTokenAuthGroup) {
+			return errors.New("agent tokens cannot include the group " + ServerBootstrapTokenAuthGroup)
+		}
+	case TokenRoleServer:
+		if slices.Contains(cfg.Groups.Value(), NodeBootstrapTokenAuthGroup) {
+			return errors.New("server tokens cannot include the group " + NodeBootstrapTokenAuthGroup)
+		}
+		if !slices.Contains(cfg.Groups.Value(), ServerBootstrapTokenAuthGroup) {
+			cfg.Groups = *cli.NewStringSlice(append(cfg.Groups.Value(), ServerBootstrapTokenAuthGroup)...)
+		}
+	default:
+		return errors.New("invalid token role: " + cfg.Role)
+	}
+
+	if cfg.MaxUses < 0 {
+		return errors.New("invalid max uses: must not be negative")
 	}
 
 	if !clx.IsSet("usages") {
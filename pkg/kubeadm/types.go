@@ -33,6 +33,13 @@ type BootstrapToken struct {
 	// used for authentication
 	// +optional
 	Groups []string `json:"groups,omitempty"`
+	// MaxUses is the number of distinct nodes that may join using this token. Zero means unlimited.
+	// This is not a kubeadm field; it is stored as an annotation on the Secret and enforced by the supervisor.
+	// +optional
+	MaxUses int `json:"maxUses,omitempty"`
+	// UsedBy lists the nodes that have joined using this token, if MaxUses is set.
+	// +optional
+	UsedBy []string `json:"usedBy,omitempty"`
 }
 
 // BootstrapTokenString is a token of the format abcdef.abcdef0123456789 that is used
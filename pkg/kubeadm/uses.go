@@ -0,0 +1,86 @@
+package kubeadm
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Limited-use tokens may only be used to join a fixed number of distinct nodes. The limit, and the nodes that have
+// used the token, are tracked by annotations on the token Secret. Nodes that have already used the token may continue
+// to use it, so that agents can retrieve new certificates when restarted; the node password prevents other hosts from
+// reusing the name of a node that has already joined.
+var (
+	TokenMaxUsesAnnotation = version.Program + ".io/token-max-uses"
+	TokenUsedByAnnotation  = version.Program + ".io/token-used-by"
+)
+
+var (
+	// ErrTokenUsesExhausted is returned when a limited-use token has already been used by the maximum number of nodes.
+	ErrTokenUsesExhausted = errors.New("bootstrap token has been used the maximum number of times")
+	// ErrTokenNodeRequired is returned when a limited-use token is used by a client that does not identify its node.
+	ErrTokenNodeRequired = errors.New("node name is required to use a limited-use bootstrap token")
+)
+
+// TokenUses returns the maximum number of uses for the token Secret, and the nodes that have used it.
+// A maximum of zero indicates that the token may be used an unlimited number of times.
+func TokenUses(secret *v1.Secret) (int, []string, error) {
+	value, ok := secret.Annotations[TokenMaxUsesAnnotation]
+	if !ok {
+		return 0, nil, nil
+	}
+	maxUses, err := strconv.Atoi(value)
+	if err != nil || maxUses < 0 {
+		return 0, nil, fmt.Errorf("invalid %s annotation on bootstrap token %q: %q", TokenMaxUsesAnnotation, secret.Name, value)
+	}
+	var usedBy []string
+	if value := secret.Annotations[TokenUsedByAnnotation]; value != "" {
+		usedBy = strings.Split(value, ",")
+	}
+	return maxUses, usedBy, nil
+}
+
+// ReserveTokenUse records that the node has used the token, returning true if the Secret was modified. An error
+// is returned if the node has not already used the token, and the token has no remaining uses.
+func ReserveTokenUse(secret *v1.Secret, node string) (bool, error) {
+	maxUses, usedBy, err := TokenUses(secret)
+	if err != nil || maxUses == 0 || slices.Contains(usedBy, node) {
+		return false, err
+	}
+	if node == "" {
+		return false, ErrTokenNodeRequired
+	}
+	if len(usedBy) >= maxUses {
+		return false, ErrTokenUsesExhausted
+	}
+	secret.Annotations[TokenUsedByAnnotation] = strings.Join(append(usedBy, node), ",")
+	return true, nil
+}
+
+// CheckTokenUse returns an error if the token has a maximum number of uses, and the node has not already used it.
+func CheckTokenUse(secret *v1.Secret, node string) error {
+	maxUses, usedBy, err := TokenUses(secret)
+	if err != nil || maxUses == 0 || slices.Contains(usedBy, node) {
+		return err
+	}
+	if node == "" {
+		return ErrTokenNodeRequired
+	}
+	return ErrTokenUsesExhausted
+}
+
+// ReleaseTokenUse removes the node from the list of nodes that have used the token, returning true if the
+// Secret was modified.
+func ReleaseTokenUse(secret *v1.Secret, node string) bool {
+	_, usedBy, err := TokenUses(secret)
+	if err != nil || !slices.Contains(usedBy, node) {
+		return false
+	}
+	secret.Annotations[TokenUsedByAnnotation] = strings.Join(slices.DeleteFunc(usedBy, func(n string) bool { return n == node }), ",")
+	return true
+}
@@ -0,0 +1,89 @@
+package kubeadm
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitReserveTokenUse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		node        string
+		wantChanged bool
+		wantUsedBy  string
+		wantErr     bool
+	}{
+		{
+			name:        "unlimited token",
+			annotations: map[string]string{},
+			node:        "agent-1",
+		},
+		{
+			name:        "first use",
+			annotations: map[string]string{TokenMaxUsesAnnotation: "1"},
+			node:        "agent-1",
+			wantChanged: true,
+			wantUsedBy:  "agent-1",
+		},
+		{
+			name:        "reuse by same node",
+			annotations: map[string]string{TokenMaxUsesAnnotation: "1", TokenUsedByAnnotation: "agent-1"},
+			node:        "agent-1",
+			wantUsedBy:  "agent-1",
+		},
+		{
+			name:        "uses exhausted",
+			annotations: map[string]string{TokenMaxUsesAnnotation: "1", TokenUsedByAnnotation: "agent-1"},
+			node:        "agent-2",
+			wantUsedBy:  "agent-1",
+			wantErr:     true,
+		},
+		{
+			name:        "uses remaining",
+			annotations: map[string]string{TokenMaxUsesAnnotation: "3", TokenUsedByAnnotation: "agent-1"},
+			node:        "agent-2",
+			wantChanged: true,
+			wantUsedBy:  "agent-1,agent-2",
+		},
+		{
+			name:        "invalid max uses",
+			annotations: map[string]string{TokenMaxUsesAnnotation: "many"},
+			node:        "agent-1",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-token-abcdef", Annotations: tt.annotations}}
+			changed, err := ReserveTokenUse(secret, tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReserveTokenUse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("ReserveTokenUse() = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := secret.Annotations[TokenUsedByAnnotation]; got != tt.wantUsedBy {
+				t.Errorf("ReserveTokenUse() used by = %q, want %q", got, tt.wantUsedBy)
+			}
+		})
+	}
+}
+
+func Test_UnitReleaseTokenUse(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		TokenMaxUsesAnnotation: "2",
+		TokenUsedByAnnotation:  "agent-1,agent-2",
+	}}}
+	if ReleaseTokenUse(secret, "agent-3") {
+		t.Errorf("ReleaseTokenUse() = true for node that has not used the token")
+	}
+	if !ReleaseTokenUse(secret, "agent-1") {
+		t.Errorf("ReleaseTokenUse() = false for node that has used the token")
+	}
+	if got := secret.Annotations[TokenUsedByAnnotation]; got != "agent-2" {
+		t.Errorf("ReleaseTokenUse() used by = %q, want %q", got, "agent-2")
+	}
+}
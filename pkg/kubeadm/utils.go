@@ -3,6 +3,7 @@ package kubeadm
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -49,7 +50,7 @@ func NewBootstrapTokenStringFromIDAndSecret(id, secret string) (*BootstrapTokenS
 // BootstrapTokenToSecret converts the given BootstrapToken object to its Secret representation that
 // may be submitted to the API Server in order to be stored.
 func BootstrapTokenToSecret(bt *BootstrapToken) *v1.Secret {
-	return &v1.Secret{
+	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      bootstraputil.BootstrapTokenSecretName(bt.Token.ID),
 			Namespace: metav1.NamespaceSystem,
@@ -57,6 +58,10 @@ func BootstrapTokenToSecret(bt *BootstrapToken) *v1.Secret {
 		Type: v1.SecretType(bootstrapapi.SecretTypeBootstrapToken),
 		Data: encodeTokenSecretData(bt, time.Now()),
 	}
+	if bt.MaxUses > 0 {
+		secret.Annotations = map[string]string{TokenMaxUsesAnnotation: strconv.Itoa(bt.MaxUses)}
+	}
+	return secret
 }
 
 // encodeTokenSecretData takes the token discovery object and an optional duration and returns the .Data for the Secret
@@ -164,11 +169,18 @@ func BootstrapTokenFromSecret(secret *v1.Secret) (*BootstrapToken, error) {
 		groups = g
 	}
 
+	maxUses, usedBy, err := TokenUses(secret)
+	if err != nil {
+		return nil, err
+	}
+
 	return &BootstrapToken{
 		Token:       bts,
 		Description: description,
 		Expires:     expires,
 		Usages:      usages,
 		Groups:      groups,
+		MaxUses:     maxUses,
+		UsedBy:      usedBy,
 	}, nil
 }
@@ -0,0 +1,139 @@
+// Package loadshed detects CPU pressure on servers, and allows non-critical embedded controllers to defer their
+// work while the node is starved of CPU, so that apiserver and etcd latency stays bounded on overloaded nodes.
+package loadshed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/metrics"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// pollInterval is the interval at which CPU pressure is checked. The kernel averages pressure over 10 seconds,
+// so checking more frequently would not be useful.
+const pollInterval = 10 * time.Second
+
+var (
+	cpuPressure = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: version.Program + "_cpu_pressure_percent",
+		Help: "Percentage of time over the last 10 seconds that at least one task was stalled waiting for CPU.",
+	})
+	throttled = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: version.Program + "_cpu_pressure_throttled",
+		Help: "Set to 1 while non-critical controllers are deferring work due to CPU pressure.",
+	})
+
+	defaultMonitor = &monitor{}
+)
+
+// monitor tracks whether the node is under CPU pressure. Relieved is nil when the node is not under pressure,
+// and is closed when pressure drops below the threshold.
+type monitor struct {
+	mu       sync.Mutex
+	maxDelay time.Duration
+	relieved chan struct{}
+}
+
+// Start begins polling CPU pressure. While the pressure is at or above the threshold percentage, calls to Wait block
+// until the pressure drops, or the max delay elapses. An error is returned if CPU pressure information is not
+// available on this node.
+func Start(ctx context.Context, threshold float64, maxDelay time.Duration) error {
+	if _, err := readCPUPressure(); err != nil {
+		return errors.WithMessage(err, "failed to read CPU pressure")
+	}
+	metrics.DefaultRegisterer.MustRegister(cpuPressure, throttled)
+
+	defaultMonitor.mu.Lock()
+	defaultMonitor.maxDelay = maxDelay
+	defaultMonitor.mu.Unlock()
+
+	logrus.Infof("Starting CPU pressure monitor with threshold %.2f%% and max delay %s", threshold, maxDelay)
+	go wait.Until(func() {
+		pressure, err := readCPUPressure()
+		if err != nil {
+			logrus.Warnf("Failed to read CPU pressure: %v", err)
+			return
+		}
+		cpuPressure.Set(pressure)
+		defaultMonitor.update(pressure, threshold)
+	}, pollInterval, ctx.Done())
+	go func() {
+		<-ctx.Done()
+		defaultMonitor.update(0, 0)
+	}()
+	return nil
+}
+
+// Wait blocks while the node is under CPU pressure, until the pressure drops, the max delay elapses, or the
+// context is cancelled. The name of the deferred work is logged. Wait returns immediately if the monitor has
+// not been started.
+func Wait(ctx context.Context, name string) {
+	defaultMonitor.wait(ctx, name)
+}
+
+func (m *monitor) wait(ctx context.Context, name string) {
+	m.mu.Lock()
+	relieved, maxDelay := m.relieved, m.maxDelay
+	m.mu.Unlock()
+	if relieved == nil {
+		return
+	}
+
+	logrus.Infof("Deferring %s due to CPU pressure", name)
+	timer := time.NewTimer(maxDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-relieved:
+		logrus.Infof("Resuming %s", name)
+	case <-timer.C:
+		logrus.Warnf("Resuming %s after deferring it for %s; CPU pressure has not subsided", name, maxDelay)
+	}
+}
+
+// update records the current CPU pressure, and releases any waiters if the pressure has dropped below the threshold.
+func (m *monitor) update(pressure, threshold float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	underPressure := threshold > 0 && pressure >= threshold
+	switch {
+	case underPressure && m.relieved == nil:
+		logrus.Warnf("CPU pressure %.2f%% exceeds threshold %.2f%%; deferring non-critical controllers", pressure, threshold)
+		m.relieved = make(chan struct{})
+		throttled.Set(1)
+	case !underPressure && m.relieved != nil:
+		logrus.Infof("CPU pressure %.2f%% is below threshold %.2f%%; resuming non-critical controllers", pressure, threshold)
+		close(m.relieved)
+		m.relieved = nil
+		throttled.Set(0)
+	}
+}
+
+// parseCPUPressure returns the 10 second average from the "some" line of the CPU pressure stall information, which
+// is the percentage of time that at least one runnable task was waiting for CPU.
+func parseCPUPressure(data []byte) (float64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg10="); ok {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("avg10 not found in CPU pressure %q", data)
+}
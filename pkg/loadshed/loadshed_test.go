@@ -0,0 +1,87 @@
+package loadshed
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_UnitParseCPUPressure(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{
+			name: "pressure",
+			data: "some avg10=42.50 avg60=10.00 avg300=2.00 total=123456\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want: 42.5,
+		},
+		{
+			name: "some line only",
+			data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			data:    "some avg10=high\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCPUPressure([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCPUPressure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCPUPressure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitMonitorWait(t *testing.T) {
+	m := &monitor{maxDelay: time.Minute}
+
+	// not under pressure
+	waitWithin(t, m, time.Second)
+
+	// under pressure until relieved
+	m.update(80, 50)
+	done := make(chan struct{})
+	go func() {
+		m.wait(context.Background(), "test")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("wait() returned while under pressure")
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.update(20, 50)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait() did not return after pressure was relieved")
+	}
+
+	// under pressure until the max delay elapses
+	m.maxDelay = 10 * time.Millisecond
+	m.update(80, 50)
+	waitWithin(t, m, time.Second)
+}
+
+func waitWithin(t *testing.T, m *monitor, timeout time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	m.wait(ctx, "test")
+	if ctx.Err() != nil {
+		t.Fatalf("wait() did not return within %s", timeout)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package loadshed
+
+import "os"
+
+// cpuPressureFile contains the system-wide CPU pressure stall information. It is only present on kernels
+// built with CONFIG_PSI, and may be disabled with the psi=0 kernel command line argument.
+const cpuPressureFile = "/proc/pressure/cpu"
+
+func readCPUPressure() (float64, error) {
+	data, err := os.ReadFile(cpuPressureFile)
+	if err != nil {
+		return 0, err
+	}
+	return parseCPUPressure(data)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package loadshed
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+func readCPUPressure() (float64, error) {
+	return 0, errors.ErrUnsupportedPlatform
+}
@@ -0,0 +1,196 @@
+// Package loglevel temporarily adjusts the log level of components embedded in a running server, so that debug logs
+// can be captured without restarting the server. Adjustments are reverted automatically when they expire.
+package loglevel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Supervisor is the supervisor process itself, including embedded controllers and kine.
+	Supervisor = "supervisor"
+	// Components that log with klog.
+	KubeAPIServer          = "kube-apiserver"
+	KubeControllerManager  = "kube-controller-manager"
+	KubeScheduler          = "kube-scheduler"
+	CloudControllerManager = "cloud-controller-manager"
+	Kubelet                = "kubelet"
+	KubeProxy              = "kube-proxy"
+
+	// MaxDuration is the longest that a log level adjustment can remain active.
+	MaxDuration = 24 * time.Hour
+	// maxVerbosity is the highest klog verbosity that can be set.
+	maxVerbosity = 10
+
+	// logger names, used to track adjustments to loggers that are shared by multiple components
+	loggerLogrus = "logrus"
+	loggerKlog   = "klog"
+)
+
+var (
+	// Components are the components whose log level can be adjusted.
+	Components = []string{Supervisor, KubeAPIServer, KubeControllerManager, KubeScheduler, CloudControllerManager, Kubelet, KubeProxy}
+
+	// klogComponents share a single klog verbosity, as they all run in the same process.
+	klogComponents = []string{KubeAPIServer, KubeControllerManager, KubeScheduler, CloudControllerManager, Kubelet, KubeProxy}
+
+	// unsupported lists components that cannot have their log level adjusted at runtime, and why.
+	unsupported = map[string]string{
+		"etcd":       "the embedded etcd logger level is fixed at startup",
+		"containerd": "containerd runs as a separate process, and does not support changing its log level at runtime",
+	}
+
+	defaultAdjuster = &adjuster{adjustments: map[string]*adjustment{}}
+)
+
+// Request is a request to adjust the log level of a component. Level is a verbosity number for Kubernetes
+// components, or a logrus level name (trace, debug, info, warn, error) for the supervisor. Duration is how long
+// the adjustment remains active before the original level is restored, and is required.
+type Request struct {
+	Component string          `json:"component"`
+	Level     string          `json:"level"`
+	Duration  metav1.Duration `json:"duration"`
+}
+
+// Status describes an active log level adjustment. Components lists all of the components affected by the
+// adjustment, as some components share a logger.
+type Status struct {
+	Components    []string    `json:"components"`
+	Level         string      `json:"level"`
+	OriginalLevel string      `json:"originalLevel"`
+	Expiry        metav1.Time `json:"expiry"`
+}
+
+// adjustment is an active adjustment to a logger, which is reverted by the timer.
+type adjustment struct {
+	original string
+	level    string
+	expiry   time.Time
+	timer    *time.Timer
+}
+
+type adjuster struct {
+	mu          sync.Mutex
+	adjustments map[string]*adjustment
+}
+
+// Set adjusts the log level of the requested component until the duration expires. If the component's
+// level has already been adjusted, the level and expiry are replaced, and the original level is retained.
+func Set(req Request) (*Status, error) {
+	return defaultAdjuster.set(req)
+}
+
+func (a *adjuster) set(req Request) (*Status, error) {
+	if reason, ok := unsupported[req.Component]; ok {
+		return nil, fmt.Errorf("log level for component %q cannot be adjusted: %s", req.Component, reason)
+	}
+	if !slices.Contains(Components, req.Component) {
+		return nil, fmt.Errorf("invalid component %q: must be one of %s", req.Component, strings.Join(Components, ", "))
+	}
+	if req.Duration.Duration <= 0 || req.Duration.Duration > MaxDuration {
+		return nil, fmt.Errorf("invalid duration %s: must be greater than 0s and at most %s", req.Duration.Duration, MaxDuration)
+	}
+
+	logger, components := loggerLogrus, []string{Supervisor}
+	if slices.Contains(klogComponents, req.Component) {
+		logger, components = loggerKlog, klogComponents
+	}
+	level, err := normalize(logger, req.Level)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid level %q for component %q", req.Level, req.Component)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	adj, ok := a.adjustments[logger]
+	if ok {
+		adj.timer.Stop()
+	} else {
+		adj = &adjustment{original: current(logger)}
+		a.adjustments[logger] = adj
+	}
+	if err := apply(logger, level); err != nil {
+		if !ok {
+			delete(a.adjustments, logger)
+		}
+		return nil, err
+	}
+	adj.level = level
+	adj.expiry = time.Now().Add(req.Duration.Duration)
+	adj.timer = time.AfterFunc(req.Duration.Duration, func() { a.revert(logger, adj) })
+	logrus.Warnf("Log level for %s set to %s for %s; it will be reverted to %s", strings.Join(components, ", "), level, req.Duration.Duration, adj.original)
+
+	return &Status{
+		Components:    components,
+		Level:         level,
+		OriginalLevel: adj.original,
+		Expiry:        metav1.NewTime(adj.expiry),
+	}, nil
+}
+
+// revert restores the original level of a logger, if the adjustment has not since been replaced.
+func (a *adjuster) revert(logger string, adj *adjustment) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.adjustments[logger] != adj || time.Now().Before(adj.expiry) {
+		return
+	}
+	delete(a.adjustments, logger)
+	if err := apply(logger, adj.original); err != nil {
+		logrus.Errorf("Failed to revert %s log level to %s: %v", logger, adj.original, err)
+		return
+	}
+	logrus.Infof("Reverted %s log level to %s", logger, adj.original)
+}
+
+// normalize validates a level for a logger, and returns it in canonical form.
+func normalize(logger, level string) (string, error) {
+	if logger == loggerKlog {
+		v, err := strconv.Atoi(level)
+		if err != nil || v < 0 || v > maxVerbosity {
+			return "", fmt.Errorf("must be a verbosity between 0 and %d", maxVerbosity)
+		}
+		return strconv.Itoa(v), nil
+	}
+	l, err := logrus.ParseLevel(level)
+	if err != nil {
+		return "", err
+	}
+	return l.String(), nil
+}
+
+// current returns the current level of a logger.
+func current(logger string) string {
+	if logger == loggerKlog {
+		v := 0
+		for v < maxVerbosity && klog.V(klog.Level(v+1)).Enabled() {
+			v++
+		}
+		return strconv.Itoa(v)
+	}
+	return logrus.GetLevel().String()
+}
+
+// apply sets the level of a logger. The level must already be normalized.
+func apply(logger, level string) error {
+	if logger == loggerKlog {
+		var v klog.Level
+		return v.Set(level)
+	}
+	l, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(l)
+	return nil
+}
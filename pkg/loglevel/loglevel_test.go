@@ -0,0 +1,109 @@
+package loglevel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       Request
+		wantLevel string
+		wantErr   bool
+	}{
+		{
+			name:      "supervisor debug",
+			req:       Request{Component: Supervisor, Level: "DEBUG", Duration: metav1.Duration{Duration: time.Minute}},
+			wantLevel: "debug",
+		},
+		{
+			name:      "apiserver verbosity",
+			req:       Request{Component: KubeAPIServer, Level: "6", Duration: metav1.Duration{Duration: time.Minute}},
+			wantLevel: "6",
+		},
+		{
+			name:    "invalid verbosity",
+			req:     Request{Component: Kubelet, Level: "debug", Duration: metav1.Duration{Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "verbosity too high",
+			req:     Request{Component: Kubelet, Level: "11", Duration: metav1.Duration{Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid supervisor level",
+			req:     Request{Component: Supervisor, Level: "6", Duration: metav1.Duration{Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported component",
+			req:     Request{Component: "containerd", Level: "debug", Duration: metav1.Duration{Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown component",
+			req:     Request{Component: "flannel", Level: "4", Duration: metav1.Duration{Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "missing duration",
+			req:     Request{Component: Supervisor, Level: "debug"},
+			wantErr: true,
+		},
+		{
+			name:    "duration too long",
+			req:     Request{Component: Supervisor, Level: "debug", Duration: metav1.Duration{Duration: 48 * time.Hour}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &adjuster{adjustments: map[string]*adjustment{}}
+			status, err := a.set(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for logger, adj := range a.adjustments {
+				adj.timer.Stop()
+				apply(logger, adj.original)
+			}
+			if err == nil && status.Level != tt.wantLevel {
+				t.Errorf("set() level = %s, want %s", status.Level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func Test_UnitRevert(t *testing.T) {
+	logrus.SetLevel(logrus.InfoLevel)
+	a := &adjuster{adjustments: map[string]*adjustment{}}
+
+	if _, err := a.set(Request{Component: Supervisor, Level: "debug", Duration: metav1.Duration{Duration: 100 * time.Millisecond}}); err != nil {
+		t.Fatal(err)
+	}
+	// a second adjustment replaces the level and expiry, but retains the original level
+	status, err := a.set(Request{Component: Supervisor, Level: "trace", Duration: metav1.Duration{Duration: 200 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.OriginalLevel != "info" {
+		t.Errorf("set() original level = %s, want info", status.OriginalLevel)
+	}
+	if got := logrus.GetLevel(); got != logrus.TraceLevel {
+		t.Errorf("level after set() = %s, want trace", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if got := logrus.GetLevel(); got != logrus.TraceLevel {
+		t.Errorf("level after first expiry = %s, want trace", got)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := logrus.GetLevel(); got != logrus.InfoLevel {
+		t.Errorf("level after second expiry = %s, want info", got)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package logmetrics
+
+import (
+	"io"
+	"os"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"golang.org/x/sys/unix"
+)
+
+// CaptureStderr replaces the process's stderr with a pipe, so that output written by embedded components
+// directly to stderr can be matched against registered patterns. Output is passed through to the
+// original stderr.
+func CaptureStderr(backend Backend) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return errors.WithMessage(err, "failed to create stderr pipe")
+	}
+	fd, err := unix.Dup(int(os.Stderr.Fd()))
+	if err != nil {
+		r.Close()
+		w.Close()
+		return errors.WithMessage(err, "failed to duplicate stderr")
+	}
+	if err := unix.Dup3(int(w.Fd()), int(os.Stderr.Fd()), 0); err != nil {
+		r.Close()
+		w.Close()
+		unix.Close(fd)
+		return errors.WithMessage(err, "failed to replace stderr")
+	}
+	w.Close()
+
+	out := os.NewFile(uintptr(fd), os.Stderr.Name())
+	go func() {
+		// Keep draining the pipe if the original stderr cannot be written to, so that writes to stderr never block
+		if _, err := io.Copy(NewWriter(out, backend), r); err != nil {
+			io.Copy(io.Discard, r)
+		}
+	}()
+	return nil
+}
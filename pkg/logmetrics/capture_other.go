@@ -0,0 +1,10 @@
+//go:build !linux
+
+package logmetrics
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+// CaptureStderr is not supported on this platform.
+func CaptureStderr(backend Backend) error {
+	return errors.ErrUnsupportedPlatform
+}
@@ -0,0 +1,133 @@
+package logmetrics
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// maxLineLength is the longest line that will be matched against patterns. Longer lines are
+// still written to the output, but are not matched.
+const maxLineLength = 64 * 1024
+
+// Pattern matches a known warning or error in the log output of an embedded component.
+type Pattern struct {
+	// Component is the name of the component that logs the message
+	Component string
+	// Event is a short name for the condition indicated by the message
+	Event string
+	// Regexp matches lines that contain the message
+	Regexp *regexp.Regexp
+}
+
+// Backend records events matched in log output.
+type Backend interface {
+	Record(component, event string)
+}
+
+var (
+	mu       sync.RWMutex
+	patterns = []Pattern{
+		{
+			Component: "apiserver",
+			Event:     "slow_request",
+			Regexp:    regexp.MustCompile(`Trace\[\d+\]: ".*\(total time: \d+`),
+		},
+		{
+			Component: "client-go",
+			Event:     "client_throttling",
+			Regexp:    regexp.MustCompile(`Waited for .* due to client-side throttling`),
+		},
+		{
+			Component: "etcd",
+			Event:     "slow_fdatasync",
+			Regexp:    regexp.MustCompile(`"msg":"slow fdatasync"`),
+		},
+		{
+			Component: "etcd",
+			Event:     "slow_apply",
+			Regexp:    regexp.MustCompile(`"msg":"apply request took too long"`),
+		},
+		{
+			Component: "etcd",
+			Event:     "heartbeat_delayed",
+			Regexp:    regexp.MustCompile(`"msg":"leader failed to send out heartbeat on time`),
+		},
+		{
+			Component: "kubelet",
+			Event:     "pleg_unhealthy",
+			Regexp:    regexp.MustCompile(`PLEG is not healthy`),
+		},
+		{
+			Component: "kubelet",
+			Event:     "eviction",
+			Regexp:    regexp.MustCompile(`eviction manager: attempting to reclaim`),
+		},
+	}
+)
+
+// Register adds a pattern to the set of patterns matched against log output.
+func Register(pattern Pattern) {
+	mu.Lock()
+	defer mu.Unlock()
+	patterns = append(patterns, pattern)
+}
+
+// Match records an event for each pattern that matches the line.
+func Match(backend Backend, line []byte) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, p := range patterns {
+		if p.Regexp.Match(line) {
+			backend.Record(p.Component, p.Event)
+		}
+	}
+}
+
+// writer passes output through to the underlying writer, and matches each complete line against
+// the registered patterns.
+type writer struct {
+	out     io.Writer
+	backend Backend
+	line    []byte
+	skip    bool
+}
+
+// NewWriter returns a writer that writes to out, and records events for lines that match
+// registered patterns using the provided backend.
+func NewWriter(out io.Writer, backend Backend) io.Writer {
+	return &writer{out: out, backend: backend}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			w.buffer(p)
+			break
+		}
+		w.buffer(p[:i])
+		if !w.skip {
+			Match(w.backend, w.line)
+		}
+		w.line = w.line[:0]
+		w.skip = false
+		p = p[i+1:]
+	}
+	return n, err
+}
+
+// buffer appends to the current line, discarding lines that exceed the maximum length.
+func (w *writer) buffer(p []byte) {
+	if w.skip {
+		return
+	}
+	if len(w.line)+len(p) > maxLineLength {
+		w.line = w.line[:0]
+		w.skip = true
+		return
+	}
+	w.line = append(w.line, p...)
+}
@@ -0,0 +1,72 @@
+package logmetrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) Record(component, event string) {
+	r.events = append(r.events, component+"/"+event)
+}
+
+func Test_UnitWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "etcd slow fdatasync",
+			writes: []string{`{"level":"warn","ts":"2024-01-01T00:00:00.000Z","caller":"wal/wal.go:805","msg":"slow fdatasync","took":"1.5s","expected-duration":"1s"}` + "\n"},
+			want:   []string{"etcd/slow_fdatasync"},
+		},
+		{
+			name:   "kubelet PLEG split across writes",
+			writes: []string{`E0101 00:00:00.000000 1234 kubelet.go:2407] "Skipping pod synchronization" err="PLEG is not`, ` healthy: pleg was last seen active 3m0s ago"` + "\n"},
+			want:   []string{"kubelet/pleg_unhealthy"},
+		},
+		{
+			name:   "apiserver slow request",
+			writes: []string{`I0101 00:00:00.000000 1234 trace.go:236] Trace[123456]: "List" accept:application/json,audit-id:abc (01-Jan-2024 00:00:00.000) (total time: 1234ms):` + "\n"},
+			want:   []string{"apiserver/slow_request"},
+		},
+		{
+			name:   "multiple lines in one write",
+			writes: []string{"PLEG is not healthy\nnothing to see here\nPLEG is not healthy\n"},
+			want:   []string{"kubelet/pleg_unhealthy", "kubelet/pleg_unhealthy"},
+		},
+		{
+			name:   "incomplete line",
+			writes: []string{"PLEG is not healthy"},
+		},
+		{
+			name:   "line too long",
+			writes: []string{strings.Repeat("x", maxLineLength), "PLEG is not healthy\n", "PLEG is not healthy\n"},
+			want:   []string{"kubelet/pleg_unhealthy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := &recorder{}
+			w := NewWriter(out, r)
+			for _, s := range tt.writes {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := out.String(); got != strings.Join(tt.writes, "") {
+				t.Errorf("Write() output = %q, want %q", got, strings.Join(tt.writes, ""))
+			}
+			if !reflect.DeepEqual(r.events, tt.want) {
+				t.Errorf("Write() recorded events = %v, want %v", r.events, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package logmetrics
+
+import (
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var logEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: version.Program + "_component_log_events_total",
+	Help: "Total number of known warnings and errors logged by embedded components, labeled by component and event.",
+}, []string{"component", "event"})
+
+// MustRegister registers log event metrics
+func MustRegister(registerer prometheus.Registerer) {
+	registerer.MustRegister(logEvents)
+}
+
+// PrometheusBackend records events in the log events counter exposed on the supervisor metrics endpoint.
+type PrometheusBackend struct{}
+
+func (PrometheusBackend) Record(component, event string) {
+	logEvents.WithLabelValues(component, event).Inc()
+}
@@ -3,11 +3,15 @@ package metrics
 import (
 	"context"
 	"errors"
+	"net/http/httputil"
+	"net/url"
 
 	"github.com/k3s-io/k3s/pkg/agent/https"
 	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
+	"github.com/k3s-io/k3s/pkg/authlockout"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/etcd/snapshotmetrics"
+	"github.com/k3s-io/k3s/pkg/logmetrics"
 	"github.com/k3s-io/k3s/pkg/util/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	lassometrics "github.com/rancher/lasso/pkg/metrics"
@@ -41,12 +45,25 @@ func init() {
 	snapshotmetrics.MustRegister(DefaultRegisterer)
 	// and remotedialer metrics
 	rdmetrics.MustRegister(DefaultRegisterer)
+	// and embedded component log event metrics
+	logmetrics.MustRegister(DefaultRegisterer)
+	// and supervisor authentication failure metrics
+	authlockout.MustRegister(DefaultRegisterer)
 }
 
 // Config holds fields for the metrics listener
 type Config struct {
 	// Router will be called to add the metrics API handler to an existing router.
 	Router https.RouterFunc
+	// EtcdMetricsURL is the URL of the embedded etcd metrics endpoint. If set, etcd metrics
+	// are proxied at /metrics/etcd, so that they can be scraped without exposing the etcd
+	// metrics port.
+	EtcdMetricsURL string
+}
+
+// Enabled returns true if any metrics are to be served on the supervisor port.
+func (c *Config) Enabled(nodeConfig *config.Node) bool {
+	return nodeConfig.SupervisorMetrics || c.EtcdMetricsURL != ""
 }
 
 // Start starts binds the metrics API to an existing HTTP router.
@@ -55,6 +72,30 @@ func (c *Config) Start(ctx context.Context, nodeConfig *config.Node) error {
 	if err != nil {
 		return err
 	}
-	mRouter.Handle("/metrics", promhttp.HandlerFor(DefaultGatherer, promhttp.HandlerOpts{}))
+	if nodeConfig.SupervisorMetrics {
+		mRouter.Handle("/metrics", promhttp.HandlerFor(DefaultGatherer, promhttp.HandlerOpts{}))
+	}
+	if c.EtcdMetricsURL != "" {
+		u, err := url.Parse(c.EtcdMetricsURL)
+		if err != nil {
+			return err
+		}
+		mRouter.Handle("/metrics/etcd", etcdMetricsProxy(u))
+	}
 	return nil
 }
+
+// etcdMetricsProxy returns a reverse proxy to the etcd metrics endpoint. Credentials used to
+// authenticate to the supervisor are not passed through to etcd.
+func etcdMetricsProxy(u *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = u.Scheme
+			r.Out.URL.Host = u.Host
+			r.Out.URL.Path = u.Path
+			r.Out.URL.RawPath = ""
+			r.Out.Host = ""
+			r.Out.Header.Del("Authorization")
+		},
+	}
+}
@@ -3,7 +3,9 @@ package node
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net"
+	"slices"
 	"sort"
 	"strings"
 
@@ -17,8 +19,30 @@ import (
 	toolscache "k8s.io/client-go/tools/cache"
 )
 
+// hostOverrideMarker is the comment added to NodeHosts lines for host overrides, so that they can be
+// distinguished from node entries and replaced when the configured overrides change.
+const hostOverrideMarker = "host-override"
+
+// ParseHostOverrides parses host overrides in the format <hostname>=<ip> into a map of IP addresses
+// to hostnames. Host overrides are added to the coredns NodeHosts, so that the names resolve to the
+// given addresses for all pods using cluster DNS.
+func ParseHostOverrides(overrides []string) (map[string][]string, error) {
+	hosts := map[string][]string{}
+	for _, override := range overrides {
+		hostName, ip, ok := strings.Cut(override, "=")
+		if !ok || hostName == "" || strings.ContainsAny(hostName, " \t#") || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid host override %q; must be in the format <hostname>=<ip>", override)
+		}
+		if !slices.Contains(hosts[ip], hostName) {
+			hosts[ip] = append(hosts[ip], hostName)
+		}
+	}
+	return hosts, nil
+}
+
 func Register(ctx context.Context,
 	modCoreDNS bool,
+	hostOverrides map[string][]string,
 	coreClient kubernetes.Interface,
 	nodes coreclient.NodeController,
 ) error {
@@ -30,6 +54,7 @@ func Register(ctx context.Context,
 
 	h := &handler{
 		modCoreDNS:      modCoreDNS,
+		hostOverrides:   hostOverrideLines(hostOverrides),
 		ctx:             ctx,
 		configMaps:      coreClient.CoreV1().ConfigMaps(metav1.NamespaceSystem),
 		configMapsStore: indexer,
@@ -42,6 +67,7 @@ func Register(ctx context.Context,
 
 type handler struct {
 	modCoreDNS      bool
+	hostOverrides   []string
 	ctx             context.Context
 	configMaps      typedcorev1.ConfigMapInterface
 	configMapsStore toolscache.Store
@@ -100,11 +126,18 @@ func (h *handler) updateCoreDNSConfigMap(nodeName, hostName, nodeIPv4, nodeIPv6
 	}
 
 	addressMap := map[string]string{}
+	var hostOverrides []string
 
 	// extract current entries from hosts file, skipping any entries that are
 	// empty, unparsable, or hold an incorrect address for the current node.
+	// Host overrides are collected separately, as they are always replaced
+	// with the currently configured overrides.
 	for _, line := range strings.Split(configMap.Data["NodeHosts"], "\n") {
-		line, _, _ = strings.Cut(line, "#")
+		line, comment, _ := strings.Cut(line, "#")
+		if strings.TrimSpace(comment) == hostOverrideMarker {
+			hostOverrides = append(hostOverrides, strings.TrimSpace(line))
+			continue
+		}
 		if line == "" {
 			continue
 		}
@@ -138,8 +171,8 @@ func (h *handler) updateCoreDNSConfigMap(nodeName, hostName, nodeIPv4, nodeIPv6
 		namesv6 = nodeNames
 	}
 
-	// don't need to do anything if the addresses are in sync
-	if !removed && addressMap[nodeIPv4] == namesv4 && addressMap[nodeIPv6] == namesv6 {
+	// don't need to do anything if the addresses and host overrides are in sync
+	if !removed && addressMap[nodeIPv4] == namesv4 && addressMap[nodeIPv6] == namesv6 && slices.Equal(hostOverrides, h.hostOverrides) {
 		return nil
 	}
 
@@ -165,6 +198,9 @@ func (h *handler) updateCoreDNSConfigMap(nodeName, hostName, nodeIPv4, nodeIPv6
 	for _, ip := range addresses {
 		newHosts += ip + " " + addressMap[ip] + "\n"
 	}
+	for _, line := range h.hostOverrides {
+		newHosts += line + " # " + hostOverrideMarker + "\n"
+	}
 
 	if configMap.Data == nil {
 		configMap.Data = map[string]string{}
@@ -184,3 +220,19 @@ func (h *handler) updateCoreDNSConfigMap(nodeName, hostName, nodeIPv4, nodeIPv6
 	logrus.Infof("%s coredns NodeHosts entries for %s", actionType, nodeName)
 	return nil
 }
+
+// hostOverrideLines returns hosts file lines for the host overrides, sorted by IP.
+func hostOverrideLines(hostOverrides map[string][]string) []string {
+	addresses := make([]string, 0, len(hostOverrides))
+	for ip := range hostOverrides {
+		addresses = append(addresses, ip)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(addresses[i]), net.ParseIP(addresses[j])) < 0
+	})
+	lines := make([]string, 0, len(addresses))
+	for _, ip := range addresses {
+		lines = append(lines, ip+" "+strings.Join(hostOverrides[ip], " "))
+	}
+	return lines
+}
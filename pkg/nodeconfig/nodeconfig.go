@@ -21,6 +21,7 @@ var (
 	NodeEnvAnnotation        = version.Program + ".io/node-env"
 	NodeConfigHashAnnotation = version.Program + ".io/node-config-hash"
 	ClusterEgressLabel       = "egress." + version.Program + ".io/cluster"
+	WarmStandbyNodeLabel     = "node." + version.Program + ".io/warm-standby"
 )
 
 const (
@@ -121,6 +122,13 @@ func SetNodeConfigLabels(nodeConfig *config.Node, patch *util.PatchList, node *c
 			patch.Remove("metadata", "labels", ClusterEgressLabel)
 		}
 	}
+
+	_, hasLabel = node.Labels[WarmStandbyNodeLabel]
+	if nodeConfig.AgentConfig.WarmStandby && !hasLabel {
+		patch.Add("true", "metadata", "labels", WarmStandbyNodeLabel)
+	} else if !nodeConfig.AgentConfig.WarmStandby && hasLabel {
+		patch.Remove("metadata", "labels", WarmStandbyNodeLabel)
+	}
 	return nil
 }
 
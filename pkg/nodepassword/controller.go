@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/k3s-io/k3s/pkg/secretsencrypt"
 	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
@@ -32,7 +31,7 @@ var MinOrphanSecretAge = 10 * time.Minute
 // so that the node password validator can share its caches.
 var controller *nodePasswordController
 
-func Register(ctx context.Context, coreClient kubernetes.Interface, secrets coreclient.SecretController, nodes coreclient.NodeController) error {
+func Register(ctx context.Context, coreClient kubernetes.Interface, secrets coreclient.SecretController, nodes coreclient.NodeController, listPageSize int64) error {
 	// start a cache that only watches only node-password secrets in the kube-system namespace
 	lw := toolscache.NewListWatchFromClient(coreClient.CoreV1().RESTClient(), "secrets", metav1.NamespaceSystem, fields.OneTermEqualSelector("type", string(SecretTypeNodePassword)))
 	indexer, informer := toolscache.NewIndexerInformer(lw, &corev1.Secret{}, 0, &toolscache.ResourceEventHandlerFuncs{}, toolscache.Indexers{})
@@ -40,6 +39,7 @@ func Register(ctx context.Context, coreClient kubernetes.Interface, secrets core
 		nodes:        nodes,
 		secrets:      secrets,
 		secretsStore: indexer,
+		listPageSize: listPageSize,
 	}
 
 	// migrate legacy secrets over to the new type. this must not be fatal, as
@@ -61,6 +61,7 @@ type nodePasswordController struct {
 	nodes        coreclient.NodeController
 	secrets      coreclient.SecretController
 	secretsStore toolscache.Store
+	listPageSize int64
 }
 
 // onChangeNode ensures that the node password secret has an OwnerRefence to its
@@ -99,6 +100,8 @@ func (npc *nodePasswordController) onChangeNode(key string, node *corev1.Node) (
 // do not have a corresponding node. Garbage collection should handle secrets
 // for nodes that were deleted, so this cleanup is mostly for nodes that
 // requested certificates but never successfully joined the cluster.
+// Secrets for nodes that are pending join approval are not cleaned up, and the age of
+// secrets for approved nodes is measured from the time they were approved.
 func (npc *nodePasswordController) sync(ctx context.Context) {
 	if !npc.nodes.Informer().HasSynced() {
 		return
@@ -110,7 +113,20 @@ func (npc *nodePasswordController) sync(ctx context.Context) {
 	}
 	for _, s := range npc.secretsStore.List() {
 		secret, ok := s.(*corev1.Secret)
-		if !ok || secret.CreationTimestamp.After(minCreateTime) || nodeSecretNames.Has(secret.Name) {
+		if !ok || nodeSecretNames.Has(secret.Name) {
+			continue
+		}
+		if secret.Annotations[JoinApprovalAnnotation] == JoinApprovalApproved && secret.Annotations[JoinApprovedAtAnnotation] == "" {
+			// Secrets approved by annotating them directly do not have an approval time; record
+			// the current time so that the node has time to register before the secret is cleaned up.
+			secret = secret.DeepCopy()
+			secret.Annotations[JoinApprovedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+			if _, err := npc.secrets.Update(secret); err != nil {
+				logrus.Errorf("Failed to record join approval time for node-password secret %s: %v", secret.Name, err)
+			}
+			continue
+		}
+		if !isOrphanable(secret, minCreateTime) {
 			continue
 		}
 		if err := npc.secrets.Delete(secret.Namespace, secret.Name, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &secret.UID}}); err != nil {
@@ -121,13 +137,26 @@ func (npc *nodePasswordController) sync(ctx context.Context) {
 	}
 }
 
+// isOrphanable returns true if a node-password secret without a corresponding node
+// is old enough to be cleaned up.
+func isOrphanable(secret *corev1.Secret, minCreateTime time.Time) bool {
+	switch secret.Annotations[JoinApprovalAnnotation] {
+	case JoinApprovalPending:
+		return false
+	case JoinApprovalApproved:
+		approvedAt, err := time.Parse(time.RFC3339, secret.Annotations[JoinApprovedAtAnnotation])
+		return err == nil && approvedAt.Before(minCreateTime)
+	}
+	return !secret.CreationTimestamp.After(minCreateTime)
+}
+
 // migrateSecrets recreates legacy node password secrets with the correct type
 func (npc *nodePasswordController) migrateSecrets(ctx context.Context) error {
 	secretSuffix := getSecretName("")
 	secretPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
 		return npc.secrets.List(metav1.NamespaceSystem, opts)
 	}))
-	secretPager.PageSize = secretsencrypt.SecretListPageSize
+	secretPager.PageSize = npc.listPageSize
 
 	return secretPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
 		secret, ok := obj.(*corev1.Secret)
@@ -3,7 +3,9 @@ package nodepassword
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/k3s-io/k3s/pkg/authenticator/hash"
 	"github.com/k3s-io/k3s/pkg/util"
@@ -21,6 +23,20 @@ var (
 	ErrVerifyFailed = errVerifyFailed()
 
 	SecretTypeNodePassword = v1.SecretType(version.Program + ".cattle.io/node-password")
+
+	// JoinApprovalAnnotation is set on the node-password secret of nodes that are waiting for, or have received,
+	// approval to join the cluster. Secrets without the annotation were created before join approval was enabled.
+	JoinApprovalAnnotation = version.Program + ".io/join-approval"
+	// JoinApprovedAtAnnotation records when a node was approved to join, so that the secret is not cleaned up
+	// as an orphan before the newly approved node has registered.
+	JoinApprovedAtAnnotation = version.Program + ".io/join-approved-at"
+
+	ErrJoinPending = errors.New("node join is pending approval")
+)
+
+const (
+	JoinApprovalPending  = "pending"
+	JoinApprovalApproved = "approved"
 )
 
 type passwordError struct {
@@ -60,8 +76,9 @@ func (npc *nodePasswordController) verifyHash(nodeName, pass string, cached bool
 	return &passwordError{node: nodeName, err: errors.New("password hash not found in node secret")}
 }
 
-// ensure will verify a node-password secret if it exists, otherwise it will create one
-func (npc *nodePasswordController) ensure(nodeName, pass string) error {
+// ensure will verify a node-password secret if it exists, otherwise it will create one.
+// If pending is true, new secrets are created with a pending join approval.
+func (npc *nodePasswordController) ensure(nodeName, pass string, pending bool) error {
 	err := npc.verifyHash(nodeName, pass, true)
 	if apierrors.IsNotFound(err) {
 		var hash string
@@ -69,10 +86,15 @@ func (npc *nodePasswordController) ensure(nodeName, pass string) error {
 		if err != nil {
 			return &passwordError{node: nodeName, err: err}
 		}
+		var annotations map[string]string
+		if pending {
+			annotations = map[string]string{JoinApprovalAnnotation: JoinApprovalPending}
+		}
 		_, err = npc.secrets.Create(&v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      getSecretName(nodeName),
-				Namespace: metav1.NamespaceSystem,
+				Name:        getSecretName(nodeName),
+				Namespace:   metav1.NamespaceSystem,
+				Annotations: annotations,
 			},
 			Immutable: ptr.To(true),
 			Data:      map[string][]byte{"hash": []byte(hash)},
@@ -86,6 +108,22 @@ func (npc *nodePasswordController) ensure(nodeName, pass string) error {
 	return err
 }
 
+// verifyApproved returns an error if the node's join approval is pending. Secrets that were just created may not
+// yet be in the cache, so the secret is retrieved from the apiserver if it is not found.
+func (npc *nodePasswordController) verifyApproved(nodeName string) error {
+	secret, err := npc.getSecret(nodeName, true)
+	if apierrors.IsNotFound(err) {
+		secret, err = npc.getSecret(nodeName, false)
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Annotations[JoinApprovalAnnotation] == JoinApprovalPending {
+		return ErrJoinPending
+	}
+	return nil
+}
+
 // verifyNode confirms that a node with the given name exists, to prevent auth
 // from succeeding with a client certificate for a node that has been deleted from the cluster.
 func (npc *nodePasswordController) verifyNode(ctx context.Context, node *nodeInfo) error {
@@ -97,6 +135,42 @@ func (npc *nodePasswordController) verifyNode(ctx context.Context, node *nodeInf
 	return nil
 }
 
+// Approve uses the controller to approve a node that is pending approval to join the cluster. It is not an error
+// to approve a node that has already been approved, or that joined before join approval was enabled.
+func Approve(nodeName string) error {
+	if controller == nil {
+		return util.ErrCoreNotReady
+	}
+	secret, err := controller.getSecret(nodeName, false)
+	if err != nil {
+		return err
+	}
+	if secret.Annotations[JoinApprovalAnnotation] != JoinApprovalPending {
+		return nil
+	}
+	secret = secret.DeepCopy()
+	secret.Annotations[JoinApprovalAnnotation] = JoinApprovalApproved
+	secret.Annotations[JoinApprovedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	_, err = controller.secrets.Update(secret)
+	return err
+}
+
+// Pending uses the controller to list the names of nodes that are pending approval to join the cluster.
+func Pending() ([]string, error) {
+	if controller == nil {
+		return nil, util.ErrCoreNotReady
+	}
+	suffix := getSecretName("")
+	var names []string
+	for _, s := range controller.secretsStore.List() {
+		if secret, ok := s.(*v1.Secret); ok && secret.Annotations[JoinApprovalAnnotation] == JoinApprovalPending {
+			names = append(names, strings.TrimSuffix(secret.Name, suffix))
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
 // Delete uses the controller to delete the secret for a node, if the controller has been started
 func Delete(nodeName string) error {
 	if controller == nil {
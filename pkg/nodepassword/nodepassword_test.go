@@ -7,8 +7,11 @@ import (
 	"os"
 	"runtime"
 	"testing"
+	"time"
 
+	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -29,6 +32,61 @@ func Test_PasswordError(t *testing.T) {
 	assertNotEqual(t, errors.Unwrap(err), nil)
 }
 
+func Test_UnitIsOrphanable(t *testing.T) {
+	now := time.Now()
+	minCreateTime := now.Add(-MinOrphanSecretAge)
+	old := metav1.NewTime(now.Add(-time.Hour))
+	tests := []struct {
+		name        string
+		created     metav1.Time
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name:    "new secret",
+			created: metav1.NewTime(now),
+		},
+		{
+			name:    "old secret",
+			created: old,
+			want:    true,
+		},
+		{
+			name:        "old pending secret",
+			created:     old,
+			annotations: map[string]string{JoinApprovalAnnotation: JoinApprovalPending},
+		},
+		{
+			name:    "old secret recently approved",
+			created: old,
+			annotations: map[string]string{
+				JoinApprovalAnnotation:   JoinApprovalApproved,
+				JoinApprovedAtAnnotation: now.UTC().Format(time.RFC3339),
+			},
+		},
+		{
+			name:    "old secret approved long ago",
+			created: old,
+			annotations: map[string]string{
+				JoinApprovalAnnotation:   JoinApprovalApproved,
+				JoinApprovedAtAnnotation: old.UTC().Format(time.RFC3339),
+			},
+			want: true,
+		},
+		{
+			name:        "old secret approved without approval time",
+			created:     old,
+			annotations: map[string]string{JoinApprovalAnnotation: JoinApprovalApproved},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: tt.created, Annotations: tt.annotations}}
+			assertEqual(t, isOrphanable(secret, minCreateTime), tt.want)
+		})
+	}
+}
+
 // --------------------------
 // utility functions
 
@@ -0,0 +1,70 @@
+package nodepassword
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/tpm"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var (
+	// TPMEKAnnotation records the public TPM endorsement key of the node, for use by operators identifying the node's TPM.
+	// The endorsement key is reported by the node, and is not verified.
+	TPMEKAnnotation = version.Program + ".io/tpm-ek"
+	// TPMKeyAnnotation records the public TPM signing key of the node. Once it has been recorded, requests for the
+	// node must be signed with the same key. The annotation can be removed to allow the node to record a new key,
+	// for example after the node's TPM has been cleared or replaced.
+	TPMKeyAnnotation = version.Program + ".io/tpm-key"
+
+	ErrTPMKeyRequired = errors.New("TPM key proof is required")
+	ErrTPMKeyMismatch = errors.New("TPM key does not match the key pinned for the node")
+)
+
+// verifyTPMKey verifies the node's TPM key proof, if any, against the key pinned in the node-password secret.
+// The key is pinned on first use; as the key is not attested, the first request for a node is trusted to come
+// from the node's TPM. If the node has a pinned key, or a key is required, requests without a valid proof are rejected.
+func (npc *nodePasswordController) verifyTPMKey(node *nodeInfo, required bool) (int, error) {
+	secret, err := npc.getSecret(node.Name, true)
+	if apierrors.IsNotFound(err) {
+		secret, err = npc.getSecret(node.Name, false)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	pinnedKey := secret.Annotations[TPMKeyAnnotation]
+	if node.TPMKeyProof == nil {
+		if pinnedKey != "" || required {
+			return http.StatusForbidden, errors.WithMessagef(ErrTPMKeyRequired, "unable to verify node %s", node.Name)
+		}
+		return http.StatusOK, nil
+	}
+
+	if err := node.TPMKeyProof.Verify(node.Name, node.Body, time.Now()); err != nil {
+		return http.StatusForbidden, errors.WithMessagef(err, "unable to verify node %s", node.Name)
+	}
+
+	key := base64.StdEncoding.EncodeToString(node.TPMKeyProof.Key)
+	if pinnedKey == key {
+		return http.StatusOK, nil
+	} else if pinnedKey != "" {
+		return http.StatusForbidden, errors.WithMessagef(ErrTPMKeyMismatch, "unable to verify node %s", node.Name)
+	}
+
+	secret = secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[TPMEKAnnotation] = base64.StdEncoding.EncodeToString(node.TPMKeyProof.EK)
+	secret.Annotations[TPMKeyAnnotation] = key
+	if _, err := npc.secrets.Update(secret); err != nil {
+		return http.StatusInternalServerError, errors.WithMessagef(err, "failed to pin TPM key for node %s", node.Name)
+	}
+	logrus.Infof("Pinned TPM key %s for node %s", tpm.Fingerprint(node.TPMKeyProof.Key), node.Name)
+	return http.StatusOK, nil
+}
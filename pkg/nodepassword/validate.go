@@ -1,7 +1,9 @@
 package nodepassword
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -10,7 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/authlockout"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/tpm"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
@@ -31,9 +35,11 @@ type NodeAuthValidator func(req *http.Request) (string, int, error)
 // nodeInfo contains information on the requesting node, derived from auth creds
 // and request headers.
 type nodeInfo struct {
-	Name     string
-	Password string
-	User     user.Info
+	Name        string
+	Password    string
+	User        user.Info
+	TPMKeyProof *tpm.KeyProof
+	Body        []byte
 }
 
 // GetNodeAuthValidator returns a function that will be called to validate node password authentication.
@@ -45,7 +51,7 @@ func GetNodeAuthValidator(ctx context.Context, control *config.Control) NodeAuth
 	deferredNodes := map[string]bool{}
 	var mu sync.Mutex
 
-	return func(req *http.Request) (string, int, error) {
+	validate := func(req *http.Request) (string, int, error) {
 		node, err := getNodeInfo(req)
 		if err != nil {
 			return "", http.StatusBadRequest, err
@@ -63,6 +69,14 @@ func GetNodeAuthValidator(ctx context.Context, control *config.Control) NodeAuth
 				// If we're verifying our own password, verify it locally and ensure a secret later.
 				return verifyLocalPassword(ctx, control, &mu, deferredNodes, node)
 			} else if control.DisableAPIServer && !isNodeAuth {
+				// If join approval or TPM key pinning is required, new nodes cannot be allowed to join until their
+				// approval or pinned key can be checked, so reject the request until an apiserver is available.
+				if control.NodeJoinApproval {
+					return "", http.StatusServiceUnavailable, errors.WithMessage(util.ErrCoreNotReady, "unable to verify node join approval")
+				}
+				if control.RequireTPMKeyPinning {
+					return "", http.StatusServiceUnavailable, errors.WithMessage(util.ErrCoreNotReady, "unable to verify node TPM key")
+				}
 				// If we're running on an etcd-only node, and the request didn't use Node Identity auth,
 				// defer node password verification until an apiserver joins the cluster.
 				return verifyRemotePassword(ctx, control, &mu, deferredNodes, node)
@@ -76,8 +90,13 @@ func GetNodeAuthValidator(ctx context.Context, control *config.Control) NodeAuth
 			return "", http.StatusUnauthorized, err
 		}
 
+		// Hold new nodes until they are approved, if join approval is enabled. Nodes using
+		// Node Identity auth have already joined the cluster, and the local node is always allowed.
+		requireApproval := control.NodeJoinApproval && !isNodeAuth && node.Name != os.Getenv("NODE_NAME")
+		requireTPMKey := control.RequireTPMKeyPinning && node.Name != os.Getenv("NODE_NAME")
+
 		// verify that the node password secret matches, or create it if it does not
-		if err := controller.ensure(node.Name, node.Password); err != nil {
+		if err := controller.ensure(node.Name, node.Password, requireApproval); err != nil {
 			// if the verification failed, reject the request
 			if errors.Is(err, ErrVerifyFailed) {
 				return "", http.StatusForbidden, err
@@ -88,12 +107,44 @@ func GetNodeAuthValidator(ctx context.Context, control *config.Control) NodeAuth
 			// blocking secret creation - if the outage requires new nodes to join in order to
 			// run the webhook pods, we must fail open here to resolve the outage.
 			// ref: github.com/k3s-io/k3s/issues/7654
+			// Nodes that require join approval or a TPM key cannot be allowed without the secret that records
+			// their approval or pinned key, so the request is rejected until the secret can be created.
 			logrus.Warnf("Failed to ensure node-password secret for node %s: %v", node.Name, err)
+			if requireApproval {
+				return "", http.StatusServiceUnavailable, errors.WithMessage(err, "unable to verify node join approval")
+			}
+			if requireTPMKey {
+				return "", http.StatusServiceUnavailable, errors.WithMessage(err, "unable to verify node TPM key")
+			}
 			return verifyRemotePassword(ctx, control, &mu, deferredNodes, node)
 		}
 
+		// verify the node's TPM key proof. The local node is not required to use a TPM key.
+		if code, err := controller.verifyTPMKey(node, requireTPMKey); err != nil {
+			return "", code, err
+		}
+
+		if requireApproval {
+			if err := controller.verifyApproved(node.Name); err != nil {
+				if errors.Is(err, ErrJoinPending) {
+					logrus.Infof("Node %s is pending approval to join the cluster", node.Name)
+					return "", http.StatusForbidden, errors.WithMessagef(err, "run '%s node approve %s' on a server to allow the node to join", version.Program, node.Name)
+				}
+				return "", http.StatusInternalServerError, err
+			}
+		}
+
 		return node.Name, http.StatusOK, nil
 	}
+
+	// record failed node password verification, so that clients guessing node passwords are locked out
+	return func(req *http.Request) (string, int, error) {
+		nodeName, code, err := validate(req)
+		if errors.Is(err, ErrVerifyFailed) {
+			control.Runtime.AuthLockout.Failure(req, authlockout.ReasonNodePassword)
+		}
+		return nodeName, code, err
+	}
 }
 
 // getNodeInfo returns node name, password, and user extracted
@@ -115,11 +166,29 @@ func getNodeInfo(req *http.Request) (*nodeInfo, error) {
 		return nil, errors.New("node password not set")
 	}
 
-	return &nodeInfo{
+	info := &nodeInfo{
 		Name:     strings.ToLower(nodeName),
 		Password: nodePassword,
 		User:     user,
-	}, nil
+	}
+
+	// The key proof is bound to the request body, so the body is read for verification
+	// and then replaced so that it can be read again by the handler.
+	if value := req.Header.Get(tpm.HeaderName); value != "" {
+		proof, err := tpm.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		info.TPMKeyProof = proof
+		info.Body = body
+	}
+
+	return info, nil
 }
 
 // verifyLocalPassword is used to validate the local node's password secret directly against the node password file, when the apiserver is unavailable.
@@ -182,7 +251,10 @@ func verifyRemotePassword(ctx context.Context, control *config.Control, mu *sync
 
 // ensureSecret validates a server's node password secret once the apiserver is up.
 // As the node has already joined the cluster at this point, this is purely informational.
+// If join approval is enabled, secrets created for nodes other than the local node are still created
+// with a pending approval, so that deferred verification never implicitly approves a node.
 func ensureSecret(ctx context.Context, control *config.Control, node *nodeInfo) {
+	pending := control.NodeJoinApproval && node.Name != os.Getenv("NODE_NAME")
 	_ = wait.PollImmediateUntilWithContext(ctx, time.Second*5, func(ctx context.Context) (bool, error) {
 		if controller != nil {
 			// This is consistent with events attached to the node generated by the kubelet
@@ -193,7 +265,7 @@ func ensureSecret(ctx context.Context, control *config.Control, node *nodeInfo)
 				UID:       types.UID(node.Name),
 				Namespace: "",
 			}
-			if err := controller.ensure(node.Name, node.Password); err != nil {
+			if err := controller.ensure(node.Name, node.Password, pending); err != nil {
 				control.Runtime.Event.Eventf(nodeRef, corev1.EventTypeWarning, "NodePasswordValidationFailed", "Deferred node password secret validation failed: %v", err)
 				// Return true to stop polling if the password verification failed; only retry on secret creation errors.
 				return errors.Is(err, ErrVerifyFailed), nil
@@ -0,0 +1,55 @@
+package nodepassword
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func Test_UnitEtcdOnlyJoinApproval(t *testing.T) {
+	tests := []struct {
+		name             string
+		nodeJoinApproval bool
+		requireTPMKey    bool
+		wantCode         int
+	}{
+		{
+			name:     "deferred without join approval",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:             "rejected with join approval",
+			nodeJoinApproval: true,
+			wantCode:         http.StatusServiceUnavailable,
+		},
+		{
+			name:          "rejected with required TPM key",
+			requireTPMKey: true,
+			wantCode:      http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := &config.Control{
+				DisableAPIServer:     true,
+				NodeJoinApproval:     tt.nodeJoinApproval,
+				RequireTPMKeyPinning: tt.requireTPMKey,
+				Runtime:              config.NewRuntime(),
+			}
+			req := httptest.NewRequest(http.MethodGet, "/v1-"+version.Program+"/serving-kubelet.crt", nil)
+			req.Header.Set(version.Program+"-Node-Name", "agent-1")
+			req.Header.Set(version.Program+"-Node-Password", "password")
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "node"}))
+
+			_, code, err := GetNodeAuthValidator(t.Context(), control)(req)
+			if code != tt.wantCode {
+				t.Errorf("validator code = %d, want %d, err = %v", code, tt.wantCode, err)
+			}
+		})
+	}
+}
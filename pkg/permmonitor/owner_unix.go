@@ -0,0 +1,16 @@
+//go:build !windows
+
+package permmonitor
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the owner and group IDs of the file.
+func fileOwner(fi fs.FileInfo) (int, int) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
@@ -0,0 +1,250 @@
+package permmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// ActionWarn reports permission drift without changing any files.
+	ActionWarn = "warn"
+	// ActionFix reports permission drift, and restores the expected permissions and ownership.
+	ActionFix = "fix"
+)
+
+var controllerName = version.Program + "-permissions-monitor"
+
+// auditDirs are the directories under the server data-dir that are audited, recursively.
+var auditDirs = []string{"tls", "cred", "etc"}
+
+// Finding describes a file or directory whose permissions are less restrictive than expected,
+// or whose ownership does not match the expected owner. A WantUID or WantGID of -1 indicates
+// that the owner or group is not checked.
+type Finding struct {
+	Path     string      `json:"path"`
+	Mode     fs.FileMode `json:"mode"`
+	WantMode fs.FileMode `json:"wantMode"`
+	UID      int         `json:"uid"`
+	GID      int         `json:"gid"`
+	WantUID  int         `json:"wantUID"`
+	WantGID  int         `json:"wantGID"`
+}
+
+func (f Finding) String() string {
+	var problems []string
+	if f.Mode&^f.WantMode != 0 {
+		problems = append(problems, fmt.Sprintf("mode %04o should be %04o or more restrictive", f.Mode, f.WantMode))
+	}
+	if f.WantUID >= 0 && f.UID != f.WantUID {
+		problems = append(problems, fmt.Sprintf("owner %d should be %d", f.UID, f.WantUID))
+	}
+	if f.WantGID >= 0 && f.GID != f.WantGID {
+		problems = append(problems, fmt.Sprintf("group %d should be %d", f.GID, f.WantGID))
+	}
+	return f.Path + ": " + strings.Join(problems, ", ")
+}
+
+// expectation is the most permissive mode, and the required group, for a path.
+type expectation struct {
+	mode fs.FileMode
+	gid  int
+}
+
+// Audit checks the permissions and ownership of the server data-dir, and the tls, cred, and etc
+// directories under it, against CIS benchmark expectations: directories must be 0700, private keys
+// and credentials 0600, and certificates 0644 or more restrictive. When running as root, all files
+// must also be owned by root. Component kubeconfigs, client certificates, and keys are instead
+// expected to have the mode and group set by --write-kubeconfig-components, if enabled.
+func Audit(config *daemonconfig.Control) ([]Finding, error) {
+	wantUID, wantGID := -1, -1
+	if os.Geteuid() == 0 && !config.Rootless {
+		wantUID, wantGID = 0, 0
+	}
+
+	overrides, err := componentExpectations(config, wantGID)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	check := func(path string, fi fs.FileInfo, want expectation) {
+		if o, ok := overrides[path]; ok {
+			want = o
+		}
+		uid, gid := fileOwner(fi)
+		f := Finding{Path: path, Mode: fi.Mode().Perm(), WantMode: want.mode, UID: uid, GID: gid, WantUID: wantUID, WantGID: want.gid}
+		if uid < 0 {
+			// ownership is not available on this platform
+			f.WantUID, f.WantGID = -1, -1
+		}
+		if f.Mode&^f.WantMode != 0 || (f.WantUID >= 0 && f.UID != f.WantUID) || (f.WantGID >= 0 && f.GID != f.WantGID) {
+			findings = append(findings, f)
+		}
+	}
+
+	fi, err := os.Stat(config.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	check(config.DataDir, fi, expectation{mode: 0700, gid: wantGID})
+
+	for _, dir := range auditDirs {
+		root := filepath.Join(config.DataDir, dir)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			check(path, fi, expectation{mode: expectedMode(dir, path, d.IsDir()), gid: wantGID})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
+}
+
+// expectedMode returns the most permissive mode allowed for a path under the given audited directory.
+func expectedMode(dir, path string, isDir bool) fs.FileMode {
+	switch {
+	case isDir:
+		return 0700
+	case dir == "tls" && !strings.HasSuffix(path, ".key"):
+		return 0644
+	default:
+		return 0600
+	}
+}
+
+// componentExpectations returns the expected mode and group of the component kubeconfigs, client
+// certificates, and keys, and the directories containing them, as configured by --write-kubeconfig-components.
+func componentExpectations(config *daemonconfig.Control, defaultGID int) (map[string]expectation, error) {
+	if !config.KubeConfigComponents {
+		return nil, nil
+	}
+	mode, group, err := deps.ComponentKubeConfigPermissions(config)
+	if err != nil {
+		return nil, err
+	}
+	gid := defaultGID
+	if group != "" {
+		if gid, err = util.GroupID(group); err != nil {
+			return nil, fmt.Errorf("failed to look up write-kubeconfig-group %s: %w", group, err)
+		}
+	}
+
+	expectations := map[string]expectation{}
+	for _, file := range deps.ComponentKubeConfigFiles(config) {
+		expectations[file] = expectation{mode: mode, gid: gid}
+	}
+	if group != "" {
+		for _, dir := range deps.ComponentKubeConfigDirs(config) {
+			expectations[dir] = expectation{mode: 0710, gid: gid}
+		}
+	}
+	return expectations, nil
+}
+
+// Fix restores the expected permissions and ownership for the given findings.
+// Permissions are only ever removed; no permission bits are added.
+func Fix(findings []Finding) error {
+	var errs []error
+	for _, f := range findings {
+		if f.Mode&^f.WantMode != 0 {
+			if err := os.Chmod(f.Path, f.Mode&f.WantMode); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		uid, gid := -1, -1
+		if f.WantUID >= 0 && f.UID != f.WantUID {
+			uid = f.WantUID
+		}
+		if f.WantGID >= 0 && f.GID != f.WantGID {
+			gid = f.WantGID
+		}
+		if uid >= 0 || gid >= 0 {
+			if err := os.Chown(f.Path, uid, gid); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Setup starts the periodic permissions audit, if enabled. Drift is reported in the log and as
+// events attached to the Node resource, and fixed if the audit action is set to fix.
+func Setup(ctx context.Context, config *daemonconfig.Control) {
+	interval := config.PermissionsAuditInterval.Duration
+	if interval <= 0 {
+		return
+	}
+	logrus.Debugf("Starting %s with audit period %s", controllerName, interval)
+
+	go wait.Until(func() {
+		findings, err := Audit(config)
+		if err != nil {
+			logrus.Errorf("Failed to audit permissions under %s: %v", config.DataDir, err)
+			return
+		}
+		if len(findings) == 0 {
+			logrus.Debugf("Permissions under %s match CIS expectations", config.DataDir)
+			return
+		}
+		for _, f := range findings {
+			logrus.Warnf("Permissions drift detected: %s", f)
+		}
+
+		reason, message := "PermissionsDrift", fmt.Sprintf("Permissions or ownership of %d files under %s do not match CIS expectations; run '%s check cis' for details", len(findings), config.DataDir, version.Program)
+		if config.PermissionsAuditAction == ActionFix {
+			if err := Fix(findings); err != nil {
+				logrus.Errorf("Failed to fix permissions drift: %v", err)
+			} else {
+				reason, message = "PermissionsDriftFixed", fmt.Sprintf("Restored permissions and ownership of %d files under %s to match CIS expectations", len(findings), config.DataDir)
+			}
+		}
+		warningEvent(config, reason, message)
+	}, interval, ctx.Done())
+}
+
+// warningEvent emits a warning Event attached to the Node resource,
+// or directly logs a warning if the event recorder is not available.
+func warningEvent(config *daemonconfig.Control, reason, message string) {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName != "" && config.Runtime.Event != nil {
+		nodeRef := &corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			UID:  types.UID(nodeName),
+		}
+		config.Runtime.Event.Event(nodeRef, corev1.EventTypeWarning, reason, message)
+	} else {
+		logrus.Warn(message)
+	}
+}
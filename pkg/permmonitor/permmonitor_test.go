@@ -0,0 +1,132 @@
+//go:build !windows
+
+package permmonitor
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+)
+
+func Test_UnitAudit(t *testing.T) {
+	tests := []struct {
+		name       string
+		components bool
+		modes      map[string]fs.FileMode
+		want       map[string]fs.FileMode
+	}{
+		{
+			name: "Expected permissions",
+			modes: map[string]fs.FileMode{
+				"tls/":                       0700,
+				"tls/server-ca.crt":          0644,
+				"tls/server-ca.key":          0600,
+				"tls/client-admin.crt":       0600,
+				"cred/":                      0700,
+				"cred/admin.kubeconfig":      0600,
+				"etc/":                       0700,
+				"etc/cloud-config.yaml":      0400,
+				"tls/temporary-certs/":       0700,
+				"tls/temporary-certs/ca.crt": 0600,
+			},
+			want: map[string]fs.FileMode{},
+		},
+		{
+			name: "Permissive files and directories",
+			modes: map[string]fs.FileMode{
+				"tls/":                  0755,
+				"tls/server-ca.crt":     0664,
+				"tls/server-ca.key":     0640,
+				"cred/":                 0700,
+				"cred/admin.kubeconfig": 0644,
+				"etc/":                  0711,
+				"etc/cloud-config.yaml": 0600,
+			},
+			want: map[string]fs.FileMode{
+				"tls/":                  0700,
+				"tls/server-ca.crt":     0644,
+				"tls/server-ca.key":     0600,
+				"cred/admin.kubeconfig": 0600,
+				"etc/":                  0700,
+			},
+		},
+		{
+			name:       "Component kubeconfigs with mode",
+			components: true,
+			modes: map[string]fs.FileMode{
+				"tls/":                  0700,
+				"tls/client-admin.crt":  0644,
+				"tls/client-admin.key":  0644,
+				"tls/server-ca.key":     0644,
+				"cred/":                 0700,
+				"cred/admin.kubeconfig": 0644,
+				"cred/other.kubeconfig": 0644,
+			},
+			want: map[string]fs.FileMode{
+				"tls/server-ca.key":     0600,
+				"cred/other.kubeconfig": 0600,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if err := os.Chmod(dataDir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			controlConfig := &config.Control{
+				DataDir:              dataDir,
+				KubeConfigMode:       "0644",
+				KubeConfigComponents: tt.components,
+				Runtime:              config.NewRuntime(),
+			}
+			deps.CreateRuntimeCertFiles(controlConfig)
+
+			// directories are suffixed with a slash, and sorted before the files within them
+			for _, name := range slices.Sorted(maps.Keys(tt.modes)) {
+				path := filepath.Join(dataDir, name)
+				if strings.HasSuffix(name, "/") {
+					if err := os.MkdirAll(path, 0700); err != nil {
+						t.Fatal(err)
+					}
+				} else if err := os.WriteFile(path, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, tt.modes[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			findings, err := Audit(controlConfig)
+			if err != nil {
+				t.Fatalf("Audit() error = %v", err)
+			}
+			got := map[string]fs.FileMode{}
+			for _, f := range findings {
+				rel, _ := filepath.Rel(dataDir, f.Path)
+				if fi, err := os.Stat(f.Path); err == nil && fi.IsDir() {
+					rel += "/"
+				}
+				got[rel] = f.WantMode
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Audit() = %v, want %v", got, tt.want)
+			}
+
+			if err := Fix(findings); err != nil {
+				t.Fatalf("Fix() error = %v", err)
+			}
+			if findings, err := Audit(controlConfig); err != nil || len(findings) != 0 {
+				t.Errorf("Audit() after Fix() = %v, %v, want no findings", findings, err)
+			}
+		})
+	}
+}
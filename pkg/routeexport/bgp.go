@@ -0,0 +1,473 @@
+package routeexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	utilsnet "k8s.io/utils/net"
+)
+
+const (
+	// SchemeBGP is the route export target URL scheme that announces routes to a BGP peer.
+	SchemeBGP = "bgp"
+
+	bgpPort         = "179"
+	bgpVersion      = 4
+	bgpHoldTime     = 90 * time.Second
+	bgpDialTimeout  = 10 * time.Second
+	bgpRetryBackoff = 10 * time.Second
+	bgpHeaderLen    = 19
+	bgpMaxMsgLen    = 4096
+	bgpASTrans      = 23456
+
+	bgpMsgOpen         = 1
+	bgpMsgUpdate       = 2
+	bgpMsgNotification = 3
+	bgpMsgKeepalive    = 4
+
+	bgpAttrFlagOptional   = 0x80
+	bgpAttrFlagTransitive = 0x40
+
+	bgpAttrOrigin      = 1
+	bgpAttrASPath      = 2
+	bgpAttrNextHop     = 3
+	bgpAttrLocalPref   = 5
+	bgpAttrMPReachNLRI = 14
+	bgpAttrMPUnreach   = 15
+
+	bgpCapMultiprotocol = 1
+	bgpCap4OctetAS      = 65
+
+	bgpAFIIPv4     = 1
+	bgpAFIIPv6     = 2
+	bgpSAFIUnicast = 1
+)
+
+// bgpExporter announces routes to a single BGP peer. The exporter acts as a minimal BGP speaker that
+// only originates routes: routes received from the peer are ignored. The session is established when
+// routes are first exported, and is re-established with all current routes if it fails.
+type bgpExporter struct {
+	peer     string
+	localAS  uint32
+	peerAS   uint32
+	routerID net.IP
+
+	mu      sync.Mutex
+	routes  []Route
+	started bool
+	updated chan struct{}
+}
+
+// newBGPExporter returns an exporter for a target of the form
+// bgp://<peer>[:port]?local-as=<asn>&peer-as=<asn>&router-id=<ipv4>
+// The peer AS defaults to the local AS, for an iBGP session.
+func newBGPExporter(u *url.URL) (*bgpExporter, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("invalid bgp route export target: peer address is required")
+	}
+	query := u.Query()
+	localAS, err := parseASN(query.Get("local-as"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid bgp route export target local-as")
+	}
+	peerAS := localAS
+	if query.Has("peer-as") {
+		if peerAS, err = parseASN(query.Get("peer-as")); err != nil {
+			return nil, errors.WithMessage(err, "invalid bgp route export target peer-as")
+		}
+	}
+	routerID := net.ParseIP(query.Get("router-id")).To4()
+	if routerID == nil {
+		return nil, errors.New("invalid bgp route export target router-id: must be an IPv4 address")
+	}
+	port := u.Port()
+	if port == "" {
+		port = bgpPort
+	}
+	return &bgpExporter{
+		peer:     net.JoinHostPort(u.Hostname(), port),
+		localAS:  localAS,
+		peerAS:   peerAS,
+		routerID: routerID,
+		updated:  make(chan struct{}, 1),
+	}, nil
+}
+
+// parseASN parses a 4-octet AS number.
+func parseASN(s string) (uint32, error) {
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || asn == 0 {
+		return 0, fmt.Errorf("%q is not a valid AS number", s)
+	}
+	return uint32(asn), nil
+}
+
+// Export records the routes to be announced, and starts the BGP session if it is not already running.
+// Routes are announced asynchronously; failures to connect to the peer are logged and retried.
+func (b *bgpExporter) Export(ctx context.Context, routes []Route) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.routes = routes
+	if !b.started {
+		b.started = true
+		go b.run(ctx)
+	}
+	select {
+	case b.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (b *bgpExporter) String() string {
+	return "BGP peer " + b.peer
+}
+
+// currentRoutes returns the routes most recently passed to Export.
+func (b *bgpExporter) currentRoutes() []Route {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.routes
+}
+
+// run maintains the BGP session until the context is cancelled.
+func (b *bgpExporter) run(ctx context.Context) {
+	for {
+		conn, err := (&net.Dialer{Timeout: bgpDialTimeout}).DialContext(ctx, "tcp", b.peer)
+		if err == nil {
+			err = b.session(ctx, conn)
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		logrus.Errorf("BGP session with %s failed: %v", b.peer, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bgpRetryBackoff):
+		}
+	}
+}
+
+// session establishes a BGP session over the given connection and announces routes until the
+// connection fails or the context is cancelled.
+func (b *bgpExporter) session(ctx context.Context, conn net.Conn) error {
+	if err := writeBGPMessage(conn, bgpMsgOpen, b.openMessage()); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(bgpHoldTime))
+	msgType, body, err := readBGPMessage(conn)
+	if err != nil {
+		return err
+	}
+	if msgType != bgpMsgOpen {
+		return unexpectedBGPMessage(msgType, body)
+	}
+	holdTime, as4, err := b.parseOpen(body)
+	if err != nil {
+		return err
+	}
+	if err := writeBGPMessage(conn, bgpMsgKeepalive, nil); err != nil {
+		return err
+	}
+	logrus.Infof("BGP session established with %s", b.peer)
+
+	// Read messages from the peer until the connection fails. Only KEEPALIVE and UPDATE messages are expected;
+	// received routes are ignored. Each message resets the hold timer.
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if holdTime > 0 {
+				conn.SetReadDeadline(time.Now().Add(holdTime))
+			} else {
+				conn.SetReadDeadline(time.Time{})
+			}
+			msgType, body, err := readBGPMessage(conn)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if msgType != bgpMsgKeepalive && msgType != bgpMsgUpdate {
+				readErr <- unexpectedBGPMessage(msgType, body)
+				return
+			}
+		}
+	}()
+
+	var keepalive <-chan time.Time
+	if holdTime > 0 {
+		ticker := time.NewTicker(holdTime / 3)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+
+	advertised := map[string]Route{}
+	announce := func() error {
+		for _, msg := range b.updateMessages(advertised, b.currentRoutes(), as4) {
+			if err := writeBGPMessage(conn, bgpMsgUpdate, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := announce(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Cease
+			writeBGPMessage(conn, bgpMsgNotification, []byte{6, 0})
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case <-keepalive:
+			if err := writeBGPMessage(conn, bgpMsgKeepalive, nil); err != nil {
+				return err
+			}
+		case <-b.updated:
+			if err := announce(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openMessage returns the body of the OPEN message, advertising support for IPv4 and IPv6
+// unicast routes and 4-octet AS numbers.
+func (b *bgpExporter) openMessage() []byte {
+	caps := []byte{
+		bgpCapMultiprotocol, 4, 0, bgpAFIIPv4, 0, bgpSAFIUnicast,
+		bgpCapMultiprotocol, 4, 0, bgpAFIIPv6, 0, bgpSAFIUnicast,
+		bgpCap4OctetAS, 4,
+	}
+	caps = binary.BigEndian.AppendUint32(caps, b.localAS)
+
+	myAS := uint16(bgpASTrans)
+	if b.localAS <= 0xffff {
+		myAS = uint16(b.localAS)
+	}
+	msg := []byte{bgpVersion}
+	msg = binary.BigEndian.AppendUint16(msg, myAS)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(bgpHoldTime/time.Second))
+	msg = append(msg, b.routerID...)
+	// a single capabilities optional parameter
+	msg = append(msg, byte(len(caps)+2), 2, byte(len(caps)))
+	return append(msg, caps...)
+}
+
+// parseOpen validates the peer's OPEN message, and returns the negotiated hold time and
+// whether both speakers support 4-octet AS numbers.
+func (b *bgpExporter) parseOpen(body []byte) (time.Duration, bool, error) {
+	if len(body) < 10 {
+		return 0, false, errors.New("BGP OPEN message too short")
+	}
+	if body[0] != bgpVersion {
+		return 0, false, fmt.Errorf("unsupported BGP version %d", body[0])
+	}
+	peerAS := uint32(binary.BigEndian.Uint16(body[1:3]))
+	holdTime := time.Duration(binary.BigEndian.Uint16(body[3:5])) * time.Second
+	if holdTime > bgpHoldTime {
+		holdTime = bgpHoldTime
+	}
+
+	as4 := false
+	params := body[10:]
+	if len(params) < int(body[9]) {
+		return 0, false, errors.New("BGP OPEN optional parameters truncated")
+	}
+	params = params[:body[9]]
+	for len(params) >= 2 {
+		paramType, paramLen := params[0], int(params[1])
+		if len(params) < 2+paramLen {
+			return 0, false, errors.New("BGP OPEN optional parameter truncated")
+		}
+		if paramType == 2 {
+			caps := params[2 : 2+paramLen]
+			for len(caps) >= 2 {
+				capCode, capLen := caps[0], int(caps[1])
+				if len(caps) < 2+capLen {
+					break
+				}
+				if capCode == bgpCap4OctetAS && capLen == 4 {
+					as4 = true
+					peerAS = binary.BigEndian.Uint32(caps[2:6])
+				}
+				caps = caps[2+capLen:]
+			}
+		}
+		params = params[2+paramLen:]
+	}
+
+	if peerAS != b.peerAS {
+		return 0, false, fmt.Errorf("BGP peer AS %d does not match configured peer AS %d", peerAS, b.peerAS)
+	}
+	return holdTime, as4, nil
+}
+
+// updateMessages returns the bodies of the UPDATE messages needed to withdraw advertised routes that are no longer
+// present, and announce new or changed routes. The advertised map is updated to reflect the announced routes.
+func (b *bgpExporter) updateMessages(advertised map[string]Route, routes []Route, as4 bool) [][]byte {
+	var msgs [][]byte
+	current := map[string]Route{}
+	for _, route := range routes {
+		current[route.PodCIDR] = route
+	}
+	for cidr := range advertised {
+		if _, ok := current[cidr]; ok {
+			continue
+		}
+		if msg, err := b.withdrawMessage(cidr); err == nil {
+			msgs = append(msgs, msg)
+		}
+		delete(advertised, cidr)
+	}
+	for cidr, route := range current {
+		if advertised[cidr] == route {
+			continue
+		}
+		msg, err := b.announceMessage(route, as4)
+		if err != nil {
+			logrus.Warnf("Not announcing pod CIDR route %s via %s: %v", cidr, route.NextHop, err)
+			continue
+		}
+		msgs = append(msgs, msg)
+		advertised[cidr] = route
+	}
+	return msgs
+}
+
+// announceMessage returns the body of an UPDATE message announcing a single route. IPv4 routes are
+// carried in the NLRI field, and IPv6 routes in the MP_REACH_NLRI attribute.
+func (b *bgpExporter) announceMessage(route Route, as4 bool) ([]byte, error) {
+	_, cidr, err := net.ParseCIDR(route.PodCIDR)
+	if err != nil {
+		return nil, err
+	}
+	nextHop := net.ParseIP(route.NextHop)
+	if nextHop == nil || utilsnet.IPFamilyOf(nextHop) != utilsnet.IPFamilyOfCIDR(cidr) {
+		return nil, errors.New("next-hop is not in the same IP family as the pod CIDR")
+	}
+
+	var attrs []byte
+	attrs = appendBGPAttr(attrs, bgpAttrFlagTransitive, bgpAttrOrigin, []byte{0})
+	asPath := []byte{}
+	if b.localAS != b.peerAS {
+		// a single AS_SEQUENCE segment containing the local AS
+		asPath = append(asPath, 2, 1)
+		if as4 {
+			asPath = binary.BigEndian.AppendUint32(asPath, b.localAS)
+		} else if b.localAS > 0xffff {
+			asPath = binary.BigEndian.AppendUint16(asPath, bgpASTrans)
+		} else {
+			asPath = binary.BigEndian.AppendUint16(asPath, uint16(b.localAS))
+		}
+	}
+	attrs = appendBGPAttr(attrs, bgpAttrFlagTransitive, bgpAttrASPath, asPath)
+	if b.localAS == b.peerAS {
+		attrs = appendBGPAttr(attrs, bgpAttrFlagTransitive, bgpAttrLocalPref, binary.BigEndian.AppendUint32(nil, 100))
+	}
+
+	var nlri []byte
+	if ip4 := nextHop.To4(); ip4 != nil {
+		attrs = appendBGPAttr(attrs, bgpAttrFlagTransitive, bgpAttrNextHop, ip4)
+		nlri = appendBGPPrefix(nil, cidr)
+	} else {
+		mpReach := binary.BigEndian.AppendUint16(nil, bgpAFIIPv6)
+		mpReach = append(mpReach, bgpSAFIUnicast, net.IPv6len)
+		mpReach = append(mpReach, nextHop.To16()...)
+		mpReach = append(mpReach, 0)
+		mpReach = appendBGPPrefix(mpReach, cidr)
+		attrs = appendBGPAttr(attrs, bgpAttrFlagOptional, bgpAttrMPReachNLRI, mpReach)
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(attrs)))
+	msg = append(msg, attrs...)
+	return append(msg, nlri...), nil
+}
+
+// withdrawMessage returns the body of an UPDATE message withdrawing a single route.
+func (b *bgpExporter) withdrawMessage(podCIDR string) ([]byte, error) {
+	_, cidr, err := net.ParseCIDR(podCIDR)
+	if err != nil {
+		return nil, err
+	}
+	if cidr.IP.To4() != nil {
+		withdrawn := appendBGPPrefix(nil, cidr)
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(withdrawn)))
+		msg = append(msg, withdrawn...)
+		return binary.BigEndian.AppendUint16(msg, 0), nil
+	}
+	mpUnreach := binary.BigEndian.AppendUint16(nil, bgpAFIIPv6)
+	mpUnreach = append(mpUnreach, bgpSAFIUnicast)
+	mpUnreach = appendBGPPrefix(mpUnreach, cidr)
+	attrs := appendBGPAttr(nil, bgpAttrFlagOptional, bgpAttrMPUnreach, mpUnreach)
+	msg := binary.BigEndian.AppendUint16(nil, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(attrs)))
+	return append(msg, attrs...), nil
+}
+
+// appendBGPAttr appends a path attribute with a single-octet length.
+func appendBGPAttr(b []byte, flags, attrType byte, value []byte) []byte {
+	return append(append(b, flags, attrType, byte(len(value))), value...)
+}
+
+// appendBGPPrefix appends a prefix in the length-prefixed encoding used by NLRI and withdrawn routes.
+func appendBGPPrefix(b []byte, cidr *net.IPNet) []byte {
+	ones, _ := cidr.Mask.Size()
+	ip := cidr.IP.To4()
+	if ip == nil {
+		ip = cidr.IP.To16()
+	}
+	return append(append(b, byte(ones)), ip[:(ones+7)/8]...)
+}
+
+// writeBGPMessage writes a BGP message with the given type and body.
+func writeBGPMessage(w io.Writer, msgType byte, body []byte) error {
+	msg := bytes.Repeat([]byte{0xff}, 16)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(bgpHeaderLen+len(body)))
+	msg = append(msg, msgType)
+	_, err := w.Write(append(msg, body...))
+	return err
+}
+
+// readBGPMessage reads a single BGP message, and returns its type and body.
+func readBGPMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, bgpHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[:16], bytes.Repeat([]byte{0xff}, 16)) {
+		return 0, nil, errors.New("invalid BGP message marker")
+	}
+	length := int(binary.BigEndian.Uint16(header[16:18]))
+	if length < bgpHeaderLen || length > bgpMaxMsgLen {
+		return 0, nil, fmt.Errorf("invalid BGP message length %d", length)
+	}
+	body := make([]byte, length-bgpHeaderLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[18], body, nil
+}
+
+// unexpectedBGPMessage returns an error describing an unexpected message from the peer.
+func unexpectedBGPMessage(msgType byte, body []byte) error {
+	if msgType == bgpMsgNotification && len(body) >= 2 {
+		return fmt.Errorf("BGP peer sent NOTIFICATION code %d subcode %d", body[0], body[1])
+	}
+	return fmt.Errorf("unexpected BGP message type %d", msgType)
+}
@@ -0,0 +1,212 @@
+package routeexport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_UnitNewBGPExporter(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantPeer   string
+		wantPeerAS uint32
+		wantErr    bool
+	}{
+		{
+			name:       "iBGP default port",
+			target:     "bgp://192.168.1.1?local-as=64512&router-id=192.168.1.10",
+			wantPeer:   "192.168.1.1:179",
+			wantPeerAS: 64512,
+		},
+		{
+			name:       "eBGP with port",
+			target:     "bgp://router.example.com:1179?local-as=64512&peer-as=65000&router-id=192.168.1.10",
+			wantPeer:   "router.example.com:1179",
+			wantPeerAS: 65000,
+		},
+		{
+			name:       "IPv6 peer",
+			target:     "bgp://[fd00::1]?local-as=4200000000&router-id=192.168.1.10",
+			wantPeer:   "[fd00::1]:179",
+			wantPeerAS: 4200000000,
+		},
+		{name: "missing peer", target: "bgp://?local-as=64512&router-id=192.168.1.10", wantErr: true},
+		{name: "missing local-as", target: "bgp://192.168.1.1?router-id=192.168.1.10", wantErr: true},
+		{name: "invalid peer-as", target: "bgp://192.168.1.1?local-as=64512&peer-as=0&router-id=192.168.1.10", wantErr: true},
+		{name: "missing router-id", target: "bgp://192.168.1.1?local-as=64512", wantErr: true},
+		{name: "IPv6 router-id", target: "bgp://192.168.1.1?local-as=64512&router-id=fd00::10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, err := NewExporter(tt.target, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			b := exporter.(*bgpExporter)
+			if b.peer != tt.wantPeer || b.peerAS != tt.wantPeerAS {
+				t.Errorf("NewExporter() peer = %s AS %d, want %s AS %d", b.peer, b.peerAS, tt.wantPeer, tt.wantPeerAS)
+			}
+		})
+	}
+}
+
+func Test_UnitBGPUpdateMessages(t *testing.T) {
+	b := mustBGPExporter(t, "bgp://192.168.1.1?local-as=64512&router-id=192.168.1.10")
+	advertised := map[string]Route{}
+
+	route := Route{NodeName: "node1", PodCIDR: "10.42.1.0/24", NextHop: "192.168.1.2"}
+	msgs := b.updateMessages(advertised, []Route{route}, true)
+	want := []byte{
+		0, 0, // withdrawn routes length
+		0, 21, // path attributes length
+		0x40, bgpAttrOrigin, 1, 0,
+		0x40, bgpAttrASPath, 0,
+		0x40, bgpAttrLocalPref, 4, 0, 0, 0, 100,
+		0x40, bgpAttrNextHop, 4, 192, 168, 1, 2,
+		24, 10, 42, 1, // NLRI
+	}
+	if len(msgs) != 1 || !bytes.Equal(msgs[0], want) {
+		t.Fatalf("announce = %v, want %v", msgs, want)
+	}
+
+	if msgs := b.updateMessages(advertised, []Route{route}, true); len(msgs) != 0 {
+		t.Errorf("unchanged routes produced %d UPDATE messages, want 0", len(msgs))
+	}
+
+	msgs = b.updateMessages(advertised, nil, true)
+	want = []byte{0, 4, 24, 10, 42, 1, 0, 0}
+	if len(msgs) != 1 || !bytes.Equal(msgs[0], want) {
+		t.Fatalf("withdraw = %v, want %v", msgs, want)
+	}
+	if len(advertised) != 0 {
+		t.Errorf("advertised = %v after withdraw, want empty", advertised)
+	}
+
+	route6 := Route{NodeName: "node1", PodCIDR: "fd42:0:0:1::/64", NextHop: "fd00::2"}
+	msgs = b.updateMessages(advertised, []Route{route6}, true)
+	if len(msgs) != 1 || !bytes.Contains(msgs[0], []byte{bgpAttrFlagOptional, bgpAttrMPReachNLRI}) {
+		t.Fatalf("IPv6 announce = %v, want MP_REACH_NLRI attribute", msgs)
+	}
+
+	mismatched := Route{NodeName: "node2", PodCIDR: "10.42.2.0/24", NextHop: "fd00::3"}
+	if msgs := b.updateMessages(map[string]Route{}, []Route{mismatched}, true); len(msgs) != 0 {
+		t.Errorf("mixed-family route produced %d UPDATE messages, want 0", len(msgs))
+	}
+}
+
+func Test_UnitBGPSession(t *testing.T) {
+	b := mustBGPExporter(t, "bgp://192.168.1.1?local-as=64512&peer-as=65000&router-id=192.168.1.10")
+	peer := mustBGPExporter(t, "bgp://192.168.1.10?local-as=65000&peer-as=64512&router-id=192.168.1.1")
+	b.routes = []Route{{NodeName: "node1", PodCIDR: "10.42.1.0/24", NextHop: "192.168.1.2"}}
+	// the session is driven directly by the test, so Export must not dial the peer
+	b.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local, remote := net.Pipe()
+	defer remote.Close()
+	remote.SetDeadline(time.Now().Add(10 * time.Second))
+
+	sessionErr := make(chan error, 1)
+	go func() {
+		sessionErr <- b.session(ctx, local)
+		local.Close()
+	}()
+
+	expect := func(wantType byte) []byte {
+		t.Helper()
+		msgType, body, err := readBGPMessage(remote)
+		if err != nil {
+			t.Fatalf("failed to read BGP message: %v", err)
+		}
+		if msgType != wantType {
+			t.Fatalf("BGP message type = %d, want %d", msgType, wantType)
+		}
+		return body
+	}
+
+	open := expect(bgpMsgOpen)
+	if _, as4, err := peer.parseOpen(open); err != nil || !as4 {
+		t.Fatalf("peer failed to parse OPEN: as4 = %v, err = %v", as4, err)
+	}
+	if err := writeBGPMessage(remote, bgpMsgOpen, peer.openMessage()); err != nil {
+		t.Fatal(err)
+	}
+	expect(bgpMsgKeepalive)
+	if err := writeBGPMessage(remote, bgpMsgKeepalive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	update := expect(bgpMsgUpdate)
+	if !bytes.HasSuffix(update, []byte{24, 10, 42, 1}) {
+		t.Errorf("UPDATE = %v, want NLRI for 10.42.1.0/24", update)
+	}
+	// eBGP sessions carry the local AS as a 4-octet AS_SEQUENCE
+	if !bytes.Contains(update, []byte{0x40, bgpAttrASPath, 6, 2, 1, 0, 0, 0xfc, 0x00}) {
+		t.Errorf("UPDATE = %v, want AS_PATH containing AS 64512", update)
+	}
+
+	// replace the route; the old route should be withdrawn and the new one announced
+	if err := b.Export(ctx, []Route{{NodeName: "node2", PodCIDR: "10.42.2.0/24", NextHop: "192.168.1.3"}}); err != nil {
+		t.Fatal(err)
+	}
+	withdraw := expect(bgpMsgUpdate)
+	if !bytes.Equal(withdraw, []byte{0, 4, 24, 10, 42, 1, 0, 0}) {
+		t.Errorf("UPDATE = %v, want withdrawal of 10.42.1.0/24", withdraw)
+	}
+	update = expect(bgpMsgUpdate)
+	if !bytes.HasSuffix(update, []byte{24, 10, 42, 2}) {
+		t.Errorf("UPDATE = %v, want NLRI for 10.42.2.0/24", update)
+	}
+
+	cancel()
+	expect(bgpMsgNotification)
+	if err := <-sessionErr; err != context.Canceled {
+		t.Errorf("session() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_UnitBGPSessionPeerASMismatch(t *testing.T) {
+	b := mustBGPExporter(t, "bgp://192.168.1.1?local-as=64512&peer-as=65000&router-id=192.168.1.10")
+	peer := mustBGPExporter(t, "bgp://192.168.1.10?local-as=65001&router-id=192.168.1.1")
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	remote.SetDeadline(time.Now().Add(10 * time.Second))
+
+	sessionErr := make(chan error, 1)
+	go func() {
+		sessionErr <- b.session(context.Background(), local)
+		local.Close()
+	}()
+	if _, _, err := readBGPMessage(remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBGPMessage(remote, bgpMsgOpen, peer.openMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-sessionErr; err == nil {
+		t.Error("session() expected error for mismatched peer AS")
+	}
+}
+
+func mustBGPExporter(t *testing.T, target string) *bgpExporter {
+	t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newBGPExporter(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
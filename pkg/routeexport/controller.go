@@ -0,0 +1,129 @@
+package routeexport
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilsnet "k8s.io/utils/net"
+)
+
+const (
+	// The flannel public-ip annotations hold the address that flannel uses as the next-hop for the
+	// node's pod CIDR; this is the node's external IP if flannel-external-ip is enabled, or the
+	// public-ip-overwrite address if set.
+	flannelPublicIPv4Annotation = "flannel.alpha.coreos.com/public-ip"
+	flannelPublicIPv6Annotation = "flannel.alpha.coreos.com/public-ipv6"
+)
+
+// Route is a route to a node's pod CIDR.
+type Route struct {
+	NodeName string `json:"nodeName"`
+	PodCIDR  string `json:"podCIDR"`
+	NextHop  string `json:"nextHop"`
+}
+
+// Register starts a controller that publishes the pod CIDR routes of all nodes to the given
+// exporter whenever a node's pod CIDRs or addresses change.
+func Register(ctx context.Context, exporter Exporter, nodes coreclient.NodeController) error {
+	h := &handler{
+		ctx:       ctx,
+		exporter:  exporter,
+		nodeCache: nodes.Cache(),
+	}
+	nodes.OnChange(ctx, version.Program+"-route-export", h.onChange)
+	return nil
+}
+
+type handler struct {
+	ctx       context.Context
+	exporter  Exporter
+	nodeCache coreclient.NodeCache
+
+	mu       sync.Mutex
+	exported []Route
+}
+
+// onChange exports the routes for all nodes. The routes are only exported if they differ
+// from the last successful export, so that updates to node status do not cause a flood of
+// exports. Node deletion is handled by the nil node passed to the handler on removal.
+func (h *handler) onChange(key string, node *corev1.Node) (*corev1.Node, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	nodes, err := h.nodeCache.List(labels.Everything())
+	if err != nil {
+		return node, err
+	}
+	routes := nodeRoutes(nodes)
+	if h.exported != nil && slices.Equal(h.exported, routes) {
+		return node, nil
+	}
+	if err := h.exporter.Export(h.ctx, routes); err != nil {
+		return node, err
+	}
+	logrus.Infof("Exported %d pod CIDR routes to %s", len(routes), h.exporter)
+	h.exported = routes
+	return node, nil
+}
+
+// nodeRoutes returns the routes for the pod CIDRs of the given nodes, sorted by node name and pod CIDR.
+// Nodes that are being deleted, and pod CIDRs without an address of the same IP family to use as the
+// next-hop, are skipped.
+func nodeRoutes(nodes []*corev1.Node) []Route {
+	routes := []Route{}
+	for _, node := range nodes {
+		if node.DeletionTimestamp != nil {
+			continue
+		}
+		for _, podCIDR := range node.Spec.PodCIDRs {
+			_, cidr, err := net.ParseCIDR(podCIDR)
+			if err != nil {
+				continue
+			}
+			nextHop := nodeNextHop(node, utilsnet.IPFamilyOfCIDR(cidr))
+			if nextHop == "" {
+				logrus.Debugf("Skipping route for pod CIDR %s of node %s: no IPv%s address", podCIDR, node.Name, utilsnet.IPFamilyOfCIDR(cidr))
+				continue
+			}
+			routes = append(routes, Route{NodeName: node.Name, PodCIDR: cidr.String(), NextHop: nextHop})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].NodeName != routes[j].NodeName {
+			return routes[i].NodeName < routes[j].NodeName
+		}
+		return routes[i].PodCIDR < routes[j].PodCIDR
+	})
+	return routes
+}
+
+// nodeNextHop returns the next-hop address of the given IP family for a node. The flannel public-ip
+// annotation is preferred, so that the next-hop matches the routes installed by flannel host-gw;
+// the first internal IP of the same family is used if the annotation is not set.
+func nodeNextHop(node *corev1.Node, family utilsnet.IPFamily) string {
+	annotation := flannelPublicIPv4Annotation
+	if family == utilsnet.IPv6 {
+		annotation = flannelPublicIPv6Annotation
+	}
+	if ip := net.ParseIP(strings.TrimSpace(node.Annotations[annotation])); ip != nil && utilsnet.IPFamilyOf(ip) == family {
+		return ip.String()
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type != corev1.NodeInternalIP {
+			continue
+		}
+		if ip := net.ParseIP(address.Address); ip != nil && utilsnet.IPFamilyOf(ip) == family {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package routeexport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitNodeRoutes(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name  string
+		nodes []*corev1.Node
+		want  []Route
+	}{
+		{
+			name: "no nodes",
+			want: []Route{},
+		},
+		{
+			name: "dual-stack with flannel annotation",
+			nodes: []*corev1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "node2",
+						Annotations: map[string]string{flannelPublicIPv4Annotation: "192.168.2.10"},
+					},
+					Spec: corev1.NodeSpec{PodCIDRs: []string{"10.42.1.0/24", "fd42:0:0:1::/64"}},
+					Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+						{Type: corev1.NodeInternalIP, Address: "fd00::2"},
+					}},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+					Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.42.0.0/24"}},
+					Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeExternalIP, Address: "192.168.2.1"},
+						{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					}},
+				},
+			},
+			want: []Route{
+				{NodeName: "node1", PodCIDR: "10.42.0.0/24", NextHop: "10.0.0.1"},
+				{NodeName: "node2", PodCIDR: "10.42.1.0/24", NextHop: "192.168.2.10"},
+				{NodeName: "node2", PodCIDR: "fd42:0:0:1::/64", NextHop: "fd00::2"},
+			},
+		},
+		{
+			name: "skip deleted nodes, missing pod CIDRs, and missing addresses",
+			nodes: []*corev1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "deleted", DeletionTimestamp: &now},
+					Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.42.0.0/24"}},
+					Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+					Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.2"}}},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "ipv4-only"},
+					Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.42.3.0/24", "fd42:0:0:3::/64"}},
+					Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.3"}}},
+				},
+			},
+			want: []Route{
+				{NodeName: "ipv4-only", PodCIDR: "10.42.3.0/24", NextHop: "10.0.0.3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodeRoutes(tt.nodes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodeRoutes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitHTTPExporter(t *testing.T) {
+	routes := []Route{{NodeName: "node1", PodCIDR: "10.42.0.0/24", NextHop: "10.0.0.1"}}
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusOK},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got httpRequest
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			exporter, err := NewExporter(server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exporter.(*httpExporter).client = server.Client()
+
+			err = exporter.Export(context.Background(), routes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got.Routes, routes) {
+				t.Errorf("Export() sent %+v, want %+v", got.Routes, routes)
+			}
+		})
+	}
+}
+
+func Test_UnitNewExporter(t *testing.T) {
+	for _, target := range []string{"http://router.example.com/routes", "bgp", "https://"} {
+		if _, err := NewExporter(target, nil); err == nil {
+			t.Errorf("NewExporter(%q) expected error", target)
+		}
+	}
+	if _, err := NewExporter(TargetConfigMap, nil); err != nil {
+		t.Errorf("NewExporter(%q) unexpected error: %v", TargetConfigMap, err)
+	}
+}
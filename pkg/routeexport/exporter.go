@@ -0,0 +1,143 @@
+package routeexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// TargetConfigMap is the route export target that writes routes to a ConfigMap.
+	TargetConfigMap = "configmap"
+
+	// RoutesKey is the ConfigMap data key that the routes are written to, as a JSON list.
+	RoutesKey = "routes.json"
+
+	httpTimeout = 10 * time.Second
+)
+
+// RoutesConfigMapName is the name of the ConfigMap in kube-system that routes are exported to.
+var RoutesConfigMapName = version.Program + "-pod-cidr-routes"
+
+// Exporter publishes routes to an external target.
+type Exporter interface {
+	// Export publishes the complete set of routes, replacing any previously exported routes.
+	Export(ctx context.Context, routes []Route) error
+	// String returns a description of the target, for logging.
+	String() string
+}
+
+// NewExporter returns an exporter for the given target, which is either "configmap", an https URL, or a bgp URL.
+func NewExporter(target string, configMaps coreclient.ConfigMapController) (Exporter, error) {
+	if target == TargetConfigMap {
+		return &configMapExporter{configMaps: configMaps}, nil
+	}
+	u, err := url.Parse(target)
+	if err == nil && u.Scheme == SchemeBGP {
+		return newBGPExporter(u)
+	}
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid route export target: must be %q, an https URL, or a bgp URL", TargetConfigMap)
+	}
+	return &httpExporter{url: target, host: u.Host, client: &http.Client{Timeout: httpTimeout}}, nil
+}
+
+// configMapExporter writes routes to a ConfigMap, for consumption by routers or
+// route reflectors that can read from the Kubernetes API.
+type configMapExporter struct {
+	configMaps coreclient.ConfigMapController
+}
+
+func (c *configMapExporter) Export(ctx context.Context, routes []Route) error {
+	b, err := json.Marshal(routes)
+	if err != nil {
+		return err
+	}
+	configMap, err := c.configMaps.Get(metav1.NamespaceSystem, RoutesConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = c.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      RoutesConfigMapName,
+				Namespace: metav1.NamespaceSystem,
+			},
+			Data: map[string]string{RoutesKey: string(b)},
+		})
+		return errors.WithMessage(err, "failed to create routes ConfigMap")
+	} else if err != nil {
+		return errors.WithMessage(err, "failed to get routes ConfigMap")
+	}
+
+	if configMap.Data[RoutesKey] == string(b) {
+		return nil
+	}
+	configMap = configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[RoutesKey] = string(b)
+	_, err = c.configMaps.Update(configMap)
+	return errors.WithMessage(err, "failed to update routes ConfigMap")
+}
+
+func (c *configMapExporter) String() string {
+	return "ConfigMap " + metav1.NamespaceSystem + "/" + RoutesConfigMapName
+}
+
+// httpRequest is the body POSTed to the route export URL.
+type httpRequest struct {
+	Routes []Route `json:"routes"`
+}
+
+// httpExporter POSTs routes to an https endpoint, for routers or controllers
+// that cannot read from the Kubernetes API.
+type httpExporter struct {
+	url    string
+	host   string
+	client *http.Client
+}
+
+func (h *httpExporter) Export(ctx context.Context, routes []Route) error {
+	b, err := json.Marshal(httpRequest{Routes: routes})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(b))
+	if err != nil {
+		return errors.WithMessage(err, "failed to create route export request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// do not return the url.Error directly, as the URL may contain a secret token
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return errors.WithMessage(err, "failed to export routes")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("route export endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *httpExporter) String() string {
+	return "https://" + h.host
+}
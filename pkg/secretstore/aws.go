@@ -0,0 +1,136 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	awsService         = "secretsmanager"
+	awsTarget          = "secretsmanager.GetSecretValue"
+	awsAlgorithm       = "AWS4-HMAC-SHA256"
+	awsContentType     = "application/x-amz-json-1.1"
+	awsTimeFormat      = "20060102T150405Z"
+	awsShortTimeFormat = "20060102"
+)
+
+// readAWS reads a secret from AWS Secrets Manager, using credentials from the environment,
+// the shared credentials file, or the instance profile. The region is taken from the secret ARN,
+// or from AWS_REGION or AWS_DEFAULT_REGION if the secret is referenced by name.
+func readAWS(ctx context.Context, secretID string) (string, error) {
+	region := awsRegion(secretID)
+	if region == "" {
+		return "", errors.New("unable to determine region; reference the secret by ARN, or set AWS_REGION")
+	}
+	creds, err := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	}).Get()
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to get AWS credentials")
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	endpoint := "https://" + awsService + "." + region + ".amazonaws.com/"
+	if strings.HasPrefix(region, "cn-") {
+		endpoint = "https://" + awsService + "." + region + ".amazonaws.com.cn/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", awsContentType)
+	req.Header.Set("X-Amz-Target", awsTarget)
+	signV4(req, body, creds, region, awsService, time.Now())
+
+	secret := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	if err := do(http.DefaultClient, req, &secret); err != nil {
+		return "", err
+	}
+	return secret.SecretString, nil
+}
+
+// awsRegion returns the region from a secret ARN, or from the environment.
+func awsRegion(secretID string) string {
+	// arn:PARTITION:secretsmanager:REGION:ACCOUNT:secret:NAME
+	if parts := strings.SplitN(secretID, ":", 6); len(parts) == 6 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// signV4 adds an AWS Signature Version 4 authorization header to a request.
+// All headers set on the request are signed.
+func signV4(req *http.Request, body []byte, creds credentials.Value, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(awsTimeFormat)
+	scope := strings.Join([]string{now.UTC().Format(awsShortTimeFormat), region, service, "aws4_request"}, "/")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+	stringToSign := strings.Join([]string{awsAlgorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", awsAlgorithm+" Credential="+creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
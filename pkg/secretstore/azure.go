@@ -0,0 +1,46 @@
+package secretstore
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	azureTokenURL   = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureResource   = "https://vault.azure.net"
+	azureAPIVersion = "7.4"
+	azureVaultHost  = ".vault.azure.net"
+)
+
+// readAzure reads a secret from Azure Key Vault, using an access token for the instance's managed identity.
+// The vault may be specified by name, or by host name for vaults outside of the public cloud.
+func readAzure(ctx context.Context, path string) (string, error) {
+	vault, secretPath, _ := strings.Cut(path, "/")
+	if !strings.Contains(vault, ".") {
+		vault += azureVaultHost
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	u := azureTokenURL + "?api-version=2018-02-01&resource=" + url.QueryEscape(azureResource)
+	if err := getMetadata(ctx, u, "Metadata", "true", &token); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+vault+"/secrets/"+secretPath+"?api-version="+azureAPIVersion, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	secret := struct {
+		Value string `json:"value"`
+	}{}
+	if err := do(http.DefaultClient, req, &secret); err != nil {
+		return "", err
+	}
+	return secret.Value, nil
+}
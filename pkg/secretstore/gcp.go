@@ -0,0 +1,43 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	gcpTokenURL         = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+)
+
+// readGCP reads a secret version from Google Cloud Secret Manager, using an access token
+// for the instance's default service account.
+func readGCP(ctx context.Context, name string) (string, error) {
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := getMetadata(ctx, gcpTokenURL, "Metadata-Flavor", "Google", &token); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerURL+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	secret := struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}{}
+	if err := do(http.DefaultClient, req, &secret); err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(secret.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
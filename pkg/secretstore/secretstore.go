@@ -0,0 +1,223 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	Vault = "vault"
+	AWS   = "aws-sm"
+	GCP   = "gcp-sm"
+	Azure = "azure-kv"
+
+	// DefaultRefreshInterval is the default interval at which secrets are read from the store again.
+	DefaultRefreshInterval = 5 * time.Minute
+
+	// defaultVaultKey is the key read from Vault secrets when no key is specified.
+	defaultVaultKey = "token"
+	requestTimeout  = 30 * time.Second
+	maxBodySize     = 1024 * 1024
+)
+
+var (
+	cacheLock sync.Mutex
+	cache     = map[string]cachedValue{}
+)
+
+type cachedValue struct {
+	value   string
+	expires time.Time
+}
+
+// Reference identifies a secret in an external secret store, in SCHEME://PATH[#KEY] format.
+// If a key is specified, the secret is expected to contain a JSON object, and the value of the key is used.
+// Secrets stored in Vault are always objects; the key defaults to "token".
+type Reference struct {
+	Scheme string
+	Path   string
+	Key    string
+}
+
+// Parse parses a secret store reference.
+func Parse(ref string) (*Reference, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid secret store reference %q: must be in SCHEME://PATH[#KEY] format", ref)
+	}
+	r := &Reference{Scheme: scheme}
+	r.Path, r.Key, _ = strings.Cut(rest, "#")
+	r.Path = strings.Trim(r.Path, "/")
+	if r.Path == "" {
+		return nil, fmt.Errorf("invalid secret store reference %q: path is required", ref)
+	}
+
+	switch scheme {
+	case Vault:
+		if r.Key == "" {
+			r.Key = defaultVaultKey
+		}
+	case AWS:
+	case GCP:
+		// projects/PROJECT/secrets/SECRET[/versions/VERSION]
+		parts := strings.Split(r.Path, "/")
+		if (len(parts) != 4 && len(parts) != 6) || parts[0] != "projects" || parts[2] != "secrets" || (len(parts) == 6 && parts[4] != "versions") {
+			return nil, fmt.Errorf("invalid secret store reference %q: must be in %s://projects/PROJECT/secrets/SECRET[/versions/VERSION] format", ref, GCP)
+		}
+		if len(parts) == 4 {
+			r.Path += "/versions/latest"
+		}
+	case Azure:
+		// VAULT/SECRET[/VERSION]
+		if parts := strings.Split(r.Path, "/"); len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid secret store reference %q: must be in %s://VAULT/SECRET[/VERSION] format", ref, Azure)
+		}
+	default:
+		return nil, fmt.Errorf("invalid secret store reference %q: unsupported secret store %q; must be one of %s", ref, scheme, strings.Join([]string{Vault, AWS, GCP, Azure}, ", "))
+	}
+	return r, nil
+}
+
+// String returns the reference in SCHEME://PATH[#KEY] format.
+func (r *Reference) String() string {
+	if r.Key == "" {
+		return r.Scheme + "://" + r.Path
+	}
+	return r.Scheme + "://" + r.Path + "#" + r.Key
+}
+
+// read reads the secret value from the store.
+func (r *Reference) read(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var value string
+	var err error
+	switch r.Scheme {
+	case Vault:
+		value, err = readVault(ctx, r.Path, r.Key)
+	case AWS:
+		value, err = readAWS(ctx, r.Path)
+	case GCP:
+		value, err = readGCP(ctx, r.Path)
+	case Azure:
+		value, err = readAzure(ctx, r.Path)
+	}
+	if err == nil && r.Key != "" && r.Scheme != Vault {
+		value, err = jsonKey([]byte(value), r.Key)
+	}
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed to read secret from %s", r)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("secret read from %s is empty", r)
+	}
+	return value, nil
+}
+
+// Read returns the value of the referenced secret. Values are cached, and are read from the store
+// again once they are older than the refresh interval.
+func Read(ctx context.Context, ref string, refresh time.Duration) (string, error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if cached, ok := cache[ref]; ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+	r, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := r.read(ctx)
+	if err != nil {
+		return "", err
+	}
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+	cache[ref] = cachedValue{value: value, expires: time.Now().Add(refresh)}
+	return value, nil
+}
+
+// Watch reads the referenced secret at the refresh interval until the context is cancelled,
+// and calls update with the new value each time it changes from the initial value.
+func Watch(ctx context.Context, ref string, refresh time.Duration, update func(value string)) {
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+	current, _ := Read(ctx, ref, refresh)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		value, err := Read(ctx, ref, refresh)
+		if err != nil {
+			logrus.Warnf("Failed to refresh secret: %v", err)
+			return
+		}
+		if value == current {
+			return
+		}
+		current = value
+		update(value)
+	}, refresh)
+}
+
+// jsonKey returns the string value of a key in a JSON object.
+func jsonKey(b []byte, key string) (string, error) {
+	data := map[string]any{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", errors.WithMessagef(err, "failed to decode secret containing key %q", key)
+	}
+	return stringKey(data, key)
+}
+
+func stringKey(data map[string]any, key string) (string, error) {
+	v, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret does not contain key %q", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("secret key %q is not a string", key)
+	}
+	return s, nil
+}
+
+// do sends a request and decodes the JSON response body into v.
+func do(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// getMetadata retrieves a value from a cloud instance metadata service.
+func getMetadata(ctx context.Context, u, header, value string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(header, value)
+
+	// The metadata service is link-local, and must not be reached through a proxy
+	return do(&http.Client{Transport: &http.Transport{}}, req, v)
+}
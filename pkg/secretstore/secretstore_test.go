@@ -0,0 +1,156 @@
+package secretstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func Test_UnitParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "vault default key",
+			ref:  "vault://secret/data/k3s",
+			want: "vault://secret/data/k3s#token",
+		},
+		{
+			name: "vault key",
+			ref:  "vault://secret/data/k3s#agent-token",
+			want: "vault://secret/data/k3s#agent-token",
+		},
+		{
+			name: "aws arn",
+			ref:  "aws-sm://arn:aws:secretsmanager:us-west-2:123456789012:secret:k3s-token",
+			want: "aws-sm://arn:aws:secretsmanager:us-west-2:123456789012:secret:k3s-token",
+		},
+		{
+			name: "gcp default version",
+			ref:  "gcp-sm://projects/my-project/secrets/k3s-token",
+			want: "gcp-sm://projects/my-project/secrets/k3s-token/versions/latest",
+		},
+		{
+			name: "gcp version",
+			ref:  "gcp-sm://projects/my-project/secrets/k3s-token/versions/3",
+			want: "gcp-sm://projects/my-project/secrets/k3s-token/versions/3",
+		},
+		{
+			name:    "gcp invalid",
+			ref:     "gcp-sm://my-project/k3s-token",
+			wantErr: true,
+		},
+		{
+			name: "azure",
+			ref:  "azure-kv://my-vault/k3s-token",
+			want: "azure-kv://my-vault/k3s-token",
+		},
+		{
+			name:    "azure invalid",
+			ref:     "azure-kv://k3s-token",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported",
+			ref:     "file:///etc/k3s/token",
+			wantErr: true,
+		},
+		{
+			name:    "missing scheme",
+			ref:     "secret/data/k3s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && r.String() != tt.want {
+				t.Errorf("Parse() = %s, want %s", r, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitReadVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/k3s":
+			w.Write([]byte(`{"data":{"data":{"token":"kv2-token"},"metadata":{"version":1}}}`))
+		case "/v1/kv/k3s":
+			w.Write([]byte(`{"data":{"token":"kv1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "kv version 2",
+			ref:  "vault://secret/data/k3s",
+			want: "kv2-token",
+		},
+		{
+			name: "kv version 1",
+			ref:  "vault://kv/k3s",
+			want: "kv1-token",
+		},
+		{
+			name:    "missing key",
+			ref:     "vault://kv/k3s#agent-token",
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			ref:     "vault://kv/missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(context.Background(), tt.ref, time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_UnitSignV4 uses the get-vanilla example from the AWS Signature Version 4 test suite.
+func Test_UnitSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := credentials.Value{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("signV4() Authorization = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package secretstore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// readVault reads a key from a Vault secret, using the standard Vault client environment variables:
+// VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE, and VAULT_CACERT. If VAULT_TOKEN is not set, the token
+// is read from ~/.vault-token, where it is written by 'vault login' and Vault Agent sinks.
+// Both KV version 1 and version 2 secrets are supported; for KV version 2 the path must include the
+// data/ prefix, for example secret/data/NAME.
+func readVault(ctx context.Context, path, key string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	client, err := vaultClient()
+	if err != nil {
+		return "", err
+	}
+	secret := struct {
+		Data map[string]any `json:"data"`
+	}{}
+	if err := do(client, req, &secret); err != nil {
+		return "", err
+	}
+
+	// KV version 2 secrets nest the secret data within the data field, alongside the secret metadata.
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	return stringKey(data, key)
+}
+
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WithMessage(err, "VAULT_TOKEN is not set")
+	}
+	b, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", errors.WithMessage(err, "VAULT_TOKEN is not set")
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func vaultClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// apiserverSANFileInterval is the interval at which the apiserver-san-file is checked for changes.
+const apiserverSANFileInterval = 30 * time.Second
+
+// watchAPIServerSANFile periodically re-reads the apiserver-san-file, and re-issues the kube-apiserver
+// serving cert if the list of names has changed. The kube-apiserver watches its serving cert and key
+// for changes, so the new cert is used without restarting the apiserver.
+func watchAPIServerSANFile(ctx context.Context, controlConfig *config.Control) {
+	logrus.Infof("Watching %s for changes to kube-apiserver serving cert SANs", controlConfig.APIServerSANFile)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		updated, err := deps.UpdateAPIServerServingCert(controlConfig)
+		if err != nil {
+			logrus.Errorf("Failed to update kube-apiserver serving cert SANs: %v", err)
+			return
+		}
+		if updated {
+			logrus.Infof("Re-issued kube-apiserver serving cert with SANs from %s", controlConfig.APIServerSANFile)
+		}
+	}, apiserverSANFileInterval)
+}
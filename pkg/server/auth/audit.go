@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/authenticator/cloudidentity"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+)
+
+const (
+	TokenTypeBasic         = "basic"
+	TokenTypeBootstrap     = "bootstrap"
+	TokenTypeCloudIdentity = "cloud-identity"
+
+	TokenAuditSuccess = "success"
+	TokenAuditFailure = "failure"
+)
+
+// TokenAuditRecord records an attempt to authenticate to the supervisor with a token.
+// The token secret is never recorded.
+type TokenAuditRecord struct {
+	Time time.Time `json:"time"`
+	// Result is either success or failure
+	Result string `json:"result"`
+	// Reason describes why authentication failed
+	Reason string `json:"reason,omitempty"`
+	// TokenType is basic for the server and agent tokens, bootstrap for bootstrap tokens,
+	// or cloud-identity for cloud identity credentials
+	TokenType string `json:"tokenType"`
+	// TokenID is the username for basic tokens, the token ID for bootstrap tokens,
+	// or the provider for cloud identity credentials
+	TokenID  string `json:"tokenID"`
+	SourceIP string `json:"sourceIP"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	// RequestedRoles are the roles allowed to make the request
+	RequestedRoles []string `json:"requestedRoles,omitempty"`
+	// User is the name of the authenticated user
+	User string `json:"user,omitempty"`
+	// NodeName is the node name sent by the client
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// tokenFromRequest returns the type and ID of the token used to authenticate the request,
+// along with a bool indicating if the request contains a token.
+func tokenFromRequest(req *http.Request) (string, string, bool) {
+	if username, password, ok := req.BasicAuth(); ok {
+		if provider, _, ok := cloudidentity.ParsePassword(password); ok {
+			return TokenTypeCloudIdentity, provider, true
+		}
+		return TokenTypeBasic, username, true
+	}
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		if id, _, ok := strings.Cut(token, "."); ok && bootstraputil.IsValidBootstrapToken(token) {
+			return TokenTypeBootstrap, id, true
+		}
+	}
+	return "", "", false
+}
+
+// auditTokenAuth records the result of token authentication for a request, if token auditing is enabled.
+// Requests that do not contain a token, such as those authenticated with client certificates, are not recorded.
+func auditTokenAuth(serverConfig *config.Control, req *http.Request, roles []string, info user.Info, reason string) {
+	if serverConfig.Runtime.TokenAuditLog == nil && !serverConfig.TokenAuditEvents {
+		return
+	}
+	tokenType, tokenID, ok := tokenFromRequest(req)
+	if !ok {
+		return
+	}
+
+	record := TokenAuditRecord{
+		Time:           time.Now().UTC(),
+		Result:         TokenAuditSuccess,
+		Reason:         reason,
+		TokenType:      tokenType,
+		TokenID:        tokenID,
+		Method:         req.Method,
+		Path:           req.URL.Path,
+		RequestedRoles: roles,
+		NodeName:       strings.ToLower(req.Header.Get(version.Program + "-Node-Name")),
+	}
+	record.SourceIP, _, _ = net.SplitHostPort(req.RemoteAddr)
+	if reason != "" {
+		record.Result = TokenAuditFailure
+	}
+	if info != nil {
+		record.User = info.GetName()
+	}
+
+	if serverConfig.Runtime.TokenAuditLog != nil {
+		b, err := json.Marshal(record)
+		if err == nil {
+			_, err = serverConfig.Runtime.TokenAuditLog.Write(append(b, '\n'))
+		}
+		if err != nil {
+			logrus.Errorf("Failed to write token audit log: %v", err)
+		}
+	}
+
+	if serverConfig.TokenAuditEvents && serverConfig.Runtime.Event != nil {
+		if ref := tokenAuditObjectRef(record); ref != nil {
+			if record.Result == TokenAuditSuccess {
+				serverConfig.Runtime.Event.Eventf(ref, corev1.EventTypeNormal, "TokenAuthenticationSucceeded", "%s token %s used by %s for %s %s", record.TokenType, record.TokenID, record.SourceIP, record.Method, record.Path)
+			} else {
+				serverConfig.Runtime.Event.Eventf(ref, corev1.EventTypeWarning, "TokenAuthenticationFailed", "%s token %s rejected from %s for %s %s: %s", record.TokenType, record.TokenID, record.SourceIP, record.Method, record.Path, record.Reason)
+			}
+		}
+	}
+}
+
+// tokenAuditObjectRef returns a reference to the Node that the request was made for, or the Secret for the
+// bootstrap token that the request was made with. Events are not recorded for other requests, as there
+// is no object to attach them to.
+func tokenAuditObjectRef(record TokenAuditRecord) *corev1.ObjectReference {
+	if record.NodeName != "" {
+		return &corev1.ObjectReference{
+			Kind: "Node",
+			Name: record.NodeName,
+			UID:  types.UID(record.NodeName),
+		}
+	}
+	if record.TokenType == TokenTypeBootstrap {
+		return &corev1.ObjectReference{
+			Kind:      "Secret",
+			Namespace: metav1.NamespaceSystem,
+			Name:      bootstrapapi.BootstrapTokenSecretPrefix + record.TokenID,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func Test_UnitAuditTokenAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		bearer    string
+		reason    string
+		wantType  string
+		wantID    string
+		wantEntry bool
+	}{
+		{
+			name:      "agent token",
+			username:  "node",
+			password:  "secret",
+			wantType:  TokenTypeBasic,
+			wantID:    "node",
+			wantEntry: true,
+		},
+		{
+			name:      "bootstrap token",
+			bearer:    "abcdef.0123456789abcdef",
+			reason:    "not authenticated",
+			wantType:  TokenTypeBootstrap,
+			wantID:    "abcdef",
+			wantEntry: true,
+		},
+		{
+			name:      "cloud identity",
+			username:  "node",
+			password:  "cloud-identity:gcp:a.b.c",
+			wantType:  TokenTypeCloudIdentity,
+			wantID:    "gcp",
+			wantEntry: true,
+		},
+		{
+			name: "client certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &bytes.Buffer{}
+			control := &config.Control{Runtime: &config.ControlRuntime{TokenAuditLog: log}}
+			req := httptest.NewRequest("GET", "/v1-"+version.Program+"/config", nil)
+			req.RemoteAddr = "10.0.0.1:12345"
+			req.Header.Set(version.Program+"-Node-Name", "Node1")
+			if tt.password != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+
+			auditTokenAuth(control, req, []string{version.Program + ":agent"}, &user.DefaultInfo{Name: "node"}, tt.reason)
+
+			if !tt.wantEntry {
+				if log.Len() != 0 {
+					t.Fatalf("auditTokenAuth() recorded %q, want nothing", log.String())
+				}
+				return
+			}
+			record := TokenAuditRecord{}
+			if err := json.Unmarshal(log.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record.TokenType != tt.wantType || record.TokenID != tt.wantID {
+				t.Errorf("auditTokenAuth() recorded token %s/%s, want %s/%s", record.TokenType, record.TokenID, tt.wantType, tt.wantID)
+			}
+			if record.SourceIP != "10.0.0.1" || record.NodeName != "node1" {
+				t.Errorf("auditTokenAuth() recorded source %s and node %s, want 10.0.0.1 and node1", record.SourceIP, record.NodeName)
+			}
+			wantResult := TokenAuditSuccess
+			if tt.reason != "" {
+				wantResult = TokenAuditFailure
+			}
+			if record.Result != wantResult {
+				t.Errorf("auditTokenAuth() recorded result %s, want %s", record.Result, wantResult)
+			}
+			if tt.password != "" && bytes.Contains(log.Bytes(), []byte(tt.password)) {
+				t.Errorf("auditTokenAuth() recorded token secret")
+			}
+		})
+	}
+}
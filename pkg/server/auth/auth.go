@@ -2,14 +2,20 @@ package auth
 
 import (
 	"errors"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 
+	"github.com/k3s-io/k3s/pkg/authlockout"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/mux"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
 	genericapifilters "k8s.io/apiserver/pkg/endpoints/filters"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/server"
@@ -34,7 +40,8 @@ func hasRole(mustRoles []string, roles []string) bool {
 	return false
 }
 
-// doAuth calls the cluster's authenticator to validate that the client has at least one of the listed roles
+// doAuth calls the cluster's authenticator to validate that the client has at least one of the listed roles.
+// If roles is nil, any authenticated client is allowed.
 func doAuth(roles []string, serverConfig *config.Control, next http.Handler, rw http.ResponseWriter, req *http.Request) {
 	switch {
 	case serverConfig == nil:
@@ -48,17 +55,39 @@ func doAuth(roles []string, serverConfig *config.Control, next http.Handler, rw
 	}
 
 	resp, ok, err := serverConfig.Runtime.Authenticator.AuthenticateRequest(req)
+	if err != nil || !ok {
+		// Requests that authenticate are never rejected, so that clients sharing an address with a locked out
+		// client can still authenticate; only failed attempts from a locked out client are rejected.
+		if retryAfter, locked := serverConfig.Runtime.AuthLockout.Check(req); locked {
+			auditTokenAuth(serverConfig, req, roles, nil, "client locked out after repeated authentication failures")
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			util.SendError(errors.New("too many failed authentication attempts"), rw, req, http.StatusTooManyRequests)
+			return
+		}
+		serverConfig.Runtime.AuthLockout.Failure(req, authlockout.ReasonToken)
+	}
+
 	if err != nil {
 		logrus.Errorf("Failed to authenticate request from %s: %v", req.RemoteAddr, err)
+		auditTokenAuth(serverConfig, req, roles, nil, "authentication failed")
 		util.SendError(errors.New("not authorized"), rw, req, http.StatusUnauthorized)
 		return
 	}
 
-	if !ok || !hasRole(roles, resp.User.GetGroups()) {
+	if !ok {
+		auditTokenAuth(serverConfig, req, roles, nil, "not authenticated")
+		util.SendError(errors.New("forbidden"), rw, req, http.StatusForbidden)
+		return
+	}
+
+	if roles != nil && !hasRole(roles, resp.User.GetGroups()) {
+		auditTokenAuth(serverConfig, req, roles, resp.User, "user does not have a requested role")
 		util.SendError(errors.New("forbidden"), rw, req, http.StatusForbidden)
 		return
 	}
 
+	auditTokenAuth(serverConfig, req, roles, resp.User, "")
+
 	ctx := apirequest.WithUser(req.Context(), resp.User)
 	req = req.WithContext(ctx)
 	next.ServeHTTP(rw, req)
@@ -74,6 +103,71 @@ func HasRole(serverConfig *config.Control, roles ...string) mux.MiddlewareFunc {
 	}
 }
 
+// HasRoleOrAccess returns a middleware function that validates that the request is being made with at least
+// one of the listed roles, or by a user that is authorized by Kubernetes RBAC to get the request path as a
+// non-resource URL. This allows read-only endpoints to be accessed by external clients using service account
+// tokens, without sharing the cluster join token.
+func HasRoleOrAccess(serverConfig *config.Control, roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			doAuth(nil, serverConfig, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				info, _ := apirequest.UserFrom(req.Context())
+				if !hasRole(roles, info.GetGroups()) {
+					if allowed, err := nonResourceAccess(req, serverConfig, info); err != nil || !allowed {
+						if err != nil {
+							logrus.Errorf("Failed to authorize request from %s: %v", req.RemoteAddr, err)
+						}
+						util.SendError(errors.New("forbidden"), rw, req, http.StatusForbidden)
+						return
+					}
+				}
+				next.ServeHTTP(rw, req)
+			}), rw, req)
+		})
+	}
+}
+
+// nonResourceAccess makes a SubjectAccessReview request to check if the user is allowed to get the request path.
+func nonResourceAccess(req *http.Request, serverConfig *config.Control, info user.Info) (bool, error) {
+	if serverConfig.Runtime.K8s == nil {
+		return false, util.ErrCoreNotReady
+	}
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range info.GetExtra() {
+		extra[k] = v
+	}
+	sar, err := serverConfig.Runtime.K8s.AuthorizationV1().SubjectAccessReviews().Create(req.Context(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: req.URL.Path,
+				Verb: "get",
+			},
+			User:   info.GetName(),
+			UID:    info.GetUID(),
+			Groups: info.GetGroups(),
+			Extra:  extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return sar.Status.Allowed, nil
+}
+
+// DenyRole returns a middleware function that rejects requests made with any of the listed roles.
+// It must be used after a middleware that authenticates the request.
+func DenyRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if info, ok := apirequest.UserFrom(req.Context()); !ok || hasRole(roles, info.GetGroups()) {
+				util.SendError(errors.New("forbidden"), rw, req, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
 // IsLocalOrHasRole returns a middleware function that validates that the request
 // is from a local client or has at least one of the listed roles.
 func IsLocalOrHasRole(serverConfig *config.Control, roles ...string) mux.MiddlewareFunc {
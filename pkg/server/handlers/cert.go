@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/bootstrap"
 	"github.com/k3s-io/k3s/pkg/cluster"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -34,6 +35,7 @@ func CACertReplace(control *config.Control) http.HandlerFunc {
 			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
 			return
 		}
+		auditlog.Annotate(req, "certificate rotate-ca", "")
 		force, _ := strconv.ParseBool(req.FormValue("force"))
 		if err := caCertReplace(control, req.Body, force); err != nil {
 			util.SendErrorWithID(err, "certificate", resp, req, http.StatusInternalServerError)
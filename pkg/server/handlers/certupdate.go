@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/k3s-io/k3s/pkg/agent/certupdate"
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	certutil "github.com/rancher/dynamiclistener/cert"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/keyutil"
+)
+
+// NodeCertRequest is sent by the CLI to request that kubelet certificates be re-issued for a node.
+type NodeCertRequest struct {
+	Name string `json:"name"`
+}
+
+// NodeCertRotate re-issues kubelet client and serving certificates for a node, and pushes them to the
+// agent through its tunnel session. The agent must be connected to the server handling the request.
+// Certificates previously issued to the node are not revoked, and remain valid until they expire.
+func NodeCertRotate(ctx context.Context, control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		nodeReq := NodeCertRequest{}
+		if err := json.NewDecoder(io.LimitReader(req.Body, 1024*1024)).Decode(&nodeReq); err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		if nodeReq.Name == "" {
+			util.SendError(errors.New("node name is required"), resp, req, http.StatusBadRequest)
+			return
+		}
+		auditlog.Annotate(req, "certificate rotate", "node:"+nodeReq.Name)
+
+		dialer, ok := control.Runtime.Tunnel.(agentDialer)
+		if !ok || control.Runtime.Core == nil {
+			util.SendError(errors.New("tunnel server is not available"), resp, req, http.StatusServiceUnavailable)
+			return
+		}
+		node, err := control.Runtime.Core.Core().V1().Node().Cache().Get(nodeReq.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				util.SendError(errors.New("node "+nodeReq.Name+" not found"), resp, req, http.StatusNotFound)
+			} else {
+				util.SendError(err, resp, req, http.StatusInternalServerError)
+			}
+			return
+		}
+		if !dialer.HasSession(node.Name) {
+			util.SendError(errors.New("agent "+node.Name+" is not connected to this server"), resp, req, http.StatusConflict)
+			return
+		}
+
+		update, err := nodeCertUpdate(control, node)
+		if err != nil {
+			util.SendErrorWithID(err, "certificate", resp, req, http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(update)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusInternalServerError)
+			return
+		}
+		if err := updateAgent(ctx, dialer, node.Name, certupdate.Address, body); err != nil {
+			util.SendError(errors.WithMessagef(err, "failed to push certificates to agent %s", node.Name), resp, req, http.StatusBadGateway)
+			return
+		}
+		logrus.Infof("certificate: Pushed re-issued kubelet certificates to agent %s", node.Name)
+		resp.WriteHeader(http.StatusOK)
+	})
+}
+
+// nodeCertUpdate generates new private keys, and signs kubelet client and serving certificates for the node.
+// The serving certificate is valid for the node's addresses, as reported in the node status.
+func nodeCertUpdate(control *config.Control, node *corev1.Node) (*certupdate.Update, error) {
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}
+	for _, address := range node.Status.Addresses {
+		if address.Type != corev1.NodeInternalIP && address.Type != corev1.NodeExternalIP {
+			continue
+		}
+		if ip := net.ParseIP(address.Address); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	clientCert, clientKey, err := signNewKey(control.Runtime.ClientCA, control.Runtime.ClientCAKey, clientKubeletCertConfig(node.Name))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign kubelet client certificate")
+	}
+	servingCert, servingKey, err := signNewKey(control.Runtime.ServerCA, control.Runtime.ServerCAKey, servingKubeletCertConfig(node.Name, ips))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign kubelet serving certificate")
+	}
+	return &certupdate.Update{
+		ClientCert:  string(clientCert),
+		ClientKey:   string(clientKey),
+		ServingCert: string(servingCert),
+		ServingKey:  string(servingKey),
+	}, nil
+}
+
+// signNewKey generates a new private key, and signs a certificate for it. The PEM encoded
+// certificate and CA bundle are returned, along with the PEM encoded private key.
+func signNewKey(caCertFile, caKeyFile string, certConfig certutil.Config) ([]byte, []byte, error) {
+	caCerts, caKey, err := getCACertAndKey(caCertFile, caKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := certutil.NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := certutil.NewSignedCert(certConfig, key, caCerts[0], caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBytes, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return util.EncodeCertsPEM(cert, caCerts), keyBytes, nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/agent/certupdate"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	testutil "github.com/k3s-io/k3s/tests"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitNodeCertUpdate(t *testing.T) {
+	g := NewWithT(t)
+	control := &config.Control{DataDir: t.TempDir()}
+	g.Expect(testutil.GenerateRuntime(control)).To(Succeed())
+	defer testutil.CleanupDataDir(control)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "k3s-agent-1"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+				{Type: corev1.NodeHostName, Address: "k3s-agent-1"},
+			},
+		},
+	}
+
+	update, err := nodeCertUpdate(control, node)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// the agent accepts the certificates for its own node name, but not for any other node
+	g.Expect(certupdate.Validate(update, node.Name, control.Runtime.ClientCA, control.Runtime.ServerCA)).To(Succeed())
+	g.Expect(certupdate.Validate(update, "k3s-agent-2", control.Runtime.ClientCA, control.Runtime.ServerCA)).ToNot(Succeed())
+
+	// the client and serving certificates must be signed by the correct CAs
+	g.Expect(certupdate.Validate(update, node.Name, control.Runtime.ServerCA, control.Runtime.ServerCA)).ToNot(Succeed())
+	g.Expect(certupdate.Validate(update, node.Name, control.Runtime.ClientCA, control.Runtime.ClientCA)).ToNot(Succeed())
+
+	// keys must match their certificates
+	swapped := *update
+	swapped.ClientKey, swapped.ServingKey = update.ServingKey, update.ClientKey
+	g.Expect(certupdate.Validate(&swapped, node.Name, control.Runtime.ClientCA, control.Runtime.ServerCA)).ToNot(Succeed())
+
+	block, _ := pem.Decode([]byte(update.ServingCert))
+	g.Expect(block).ToNot(BeNil())
+	cert, err := x509.ParseCertificate(block.Bytes)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cert.DNSNames).To(ConsistOf(node.Name, "localhost"))
+	g.Expect(cert.IPAddresses).To(ConsistOf(
+		net.ParseIP("127.0.0.1").To4(),
+		net.ParseIP("::1"),
+		net.ParseIP("10.0.0.10").To4(),
+		net.ParseIP("203.0.113.10").To4(),
+	))
+}
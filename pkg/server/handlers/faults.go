@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/faults"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// Faults handles requests to the fault injection API. GET returns the active faults, POST injects the fault
+// described by the request body, and DELETE clears all active faults. The API is only available if fault
+// injection was enabled when the server was started.
+func Faults(control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		injector := control.Runtime.Faults
+		if injector == nil {
+			util.SendError(errors.New("fault injection is not enabled"), resp, req, http.StatusNotFound)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				util.SendError(err, resp, req, http.StatusBadRequest)
+				return
+			}
+			faultReq := faults.Request{}
+			if err := json.Unmarshal(b, &faultReq); err != nil {
+				util.SendError(err, resp, req, http.StatusBadRequest)
+				return
+			}
+			if err := injector.Inject(faultReq); err != nil {
+				util.SendError(err, resp, req, http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			injector.Clear()
+		default:
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+
+		b, err := json.Marshal(injector.Status())
+		if err != nil {
+			util.SendErrorWithID(err, "faults", resp, req, http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(b)
+	})
+}
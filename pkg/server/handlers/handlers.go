@@ -17,6 +17,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/etcd"
 	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/signals"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/util/errors"
 	"github.com/k3s-io/k3s/pkg/version"
@@ -33,6 +34,8 @@ import (
 	typeddiscoveryv1 "k8s.io/client-go/kubernetes/typed/discovery/v1"
 )
 
+var errShuttingDown = errors.New("server is shutting down")
+
 func CACerts(config *config.Control) http.Handler {
 	var ca []byte
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
@@ -79,14 +82,7 @@ func ServingKubeletCert(control *config.Control, auth nodepassword.NodeAuthValid
 			}
 		}
 
-		signAndSend(resp, req, control.Runtime.ServerCA, control.Runtime.ServerCAKey, control.Runtime.ServingKubeletKey, certutil.Config{
-			CommonName: nodeName,
-			Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-			AltNames: certutil.AltNames{
-				DNSNames: []string{nodeName, "localhost"},
-				IPs:      ips,
-			},
-		})
+		signAndSend(resp, req, control.Runtime.ServerCA, control.Runtime.ServerCAKey, control.Runtime.ServingKubeletKey, servingKubeletCertConfig(nodeName, ips))
 	})
 }
 
@@ -97,14 +93,32 @@ func ClientKubeletCert(control *config.Control, auth nodepassword.NodeAuthValida
 			util.SendError(err, resp, req, errCode)
 			return
 		}
-		signAndSend(resp, req, control.Runtime.ClientCA, control.Runtime.ClientCAKey, control.Runtime.ClientKubeletKey, certutil.Config{
-			CommonName:   "system:node:" + nodeName,
-			Organization: []string{user.NodesGroup},
-			Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-		})
+		signAndSend(resp, req, control.Runtime.ClientCA, control.Runtime.ClientCAKey, control.Runtime.ClientKubeletKey, clientKubeletCertConfig(nodeName))
 	})
 }
 
+// servingKubeletCertConfig returns the configuration for a kubelet serving certificate
+// valid for the node name, localhost, and the provided IP addresses.
+func servingKubeletCertConfig(nodeName string, ips []net.IP) certutil.Config {
+	return certutil.Config{
+		CommonName: nodeName,
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		AltNames: certutil.AltNames{
+			DNSNames: []string{nodeName, "localhost"},
+			IPs:      ips,
+		},
+	}
+}
+
+// clientKubeletCertConfig returns the configuration for a kubelet client certificate for the node name.
+func clientKubeletCertConfig(nodeName string) certutil.Config {
+	return certutil.Config{
+		CommonName:   "system:node:" + nodeName,
+		Organization: []string{user.NodesGroup},
+		Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
 func ClientKubeProxyCert(control *config.Control) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 		signAndSend(resp, req, control.Runtime.ClientCA, control.Runtime.ClientCAKey, control.Runtime.ClientKubeProxyKey, certutil.Config{
@@ -201,7 +215,16 @@ func Readyz(control *config.Control) http.Handler {
 
 func Bootstrap(control *config.Control) http.Handler {
 	if control.Runtime.HTTPBootstrap != nil {
-		return control.Runtime.HTTPBootstrap
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			// new servers are not allowed to join once shutdown has started, but in-progress requests are allowed to complete
+			release, ok := signals.Hold(signals.PhaseJoins)
+			defer release()
+			if !ok {
+				util.SendError(errShuttingDown, resp, req, http.StatusServiceUnavailable)
+				return
+			}
+			control.Runtime.HTTPBootstrap.ServeHTTP(resp, req)
+		})
 	}
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 		logrus.Warnf("Received HTTP bootstrap request from %s, but embedded etcd is not enabled.", req.RemoteAddr)
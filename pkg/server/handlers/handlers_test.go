@@ -1750,7 +1750,7 @@ func getMockedControl(t *testing.T) (*config.Control, context.CancelFunc) {
 	control.Runtime.Event = util.BuildControllerEventRecorder(ctx, control.Runtime.K8s, version.Program+"-supervisor", metav1.NamespaceAll)
 
 	// start the node password controller
-	err = nodepassword.Register(ctx, control.Runtime.K8s, coreFactory.Core().V1().Secret(), coreFactory.Core().V1().Node())
+	err = nodepassword.Register(ctx, control.Runtime.K8s, coreFactory.Core().V1().Secret(), coreFactory.Core().V1().Node(), control.GetListPageSize())
 	g.Expect(err).ToNot(HaveOccurred())
 
 	// add authenticator
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cluster/managed"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// healthTimeout is the maximum time allowed for each individual health check.
+const healthTimeout = 10 * time.Second
+
+// ClusterHealth is the aggregated health of the cluster, as observed by the server that handled
+// the request. Etcd members and nodes are only included if the cluster view was requested.
+type ClusterHealth struct {
+	Healthy   bool                   `json:"healthy"`
+	Server    string                 `json:"server"`
+	APIServer ComponentHealth        `json:"apiserver"`
+	Datastore DatastoreHealth        `json:"datastore"`
+	Etcd      []managed.MemberHealth `json:"etcd,omitempty"`
+	Nodes     []NodeHealth           `json:"nodes,omitempty"`
+	Errors    []string               `json:"errors,omitempty"`
+}
+
+// ComponentHealth contains the health of a single component.
+// Error is set if the component is not healthy.
+type ComponentHealth struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DatastoreHealth contains the health of the datastore, and the latency of a read from it.
+type DatastoreHealth struct {
+	Healthy bool            `json:"healthy"`
+	Latency metav1.Duration `json:"latency,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// NodeHealth contains the health of a single node. A node is healthy if it is Ready,
+// and its agent has a tunnel connected to the server that handled the request.
+type NodeHealth struct {
+	Name            string `json:"name"`
+	Healthy         bool   `json:"healthy"`
+	ControlPlane    bool   `json:"controlPlane,omitempty"`
+	Ready           bool   `json:"ready"`
+	TunnelConnected bool   `json:"tunnelConnected"`
+}
+
+// sessionChecker is implemented by the tunnel server, and is used to check if the agent on a node
+// is connected to this server.
+type sessionChecker interface {
+	HasSession(nodeName string) bool
+}
+
+// Health handles requests for the aggregated health of the cluster. By default only the local
+// apiserver and datastore are checked; if the cluster query parameter is set to true, the health
+// of all etcd members and nodes is also included. The response status is 200 even if the cluster
+// is unhealthy, so that the full health document can be returned to the client.
+func Health(control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		cluster, _ := strconv.ParseBool(req.URL.Query().Get("cluster"))
+
+		health := getClusterHealth(req.Context(), control, cluster)
+		b, err := json.Marshal(health)
+		if err != nil {
+			util.SendErrorWithID(err, "health", resp, req, http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(b)
+	})
+}
+
+func getClusterHealth(ctx context.Context, control *config.Control, cluster bool) *ClusterHealth {
+	health := &ClusterHealth{
+		Server:    control.ServerNodeName,
+		APIServer: apiserverHealth(ctx, control),
+		Datastore: datastoreHealth(ctx, control),
+	}
+	health.Healthy = health.APIServer.Healthy && health.Datastore.Healthy
+
+	if !cluster {
+		return health
+	}
+
+	// HTTPBootstrap is only set when the datastore is managed etcd
+	if control.Runtime.HTTPBootstrap != nil {
+		etcdCtx, cancel := context.WithTimeout(ctx, healthTimeout)
+		defer cancel()
+		members, err := etcd.MemberHealth(etcdCtx, control)
+		if err != nil {
+			health.Healthy = false
+			health.Errors = append(health.Errors, err.Error())
+		}
+		for _, member := range members {
+			health.Healthy = health.Healthy && member.Healthy
+		}
+		health.Etcd = members
+	}
+
+	nodes, err := nodeHealth(control)
+	if err != nil {
+		health.Healthy = false
+		health.Errors = append(health.Errors, errors.WithMessage(err, "failed to list nodes").Error())
+	}
+	for _, node := range nodes {
+		health.Healthy = health.Healthy && node.Healthy
+	}
+	health.Nodes = nodes
+	return health
+}
+
+// apiserverHealth checks the readyz endpoint of the apiserver used by the supervisor.
+func apiserverHealth(ctx context.Context, control *config.Control) ComponentHealth {
+	if control.Runtime.K8s == nil {
+		return ComponentHealth{Error: util.ErrCoreNotReady.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	if _, err := control.Runtime.K8s.Discovery().RESTClient().Get().AbsPath("/readyz").DoRaw(ctx); err != nil {
+		return ComponentHealth{Error: err.Error()}
+	}
+	return ComponentHealth{Healthy: true}
+}
+
+// datastoreHealth checks the latency of a read from the datastore.
+func datastoreHealth(ctx context.Context, control *config.Control) DatastoreHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	latency, err := etcd.DatastoreLatency(ctx, control)
+	if err != nil {
+		return DatastoreHealth{Error: err.Error()}
+	}
+	return DatastoreHealth{Healthy: true, Latency: metav1.Duration{Duration: latency}}
+}
+
+// nodeHealth returns the health of all nodes in the cluster, sorted by name.
+func nodeHealth(control *config.Control) ([]NodeHealth, error) {
+	if control.Runtime.Core == nil {
+		return nil, util.ErrCoreNotReady
+	}
+	nodeList, err := control.Runtime.Core.Core().V1().Node().Cache().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	tunnel, _ := control.Runtime.Tunnel.(sessionChecker)
+
+	nodes := []NodeHealth{}
+	for _, node := range nodeList {
+		health := NodeHealth{
+			Name:         node.Name,
+			ControlPlane: node.Labels[util.ControlPlaneRoleLabelKey] == "true",
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				health.Ready = condition.Status == corev1.ConditionTrue
+			}
+		}
+		if tunnel != nil {
+			health.TunnelConnected = tunnel.HasSession(node.Name)
+		}
+		health.Healthy = health.Ready && health.TunnelConnected
+		nodes = append(nodes, health)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/util/services"
+	"github.com/k3s-io/k3s/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// inventoryCacheTTL is the length of time that a generated inventory document is served from cache.
+// Fleet management pollers may scrape many clusters frequently; caching ensures that the cost to the
+// cluster does not scale with the number of pollers.
+const inventoryCacheTTL = 30 * time.Second
+
+// ClusterInventory is a read-only summary of the cluster, intended for external fleet management tools.
+// Certificates are those of the server that handled the request.
+type ClusterInventory struct {
+	Server       string                 `json:"server"`
+	Version      string                 `json:"version"`
+	GeneratedAt  metav1.Time            `json:"generatedAt"`
+	Nodes        []NodeInventory        `json:"nodes"`
+	Addons       []AddonInventory       `json:"addons"`
+	Snapshots    []SnapshotInventory    `json:"snapshots,omitempty"`
+	Certificates []CertificateInventory `json:"certificates"`
+	Errors       []string               `json:"errors,omitempty"`
+}
+
+// NodeInventory contains the version information and readiness of a single node.
+type NodeInventory struct {
+	Name                    string   `json:"name"`
+	Roles                   []string `json:"roles,omitempty"`
+	Ready                   bool     `json:"ready"`
+	KubeletVersion          string   `json:"kubeletVersion"`
+	ContainerRuntimeVersion string   `json:"containerRuntimeVersion"`
+	OSImage                 string   `json:"osImage"`
+	KernelVersion           string   `json:"kernelVersion"`
+	Architecture            string   `json:"architecture"`
+}
+
+// AddonInventory contains the state of a single addon manifest. An addon is applied once
+// the checksum of the most recently applied manifest has been recorded.
+type AddonInventory struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Source    string `json:"source"`
+	Checksum  string `json:"checksum,omitempty"`
+	Applied   bool   `json:"applied"`
+}
+
+// SnapshotInventory contains the location and state of a single etcd snapshot.
+type SnapshotInventory struct {
+	Name         string       `json:"name"`
+	Node         string       `json:"node"`
+	Location     string       `json:"location"`
+	Size         int64        `json:"size,omitempty"`
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+	Phase        string       `json:"phase,omitempty"`
+}
+
+// CertificateInventory contains the expiration of a single certificate.
+type CertificateInventory struct {
+	Filename string      `json:"filename"`
+	Subject  string      `json:"subject"`
+	Expires  metav1.Time `json:"expires"`
+	Status   string      `json:"status"`
+}
+
+// inventoryCache holds the most recently generated inventory document, and its ETag.
+type inventoryCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	generate  func() *ClusterInventory
+	body      []byte
+	etag      string
+	generated time.Time
+}
+
+// get returns the cached inventory document and its ETag, generating a new document if the cache has expired.
+// The ETag does not include the generation time, so that it only changes when the content of the document changes;
+// if the content is unchanged, the previously generated document is retained.
+func (c *inventoryCache) get() ([]byte, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body != nil && time.Since(c.generated) < c.ttl {
+		return c.body, c.etag, nil
+	}
+
+	inventory := c.generate()
+	generatedAt := inventory.GeneratedAt
+	inventory.GeneratedAt = metav1.Time{}
+	b, err := json.Marshal(inventory)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(b)
+	c.generated = time.Now()
+	if etag := strconv.Quote(hex.EncodeToString(sum[:16])); etag != c.etag {
+		inventory.GeneratedAt = generatedAt
+		if c.body, err = json.Marshal(inventory); err != nil {
+			c.etag = ""
+			return nil, "", err
+		}
+		c.etag = etag
+	}
+	return c.body, c.etag, nil
+}
+
+// Inventory handles requests for the cluster inventory document. Documents are cached for a short period, and
+// include an ETag so that pollers can send If-None-Match to avoid transferring an unchanged document.
+func Inventory(control *config.Control) http.Handler {
+	return inventoryHandler(&inventoryCache{
+		ttl:      inventoryCacheTTL,
+		generate: func() *ClusterInventory { return getClusterInventory(control) },
+	})
+}
+
+func inventoryHandler(cache *inventoryCache) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		b, etag, err := cache.get()
+		if err != nil {
+			util.SendErrorWithID(err, "inventory", resp, req, http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("ETag", etag)
+		resp.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(inventoryCacheTTL.Seconds())))
+		if req.Header.Get("If-None-Match") == etag {
+			resp.WriteHeader(http.StatusNotModified)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(b)
+	})
+}
+
+func getClusterInventory(control *config.Control) *ClusterInventory {
+	inventory := &ClusterInventory{
+		Server:      control.ServerNodeName,
+		Version:     version.Version,
+		GeneratedAt: metav1.Now(),
+	}
+
+	var err error
+	if inventory.Nodes, err = nodeInventory(control); err != nil {
+		inventory.Errors = append(inventory.Errors, errors.WithMessage(err, "failed to list nodes").Error())
+	}
+	if inventory.Addons, err = addonInventory(control); err != nil {
+		inventory.Errors = append(inventory.Errors, errors.WithMessage(err, "failed to list addons").Error())
+	}
+	// HTTPBootstrap is only set when the datastore is managed etcd
+	if control.Runtime.HTTPBootstrap != nil {
+		if inventory.Snapshots, err = snapshotInventory(control); err != nil {
+			inventory.Errors = append(inventory.Errors, errors.WithMessage(err, "failed to list etcd snapshots").Error())
+		}
+	}
+	if inventory.Certificates, err = certificateInventory(control); err != nil {
+		inventory.Errors = append(inventory.Errors, errors.WithMessage(err, "failed to list certificates").Error())
+	}
+	return inventory
+}
+
+// nodeInventory returns the inventory of all nodes in the cluster, sorted by name.
+func nodeInventory(control *config.Control) ([]NodeInventory, error) {
+	if control.Runtime.Core == nil {
+		return nil, util.ErrCoreNotReady
+	}
+	nodeList, err := control.Runtime.Core.Core().V1().Node().Cache().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []NodeInventory{}
+	for _, node := range nodeList {
+		inventory := NodeInventory{
+			Name:                    node.Name,
+			KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+			ContainerRuntimeVersion: node.Status.NodeInfo.ContainerRuntimeVersion,
+			OSImage:                 node.Status.NodeInfo.OSImage,
+			KernelVersion:           node.Status.NodeInfo.KernelVersion,
+			Architecture:            node.Status.NodeInfo.Architecture,
+		}
+		for _, label := range []string{util.ControlPlaneRoleLabelKey, util.ETCDRoleLabelKey} {
+			if node.Labels[label] == "true" {
+				inventory.Roles = append(inventory.Roles, filepath.Base(label))
+			}
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				inventory.Ready = condition.Status == corev1.ConditionTrue
+			}
+		}
+		nodes = append(nodes, inventory)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// addonInventory returns the state of all addons, sorted by namespace and name.
+func addonInventory(control *config.Control) ([]AddonInventory, error) {
+	if control.Runtime.K3s == nil {
+		return nil, util.ErrCoreNotReady
+	}
+	addonList, err := control.Runtime.K3s.K3s().V1().Addon().Cache().List(metav1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	addons := []AddonInventory{}
+	for _, addon := range addonList {
+		addons = append(addons, AddonInventory{
+			Name:      addon.Name,
+			Namespace: addon.Namespace,
+			Source:    addon.Spec.Source,
+			Checksum:  addon.Spec.Checksum,
+			Applied:   addon.Spec.Checksum != "",
+		})
+	}
+	sort.Slice(addons, func(i, j int) bool {
+		if addons[i].Namespace != addons[j].Namespace {
+			return addons[i].Namespace < addons[j].Namespace
+		}
+		return addons[i].Name < addons[j].Name
+	})
+	return addons, nil
+}
+
+// snapshotInventory returns all etcd snapshots, sorted by creation time.
+func snapshotInventory(control *config.Control) ([]SnapshotInventory, error) {
+	if control.Runtime.K3s == nil {
+		return nil, util.ErrCoreNotReady
+	}
+	snapshotList, err := control.Runtime.K3s.K3s().V1().ETCDSnapshotFile().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []SnapshotInventory{}
+	for _, snapshot := range snapshotList.Items {
+		inventory := SnapshotInventory{
+			Name:         snapshot.Spec.SnapshotName,
+			Node:         snapshot.Spec.NodeName,
+			Location:     snapshot.Spec.Location,
+			CreationTime: snapshot.Status.CreationTime,
+			Phase:        string(snapshot.Status.Phase),
+		}
+		if snapshot.Status.Size != nil {
+			inventory.Size = snapshot.Status.Size.Value()
+		}
+		snapshots = append(snapshots, inventory)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].CreationTime == nil || snapshots[j].CreationTime == nil {
+			return snapshots[j].CreationTime != nil
+		}
+		return snapshots[i].CreationTime.Before(snapshots[j].CreationTime)
+	})
+	return snapshots, nil
+}
+
+// certificateInventory returns the expiration of all certificates on this server, sorted by expiration.
+func certificateInventory(control *config.Control) ([]CertificateInventory, error) {
+	fileMap, err := services.FilesForServices(*control, slices.Concat(services.All, services.CA))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	warn := now.Add(time.Hour * 24 * config.CertificateRenewDays)
+	seen := map[string]bool{}
+	certs := []CertificateInventory{}
+	for _, files := range fileMap {
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			fileCerts, err := certutil.CertsFromFile(file)
+			if err != nil {
+				continue
+			}
+			for _, cert := range fileCerts {
+				certs = append(certs, CertificateInventory{
+					Filename: filepath.Base(file),
+					Subject:  cert.Subject.CommonName,
+					Expires:  metav1.Time{Time: cert.NotAfter},
+					Status:   util.GetCertStatus(cert, now, warn),
+				})
+			}
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool { return certs[i].Expires.Before(&certs[j].Expires) })
+	return certs, nil
+}
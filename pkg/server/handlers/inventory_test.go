@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitInventoryCache(t *testing.T) {
+	clusterVersion := "v1.0.0"
+	cache := &inventoryCache{
+		generate: func() *ClusterInventory {
+			return &ClusterInventory{Version: clusterVersion, GeneratedAt: metav1.Now()}
+		},
+	}
+
+	body, etag, err := cache.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	unchangedBody, unchangedETag, err := cache.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchangedETag != etag || string(unchangedBody) != string(body) {
+		t.Errorf("get() with unchanged content = %s %q, want %s %q", unchangedBody, unchangedETag, body, etag)
+	}
+
+	clusterVersion = "v1.0.1"
+	if _, changedETag, err := cache.get(); err != nil {
+		t.Fatal(err)
+	} else if changedETag == etag {
+		t.Errorf("get() with changed content returned unchanged ETag %q", etag)
+	}
+}
+
+func Test_UnitInventoryHandler(t *testing.T) {
+	cache := &inventoryCache{
+		ttl:      time.Minute,
+		generate: func() *ClusterInventory { return &ClusterInventory{Version: "v1.0.0"} },
+	}
+	_, etag, err := cache.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := inventoryHandler(cache)
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{name: "get", method: http.MethodGet, wantCode: http.StatusOK},
+		{name: "get matching etag", method: http.MethodGet, ifNoneMatch: etag, wantCode: http.StatusNotModified},
+		{name: "get stale etag", method: http.MethodGet, ifNoneMatch: `"stale"`, wantCode: http.StatusOK},
+		{name: "post", method: http.MethodPost, wantCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1-k3s/inventory", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			if resp.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/ipam"
+	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+)
+
+// NodeIPAM handles requests from agents for addresses allocated by the external IPAM webhook.
+// The node IPs detected by the agent are passed to the webhook, and the webhook response is
+// returned to the agent.
+func NodeIPAM(control *config.Control, auth nodepassword.NodeAuthValidator) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if control.IPAMWebhookURL == "" {
+			util.SendError(errors.New("external IPAM is not enabled"), resp, req, http.StatusNotFound)
+			return
+		}
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+
+		nodeName, errCode, err := auth(req)
+		if err != nil {
+			util.SendError(err, resp, req, errCode)
+			return
+		}
+
+		var ips []net.IP
+		if nodeIP := req.Header.Get(version.Program + "-Node-IP"); nodeIP != "" {
+			for _, v := range strings.Split(nodeIP, ",") {
+				ip := net.ParseIP(v)
+				if ip == nil {
+					util.SendError(fmt.Errorf("invalid node IP address %s", v), resp, req, http.StatusBadRequest)
+					return
+				}
+				ips = append(ips, ip)
+			}
+		}
+
+		webhook := ipam.NewWebhook(control.IPAMWebhookURL, control.ClusterIPRanges)
+		ipamResp, err := webhook.Allocate(req.Context(), nodeName, ips)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusBadGateway)
+			return
+		}
+
+		resp.Header().Set("content-type", "application/json")
+		if err := json.NewEncoder(resp).Encode(ipamResp); err != nil {
+			util.SendError(errors.WithMessage(err, "failed to encode IPAM response"), resp, req, http.StatusInternalServerError)
+		}
+	})
+}
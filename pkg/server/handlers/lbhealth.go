@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// lbHealthTimeout is the maximum time allowed for each individual check. External load-balancers
+	// typically time out health checks after a few seconds, so checks are run in parallel with a
+	// short timeout.
+	lbHealthTimeout = 5 * time.Second
+
+	// lbHealthCacheTime is the time for which check results are reused. As the endpoints are not
+	// authenticated, checks are run at most once per interval, and only one set of checks is run at
+	// a time, regardless of how many requests are received.
+	lbHealthCacheTime = time.Second
+)
+
+// lbHealthCheck is a single check run by the load-balancer health endpoints.
+// Checks that are not required are reported, but do not cause the endpoint to fail.
+type lbHealthCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+	err      error
+}
+
+// LBReadyz handles readiness checks from external load-balancers in front of the apiserver. The
+// apiserver readyz endpoint, datastore, scheduler, and controller-manager are checked, and
+// the components that must be healthy for the server to be considered ready are set by the
+// lb-health-strictness option. The response format matches that of the apiserver readyz
+// endpoint, including support for the verbose and exclude query parameters, although required
+// checks cannot be excluded. As the endpoint is not authenticated, the reason for any failed
+// check is only logged, not returned.
+func LBReadyz(control *config.Control) http.Handler {
+	return lbHealthHandler("readyz", func() []*lbHealthCheck {
+		datastoreRequired := control.LBHealthStrictness != config.LBHealthStrictnessAPIServer
+		allRequired := control.LBHealthStrictness == config.LBHealthStrictnessAll
+		checks := []*lbHealthCheck{
+			{name: "apiserver", required: true, check: apiserverCheck(control, "/readyz")},
+			{name: "etcd", required: datastoreRequired, check: func(ctx context.Context) error {
+				_, err := etcd.DatastoreLatency(ctx, control)
+				return err
+			}},
+		}
+		if port := componentPort(control.ExtraSchedulerArgs, config.SchedulerSecurePort); !control.DisableScheduler && port != 0 {
+			checks = append(checks, &lbHealthCheck{name: "scheduler", required: allRequired, check: componentCheck(control, port)})
+		}
+		if port := componentPort(control.ExtraControllerArgs, config.ControllerManagerSecurePort); !control.DisableControllerManager && port != 0 {
+			checks = append(checks, &lbHealthCheck{name: "controller-manager", required: allRequired, check: componentCheck(control, port)})
+		}
+		return checks
+	})
+}
+
+// LBLivez handles liveness checks from external load-balancers in front of the apiserver.
+// Only the apiserver livez endpoint is checked; as with the apiserver's own livez endpoint,
+// datastore health does not affect liveness.
+func LBLivez(control *config.Control) http.Handler {
+	return lbHealthHandler("livez", func() []*lbHealthCheck {
+		return []*lbHealthCheck{
+			{name: "apiserver", required: true, check: apiserverCheck(control, "/livez")},
+		}
+	})
+}
+
+func lbHealthHandler(name string, getChecks func() []*lbHealthCheck) http.Handler {
+	var (
+		mu      sync.Mutex
+		results []*lbHealthCheck
+		checked time.Time
+	)
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Requests received while checks are running wait for the results. The checks are not bound to
+		// the request context, as their results are shared with other requests.
+		mu.Lock()
+		if results == nil || time.Since(checked) > lbHealthCacheTime {
+			results = getChecks()
+			runLBHealthChecks(context.Background(), results)
+			checked = time.Now()
+		}
+		checks := slices.Clone(results)
+		mu.Unlock()
+
+		query := req.URL.Query()
+		checks = slices.DeleteFunc(checks, func(c *lbHealthCheck) bool {
+			return !c.required && slices.Contains(query["exclude"], c.name)
+		})
+
+		healthy := true
+		out := &bytes.Buffer{}
+		for _, c := range checks {
+			switch {
+			case c.err == nil:
+				fmt.Fprintf(out, "[+]%s ok\n", c.name)
+			case c.required:
+				healthy = false
+				fmt.Fprintf(out, "[-]%s failed: reason withheld\n", c.name)
+				logrus.Debugf("Load-balancer %s check %s failed: %v", name, c.name, c.err)
+			default:
+				fmt.Fprintf(out, "[-]%s failed (not required): reason withheld\n", c.name)
+				logrus.Debugf("Load-balancer %s check %s failed: %v", name, c.name, c.err)
+			}
+		}
+
+		code := http.StatusOK
+		if !healthy {
+			code = http.StatusServiceUnavailable
+			fmt.Fprintf(out, "%s check failed\n", name)
+		} else {
+			fmt.Fprintf(out, "%s check passed\n", name)
+		}
+		if _, verbose := query["verbose"]; !verbose && healthy {
+			out.Reset()
+			out.WriteString("ok")
+		}
+		resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		resp.Header().Set("X-Content-Type-Options", "nosniff")
+		resp.WriteHeader(code)
+		resp.Write(out.Bytes())
+	})
+}
+
+// runLBHealthChecks runs all checks in parallel, storing the result in each check.
+func runLBHealthChecks(ctx context.Context, checks []*lbHealthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, lbHealthTimeout)
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.err = c.check(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// apiserverCheck returns a check of the given health endpoint of the local apiserver.
+func apiserverCheck(control *config.Control, path string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if control.DisableAPIServer {
+			return errors.New("apiserver is disabled on this server")
+		}
+		if control.Runtime.K8s == nil {
+			return util.ErrCoreNotReady
+		}
+		_, err := control.Runtime.K8s.Discovery().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+		return err
+	}
+}
+
+// componentCheck returns a check of the healthz endpoint of a control-plane component listening
+// on the given secure port on the loopback address. The component serving certificates are
+// signed by the server CA.
+func componentCheck(control *config.Control, port int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		cacerts, err := os.ReadFile(control.Runtime.ServerCA)
+		if err != nil {
+			return err
+		}
+		u := url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%s:%d", control.Loopback(true), port),
+			Path:   "/healthz",
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := clientaccess.GetHTTPClient(cacerts, "", "").Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", u.String(), resp.Status)
+		}
+		return nil
+	}
+}
+
+// componentPort returns the secure port of a control-plane component, as set by its extra args.
+// Zero is returned if the secure port is disabled.
+func componentPort(extraArgs []string, defaultPort string) int {
+	value := util.ArgValue("secure-port", extraArgs)
+	if value == "" {
+		value = defaultPort
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		port, _ = strconv.Atoi(defaultPort)
+	}
+	return port
+}
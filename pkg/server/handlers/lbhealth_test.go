@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+)
+
+func Test_UnitLBHealthHandler(t *testing.T) {
+	pass := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("failed") }
+	tests := []struct {
+		name     string
+		target   string
+		checks   func() []*lbHealthCheck
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:   "Healthy",
+			target: "/readyz",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{{name: "apiserver", required: true, check: pass}}
+			},
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:   "Healthy verbose",
+			target: "/readyz?verbose",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{{name: "apiserver", required: true, check: pass}}
+			},
+			wantCode: http.StatusOK,
+			wantBody: "[+]apiserver ok\nreadyz check passed\n",
+		},
+		{
+			name:   "Optional check failed",
+			target: "/readyz?verbose",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{
+					{name: "apiserver", required: true, check: pass},
+					{name: "scheduler", check: fail},
+				}
+			},
+			wantCode: http.StatusOK,
+			wantBody: "[+]apiserver ok\n[-]scheduler failed (not required): reason withheld\nreadyz check passed\n",
+		},
+		{
+			name:   "Required check failed",
+			target: "/readyz",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{
+					{name: "apiserver", required: true, check: pass},
+					{name: "etcd", required: true, check: fail},
+				}
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "[+]apiserver ok\n[-]etcd failed: reason withheld\nreadyz check failed\n",
+		},
+		{
+			name:   "Required check excluded",
+			target: "/readyz?exclude=etcd",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{
+					{name: "apiserver", required: true, check: pass},
+					{name: "etcd", required: true, check: fail},
+				}
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "[+]apiserver ok\n[-]etcd failed: reason withheld\nreadyz check failed\n",
+		},
+		{
+			name:   "Optional check excluded",
+			target: "/readyz?verbose&exclude=scheduler",
+			checks: func() []*lbHealthCheck {
+				return []*lbHealthCheck{
+					{name: "apiserver", required: true, check: pass},
+					{name: "scheduler", check: fail},
+				}
+			},
+			wantCode: http.StatusOK,
+			wantBody: "[+]apiserver ok\nreadyz check passed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			lbHealthHandler("readyz", tt.checks).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("lbHealthHandler() code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("lbHealthHandler() body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_UnitLBHealthHandlerCache(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := lbHealthHandler("readyz", func() []*lbHealthCheck {
+		return []*lbHealthCheck{{name: "apiserver", required: true, check: func(context.Context) error {
+			calls.Add(1)
+			<-release
+			return nil
+		}}}
+	})
+
+	// concurrent requests share a single run of the checks
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("lbHealthHandler() code = %d, want %d", rec.Code, http.StatusOK)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("checks ran %d times for concurrent requests, want 1", got)
+	}
+
+	// results are reused until they expire
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if got := calls.Load(); got != 1 {
+		t.Errorf("checks ran %d times within the cache time, want 1", got)
+	}
+	time.Sleep(lbHealthCacheTime + 100*time.Millisecond)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if got := calls.Load(); got != 2 {
+		t.Errorf("checks ran %d times after the cache time, want 2", got)
+	}
+}
+
+func Test_UnitComponentPort(t *testing.T) {
+	tests := []struct {
+		name      string
+		extraArgs []string
+		want      int
+	}{
+		{name: "default", want: 10259},
+		{name: "custom port", extraArgs: []string{"v=2", "secure-port=11259"}, want: 11259},
+		{name: "custom port with hyphens", extraArgs: []string{"--secure-port=11259"}, want: 11259},
+		{name: "disabled", extraArgs: []string{"secure-port=0"}, want: 0},
+		{name: "invalid", extraArgs: []string{"secure-port=bogus"}, want: 10259},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := componentPort(tt.extraArgs, config.SchedulerSecurePort); got != tt.want {
+				t.Errorf("componentPort() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
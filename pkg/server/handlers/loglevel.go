@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/loglevel"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// LogLevel handles requests to temporarily adjust the log level of a component embedded in this server.
+// The request body is a loglevel.Request, and the response is the status of the adjustment.
+func LogLevel() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		levelReq := loglevel.Request{}
+		if err := json.Unmarshal(b, &levelReq); err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		auditlog.Annotate(req, "debug set-log-level", "")
+		status, err := loglevel.Set(levelReq)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+
+		b, err = json.Marshal(status)
+		if err != nil {
+			util.SendErrorWithID(err, "log-level", resp, req, http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(b)
+	})
+}
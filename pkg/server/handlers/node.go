@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+const (
+	NodeActionCordon   = "cordon"
+	NodeActionUncordon = "uncordon"
+	NodeActionDrain    = "drain"
+	NodeActionApprove  = "approve"
+
+	NodeDrainRunning   = "Running"
+	NodeDrainSucceeded = "Succeeded"
+	NodeDrainFailed    = "Failed"
+
+	// DefaultDrainTimeout is the drain timeout used when the request does not set one,
+	// so that a drain cannot run on the server indefinitely.
+	DefaultDrainTimeout = 5 * time.Minute
+)
+
+// NodeRequest is a request to cordon, uncordon, drain, or approve a node. The drain options
+// correspond to the options of the same name for kubectl drain.
+type NodeRequest struct {
+	Action             string        `json:"action"`
+	Name               string        `json:"name"`
+	Force              bool          `json:"force,omitempty"`
+	IgnoreDaemonSets   bool          `json:"ignoreDaemonSets,omitempty"`
+	DeleteEmptyDirData bool          `json:"deleteEmptyDirData,omitempty"`
+	DisableEviction    bool          `json:"disableEviction,omitempty"`
+	GracePeriodSeconds int           `json:"gracePeriodSeconds"`
+	PodSelector        string        `json:"podSelector,omitempty"`
+	Timeout            time.Duration `json:"timeout,omitempty"`
+}
+
+// NodeResponse contains the output of the cordon, uncordon, or drain operation.
+// Drain operations run in the background; the response to a drain request has
+// status Running, and the progress of the drain is retrieved with a GET request
+// for the node name until the status is Succeeded or Failed.
+type NodeResponse struct {
+	Output string `json:"output,omitempty"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// drainOperation tracks the progress of a node drain running in the background.
+type drainOperation struct {
+	mu     sync.Mutex
+	out    bytes.Buffer
+	status string
+	err    error
+}
+
+func (d *drainOperation) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.out.Write(p)
+}
+
+func (d *drainOperation) finish(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+	if err != nil {
+		d.status = NodeDrainFailed
+	} else {
+		d.status = NodeDrainSucceeded
+	}
+}
+
+func (d *drainOperation) response() NodeResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resp := NodeResponse{Output: d.out.String(), Status: d.status}
+	if d.err != nil {
+		resp.Error = d.err.Error()
+	}
+	return resp
+}
+
+func getNodeRequest(req *http.Request) (*NodeRequest, error) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	result := &NodeRequest{GracePeriodSeconds: -1}
+	err = json.Unmarshal(b, &result)
+	return result, err
+}
+
+// NodeCordonDrain handles requests to cordon, uncordon, or drain a node, using the supervisor's
+// own client so that nodes can be managed from any server without an admin kubeconfig.
+// Draining respects PodDisruptionBudgets unless eviction is disabled. Drains run in the
+// background until complete or timed out, and their progress is polled with GET requests;
+// only the most recent drain of each node is tracked.
+func NodeCordonDrain(ctx context.Context, control *config.Control) http.Handler {
+	var mu sync.Mutex
+	drains := map[string]*drainOperation{}
+
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			name := req.URL.Query().Get("name")
+			mu.Lock()
+			op := drains[name]
+			mu.Unlock()
+			if op == nil {
+				util.SendError(fmt.Errorf("no drain found for node %q", name), resp, req, http.StatusNotFound)
+				return
+			}
+			sendNodeResponse(op.response(), resp, req, http.StatusOK)
+			return
+		}
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		if control.Runtime.K8s == nil {
+			util.SendError(util.ErrCoreNotReady, resp, req, http.StatusServiceUnavailable)
+			return
+		}
+		nodeReq, err := getNodeRequest(req)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		auditlog.Annotate(req, "node "+nodeReq.Action, nodeReq.Name)
+		// nodes pending join approval do not have a node object yet, so approval is handled separately
+		if nodeReq.Action == NodeActionApprove {
+			approveNode(nodeReq, resp, req)
+			return
+		}
+		if nodeReq.Name == "" {
+			util.SendError(errors.New("node name must be set"), resp, req, http.StatusBadRequest)
+			return
+		}
+
+		node, err := control.Runtime.K8s.CoreV1().Nodes().Get(req.Context(), nodeReq.Name, metav1.GetOptions{})
+		if err != nil {
+			code := http.StatusInternalServerError
+			if apierrors.IsNotFound(err) {
+				code = http.StatusNotFound
+			}
+			util.SendError(err, resp, req, code)
+			return
+		}
+
+		logrus.Infof("Handling %s request for node %s", nodeReq.Action, nodeReq.Name)
+		switch nodeReq.Action {
+		case NodeActionCordon, NodeActionUncordon:
+			out := &bytes.Buffer{}
+			helper := newDrainHelper(req.Context(), control.Runtime.K8s, nodeReq, out)
+			if err := drain.RunCordonOrUncordon(helper, node, nodeReq.Action == NodeActionCordon); err != nil {
+				util.SendErrorWithID(err, "node", resp, req, http.StatusInternalServerError)
+				return
+			}
+			sendNodeResponse(NodeResponse{Output: out.String()}, resp, req, http.StatusOK)
+		case NodeActionDrain:
+			mu.Lock()
+			if op := drains[node.Name]; op != nil && op.response().Status == NodeDrainRunning {
+				mu.Unlock()
+				util.SendError(fmt.Errorf("node %s is already being drained", node.Name), resp, req, http.StatusConflict)
+				return
+			}
+			op := &drainOperation{status: NodeDrainRunning}
+			drains[node.Name] = op
+			mu.Unlock()
+
+			go runNodeDrain(ctx, control.Runtime.K8s, nodeReq, node, op)
+			sendNodeResponse(op.response(), resp, req, http.StatusAccepted)
+		default:
+			util.SendError(fmt.Errorf("invalid node action %q", nodeReq.Action), resp, req, http.StatusBadRequest)
+		}
+	})
+}
+
+// approveNode approves a node that is pending approval to join the cluster. If no node name is set,
+// the names of all nodes pending approval are returned instead.
+func approveNode(nodeReq *NodeRequest, resp http.ResponseWriter, req *http.Request) {
+	if nodeReq.Name == "" {
+		names, err := nodepassword.Pending()
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusServiceUnavailable)
+			return
+		}
+		var out strings.Builder
+		for _, name := range names {
+			out.WriteString(name + "\n")
+		}
+		sendNodeResponse(NodeResponse{Output: out.String()}, resp, req, http.StatusOK)
+		return
+	}
+
+	logrus.Infof("Handling %s request for node %s", nodeReq.Action, nodeReq.Name)
+	if err := nodepassword.Approve(nodeReq.Name); err != nil {
+		code := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("no join request found for node %s", nodeReq.Name)
+			code = http.StatusNotFound
+		} else if errors.Is(err, util.ErrCoreNotReady) {
+			code = http.StatusServiceUnavailable
+		}
+		util.SendError(err, resp, req, code)
+		return
+	}
+	sendNodeResponse(NodeResponse{Output: fmt.Sprintf("node/%s approved\n", nodeReq.Name)}, resp, req, http.StatusOK)
+}
+
+// runNodeDrain cordons and drains a node, recording progress to the drain operation.
+// The drain is bounded by the request timeout, or the default timeout if none was set.
+func runNodeDrain(ctx context.Context, client kubernetes.Interface, nodeReq *NodeRequest, node *corev1.Node, op *drainOperation) {
+	if nodeReq.Timeout <= 0 {
+		nodeReq.Timeout = DefaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, nodeReq.Timeout)
+	defer cancel()
+
+	helper := newDrainHelper(ctx, client, nodeReq, op)
+	err := drain.RunCordonOrUncordon(helper, node, true)
+	if err == nil {
+		err = drain.RunNodeDrain(helper, node.Name)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to drain node %s: %v", node.Name, err)
+	} else {
+		logrus.Infof("Drained node %s", node.Name)
+	}
+	op.finish(err)
+}
+
+func newDrainHelper(ctx context.Context, client kubernetes.Interface, nodeReq *NodeRequest, out io.Writer) *drain.Helper {
+	return &drain.Helper{
+		Ctx:                 ctx,
+		Client:              client,
+		Force:               nodeReq.Force,
+		IgnoreAllDaemonSets: nodeReq.IgnoreDaemonSets,
+		DeleteEmptyDirData:  nodeReq.DeleteEmptyDirData,
+		DisableEviction:     nodeReq.DisableEviction,
+		GracePeriodSeconds:  nodeReq.GracePeriodSeconds,
+		PodSelector:         nodeReq.PodSelector,
+		Timeout:             nodeReq.Timeout,
+		Out:                 out,
+		ErrOut:              out,
+		OnPodDeletionOrEvictionFinished: func(pod *corev1.Pod, usingEviction bool, err error) {
+			if err != nil {
+				return
+			}
+			verb := "deleted"
+			if usingEviction {
+				verb = "evicted"
+			}
+			fmt.Fprintf(out, "pod %s/%s %s\n", pod.Namespace, pod.Name, verb)
+		},
+	}
+}
+
+func sendNodeResponse(nodeResp NodeResponse, resp http.ResponseWriter, req *http.Request, code int) {
+	b, err := json.Marshal(nodeResp)
+	if err != nil {
+		util.SendErrorWithID(err, "node", resp, req, http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
+	resp.Write(b)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_UnitNodeCordonDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8s := fake.NewClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	control := &config.Control{Runtime: &config.ControlRuntime{K8s: k8s}}
+	handler := NodeCordonDrain(ctx, control)
+
+	do := func(method, target string, nodeReq *NodeRequest) (int, NodeResponse) {
+		t.Helper()
+		var body bytes.Buffer
+		if nodeReq != nil {
+			if err := json.NewEncoder(&body).Encode(nodeReq); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, &body))
+		nodeResp := NodeResponse{}
+		if rec.Code < 300 {
+			if err := json.Unmarshal(rec.Body.Bytes(), &nodeResp); err != nil {
+				t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, nodeResp
+	}
+	unschedulable := func() bool {
+		t.Helper()
+		node, err := k8s.CoreV1().Nodes().Get(ctx, "node1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node.Spec.Unschedulable
+	}
+
+	if code, _ := do(http.MethodPost, "/node", &NodeRequest{Action: NodeActionCordon, Name: "missing"}); code != http.StatusNotFound {
+		t.Errorf("cordon of missing node returned %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := do(http.MethodPost, "/node", &NodeRequest{Action: "reboot", Name: "node1"}); code != http.StatusBadRequest {
+		t.Errorf("invalid action returned %d, want %d", code, http.StatusBadRequest)
+	}
+	if code, _ := do(http.MethodGet, "/node?name=node1", nil); code != http.StatusNotFound {
+		t.Errorf("drain status before drain returned %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code, _ := do(http.MethodPost, "/node", &NodeRequest{Action: NodeActionCordon, Name: "node1"}); code != http.StatusOK {
+		t.Fatalf("cordon returned %d, want %d", code, http.StatusOK)
+	}
+	if !unschedulable() {
+		t.Error("node is schedulable after cordon")
+	}
+	if code, _ := do(http.MethodPost, "/node", &NodeRequest{Action: NodeActionUncordon, Name: "node1"}); code != http.StatusOK {
+		t.Fatalf("uncordon returned %d, want %d", code, http.StatusOK)
+	}
+	if unschedulable() {
+		t.Error("node is unschedulable after uncordon")
+	}
+
+	code, nodeResp := do(http.MethodPost, "/node", &NodeRequest{Action: NodeActionDrain, Name: "node1", IgnoreDaemonSets: true})
+	if code != http.StatusAccepted || nodeResp.Status != NodeDrainRunning {
+		t.Fatalf("drain returned %d with status %q, want %d with status %q", code, nodeResp.Status, http.StatusAccepted, NodeDrainRunning)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for nodeResp.Status == NodeDrainRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if code, nodeResp = do(http.MethodGet, "/node?name=node1", nil); code != http.StatusOK {
+			t.Fatalf("drain status returned %d, want %d", code, http.StatusOK)
+		}
+	}
+	if nodeResp.Status != NodeDrainSucceeded {
+		t.Fatalf("drain finished with status %q error %q, want %q", nodeResp.Status, nodeResp.Error, NodeDrainSucceeded)
+	}
+	if !unschedulable() {
+		t.Error("node is schedulable after drain")
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"net/http"
 	"path/filepath"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/faults"
+	"github.com/k3s-io/k3s/pkg/imagepolicy"
+	"github.com/k3s-io/k3s/pkg/kubeadm"
 	"github.com/k3s-io/k3s/pkg/nodepassword"
 	"github.com/k3s-io/k3s/pkg/server/auth"
 	"github.com/k3s-io/k3s/pkg/util/mux"
@@ -31,43 +35,96 @@ func NewHandler(ctx context.Context, control *config.Control, cfg *cmds.Server)
 	nodeAuth := nodepassword.GetNodeAuthValidator(ctx, control)
 
 	prefix := "/v1-" + version.Program
+	apiServer := APIServer(control, cfg)
+	tunnel := control.Runtime.Tunnel
+	if injector := control.Runtime.Faults; injector != nil {
+		apiServer = injector.Pause(faults.ComponentAPIServer, apiServer)
+		tunnel = injector.Tunnels(tunnel)
+	}
+
 	authed := mux.NewRouter()
-	authed.NotFoundHandler = APIServer(control, cfg)
+	authed.NotFoundHandler = apiServer
 	authed.Use(auth.HasRole(control, version.Program+":agent", user.NodesGroup, bootstrapapi.BootstrapDefaultGroup), auth.RequestInfo(), auth.MaxInFlight(maxNonMutatingAgentRequests, maxMutatingAgentRequests))
-	authed.Handle(prefix+"/serving-kubelet.crt", ServingKubeletCert(control, nodeAuth))
-	authed.Handle(prefix+"/client-kubelet.crt", ClientKubeletCert(control, nodeAuth))
-	authed.Handle(prefix+"/client-kube-proxy.crt", ClientKubeProxyCert(control))
-	authed.Handle(prefix+"/client-"+version.Program+"-controller.crt", ClientControllerCert(control))
+	// server-scoped bootstrap tokens cannot be used to join agents
+	agentJoin := auth.DenyRole(kubeadm.ServerBootstrapTokenAuthGroup)
+	// limited-use bootstrap tokens are consumed by the first request for node-named certificates
+	limitUses := LimitTokenUses(control, nodeNameIdentity)
+	requireUse := RequireTokenUse(control, nodeAuth)
+	authed.Handle(prefix+"/serving-kubelet.crt", agentJoin(limitUses(ServingKubeletCert(control, nodeAuth))))
+	authed.Handle(prefix+"/client-kubelet.crt", agentJoin(limitUses(ClientKubeletCert(control, nodeAuth))))
+	authed.Handle(prefix+"/client-kube-proxy.crt", agentJoin(requireUse(ClientKubeProxyCert(control))))
+	authed.Handle(prefix+"/client-"+version.Program+"-controller.crt", agentJoin(requireUse(ClientControllerCert(control))))
 	authed.Handle(prefix+"/client-ca.crt", File(control.Runtime.ClientCA))
 	authed.Handle(prefix+"/server-ca.crt", File(control.Runtime.ServerCA))
 	authed.Handle(prefix+"/apiservers", APIServers(control))
 	authed.Handle(prefix+"/config", Config(control, cfg))
+	authed.Handle(prefix+"/ipam", agentJoin(limitUses(NodeIPAM(control, nodeAuth))))
 	authed.Handle(prefix+"/readyz", Readyz(control))
 
 	nodeAuthed := mux.NewRouter()
 	nodeAuthed.NotFoundHandler = authed
 	nodeAuthed.Use(auth.HasRole(control, user.NodesGroup))
-	nodeAuthed.Handle(prefix+"/connect", control.Runtime.Tunnel)
+	nodeAuthed.Handle(prefix+"/connect", tunnel)
 
 	serverAuthed := mux.NewRouter()
 	serverAuthed.NotFoundHandler = nodeAuthed
-	serverAuthed.Use(auth.HasRole(control, version.Program+":server"))
+	serverAuthed.Use(auth.HasRole(control, version.Program+":server"), auditlog.Middleware(control.DataDir))
 	serverAuthed.Handle(prefix+"/encrypt/status", EncryptionStatus(control))
 	serverAuthed.Handle(prefix+"/encrypt/config", EncryptionConfig(ctx, control))
 	serverAuthed.Handle(prefix+"/cert/cacerts", CACertReplace(control))
-	serverAuthed.Handle(prefix+"/server-bootstrap", Bootstrap(control))
+	serverAuthed.Handle(prefix+"/cert/node", NodeCertRotate(ctx, control))
 	serverAuthed.Handle(prefix+"/token", TokenRequest(ctx, control))
+	serverAuthed.Handle(prefix+"/node", NodeCordonDrain(ctx, control))
+	serverAuthed.Handle(prefix+"/health", Health(control))
+	serverAuthed.Handle(prefix+"/faults", Faults(control))
+	serverAuthed.Handle(prefix+"/standby/promote", StandbyPromote(control))
+	serverAuthed.Handle(prefix+"/debug/log-level", LogLevel())
+
+	// server-scoped bootstrap tokens can only be used to retrieve bootstrap data when joining servers
+	serverJoinAuthed := mux.NewRouter()
+	serverJoinAuthed.NotFoundHandler = serverAuthed
+	serverJoinAuthed.Use(auth.HasRole(control, version.Program+":server", kubeadm.ServerBootstrapTokenAuthGroup))
+	serverJoinAuthed.Handle(prefix+"/server-bootstrap", LimitTokenUses(control, clientIPIdentity)(Bootstrap(control)))
 
 	systemAuthed := mux.NewRouter()
-	systemAuthed.NotFoundHandler = serverAuthed
+	systemAuthed.NotFoundHandler = serverJoinAuthed
 	systemAuthed.Use(auth.HasRole(control, user.SystemPrivilegedGroup))
-	systemAuthed.Handle("CONNECT /", control.Runtime.Tunnel)
+	systemAuthed.Handle("CONNECT /", tunnel)
 
 	router := mux.NewRouter()
 	router.NotFoundHandler = systemAuthed
 	router.Handle(staticURL, Static(staticURL, filepath.Join(control.DataDir, "static")))
 	router.Handle("/cacerts", CACerts(control))
 	router.Handle("/ping", Ping())
+	// health checks from external load-balancers are not authenticated
+	router.Handle(prefix+"/lb/readyz", LBReadyz(control))
+	router.Handle(prefix+"/lb/livez", LBLivez(control))
+	// inventory requests from external fleet management tools are authorized by RBAC, if not made with the server token
+	router.Handle(prefix+"/inventory", auth.HasRoleOrAccess(control, version.Program+":server")(Inventory(control)))
+	// admission webhook requests from the apiserver are not authenticated
+	router.Handle(imagepolicy.Path, imagepolicy.Handler(control))
+
+	if injector := control.Runtime.Faults; injector != nil {
+		pause := pauseSupervisor(injector, prefix+"/faults")
+		for _, r := range []*mux.Router{router, systemAuthed, serverJoinAuthed, serverAuthed, nodeAuthed, authed} {
+			r.Use(pause)
+		}
+	}
 
 	return router
 }
+
+// pauseSupervisor returns a middleware that pauses supervisor handlers while the supervisor is paused by fault
+// injection. The fault injection API itself is not paused, so that the fault can be cleared.
+func pauseSupervisor(injector *faults.Injector, faultsPath string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		paused := injector.Pause(faults.ComponentSupervisor, next)
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == faultsPath {
+				next.ServeHTTP(resp, req)
+				return
+			}
+			paused.ServeHTTP(resp, req)
+		})
+	}
+}
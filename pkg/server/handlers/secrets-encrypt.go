@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/cluster"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/secretsencrypt"
@@ -212,17 +213,26 @@ func EncryptionConfig(ctx context.Context, control *config.Control) http.Handler
 		if encryptReq.Stage != nil {
 			switch *encryptReq.Stage {
 			case secretsencrypt.EncryptionPrepare:
+				auditlog.Annotate(req, "secrets-encrypt prepare", "")
 				err = encryptionPrepare(ctx, control, encryptReq.Force)
 			case secretsencrypt.EncryptionRotate:
+				auditlog.Annotate(req, "secrets-encrypt rotate", "")
 				err = encryptionRotate(ctx, control, encryptReq.Force)
 			case secretsencrypt.EncryptionRotateKeys:
+				auditlog.Annotate(req, "secrets-encrypt rotate-keys", "")
 				err = encryptionRotateKeys(ctx, control)
 			case secretsencrypt.EncryptionReencryptActive:
+				auditlog.Annotate(req, "secrets-encrypt reencrypt", "")
 				err = encryptionReencrypt(ctx, control, encryptReq.Force, encryptReq.Skip)
 			default:
 				err = fmt.Errorf("unknown stage %s requested", *encryptReq.Stage)
 			}
 		} else if encryptReq.Enable != nil {
+			if *encryptReq.Enable {
+				auditlog.Annotate(req, "secrets-encrypt enable", "")
+			} else {
+				auditlog.Annotate(req, "secrets-encrypt disable", "")
+			}
 			err = encryptionEnable(ctx, control, *encryptReq.Enable)
 		}
 
@@ -458,7 +468,7 @@ func updateSecrets(ctx context.Context, control *config.Control, nodeName string
 	secretPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
 		return k8s.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
 	}))
-	secretPager.PageSize = secretsencrypt.SecretListPageSize
+	secretPager.PageSize = control.GetListPageSize()
 
 	i := 0
 	if err := secretPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/k3s-io/k3s/pkg/auditlog"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/standby"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// StandbyPromote handles requests to promote a control-plane standby server. The server records the promotion
+// and restarts after the response is sent.
+func StandbyPromote(control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
+			return
+		}
+		auditlog.Annotate(req, "node promote", "")
+		if err := standby.Promote(control, "requested via "+req.URL.Path+" by "+req.RemoteAddr); err != nil {
+			util.SendError(err, resp, req, http.StatusBadRequest)
+			return
+		}
+		resp.WriteHeader(http.StatusOK)
+	})
+}
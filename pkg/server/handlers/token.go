@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/cluster"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -19,7 +20,8 @@ import (
 )
 
 type TokenRotateRequest struct {
-	NewToken *string `json:"newToken,omitempty"`
+	NewToken     *string `json:"newToken,omitempty"`
+	UpdateAgents *bool   `json:"updateAgents,omitempty"`
 }
 
 func getServerTokenRequest(req *http.Request) (TokenRotateRequest, error) {
@@ -38,6 +40,7 @@ func TokenRequest(ctx context.Context, control *config.Control) http.Handler {
 			util.SendError(errors.New("method not allowed"), resp, req, http.StatusMethodNotAllowed)
 			return
 		}
+		auditlog.Annotate(req, "token rotate", "")
 		var err error
 		sTokenReq, err := getServerTokenRequest(req)
 		logrus.Debug("Received token request")
@@ -45,10 +48,18 @@ func TokenRequest(ctx context.Context, control *config.Control) http.Handler {
 			util.SendError(err, resp, req, http.StatusBadRequest)
 			return
 		}
-		if err = tokenRotate(ctx, control, *sTokenReq.NewToken); err != nil {
+		agentToken, err := tokenRotate(ctx, control, *sTokenReq.NewToken)
+		if err != nil {
 			util.SendErrorWithID(err, "token", resp, req, http.StatusInternalServerError)
 			return
 		}
+		if sTokenReq.UpdateAgents != nil && *sTokenReq.UpdateAgents {
+			if agentToken == "" {
+				logrus.Info("Agent token is not shared with the server token; agents do not need to be updated")
+			} else {
+				updateAgents(ctx, control, agentToken)
+			}
+		}
 		resp.WriteHeader(http.StatusOK)
 	})
 }
@@ -65,50 +76,54 @@ func WriteToken(token, file, certs string) error {
 	return os.WriteFile(file, []byte(token+"\n"), 0600)
 }
 
-func tokenRotate(ctx context.Context, control *config.Control, newToken string) error {
+// tokenRotate rotates the server token. If the agent token is shared with the server token,
+// it is also rotated, and the new agent token is returned.
+func tokenRotate(ctx context.Context, control *config.Control, newToken string) (string, error) {
 	passwd, err := passwd.Read(control.Runtime.PasswdFile)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	oldToken, found := passwd.Pass("server")
 	if !found {
-		return errors.New("server token not found")
+		return "", errors.New("server token not found")
 	}
 	if newToken == "" {
 		newToken, err = util.Random(16)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
 	if newToken, err = util.NormalizeToken(newToken); err != nil {
-		return err
+		return "", err
 	}
 
 	if err := passwd.EnsureUser("server", version.Program+":server", newToken); err != nil {
-		return err
+		return "", err
 	}
 
 	// If the agent token is the same a server, we need to change both
+	var newAgentToken string
 	if agentToken, found := passwd.Pass("node"); found && agentToken == oldToken && control.AgentToken == "" {
 		if err := passwd.EnsureUser("node", version.Program+":agent", newToken); err != nil {
-			return err
+			return "", err
 		}
+		newAgentToken = newToken
 	}
 
 	if err := passwd.Write(control.Runtime.PasswdFile); err != nil {
-		return err
+		return "", err
 	}
 
 	serverTokenFile := filepath.Join(control.DataDir, "token")
 	if err := WriteToken("server:"+newToken, serverTokenFile, control.Runtime.ServerCA); err != nil {
-		return err
+		return "", err
 	}
 
 	if err := cluster.RotateBootstrapToken(ctx, control, oldToken); err != nil {
-		return err
+		return "", err
 	}
 	control.Token = newToken
-	return cluster.Save(ctx, control, true)
+	return newAgentToken, cluster.Save(ctx, control, true)
 }
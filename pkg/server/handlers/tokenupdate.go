@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/agent/tokenupdate"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const agentUpdateTimeout = 30 * time.Second
+
+// agentDialer is implemented by the tunnel server, and is used to dial agents through their tunnel session.
+type agentDialer interface {
+	sessionChecker
+	DialAgent(ctx context.Context, nodeName, address string) (net.Conn, error)
+}
+
+// updateAgents pushes the new agent token to agents that are connected to this server. Agents store the
+// token, and use it in place of their configured token the next time they are started. Servers are not
+// updated, as they must be restarted with the new token.
+func updateAgents(ctx context.Context, control *config.Control, agentToken string) {
+	dialer, ok := control.Runtime.Tunnel.(agentDialer)
+	if !ok || control.Runtime.Core == nil {
+		logrus.Warn("Unable to push rotated token to agents: tunnel server is not available")
+		return
+	}
+	nodes, err := control.Runtime.Core.Core().V1().Node().Cache().List(labels.Everything())
+	if err != nil {
+		logrus.Warnf("Unable to push rotated token to agents: %v", err)
+		return
+	}
+	body, err := json.Marshal(tokenupdate.Update{Password: agentToken})
+	if err != nil {
+		logrus.Warnf("Unable to push rotated token to agents: %v", err)
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	for _, node := range nodes {
+		if node.Labels[util.ControlPlaneRoleLabelKey] == "true" {
+			continue
+		}
+		if !dialer.HasSession(node.Name) {
+			logrus.Warnf("Unable to push rotated token to agent %s: agent is not connected to this server", node.Name)
+			continue
+		}
+		wg.Add(1)
+		go func(nodeName string) {
+			defer wg.Done()
+			if err := updateAgent(ctx, dialer, nodeName, tokenupdate.Address, body); err != nil {
+				logrus.Warnf("Failed to push rotated token to agent %s: %v", nodeName, err)
+				return
+			}
+			logrus.Infof("Pushed rotated token to agent %s", nodeName)
+		}(node.Name)
+	}
+	wg.Wait()
+}
+
+// updateAgent sends an update request to an address served by the agent on the named node, through its tunnel session.
+func updateAgent(ctx context.Context, dialer agentDialer, nodeName, address string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, agentUpdateTimeout)
+	defer cancel()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialAgent(ctx, nodeName, address)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://"+address+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/kubeadm"
+	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/util/mux"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/util/retry"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+)
+
+// nodeNameIdentity identifies agents by the node name header. The node password is validated by the handler,
+// and the use is released if the request is not successful.
+func nodeNameIdentity(req *http.Request) string {
+	return strings.ToLower(req.Header.Get(version.Program + "-Node-Name"))
+}
+
+// clientIPIdentity identifies servers by client address, as servers do not send their node name when retrieving
+// bootstrap data.
+func clientIPIdentity(req *http.Request) string {
+	host, _, _ := net.SplitHostPort(req.RemoteAddr)
+	return host
+}
+
+// bootstrapTokenID returns the ID of the bootstrap token that the request was authenticated with, if any.
+func bootstrapTokenID(req *http.Request) (string, bool) {
+	info, ok := apirequest.UserFrom(req.Context())
+	if !ok || !strings.HasPrefix(info.GetName(), bootstrapapi.BootstrapUserPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(info.GetName(), bootstrapapi.BootstrapUserPrefix), true
+}
+
+// sendTokenUseError sends an error response for a failure to check or reserve a use of a bootstrap token.
+func sendTokenUseError(err error, tokenID, node string, resp http.ResponseWriter, req *http.Request) {
+	if errors.Is(err, kubeadm.ErrTokenUsesExhausted) || errors.Is(err, kubeadm.ErrTokenNodeRequired) {
+		logrus.Warnf("Rejected request from %s for node %q using bootstrap token %s: %v", req.RemoteAddr, node, tokenID, err)
+		util.SendError(err, resp, req, http.StatusForbidden)
+		return
+	}
+	util.SendErrorWithID(err, "token-use", resp, req, http.StatusInternalServerError)
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// LimitTokenUses returns a middleware function that enforces the maximum number of uses of limited-use bootstrap
+// tokens. The use is recorded on the token Secret before the request is handled, so that concurrent requests from
+// different nodes cannot exceed the limit, and released if the request is not successful. Requests not authenticated
+// with a bootstrap token are not affected. It must be used after a middleware that authenticates the request.
+func LimitTokenUses(control *config.Control, identity func(*http.Request) string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			tokenID, ok := bootstrapTokenID(req)
+			if !ok {
+				next.ServeHTTP(resp, req)
+				return
+			}
+			node := identity(req)
+			reserved, err := updateTokenUse(control, tokenID, func(secret *v1.Secret) (bool, error) {
+				return kubeadm.ReserveTokenUse(secret, node)
+			})
+			if err != nil {
+				sendTokenUseError(err, tokenID, node, resp, req)
+				return
+			}
+			if !reserved {
+				next.ServeHTTP(resp, req)
+				return
+			}
+
+			recorder := &statusRecorder{ResponseWriter: resp}
+			next.ServeHTTP(recorder, req)
+			if recorder.status != 0 && recorder.status < http.StatusBadRequest {
+				logrus.Infof("Recorded use of bootstrap token %s by %s", tokenID, node)
+			} else if _, err := updateTokenUse(control, tokenID, func(secret *v1.Secret) (bool, error) {
+				return kubeadm.ReleaseTokenUse(secret, node), nil
+			}); err != nil {
+				logrus.Errorf("Failed to release use of bootstrap token %s by %s: %v", tokenID, node, err)
+			}
+		})
+	}
+}
+
+// RequireTokenUse returns a middleware function that rejects requests authenticated with a limited-use bootstrap
+// token, unless the requesting node has already used the token. It is used for endpoints that are only requested
+// after the node has retrieved its kubelet certificates, and that do not otherwise validate the node password.
+// It must be used after a middleware that authenticates the request.
+func RequireTokenUse(control *config.Control, auth nodepassword.NodeAuthValidator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			tokenID, ok := bootstrapTokenID(req)
+			if !ok {
+				next.ServeHTTP(resp, req)
+				return
+			}
+			node := nodeNameIdentity(req)
+			var limited bool
+			if _, err := updateTokenUse(control, tokenID, func(secret *v1.Secret) (bool, error) {
+				maxUses, _, _ := kubeadm.TokenUses(secret)
+				limited = maxUses > 0
+				return false, kubeadm.CheckTokenUse(secret, node)
+			}); err != nil {
+				sendTokenUseError(err, tokenID, node, resp, req)
+				return
+			}
+			// the node has already used the limited-use token; validate the node password so that a captured
+			// token cannot be used to impersonate a node that has already joined.
+			if limited {
+				if _, errCode, err := auth(req); err != nil {
+					util.SendError(err, resp, req, errCode)
+					return
+				}
+			}
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
+// updateTokenUse applies a modification to the Secret for the bootstrap token with the given ID, retrying on
+// conflict. It returns true if the Secret was modified. Tokens that have been deleted are not modified, as the
+// request will already have been rejected by the authenticator.
+func updateTokenUse(control *config.Control, tokenID string, modify func(*v1.Secret) (bool, error)) (bool, error) {
+	if control.Runtime.Core == nil {
+		return false, util.ErrCoreNotReady
+	}
+	secrets := control.Runtime.Core.Core().V1().Secret()
+	var changed bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(metav1.NamespaceSystem, bootstraputil.BootstrapTokenSecretName(tokenID), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		secret = secret.DeepCopy()
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		if changed, err = modify(secret); err != nil || !changed {
+			return err
+		}
+		_, err = secrets.Update(secret)
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return changed, err
+}
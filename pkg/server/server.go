@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/k3s-io/k3s/pkg/auditlog"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -18,9 +19,14 @@ import (
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/datadir"
 	"github.com/k3s-io/k3s/pkg/deploy"
+	"github.com/k3s-io/k3s/pkg/dnsautoscaler"
+	"github.com/k3s-io/k3s/pkg/imagepolicy"
+	"github.com/k3s-io/k3s/pkg/ipam"
 	"github.com/k3s-io/k3s/pkg/node"
 	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/permmonitor"
 	"github.com/k3s-io/k3s/pkg/rootlessports"
+	"github.com/k3s-io/k3s/pkg/routeexport"
 	"github.com/k3s-io/k3s/pkg/secretsencrypt"
 	"github.com/k3s-io/k3s/pkg/server/handlers"
 	"github.com/k3s-io/k3s/pkg/static"
@@ -122,7 +128,7 @@ func runControllers(ctx context.Context, config *Config) error {
 	}
 
 	// start the nodepassword controller before we set controlConfig.Runtime.Core
-	if err := nodepassword.Register(ctx, sc.K8s, sc.Core.Core().V1().Secret(), sc.Core.Core().V1().Node()); err != nil {
+	if err := nodepassword.Register(ctx, sc.K8s, sc.Core.Core().V1().Secret(), sc.Core.Core().V1().Node(), controlConfig.GetListPageSize()); err != nil {
 		return errors.WithMessage(err, "failed to start node-password secret controller")
 	}
 
@@ -162,6 +168,17 @@ func runControllers(ctx context.Context, config *Config) error {
 
 	go setClusterDNSConfig(ctx, config, sc.Core.Core().V1().ConfigMap())
 
+	if !controlConfig.DisableAPIServer && controlConfig.WatchCacheReportInterval.Duration > 0 {
+		go reportWatchCacheUsage(ctx, sc.K8s, controlConfig.WatchCacheReportInterval.Duration)
+	}
+
+	if !controlConfig.DisableAPIServer && controlConfig.APIServerSANFile != "" {
+		go watchAPIServerSANFile(ctx, controlConfig)
+	}
+
+	permmonitor.Setup(ctx, controlConfig)
+	auditlog.StartEvents(ctx, controlConfig)
+
 	if controlConfig.NoLeaderElect {
 		for name, cb := range controlConfig.Runtime.LeaderElectedClusterControllerStarts {
 			go runOrDie(ctx, name, cb)
@@ -210,11 +227,18 @@ func runOrDie(ctx context.Context, name string, cb leader.Callback) {
 // * Node controller (manages coredns node hosts file)
 // * Helm controller
 // * Secrets encryption
+// * CoreDNS autoscaler
+// * ServiceCIDR expansion
 // * Rootless ports
 // These controllers should only be run on nodes with a local apiserver
 func coreControllers(ctx context.Context, sc *Context, config *Config) error {
+	hostOverrides, err := node.ParseHostOverrides(config.ControlConfig.HostOverrides)
+	if err != nil {
+		return err
+	}
 	if err := node.Register(ctx,
 		!config.ControlConfig.Skips["coredns"],
+		hostOverrides,
 		sc.K8s,
 		sc.Core.Core().V1().Node()); err != nil {
 		return err
@@ -258,6 +282,41 @@ func coreControllers(ctx context.Context, sc *Context, config *Config) error {
 			core.V1().Secret())
 	}
 
+	go reconcileServiceCIDRs(ctx, sc.K8s, config.ControlConfig.ServiceIPRanges)
+
+	if config.ControlConfig.IPAMWebhookURL != "" {
+		webhook := ipam.NewWebhook(config.ControlConfig.IPAMWebhookURL, config.ControlConfig.ClusterIPRanges)
+		if err := ipam.Register(ctx, webhook, sc.Core.Core().V1().Node()); err != nil {
+			return err
+		}
+	} else if config.ControlConfig.StickyPodCIDRs {
+		allocator, err := ipam.NewStickyAllocator(config.ControlConfig.ClusterIPRanges, config.ControlConfig.ExtraControllerArgs, sc.Core.Core().V1().ConfigMap(), sc.Core.Core().V1().Node().Cache())
+		if err != nil {
+			return errors.WithMessage(err, "failed to configure sticky pod CIDR allocation")
+		}
+		if err := ipam.Register(ctx, allocator, sc.Core.Core().V1().Node()); err != nil {
+			return err
+		}
+	}
+
+	if config.ControlConfig.RouteExportTarget != "" {
+		exporter, err := routeexport.NewExporter(config.ControlConfig.RouteExportTarget, sc.Core.Core().V1().ConfigMap())
+		if err != nil {
+			return err
+		}
+		if err := routeexport.Register(ctx, exporter, sc.Core.Core().V1().Node()); err != nil {
+			return err
+		}
+	}
+
+	if config.ControlConfig.DNSAutoscaler != nil && !config.ControlConfig.Skips["coredns"] {
+		dnsautoscaler.Register(ctx, sc.K8s, sc.Core.Core().V1().Node().Cache(), config.ControlConfig.DNSAutoscaler)
+	}
+
+	if err := imagepolicy.Register(ctx, sc.K8s, &config.ControlConfig); err != nil {
+		return errors.WithMessage(err, "failed to configure image policy webhook")
+	}
+
 	if config.ControlConfig.Rootless {
 		return rootlessports.Register(ctx,
 			sc.Core.Core().V1().Service(),
@@ -278,6 +337,13 @@ func stageFiles(ctx context.Context, sc *Context, controlConfig *config.Control)
 	}
 	dataDir = filepath.Join(controlConfig.DataDir, "manifests")
 
+	// Linux-only packaged components that do not already set an OS node selector must be kept off
+	// Windows nodes in mixed-OS clusters.
+	mixedOSNodeSelector := "{}"
+	if controlConfig.MixedOS {
+		mixedOSNodeSelector = `{"kubernetes.io/os": "linux"}`
+	}
+
 	dnsIPFamilyPolicy := "SingleStack"
 	if len(controlConfig.ClusterDNSs) > 1 {
 		dnsIPFamilyPolicy = "RequireDualStack"
@@ -292,6 +358,7 @@ func stageFiles(ctx context.Context, sc *Context, controlConfig *config.Control)
 		"%{SYSTEM_DEFAULT_REGISTRY}%":     registryTemplate(controlConfig.SystemDefaultRegistry),
 		"%{SYSTEM_DEFAULT_REGISTRY_RAW}%": controlConfig.SystemDefaultRegistry,
 		"%{PREFERRED_ADDRESS_TYPES}%":     addrTypesPrioTemplate(controlConfig.FlannelExternalIP),
+		"%{MIXED_OS_NODE_SELECTOR}%":      mixedOSNodeSelector,
 	}
 
 	skip := controlConfig.Skips
@@ -310,6 +377,11 @@ func stageFiles(ctx context.Context, sc *Context, controlConfig *config.Control)
 		return err
 	}
 
+	placements, err := deploy.ParsePlacements(controlConfig.ComponentNodeSelectors, controlConfig.ComponentTolerations)
+	if err != nil {
+		return err
+	}
+
 	apply := apply.New(k8s, apply.NewClientFactory(restConfig)).WithDynamicLookup()
 	k3s := sc.K3s.WithAgent(restConfig.UserAgent)
 
@@ -318,6 +390,8 @@ func stageFiles(ctx context.Context, sc *Context, controlConfig *config.Control)
 		apply,
 		k3s.V1().Addon(),
 		controlConfig.Disables,
+		placements,
+		controlConfig.Runtime.BootstrapAddonsReady,
 		dataDir)
 }
 
@@ -575,14 +649,28 @@ func setClusterDNSConfig(ctx context.Context, config *Config, configMap v1.Confi
 	if config.ControlConfig.DisableAPIServer {
 		return nil
 	}
-	// check if configmap already exists
-	_, err := configMap.Get("kube-system", "cluster-dns", metav1.GetOptions{})
+	clusterDNS := config.ControlConfig.ClusterDNS
+	clusterDomain := config.ControlConfig.ClusterDomain
+	// check if configmap already exists, and update it if the cluster domain has been migrated
+	existing, err := configMap.Get("kube-system", "cluster-dns", metav1.GetOptions{})
 	if err == nil {
-		logrus.Infof("Cluster dns configmap already exists")
+		if existing.Data["clusterDNS"] == clusterDNS.String() && existing.Data["clusterDomain"] == clusterDomain {
+			logrus.Infof("Cluster dns configmap already exists")
+			return nil
+		}
+		existing = existing.DeepCopy()
+		if existing.Data == nil {
+			existing.Data = map[string]string{}
+		}
+		existing.Data["clusterDNS"] = clusterDNS.String()
+		existing.Data["clusterDomain"] = clusterDomain
+		if _, err := configMap.Update(existing); err != nil {
+			logrus.Errorf("Failed to update cluster dns configmap: %v", err)
+			return err
+		}
+		logrus.Infof("Cluster dns configmap has been updated successfully")
 		return nil
 	}
-	clusterDNS := config.ControlConfig.ClusterDNS
-	clusterDomain := config.ControlConfig.ClusterDomain
 	c := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
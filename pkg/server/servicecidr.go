@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// serviceCIDRManagedByLabel is set on ServiceCIDR resources created for expanded service CIDRs.
+const serviceCIDRManagedByLabel = "app.kubernetes.io/managed-by"
+
+// reconcileServiceCIDRs ensures that the configured service CIDRs are covered by ServiceCIDR resources.
+// The kube-apiserver only creates the default ServiceCIDR from its flags when the cluster is first
+// started, so if the service CIDR has been expanded, an additional ServiceCIDR must be created for
+// ClusterIPs to be allocated from the expanded range.
+func reconcileServiceCIDRs(ctx context.Context, k8s kubernetes.Interface, serviceIPRanges []*net.IPNet) {
+	if err := wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		serviceCIDRs, err := k8s.NetworkingV1().ServiceCIDRs().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			logrus.Warnf("Failed to list ServiceCIDRs: %v", err)
+			return false, nil
+		}
+
+		cidrs := uncoveredServiceCIDRs(serviceCIDRs.Items, serviceIPRanges)
+		if len(cidrs) == 0 {
+			return true, nil
+		}
+
+		serviceCIDR := &networkingv1.ServiceCIDR{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   serviceCIDRName(cidrs),
+				Labels: map[string]string{serviceCIDRManagedByLabel: version.Program},
+			},
+			Spec: networkingv1.ServiceCIDRSpec{
+				CIDRs: cidrs,
+			},
+		}
+		if _, err := k8s.NetworkingV1().ServiceCIDRs().Create(ctx, serviceCIDR, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			logrus.Warnf("Failed to create ServiceCIDR %s for service-cidr %s: %v", serviceCIDR.Name, strings.Join(cidrs, ","), err)
+			return false, nil
+		}
+		logrus.Infof("Created ServiceCIDR %s for expanded service-cidr %s", serviceCIDR.Name, strings.Join(cidrs, ","))
+		return true, nil
+	}); err != nil && ctx.Err() == nil {
+		logrus.Errorf("Failed to reconcile ServiceCIDRs: %v", err)
+	}
+}
+
+// uncoveredServiceCIDRs returns the configured service CIDRs, if any of them are not fully
+// contained within an existing ServiceCIDR. If all configured CIDRs are covered, nil is returned.
+func uncoveredServiceCIDRs(serviceCIDRs []networkingv1.ServiceCIDR, serviceIPRanges []*net.IPNet) []string {
+	var cidrs []string
+	covered := true
+	for _, ipRange := range serviceIPRanges {
+		cidrs = append(cidrs, ipRange.String())
+		if !serviceCIDRsContain(serviceCIDRs, ipRange) {
+			covered = false
+		}
+	}
+	if covered {
+		return nil
+	}
+	return cidrs
+}
+
+// serviceCIDRsContain returns true if the IP range is fully contained within any existing ServiceCIDR.
+func serviceCIDRsContain(serviceCIDRs []networkingv1.ServiceCIDR, ipRange *net.IPNet) bool {
+	ones, bits := ipRange.Mask.Size()
+	for _, serviceCIDR := range serviceCIDRs {
+		if !serviceCIDR.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, cidr := range serviceCIDR.Spec.CIDRs {
+			_, parsed, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			parsedOnes, parsedBits := parsed.Mask.Size()
+			if parsedBits == bits && parsedOnes <= ones && parsed.Contains(ipRange.IP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serviceCIDRName returns a stable name for the ServiceCIDR covering the given CIDRs.
+func serviceCIDRName(cidrs []string) string {
+	h := sha256.Sum256([]byte(strings.Join(cidrs, ",")))
+	return version.Program + "-service-cidr-" + hex.EncodeToString(h[:])[:8]
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitUncoveredServiceCIDRs(t *testing.T) {
+	serviceCIDR := func(cidrs ...string) networkingv1.ServiceCIDR {
+		return networkingv1.ServiceCIDR{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes"},
+			Spec:       networkingv1.ServiceCIDRSpec{CIDRs: cidrs},
+		}
+	}
+	parseCIDRs := func(cidrs ...string) []*net.IPNet {
+		var res []*net.IPNet
+		for _, cidr := range cidrs {
+			_, parsed, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res = append(res, parsed)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name            string
+		serviceCIDRs    []networkingv1.ServiceCIDR
+		serviceIPRanges []*net.IPNet
+		want            []string
+	}{
+		{
+			name:            "default range",
+			serviceCIDRs:    []networkingv1.ServiceCIDR{serviceCIDR("10.43.0.0/16")},
+			serviceIPRanges: parseCIDRs("10.43.0.0/16"),
+		},
+		{
+			name:            "expanded range",
+			serviceCIDRs:    []networkingv1.ServiceCIDR{serviceCIDR("10.43.0.0/16")},
+			serviceIPRanges: parseCIDRs("10.40.0.0/13"),
+			want:            []string{"10.40.0.0/13"},
+		},
+		{
+			name:            "expanded range already covered",
+			serviceCIDRs:    []networkingv1.ServiceCIDR{serviceCIDR("10.43.0.0/16"), serviceCIDR("10.40.0.0/13")},
+			serviceIPRanges: parseCIDRs("10.40.0.0/13"),
+		},
+		{
+			name:            "dual-stack with expanded IPv6 range",
+			serviceCIDRs:    []networkingv1.ServiceCIDR{serviceCIDR("10.43.0.0/16", "fd00:43::/112")},
+			serviceIPRanges: parseCIDRs("10.43.0.0/16", "fd00:43::/108"),
+			want:            []string{"10.43.0.0/16", "fd00:43::/108"},
+		},
+		{
+			name:            "no existing ServiceCIDRs",
+			serviceIPRanges: parseCIDRs("10.43.0.0/16"),
+			want:            []string{"10.43.0.0/16"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uncoveredServiceCIDRs(tt.serviceCIDRs, tt.serviceIPRanges); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uncoveredServiceCIDRs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// watchCacheReportCount is the number of resources included in each watch cache report.
+const watchCacheReportCount = 10
+
+// watchCacheUsage contains watch cache metrics for a single resource.
+type watchCacheUsage struct {
+	Resource string
+	Objects  int64
+	Capacity int64
+}
+
+// reportWatchCacheUsage periodically scrapes kube-apiserver metrics, and logs the resources
+// with the most stored objects and largest watch cache capacity. The object count is the
+// best available proxy for watch cache memory use, as the apiserver keeps a copy of every
+// object of a cached resource in memory.
+func reportWatchCacheUsage(ctx context.Context, k8s kubernetes.Interface, interval time.Duration) {
+	logrus.Infof("Reporting kube-apiserver watch cache usage every %s", interval)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		b, err := k8s.Discovery().RESTClient().Get().AbsPath("/metrics").DoRaw(ctx)
+		if err != nil {
+			logrus.Warnf("Failed to retrieve kube-apiserver metrics for watch cache report: %v", err)
+			return
+		}
+		usage := parseWatchCacheUsage(b)
+		if len(usage) > watchCacheReportCount {
+			usage = usage[:watchCacheReportCount]
+		}
+		buf := &bytes.Buffer{}
+		w := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+		fmt.Fprint(w, "RESOURCE\tOBJECTS\tCAPACITY\n")
+		for _, u := range usage {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", u.Resource, u.Objects, u.Capacity)
+		}
+		w.Flush()
+		logrus.Infof("Top %d kube-apiserver watch caches by object count:\n%s", len(usage), buf.String())
+	}, interval)
+}
+
+// parseWatchCacheUsage extracts per-resource object counts and watch cache capacity from
+// kube-apiserver metrics in the Prometheus text exposition format. Results are sorted by
+// object count, then capacity, largest first.
+func parseWatchCacheUsage(b []byte) []watchCacheUsage {
+	resources := map[string]*watchCacheUsage{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, rest, ok := strings.Cut(line, "{")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "apiserver_storage_objects", "apiserver_resource_objects", "apiserver_watch_cache_capacity":
+		default:
+			continue
+		}
+		labels, value, ok := strings.Cut(rest, "} ")
+		if !ok {
+			continue
+		}
+		resource := metricLabel(labels, "resource")
+		if resource == "" {
+			continue
+		}
+		if group := metricLabel(labels, "group"); group != "" && !strings.Contains(resource, ".") {
+			resource += "." + group
+		}
+		v, err := strconv.ParseFloat(strings.Fields(value)[0], 64)
+		if err != nil || v < 0 {
+			continue
+		}
+		u := resources[resource]
+		if u == nil {
+			u = &watchCacheUsage{Resource: resource}
+			resources[resource] = u
+		}
+		if name == "apiserver_watch_cache_capacity" {
+			u.Capacity = int64(v)
+		} else {
+			u.Objects = int64(v)
+		}
+	}
+
+	usage := make([]watchCacheUsage, 0, len(resources))
+	for _, u := range resources {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Objects != usage[j].Objects {
+			return usage[i].Objects > usage[j].Objects
+		}
+		if usage[i].Capacity != usage[j].Capacity {
+			return usage[i].Capacity > usage[j].Capacity
+		}
+		return usage[i].Resource < usage[j].Resource
+	})
+	return usage
+}
+
+// metricLabel returns the value of the named label from a Prometheus label set.
+func metricLabel(labels, name string) string {
+	for _, label := range strings.Split(labels, ",") {
+		if k, v, ok := strings.Cut(label, "="); ok && k == name {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
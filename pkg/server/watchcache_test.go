@@ -0,0 +1,29 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_UnitParseWatchCacheUsage(t *testing.T) {
+	metrics := []byte(`# HELP apiserver_storage_objects [STABLE] Number of stored objects at the time of last check split by kind.
+# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="configmaps"} 12
+apiserver_storage_objects{resource="events"} 540
+apiserver_storage_objects{resource="deployments.apps"} 3
+# HELP apiserver_watch_cache_capacity [ALPHA] Total capacity of watch cache broken by resource type.
+# TYPE apiserver_watch_cache_capacity gauge
+apiserver_watch_cache_capacity{group="",resource="configmaps"} 100
+apiserver_watch_cache_capacity{group="",resource="events"} 1024
+apiserver_watch_cache_capacity{group="apps",resource="deployments"} 100
+apiserver_request_total{code="200",resource="pods"} 42
+`)
+	want := []watchCacheUsage{
+		{Resource: "events", Objects: 540, Capacity: 1024},
+		{Resource: "configmaps", Objects: 12, Capacity: 100},
+		{Resource: "deployments.apps", Objects: 3, Capacity: 100},
+	}
+	if got := parseWatchCacheUsage(metrics); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWatchCacheUsage() = %+v\nWant = %+v", got, want)
+	}
+}
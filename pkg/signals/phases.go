@@ -0,0 +1,189 @@
+package signals
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Phase is a stage of the shutdown sequence. Once a shutdown signal or request is received,
+// phases are run in order; each phase completes once all work holding the phase has finished,
+// or the phase timeout expires. The signal context is cancelled at the start of the final
+// phase, which stops all remaining components, including etcd.
+type Phase string
+
+const (
+	// PhaseJoins stops accepting new servers joining the cluster.
+	PhaseJoins Phase = "joins"
+	// PhaseSnapshots waits for in-progress etcd snapshots and uploads to complete.
+	PhaseSnapshots Phase = "snapshots"
+	// PhaseControllers stops the controller-manager, scheduler, and cloud-controller-manager.
+	PhaseControllers Phase = "controllers"
+	// PhaseKubelet stops the kubelet.
+	PhaseKubelet Phase = "kubelet"
+	// PhaseLeadership transfers etcd leadership to another member, if this member is the leader.
+	PhaseLeadership Phase = "leadership"
+	// PhaseETCD cancels the signal context, stopping all remaining components, including the apiserver and etcd.
+	PhaseETCD Phase = "etcd"
+)
+
+// Phases lists all shutdown phases, in the order that they are run.
+var Phases = []Phase{PhaseJoins, PhaseSnapshots, PhaseControllers, PhaseKubelet, PhaseLeadership, PhaseETCD}
+
+// DefaultPhaseTimeouts are the maximum time allowed for each phase.
+// A timeout of zero waits indefinitely.
+var DefaultPhaseTimeouts = map[Phase]time.Duration{
+	PhaseJoins:       5 * time.Second,
+	PhaseSnapshots:   5 * time.Minute,
+	PhaseControllers: 30 * time.Second,
+	PhaseKubelet:     time.Minute,
+	PhaseLeadership:  10 * time.Second,
+	PhaseETCD:        0,
+}
+
+type phase struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	timeout time.Duration
+}
+
+var (
+	phasesMu sync.Mutex
+	phases   = newPhases()
+)
+
+func newPhases() map[Phase]*phase {
+	p := map[Phase]*phase{}
+	for _, name := range Phases {
+		ctx, cancel := context.WithCancel(context.Background())
+		p[name] = &phase{ctx: ctx, cancel: cancel, timeout: DefaultPhaseTimeouts[name]}
+	}
+	return p
+}
+
+// ParsePhaseTimeouts parses a comma-separated list of phase=duration pairs.
+// Phases that are not listed use the default timeout.
+func ParsePhaseTimeouts(s string) (map[Phase]time.Duration, error) {
+	timeouts := map[Phase]time.Duration{}
+	for name, timeout := range DefaultPhaseTimeouts {
+		timeouts[name] = timeout
+	}
+	if s == "" {
+		return timeouts, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid shutdown phase timeout %q: must be in phase=duration format", pair)
+		}
+		if _, ok := DefaultPhaseTimeouts[Phase(name)]; !ok {
+			return nil, fmt.Errorf("unknown shutdown phase %q", name)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for shutdown phase %q: %w", name, err)
+		}
+		if timeout < 0 {
+			return nil, fmt.Errorf("timeout for shutdown phase %q must not be negative", name)
+		}
+		timeouts[Phase(name)] = timeout
+	}
+	return timeouts, nil
+}
+
+// SetPhaseTimeouts sets the maximum time allowed for each phase.
+func SetPhaseTimeouts(timeouts map[Phase]time.Duration) {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	for name, timeout := range timeouts {
+		if p, ok := phases[name]; ok {
+			p.timeout = timeout
+		}
+	}
+}
+
+// PhaseTimeout returns the maximum time allowed for the given phase.
+func PhaseTimeout(name Phase) time.Duration {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	return phases[name].timeout
+}
+
+// PhaseStarted returns true if the given shutdown phase has started.
+func PhaseStarted(name Phase) bool {
+	return phases[name].ctx.Err() != nil
+}
+
+// Hold delays completion of the given shutdown phase until the returned function is called,
+// or the phase timeout expires. If the phase has already started, false is returned, and the
+// caller should not start any new work.
+func Hold(name Phase) (func(), bool) {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	p := phases[name]
+	if p.ctx.Err() != nil {
+		return func() {}, false
+	}
+	p.wg.Add(1)
+	return sync.OnceFunc(p.wg.Done), true
+}
+
+// PhaseContext returns a copy of the parent context that is also cancelled when the given shutdown
+// phase starts, along with a function that must be called once the caller has stopped. The phase
+// does not complete until the function is called, or the phase timeout expires.
+func PhaseContext(parent context.Context, name Phase) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	release, ok := Hold(name)
+	if !ok {
+		cancel()
+		return ctx, release
+	}
+	stop := context.AfterFunc(phases[name].ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		release()
+	}
+}
+
+// runPhases runs all shutdown phases prior to the final phase, in order.
+func runPhases() {
+	for _, name := range Phases[:len(Phases)-1] {
+		phasesMu.Lock()
+		p := phases[name]
+		p.cancel()
+		phasesMu.Unlock()
+
+		logrus.Infof("Running shutdown phase %s", name)
+		if !Wait(&p.wg, p.timeout) {
+			logrus.Warnf("Shutdown phase %s did not complete within %s", name, p.timeout)
+		}
+	}
+	logrus.Infof("Running shutdown phase %s", PhaseETCD)
+	phases[PhaseETCD].cancel()
+}
+
+// Wait waits for the WaitGroup, returning false if the timeout expires first.
+// A timeout of zero waits indefinitely.
+func Wait(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if timeout == 0 {
+		<-done
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package signals
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_UnitParsePhaseTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts string
+		want     map[Phase]time.Duration
+		wantErr  bool
+	}{
+		{
+			name: "Defaults",
+			want: DefaultPhaseTimeouts,
+		},
+		{
+			name:     "Overrides",
+			timeouts: "snapshots=10m, etcd=30s",
+			want: map[Phase]time.Duration{
+				PhaseJoins:       5 * time.Second,
+				PhaseSnapshots:   10 * time.Minute,
+				PhaseControllers: 30 * time.Second,
+				PhaseKubelet:     time.Minute,
+				PhaseLeadership:  10 * time.Second,
+				PhaseETCD:        30 * time.Second,
+			},
+		},
+		{
+			name:     "Unknown phase",
+			timeouts: "apiserver=10s",
+			wantErr:  true,
+		},
+		{
+			name:     "Invalid duration",
+			timeouts: "kubelet=10",
+			wantErr:  true,
+		},
+		{
+			name:     "Negative duration",
+			timeouts: "kubelet=-10s",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePhaseTimeouts(tt.timeouts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePhaseTimeouts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePhaseTimeouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,8 +13,8 @@ var signalHandler chan os.Signal
 var shutdownHandler chan error
 
 // SetupSignalHandler registers for SIGTERM and SIGINT. A context is returned
-// which is cancelled on one of these signals. If a second signal is caught, the program
-// is terminated with exit code 1.
+// which is cancelled once the shutdown phases have been run following one of these signals.
+// If a second signal is caught, the program is terminated with exit code 1.
 func SetupSignalContext() context.Context {
 	close(onlyOneSignalHandler) // panics when called twice
 
@@ -34,7 +34,10 @@ func SetupSignalContext() context.Context {
 				logrus.Infof("Shutdown request received")
 			}
 		}
-		cancel()
+		go func() {
+			runPhases()
+			cancel()
+		}()
 		s := <-signalHandler
 		logrus.Infof("Second shutdown signal received: %s, exiting...", s)
 
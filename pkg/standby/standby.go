@@ -0,0 +1,177 @@
+// Package standby implements control-plane standby servers. A standby server runs etcd and the supervisor, keeping
+// a full copy of the cluster datastore, but does not run the apiserver, scheduler, or controller-manager until it is
+// promoted. Promotion is requested via the CLI, or happens automatically when no other server's apiserver has been
+// ready for the configured timeout. Once promoted, the server records the promotion and exits, so that it is
+// restarted by its service manager with the control-plane components enabled.
+package standby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/daemons/executor"
+	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/signals"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// checkInterval is the interval at which the apiservers on other servers are checked.
+	checkInterval = 10 * time.Second
+	// checkTimeout is the maximum time allowed to list and check the apiservers.
+	checkTimeout = 5 * time.Second
+)
+
+// promotedFile returns the path of the file within the server data dir that records that the standby server
+// has been promoted.
+func promotedFile(dataDir string) string {
+	return filepath.Join(dataDir, "standby-promoted")
+}
+
+// Promoted returns true if the standby server with the given server data dir has been promoted.
+func Promoted(dataDir string) bool {
+	_, err := os.Stat(promotedFile(dataDir))
+	return err == nil
+}
+
+// Configure validates the server configuration for a control-plane standby, and disables the apiserver, scheduler,
+// and controller-manager if the standby has not yet been promoted. Standby servers must join an existing cluster,
+// and require embedded etcd, so that the datastore is kept in sync while the standby is not promoted. The
+// server data dir is passed separately, as it has not yet been resolved in the control config.
+func Configure(control *config.Control, dataDir string) error {
+	if !control.ControlPlaneStandby {
+		return nil
+	}
+	if control.JoinURL == "" {
+		return errors.New("invalid flag use; --server is required with --control-plane-standby")
+	}
+	if control.DisableETCD || control.Datastore.Endpoint != "" {
+		return errors.New("invalid flag use; --control-plane-standby requires embedded etcd")
+	}
+	if control.EtcdWitness {
+		return errors.New("invalid flag use; cannot use --control-plane-standby with --etcd-witness")
+	}
+	if Promoted(dataDir) {
+		logrus.Infof("Control-plane standby has been promoted; delete %s and restart to return to standby", promotedFile(dataDir))
+		return nil
+	}
+	control.DisableAPIServer = true
+	control.DisableControllerManager = true
+	control.DisableScheduler = true
+	return nil
+}
+
+// Promote records that the standby server has been promoted, and requests shutdown, so that the server is
+// restarted with the control-plane components enabled.
+func Promote(control *config.Control, reason string) error {
+	if !control.ControlPlaneStandby {
+		return errors.New("server is not a control-plane standby")
+	}
+	if Promoted(control.DataDir) {
+		return nil
+	}
+	if err := os.WriteFile(promotedFile(control.DataDir), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0600); err != nil {
+		return err
+	}
+	logrus.Warnf("Promoting control-plane standby: %s", reason)
+	signals.RequestShutdown(fmt.Errorf("control-plane standby promoted; restarting %s to start control-plane components", version.Program))
+	return nil
+}
+
+// Monitor automatically promotes the standby server if no other server's apiserver has been ready for the
+// promotion timeout. The standby is not promoted while the apiserver addresses cannot be read from etcd, as
+// starting the control-plane components would not help if etcd has lost quorum. Automatic promotion is
+// disabled if the timeout is zero, or the standby has already been promoted.
+func Monitor(ctx context.Context, control *config.Control) {
+	timeout := control.StandbyPromoteTimeout.Duration
+	if !control.ControlPlaneStandby || timeout == 0 {
+		return
+	}
+
+	// the data dir is not resolved until the server is started, so wait for etcd before checking for promotion.
+	select {
+	case <-ctx.Done():
+		return
+	case <-executor.ETCDReadyChan():
+	}
+	if Promoted(control.DataDir) {
+		return
+	}
+
+	logrus.Infof("Control-plane standby will be promoted if no apiserver is ready for %s", timeout)
+	lastReady := time.Now()
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+		addresses, err := etcd.GetAPIServerURLsFromETCD(ctx, control)
+		if err != nil {
+			logrus.Warnf("Failed to get apiserver addresses from etcd: %v", err)
+			return
+		}
+		if err := checkAPIServers(ctx, control, addresses); err != nil {
+			logrus.Warnf("No apiserver is ready for %s: %v", time.Since(lastReady).Round(time.Second), err)
+			if time.Since(lastReady) >= timeout {
+				if err := Promote(control, fmt.Sprintf("no apiserver has been ready for %s", timeout)); err != nil {
+					logrus.Errorf("Failed to promote control-plane standby: %v", err)
+				}
+			}
+			return
+		}
+		lastReady = time.Now()
+	}, checkInterval)
+}
+
+// checkAPIServers returns an error if none of the apiservers at the given addresses are ready. Readiness is checked
+// via the load-balancer readiness endpoint on the supervisor of each server, which does not require authentication.
+func checkAPIServers(ctx context.Context, control *config.Control, addresses []string) error {
+	cacerts, err := os.ReadFile(control.Runtime.ServerCA)
+	if err != nil {
+		return err
+	}
+	client := clientaccess.GetHTTPClient(cacerts, "", "")
+
+	var errs []error
+	for _, address := range addresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		u := url.URL{
+			Scheme: "https",
+			Host:   net.JoinHostPort(host, strconv.Itoa(control.SupervisorPort)),
+			Path:   "/v1-" + version.Program + "/lb/readyz",
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", u.String(), resp.Status))
+	}
+	if len(errs) == 0 {
+		return errors.New("no apiserver addresses found")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package standby
+
+import (
+	"os"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+)
+
+func Test_UnitConfigure(t *testing.T) {
+	tests := []struct {
+		name         string
+		control      config.Control
+		promoted     bool
+		wantErr      bool
+		wantDisabled bool
+	}{
+		{
+			name:    "not a standby",
+			control: config.Control{JoinURL: "https://10.0.0.1:6443"},
+		},
+		{
+			name:         "standby",
+			control:      config.Control{ControlPlaneStandby: true, JoinURL: "https://10.0.0.1:6443"},
+			wantDisabled: true,
+		},
+		{
+			name:     "promoted standby",
+			control:  config.Control{ControlPlaneStandby: true, JoinURL: "https://10.0.0.1:6443"},
+			promoted: true,
+		},
+		{
+			name:    "standby without server",
+			control: config.Control{ControlPlaneStandby: true},
+			wantErr: true,
+		},
+		{
+			name:    "standby without embedded etcd",
+			control: config.Control{ControlPlaneStandby: true, JoinURL: "https://10.0.0.1:6443", DisableETCD: true},
+			wantErr: true,
+		},
+		{
+			name:    "standby witness",
+			control: config.Control{ControlPlaneStandby: true, EtcdWitness: true, JoinURL: "https://10.0.0.1:6443"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if tt.promoted {
+				if err := os.WriteFile(promotedFile(dataDir), nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			control := tt.control
+			if err := Configure(&control, dataDir); (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			disabled := control.DisableAPIServer && control.DisableControllerManager && control.DisableScheduler
+			if !tt.wantErr && disabled != tt.wantDisabled {
+				t.Errorf("Configure() disabled control-plane = %v, want %v", disabled, tt.wantDisabled)
+			}
+		})
+	}
+}
+
+func Test_UnitPromote(t *testing.T) {
+	control := &config.Control{DataDir: t.TempDir()}
+	if err := Promote(control, "test"); err == nil {
+		t.Errorf("Promote() of a server that is not a standby did not return an error")
+	}
+	if Promoted(control.DataDir) {
+		t.Errorf("Promoted() = true for a server that is not a standby")
+	}
+}
@@ -0,0 +1,205 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	coreclient "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultInterval is the interval at which reports are sent, if not set.
+	DefaultInterval = 24 * time.Hour
+	// requestTimeout is the timeout for sending a report to the endpoint.
+	requestTimeout = 30 * time.Second
+	// controlPlaneLabel identifies server nodes, for counting servers and agents.
+	controlPlaneLabel = "node-role.kubernetes.io/control-plane"
+)
+
+// Report is the anonymous feature usage report sent to the telemetry endpoint. It does not contain
+// names, addresses, credentials, or other information that identifies the cluster or its nodes;
+// the cluster ID is a hash of the kube-system namespace UID, so that reports from the same cluster
+// can be aggregated without revealing the UID itself.
+type Report struct {
+	ClusterID          string         `json:"clusterID"`
+	Version            string         `json:"version"`
+	Datastore          string         `json:"datastore"`
+	CNI                string         `json:"cni"`
+	DisabledComponents []string       `json:"disabledComponents,omitempty"`
+	Servers            int            `json:"servers"`
+	Agents             int            `json:"agents"`
+	NodesByArch        map[string]int `json:"nodesByArch"`
+	NodesByOS          map[string]int `json:"nodesByOS"`
+}
+
+// ValidateEndpoint checks that the telemetry endpoint, if set, is an http or https URL.
+func ValidateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("telemetry endpoint %q must be an http or https URL", endpoint)
+	}
+	return nil
+}
+
+// DatastoreType returns the type of datastore for a datastore endpoint, without any of the
+// connection details. Servers without a datastore endpoint use embedded etcd if they are
+// initializing or joining a cluster, and sqlite otherwise.
+func DatastoreType(endpoint string, embeddedEtcd bool) string {
+	if endpoint == "" {
+		if embeddedEtcd {
+			return "embedded-etcd"
+		}
+		return "sqlite"
+	}
+	scheme, _, ok := strings.Cut(endpoint, "://")
+	if !ok {
+		return "unknown"
+	}
+	switch scheme {
+	case "http", "https":
+		return "etcd"
+	case "mysql", "postgres", "sqlite", "nats", "t4", "dns+srv":
+		return scheme
+	}
+	return "unknown"
+}
+
+// Register starts sending reports to the telemetry endpoint at the configured interval. Reports are
+// only sent by the leader, and failures are logged without being retried until the next interval.
+func Register(ctx context.Context, k8s kubernetes.Interface, nodes coreclient.NodeCache, control *config.Control) error {
+	t := control.Telemetry
+	interval := t.Interval.Duration
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	r := &reporter{
+		k8s:      k8s,
+		nodes:    nodes,
+		control:  control,
+		client:   &http.Client{Timeout: requestTimeout},
+		endpoint: t.Endpoint,
+	}
+	logrus.Infof("Sending anonymous feature usage reports to %s every %s", t.Endpoint, interval)
+	go wait.JitterUntilWithContext(ctx, r.send, interval, 0.1, true)
+	return nil
+}
+
+type reporter struct {
+	k8s      kubernetes.Interface
+	nodes    coreclient.NodeCache
+	control  *config.Control
+	client   *http.Client
+	endpoint string
+}
+
+func (r *reporter) send(ctx context.Context) {
+	report, err := r.report(ctx)
+	if err != nil {
+		logrus.Warnf("Failed to generate feature usage report: %v", err)
+		return
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		logrus.Warnf("Failed to encode feature usage report: %v", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		logrus.Warnf("Failed to create feature usage report request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.Program+"/"+version.Version)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		logrus.Warnf("Failed to send feature usage report: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logrus.Warnf("Failed to send feature usage report: %s", resp.Status)
+		return
+	}
+	logrus.Debugf("Sent feature usage report to %s", r.endpoint)
+}
+
+// report generates the feature usage report from the server configuration and the current list of nodes.
+func (r *reporter) report(ctx context.Context) (*Report, error) {
+	ns, err := r.k8s.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodeList, err := r.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return newReport(r.control, string(ns.UID), nodeList), nil
+}
+
+// newReport returns a report for the server configuration and nodes. The kube-system namespace UID
+// is hashed to produce an anonymous cluster ID.
+func newReport(control *config.Control, namespaceUID string, nodeList []*corev1.Node) *Report {
+	clusterID := sha256.Sum256([]byte(namespaceUID))
+	report := &Report{
+		ClusterID:   hex.EncodeToString(clusterID[:]),
+		Version:     version.Version,
+		Datastore:   control.Telemetry.Datastore,
+		CNI:         cniType(control),
+		NodesByArch: map[string]int{},
+		NodesByOS:   map[string]int{},
+	}
+	for component, disabled := range control.Disables {
+		if disabled {
+			report.DisabledComponents = append(report.DisabledComponents, component)
+		}
+	}
+	slices.Sort(report.DisabledComponents)
+	for _, node := range nodeList {
+		if node.Labels[controlPlaneLabel] == "true" {
+			report.Servers++
+		} else {
+			report.Agents++
+		}
+		report.NodesByArch[nodeLabel(node, corev1.LabelArchStable)]++
+		report.NodesByOS[nodeLabel(node, corev1.LabelOSStable)]++
+	}
+	return report
+}
+
+// cniType returns the flannel backend, or "none" if flannel is disabled in favor of another CNI.
+func cniType(control *config.Control) string {
+	if control.FlannelBackend == "none" {
+		return "none"
+	}
+	return "flannel-" + control.FlannelBackend
+}
+
+func nodeLabel(node *corev1.Node, label string) string {
+	if value := node.Labels[label]; value != "" {
+		return value
+	}
+	return "unknown"
+}
@@ -0,0 +1,112 @@
+package tpm
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/util/errors"
+	"github.com/k3s-io/k3s/pkg/version"
+)
+
+// MaxClockSkew is the maximum difference between the proof time and the current time.
+// Proofs are bound to the request body, so a replayed proof can only be used to
+// repeat the original request; the time is only checked to limit how long captured requests are useful.
+const MaxClockSkew = 15 * time.Minute
+
+var (
+	// HeaderName is the request header that contains the encoded key proof.
+	HeaderName = version.Program + "-Node-TPM-Key-Proof"
+
+	ErrVerifyFailed = errors.New("TPM key proof signature verification failed")
+)
+
+// KeyProof proves possession of a TPM-resident signing key for a request made on behalf of a node.
+// The key is a primary key derived from the TPM's storage hierarchy seed, which never leaves the TPM,
+// so the same key cannot be produced by another TPM, even from a cloned disk image.
+//
+// This is trust-on-first-use key pinning, not remote attestation: the server records the key the first time
+// it is presented for a node, and requires the same key from then on. The signing key is not certified by the
+// endorsement key, and the endorsement key is not checked against the TPM manufacturer's certificate, so the
+// first proof for a node does not show that the key is resident in a genuine TPM. The endorsement key is only
+// included so that operators can identify the TPM.
+type KeyProof struct {
+	// EK is the PKIX-encoded public endorsement key
+	EK []byte `json:"ek"`
+	// Key is the PKIX-encoded public signing key
+	Key []byte `json:"key"`
+	// Time is the time at which the proof was created
+	Time time.Time `json:"time"`
+	// Signature is the ASN.1-encoded ECDSA signature of the digest, made with the signing key
+	Signature []byte `json:"signature"`
+}
+
+// Digest returns the digest signed by the signing key, which binds the proof to the node name,
+// the proof time, and the request body.
+func Digest(nodeName string, t time.Time, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%x", nodeName, t.UTC().Format(time.RFC3339), bodyHash)
+	return h.Sum(nil)
+}
+
+// Encode returns the key proof encoded for use as a header value.
+func (p *KeyProof) Encode() (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Decode decodes a key proof from a header value.
+func Decode(value string) (*KeyProof, error) {
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to decode TPM key proof")
+	}
+	p := &KeyProof{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode TPM key proof")
+	}
+	return p, nil
+}
+
+// Verify verifies that the proof was signed by the signing key for the node name and request body,
+// within the allowed clock skew of the current time. It does not verify that the key is resident in a TPM.
+func (p *KeyProof) Verify(nodeName string, body []byte, now time.Time) error {
+	if skew := now.Sub(p.Time).Abs(); skew > MaxClockSkew {
+		return fmt.Errorf("TPM key proof time %s differs from server time by %s", p.Time.Format(time.RFC3339), skew.Round(time.Second))
+	}
+	ek, err := x509.ParsePKIXPublicKey(p.EK)
+	if err != nil {
+		return errors.WithMessage(err, "failed to parse TPM endorsement key")
+	}
+	switch ek.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported TPM endorsement key type %T", ek)
+	}
+	key, err := x509.ParsePKIXPublicKey(p.Key)
+	if err != nil {
+		return errors.WithMessage(err, "failed to parse TPM signing key")
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported TPM signing key type %T", key)
+	}
+	if !ecdsa.VerifyASN1(pub, Digest(nodeName, p.Time, body), p.Signature) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of a PKIX-encoded public key, for display.
+func Fingerprint(pub []byte) string {
+	return fmt.Sprintf("SHA256:%x", sha256.Sum256(pub))
+}
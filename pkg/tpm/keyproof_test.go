@@ -0,0 +1,88 @@
+package tpm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func Test_UnitKeyProofVerify(t *testing.T) {
+	ekKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := x509.MarshalPKIXPublicKey(&ekKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	body := []byte("csr")
+	signature, err := ecdsa.SignASN1(rand.Reader, key, Digest("node1", now, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		nodeName string
+		body     []byte
+		now      time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			nodeName: "node1",
+			body:     body,
+			now:      now,
+		},
+		{
+			name:     "different node",
+			nodeName: "node2",
+			body:     body,
+			now:      now,
+			wantErr:  true,
+		},
+		{
+			name:     "different body",
+			nodeName: "node1",
+			body:     []byte("other csr"),
+			now:      now,
+			wantErr:  true,
+		},
+		{
+			name:     "expired",
+			nodeName: "node1",
+			body:     body,
+			now:      now.Add(MaxClockSkew + time.Minute),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := (&KeyProof{EK: ek, Key: pub, Time: now, Signature: signature}).Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Decode(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Verify(tt.nodeName, tt.body, tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
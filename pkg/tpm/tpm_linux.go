@@ -0,0 +1,143 @@
+//go:build linux
+
+package tpm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+	"github.com/k3s-io/k3s/pkg/util/errors"
+)
+
+// keyTemplate is the template for the signing key. As a primary key in the storage hierarchy,
+// the same key is created from the template each time, until the TPM is cleared.
+var keyTemplate = tpm2.TPMTPublic{
+	Type:    tpm2.TPMAlgECC,
+	NameAlg: tpm2.TPMAlgSHA256,
+	ObjectAttributes: tpm2.TPMAObject{
+		FixedTPM:            true,
+		FixedParent:         true,
+		SensitiveDataOrigin: true,
+		UserWithAuth:        true,
+		SignEncrypt:         true,
+	},
+	Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgECC, &tpm2.TPMSECCParms{
+		Symmetric: tpm2.TPMTSymDefObject{Algorithm: tpm2.TPMAlgNull},
+		Scheme: tpm2.TPMTECCScheme{
+			Scheme:  tpm2.TPMAlgECDSA,
+			Details: tpm2.NewTPMUAsymScheme(tpm2.TPMAlgECDSA, &tpm2.TPMSSigSchemeECDSA{HashAlg: tpm2.TPMAlgSHA256}),
+		},
+		CurveID: tpm2.TPMECCNistP256,
+		KDF:     tpm2.TPMTKDFScheme{Scheme: tpm2.TPMAlgNull},
+	}),
+	Unique: tpm2.NewTPMUPublicID(tpm2.TPMAlgECC, &tpm2.TPMSECCPoint{
+		X: tpm2.TPM2BECCParameter{Buffer: make([]byte, 32)},
+		Y: tpm2.TPM2BECCParameter{Buffer: make([]byte, 32)},
+	}),
+}
+
+// Prove creates a key proof for a request body, using the TPM at the given device path.
+func Prove(device, nodeName string, body []byte) (*KeyProof, error) {
+	t, err := linuxtpm.Open(device)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to open TPM %s", device)
+	}
+	defer t.Close()
+
+	ek, err := createPrimary(t, tpm2.TPMRHEndorsement, tpm2.RSAEKTemplate)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create TPM endorsement key")
+	}
+	defer flush(t, ek.ObjectHandle)
+	ekPub, err := publicKey(&ek.OutPublic)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read TPM endorsement key")
+	}
+
+	key, err := createPrimary(t, tpm2.TPMRHOwner, keyTemplate)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create TPM signing key")
+	}
+	defer flush(t, key.ObjectHandle)
+	keyPub, err := publicKey(&key.OutPublic)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read TPM signing key")
+	}
+
+	p := &KeyProof{EK: ekPub, Key: keyPub, Time: time.Now().UTC().Truncate(time.Second)}
+	rsp, err := tpm2.Sign{
+		KeyHandle: tpm2.NamedHandle{Handle: key.ObjectHandle, Name: key.Name},
+		Digest:    tpm2.TPM2BDigest{Buffer: Digest(nodeName, p.Time, body)},
+		InScheme: tpm2.TPMTSigScheme{
+			Scheme:  tpm2.TPMAlgECDSA,
+			Details: tpm2.NewTPMUSigScheme(tpm2.TPMAlgECDSA, &tpm2.TPMSSchemeHash{HashAlg: tpm2.TPMAlgSHA256}),
+		},
+		Validation: tpm2.TPMTTKHashCheck{Tag: tpm2.TPMSTHashCheck},
+	}.Execute(t)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign with TPM signing key")
+	}
+	sig, err := rsp.Signature.Signature.ECDSA()
+	if err != nil {
+		return nil, err
+	}
+	p.Signature, err = asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(sig.SignatureR.Buffer),
+		S: new(big.Int).SetBytes(sig.SignatureS.Buffer),
+	})
+	return p, err
+}
+
+func createPrimary(t transport.TPM, hierarchy tpm2.TPMHandle, template tpm2.TPMTPublic) (*tpm2.CreatePrimaryResponse, error) {
+	return tpm2.CreatePrimary{
+		PrimaryHandle: hierarchy,
+		InPublic:      tpm2.New2B(template),
+	}.Execute(t)
+}
+
+func flush(t transport.TPM, handle tpm2.TPMHandle) {
+	_, _ = tpm2.FlushContext{FlushHandle: handle}.Execute(t)
+}
+
+// publicKey returns the PKIX encoding of a TPM RSA or ECC P256 public key.
+func publicKey(outPublic *tpm2.TPM2BPublic) ([]byte, error) {
+	pub, err := outPublic.Contents()
+	if err != nil {
+		return nil, err
+	}
+	switch pub.Type {
+	case tpm2.TPMAlgRSA:
+		params, err := pub.Parameters.RSADetail()
+		if err != nil {
+			return nil, err
+		}
+		modulus, err := pub.Unique.RSA()
+		if err != nil {
+			return nil, err
+		}
+		exponent := int(params.Exponent)
+		if exponent == 0 {
+			exponent = 65537
+		}
+		return x509.MarshalPKIXPublicKey(&rsa.PublicKey{N: new(big.Int).SetBytes(modulus.Buffer), E: exponent})
+	case tpm2.TPMAlgECC:
+		point, err := pub.Unique.ECC()
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point.X.Buffer),
+			Y:     new(big.Int).SetBytes(point.Y.Buffer),
+		})
+	}
+	return nil, errors.New("unsupported TPM key type")
+}
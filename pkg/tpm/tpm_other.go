@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tpm
+
+import "github.com/k3s-io/k3s/pkg/util/errors"
+
+// Prove is not supported on this platform.
+func Prove(device, nodeName string, body []byte) (*KeyProof, error) {
+	return nil, errors.New("TPM key pinning is only supported on Linux")
+}
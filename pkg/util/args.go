@@ -22,6 +22,13 @@ func ArgValue(searchArg string, extraArgs []string) string {
 	return value
 }
 
+// ArgName returns the name of the flag set by an extra arg, without leading hyphens,
+// value, or the suffix used to prepend or append to the default value.
+func ArgName(arg string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(arg, hyphens), "=")
+	return strings.TrimRight(name, "-+")
+}
+
 // GetArgs appends extra arguments to existing arguments with logic to override any default
 // arguments whilst also allowing to prefix and suffix default string slice arguments.
 func GetArgs(initialArgs map[string]string, extraArgs []string) []string {
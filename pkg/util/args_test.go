@@ -187,3 +187,23 @@ func Test_UnitGetArgs(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitArgName(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "max-pods=250", want: "max-pods"},
+		{arg: "--max-pods=250", want: "max-pods"},
+		{arg: "enable-admission-plugins+=NodeRestriction", want: "enable-admission-plugins"},
+		{arg: "tls-cipher-suites-=TLS_AES_128_GCM_SHA256", want: "tls-cipher-suites"},
+		{arg: "profiling", want: "profiling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			if got := ArgName(tt.arg); got != tt.want {
+				t.Errorf("ArgName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
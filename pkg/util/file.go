@@ -2,6 +2,7 @@ package util
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -18,24 +19,27 @@ func SetFileModeForPath(name string, mode os.FileMode) error {
 }
 
 func SetFileGroupForPath(name string, group string) error {
+	gid, err := GroupID(group)
+	if err != nil {
+		return err
+	}
+	return os.Chown(name, -1, gid)
+}
+
+// GroupID returns the numeric ID of the group, which may be given by name or ID.
+func GroupID(group string) (int, error) {
 	// Try to use as group id
 	gid, err := strconv.Atoi(group)
 	if err == nil {
-		return os.Chown(name, -1, gid)
+		return gid, nil
 	}
 
 	// Otherwise, it must be a group name
 	g, err := user.LookupGroup(group)
 	if err != nil {
-		return err
-	}
-
-	gid, err = strconv.Atoi(g.Gid)
-	if err != nil {
-		return err
+		return 0, err
 	}
-
-	return os.Chown(name, -1, gid)
+	return strconv.Atoi(g.Gid)
 }
 
 func SetFileModeForFile(file *os.File, mode os.FileMode) error {
@@ -64,25 +68,58 @@ func ReadFile(ctx context.Context, path string) (string, error) {
 // AtomicWrite firsts writes data to a temp file, then renames to the destination file.
 // This ensures that the destination file is never partially written.
 func AtomicWrite(fileName string, data []byte, perm os.FileMode) error {
-	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp")
+	return AtomicWriteFunc(fileName, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// AtomicWriteFunc calls the provided function to write to a temp file, then syncs and renames
+// it to the destination file, and syncs the parent directory. This ensures that the destination
+// file is never partially written, even if the host loses power while the file is being written.
+func AtomicWriteFunc(fileName string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := filepath.Dir(fileName)
+	f, err := os.CreateTemp(dir, filepath.Base(fileName)+".tmp")
 	if err != nil {
 		return err
 	}
 	tmpName := f.Name()
 	defer os.Remove(tmpName)
-	if _, err := f.Write(data); err != nil {
+	if err := write(f); err != nil {
 		f.Close()
 		return err
 	}
 	if err := f.Chmod(perm); err != nil {
+		f.Close()
 		return err
 	}
 	if err := f.Sync(); err != nil {
+		f.Close()
 		return err
 	}
 	if err := f.Close(); err != nil {
 		return err
 	}
+	if err := os.Rename(tmpName, fileName); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
 
-	return os.Rename(tmpName, fileName)
+// WriteCert atomically writes a certificate to the given path, creating the parent directory
+// if necessary. Permissions match those set by the certutil function of the same name.
+func WriteCert(certPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return err
+	}
+	return AtomicWrite(certPath, data, 0644)
+}
+
+// WriteKey atomically writes a private key to the given path, creating the parent directory
+// if necessary. Permissions match those set by the certutil function of the same name.
+func WriteKey(keyPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		return err
+	}
+	return AtomicWrite(keyPath, data, 0600)
 }
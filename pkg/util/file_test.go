@@ -0,0 +1,71 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_UnitAtomicWriteFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		write    func(w io.Writer) error
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "New file",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new")
+				return err
+			},
+			want: "new",
+		},
+		{
+			name:     "Replace existing file",
+			existing: "old",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new")
+				return err
+			},
+			want: "new",
+		},
+		{
+			name:     "Write error leaves existing file",
+			existing: "old",
+			write: func(w io.Writer) error {
+				io.WriteString(w, "partial")
+				return errors.New("write failed")
+			},
+			want:    "old",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "config")
+			if tt.existing != "" {
+				if err := os.WriteFile(fileName, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := AtomicWriteFunc(fileName, 0600, tt.write); (err != nil) != tt.wantErr {
+				t.Errorf("AtomicWriteFunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("AtomicWriteFunc() content = %q, want %q", got, tt.want)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("AtomicWriteFunc() left %d files in directory, want 1", len(entries))
+			}
+		})
+	}
+}
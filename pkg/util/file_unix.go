@@ -0,0 +1,15 @@
+//go:build !windows
+
+package util
+
+import "os"
+
+// syncDir syncs the directory, so that a file renamed into it is persisted.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
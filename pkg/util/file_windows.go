@@ -0,0 +1,9 @@
+//go:build windows
+
+package util
+
+// syncDir is a no-op on Windows, where directories cannot be opened for syncing;
+// NTFS journals metadata changes such as renames.
+func syncDir(dir string) error {
+	return nil
+}